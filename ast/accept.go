@@ -17,6 +17,10 @@ func (rs *ReturnStatement) Accept(v Visitor) error {
 	return v.VisitReturnStatement(rs)
 }
 
+func (ys *YieldStatement) Accept(v Visitor) error {
+	return v.VisitYieldStatement(ys)
+}
+
 func (es *ExpressionStatement) Accept(v Visitor) error {
 	return v.VisitExpressionStatement(es)
 }
@@ -29,6 +33,10 @@ func (sl *StringLiteral) Accept(v Visitor) error {
 	return v.VisitStringLiteral(sl)
 }
 
+func (bl *BooleanLiteral) Accept(v Visitor) error {
+	return v.VisitBooleanLiteral(bl)
+}
+
 func (id *Identifier) Accept(v Visitor) error {
 	return v.VisitIdentifier(id)
 }
@@ -45,6 +53,10 @@ func (ce *CallExpression) Accept(v Visitor) error {
 	return v.VisitCallExpression(ce)
 }
 
+func (na *NamedArgument) Accept(v Visitor) error {
+	return v.VisitNamedArgument(na)
+}
+
 func (al *ArrayLiteral) Accept(v Visitor) error {
 	return v.VisitArrayLiteral(al)
 }
@@ -1,16 +1,49 @@
 package ast
 
-import "compiler/lexer"
+import (
+	"compiler/lexer"
+	"strings"
+)
 
+// AssemblyExpression is either a call to a runtime helper,
+// asm("builtin_name", args...), or an inline assembly block:
+//
+//	asm volatile intel {
+//	    "mov %1, %0"
+//	    : "=r"(out)
+//	    : "r"(in)
+//	    : "memory"
+//	}
 type AssemblyExpression struct {
 	Token lexer.LangToken
-	Code  *StringLiteral
+	Code  *StringLiteral // Helper name, or the block's lines joined by "\n"
 	Args  []ExpressionNode
+
+	// Block form only.
+	Block    bool
+	Volatile bool
+	Dialect  string // "att" (the default) or "intel"
+	Outputs  []*AsmOperand
+	Inputs   []*AsmOperand
+	Clobbers []*StringLiteral
+}
+
+// AsmOperand binds a constraint such as "=r" to a Y expression.
+type AsmOperand struct {
+	Constraint *StringLiteral
+	Value      ExpressionNode
+}
+
+func (op *AsmOperand) String() string {
+	return op.Constraint.String() + "(" + op.Value.String() + ")"
 }
 
 func (ae *AssemblyExpression) expressionNode()      {}
 func (ae *AssemblyExpression) TokenLiteral() string { return ae.Token.Literal }
 func (ae *AssemblyExpression) String() string {
+	if ae.Block {
+		return ae.blockString()
+	}
 	s := "asm(" + ae.Code.String()
 	for _, arg := range ae.Args {
 		s += ", " + arg.String()
@@ -19,6 +52,47 @@ func (ae *AssemblyExpression) String() string {
 	return s
 }
 
+func (ae *AssemblyExpression) blockString() string {
+	var sb strings.Builder
+	sb.WriteString("asm ")
+	if ae.Volatile {
+		sb.WriteString("volatile ")
+	}
+	if ae.Dialect != "" {
+		sb.WriteString(ae.Dialect + " ")
+	}
+	sb.WriteString("{ ")
+	for i, line := range strings.Split(ae.Code.Value, "\n") {
+		if i > 0 {
+			sb.WriteString(" ")
+		}
+		sb.WriteString((&StringLiteral{Value: line}).String())
+	}
+	// Trailing empty sections are left out.
+	var sections [3][]string
+	for _, op := range ae.Outputs {
+		sections[0] = append(sections[0], op.String())
+	}
+	for _, op := range ae.Inputs {
+		sections[1] = append(sections[1], op.String())
+	}
+	for _, c := range ae.Clobbers {
+		sections[2] = append(sections[2], c.String())
+	}
+	last := len(sections) - 1
+	for last >= 0 && len(sections[last]) == 0 {
+		last--
+	}
+	for _, section := range sections[:last+1] {
+		sb.WriteString(" :")
+		if len(section) > 0 {
+			sb.WriteString(" " + strings.Join(section, ", "))
+		}
+	}
+	sb.WriteString(" }")
+	return sb.String()
+}
+
 func (ae *AssemblyExpression) Accept(v Visitor) error {
 	visitor, ok := v.(interface {
 		VisitAssemblyExpression(*AssemblyExpression) error
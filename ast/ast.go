@@ -3,6 +3,8 @@ package ast
 import (
 	. "compiler/lexer"
 	"fmt"
+	"strconv"
+	"strings"
 )
 
 type Node interface {
@@ -45,6 +47,22 @@ func (nl *NumberLiteral) String() string {
 	return fmt.Sprintf("%v", nl.Value)
 }
 
+// Int returns the value of an integer literal exactly, which Value cannot
+// hold beyond 2^53. Hexadecimal literals above the i64 range wrap, so
+// 0xffffffffffffffff is -1. ok is false for a literal with a fraction.
+func (nl *NumberLiteral) Int() (v int64, ok bool) {
+	lit := nl.Token.Literal
+	if strings.HasPrefix(lit, "0x") || strings.HasPrefix(lit, "0X") {
+		u, err := strconv.ParseUint(lit[2:], 16, 64)
+		return int64(u), err == nil
+	}
+	if strings.Contains(lit, ".") {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(lit, 10, 64)
+	return v, err == nil
+}
+
 type StringLiteral struct {
 	Token LangToken
 	Value string
@@ -56,6 +74,17 @@ func (sl *StringLiteral) String() string {
 	return fmt.Sprintf("\"%s\"", sl.Value)
 }
 
+type BooleanLiteral struct {
+	Token LangToken
+	Value bool
+}
+
+func (bl *BooleanLiteral) expressionNode()      {}
+func (bl *BooleanLiteral) TokenLiteral() string { return bl.Token.Literal }
+func (bl *BooleanLiteral) String() string {
+	return fmt.Sprintf("%t", bl.Value)
+}
+
 type InfixExpression struct {
 	Token    LangToken
 	Left     ExpressionNode
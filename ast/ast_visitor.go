@@ -8,10 +8,12 @@ type Visitor interface {
 	VisitExpressionStatement(es *ExpressionStatement) error
 	VisitNumberLiteral(nl *NumberLiteral) error
 	VisitStringLiteral(sl *StringLiteral) error
+	VisitBooleanLiteral(bl *BooleanLiteral) error
 	VisitIdentifier(id *Identifier) error
 	VisitInfixExpression(ie *InfixExpression) error
 	VisitPrefixExpression(pe *PrefixExpression) error
 	VisitCallExpression(ce *CallExpression) error
+	VisitNamedArgument(na *NamedArgument) error
 	VisitArrayLiteral(al *ArrayLiteral) error
 	VisitLambdaExpression(le *LambdaExpression) error
 	VisitMemberAccessExpression(mae *MemberAccessExpression) error
@@ -21,6 +23,8 @@ type Visitor interface {
 	VisitVariableDeclaration(vd *VariableDeclaration) error
 	VisitIfStatement(is *IfStatement) error
 	VisitWhileStatement(ws *WhileStatement) error
+	VisitForInStatement(fs *ForInStatement) error
+	VisitYieldStatement(ys *YieldStatement) error
 	VisitTraditionalTernaryExpression(te *TraditionalTernaryExpression) error
 	VisitLambdaStyleTernaryExpression(aste *LambdaStyleTernaryExpression) error
 	VisitInlineIfElseTernaryExpression(iite *InlineIfElseTernaryExpression) error
@@ -28,6 +32,7 @@ type Visitor interface {
 	VisitSyscallExpression(se *SyscallExpression) error
 	VisitImportStatement(is *ImportStatement) error
 	VisitAssemblyExpression(ae *AssemblyExpression) error
+	VisitCastExpression(ce *CastExpression) error
 
 	// ac: todo add more visit methods here
 }
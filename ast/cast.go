@@ -0,0 +1,20 @@
+package ast
+
+import "compiler/lexer"
+
+// CastExpression converts a value to another type, e.g. `buf as *u16`.
+type CastExpression struct {
+	Token lexer.LangToken // The 'as' token
+	Value ExpressionNode
+	Type  *Identifier
+}
+
+func (ce *CastExpression) expressionNode()      {}
+func (ce *CastExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CastExpression) String() string {
+	return "(" + ce.Value.String() + " as " + ce.Type.String() + ")"
+}
+
+func (ce *CastExpression) Accept(v Visitor) error {
+	return v.VisitCastExpression(ce)
+}
@@ -10,6 +10,30 @@ type ClassDeclaration struct {
 	Name        *Identifier
 	Members     []*ClassMember
 	LambdaStyle bool
+	Layout      StructLayout
+}
+
+// StructLayout selects how the fields of a type are laid out in memory.
+type StructLayout int
+
+const (
+	// StructLayoutDefault leaves the field order to the compiler.
+	StructLayoutDefault StructLayout = iota
+	// StructLayoutExtern keeps the declared order with C alignment, for
+	// structures shared with the kernel or C code.
+	StructLayoutExtern
+	// StructLayoutPacked keeps the declared order without any padding.
+	StructLayoutPacked
+)
+
+func (l StructLayout) String() string {
+	switch l {
+	case StructLayoutExtern:
+		return "extern"
+	case StructLayoutPacked:
+		return "packed"
+	}
+	return ""
 }
 
 func (cd *ClassDeclaration) expressionNode()      {}
@@ -19,7 +43,11 @@ func (cd *ClassDeclaration) String() string {
 	for _, member := range cd.Members {
 		members = append(members, member.String())
 	}
-	return cd.Name.String() + " {" + strings.Join(members, " ") + "}"
+	prefix := ""
+	if cd.Layout != StructLayoutDefault {
+		prefix = cd.Layout.String() + " "
+	}
+	return prefix + cd.Name.String() + " {" + strings.Join(members, " ") + "}"
 }
 
 type CallExpression struct {
@@ -38,9 +66,26 @@ func (ce *CallExpression) String() string {
 	return ce.Function.String() + "(" + strings.Join(args, ", ") + ")"
 }
 
+// NamedArgument is a call-site argument bound to a parameter by name, e.g.
+// the `b = 2` in f(b = 2, a = 1).
+type NamedArgument struct {
+	Token lexer.LangToken // The '=' token
+	Name  *Identifier
+	Value ExpressionNode
+}
+
+func (na *NamedArgument) expressionNode()      {}
+func (na *NamedArgument) TokenLiteral() string { return na.Token.Literal }
+func (na *NamedArgument) String() string {
+	return na.Name.String() + " = " + na.Value.String()
+}
+
+// Field is a field of a data declaration: `let name` or `let name: Type`.
+// Fields without a type hold any value.
 type Field struct {
 	Token lexer.LangToken // The 'let' token
 	Name  *Identifier
+	Type  *Identifier // Optional type annotation
 }
 
 func (f *Field) expressionNode()      {}
@@ -89,14 +134,30 @@ func (md *MethodDeclaration) String() string {
 	return md.ReturnType.String() + " " + md.Name.String() + "(" + strings.Join(params, ", ") + ") " + md.Body.String()
 }
 
+// Parameter is a single entry in the parameter list of a function, lambda or
+// method: `name`, `name: Type` or `name: Type = default`.
 type Parameter struct {
-	Token lexer.LangToken // The identifier token
-	Name  *Identifier
-	Type  *Identifier
+	Token   lexer.LangToken // The identifier token
+	Name    *Identifier
+	Type    *Identifier    // Optional type annotation
+	Default ExpressionNode // Optional default value, evaluated at the call site
+}
+
+// IsVariadic reports whether the parameter collects the remaining arguments
+// (declared as `name: ...T`).
+func (p *Parameter) IsVariadic() bool {
+	return p.Type != nil && strings.HasPrefix(p.Type.Value, "...")
 }
 
 func (p *Parameter) expressionNode()      {}
 func (p *Parameter) TokenLiteral() string { return p.Token.Literal }
 func (p *Parameter) String() string {
-	return p.Type.String() + " " + p.Name.String()
+	out := p.Name.String()
+	if p.Type != nil {
+		out += ": " + p.Type.String()
+	}
+	if p.Default != nil {
+		out += " = " + p.Default.String()
+	}
+	return out
 }
@@ -85,3 +85,31 @@ func (ws *WhileStatement) String() string {
 	}
 	return out.String()
 }
+
+// ForInStatement represents a 'for item in iterable { body }' loop over an
+// array, a slice or a generator.
+type ForInStatement struct {
+	Token    LangToken      // The 'for' token
+	Variable *Identifier    // Loop variable, bound to each element in turn
+	Iterable ExpressionNode // Expression producing the elements
+	Body     ExpressionNode // Loop body (BlockStatement)
+}
+
+func (fs *ForInStatement) Accept(visitor Visitor) error {
+	return visitor.VisitForInStatement(fs)
+}
+
+func (fs *ForInStatement) statementNode()       {}
+func (fs *ForInStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForInStatement) String() string {
+	var out strings.Builder
+	out.WriteString("for ")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(" ")
+	if fs.Body != nil {
+		out.WriteString(fs.Body.String())
+	}
+	return out.String()
+}
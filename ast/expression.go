@@ -6,9 +6,18 @@ import (
 )
 
 type LetStatement struct {
-	Token lexer.LangToken // the TokenTypeLet token
+	Token lexer.LangToken // the TokenTypeLet or TokenTypeConst token
 	Name  *Identifier
+	Type  *Identifier // Optional type annotation (let x: T = ...)
 	Value ExpressionNode
+	// ThreadLocal marks a top-level variable of which every thread has its
+	// own copy (thread_local let x = ...).
+	ThreadLocal bool
+}
+
+// IsConst reports whether the statement declares a constant (const x = ...).
+func (ls *LetStatement) IsConst() bool {
+	return ls.Token.Type == lexer.TokenTypeConst
 }
 
 func (ls *LetStatement) statementNode()       {}
@@ -20,7 +29,18 @@ func (ls *LetStatement) String() string {
 func (ls *LetStatement) StringIndent(indent int) string {
 	indentStr := strings.Repeat("    ", indent)
 	var out strings.Builder
-	out.WriteString("let " + ls.Name.String() + " = " + ls.Value.String() + ";")
+	keyword := "let "
+	if ls.IsConst() {
+		keyword = "const "
+	}
+	if ls.ThreadLocal {
+		keyword = "thread_local " + keyword
+	}
+	out.WriteString(keyword + ls.Name.String())
+	if ls.Type != nil {
+		out.WriteString(": " + ls.Type.String())
+	}
+	out.WriteString(" = " + ls.Value.String() + ";")
 	return indentStr + out.String()
 }
 
@@ -47,6 +67,19 @@ func (rs *ReturnStatement) StringIndent(indent int) string {
 	return indentStr + out.String()
 }
 
+// YieldStatement hands a value to the caller of a generator function and
+// suspends the generator until the next value is requested.
+type YieldStatement struct {
+	Token lexer.LangToken // the TokenTypeYield token
+	Value ExpressionNode
+}
+
+func (ys *YieldStatement) statementNode()       {}
+func (ys *YieldStatement) TokenLiteral() string { return ys.Token.Literal }
+func (ys *YieldStatement) String() string {
+	return "yield " + ys.Value.String() + ";"
+}
+
 type ExpressionStatement struct {
 	Token      lexer.LangToken // the first token of the expression
 	Expression ExpressionNode
@@ -9,9 +9,12 @@ type FunctionDefinition struct {
 	Token      lexer.LangToken // The first token of the expression
 	Name       *Identifier
 	Expression ExpressionNode
-	Parameters []*Identifier
+	Parameters []*Parameter
 	Body       ExpressionNode
 	ReturnType *Identifier
+	// Generator is set for 'function* name(...)'; ReturnType is then the
+	// type of the values it yields.
+	Generator bool
 }
 
 func (es *FunctionDefinition) expressionNode() {
@@ -37,6 +40,9 @@ func (fd *FunctionDefinition) StringIndent(indent int) string {
 
 	var out strings.Builder
 	// function header
+	if fd.Generator {
+		out.WriteString("function* ")
+	}
 	if fd.Name != nil {
 		out.WriteString(fd.Name.String())
 	} else {
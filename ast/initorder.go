@@ -0,0 +1,151 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// InitCycleError reports top-level declarations whose initializers refer to
+// each other, each to the next and the last to the first.
+type InitCycleError struct {
+	Cycle []*LetStatement
+}
+
+func (e *InitCycleError) Error() string {
+	var out strings.Builder
+	first := e.Cycle[0]
+	fmt.Fprintf(&out, "%s '%s' at line %d: initialization cycle: ", declKind(first), first.Name.Value, first.Token.Line+1)
+	for i, ls := range e.Cycle {
+		next := e.Cycle[(i+1)%len(e.Cycle)]
+		if i > 0 {
+			out.WriteString(", ")
+		}
+		fmt.Fprintf(&out, "'%s' refers to '%s'", ls.Name.Value, next.Name.Value)
+		if i > 0 {
+			fmt.Fprintf(&out, " at line %d", ls.Token.Line+1)
+		}
+	}
+	return out.String()
+}
+
+func declKind(ls *LetStatement) string {
+	if ls.IsConst() {
+		return "const"
+	}
+	return "let"
+}
+
+// InitOrder returns the top-level declarations of a module in the order
+// their initializers must run: each after the declarations its initializer
+// names, and otherwise in source order, so a declaration may refer to one
+// further down the file. Initializers that refer to each other, directly or
+// through other declarations, are reported as an *InitCycleError.
+//
+// Only the names an initializer spells out count; a function it calls may
+// still read a global that is not initialized yet, and gets its zero value.
+func InitOrder(globals []*LetStatement) ([]*LetStatement, error) {
+	byName := make(map[string]*LetStatement, len(globals))
+	for _, ls := range globals {
+		if _, dup := byName[ls.Name.Value]; !dup {
+			byName[ls.Name.Value] = ls
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[*LetStatement]int, len(globals))
+	order := make([]*LetStatement, 0, len(globals))
+	var path []*LetStatement
+	var visit func(ls *LetStatement) error
+	visit = func(ls *LetStatement) error {
+		switch state[ls] {
+		case done:
+			return nil
+		case visiting:
+			for i, p := range path {
+				if p == ls {
+					return &InitCycleError{Cycle: append([]*LetStatement(nil), path[i:]...)}
+				}
+			}
+		}
+		state[ls] = visiting
+		path = append(path, ls)
+		for _, name := range initRefs(ls.Value) {
+			if dep, ok := byName[name]; ok {
+				if err := visit(dep); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[ls] = done
+		order = append(order, ls)
+		return nil
+	}
+	for _, ls := range globals {
+		if err := visit(ls); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// initRefs returns the names expr refers to, in the order they appear. Names
+// expr declares itself, parameters of lambdas and variables of the blocks in
+// it, are left out wherever they appear, as they may shadow globals.
+func initRefs(expr ExpressionNode) []string {
+	var refs []string
+	declared := make(map[string]bool)
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.Interface:
+			if !v.IsNil() {
+				walk(v.Elem())
+			}
+		case reflect.Pointer:
+			if v.IsNil() || v.Elem().Kind() != reflect.Struct {
+				return
+			}
+			if id, ok := v.Interface().(*Identifier); ok {
+				refs = append(refs, id.Value)
+				return
+			}
+			walk(v.Elem())
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				f := v.Type().Field(i)
+				if !f.IsExported() {
+					continue
+				}
+				if f.Type == identifierType {
+					// An identifier held as such rather than as an expression
+					// names a member or a type, or declares a name.
+					if id := v.Field(i).Interface().(*Identifier); id != nil && (f.Name == "Name" || f.Name == "Variable") {
+						declared[id.Value] = true
+					}
+					continue
+				}
+				walk(v.Field(i))
+			}
+		case reflect.Slice:
+			for i := 0; i < v.Len(); i++ {
+				walk(v.Index(i))
+			}
+		}
+	}
+	walk(reflect.ValueOf(expr))
+	names := refs[:0]
+	for _, name := range refs {
+		if !declared[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+var identifierType = reflect.TypeOf((*Identifier)(nil))
@@ -0,0 +1,174 @@
+package ast
+
+import "reflect"
+
+// ParamKeeper tells whether a function keeps an argument after it returns,
+// by storing it in memory or in a global, or by passing it to a function
+// that keeps it, and whether it returns the argument. Both backends use it to
+// reject passing a sequence whose frame is on the caller's stack to such a
+// function, e.g. async.spawn, or returning it from the caller.
+//
+// Only what the body spells out counts: a parameter copied into a local and
+// from there elsewhere is followed, but one passed through a function
+// pointer or a lambda is assumed not to be kept.
+type ParamKeeper struct {
+	// Callee returns the function a call in the body of fn names, or nil
+	// when it cannot be known statically.
+	Callee func(fn *FunctionDefinition, call *CallExpression) *FunctionDefinition
+
+	memo map[keptParam]paramUse
+}
+
+type keptParam struct {
+	fn *FunctionDefinition
+	i  int
+}
+
+type paramUse struct {
+	kept, returned bool
+}
+
+// Keeps reports whether fn keeps its parameter i.
+func (k *ParamKeeper) Keeps(fn *FunctionDefinition, i int) bool {
+	return k.use(fn, i).kept
+}
+
+// Returns reports whether fn may return its parameter i.
+func (k *ParamKeeper) Returns(fn *FunctionDefinition, i int) bool {
+	return k.use(fn, i).returned
+}
+
+func (k *ParamKeeper) use(fn *FunctionDefinition, i int) paramUse {
+	key := keptParam{fn, i}
+	if use, ok := k.memo[key]; ok {
+		return use
+	}
+	if k.memo == nil {
+		k.memo = make(map[keptParam]paramUse)
+	}
+	// Recursive calls see neither until the answer is known.
+	k.memo[key] = paramUse{}
+	var use paramUse
+	if !fn.Generator && i < len(fn.Parameters) {
+		use = k.uses(fn, fn.Parameters[i].Name.Value)
+	}
+	k.memo[key] = use
+	return use
+}
+
+// uses reports what the body of fn does with the value of the parameter
+// name.
+func (k *ParamKeeper) uses(fn *FunctionDefinition, name string) paramUse {
+	aliases := map[string]bool{name: true}
+	locals := make(map[string]bool)
+	for _, p := range fn.Parameters {
+		locals[p.Name.Value] = true
+	}
+	var use paramUse
+	// passes returns what the callee of call does with the arguments that
+	// are aliases.
+	passes := func(call *CallExpression) paramUse {
+		var use paramUse
+		for j, arg := range call.Arguments {
+			named, isNamed := arg.(*NamedArgument)
+			if isNamed {
+				arg = named.Value
+			}
+			id, ok := arg.(*Identifier)
+			if !ok || !aliases[id.Value] {
+				continue
+			}
+			callee := k.Callee(fn, call)
+			if callee == nil {
+				continue
+			}
+			if isNamed {
+				j = paramIndex(callee, named.Name.Value)
+			}
+			if j >= 0 {
+				u := k.use(callee, j)
+				use.kept = use.kept || u.kept
+				use.returned = use.returned || u.returned
+			}
+		}
+		return use
+	}
+	isAlias := func(e ExpressionNode) bool {
+		switch e := e.(type) {
+		case *Identifier:
+			return aliases[e.Value]
+		case *CallExpression:
+			return passes(e).returned
+		}
+		return false
+	}
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		if use.kept {
+			return
+		}
+		switch v.Kind() {
+		case reflect.Interface:
+			if !v.IsNil() {
+				walk(v.Elem())
+			}
+			return
+		case reflect.Slice:
+			for i := 0; i < v.Len(); i++ {
+				walk(v.Index(i))
+			}
+			return
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				if v.Type().Field(i).IsExported() {
+					walk(v.Field(i))
+				}
+			}
+			return
+		case reflect.Pointer:
+			if v.IsNil() || v.Elem().Kind() != reflect.Struct {
+				return
+			}
+		default:
+			return
+		}
+		switch n := v.Interface().(type) {
+		case *LetStatement:
+			locals[n.Name.Value] = true
+			if isAlias(n.Value) {
+				aliases[n.Name.Value] = true
+			}
+		case *AssignmentExpression:
+			if isAlias(n.Right) {
+				id, ok := n.Left.(*Identifier)
+				if !ok || !locals[id.Value] {
+					use.kept = true
+					return
+				}
+				aliases[id.Value] = true
+			}
+		case *ReturnStatement:
+			if n.ReturnValue != nil && isAlias(n.ReturnValue) {
+				use.returned = true
+			}
+		case *CallExpression:
+			if passes(n).kept {
+				use.kept = true
+				return
+			}
+		}
+		walk(v.Elem())
+	}
+	walk(reflect.ValueOf(fn.Body))
+	return use
+}
+
+// paramIndex returns the index of the parameter name of fn, or -1.
+func paramIndex(fn *FunctionDefinition, name string) int {
+	for i, p := range fn.Parameters {
+		if p.Name.Value == name {
+			return i
+		}
+	}
+	return -1
+}
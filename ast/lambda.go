@@ -7,7 +7,7 @@ import (
 
 type LambdaExpression struct {
 	Token      lexer.LangToken // the TokenTypeLeftParenthesis token
-	Parameters []*Identifier
+	Parameters []*Parameter
 	Body       ExpressionNode
 }
 
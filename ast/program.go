@@ -8,6 +8,8 @@ type Program struct {
 	Functions         []*FunctionDefinition
 	DataStructures    []*DataStructure
 	ImportStatements  []*ImportStatement
+	Globals           []*LetStatement // Top-level let and const declarations, in source order
+	Tests             []*TestBlock
 }
 
 func (p *Program) TokenLiteral() string {
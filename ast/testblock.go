@@ -0,0 +1,25 @@
+package ast
+
+import (
+	"compiler/lexer"
+	"strings"
+)
+
+// TestBlock is a top-level `test "name" { ... }` block. Test blocks are only
+// compiled for `ylang test`, each into a function the test harness runs.
+type TestBlock struct {
+	Token lexer.LangToken // The 'test' token
+	Name  *StringLiteral
+	Body  *BlockStatement
+}
+
+func (tb *TestBlock) statementNode()       {}
+func (tb *TestBlock) TokenLiteral() string { return tb.Token.Literal }
+func (tb *TestBlock) String() string {
+	var out strings.Builder
+	out.WriteString("test \"")
+	out.WriteString(tb.Name.Value)
+	out.WriteString("\" ")
+	out.WriteString(tb.Body.String())
+	return out.String()
+}
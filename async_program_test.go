@@ -0,0 +1,165 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestAsyncProgram runs a stdlib/async event loop over pipes and socketpairs:
+// fd callbacks, timers, and generator tasks suspended on readable, writable
+// and sleep.
+func TestAsyncProgram(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name: "callbacks, timers and tasks",
+			input: `
+			import "stdlib/fmt";
+			import "stdlib/async";
+			import "stdlib/mem";
+
+			let got: *i64 = alloc(64) as *i64;
+
+			function onTick(loop: *async.Loop, fd: i64, n: i64, ctx: *u8): i64 -> {
+				got[0] = got[0] + 1;
+				printf("tick %d\n", got[0]);
+				if (got[0] == 3) {
+					async.cancel(loop, fd);
+				}
+				return 0;
+			}
+
+			function onRead(loop: *async.Loop, fd: i64, events: i64, ctx: *u8): i64 -> {
+				let buf = alloc(64);
+				let n = async.read(fd, buf, 63);
+				if (n > 0) {
+					buf[n] = 0;
+					printf("read %d: %s\n", n, buf as string);
+				} else {
+					printf("eof %d\n", n);
+					async.unwatch(loop, fd);
+				}
+				free(buf, 64);
+				return 0;
+			}
+
+			function* echo(fd: i64, out: i64): i64 -> {
+				let buf = alloc(64);
+				let n: i64 = -11;
+				let done = false;
+				while (!done) {
+					n = async.read(fd, buf, 64);
+					if (n == -11) {
+						yield async.readable(fd);
+					} else if (n <= 0) {
+						done = true;
+					} else {
+						async.write(out, buf, n);
+					}
+				}
+				printf("echo done\n");
+			}
+
+			function* sleeper(): i64 -> {
+				printf("sleep start\n");
+				yield async.sleep(60);
+				printf("slept\n");
+			}
+
+			main() -> {
+				let loop = async.newLoop();
+				let fds: i64 = 0;
+				let p = &fds as *i32;
+				async.pipe(p);
+				async.watch(loop, p[0], async.EPOLLIN, onRead);
+				async.write(p[1], "hello" as *u8, 5);
+				async.timer(loop, 5, 5, onTick);
+				let sp: i64 = 0;
+				let s = &sp as *i32;
+				async.socketpair(s);
+				let q: i64 = 0;
+				let qp = &q as *i32;
+				async.pipe(qp);
+				async.spawn(loop, echo(s[1], qp[1]));
+				async.spawn(loop, sleeper());
+				async.write(s[0], "ping" as *u8, 4);
+				syscall(SYS.close, s[0], 0,0,0,0,0);
+				syscall(SYS.close, p[1], 0,0,0,0,0);
+				let r = async.run(loop);
+				let buf = alloc(16);
+				let n = async.read(qp[0], buf, 15);
+				buf[n] = 0;
+				printf("run %d echoed %s\n", r, buf as string);
+				async.close(loop);
+				return 0;
+			}`,
+			expected: []string{"sleep start", "read 5: hello", "echo done", "eof 0", "tick 1", "tick 2", "tick 3", "slept", "run 0 echoed ping"},
+		},
+		{
+			name: "tasks talking over a socketpair",
+			input: `
+			import "stdlib/fmt";
+			import "stdlib/async";
+			import "stdlib/mem";
+			import "stdlib/sys";
+
+			function* pinger(fd: i64, rounds: i64): i64 -> {
+				let buf = alloc(8);
+				let i = 0;
+				while (i < rounds) {
+					buf[0] = 48 + i;
+					while (async.write(fd, buf, 1) == -sys.EAGAIN) { yield async.writable(fd); }
+					while (async.read(fd, buf, 1) == -sys.EAGAIN) { yield async.readable(fd); }
+					printf("ping got %d\n", buf[0]);
+					i = i + 1;
+				}
+			}
+
+			function* ponger(fd: i64): i64 -> {
+				let buf = alloc(8);
+				let n = async.read(fd, buf, 1);
+				while (n != 0) {
+					if (n == -sys.EAGAIN) {
+						yield async.readable(fd);
+					} else {
+						buf[0] = buf[0] + 17;
+						async.write(fd, buf, 1);
+					}
+					n = async.read(fd, buf, 1);
+				}
+				printf("pong eof\n");
+			}
+
+			function* closer(fd: i64): i64 -> {
+				yield async.sleep(60);
+				syscall(SYS.close, fd, 0, 0, 0, 0, 0);
+			}
+
+			main() -> {
+				let loop = async.newLoop();
+				let sp: i64 = 0;
+				let s = &sp as *i32;
+				async.socketpair(s);
+				async.spawn(loop, pinger(s[0], 3));
+				async.spawn(loop, ponger(s[1]));
+				async.spawn(loop, closer(s[0]));
+				async.timer(loop, 20, 0, (l: *async.Loop, fd: i64, n: i64, c: *u8) -> printf("once %d\n", n));
+				printf("run %d\n", async.run(loop));
+				return 0;
+			}`,
+			expected: []string{"ping got 65", "ping got 66", "ping got 67", "once 1", "pong eof", "run 0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := runLines(t, buildFreestanding(t, tt.input))
+			if strings.Join(lines, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("program output mismatch\nGot:\n%s\nWant:\n%s", strings.Join(lines, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,376 @@
+// Command ylang compiles Y-lang programs.
+//
+// Usage:
+//
+//	ylang build [--target arch] [--freestanding] [--emit-llvm] [-o output] file.y
+//	ylang test [--target arch] [--freestanding] [--run pattern] [-v] file.y
+//	ylang run [--freestanding] file.y [args...]
+//	ylang repl [--freestanding]
+//	ylang lsp [--target arch]
+//
+// build writes LLVM IR with --emit-llvm, and otherwise links an executable
+// with clang. Freestanding programs get their own _start and are linked with
+// -nostdlib -static, so no C runtime is involved.
+//
+// test compiles the `test "name" { ... }` blocks of a file into a harness
+// that runs each test in its own process, and exits with status 1 when one
+// fails. --run runs only the tests whose name contains pattern and -v
+// reports passed tests too.
+//
+// run runs a program with the interpreter, without clang, passing it the
+// arguments after the file, and exits with the program's status.
+//
+// repl reads statements, expressions and declarations from the standard
+// input and runs them with the interpreter; :help lists its commands.
+//
+// lsp is a language server speaking the language server protocol on the
+// standard input and output, for editors.
+package main
+
+import (
+	"compiler/ast"
+	c "compiler/compiler"
+	"compiler/compiler/interpreter"
+	"compiler/compiler/target"
+	l "compiler/lexer"
+	"compiler/lsp"
+	"compiler/module"
+	p "compiler/parser"
+	"compiler/repl"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "build":
+		err = build(os.Args[2:])
+	case "test":
+		var status int
+		if status, err = test(os.Args[2:]); err == nil && status != 0 {
+			os.Exit(status)
+		}
+	case "run":
+		var status int
+		if status, err = run(os.Args[2:]); err == nil {
+			os.Exit(status)
+		}
+	case "repl":
+		var status int
+		if status, err = replCmd(os.Args[2:]); err == nil {
+			os.Exit(status)
+		}
+	case "lsp":
+		err = lspCmd(os.Args[2:])
+	case "help", "-h", "--help":
+		usage()
+		return
+	default:
+		err = fmt.Errorf("unknown command '%s'", os.Args[1])
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ylang: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: ylang build [--target arch] [--freestanding] [--emit-llvm] [-o output] file.y\n")
+	fmt.Fprintf(os.Stderr, "       ylang test [--target arch] [--freestanding] [--run pattern] [-v] file.y\n")
+	fmt.Fprintf(os.Stderr, "       ylang run [--freestanding] file.y [args...]\n")
+	fmt.Fprintf(os.Stderr, "       ylang repl [--freestanding]\n")
+	fmt.Fprintf(os.Stderr, "       ylang lsp [--target arch]\n")
+}
+
+func build(args []string) error {
+	fs := flag.NewFlagSet("build", flag.ContinueOnError)
+	targetName := fs.String("target", target.Default.Name, "target architecture or triple (amd64, arm64, riscv64)")
+	freestanding := fs.Bool("freestanding", false, "emit _start and link without the C runtime (-nostdlib -static)")
+	emitLLVM := fs.Bool("emit-llvm", false, "write LLVM IR instead of an executable")
+	output := fs.String("o", "", "output file (default: the input name without .y, plus .ll with --emit-llvm)")
+	cc := fs.String("cc", "clang", "C compiler used to link the executable")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("build expects exactly one source file")
+	}
+	input := fs.Arg(0)
+
+	tgt, err := target.Lookup(*targetName)
+	if err != nil {
+		return err
+	}
+	// What the parser and the code generator report on stdout is not part
+	// of the build; errors are returned.
+	restore := silenceStdout()
+	ir, err := compileFile(input, tgt, *freestanding)
+	restore()
+	if err != nil {
+		return err
+	}
+
+	out := *output
+	if out == "" {
+		out = strings.TrimSuffix(input, filepath.Ext(input))
+		if *emitLLVM {
+			out += ".ll"
+		}
+	}
+	if *emitLLVM {
+		return os.WriteFile(out, []byte(ir), 0o644)
+	}
+
+	return link(ir, out, tgt, *freestanding, *cc)
+}
+
+// link builds the executable out from the LLVM IR ir with the C compiler cc.
+func link(ir, out string, tgt *target.Target, freestanding bool, cc string) error {
+	tmpDir, err := os.MkdirTemp("", "ylang")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	irFile := filepath.Join(tmpDir, filepath.Base(out)+".ll")
+	if err := os.WriteFile(irFile, []byte(ir), 0o644); err != nil {
+		return err
+	}
+
+	ccArgs := []string{"--target=" + tgt.Triple, irFile, "-o", out}
+	if freestanding {
+		ccArgs = append(ccArgs, "-nostdlib", "-static")
+	} else {
+		// The float intrinsics of stdlib/math may become libm calls.
+		ccArgs = append(ccArgs, "-lm")
+	}
+	cmd := exec.Command(cc, ccArgs...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w", cc, err)
+	}
+	return nil
+}
+
+// test compiles the test blocks of a source file into a harness, runs it
+// and returns its exit status. The file is compiled as the module it is
+// imported as, so the tests of lib/stdlib/fs/fs.y test stdlib/fs itself.
+func test(args []string) (int, error) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	targetName := fs.String("target", target.Default.Name, "target architecture or triple (amd64, arm64, riscv64)")
+	freestanding := fs.Bool("freestanding", false, "emit _start and link without the C runtime (-nostdlib -static)")
+	run := fs.String("run", "", "run only the tests whose name contains this pattern")
+	verbose := fs.Bool("v", false, "report every test, not only failed ones")
+	cc := fs.String("cc", "clang", "C compiler used to link the harness")
+	if err := fs.Parse(args); err != nil {
+		return 0, err
+	}
+	if fs.NArg() != 1 {
+		return 0, fmt.Errorf("test expects exactly one source file")
+	}
+	input := fs.Arg(0)
+	if _, err := os.Stat(input); err != nil {
+		return 0, err
+	}
+
+	tgt, err := target.Lookup(*targetName)
+	if err != nil {
+		return 0, err
+	}
+	compiler := c.NewCompiler(c.LLVM, tgt)
+	compiler.Freestanding = *freestanding
+	modulePath, ok := module.NewModuleManager().ModulePath(input)
+	if !ok {
+		// Not below a module directory: make the file's own directory one.
+		compiler.SearchPaths = []string{filepath.Dir(input)}
+		modulePath = strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	}
+	compiler.TestModule = modulePath
+	// As for build, stdout is left to the harness.
+	restore := silenceStdout()
+	result := compiler.Compile(&ast.Program{ImportStatements: []*ast.ImportStatement{{Path: modulePath}}})
+	restore()
+	if len(result.Errors) > 0 {
+		return 0, fmt.Errorf("%s: %s", input, strings.Join(result.Errors, "\n"))
+	}
+
+	tmpDir, err := os.MkdirTemp("", "ylang-test")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tmpDir)
+	harness := filepath.Join(tmpDir, path.Base(modulePath)+".test")
+	if err := link(result.Output, harness, tgt, *freestanding, *cc); err != nil {
+		return 0, err
+	}
+
+	var harnessArgs []string
+	if *run != "" {
+		harnessArgs = append(harnessArgs, "--run", *run)
+	}
+	if *verbose {
+		harnessArgs = append(harnessArgs, "--verbose")
+	}
+	cmd := exec.Command(harness, harnessArgs...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() > 0 {
+		return exitErr.ExitCode(), nil
+	}
+	return 0, err
+}
+
+// run interprets a program and returns its exit status. The program sees
+// the host's system calls, so it is run for the host's architecture.
+func run(args []string) (int, error) {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	freestanding := fs.Bool("freestanding", false, "run as a program linked without the C runtime, without libm")
+	if err := fs.Parse(args); err != nil {
+		return 0, err
+	}
+	if fs.NArg() < 1 {
+		return 0, fmt.Errorf("run expects a source file")
+	}
+	input := fs.Arg(0)
+	tgt, err := target.Lookup(runtime.GOARCH)
+	if err != nil {
+		return 0, err
+	}
+	program, err := parseFile(input)
+	if err != nil {
+		return 0, err
+	}
+
+	compiler := c.NewCompiler(c.Interpreter, tgt)
+	compiler.Freestanding = *freestanding
+	compiler.SourceFile = input
+	compiler.Args = fs.Args()
+	compiler.Env = os.Environ()
+	// The parser reports on stdout while imported modules are loaded, which
+	// would be mixed into the program's own output; its errors are returned
+	// with the result.
+	compiler.Stdout = os.Stdout
+	defer silenceStdout()()
+	result := compiler.Compile(program)
+	if len(result.Errors) > 0 {
+		return 0, fmt.Errorf("%s: %s", input, strings.Join(result.Errors, "\n"))
+	}
+	return result.ExitStatus, nil
+}
+
+// replCmd runs an interactive session on the standard input.
+func replCmd(args []string) (int, error) {
+	fs := flag.NewFlagSet("repl", flag.ContinueOnError)
+	freestanding := fs.Bool("freestanding", false, "run as a program linked without the C runtime, without libm")
+	if err := fs.Parse(args); err != nil {
+		return 0, err
+	}
+	if fs.NArg() != 0 {
+		return 0, fmt.Errorf("repl takes no arguments; use :load file.y")
+	}
+	tgt, err := target.Lookup(runtime.GOARCH)
+	if err != nil {
+		return 0, err
+	}
+
+	in := interpreter.New(tgt)
+	in.Freestanding = *freestanding
+	in.Stdout = os.Stdout
+	in.Args = []string{"repl"}
+	in.Env = os.Environ()
+	// The code read from the standard input cannot read it too.
+	in.Stdin = strings.NewReader("")
+	session := repl.New(in, tgt, os.Stdout)
+	// As for run, what the parser and the code generator report on stdout
+	// is not part of the session.
+	defer silenceStdout()()
+	return session.Run(os.Stdin)
+}
+
+// lspCmd serves the language server protocol on the standard input and
+// output until the editor asks the server to exit.
+func lspCmd(args []string) error {
+	fs := flag.NewFlagSet("lsp", flag.ContinueOnError)
+	targetName := fs.String("target", target.Default.Name, "target architecture or triple (amd64, arm64, riscv64)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return fmt.Errorf("lsp takes no arguments")
+	}
+	tgt, err := target.Lookup(*targetName)
+	if err != nil {
+		return err
+	}
+	server := lsp.NewServer(tgt)
+	out := os.Stdout
+	// The protocol owns stdout; what the parser and the code generator
+	// report there would corrupt it.
+	defer silenceStdout()()
+	return server.Serve(os.Stdin, out)
+}
+
+// silenceStdout points os.Stdout at the null device, where the parser and
+// the code generator report their progress, until the function it returns
+// is called.
+func silenceStdout() (restore func()) {
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		return func() {}
+	}
+	stdout := os.Stdout
+	os.Stdout = null
+	return func() {
+		os.Stdout = stdout
+		null.Close()
+	}
+}
+
+// parseFile parses a source file.
+func parseFile(path string) (*ast.Program, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lexer, err := l.NewLexerFromString(string(src))
+	if err != nil {
+		return nil, err
+	}
+	parser := p.NewParser(lexer)
+	program := parser.ParseProgram()
+	if errs := parser.Errors(); len(errs) > 0 {
+		return nil, fmt.Errorf("%s: %s", path, strings.Join(errs, "\n"))
+	}
+	return program, nil
+}
+
+// compileFile parses and compiles a source file to LLVM IR.
+func compileFile(path string, tgt *target.Target, freestanding bool) (string, error) {
+	program, err := parseFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	compiler := c.NewCompiler(c.LLVM, tgt)
+	compiler.Freestanding = freestanding
+	compiler.SourceFile = path
+	result := compiler.Compile(program)
+	if len(result.Errors) > 0 {
+		return "", fmt.Errorf("%s: %s", path, strings.Join(result.Errors, "\n"))
+	}
+	return result.Output, nil
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestCollectionsProgram covers the heap allocator of stdlib/mem and the
+// maps, sets, deque, priority queue and string builder of
+// stdlib/collections, including SipHash reference values and a map
+// iterated in the order a fixed seed gives it.
+func TestCollectionsProgram(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name: "heap allocator",
+			input: `
+			import "stdlib/thread";
+			import "stdlib/fmt";
+			import "stdlib/mem";
+
+			// Each thread keeps 64 live blocks of varying sizes filled with
+			// its tag and checks them before freeing, so a block handed out
+			// twice shows up as a bad byte.
+			function churn(tag: i64): i64 -> {
+				let live = mem.heapAlloc(64 * 8) as *i64;
+				let bad: i64 = 0;
+				let i: i64 = 0;
+				while (i < 20000) {
+					let p = live[i % 64] as *u8;
+					if ((p as i64) != 0) {
+						let j: i64 = 0;
+						while (j < mem.heapSize(p)) {
+							if (p[j] != tag) { bad = bad + 1; }
+							j = j + 1;
+						}
+						mem.heapFree(p);
+					}
+					let size = (i * 37 + tag) % 200 + 1;
+					p = mem.heapAlloc(size);
+					let j: i64 = 0;
+					while (j < size) { p[j] = tag; j = j + 1; }
+					live[i % 64] = p as i64;
+					i = i + 1;
+				}
+				return bad;
+			}
+
+			main() -> {
+				let a = mem.heapAlloc(10);
+				let b = mem.heapAlloc(10);
+				printf("%d %d %d\n", (b as i64) - (a as i64), (a as i64) % 16, mem.heapSize(a));
+				a[0] = 7;
+				mem.heapFree(a);
+				// The freed block is reused, zeroed.
+				let c = mem.heapAlloc(5);
+				printf("%d %d\n", (c as i64) == (a as i64), c[0]);
+				c[4] = 3;
+				c = mem.heapRealloc(c, 12);
+				printf("%d %d %d\n", (c as i64) == (a as i64), c[4], mem.heapSize(c));
+				c = mem.heapRealloc(c, 5000);
+				printf("%d %d %d\n", (c as i64) == (a as i64), c[4], c[4999]);
+				let big = mem.heapAlloc(100000);
+				big[99999] = 1;
+				big = mem.heapRealloc(big, 200000);
+				printf("%d %d %d\n", big[99999], big[199999], mem.heapSize(big));
+				mem.heapFree(big);
+
+				let t1 = thread.spawn(churn, 1);
+				let t2 = thread.spawn(churn, 2);
+				let t3 = thread.spawn(churn, 3);
+				printf("%d %d %d\n", thread.join(t1), thread.join(t2), thread.join(t3));
+				return 0;
+			}`,
+			expected: []string{
+				"32 0 10",
+				"true 0",
+				"true 3 12",
+				"false 3 0",
+				"1 0 200000",
+				"0 0 0",
+			},
+		},
+		{
+			name: "hash maps and sets",
+			input: `
+			import "stdlib/fmt";
+			import "stdlib/mem";
+			import "stdlib/collections";
+
+			function showHash(h: i64) -> {
+				printf("%08x%08x\n", h >>> 32, h & 0xffffffff);
+			}
+
+			main() -> {
+				// Reference values: key 00..0f over the messages 00..0e and "".
+				let key = mem.heapAlloc(16);
+				let i: i64 = 0;
+				while (i < 16) { key[i] = i; i = i + 1; }
+				let k = key as *i64;
+				showHash(collections.sipHash(k[0], k[1], key, 15));
+				showHash(collections.sipHash(k[0], k[1], key, 0));
+
+				collections.setSeed(1, 2);
+				let m = collections.newHashMap();
+				collections.mapPut(m, "one", 1);
+				collections.mapPut(m, "two", 2);
+				collections.mapPut(m, "three", 3);
+				collections.mapPut(m, "two", 22);
+				printf("%d %d %d %d\n", collections.mapLen(m), collections.mapGet(m, "two"), collections.mapGet(m, "four", -1), collections.mapHas(m, "four"));
+				for name in collections.mapKeys(m) {
+					printf("%s=%d\n", name, collections.mapGet(m, name));
+				}
+				printf("%d %d %d\n", collections.mapRemove(m, "one"), collections.mapRemove(m, "one"), collections.mapLen(m));
+				collections.freeHashMap(m);
+
+				// Removing every other key keeps the rest reachable.
+				let im = collections.newIntHashMap();
+				i = 0;
+				while (i < 1000) { collections.mapPutInt(im, i * 7, i); i = i + 1; }
+				i = 0;
+				while (i < 1000) { if (i % 2 == 0) { collections.mapRemoveInt(im, i * 7); } i = i + 1; }
+				let ok = true;
+				i = 0;
+				while (i < 1000) {
+					let want = i % 2 == 1;
+					if (collections.mapHasInt(im, i * 7) != want) { ok = false; }
+					if (want && collections.mapGetInt(im, i * 7) != i) { ok = false; }
+					i = i + 1;
+				}
+				let sum: i64 = 0;
+				for v in collections.mapValues(im) { sum = sum + v; }
+				let keys: i64 = 0;
+				for key2 in collections.mapIntKeys(im) { keys = keys + key2; }
+				printf("%d %d %d %d\n", ok, collections.mapLen(im), sum, keys);
+
+				let s = collections.newHashSet();
+				printf("%d %d %d %d\n", collections.setAdd(s, "a"), collections.setAdd(s, "a"), collections.setHas(s, "b"), collections.setLen(s));
+				collections.setAdd(s, "b");
+				collections.setRemove(s, "a");
+				for member in collections.setMembers(s) { printf("member %s\n", member); }
+				let odd = collections.newIntHashSet();
+				i = 0;
+				while (i < 100) { collections.setAddInt(odd, i % 10); i = i + 1; }
+				collections.setRemoveInt(odd, 0);
+				let total: i64 = 0;
+				for n in collections.setIntMembers(odd) { total = total + n; }
+				printf("%d %d %d\n", collections.setLen(odd), collections.setHasInt(odd, 9), total);
+				return 0;
+			}`,
+			expected: []string{
+				"a129ca6149be45e5",
+				"726fdb47dd0e0e31",
+				"3 22 -1 false",
+				"one=1",
+				"two=22",
+				"three=3",
+				"true false 2",
+				"true 500 250000 1750000",
+				"true false false 1",
+				"member b",
+				"9 true 45",
+			},
+		},
+		{
+			name: "deque, priority queue and string builder",
+			input: `
+			import "stdlib/fmt";
+			import "stdlib/collections";
+
+			main() -> {
+				let d = collections.newDeque();
+				let i: i64 = 0;
+				while (i < 10) {
+					collections.pushBack(d, i);
+					collections.pushFront(d, -i);
+					i = i + 1;
+				}
+				printf("%d %d %d\n", collections.dequeLen(d), collections.front(d), collections.back(d));
+				printf("%d %d %d\n", collections.popFront(d), collections.popBack(d), collections.dequeAt(d, 1));
+				for x in collections.dequeItems(d) { printf("%d ", x); }
+				printf("\n");
+
+				let q = collections.newPriorityQueue();
+				collections.push(q, 5, 50);
+				collections.push(q, 1, 10);
+				collections.push(q, 3, 30);
+				collections.push(q, 1, 11);
+				collections.push(q, 9, 90);
+				printf("%d %d\n", collections.peek(q), collections.peekPriority(q));
+				while (collections.queueLen(q) > 0) { printf("%d ", collections.pop(q)); }
+				printf("\n");
+				let top = collections.newMaxPriorityQueue();
+				i = 0;
+				while (i < 20) { collections.push(top, (i * 7) % 20, i); i = i + 1; }
+				i = 0;
+				while (i < 3) { printf("%d ", collections.pop(top)); i = i + 1; }
+				printf("\n");
+
+				let b = collections.newStringBuilder();
+				collections.append(b, "min=");
+				collections.appendInt(b, -9223372036854775807 - 1);
+				collections.appendByte(b, 59);
+				i = 0;
+				while (i < 20) { collections.append(b, "ab"); i = i + 1; }
+				let s = collections.toString(b);
+				printf("%s %d\n", s, collections.builderLen(b));
+				collections.builderReset(b);
+				collections.appendInt(b, 0);
+				collections.append(b, "k");
+				printf("%s\n", collections.builderString(b));
+				return 0;
+			}`,
+			expected: []string{
+				"20 -9 9",
+				"-9 9 -7",
+				"-8 -7 -6 -5 -4 -3 -2 -1 0 0 1 2 3 4 5 6 7 8 ",
+				"10 1",
+				"10 11 30 50 90 ",
+				"17 14 11 ",
+				"min=-9223372036854775808;abababababababababababababababababababab 65",
+				"0k",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := runLines(t, buildFreestanding(t, tt.input))
+			if strings.Join(lines, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("program output mismatch\nGot:\n%s\nWant:\n%s", strings.Join(lines, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+}
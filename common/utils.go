@@ -9,3 +9,7 @@ func IsLetter(ch rune) bool {
 func IsDigit(ch rune) bool {
 	return unicode.IsDigit(ch)
 }
+
+func IsHexDigit(ch rune) bool {
+	return (ch >= '0' && ch <= '9') || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
@@ -74,3 +74,29 @@ func TestIsLetterUnit(t *testing.T) {
 		})
 	}
 }
+
+func TestIsHexDigitUnit(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ch       rune
+		expected bool
+	}{
+		{"ASCII Digit 0", '0', true},
+		{"ASCII Digit 9", '9', true},
+		{"Lower a", 'a', true},
+		{"Lower f", 'f', true},
+		{"Upper F", 'F', true},
+		{"Lower g", 'g', false},
+		{"Letter x", 'x', false},
+		{"Unicode Digit ١", '١', false},
+		{"Zero Rune", 0, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsHexDigit(tc.ch); got != tc.expected {
+				t.Errorf("IsHexDigit('%c') = %v, want %v", tc.ch, got, tc.expected)
+			}
+		})
+	}
+}
@@ -3,15 +3,37 @@ package compiler
 import (
 	"compiler/ast"
 	"compiler/compiler/generator"
+	"compiler/compiler/interpreter"
+	"compiler/compiler/target"
 	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/value"
+	"io"
 )
 
 // Compiler is the main struct for the compiler.
 type Compiler struct {
 	backend CompilerBackend
-	errors  []string
-	output  string
+	target  *target.Target
+
+	// Freestanding emits a _start entry point so the program can be linked
+	// with -nostdlib -static, without a C runtime.
+	Freestanding bool
+	// TestModule, when set, is the import path of the module whose test
+	// blocks are compiled into a test harness replacing main.
+	TestModule string
+	// SourceFile names the program's source file in messages.
+	SourceFile string
+	// SearchPaths are searched for modules after the default directories.
+	SearchPaths []string
+	// Stdin, Stdout and Stderr are the streams, Args the command line and
+	// Env the environment of the program the Interpreter backend runs. Nil
+	// streams are those of the compiler itself.
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+	Args           []string
+	Env            []string
+	errors         []string
+	output         string
 }
 
 // CompilerBackend is the backend for the compiler.
@@ -21,11 +43,18 @@ type CompilerBackend int
 type CompilerResult struct {
 	Errors []string
 	Output string
+	// ExitStatus is the exit status of the program run by the Interpreter
+	// backend: the value main returns, or 128 plus the number of the signal
+	// a crash would raise.
+	ExitStatus int
 }
 
 const (
 	// LLVM is the LLVM backend.
 	LLVM CompilerBackend = iota
+	// Interpreter runs the program by walking its syntax tree, without
+	// generating code.
+	Interpreter
 )
 
 // LLVMNode is an interface that all AST nodes should implement if they
@@ -34,16 +63,26 @@ type LLVMNode interface {
 	LLVMValue(m *ir.Module) value.Value
 }
 
-// NewCompiler creates a new compiler.
-func NewCompiler(backend CompilerBackend) *Compiler {
-	return &Compiler{backend: backend}
+// NewCompiler creates a new compiler generating code for t, or for
+// target.Default when t is nil.
+func NewCompiler(backend CompilerBackend, t *target.Target) *Compiler {
+	if t == nil {
+		t = target.Default
+	}
+	return &Compiler{backend: backend, target: t}
 }
 
 func (c *Compiler) Compile(program *ast.Program) *CompilerResult {
 	result := &CompilerResult{}
 
 	if c.backend == LLVM {
-		codeGen := generator.NewCodeGenerator()
+		codeGen := generator.NewCodeGeneratorForTarget(c.target)
+		codeGen.Freestanding = c.Freestanding
+		codeGen.TestModule = c.TestModule
+		codeGen.SourceFile = c.SourceFile
+		for _, dir := range c.SearchPaths {
+			codeGen.ModuleManager.AddSearchPath(dir)
+		}
 		err := program.Accept(codeGen)
 		if err != nil {
 			result.Errors = append(result.Errors, err.Error())
@@ -57,5 +96,22 @@ func (c *Compiler) Compile(program *ast.Program) *CompilerResult {
 		result.Output = c.output
 	}
 
+	if c.backend == Interpreter {
+		in := interpreter.New(c.target)
+		in.Freestanding = c.Freestanding
+		in.SourceFile = c.SourceFile
+		in.Stdin, in.Stdout, in.Stderr = c.Stdin, c.Stdout, c.Stderr
+		in.Args, in.Env = c.Args, c.Env
+		for _, dir := range c.SearchPaths {
+			in.ModuleManager.AddSearchPath(dir)
+		}
+		status, err := in.Run(program)
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			return result
+		}
+		result.ExitStatus = status
+	}
+
 	return result
 }
@@ -0,0 +1,193 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// Kind tags stored in the first field of an any value. They are part of the
+// runtime ABI: stdlib/fmt switches on the same numbers.
+const (
+	anyKindNil = iota
+	anyKindInt
+	anyKindFloat
+	anyKindString
+	anyKindBool
+	anyKindPointer
+)
+
+// anyType returns the boxed runtime-typed value %Any = type { i32 kind, i64 bits }.
+// Integers are sign extended into bits, floats are stored as the bit pattern of
+// a double and pointers as their address. The type is defined on first use.
+func (cg *CodeGenerator) anyType() *types.StructType {
+	if t, ok := cg.Structs["Any"]; ok {
+		if st, ok := t.(*types.StructType); ok {
+			return st
+		}
+	}
+	st := types.NewStruct(types.I32, types.I64)
+	cg.Module.NewTypeDef("Any", st)
+	cg.Structs["Any"] = st
+	cg.structFields["Any"] = []string{"kind", "value"}
+	return st
+}
+
+// boxAny wraps a primitive value in an any, tagging it with its kind.
+func (cg *CodeGenerator) boxAny(v value.Value) (value.Value, error) {
+	anyType := cg.anyType()
+	if v.Type().Equal(anyType) {
+		return v, nil
+	}
+
+	var kind int64
+	var bits value.Value
+	switch t := v.Type().(type) {
+	case *types.IntType:
+		if t.BitSize == 1 {
+			kind = anyKindBool
+		} else {
+			kind = anyKindInt
+		}
+		if c, ok := v.(*constant.Int); ok {
+			if t.BitSize == 1 {
+				bits = constant.NewInt(types.I64, c.X.Int64()&1)
+			} else {
+				bits = constant.NewInt(types.I64, c.X.Int64())
+			}
+		} else if t.BitSize == 64 {
+			bits = v
+		} else if t.BitSize == 1 {
+			bits = cg.Block.NewZExt(v, types.I64)
+		} else {
+			bits = cg.Block.NewSExt(v, types.I64)
+		}
+	case *types.FloatType:
+		kind = anyKindFloat
+		if c, ok := v.(*constant.Float); ok {
+			f, _ := c.X.Float64()
+			bits = constant.NewInt(types.I64, int64(math.Float64bits(f)))
+		} else {
+			var d value.Value = v
+			if t.Kind != types.FloatKindDouble {
+				d = cg.Block.NewFPExt(v, types.Double)
+			}
+			bits = cg.Block.NewBitCast(d, types.I64)
+		}
+	case *types.PointerType:
+		if t.ElemType.Equal(types.I8) {
+			kind = anyKindString
+		} else {
+			kind = anyKindPointer
+		}
+		bits = cg.Block.NewPtrToInt(v, types.I64)
+	default:
+		return nil, fmt.Errorf("cannot convert value of type %s to any", v.Type())
+	}
+
+	kindVal := constant.NewInt(types.I32, kind)
+	if c, ok := bits.(constant.Constant); ok {
+		return constant.NewStruct(anyType, kindVal, c), nil
+	}
+	boxed := cg.Block.NewInsertValue(constant.NewStruct(anyType, kindVal, constant.NewInt(types.I64, 0)), bits, 1)
+	return boxed, nil
+}
+
+// anyView implements the read-only views of an any value: v.int (i64),
+// v.float (double), v.string (i8*) and v.bool (i1).
+func (cg *CodeGenerator) anyView(anyPtr value.Value, view string) (value.Value, bool) {
+	anyType := cg.anyType()
+	bitsAddr := cg.Block.NewGetElementPtr(anyType, anyPtr, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 1))
+	switch view {
+	case "int":
+		return cg.Block.NewLoad(types.I64, bitsAddr), true
+	case "float":
+		return cg.Block.NewBitCast(cg.Block.NewLoad(types.I64, bitsAddr), types.Double), true
+	case "string":
+		return cg.Block.NewIntToPtr(cg.Block.NewLoad(types.I64, bitsAddr), types.NewPointer(types.I8)), true
+	case "bool":
+		return cg.Block.NewTrunc(cg.Block.NewLoad(types.I64, bitsAddr), types.I1), true
+	}
+	return nil, false
+}
+
+// sliceTypeNames turns a Y type spelling into a fragment usable in an LLVM
+// type name.
+var sliceTypeNames = strings.NewReplacer("*", "ptr.", "[]", "slice.", "...", "slice.", "(", "fn.", ")", ".", ",", ".", "->", "ret.")
+
+// sliceType returns the struct backing []T and ...T: { i32 length, T* data }.
+// []int reuses the predefined %Array so slices and array literals interoperate.
+func (cg *CodeGenerator) sliceType(elemName string) (*types.StructType, error) {
+	if elemName == "int" || elemName == "i32" {
+		return cg.resolveStructType("Array")
+	}
+	typeName := "Slice." + sliceTypeNames.Replace(elemName)
+	if t, ok := cg.Structs[typeName]; ok {
+		if st, ok := t.(*types.StructType); ok {
+			return st, nil
+		}
+	}
+	elemType, err := cg.mapType(elemName)
+	if err != nil {
+		return nil, err
+	}
+	st := types.NewStruct(types.I32, types.NewPointer(elemType))
+	cg.Module.NewTypeDef(typeName, st)
+	cg.Structs[typeName] = st
+	cg.structFields[typeName] = []string{"length", "data"}
+	return st, nil
+}
+
+// buildPack materialises the trailing arguments of a variadic call as a
+// slice on the caller's stack and returns a pointer to it.
+func (cg *CodeGenerator) buildPack(slicePtrType *types.PointerType, elems []value.Value) value.Value {
+	sliceType := slicePtrType.ElemType.(*types.StructType)
+	dataPtrType := sliceType.Fields[1].(*types.PointerType)
+
+	pack := cg.newEntryAlloca(sliceType)
+	cg.trySetName(pack, "varargs")
+	lenAddr := cg.Block.NewGetElementPtr(sliceType, pack, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 0))
+	cg.Block.NewStore(constant.NewInt(types.I32, int64(len(elems))), lenAddr)
+
+	var data value.Value = constant.NewNull(dataPtrType)
+	if len(elems) > 0 {
+		arrType := types.NewArray(uint64(len(elems)), dataPtrType.ElemType)
+		storage := cg.newEntryAlloca(arrType)
+		for i, elem := range elems {
+			slot := cg.Block.NewGetElementPtr(arrType, storage, constant.NewInt(types.I64, 0), constant.NewInt(types.I64, int64(i)))
+			cg.Block.NewStore(elem, slot)
+		}
+		data = cg.Block.NewGetElementPtr(arrType, storage, constant.NewInt(types.I64, 0), constant.NewInt(types.I64, 0))
+	}
+	dataAddr := cg.Block.NewGetElementPtr(sliceType, pack, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 1))
+	cg.Block.NewStore(data, dataAddr)
+	return pack
+}
+
+// newLocalAlloca allocates stack space for a local variable. Inside a loop the
+// allocation is hoisted to the entry block; elsewhere it stays at the point of
+// declaration.
+func (cg *CodeGenerator) newLocalAlloca(t types.Type) *ir.InstAlloca {
+	if cg.loopDepth > 0 {
+		return cg.newEntryAlloca(t)
+	}
+	return cg.Block.NewAlloca(t)
+}
+
+// newEntryAlloca allocates stack space at the top of the current function's
+// entry block, so allocations made inside loops do not grow the stack on
+// every iteration.
+func (cg *CodeGenerator) newEntryAlloca(t types.Type) *ir.InstAlloca {
+	if cg.currentFunc == nil || len(cg.currentFunc.Blocks) == 0 {
+		return cg.Block.NewAlloca(t)
+	}
+	entry := cg.currentFunc.Blocks[0]
+	alloca := ir.NewAlloca(t)
+	entry.Insts = append([]ir.Instruction{alloca}, entry.Insts...)
+	return alloca
+}
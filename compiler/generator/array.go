@@ -22,7 +22,7 @@ func (cg *CodeGenerator) VisitArrayLiteral(al *ast.ArrayLiteral) error {
 		llvmIntPtrType := types.NewPointer(llvmIntType)
 
 		arrayAlloca := cg.Block.NewAlloca(arrayStructType)
-		arrayAlloca.SetName("empty_array_struct")
+		cg.trySetName(arrayAlloca, "empty_array_struct")
 
 		// Store length = 0
 		lenFieldAddr := cg.Block.NewGetElementPtr(arrayStructType, arrayAlloca, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 0)) // Field 0 = length
@@ -74,7 +74,7 @@ func (cg *CodeGenerator) VisitArrayLiteral(al *ast.ArrayLiteral) error {
 
 	// 1. Allocate stack space for the underlying data array
 	dataAlloca := cg.Block.NewAlloca(constArrayType)
-	dataAlloca.SetName("array_data")
+	cg.trySetName(dataAlloca, "array_data")
 
 	// 2. Store the constant data into the stack allocation
 	cg.Block.NewStore(constArray, dataAlloca)
@@ -85,7 +85,7 @@ func (cg *CodeGenerator) VisitArrayLiteral(al *ast.ArrayLiteral) error {
 		return err
 	}
 	arrayStructAlloca := cg.Block.NewAlloca(arrayStructType)
-	arrayStructAlloca.SetName("array_struct")
+	cg.trySetName(arrayStructAlloca, "array_struct")
 
 	// 4. Store length field
 	lenFieldAddr := cg.Block.NewGetElementPtr(arrayStructType, arrayStructAlloca, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 0)) // Field 0 = length
@@ -96,7 +96,7 @@ func (cg *CodeGenerator) VisitArrayLiteral(al *ast.ArrayLiteral) error {
 	dataFieldAddr := cg.Block.NewGetElementPtr(arrayStructType, arrayStructAlloca, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 1)) // Field 1 = data
 	// GEP to get pointer to first element: [N x T]* -> T*
 	firstElemPtr := cg.Block.NewGetElementPtr(constArrayType, dataAlloca, constant.NewInt(types.I64, 0), constant.NewInt(types.I64, 0))
-	firstElemPtr.SetName("first_elem_ptr")
+	cg.trySetName(firstElemPtr, "first_elem_ptr")
 	cg.Block.NewStore(firstElemPtr, dataFieldAddr)
 
 	// The result of the array literal expression is the pointer to the Array struct
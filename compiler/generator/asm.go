@@ -6,9 +6,17 @@ import (
 	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
+	"strings"
 )
 
+// VisitAssemblyExpression lowers an asm block to inline assembly, and
+// asm("builtin_name", args...) to the runtime helper it names. The
+// intrinsics of the compiler are functions of the builtin module instead;
+// see intrinsics.go.
 func (cg *CodeGenerator) VisitAssemblyExpression(ae *ast.AssemblyExpression) error {
+	if ae.Block {
+		return cg.visitAsmBlock(ae)
+	}
 	asmCode := ae.Code.Value
 
 	// Evaluate arguments
@@ -57,6 +65,11 @@ func (cg *CodeGenerator) VisitAssemblyExpression(ae *ast.AssemblyExpression) err
 		return nil
 
 	default:
-		return fmt.Errorf("unsupported or unknown asm code: '%s'", asmCode)
+		// Anything that is not an intrinsic is assembly text, as in
+		// asm("pause"); operands need the block form.
+		if strings.HasPrefix(asmCode, "builtin_") || len(args) > 0 {
+			return fmt.Errorf("unsupported or unknown asm code: '%s'", asmCode)
+		}
+		return cg.visitAsmBlock(&ast.AssemblyExpression{Token: ae.Token, Code: ae.Code, Block: true})
 	}
 }
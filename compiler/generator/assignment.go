@@ -1,8 +1,16 @@
 package generator
 
-import "compiler/ast"
+import (
+	"compiler/ast"
+	"fmt"
+	"github.com/llir/llvm/ir/types"
+)
 
 func (cg *CodeGenerator) VisitAssignmentExpression(ae *ast.AssignmentExpression) error {
+	if cg.isConstRef(ae.Left) {
+		return fmt.Errorf("cannot assign to constant '%s'", ae.Left.String())
+	}
+
 	// Evaluate the left side in "LHS mode" so we get the address, not the loaded value.
 	cg.inAssignmentLHS = true
 	err := ae.Left.Accept(cg)
@@ -18,6 +26,16 @@ func (cg *CodeGenerator) VisitAssignmentExpression(ae *ast.AssignmentExpression)
 	}
 	rhsVal := cg.lastValue
 
+	// Convert scalar values to the type of the destination slot (e.g. storing
+	// an int literal into a byte buffer). Pointer slots are left untouched.
+	if ptrType, ok := lhsAddr.Type().(*types.PointerType); ok && rhsVal != nil && !types.IsPointer(ptrType.ElemType) {
+		converted, err := cg.convertValue(rhsVal, ptrType.ElemType)
+		if err != nil {
+			return fmt.Errorf("assignment to '%s': %w", ae.Left.String(), err)
+		}
+		rhsVal = converted
+	}
+
 	// Do the store.
 	cg.Block.NewStore(rhsVal, lhsAddr)
 
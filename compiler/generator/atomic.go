@@ -0,0 +1,195 @@
+package generator
+
+import (
+	"fmt"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// Memory orderings of the atomic intrinsics, indexed by the order argument
+// (the constants RELAXED, ACQUIRE, RELEASE, ACQ_REL and SEQ_CST of
+// stdlib/atomic).
+var atomicOrderings = []enum.AtomicOrdering{
+	enum.AtomicOrderingMonotonic,
+	enum.AtomicOrderingAcquire,
+	enum.AtomicOrderingRelease,
+	enum.AtomicOrderingAcquireRelease,
+	enum.AtomicOrderingSequentiallyConsistent,
+}
+
+var atomicOrderingNames = []string{"RELAXED", "ACQUIRE", "RELEASE", "ACQ_REL", "SEQ_CST"}
+
+// atomicRMWOps maps the read-modify-write intrinsics to their atomicrmw
+// operation.
+var atomicRMWOps = map[string]enum.AtomicOp{
+	"atomicAdd":  enum.AtomicOpAdd,
+	"atomicSub":  enum.AtomicOpSub,
+	"atomicAnd":  enum.AtomicOpAnd,
+	"atomicOr":   enum.AtomicOpOr,
+	"atomicXor":  enum.AtomicOpXor,
+	"atomicSwap": enum.AtomicOpXChg,
+	"atomicMax":  enum.AtomicOpMax,
+	"atomicMin":  enum.AtomicOpMin,
+}
+
+// visitAtomic lowers an atomic intrinsic:
+//
+//	builtin.atomicLoad(p, order)          load atomic
+//	builtin.atomicStore(p, v, order)      store atomic
+//	builtin.atomicAdd(p, v, order)        atomicrmw, returning the old value
+//	builtin.atomicCas(p, old, new, order) cmpxchg, returning whether it swapped
+//	builtin.fence(order)                  fence
+//
+// p points to an integer or pointer. A constant order selects the ordering
+// directly; any other order is dispatched at run time, as C compilers do.
+func (cg *CodeGenerator) visitAtomic(name string, args []value.Value) error {
+	qualified := builtinModule + "." + name
+	order := args[len(args)-1]
+
+	if name == "fence" {
+		_, err := cg.withOrdering(qualified, order, []bool{false, true, true, true, true}, nil,
+			func(block *ir.Block, o enum.AtomicOrdering) value.Value {
+				block.NewFence(o)
+				return nil
+			})
+		cg.lastValue = nil
+		return err
+	}
+
+	ptrType, ok := args[0].Type().(*types.PointerType)
+	if !ok {
+		return fmt.Errorf("%s: first argument must be a pointer, got %s", qualified, args[0].Type())
+	}
+	elem := ptrType.ElemType
+	align, err := atomicAlign(elem)
+	if err != nil {
+		return fmt.Errorf("%s: %w", qualified, err)
+	}
+	ptr := args[0]
+	var operands []value.Value
+	for _, arg := range args[1 : len(args)-1] {
+		v, err := cg.convertValue(arg, elem)
+		if err != nil {
+			return fmt.Errorf("%s: %w", qualified, err)
+		}
+		operands = append(operands, v)
+	}
+
+	switch name {
+	case "atomicLoad":
+		cg.lastValue, err = cg.withOrdering(qualified, order, []bool{true, true, false, false, true}, elem,
+			func(block *ir.Block, o enum.AtomicOrdering) value.Value {
+				load := block.NewLoad(elem, ptr)
+				load.Atomic, load.Ordering, load.Align = true, o, align
+				return load
+			})
+	case "atomicStore":
+		_, err = cg.withOrdering(qualified, order, []bool{true, false, true, false, true}, nil,
+			func(block *ir.Block, o enum.AtomicOrdering) value.Value {
+				store := block.NewStore(operands[0], ptr)
+				store.Atomic, store.Ordering, store.Align = true, o, align
+				return nil
+			})
+		cg.lastValue = nil
+	case "atomicCas":
+		cg.lastValue, err = cg.withOrdering(qualified, order, nil, types.I1,
+			func(block *ir.Block, o enum.AtomicOrdering) value.Value {
+				pair := block.NewCmpXchg(ptr, operands[0], operands[1], o, casFailureOrdering(o))
+				return block.NewExtractValue(pair, 1)
+			})
+	default:
+		op := atomicRMWOps[name]
+		if _, isInt := elem.(*types.IntType); !isInt && op != enum.AtomicOpXChg {
+			return fmt.Errorf("%s: operand must point to an integer, got %s", qualified, ptrType)
+		}
+		cg.lastValue, err = cg.withOrdering(qualified, order, nil, elem,
+			func(block *ir.Block, o enum.AtomicOrdering) value.Value {
+				return block.NewAtomicRMW(op, ptr, operands[0], o)
+			})
+	}
+	return err
+}
+
+// atomicAlign returns the alignment of an atomic access to t, which LLVM
+// requires to be spelled out: the natural alignment of t.
+func atomicAlign(t types.Type) (ir.Align, error) {
+	switch t := t.(type) {
+	case *types.IntType:
+		if t.BitSize >= 8 && t.BitSize <= 64 && t.BitSize&(t.BitSize-1) == 0 {
+			return ir.Align(t.BitSize / 8), nil
+		}
+	case *types.PointerType:
+		return 8, nil
+	}
+	return 0, fmt.Errorf("atomic access to %s is not supported (want an 8 to 64-bit integer or a pointer)", t)
+}
+
+// casFailureOrdering returns the ordering of a failed cmpxchg, which must not
+// contain a release.
+func casFailureOrdering(o enum.AtomicOrdering) enum.AtomicOrdering {
+	switch o {
+	case enum.AtomicOrderingRelease:
+		return enum.AtomicOrderingMonotonic
+	case enum.AtomicOrderingAcquireRelease:
+		return enum.AtomicOrderingAcquire
+	}
+	return o
+}
+
+// withOrdering calls emit with the ordering selected by order. valid lists the
+// orderings the operation accepts (nil for all of them); a constant order
+// outside it is an error. Any other order becomes a switch with a case per
+// valid ordering, falling back to SEQ_CST, whose results meet in a phi of
+// type result (nil when the operation produces no value).
+func (cg *CodeGenerator) withOrdering(name string, order value.Value, valid []bool, result types.Type,
+	emit func(block *ir.Block, o enum.AtomicOrdering) value.Value) (value.Value, error) {
+	isValid := func(i int) bool { return valid == nil || valid[i] }
+
+	if c, ok := order.(*constant.Int); ok {
+		i := c.X.Int64()
+		if i < 0 || i >= int64(len(atomicOrderings)) {
+			return nil, fmt.Errorf("%s: unknown memory ordering %d", name, i)
+		}
+		if !isValid(int(i)) {
+			return nil, fmt.Errorf("%s: memory ordering %s is not valid for this operation", name, atomicOrderingNames[i])
+		}
+		return emit(cg.Block, atomicOrderings[i]), nil
+	}
+
+	sel, err := cg.convertValue(order, types.I64)
+	if err != nil {
+		return nil, fmt.Errorf("%s: memory ordering: %w", name, err)
+	}
+	var blocks []*ir.Block
+	var cases []*ir.Case
+	var incoming []*ir.Incoming
+	for i, o := range atomicOrderings {
+		if !isValid(i) || o == enum.AtomicOrderingSequentiallyConsistent {
+			continue
+		}
+		block := cg.newBlock("atomic." + o.String())
+		blocks = append(blocks, block)
+		cases = append(cases, ir.NewCase(constant.NewInt(types.I64, int64(i)), block))
+		if v := emit(block, o); result != nil {
+			incoming = append(incoming, ir.NewIncoming(v, block))
+		}
+	}
+	fallback := cg.newBlock("atomic.seq_cst")
+	blocks = append(blocks, fallback)
+	if v := emit(fallback, enum.AtomicOrderingSequentiallyConsistent); result != nil {
+		incoming = append(incoming, ir.NewIncoming(v, fallback))
+	}
+	merge := cg.newBlock("atomic.done")
+	for _, block := range blocks {
+		block.NewBr(merge)
+	}
+	cg.Block.NewSwitch(sel, fallback, cases...)
+	cg.Block = merge
+	if result == nil {
+		return nil, nil
+	}
+	return merge.NewPhi(incoming...), nil
+}
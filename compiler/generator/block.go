@@ -8,6 +8,9 @@ func (cg *CodeGenerator) VisitBlockStatement(bs *ast.BlockStatement) error {
 			continue
 		}
 		if err := stmt.Accept(cg); err != nil {
+			if cg.FailedAt == nil {
+				cg.FailedAt = stmt
+			}
 			return err
 		}
 	}
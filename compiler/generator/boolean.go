@@ -0,0 +1,12 @@
+package generator
+
+import (
+	"compiler/ast"
+	"github.com/llir/llvm/ir/constant"
+)
+
+// VisitBooleanLiteral produces the i1 constants true and false.
+func (cg *CodeGenerator) VisitBooleanLiteral(bl *ast.BooleanLiteral) error {
+	cg.lastValue = constant.NewBool(bl.Value)
+	return nil
+}
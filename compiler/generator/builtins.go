@@ -1,6 +1,7 @@
 package generator
 
 import (
+	"compiler/compiler/target"
 	"fmt"
 	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/constant"
@@ -13,11 +14,11 @@ type BuiltInManager struct {
 	funcs map[string]*ir.Func
 }
 
-func NewBuiltInManager(m *ir.Module) *BuiltInManager {
+func NewBuiltInManager(m *ir.Module, t *target.Target) *BuiltInManager {
 	bm := &BuiltInManager{
 		funcs: make(map[string]*ir.Func),
 	}
-	bm.initBuiltInFuncs(m)
+	bm.initBuiltInFuncs(m, t)
 	return bm
 }
 
@@ -25,7 +26,7 @@ func (bm *BuiltInManager) GetProvidedFunctionsMap() map[string]*ir.Func {
 	return bm.funcs
 }
 
-func (bm *BuiltInManager) initBuiltInFuncs(m *ir.Module) {
+func (bm *BuiltInManager) initBuiltInFuncs(m *ir.Module, t *target.Target) {
 	// --- Builtin: malloc ---
 	mallocSig := types.NewFunc(types.NewPointer(types.I8), types.I64) // void* malloc(size_t size) -> i8* malloc(i64 size)
 	mallocFunc := m.NewFunc("malloc", mallocSig.RetType, ir.NewParam("size", mallocSig.Params[0]))
@@ -34,15 +35,13 @@ func (bm *BuiltInManager) initBuiltInFuncs(m *ir.Module) {
 	// --- Builtin: builtin_print_int(i32) -> void ---
 	printIntSig := types.NewFunc(types.Void, types.I32)
 	printIntFunc := m.NewFunc("builtin_print_int", printIntSig.RetType, ir.NewParam("val", printIntSig.Params[0]))
-	printIntEntry := printIntFunc.NewBlock("entry")
-	printIntEntry.NewRet(nil) // Return void
+	defineBuiltinPrintInt(printIntFunc, t.Syscall)
 	bm.funcs["builtin_print_int"] = printIntFunc
 
 	// --- Builtin: builtin_print_newline() -> void ---
 	printNewlineSig := types.NewFunc(types.Void)
 	printNewlineFunc := m.NewFunc("builtin_print_newline", printNewlineSig.RetType)
-	printNewlineEntry := printNewlineFunc.NewBlock("entry")
-	printNewlineEntry.NewRet(nil) // Return void
+	defineBuiltinPrintNewline(m, printNewlineFunc, t.Syscall)
 	bm.funcs["builtin_print_newline"] = printNewlineFunc
 
 	// --- Array builtins just placeholders for now ---
@@ -58,182 +57,186 @@ func (bm *BuiltInManager) initBuiltInFuncs(m *ir.Module) {
 	bm.funcs["Array_forEach"] = arrayForEachFunc // Method lookup alias
 }
 
-// generateArrayMap generates LLVM IR for array.map(callback)
-// objPtrVal: Pointer to the array (e.g., [N x T]*)
-// arrayType: The underlying array type ([N x T])
-// args: LLVM values for arguments passed to map (should contain the callback function)
-func (cg *CodeGenerator) generateArrayMap(objPtrVal value.Value, arrayType *types.ArrayType, args []value.Value) error {
-	if len(args) != 1 {
-		return fmt.Errorf("array.map expects exactly 1 argument (callback function), got %d", len(args))
-	}
-	callbackFnVal := args[0]
-
-	// Ensure callbackFnVal is a callable function pointer
-	callbackFnPtrType, ok := callbackFnVal.Type().(*types.PointerType)
-	if !ok {
-		return fmt.Errorf("argument to map is not a function pointer type: %s", callbackFnVal.Type())
-	}
-	callbackFnSig, ok := callbackFnPtrType.ElemType.(*types.FuncType)
-	if !ok {
-		return fmt.Errorf("argument to map is not a function pointer type: %s", callbackFnVal.Type())
-	}
-
-	// --- Basic Type/Size Info ---
-	arrayLen := arrayType.Len
-	elemType := arrayType.ElemType          // Type of elements in the *source* array
-	resultElemType := callbackFnSig.RetType // Type of elements in the *result* array
-
-	if len(callbackFnSig.Params) != 1 || !callbackFnSig.Params[0].Equal(elemType) {
-		return fmt.Errorf("map callback signature mismatch: expected func(%s) %s, got %s", elemType, resultElemType, callbackFnSig)
-	}
-
-	// --- Allocate Result Array ---
-	resultArrayType := types.NewArray(arrayLen, resultElemType)
-	resultArrayAlloca := cg.Block.NewAlloca(resultArrayType)
-	resultArrayAlloca.SetName("map_result_arr")
-
-	// --- Loop Setup ---
-	// We need an index variable
-	indexAlloca := cg.Block.NewAlloca(types.I64) // Use i64 for index
-	indexAlloca.SetName("map_idx")
-	cg.Block.NewStore(constant.NewInt(types.I64, 0), indexAlloca)
-
-	loopCondBlock := cg.newBlock("map_loop_cond")
-	loopBodyBlock := cg.newBlock("map_loop_body")
-	loopEndBlock := cg.newBlock("map_loop_end")
-
-	cg.Block.NewBr(loopCondBlock) // Jump to condition check first
+// emitWrite emits a write syscall of length bytes at buf to stdout.
+func emitWrite(block *ir.Block, abi target.SyscallABI, buf, length value.Value) {
+	i64 := types.I64
+	asm := makeSyscallInlineAsm(abi, i64, i64, i64, i64)
+	block.NewCall(asm, constant.NewInt(i64, abi.Numbers["write"]), constant.NewInt(i64, 1), block.NewPtrToInt(buf, i64), length)
+}
 
-	// --- Loop Condition ---
-	cg.Block = loopCondBlock
-	currentIndex := cg.Block.NewLoad(types.I64, indexAlloca)
-	currentIndex.SetName("map_current_idx")
-	cond := cg.Block.NewICmp(enum.IPredULT, currentIndex, constant.NewInt(types.I64, int64(arrayLen))) // Use unsigned less than
-	cond.SetName("map_loop_cond_check")
-	cg.Block.NewCondBr(cond, loopBodyBlock, loopEndBlock)
+// defineBuiltinPrintInt emits the body of builtin_print_int: the value is
+// converted to decimal right-to-left in a stack buffer and written to stdout
+// with a single write syscall, without a trailing newline.
+func defineBuiltinPrintInt(fn *ir.Func, abi target.SyscallABI) {
+	const bufLen = 21 // sign + 20 digits covers every i64
+	bufType := types.NewArray(bufLen, types.I8)
+	i64 := types.I64
+
+	entry := fn.NewBlock("entry")
+	loop := fn.NewBlock("digits")
+	done := fn.NewBlock("write")
+
+	buf := entry.NewAlloca(bufType)
+	buf.SetName("buf")
+	n := entry.NewSExt(fn.Params[0], i64)
+	isNeg := entry.NewICmp(enum.IPredSLT, n, constant.NewInt(i64, 0))
+	abs := entry.NewSelect(isNeg, entry.NewSub(constant.NewInt(i64, 0), n), n)
+	entry.NewBr(loop)
+
+	pos := loop.NewPhi(ir.NewIncoming(constant.NewInt(i64, bufLen), entry))
+	rest := loop.NewPhi(ir.NewIncoming(abs, entry))
+	digit := loop.NewURem(rest, constant.NewInt(i64, 10))
+	char := loop.NewTrunc(loop.NewAdd(digit, constant.NewInt(i64, '0')), types.I8)
+	nextPos := loop.NewSub(pos, constant.NewInt(i64, 1))
+	loop.NewStore(char, loop.NewGetElementPtr(bufType, buf, constant.NewInt(i64, 0), nextPos))
+	nextRest := loop.NewUDiv(rest, constant.NewInt(i64, 10))
+	pos.Incs = append(pos.Incs, ir.NewIncoming(nextPos, loop))
+	rest.Incs = append(rest.Incs, ir.NewIncoming(nextRest, loop))
+	loop.NewCondBr(loop.NewICmp(enum.IPredNE, nextRest, constant.NewInt(i64, 0)), loop, done)
+
+	// The sign slot is always written; it is only included when negative.
+	signPos := done.NewSub(nextPos, constant.NewInt(i64, 1))
+	done.NewStore(constant.NewInt(types.I8, '-'), done.NewGetElementPtr(bufType, buf, constant.NewInt(i64, 0), signPos))
+	start := done.NewSelect(isNeg, signPos, nextPos)
+	emitWrite(done, abi, done.NewGetElementPtr(bufType, buf, constant.NewInt(i64, 0), start), done.NewSub(constant.NewInt(i64, bufLen), start))
+	done.NewRet(nil)
+}
 
-	// --- Loop Body ---
-	cg.Block = loopBodyBlock
-	currentIndexForBody := cg.Block.NewLoad(types.I64, indexAlloca) // Reload index for use in GEP
-	currentIndexForBody.SetName("map_idx_body")
-
-	// Get pointer to element in SOURCE array: gep [N x T]* %objPtrVal, i64 0, i64 %currentIndexForBody
-	srcElemPtr := cg.Block.NewGetElementPtr(arrayType, objPtrVal,
-		constant.NewInt(types.I64, 0), // First index for pointer deref
-		currentIndexForBody,           // Second index for array element
-	)
-	srcElemPtr.SetName("map_src_elem_ptr")
-
-	// Load the element from the source array
-	srcElemVal := cg.Block.NewLoad(elemType, srcElemPtr)
-	srcElemVal.SetName("map_src_elem_val")
-
-	// Call the callback function
-	callResult := cg.Block.NewCall(callbackFnVal, srcElemVal)
-	callResult.SetName("map_callback_res")
-
-	// Get pointer to element in RESULT array: gep [N x ResT]* %resultArrayAlloca, i64 0, i64 %currentIndexForBody
-	destElemPtr := cg.Block.NewGetElementPtr(resultArrayType, resultArrayAlloca,
-		constant.NewInt(types.I64, 0),
-		currentIndexForBody,
-	)
-	destElemPtr.SetName("map_dest_elem_ptr")
-
-	// Store the result in the destination array
-	cg.Block.NewStore(callResult, destElemPtr)
-
-	// Increment index: index = index + 1
-	nextIndex := cg.Block.NewAdd(currentIndexForBody, constant.NewInt(types.I64, 1))
-	nextIndex.SetName("map_next_idx")
-	cg.Block.NewStore(nextIndex, indexAlloca)
-
-	cg.Block.NewBr(loopCondBlock) // Jump back to condition
-
-	// --- Loop End ---
-	cg.Block = loopEndBlock
-	// The result of map is the pointer to the new array
-	cg.lastValue = resultArrayAlloca
-	return nil
+// defineBuiltinPrintNewline emits the body of builtin_print_newline, which
+// writes "\n" to stdout.
+func defineBuiltinPrintNewline(m *ir.Module, fn *ir.Func, abi target.SyscallABI) {
+	newline := m.NewGlobalDef("builtin_newline", constant.NewArray(types.NewArray(1, types.I8), constant.NewInt(types.I8, '\n')))
+	newline.Linkage = enum.LinkagePrivate
+	newline.Immutable = true
+
+	entry := fn.NewBlock("entry")
+	ptr := entry.NewGetElementPtr(newline.ContentType, newline, constant.NewInt(types.I64, 0), constant.NewInt(types.I64, 0))
+	emitWrite(entry, abi, ptr, constant.NewInt(types.I64, 1))
+	entry.NewRet(nil)
 }
 
-// generateArrayForEach generates LLVM IR for array.forEach(callback)
-// objPtrVal: Pointer to the array (e.g., [N x T]*)
-// arrayType: The underlying array type ([N x T])
-// args: LLVM values for arguments passed to forEach (should contain the callback function)
-func (cg *CodeGenerator) generateArrayForEach(objPtrVal value.Value, arrayType *types.ArrayType, args []value.Value) error {
+// arrayCallback checks that args holds a single one-parameter function
+// pointer, as taken by array.map and array.forEach.
+func arrayCallback(method string, args []value.Value) (value.Value, *types.FuncType, error) {
 	if len(args) != 1 {
-		return fmt.Errorf("array.forEach expects exactly 1 argument (callback function), got %d", len(args))
+		return nil, nil, fmt.Errorf("array.%s expects exactly 1 argument (callback function), got %d", method, len(args))
 	}
-	callbackFnVal := args[0]
-
-	// Ensure callbackFnVal is a callable function pointer
-	callbackFnPtrType, ok := callbackFnVal.Type().(*types.PointerType)
+	callbackFnPtrType, ok := args[0].Type().(*types.PointerType)
 	if !ok {
-		return fmt.Errorf("argument to forEach is not a function pointer type: %s", callbackFnVal.Type())
+		return nil, nil, fmt.Errorf("argument to %s is not a function pointer type: %s", method, args[0].Type())
 	}
 	callbackFnSig, ok := callbackFnPtrType.ElemType.(*types.FuncType)
-	if !ok {
-		return fmt.Errorf("argument to forEach is not a function pointer type: %s", callbackFnVal.Type())
-	}
-
-	// --- Basic Type/Size Info ---
-	arrayLen := arrayType.Len
-	elemType := arrayType.ElemType
-
-	// Check signature: Expects func(ElemType) void or func(ElemType) T
-	if len(callbackFnSig.Params) != 1 || !callbackFnSig.Params[0].Equal(elemType) {
-		return fmt.Errorf("forEach callback signature mismatch: expected func(%s), got %s", elemType, callbackFnSig)
+	if !ok || len(callbackFnSig.Params) != 1 {
+		return nil, nil, fmt.Errorf("%s callback must be a function of one parameter, got %s", method, args[0].Type())
 	}
+	return args[0], callbackFnSig, nil
+}
 
-	// --- Loop Setup ---
-	indexAlloca := cg.Block.NewAlloca(types.I64)
-	indexAlloca.SetName("fe_idx")
+// forEachElement emits a loop over the elements of the %Array (or slice) at
+// arrayPtr and calls body with the loop index and the loaded element. The
+// array and the index live in stack slots rather than registers across the
+// body, so the body may suspend a generator. On return cg.Block is the block
+// after the loop.
+func (cg *CodeGenerator) forEachElement(arrayPtr value.Value, prefix string, body func(index, elem value.Value) error) error {
+	arrayType := arrayPtr.Type().(*types.PointerType).ElemType.(*types.StructType)
+	elemType := arrayType.Fields[1].(*types.PointerType).ElemType
+
+	arrayAlloca := cg.newEntryAlloca(arrayPtr.Type())
+	cg.Block.NewStore(arrayPtr, arrayAlloca)
+	indexAlloca := cg.newEntryAlloca(types.I64)
 	cg.Block.NewStore(constant.NewInt(types.I64, 0), indexAlloca)
 
-	loopCondBlock := cg.newBlock("fe_loop_cond")
-	loopBodyBlock := cg.newBlock("fe_loop_body")
-	loopEndBlock := cg.newBlock("fe_loop_end")
-
+	loopCondBlock := cg.newBlock(prefix + "_loop_cond")
+	loopBodyBlock := cg.newBlock(prefix + "_loop_body")
+	loopEndBlock := cg.newBlock(prefix + "_loop_end")
 	cg.Block.NewBr(loopCondBlock)
 
-	// --- Loop Condition ---
 	cg.Block = loopCondBlock
-	currentIndex := cg.Block.NewLoad(types.I64, indexAlloca)
-	currentIndex.SetName("fe_current_idx")
-	cond := cg.Block.NewICmp(enum.IPredULT, currentIndex, constant.NewInt(types.I64, int64(arrayLen)))
-	cond.SetName("fe_loop_cond_check")
-	cg.Block.NewCondBr(cond, loopBodyBlock, loopEndBlock)
+	index := cg.Block.NewLoad(types.I64, indexAlloca)
+	array := cg.Block.NewLoad(arrayPtr.Type(), arrayAlloca)
+	lengthField := cg.Block.NewGetElementPtr(arrayType, array, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 0))
+	length := cg.Block.NewSExt(cg.Block.NewLoad(types.I32, lengthField), types.I64)
+	cg.Block.NewCondBr(cg.Block.NewICmp(enum.IPredSLT, index, length), loopBodyBlock, loopEndBlock)
 
-	// --- Loop Body ---
 	cg.Block = loopBodyBlock
-	currentIndexForBody := cg.Block.NewLoad(types.I64, indexAlloca) // Reload index
-	currentIndexForBody.SetName("fe_idx_body")
-
-	// Get pointer to element: gep [N x T]* %objPtrVal, i64 0, i64 %currentIndexForBody
-	elemPtr := cg.Block.NewGetElementPtr(arrayType, objPtrVal,
-		constant.NewInt(types.I64, 0),
-		currentIndexForBody,
-	)
-	elemPtr.SetName("fe_elem_ptr")
-
-	// Load the element
-	elemVal := cg.Block.NewLoad(elemType, elemPtr)
-	elemVal.SetName("fe_elem_val")
+	index = cg.Block.NewLoad(types.I64, indexAlloca)
+	array = cg.Block.NewLoad(arrayPtr.Type(), arrayAlloca)
+	dataField := cg.Block.NewGetElementPtr(arrayType, array, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 1))
+	data := cg.Block.NewLoad(arrayType.Fields[1], dataField)
+	elemPtr := cg.Block.NewGetElementPtr(elemType, data, index)
+	if err := body(index, cg.Block.NewLoad(elemType, elemPtr)); err != nil {
+		return err
+	}
+	if cg.Block != nil && cg.Block.Term == nil {
+		next := cg.Block.NewAdd(cg.Block.NewLoad(types.I64, indexAlloca), constant.NewInt(types.I64, 1))
+		cg.Block.NewStore(next, indexAlloca)
+		cg.Block.NewBr(loopCondBlock)
+	}
 
-	// Call the callback function
-	cg.Block.NewCall(callbackFnVal, elemVal) // Ignore return value if any
+	cg.Block = loopEndBlock
+	return nil
+}
 
-	// Increment index
-	nextIndex := cg.Block.NewAdd(currentIndexForBody, constant.NewInt(types.I64, 1))
-	nextIndex.SetName("fe_next_idx")
-	cg.Block.NewStore(nextIndex, indexAlloca)
+// generateArrayMap generates LLVM IR for array.map(callback). Each element is
+// converted to the callback's parameter type and the results are collected in
+// a new %Array whose data lives on the stack of the current function.
+func (cg *CodeGenerator) generateArrayMap(arrayPtr value.Value, args []value.Value) error {
+	callbackFnVal, callbackFnSig, err := arrayCallback("map", args)
+	if err != nil {
+		return err
+	}
+	if callbackFnSig.RetType.Equal(types.Void) {
+		return fmt.Errorf("map callback must return a value, got %s", callbackFnSig)
+	}
 
-	cg.Block.NewBr(loopCondBlock)
+	arrayType := cg.Structs["Array"].(*types.StructType)
+	lengthField := cg.Block.NewGetElementPtr(arrayType, arrayPtr, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 0))
+	length := cg.Block.NewLoad(types.I32, lengthField)
+	resultData := cg.Block.NewAlloca(types.I32)
+	resultData.NElems = length
+	resultData.SetName("map_result_data")
+	result := cg.newEntryAlloca(arrayType)
+	cg.Block.NewStore(length, cg.Block.NewGetElementPtr(arrayType, result, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 0)))
+	cg.Block.NewStore(resultData, cg.Block.NewGetElementPtr(arrayType, result, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, 1)))
+
+	err = cg.forEachElement(arrayPtr, "map", func(index, elem value.Value) error {
+		arg, err := cg.convertValue(elem, callbackFnSig.Params[0])
+		if err != nil {
+			return fmt.Errorf("map callback: %w", err)
+		}
+		mapped, err := cg.convertValue(cg.Block.NewCall(callbackFnVal, arg), types.I32)
+		if err != nil {
+			return fmt.Errorf("map callback result: %w", err)
+		}
+		cg.Block.NewStore(mapped, cg.Block.NewGetElementPtr(types.I32, resultData, index))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	cg.lastValue = result
+	return nil
+}
 
-	// --- Loop End ---
-	cg.Block = loopEndBlock
-	// forEach usually doesn't return a meaningful value for chaining in this style,
-	// but to allow obj.forEach(...).anotherMethod(...) we might return the original object ptr.
-	cg.lastValue = objPtrVal
+// generateArrayForEach generates LLVM IR for array.forEach(callback). Each
+// element is converted to the callback's parameter type, so a function taking
+// any (like print) can be passed directly. The array itself is the result.
+func (cg *CodeGenerator) generateArrayForEach(arrayPtr value.Value, args []value.Value) error {
+	callbackFnVal, callbackFnSig, err := arrayCallback("forEach", args)
+	if err != nil {
+		return err
+	}
+	err = cg.forEachElement(arrayPtr, "fe", func(index, elem value.Value) error {
+		arg, err := cg.convertValue(elem, callbackFnSig.Params[0])
+		if err != nil {
+			return fmt.Errorf("forEach callback: %w", err)
+		}
+		cg.Block.NewCall(callbackFnVal, arg)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	cg.lastValue = arrayPtr
 	return nil
 }
@@ -2,48 +2,240 @@ package generator
 
 import (
 	"compiler/ast"
+	"compiler/lexer"
 	"fmt"
 	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
+	"strings"
 )
 
+// funcSignature records the source-level parameter list of a function so that
+// call sites can bind named arguments and fill in default values. Offset is
+// the number of implicit leading IR parameters (1 for a method's self).
+type funcSignature struct {
+	Name   string
+	Params []*ast.Parameter
+	Token  lexer.LangToken
+	Offset int
+	// Scope is the module declaring the function; default values are
+	// evaluated in it, so they may name the module's constants.
+	Scope *moduleScope
+	// Def is the function's declaration, nil for generated functions.
+	Def *ast.FunctionDefinition
+}
+
+func (cg *CodeGenerator) evaluateArgument(argExpr ast.ExpressionNode) (value.Value, error) {
+	if err := argExpr.Accept(cg); err != nil {
+		return nil, err
+	}
+	argVal := cg.lastValue
+	if argVal == nil {
+		return nil, fmt.Errorf("argument expression produced no value")
+	}
+
+	if allocaInst, isAlloca := argVal.(*ir.InstAlloca); isAlloca {
+		if ptrType, isPtr := allocaInst.ElemType.(*types.PointerType); isPtr {
+			if _, isFunc := ptrType.ElemType.(*types.FuncType); isFunc {
+				loadedFnPtr := cg.Block.NewLoad(allocaInst.ElemType, allocaInst)
+				fmt.Printf("[DEBUG] Loaded function pointer for argument: %s from %s\n", loadedFnPtr.Ident(), allocaInst.Ident())
+				argVal = loadedFnPtr
+			}
+		}
+	}
+	return argVal, nil
+}
+
 func (cg *CodeGenerator) evaluateArguments(argNodes []ast.ExpressionNode) ([]value.Value, error) {
 	args := make([]value.Value, 0, len(argNodes))
 	for _, argExpr := range argNodes {
-		if err := argExpr.Accept(cg); err != nil {
+		if _, isNamed := argExpr.(*ast.NamedArgument); isNamed {
+			return nil, fmt.Errorf("named argument '%s' used in a call to a function without a known parameter list", argExpr.String())
+		}
+		argVal, err := cg.evaluateArgument(argExpr)
+		if err != nil {
 			return nil, err
 		}
-		argVal := cg.lastValue
-		if argVal == nil {
-			return nil, fmt.Errorf("argument expression produced no value")
+		args = append(args, argVal)
+	}
+	return args, nil
+}
+
+// bindArguments matches the call-site arguments against the declared
+// parameters of sig: positional arguments fill parameters left to right,
+// named arguments bind by name and missing parameters take their default.
+// A trailing variadic parameter collects the remaining positional arguments
+// into a pack; a single argument that already is a pack of the right type is
+// forwarded as-is. Provided arguments are evaluated in source order, then any
+// defaults, and every value is converted to the IR parameter type.
+func (cg *CodeGenerator) bindArguments(sig *funcSignature, argNodes []ast.ExpressionNode, paramTypes []types.Type) ([]value.Value, error) {
+	declaredAt := fmt.Sprintf("declared at line %d", sig.Token.Line+1)
+	params := sig.Params
+	variadic := len(params) > 0 && params[len(params)-1].IsVariadic()
+	fixed := len(params)
+	if variadic {
+		fixed--
+	}
+
+	slots := make([]ast.ExpressionNode, fixed)
+	fromDefault := make([]bool, fixed)
+	var extras []ast.ExpressionNode
+	order := make([]int, 0, len(argNodes)) // parameter index per evaluated argument, -1 for extras
+
+	position := 0
+	for _, argExpr := range argNodes {
+		if named, isNamed := argExpr.(*ast.NamedArgument); isNamed {
+			idx := -1
+			for i, param := range params {
+				if param.Name.Value == named.Name.Value {
+					idx = i
+					break
+				}
+			}
+			if idx < 0 {
+				return nil, fmt.Errorf("unknown parameter '%s' in call to '%s' (%s)", named.Name.Value, sig.Name, declaredAt)
+			}
+			if idx >= fixed {
+				return nil, fmt.Errorf("variadic parameter '%s' cannot be passed by name in call to '%s'", named.Name.Value, sig.Name)
+			}
+			if slots[idx] != nil {
+				return nil, fmt.Errorf("parameter '%s' given more than once in call to '%s'", named.Name.Value, sig.Name)
+			}
+			slots[idx] = named.Value
+			order = append(order, idx)
+			continue
+		}
+		if position >= fixed {
+			if !variadic {
+				return nil, fmt.Errorf("too many arguments in call to '%s': expected at most %d, got %d (%s)", sig.Name, len(params), len(argNodes), declaredAt)
+			}
+			extras = append(extras, argExpr)
+			order = append(order, -1)
+			continue
+		}
+		slots[position] = argExpr
+		order = append(order, position)
+		position++
+	}
+
+	for i := 0; i < fixed; i++ {
+		if slots[i] != nil {
+			continue
+		}
+		if params[i].Default == nil {
+			return nil, fmt.Errorf("missing argument for parameter '%s' in call to '%s' (%s)", params[i].Name.Value, sig.Name, declaredAt)
+		}
+		slots[i] = params[i].Default
+		fromDefault[i] = true
+		order = append(order, i)
+	}
+
+	args := make([]value.Value, len(params))
+	var extraVals []value.Value
+	extraIdx := 0
+	for _, idx := range order {
+		var argExpr ast.ExpressionNode
+		var paramName string
+		if idx < 0 {
+			argExpr = extras[extraIdx]
+			extraIdx++
+			paramName = params[fixed].Name.Value
+		} else {
+			argExpr = slots[idx]
+			paramName = params[idx].Name.Value
+		}
+		callerScope := cg.scope
+		if idx >= 0 && fromDefault[idx] && sig.Scope != nil {
+			cg.scope = sig.Scope
 		}
+		argVal, err := cg.evaluateArgument(argExpr)
+		cg.scope = callerScope
+		if err != nil {
+			return nil, fmt.Errorf("argument '%s': %w", paramName, err)
+		}
+		if idx < 0 {
+			extraVals = append(extraVals, argVal)
+			continue
+		}
+		converted, err := cg.convertValue(argVal, paramTypes[idx])
+		if err != nil {
+			return nil, fmt.Errorf("argument '%s' in call to '%s': %w", paramName, sig.Name, err)
+		}
+		args[idx] = converted
+	}
 
-		if allocaInst, isAlloca := argVal.(*ir.InstAlloca); isAlloca {
-			if ptrType, isPtr := allocaInst.ElemType.(*types.PointerType); isPtr {
-				if _, isFunc := ptrType.ElemType.(*types.FuncType); isFunc {
-					loadedFnPtr := cg.Block.NewLoad(allocaInst.ElemType, allocaInst)
-					fmt.Printf("[DEBUG] Loaded function pointer for argument: %s from %s\n", loadedFnPtr.Ident(), allocaInst.Ident())
-					argVal = loadedFnPtr
+	if variadic {
+		packType, ok := paramTypes[fixed].(*types.PointerType)
+		if !ok {
+			return nil, fmt.Errorf("variadic parameter '%s' of '%s' has non-pack type %s", params[fixed].Name.Value, sig.Name, paramTypes[fixed])
+		}
+		if len(extraVals) == 1 && extraVals[0].Type().Equal(packType) {
+			args[fixed] = extraVals[0]
+		} else {
+			elemType := packType.ElemType.(*types.StructType).Fields[1].(*types.PointerType).ElemType
+			elems := make([]value.Value, len(extraVals))
+			for i, v := range extraVals {
+				converted, err := cg.convertValue(v, elemType)
+				if err != nil {
+					return nil, fmt.Errorf("variadic argument %d in call to '%s': %w", i+1, sig.Name, err)
 				}
+				elems[i] = converted
 			}
+			args[fixed] = cg.buildPack(packType, elems)
 		}
-		args = append(args, argVal)
 	}
 	return args, nil
 }
 
-func (cg *CodeGenerator) VisitCallExpression(ce *ast.CallExpression) error {
-	args, err := cg.evaluateArguments(ce.Arguments)
-	if err != nil {
-		funcNameStr := "unknown_function"
-		if ce.Function != nil {
-			funcNameStr = ce.Function.String()
+// signatureFor returns the recorded parameter list for the callee expression
+// of a call, if it names a declared function or a variable bound to one.
+func (cg *CodeGenerator) signatureFor(callee ast.ExpressionNode) *funcSignature {
+	if mae, ok := callee.(*ast.MemberAccessExpression); ok {
+		// A function called through its module alias, e.g. fs.open(path)
+		if scope := cg.moduleRef(mae.Left); scope != nil {
+			if fn, ok := scope.functions[mae.Member.Value]; ok {
+				return cg.signatures[fn]
+			}
 		}
-		return fmt.Errorf("error evaluating arguments for call to '%s': %w", funcNameStr, err)
+		return nil
+	}
+	ident, ok := callee.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	if v, ok := cg.Variables[ident.Value]; ok {
+		return cg.signatures[v]
+	}
+	if fn, ok := cg.scope.functions[ident.Value]; ok {
+		return cg.signatures[fn]
+	}
+	if fn, ok := cg.Functions[ident.Value]; ok {
+		return cg.signatures[fn]
+	}
+	return nil
+}
+
+func (cg *CodeGenerator) VisitCallExpression(ce *ast.CallExpression) error {
+	// A call is evaluated as a value, arguments included, even as the base
+	// of a member access such as f(x).field
+	isLHS := cg.inAssignmentLHS
+	cg.inAssignmentLHS = false
+	defer func() { cg.inAssignmentLHS = isLHS }()
+
+	if cg.isAssertion(ce) {
+		return cg.visitAssertion(ce)
+	}
+	if name, ok := cg.intrinsicCall(ce); ok {
+		return cg.visitIntrinsic(name, ce)
 	}
 
-	if memberAccessExpr, isMemberAccess := ce.Function.(*ast.MemberAccessExpression); isMemberAccess {
+	// Functions derived for a data type, e.g. Point.fromJson(text)
+	if mae, ok := ce.Function.(*ast.MemberAccessExpression); ok {
+		if dt := cg.dataTypeRef(mae.Left); dt != nil {
+			return cg.visitDataTypeCall(dt, mae.Member.Value, ce.Arguments)
+		}
+	}
+	if memberAccessExpr, isMemberAccess := ce.Function.(*ast.MemberAccessExpression); isMemberAccess && cg.moduleRef(memberAccessExpr.Left) == nil {
 		fmt.Printf("[DEBUG] Detected method call: %s\n", memberAccessExpr.String())
 		err := memberAccessExpr.Left.Accept(cg)
 		if err != nil {
@@ -57,6 +249,17 @@ func (cg *CodeGenerator) VisitCallExpression(ce *ast.CallExpression) error {
 
 		methodName := memberAccessExpr.Member.Value
 
+		var args []value.Value
+		if methodFunc := cg.lookupMethod(objReceiver, methodName); methodFunc != nil && cg.signatures[methodFunc] != nil {
+			sig := cg.signatures[methodFunc]
+			args, err = cg.bindArguments(sig, ce.Arguments, methodFunc.Sig.Params[sig.Offset:])
+		} else {
+			args, err = cg.evaluateArguments(ce.Arguments)
+		}
+		if err != nil {
+			return fmt.Errorf("error evaluating arguments for call to '%s': %w", ce.Function.String(), err)
+		}
+
 		return cg.handleMethodCall(objReceiver, methodName, args)
 
 	} else {
@@ -109,12 +312,32 @@ func (cg *CodeGenerator) VisitCallExpression(ce *ast.CallExpression) error {
 			return fmt.Errorf("cannot call value of type %T", fnVal)
 		}
 
+		var args []value.Value
+		var err error
+		sig := cg.signatureFor(ce.Function)
+		if sig != nil && len(sig.Params)+sig.Offset == len(fnSig.Params) {
+			args, err = cg.bindArguments(sig, ce.Arguments, fnSig.Params[sig.Offset:])
+		} else {
+			sig = nil
+			args, err = cg.evaluateArguments(ce.Arguments)
+		}
+		if err != nil {
+			return fmt.Errorf("error evaluating arguments for call to '%s': %w", ce.Function.String(), err)
+		}
+		if err := cg.checkSeqArguments(ce, sig, args); err != nil {
+			return err
+		}
+
+		// A generator's frame is allocated by its caller.
+		if gen, isGen := cg.generators[callableFn]; isGen {
+			frame := cg.newEntryAlloca(gen.frame)
+			args = append([]value.Value{frame}, args...)
+		}
+
 		if len(fnSig.Params) != len(args) {
 			return fmt.Errorf("argument count mismatch for call to '%s': expected %d, got %d", callableFn.String(), len(fnSig.Params), len(args))
 		}
 
-		// TODO: Type check arguments against fnSig.Params
-
 		call := cg.Block.NewCall(callableFn, args...)
 		if !fnSig.RetType.Equal(types.Void) {
 			cg.lastValue = call
@@ -126,6 +349,24 @@ func (cg *CodeGenerator) VisitCallExpression(ce *ast.CallExpression) error {
 	}
 }
 
+func (cg *CodeGenerator) VisitNamedArgument(na *ast.NamedArgument) error {
+	return fmt.Errorf("named argument '%s' used outside of a call argument list at line %d", na.Name.Value, na.Token.Line+1)
+}
+
+// lookupMethod returns the IR function implementing methodName for the struct
+// type the receiver points to, or nil if there is none.
+func (cg *CodeGenerator) lookupMethod(objReceiver value.Value, methodName string) *ir.Func {
+	objPtrType, ok := objReceiver.Type().(*types.PointerType)
+	if !ok {
+		return nil
+	}
+	objStructType, ok := objPtrType.ElemType.(*types.StructType)
+	if !ok {
+		return nil
+	}
+	return cg.Functions[objStructType.Name()+"_"+methodName]
+}
+
 func (cg *CodeGenerator) handleMethodCall(objReceiver value.Value, methodName string, args []value.Value) error {
 	// 1. Get receiver type info
 	objPtrType, ok := objReceiver.Type().(*types.PointerType)
@@ -140,12 +381,27 @@ func (cg *CodeGenerator) handleMethodCall(objReceiver value.Value, methodName st
 
 	fmt.Printf("[DEBUG] Method Call: receiverType=%s, method=%s\n", typeName, methodName)
 
+	// Sequences and Array.lazy are generated inline rather than called.
+	if _, isSeq := cg.seqElem(objReceiver.Type()); isSeq {
+		return cg.generateSeqMethod(objReceiver, methodName, args)
+	}
+	if methodName == "lazy" && isSliceType(objStructType) {
+		return cg.generateArrayLazy(objReceiver, args)
+	}
+
 	// 2. Find the LLVM function for the method
 	// How to link AST MethodDeclaration to LLVM ir.Func?
 	// Assume a naming convention or store mapping during class processing.
 	// Convention: ClassName_MethodName? e.g., "Array_map"
 	mangledName := typeName + "_" + methodName // Simple mangling
 	llvmMethodFunc, funcExists := cg.Functions[mangledName]
+	if dt, isData := cg.dataTypes[typeName]; !funcExists && isData && methodName == "toJson" {
+		fn, err := cg.dataFunc(dt, methodName)
+		if err != nil {
+			return err
+		}
+		llvmMethodFunc, funcExists = fn, true
+	}
 
 	if !funcExists {
 		// Fallback: Maybe it's a built-in method implemented directly in Go?
@@ -154,13 +410,14 @@ func (cg *CodeGenerator) handleMethodCall(objReceiver value.Value, methodName st
 		return fmt.Errorf("method '%s' not found for type '%s' (tried mangled name '%s')", methodName, typeName, mangledName)
 	}
 
-	// For Array built-in methods, skip argument count checking and use the placeholder
-	// TODO: implement proper Array.map/forEach with actual array iteration instead of placeholder
-	if typeName == "Array" && (methodName == "map" || methodName == "forEach") {
-		// Just call the placeholder and set a dummy result
-		// Real implementation would do the array iteration
-		cg.lastValue = objReceiver
-		return nil
+	// Array.map and Array.forEach are generated inline rather than called.
+	if typeName == "Array" && strings.HasPrefix(llvmMethodFunc.Name(), "builtin_array_") {
+		switch methodName {
+		case "map":
+			return cg.generateArrayMap(objReceiver, args)
+		case "forEach":
+			return cg.generateArrayForEach(objReceiver, args)
+		}
 	}
 
 	// 3. Prepare arguments (prepend self)
@@ -177,8 +434,9 @@ func (cg *CodeGenerator) handleMethodCall(objReceiver value.Value, methodName st
 	// }
 
 	// 6. Generate the call instruction
+	// Left unnamed: a fixed name would clash when a function calls the
+	// method twice.
 	callInst := cg.Block.NewCall(llvmMethodFunc, allArgs...)
-	callInst.SetName(methodName + "_res")
 
 	// 7. Set lastValue if method returns something
 	if !llvmMethodFunc.Sig.RetType.Equal(types.Void) {
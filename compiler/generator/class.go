@@ -7,11 +7,28 @@ import (
 	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
+	"sort"
 )
 
 func (cg *CodeGenerator) VisitClassDeclaration(cd *ast.ClassDeclaration) error {
+	if err := cg.defineStructType(cd); err != nil {
+		return err
+	}
+	return cg.generateMethods(cd)
+}
+
+// defineStructType lays out the fields of a type declaration and registers
+// the struct under its name, so that later signatures and locals can use it.
+func (cg *CodeGenerator) defineStructType(cd *ast.ClassDeclaration) error {
 	typeName := cd.Name.Value
-	if _, exists := cg.Structs[typeName]; exists {
+	// Types of imported modules are emitted under their qualified name (e.g.
+	// fs.Stat), like their functions.
+	irName := cg.scope.prefix + typeName
+	if _, exists := cg.scope.types[typeName]; exists {
+		fmt.Printf("[WARN] Type '%s' already defined, skipping definition processing.\n", typeName)
+		return nil
+	}
+	if t, exists := cg.Structs[irName]; exists && t.Name() == irName {
 		fmt.Printf("[WARN] Type '%s' already defined, skipping definition processing.\n", typeName)
 		return nil
 	}
@@ -74,21 +91,35 @@ func (cg *CodeGenerator) VisitClassDeclaration(cd *ast.ClassDeclaration) error {
 	}
 
 	// --- Step 2: Define the Struct Type in the Module ---
-	// Create the struct type with its fields directly.
+	// Create the struct type with its fields in layout order.
+	fieldTypes, fieldNames = layoutFields(cd.Layout, fieldTypes, fieldNames)
 	structType := types.NewStruct(fieldTypes...)
+	structType.Packed = cd.Layout == ast.StructLayoutPacked
 
 	// Use NewTypeDef to associate the created struct type with the name in the module.
 	// This allows referencing the type by name (e.g., %Array = type { i32, i32* })
-	definedType := cg.Module.NewTypeDef(typeName, structType)
+	definedType := cg.Module.NewTypeDef(irName, structType)
 	// Note: definedType is essentially the same as structType here for non-opaque cases,
 	// but using the result of NewTypeDef is cleaner.
 
 	// --- Step 3: Store in Compiler's Map ---
 	// Store the defined struct type (which implements types.Type) in our map.
-	cg.Structs[typeName] = definedType // Store the *types.StructType returned by NewTypeDef
+	cg.scope.types[typeName] = definedType
+	cg.Structs[irName] = definedType // Store the *types.StructType returned by NewTypeDef
+	cg.structFields[irName] = fieldNames
+	if _, taken := cg.Structs[typeName]; !taken {
+		// Unqualified names reach the first imported module declaring the type.
+		cg.Structs[typeName] = definedType
+	}
 
 	fmt.Printf("[DEBUG] Defined struct type '%s' with fields %v -> %s\n", typeName, fieldNames, definedType)
+	return nil
+}
 
+// generateMethods emits the methods of a type declaration whose struct has
+// already been defined.
+func (cg *CodeGenerator) generateMethods(cd *ast.ClassDeclaration) error {
+	typeName := cd.Name.Value
 	// --- Step 4: Process Methods ---
 	// Iterate members again to find and generate functions for methods.
 	for _, member := range cd.Members {
@@ -105,6 +136,61 @@ func (cg *CodeGenerator) VisitClassDeclaration(cd *ast.ClassDeclaration) error {
 	return nil
 }
 
+// layoutFields orders the fields of a struct. extern and packed types keep
+// the declared order, as C and the kernel expect; otherwise fields are sorted
+// by decreasing alignment (keeping the declared order among equals) so that
+// no padding is needed between them.
+func layoutFields(layout ast.StructLayout, fieldTypes []types.Type, fieldNames []string) ([]types.Type, []string) {
+	if layout != ast.StructLayoutDefault {
+		return fieldTypes, fieldNames
+	}
+	order := make([]int, len(fieldTypes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return abiAlign(fieldTypes[order[a]]) > abiAlign(fieldTypes[order[b]])
+	})
+	sortedTypes := make([]types.Type, len(order))
+	sortedNames := make([]string, len(order))
+	for i, j := range order {
+		sortedTypes[i] = fieldTypes[j]
+		sortedNames[i] = fieldNames[j]
+	}
+	return sortedTypes, sortedNames
+}
+
+// abiAlign returns the alignment in bytes of a type on the 64-bit targets.
+func abiAlign(t types.Type) int {
+	switch t := t.(type) {
+	case *types.IntType:
+		align := 1
+		for align*8 < int(t.BitSize) && align < 8 {
+			align *= 2
+		}
+		return align
+	case *types.FloatType:
+		if t.Kind == types.FloatKindFloat {
+			return 4
+		}
+		return 8
+	case *types.ArrayType:
+		return abiAlign(t.ElemType)
+	case *types.StructType:
+		if t.Packed {
+			return 1
+		}
+		align := 1
+		for _, field := range t.Fields {
+			if a := abiAlign(field); a > align {
+				align = a
+			}
+		}
+		return align
+	}
+	return 8
+}
+
 func (cg *CodeGenerator) generateMethod(className string, methodAST *ast.MethodDeclaration) error {
 	methodName := methodAST.Name.Value
 	mangledName := className + "_" + methodName // Simple name mangling
@@ -115,7 +201,7 @@ func (cg *CodeGenerator) generateMethod(className string, methodAST *ast.MethodD
 	}
 
 	// 1. Determine Parameter Types (including implicit 'self')
-	selfType, ok := cg.Structs[className] // Get the types.Type (should be *types.StructType)
+	selfType, ok := cg.scope.types[className] // Get the types.Type (should be *types.StructType)
 	if !ok {
 		return fmt.Errorf("internal error: struct type '%s' not found when generating method '%s'", className, methodName)
 	}
@@ -177,6 +263,7 @@ func (cg *CodeGenerator) generateMethod(className string, methodAST *ast.MethodD
 
 	llvmFunc := cg.Module.NewFunc(mangledName, retType, funcParams...)
 	cg.Functions[mangledName] = llvmFunc // Store the function
+	cg.signatures[llvmFunc] = &funcSignature{Name: className + "." + methodName, Params: methodAST.Parameters, Token: methodAST.Token, Offset: 1, Scope: cg.scope}
 
 	fmt.Printf("[DEBUG] Declared method '%s' as LLVM function '%s' with signature %s\n", methodName, mangledName, llvmFunc.Sig)
 
@@ -2,7 +2,9 @@ package generator
 
 import (
 	"compiler/ast"
+	"compiler/compiler/target"
 	"compiler/module"
+	"errors"
 	"fmt"
 	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/types"
@@ -13,11 +15,31 @@ import (
 type CodeGenerator struct {
 	ModuleManager *module.ModuleManager
 	Module        *ir.Module
-	Functions     map[string]*ir.Func
-	Variables     map[string]value.Value
-	Structs       map[string]types.Type
-	Block         *ir.Block
-	currentFunc   *ir.Func
+	target        *target.Target
+
+	// Freestanding programs get their own _start and do not rely on a C
+	// runtime to reach main or to exit.
+	Freestanding bool
+	// TestModule is the import path of the module whose test blocks are
+	// compiled. When set, main is replaced by a harness running them with
+	// stdlib/testing; otherwise test blocks are left out.
+	TestModule string
+	// SourceFile names the program's source file in assertion failures.
+	SourceFile string
+	// Types, when not nil, receives the type of the variable each let
+	// statement declares and of the value of each expression statement,
+	// void when it has none, for tools showing inferred types.
+	Types map[ast.Statement]types.Type
+	// FailedAt is, once generating code has failed, the innermost statement
+	// whose code could not be generated, for tools locating the error.
+	FailedAt  ast.Statement
+	Functions map[string]*ir.Func
+	Variables map[string]value.Value
+	Structs   map[string]types.Type
+	// structFields lists the field names of each named struct in layout order.
+	structFields map[string][]string
+	Block        *ir.Block
+	currentFunc  *ir.Func
 
 	// lastValue holds the most recently produced LLVM value by a node visit.
 	lastValue value.Value
@@ -26,26 +48,101 @@ type CodeGenerator struct {
 
 	methodCallReceiver value.Value
 
+	// signatures maps functions, and variables holding function pointers, to
+	// their source parameter lists for named and default argument binding.
+	signatures map[value.Value]*funcSignature
+
+	// loopDepth counts the loops enclosing the code being generated.
+	loopDepth int
+
+	// scope holds the top-level names of the module being compiled; modules
+	// maps import paths to their scopes and moduleAliases maps the alias an
+	// import introduces (the last path element) to its path.
+	scope         *moduleScope
+	modules       map[string]*moduleScope
+	moduleAliases map[string]string
+
+	// procArgs holds the command line globals once a program asks for them.
+	procArgs *processArgs
+
+	// finalizers are the fini functions of imported modules, in the order the
+	// modules were compiled; finiFn runs them in reverse when the program exits.
+	finalizers []*ir.Func
+	finiFn     *ir.Func
+
+	// usesTLS is set once a thread-local global is defined, so a
+	// freestanding main thread gets a TLS area; tls holds the functions
+	// laying TLS areas out once emitted.
+	usesTLS bool
+	tls     *tlsRuntime
+
+	// generators maps the function starting each generator to its frame
+	// and resume function; gen is the generator whose body is being
+	// generated, if any.
+	generators map[value.Value]*generatorInfo
+	gen        *generatorState
+
+	// seqTypes holds the seq<T> header struct of each element type and
+	// seqAdapters the lazy sequences emitted so far.
+	seqTypes    map[string]*types.StructType
+	seqAdapters map[string]*seqAdapter
+
+	// keeper tells which functions keep a seq argument past the call;
+	// definitions maps each declared function to its signature for it.
+	keeper      ast.ParamKeeper
+	definitions map[*ast.FunctionDefinition]*funcSignature
+
+	// dataTypes maps the IR name of the struct of each data declaration to
+	// what the functions derived from it need.
+	dataTypes map[string]*dataType
+
+	// tests holds the test blocks of TestModule until the harness is
+	// generated.
+	tests []*pendingTest
+
+	// stringCounter numbers the globals holding string literals.
+	stringCounter int
+
 	// blockCounter is incremented each time a new labelled block is created so
 	// that inner loops / nested ifs never share a label with an outer one.
 	blockCounter int
 }
 
+// NewCodeGenerator creates a code generator for the default target.
 func NewCodeGenerator() *CodeGenerator {
+	return NewCodeGeneratorForTarget(target.Default)
+}
+
+// NewCodeGeneratorForTarget creates a code generator emitting IR for t.
+func NewCodeGeneratorForTarget(t *target.Target) *CodeGenerator {
 	m := ir.NewModule()
+	m.TargetTriple = t.Triple
+	m.DataLayout = t.DataLayout
 	mm := module.NewModuleManager()
-	builtInManager := NewBuiltInManager(m)
+	builtInManager := NewBuiltInManager(m, t)
 
 	cg := &CodeGenerator{
 		ModuleManager: mm,
 		Module:        m,
+		target:        t,
 		Functions:     builtInManager.GetProvidedFunctionsMap(),
 		Variables:     make(map[string]value.Value),
 		Structs:       make(map[string]types.Type),
+		structFields:  make(map[string][]string),
 		Block:         nil,
 		currentFunc:   nil,
 		lastValue:     nil,
+		signatures:    make(map[value.Value]*funcSignature),
+		scope:         newModuleScope("", ""),
+		modules:       make(map[string]*moduleScope),
+		moduleAliases: make(map[string]string),
+		generators:    make(map[value.Value]*generatorInfo),
+		seqTypes:      make(map[string]*types.StructType),
+		seqAdapters:   make(map[string]*seqAdapter),
+		dataTypes:     make(map[string]*dataType),
+		definitions:   make(map[*ast.FunctionDefinition]*funcSignature),
 	}
+	cg.keeper.Callee = cg.keptCallee
 
 	// Pre-define the Array struct type used by array operations
 	llvmIntType := types.I32
@@ -53,6 +150,10 @@ func NewCodeGenerator() *CodeGenerator {
 	arrayStructType := types.NewStruct(llvmIntType, llvmIntPtrType)
 	m.NewTypeDef("Array", arrayStructType)
 	cg.Structs["Array"] = arrayStructType
+	cg.structFields["Array"] = []string{"length", "data"}
+
+	cg.modules[syscallTableName] = newSyscallScope(t.Syscall.Numbers)
+	cg.moduleAliases[syscallTableName] = syscallTableName
 
 	return cg
 }
@@ -93,15 +194,35 @@ func (cg *CodeGenerator) VisitVariableDeclaration(vd *ast.VariableDeclaration) e
 }
 
 func (cg *CodeGenerator) VisitProgram(program *ast.Program) error {
+	// The test harness is built on stdlib/testing.
+	testHarness := cg.TestModule != "" && cg.scope.path == ""
+	if testHarness {
+		if err := cg.VisitImportStatement(&ast.ImportStatement{Path: testingModule}); err != nil {
+			return fmt.Errorf("error visiting import %s: %w", testingModule, err)
+		}
+	}
 	for _, is := range program.ImportStatements {
 		if err := is.Accept(cg); err != nil {
 			return fmt.Errorf("error visiting import %s: %w", is.Path, err)
 		}
 	}
 
+	// Lay out declared types first so signatures and globals can use them.
+	for _, cd := range program.ClassDeclarations {
+		if err := cg.defineStructType(cd); err != nil {
+			return fmt.Errorf("error defining type %s: %w", cd.Name.Value, err)
+		}
+	}
+
+	for _, ds := range program.DataStructures {
+		if err := cg.defineDataType(ds); err != nil {
+			return fmt.Errorf("error defining data type %s: %w", ds.Name.Value, err)
+		}
+	}
+
 	// Pre-declare all functions (including main) to handle forward references
 	// and allow module integration to find them.
-	if program.MainFunction != nil {
+	if program.MainFunction != nil && !testHarness {
 		if err := cg.declareFunction(program.MainFunction); err != nil {
 			return fmt.Errorf("error declaring main function: %w", err)
 		}
@@ -112,6 +233,28 @@ func (cg *CodeGenerator) VisitProgram(program *ast.Program) error {
 		}
 	}
 
+	// Define top-level constants and globals after those their initializers
+	// refer to, wherever these are declared.
+	globals, err := ast.InitOrder(program.Globals)
+	if err != nil {
+		var cycle *ast.InitCycleError
+		if errors.As(err, &cycle) {
+			cg.FailedAt = cycle.Cycle[0]
+		}
+		return err
+	}
+	for _, ls := range globals {
+		if err := cg.defineGlobal(ls); err != nil {
+			return err
+		}
+	}
+
+	for _, cd := range program.ClassDeclarations {
+		if err := cg.generateMethods(cd); err != nil {
+			return err
+		}
+	}
+
 	// Visit each normal function definition to generate its body.
 	for _, fn := range program.Functions {
 		if err := fn.Accept(cg); err != nil {
@@ -119,11 +262,35 @@ func (cg *CodeGenerator) VisitProgram(program *ast.Program) error {
 		}
 	}
 	// Then visit the main function definition, if any.
-	if program.MainFunction != nil {
+	if program.MainFunction != nil && !testHarness {
 		if err := program.MainFunction.Accept(cg); err != nil {
 			return fmt.Errorf("error visiting main function: %w", err)
 		}
 	}
+
+	// Test blocks are compiled once the whole program is known, as they
+	// may use any module, stdlib/testing included.
+	if cg.TestModule != "" && cg.scope.path == cg.TestModule {
+		for _, tb := range program.Tests {
+			cg.tests = append(cg.tests, &pendingTest{block: tb, scope: cg.scope})
+		}
+	}
+
+	// Only the program itself (not an imported module) provides the exit
+	// path and the entry point.
+	if cg.scope.path == "" {
+		if testHarness {
+			if err := cg.defineTestHarness(); err != nil {
+				return err
+			}
+		}
+		cg.defineFini()
+		if cg.Freestanding {
+			if err := cg.defineStart(); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
@@ -135,19 +302,41 @@ func (cg *CodeGenerator) declareFunction(fn *ast.FunctionDefinition) error {
 		return fmt.Errorf("declareFunction received anonymous function AST node")
 	}
 
-	if existingFunc, exists := cg.Functions[fnName]; exists {
+	// Functions of imported modules are emitted under their qualified name
+	// (e.g. sys.write) so they cannot clash with the program's own functions or
+	// with C symbols of the same name.
+	irName := cg.scope.prefix + fnName
+	if existingFunc, exists := cg.scope.functions[fnName]; exists {
+		fmt.Printf("[DEBUG] Function '%s' already declared (Sig: %s), skipping.\n", fnName, existingFunc.Sig.String())
+		return nil
+	}
+	if existingFunc, exists := cg.Functions[fnName]; exists && existingFunc.Name() == irName {
 		fmt.Printf("[DEBUG] Function '%s' already declared (Sig: %s), skipping.\n", fnName, existingFunc.Sig.String())
 		return nil
 	}
 
+	if fn.Generator {
+		irFunc, err := cg.declareGenerator(fn, irName)
+		if err != nil {
+			return err
+		}
+		// The frame is an implicit first parameter supplied by the call.
+		cg.registerFunction(fnName, irFunc, &funcSignature{Name: fnName, Params: fn.Parameters, Token: fn.Token, Offset: 1, Scope: cg.scope, Def: fn})
+		return nil
+	}
+
 	// Determine parameter types and names
 	paramTypes := make([]types.Type, len(fn.Parameters))
 	paramNames := make([]string, len(fn.Parameters))
 	fmt.Printf("[DEBUG] declareFunction '%s': Processing %d AST parameters.\n", fnName, len(fn.Parameters))
 	for i, paramAST := range fn.Parameters {
-		paramTypes[i] = types.I32 // Assuming i32 for now fix later
-		paramNames[i] = paramAST.Value
-		fmt.Printf("[DEBUG] declareFunction '%s': Found param %d: Name='%s' (assuming Type=i32)\n", fnName, i, paramNames[i])
+		paramType, err := cg.paramType(paramAST)
+		if err != nil {
+			return fmt.Errorf("parameter '%s' of function '%s': %w", paramAST.Name.Value, fnName, err)
+		}
+		paramTypes[i] = paramType
+		paramNames[i] = paramAST.Name.Value
+		fmt.Printf("[DEBUG] declareFunction '%s': Found param %d: Name='%s' Type=%s\n", fnName, i, paramNames[i], paramTypes[i])
 	}
 
 	// Determine return type
@@ -180,7 +369,9 @@ func (cg *CodeGenerator) declareFunction(fn *ast.FunctionDefinition) error {
 							break
 						}
 						asmCode := asmExpr.Code.Value
-						isVoidBuiltin := false
+						// An asm block produces no value; its outputs are
+						// stored to variables.
+						isVoidBuiltin := asmExpr.Block
 						if builtinFunc, exists := cg.Functions[asmCode]; exists {
 							if builtinFunc.Sig.RetType.Equal(types.Void) {
 								isVoidBuiltin = true
@@ -216,17 +407,32 @@ func (cg *CodeGenerator) declareFunction(fn *ast.FunctionDefinition) error {
 		funcParams[i] = ir.NewParam(pName, paramTypes[i])
 	}
 
-	irFunc := cg.Module.NewFunc(fnName, retType, funcParams...)
+	irFunc := cg.Module.NewFunc(irName, retType, funcParams...)
 
 	fmt.Printf("[DEBUG] declareFunction '%s': Created Func. Checking Sig(): %s\n", fnName, irFunc.Sig.String())
 
 	fmt.Printf("[DEBUG] declareFunction '%s': Func.Params field has %d entries.\n", fnName, len(irFunc.Params))
 
-	cg.Functions[fnName] = irFunc
-	fmt.Printf("[DEBUG] Stored function '%s' in Functions map.\n", fnName)
+	cg.registerFunction(fnName, irFunc, &funcSignature{Name: fnName, Params: fn.Parameters, Token: fn.Token, Scope: cg.scope, Def: fn})
 	return nil
 }
 
+// registerFunction makes a declared function callable by name from the
+// current module and records its source parameter list.
+func (cg *CodeGenerator) registerFunction(fnName string, irFunc *ir.Func, sig *funcSignature) {
+	cg.scope.functions[fnName] = irFunc
+	if _, taken := cg.Functions[fnName]; !taken || cg.scope.prefix == "" {
+		// Unqualified calls prefer the program's own functions, then the first
+		// imported module declaring the name.
+		cg.Functions[fnName] = irFunc
+	}
+	cg.signatures[irFunc] = sig
+	if sig.Def != nil {
+		cg.definitions[sig.Def] = sig
+	}
+	fmt.Printf("[DEBUG] Stored function '%s' in Functions map.\n", fnName)
+}
+
 func (cg *CodeGenerator) resolveStructType(typeName string) (*types.StructType, error) {
 	t, ok := cg.Structs[typeName]
 	if !ok {
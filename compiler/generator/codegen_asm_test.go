@@ -0,0 +1,195 @@
+package generator
+
+import (
+	"compiler/compiler/target"
+	"compiler/lexer"
+	"compiler/parser"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestCodeGenInlineAsm covers asm blocks: operand binding, the template
+// rewriting of %N and $, dialects, side effects and constraint diagnostics.
+func TestCodeGenInlineAsm(t *testing.T) {
+	tests := []struct {
+		name                 string
+		input                string
+		target               *target.Target // nil for the default target
+		expectedIRSubstrings []string
+		expectedError        string // Substring of the expected error, empty if none
+	}{
+		{
+			name:  "README Example",
+			input: `performLowLevelOperation() -> { asm intel { "mov eax, 1" } } main() -> { performLowLevelOperation(); return 0; }`,
+			expectedIRSubstrings: []string{
+				`define void @performLowLevelOperation\(\)`,
+				`call void asm sideeffect inteldialect "mov eax, 1", ""\(\)`,
+			},
+		},
+		{
+			name:  "Basic Asm Keeps Percent And Escapes Dollar",
+			input: `main() -> { asm { "movl $1, %eax" } return 0; }`,
+			expectedIRSubstrings: []string{
+				`call void asm sideeffect "movl \$\$1, %eax", ""\(\)`,
+			},
+		},
+		{
+			name:  "Asm Text In Parentheses",
+			input: `main() -> { asm("pause"); return 0; }`,
+			expectedIRSubstrings: []string{
+				`call void asm sideeffect "pause", ""\(\)`,
+			},
+		},
+		{
+			name:  "Outputs Inputs And Clobbers",
+			input: `main() -> { let a: i64 = 2; let b: i64 = 3; let r: i64 = 0; asm { "mov %1, %0" "add %2, %0" : "=&r"(r) : "r"(a), "r"(b) : "cc" } return r; }`,
+			expectedIRSubstrings: []string{
+				`%[0-9]+ = call i64 asm "mov \$1, \$0\\0Aadd \$2, \$0", "=&r,r,r,~\{cc\}"\(i64 %[0-9]+, i64 %[0-9]+\)\n\s+store i64 %[0-9]+, i64\* %[0-9]+`,
+			},
+		},
+		{
+			name:  "Read Write Output Is Tied",
+			input: `main() -> { let x: i64 = 5; asm volatile { "addq $10, %0" : "+r"(x) } return x; }`,
+			expectedIRSubstrings: []string{
+				`call i64 asm sideeffect "addq \$\$10, \$0", "=r,0"\(i64 %[0-9]+\)`,
+			},
+		},
+		{
+			name:  "Several Outputs And Explicit Registers",
+			input: `main() -> { let lo: i32 = 0; let hi: i32 = 0; asm volatile { "rdtsc" : "={eax}"(lo), "={edx}"(hi) } return lo; }`,
+			expectedIRSubstrings: []string{
+				`(%[0-9]+) = call \{ i32, i32 \} asm sideeffect "rdtsc", "=\{eax\},=\{edx\}"\(\)`,
+				`extractvalue \{ i32, i32 \} %[0-9]+, 0\n\s+store i32 %[0-9]+, i32\* %[0-9]+`,
+				`extractvalue \{ i32, i32 \} %[0-9]+, 1\n\s+store i32 %[0-9]+, i32\* %[0-9]+`,
+			},
+		},
+		{
+			name:  "Memory Operand Is Passed By Address",
+			input: `main() -> { let m: i64 = 7; asm { "incq %0" : "=m"(m) : : "memory" } return m; }`,
+			expectedIRSubstrings: []string{
+				`call void asm sideeffect "incq \$0", "=\*m,~\{memory\}"\(i64\* elementtype\(i64\) %[0-9]+\)`,
+			},
+		},
+		{
+			name:   "Operand Modifier On arm64",
+			input:  `main() -> { let r: i32 = 0; asm { "mov %w0, #1" : "=r"(r) } return r; }`,
+			target: target.ARM64,
+			expectedIRSubstrings: []string{
+				`call i32 asm "mov \$\{0:w\}, #1", "=r"\(\)`,
+			},
+		},
+		{
+			name:          "Output Without Equals",
+			input:         `main() -> { let r: i64 = 0; asm { "nop" : "r"(r) } return 0; }`,
+			expectedError: `asm output 0: invalid constraint "r": outputs must start with '=' or '+'`,
+		},
+		{
+			name:          "Equals On Input",
+			input:         `main() -> { let r: i64 = 0; asm { "nop" : : "=r"(r) } return 0; }`,
+			expectedError: `asm input 0: invalid constraint "=r": '=' is only allowed on outputs`,
+		},
+		{
+			name:          "Unknown Operand Kind",
+			input:         `main() -> { let r: i64 = 0; asm { "nop" : "=Z"(r) } return 0; }`,
+			expectedError: `invalid constraint "=Z": unknown operand kind 'Z' on amd64`,
+		},
+		{
+			name:          "Target Specific Kind Elsewhere",
+			input:         `main() -> { let r: i64 = 0; asm { "nop" : "=a"(r) } return 0; }`,
+			target:        target.RISCV64,
+			expectedError: `unknown operand kind 'a' on riscv64`,
+		},
+		{
+			name:          "Malformed Register",
+			input:         `main() -> { let r: i64 = 0; asm { "nop" : "={rax"(r) } return 0; }`,
+			expectedError: `malformed register name`,
+		},
+		{
+			name:          "Tie To Missing Output",
+			input:         `main() -> { let r: i64 = 0; asm { "nop" : "=r"(r) : "1"(r) } return 0; }`,
+			expectedError: `no output operand 1 to tie to`,
+		},
+		{
+			name:          "Output Must Be Assignable",
+			input:         `const K = 1; main() -> { asm { "nop" : "=r"(K) } return 0; }`,
+			expectedError: `cannot bind constant 'K' to an asm operand`,
+		},
+		{
+			name:          "Bad Clobber",
+			input:         `main() -> { asm { "nop" : : : "~{rax}" } return 0; }`,
+			expectedError: `invalid asm clobber "~{rax}"`,
+		},
+		{
+			name:          "Clobber Must Be A Register Of The Target",
+			input:         `main() -> { asm { "nop" : : : "xmm0" } return 0; }`,
+			target:        target.ARM64,
+			expectedError: `invalid asm clobber "xmm0": expected "memory", "cc" or a register of arm64`,
+		},
+		{
+			name:   "Register Clobbers",
+			input:  `main() -> { asm volatile { "ecall" : : : "a0", "t1", "memory" } return 0; }`,
+			target: target.RISCV64,
+			expectedIRSubstrings: []string{
+				`call void asm sideeffect "ecall", "~\{a0\},~\{t1\},~\{memory\}"\(\)`,
+			},
+		},
+		{
+			name:          "Operand Out Of Range",
+			input:         `main() -> { let r: i64 = 0; asm { "mov %1, %0" : "=r"(r) } return r; }`,
+			expectedError: `asm operand %1 out of range: the block has 1 operand(s)`,
+		},
+		{
+			name:          "Modified Operand Out Of Range",
+			input:         `main() -> { asm { "mov %w0, #1" : : : "x0" } return 0; }`,
+			target:        target.ARM64,
+			expectedError: `asm operand %w0 out of range: the block has 0 operand(s)`,
+		},
+		{
+			name:          "Unknown Intrinsic",
+			input:         `main() -> { asm("builtin_nope"); return 0; }`,
+			expectedError: `unsupported or unknown asm code: 'builtin_nope'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := lexer.NewLexerFromString(tt.input)
+			if err != nil {
+				t.Fatalf("Lexer creation failed: %v", err)
+			}
+			p := parser.NewParser(l)
+			prog := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("Parser errors: %v", p.Errors())
+			}
+
+			cg := NewCodeGenerator()
+			if tt.target != nil {
+				cg = NewCodeGeneratorForTarget(tt.target)
+			}
+			err = prog.Accept(cg)
+
+			if tt.expectedError != "" {
+				if err == nil {
+					t.Fatalf("Expected error containing %q, got nil", tt.expectedError)
+				}
+				if !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error containing %q, got: %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Code generation failed: %v", err)
+			}
+
+			ir := cg.Module.String()
+			for _, subPattern := range tt.expectedIRSubstrings {
+				re := regexp.MustCompile(subPattern)
+				if !re.MatchString(ir) {
+					t.Errorf("Generated IR missing expected pattern for test %q.\nExpected pattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+		})
+	}
+}
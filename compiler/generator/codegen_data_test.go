@@ -0,0 +1,100 @@
+package generator
+
+import (
+	"compiler/lexer"
+	"compiler/parser"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestCodeGenData covers the struct layout of data declarations and the
+// errors of the functions derived for them. The derived functions need
+// stdlib/json and stdlib/mem, so json_program_test.go runs them.
+func TestCodeGenData(t *testing.T) {
+	tests := []struct {
+		name                 string
+		input                string
+		expectedIRSubstrings []string
+		unexpectedIR         []string // Patterns that must not appear
+		expectedError        string   // Substring of the expected error, empty if none
+	}{
+		{
+			name:                 "Typed And Untyped Fields",
+			input:                `data Point { let x: i32, let tag } main() -> { let p: *Point = 0 as *Point; return 0; }`,
+			expectedIRSubstrings: []string{`%Point = type \{ %Any, i32 \}`},
+			unexpectedIR:         []string{`@Point_new`, `@Point_toJson`, `@Point_fromJson`},
+		},
+		{
+			name: "Field Layout",
+			input: `packed type Pad { let b: u8; }
+				data Rec { let a: u8; let b: f64; let c: *Pad; }
+				main() -> { let r: *Rec = 0 as *Rec; return 0; }`,
+			expectedIRSubstrings: []string{`%Rec = type \{ double, %Pad\*, i8 \}`},
+		},
+		{
+			name:          "New Without stdlib/mem",
+			input:         `data Point { let x: i64 } main() -> { let p = Point.new(); return 0; }`,
+			expectedError: "Point.new needs stdlib/mem; import it",
+		},
+		{
+			name:          "FromJson Without stdlib/json",
+			input:         `data Point { let x: i64 } main() -> { let p = Point.fromJson("{}"); return 0; }`,
+			expectedError: "Point.fromJson needs stdlib/json; import it",
+		},
+		{
+			name:          "FromJson Argument Count",
+			input:         `data Point { let x: i64 } main() -> { let p = Point.fromJson(1, 2); return 0; }`,
+			expectedError: "Point.fromJson expects 1 argument, got 2",
+		},
+		{
+			name:          "Unknown Function",
+			input:         `data Point { let x: i64 } main() -> { let p = Point.copy(); return 0; }`,
+			expectedError: "data type 'Point' has no function 'copy'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := lexer.NewLexerFromString(tt.input)
+			if err != nil {
+				t.Fatalf("Lexer creation failed: %v", err)
+			}
+			p := parser.NewParser(l)
+			prog := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("Parser errors: %v", p.Errors())
+			}
+
+			cg := NewCodeGenerator()
+			err = prog.Accept(cg)
+
+			if tt.expectedError != "" {
+				if err == nil {
+					t.Fatalf("Expected error containing %q, got nil", tt.expectedError)
+				}
+				if !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error containing %q, got: %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Code generation failed: %v", err)
+			}
+
+			ir := cg.Module.String()
+			for _, subPattern := range tt.expectedIRSubstrings {
+				re := regexp.MustCompile(subPattern)
+				if !re.MatchString(ir) {
+					t.Errorf("Generated IR missing expected pattern for test %q.\nExpected pattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+			for _, subPattern := range tt.unexpectedIR {
+				re := regexp.MustCompile(subPattern)
+				if re.MatchString(ir) {
+					t.Errorf("Generated IR contains unexpected pattern for test %q.\nPattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+		})
+	}
+}
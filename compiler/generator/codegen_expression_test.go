@@ -62,6 +62,22 @@ func TestCodeGenInfixExpressionUnit(t *testing.T) {
 			expectedResultRe: `(%[a-zA-Z0-9_.]+) = add (nsw )?i32 %[a-zA-Z0-9_.]+, %[a-zA-Z0-9_.]+`,
 			expectedRetRe:    `ret i32 %[a-zA-Z0-9_.]+`,
 		},
+		{
+			name:              "Bitwise And",
+			input:             `12 & 10`,
+			setupInput:        "",
+			expectedOperation: "and",
+			expectedResultRe:  `(%[a-zA-Z0-9_.]+) = and i32 12, 10`,
+			expectedRetRe:     `ret i32 %[a-zA-Z0-9_.]+`,
+		},
+		{
+			name:              "Logical Shift Right",
+			input:             `a >>> 2`,
+			setupInput:        `let a = -8;`,
+			expectedOperation: "lshr", // >> is ashr, >>> shifts in zeros
+			expectedResultRe:  `(%[a-zA-Z0-9_.]+) = lshr i32 %[a-zA-Z0-9_.]+, 2`,
+			expectedRetRe:     `ret i32 %[a-zA-Z0-9_.]+`,
+		},
 		// Add tests for comparison operators (>, <, ==, !=) when implemented
 		// {
 		//  name:            "Greater Than",
@@ -0,0 +1,140 @@
+package generator
+
+import (
+	"compiler/lexer"
+	"compiler/parser"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestCodeGenGenerators covers the frame layout and resume state machine of
+// function* generators, for-in loops and the lazy seq adapters.
+func TestCodeGenGenerators(t *testing.T) {
+	tests := []struct {
+		name                 string
+		input                string
+		expectedIRSubstrings []string
+		unexpectedIR         []string // Patterns that must not appear
+		expectedError        string   // Substring of the expected error, empty if none
+	}{
+		{
+			name: "Generator Frame And Resume",
+			input: `function* count(from: i64, to: i64): i64 -> {
+					let i = from;
+					while (i < to) { yield i; i = i + 1; }
+				}
+				main() -> { let s = 0; for x in count(1, 3) { s = s + 1; } return s; }`,
+			expectedIRSubstrings: []string{
+				`%count.frame = type \{ i1 \(i8\*\)\*, i64, i32, i64, i64, i64 \}`,
+				`%Seq.i64 = type \{ i1 \(i8\*\)\*, i64 \}`,
+				`define internal i1 @count.resume\(i8\* %gen.frame\)`,
+				`switch i32 %[0-9]+, label %gen.done \[\s*i32 0, label %gen.start\s*i32 1, label %gen.resume.1\s*\]`,
+				`store i32 -1, i32\* %[0-9]+`,
+				`define %Seq.i64\* @count\(%count.frame\* %gen.frame, i64 %from, i64 %to\)`,
+				`alloca %count.frame\n`,
+				`call %Seq.i64\* @count\(%count.frame\* %[0-9]+, i64 1, i64 3\)`,
+			},
+			// Locals live in the frame, not on the resume function's stack.
+			unexpectedIR: []string{`define internal i1 @count.resume\(i8\* %gen.frame\) \{\n[^}]*alloca`},
+		},
+		{
+			name: "Lazy Array Pipeline",
+			input: `main() -> {
+					let a = [1, 2, 3];
+					let s = 0;
+					for v in a.lazy().map((x: int) -> x * 2).filter((x: int) -> x > 2).take(1) { s = s + v; }
+					return s;
+				}`,
+			expectedIRSubstrings: []string{
+				`define internal i1 @seq.lazy.[0-9]+\(i8\* %gen.frame\)`,
+				`define internal i1 @seq.map.[0-9]+\(i8\* %gen.frame\)`,
+				`define internal i1 @seq.filter.[0-9]+\(i8\* %gen.frame\)`,
+				`define internal i1 @seq.take.[0-9]+\(i8\* %gen.frame\)`,
+			},
+		},
+		{
+			name: "Stepping A Seq By Hand",
+			input: `function* g(): i64 -> { yield 7; }
+				main() -> { let s = g(); let n = 0; while (s.next()) { n = n + s.current(); } return n; }`,
+			expectedIRSubstrings: []string{
+				`load i1 \(i8\*\)\*, i1 \(i8\*\)\*\* %[0-9]+\n\s+%[0-9]+ = bitcast %Seq.i64\* %[0-9]+ to i8\*\n\s+%[0-9]+ = call i1 %[0-9]+\(i8\* %[0-9]+\)`,
+				`getelementptr %Seq.i64, %Seq.i64\* %[0-9]+, i32 0, i32 1\n\s+%[0-9]+ = load i64, i64\* %[0-9]+`,
+			},
+		},
+		{
+			name:          "Yield Outside Generator",
+			input:         `main() -> { yield 1; return 0; }`,
+			expectedError: "yield outside of a generator function",
+		},
+		{
+			name:          "Return With Value",
+			input:         `function* g(): i64 -> { return 5; } main() -> { return 0; }`,
+			expectedError: "cannot have a value; use yield",
+		},
+		{
+			name:          "Recursive Generator",
+			input:         `function* g(n: i64): i64 -> { for x in g(n) { yield x; } } main() -> { return 0; }`,
+			expectedError: "a generator cannot call itself",
+		},
+		{
+			name:          "Run Time Sized Allocation In Generator",
+			input:         `function* g(): i64 -> { let a = [1, 2]; let b = a.map((x: int) -> x); yield 1; } main() -> { return 0; }`,
+			expectedError: "size is only known at run time",
+		},
+		{
+			name:          "For Over A Number",
+			input:         `main() -> { for x in 5 { } return 0; }`,
+			expectedError: "cannot iterate over '5' of type i32",
+		},
+		{
+			name:          "Unknown Seq Method",
+			input:         `function* g(): i64 -> { yield 1; } main() -> { g().reduce(0); return 0; }`,
+			expectedError: "method 'reduce' not found for seq",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := lexer.NewLexerFromString(tt.input)
+			if err != nil {
+				t.Fatalf("Lexer creation failed: %v", err)
+			}
+			p := parser.NewParser(l)
+			prog := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("Parser errors: %v", p.Errors())
+			}
+
+			cg := NewCodeGenerator()
+			err = prog.Accept(cg)
+
+			if tt.expectedError != "" {
+				if err == nil {
+					t.Fatalf("Expected error containing %q, got nil", tt.expectedError)
+				}
+				if !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error containing %q, got: %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Code generation failed: %v", err)
+			}
+
+			ir := cg.Module.String()
+			for _, subPattern := range tt.expectedIRSubstrings {
+				re := regexp.MustCompile(subPattern)
+				if !re.MatchString(ir) {
+					t.Errorf("Generated IR missing expected pattern for test %q.\nExpected pattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+			for _, subPattern := range tt.unexpectedIR {
+				re := regexp.MustCompile(subPattern)
+				if re.MatchString(ir) {
+					t.Errorf("Generated IR contains unexpected pattern for test %q.\nPattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+package generator
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestCodeGenGlobals covers top-level const and let declarations: constant
+// folding, static and lazily initialised globals, and constant assignment
+// diagnostics.
+func TestCodeGenGlobals(t *testing.T) {
+	tests := []struct {
+		name                 string
+		input                string
+		expectedIRSubstrings []string
+		unexpectedIR         []string // Patterns that must not appear
+		expectedError        string   // Substring of the expected error, empty if none
+	}{
+		{
+			name:  "Constants Fold At Compile Time",
+			input: `const BASE = 0x10; const LIMIT = BASE * 4 + 2; main() -> { return LIMIT; }`,
+			expectedIRSubstrings: []string{
+				`ret i32 66`,
+			},
+			unexpectedIR: []string{`@BASE`, `@LIMIT`, `mul i32`},
+		},
+		{
+			name:  "Bitwise Constants",
+			input: `const FLAGS = 1 << 4 | 3; const TOP = ~0 >>> 28; const SIGN = -32 >> 4; main() -> { let a = FLAGS; let b = TOP; let c = SIGN; return 0; }`,
+			expectedIRSubstrings: []string{
+				`store i32 19, i32\* %`,
+				`store i32 15, i32\* %`,
+				`store i32 -2, i32\* %`,
+			},
+			unexpectedIR: []string{`shl i32`, `lshr i32`, `ashr i32`},
+		},
+		{
+			name:  "Wide Literals Are Exact",
+			input: `const K = 0x736f6d6570736575; main() -> { let k = K; let d = 9007199254740993; let m = 0xffffffffffffffff; return 0; }`,
+			expectedIRSubstrings: []string{
+				`store i64 8317987319222330741, i64\* %`,
+				`store i64 u0x20000000000001, i64\* %`, // 2^53 + 1, which llir prints in hex
+				`store i32 -1, i32\* %`,
+			},
+		},
+		{
+			name:          "Constant Shift Out Of Range",
+			input:         `const BAD = 1 << 32; main() -> { return BAD; }`,
+			expectedError: "shift count 32 out of range",
+		},
+		{
+			name:  "Typed Constant Is Converted",
+			input: `const WIDE: i64 = -100; main() -> { let x: i64 = WIDE; return 0; }`,
+			expectedIRSubstrings: []string{
+				`store i64 -100, i64\* %`,
+			},
+		},
+		{
+			name:  "Float And Comparison Constants",
+			input: `const HALF = 1.0 / 2; const BIG = 3 > 2; main() -> { let h = HALF; let b = BIG; return 0; }`,
+			expectedIRSubstrings: []string{
+				`store float 0\.5, float\* %`,
+				`store i1 true, i1\* %`,
+			},
+		},
+		{
+			name:  "String Constant",
+			input: `const NAME = "ylang"; main() -> { let s = NAME; return 0; }`,
+			expectedIRSubstrings: []string{
+				`store i8\* getelementptr \(\[6 x i8\], \[6 x i8\]\* @str_[0-9]+, i32 0, i32 0\), i8\*\* %`,
+			},
+		},
+		{
+			name:  "Global With Static Initializer",
+			input: `let counter = 5; bump() -> { counter = counter + 1; return counter; } main() -> bump();`,
+			expectedIRSubstrings: []string{
+				`@counter = global i32 5`,
+				`load i32, i32\* @counter`,
+				`store i32 %[0-9]+, i32\* @counter`,
+			},
+		},
+		{
+			name:  "Global Initialised From Constant Expression",
+			input: `const N = 4; let size: i64 = N * 8; main() -> { return 0; }`,
+			expectedIRSubstrings: []string{
+				`@size = global i64 32`,
+			},
+		},
+		{
+			name:  "Global With Lazy Initializer",
+			input: `seed() -> 42; let value = seed(); main() -> { return value; }`,
+			expectedIRSubstrings: []string{
+				`@value.ready = internal global i1 false`,
+				`@value = global i32 zeroinitializer`,
+				`define internal void @value.init\(\)`,
+				`store i1 true, i1\* @value.ready`,
+				`call i32 @seed\(\)`,
+				`call void @value.init\(\)\n\s+%[0-9]+ = load i32, i32\* @value`,
+			},
+		},
+		{
+			name:  "Local Constant",
+			input: `main() -> { const n = 3 * 3; return n; }`,
+			expectedIRSubstrings: []string{
+				`define i32 @main\(\) \{\nentry:\n\s+ret i32 9`,
+			},
+		},
+		{
+			name:          "Assigning To Constant Fails",
+			input:         `const LIMIT = 3; main() -> { LIMIT = 4; return 0; }`,
+			expectedError: "cannot assign to constant 'LIMIT'",
+		},
+		{
+			name:          "Assigning To Local Constant Fails",
+			input:         `main() -> { const n = 1; n = 2; return n; }`,
+			expectedError: "cannot assign to constant 'n'",
+		},
+		{
+			name:          "Constant Needs Compile-Time Initializer",
+			input:         `seed() -> 42; const N = seed(); main() -> { return N; }`,
+			expectedError: "is not a compile-time constant",
+		},
+		{
+			name:          "Constant Division By Zero",
+			input:         `const N = 1 / 0; main() -> { return N; }`,
+			expectedError: "division by zero",
+		},
+		{
+			name:  "Forward Reference Is Ordered",
+			input: `let c = d * 2; let d = seed(); const K = L + 1; const L = 4; seed() -> 7; main() -> { return c + K; }`,
+			expectedIRSubstrings: []string{
+				`define internal void @d.init\(\)`,
+				`define internal void @c.init\(\)(.|\n)*call void @d.init\(\)`,
+				`add i32 %[0-9]+, 5`,
+			},
+		},
+		{
+			name:          "Initialization Cycle",
+			input:         `let a = b + 1; let b = a + 1; main() -> { return a; }`,
+			expectedError: "let 'a' at line 1: initialization cycle: 'a' refers to 'b', 'b' refers to 'a' at line 1",
+		},
+		{
+			name:          "Self-Referencing Initializer",
+			input:         `seed() -> 1; let n = seed() + n; main() -> { return n; }`,
+			expectedError: "initialization cycle: 'n' refers to 'n'",
+		},
+		{
+			name:  "Lambda Parameter Shadows Global",
+			input: `let twice = (n) -> n * 2; let n = twice(3); main() -> { return n; }`,
+			expectedIRSubstrings: []string{
+				`define internal void @n.init\(\)`,
+			},
+		},
+		{
+			name:          "Duplicate Top-Level Name",
+			input:         `let x = 1; const x = 2; main() -> { return x; }`,
+			expectedError: "already declared",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ir, err := generateIRForProgram(t, tt.input)
+
+			if tt.expectedError != "" {
+				if err == nil {
+					t.Fatalf("Expected error containing %q, got nil.\nIR Generated:\n%s", tt.expectedError, ir)
+				}
+				if !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error containing %q, got: %v", tt.expectedError, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("generateIRForProgram failed: %v", err)
+			}
+
+			for _, subPattern := range tt.expectedIRSubstrings {
+				re := regexp.MustCompile(subPattern)
+				if !re.MatchString(ir) {
+					t.Errorf("Generated IR missing expected pattern for test %q.\nExpected pattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+			for _, subPattern := range tt.unexpectedIR {
+				re := regexp.MustCompile(subPattern)
+				if re.MatchString(ir) {
+					t.Errorf("Generated IR contains unexpected pattern for test %q.\nPattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+		})
+	}
+}
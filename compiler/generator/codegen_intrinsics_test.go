@@ -0,0 +1,77 @@
+package generator
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestCodeGenIntrinsics covers calls to the builtin module: argument counts,
+// unknown names, and local names hiding the module.
+func TestCodeGenIntrinsics(t *testing.T) {
+	tests := []struct {
+		name                 string
+		input                string
+		expectedIRSubstrings []string
+		expectedError        string // Substring of the expected error, empty if none
+	}{
+		{
+			name:  "Intrinsic Needs No Import",
+			input: `main() -> { let x: f64 = 2.0; let r = builtin.sqrt(x); return 0; }`,
+			expectedIRSubstrings: []string{
+				`call double @llvm.sqrt.f64\(double %[0-9]+\)`,
+			},
+		},
+		{
+			name:          "Unknown Intrinsic",
+			input:         `main() -> { builtin.nope(); return 0; }`,
+			expectedError: `unknown intrinsic 'builtin.nope'`,
+		},
+		{
+			name:          "Wrong Argument Count",
+			input:         `main() -> { let x: i64 = 0; builtin.atomicAdd(&x, 1); return 0; }`,
+			expectedError: `builtin.atomicAdd expects 3 argument(s), got 2`,
+		},
+		{
+			name:          "Named Arguments Are Rejected",
+			input:         `main() -> { builtin.exit(status = 1); return 0; }`,
+			expectedError: `named argument 'status = 1' used in a call to a function without a known parameter list`,
+		},
+		{
+			name:  "Local Name Hides The Module",
+			input: `type Box { let v: i64; function i64 sqrt(x: i64) -> { return x * 2; } } main() -> { let builtin = 0 as *Box; let r = builtin.sqrt(3); return 0; }`,
+			expectedIRSubstrings: []string{
+				`call i64 @Box_sqrt\(`,
+			},
+		},
+		{
+			name:          "Old Asm Spelling Is Rejected",
+			input:         `main() -> { let a: []string = asm("builtin_args"); return 0; }`,
+			expectedError: `unsupported or unknown asm code: 'builtin_args'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ir, err := generateIRForProgram(t, tt.input)
+
+			if tt.expectedError != "" {
+				if err == nil {
+					t.Fatalf("Expected error containing %q, got nil", tt.expectedError)
+				}
+				if !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error containing %q, got: %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Code generation failed: %v", err)
+			}
+			for _, pattern := range tt.expectedIRSubstrings {
+				if !regexp.MustCompile(pattern).MatchString(ir) {
+					t.Errorf("Generated IR missing expected pattern %s\nGot IR:\n%s", pattern, ir)
+				}
+			}
+		})
+	}
+}
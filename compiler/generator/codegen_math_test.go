@@ -0,0 +1,130 @@
+package generator
+
+import (
+	"compiler/lexer"
+	"compiler/parser"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestCodeGenMath covers the float, overflow and bit intrinsics behind
+// stdlib/math, constant if conditions and the precision of float literals.
+func TestCodeGenMath(t *testing.T) {
+	tests := []struct {
+		name                 string
+		input                string
+		freestanding         bool
+		expectedIRSubstrings []string
+		unexpectedIR         []string // Patterns that must not appear
+		expectedError        string   // Substring of the expected error, empty if none
+	}{
+		{
+			name:  "Float Intrinsics",
+			input: `main() -> { let x: f64 = 2.0; let f: f32 = 2.0; let a = builtin.sqrt(x); let b = builtin.exp(x); let c = builtin.floor(f); return 0; }`,
+			expectedIRSubstrings: []string{
+				`call double @llvm.sqrt.f64\(double %[0-9]+\)`,
+				`call double @llvm.exp.f64\(double %[0-9]+\)`,
+				`call float @llvm.floor.f32\(float %[0-9]+\)`,
+				`declare double @llvm.sqrt.f64\(double %p0\)`,
+			},
+		},
+		{
+			name:          "Libm Intrinsic When Freestanding",
+			input:         `main() -> { let x: f64 = 2.0; let y = builtin.sin(x); return 0; }`,
+			freestanding:  true,
+			expectedError: "builtin.sin needs libm, which freestanding programs do not link",
+		},
+		{
+			name:                 "Sqrt When Freestanding",
+			input:                `main() -> { let x: f64 = 2.0; let y = builtin.sqrt(x); return 0; }`,
+			freestanding:         true,
+			expectedIRSubstrings: []string{`call double @llvm.sqrt.f64`},
+		},
+		{
+			name: "Constant Condition Drops The Other Branch",
+			input: `function f(x: f64): f64 -> {
+					if (builtin.libm()) { return builtin.cos(x); }
+					return x;
+				}
+				main() -> { let y = f(1.0); return 0; }`,
+			freestanding:         true,
+			expectedIRSubstrings: []string{`define double @f\(double %x\)`},
+			unexpectedIR:         []string{`llvm.cos`, `br i1 true`},
+		},
+		{
+			name:  "Overflow Intrinsics",
+			input: `main() -> { let r: i64 = 0; let a: i64 = 5; let o = builtin.mulOverflow(a, a, &r); return 0; }`,
+			expectedIRSubstrings: []string{
+				`%[0-9]+ = call \{ i64, i1 \} @llvm.smul.with.overflow.i64\(i64 %[0-9]+, i64 %[0-9]+\)`,
+				`extractvalue \{ i64, i1 \} %[0-9]+, 0\n\s+store i64 %[0-9]+, i64\* %0`,
+				`extractvalue \{ i64, i1 \} %[0-9]+, 1`,
+			},
+		},
+		{
+			name:                 "Float Bits",
+			input:                `main() -> { let x: f64 = 1.5; let b = builtin.floatBits(x); let y = builtin.floatFromBits(b); return 0; }`,
+			expectedIRSubstrings: []string{`bitcast double %[0-9]+ to i64`, `bitcast i64 %[0-9]+ to double`},
+		},
+		{
+			name:                 "Float Literal Keeps Its Precision",
+			input:                `main() -> { let x: f64 = 0.1; return 0; }`,
+			expectedIRSubstrings: []string{`store double 0x3FB999999999999A, double\* %0`},
+		},
+		{
+			name:          "Overflow Target Not A Pointer",
+			input:         `main() -> { let a: i64 = 1; let o = builtin.addOverflow(a, a, a); return 0; }`,
+			expectedError: "third argument must point to an integer",
+		},
+		{
+			name:          "Float Bits Of An Integer",
+			input:         `main() -> { let a: i64 = 1; let b = builtin.floatBits(a); return 0; }`,
+			expectedError: "argument must be a float, got i64",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := lexer.NewLexerFromString(tt.input)
+			if err != nil {
+				t.Fatalf("Lexer creation failed: %v", err)
+			}
+			p := parser.NewParser(l)
+			prog := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("Parser errors: %v", p.Errors())
+			}
+
+			cg := NewCodeGenerator()
+			cg.Freestanding = tt.freestanding
+			err = prog.Accept(cg)
+
+			if tt.expectedError != "" {
+				if err == nil {
+					t.Fatalf("Expected error containing %q, got nil", tt.expectedError)
+				}
+				if !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error containing %q, got: %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Code generation failed: %v", err)
+			}
+
+			ir := cg.Module.String()
+			for _, subPattern := range tt.expectedIRSubstrings {
+				re := regexp.MustCompile(subPattern)
+				if !re.MatchString(ir) {
+					t.Errorf("Generated IR missing expected pattern for test %q.\nExpected pattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+			for _, subPattern := range tt.unexpectedIR {
+				re := regexp.MustCompile(subPattern)
+				if re.MatchString(ir) {
+					t.Errorf("Generated IR contains unexpected pattern for test %q.\nPattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+		})
+	}
+}
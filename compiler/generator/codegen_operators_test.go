@@ -0,0 +1,66 @@
+package generator
+
+import (
+	"regexp"
+	"testing"
+)
+
+// TestCodeGenOperators covers %, the comparisons != and >=, the short-circuit
+// operators && and ||, prefix ! and the boolean literals.
+func TestCodeGenOperators(t *testing.T) {
+	tests := []struct {
+		name                 string
+		input                string
+		expectedIRSubstrings []string
+	}{
+		{
+			name:  "Modulo And Not Equal",
+			input: `main() -> { let a = 7; return a % 3 != 0; }`,
+			expectedIRSubstrings: []string{
+				`srem i32 %[0-9]+, 3`,
+				`icmp ne i32 %[0-9]+, 0`,
+			},
+		},
+		{
+			name:  "Greater Than Or Equal",
+			input: `main() -> { let a = 7; return a >= 3; }`,
+			expectedIRSubstrings: []string{
+				`icmp sge i32 %[0-9]+, 3`,
+			},
+		},
+		{
+			name:  "Short Circuit Logical Operators",
+			input: `main() -> { let a = 1; let b = 0; if (a > 0 && b > 0 || !b) { return 1; } return 0; }`,
+			expectedIRSubstrings: []string{
+				`logic_rhs:`,
+				`logic_merge:`,
+				`phi i1 \[ false, %[a-z_0-9]+ \], \[ %[0-9]+, %logic_rhs \]`,
+				`phi i1 \[ true, %[a-z_0-9]+ \], \[ %[0-9]+, %logic_rhs_[0-9]+ \]`,
+				`xor i1 %[0-9]+, true`,
+			},
+		},
+		{
+			name:  "Boolean Literals",
+			input: `main() -> { if (true && !false) { return 1; } return 0; }`,
+			expectedIRSubstrings: []string{
+				`br i1 true, label %logic_rhs, label %logic_merge`,
+				`xor i1 false, true`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ir, err := generateIRForProgram(t, tt.input)
+			if err != nil {
+				t.Fatalf("generateIRForProgram failed: %v", err)
+			}
+			for _, subPattern := range tt.expectedIRSubstrings {
+				re := regexp.MustCompile(subPattern)
+				if !re.MatchString(ir) {
+					t.Errorf("Generated IR missing expected pattern for test %q.\nExpected pattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package generator
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestCodeGenParameters covers typed parameters, default values and named
+// arguments at call sites.
+func TestCodeGenParameters(t *testing.T) {
+	tests := []struct {
+		name                 string
+		input                string
+		expectedIRSubstrings []string
+		expectedError        string // Substring of the expected error, empty if none
+	}{
+		{
+			name:  "Typed Parameters",
+			input: `scale(x: i64, factor: i8): i64 -> x; main() -> { return scale(5, 2); }`,
+			expectedIRSubstrings: []string{
+				`define i64 @scale\(i64 %x, i8 %factor\)`,
+				`call i64 @scale\(i64 5, i8 2\)`,
+			},
+		},
+		{
+			name:  "Default Parameter Filled At Call Site",
+			input: `add(a: int, b: int = 10): int -> a + b; main() -> { return add(1); }`,
+			expectedIRSubstrings: []string{
+				`call i32 @add\(i32 1, i32 10\)`,
+			},
+		},
+		{
+			name:  "Named Arguments Reordered",
+			input: `sub(a: int, b: int): int -> a - b; main() -> { return sub(b = 1, a = 7); }`,
+			expectedIRSubstrings: []string{
+				`call i32 @sub\(i32 7, i32 1\)`,
+			},
+		},
+		{
+			name:  "Named Argument Skips Default",
+			input: `f(a, b = 2, c = 3) -> a; main() -> { return f(1, c = 9); }`,
+			expectedIRSubstrings: []string{
+				`call i32 @f\(i32 1, i32 2, i32 9\)`,
+			},
+		},
+		{
+			name:  "Lambda Defaults Through Variable",
+			input: `main() -> { let g = (x: int, y = 4) -> x * y; return g(3); }`,
+			expectedIRSubstrings: []string{
+				`define internal i32 @lambda_[0-9]+\(i32 %x, i32 %y\)`,
+				`call i32 %[0-9]+\(i32 3, i32 4\)`,
+			},
+		},
+		{
+			name:  "Function Typed Parameter",
+			input: `apply(f: (int) -> int, v: int): int -> f(v); main() -> { return apply((x) -> x + 1, 2); }`,
+			expectedIRSubstrings: []string{
+				`define i32 @apply\(i32 \(i32\)\* %f, i32 %v\)`,
+			},
+		},
+		{
+			name:          "Missing Argument",
+			input:         `add(a: int, b: int): int -> a + b; main() -> { return add(1); }`,
+			expectedError: "missing argument for parameter 'b' in call to 'add' (declared at line 1)",
+		},
+		{
+			name:          "Too Many Arguments",
+			input:         `one(a) -> a; main() -> { return one(1, 2); }`,
+			expectedError: "too many arguments in call to 'one': expected at most 1, got 2",
+		},
+		{
+			name:          "Unknown Named Argument",
+			input:         `one(a) -> a; main() -> { return one(z = 1); }`,
+			expectedError: "unknown parameter 'z' in call to 'one'",
+		},
+		{
+			name:  "Array Literal Converted For Slice Parameter",
+			input: `sum(xs: []i64): i64 -> xs.length; main() -> { return sum([1, 2, 3]); }`,
+			expectedIRSubstrings: []string{
+				`define i64 @sum\(%Slice.i64\* %xs\)`,
+				`alloca i64, i32 %[0-9]+`,
+				`sext i32 %[0-9]+ to i64`,
+				`call i64 @sum\(%Slice.i64\* %[0-9]+\)`,
+			},
+		},
+		{
+			name:  "Array Literal Converted For Slice Local",
+			input: `main() -> { let xs: []f64 = [1, 2]; return 0; }`,
+			expectedIRSubstrings: []string{
+				`alloca double, i32 %[0-9]+`,
+				`sitofp i32 %[0-9]+ to double`,
+			},
+		},
+		{
+			name:          "Slice Of Strings Does Not Convert",
+			input:         `count(xs: []string): i64 -> xs.length; main() -> { return count([1]); }`,
+			expectedError: "cannot convert value of type %Array* to %Slice.string*",
+		},
+		{
+			name:          "Pointer To Other Type Needs A Cast",
+			input:         `main() -> { let x: i32 = 1; let p: *i64 = &x; return 0; }`,
+			expectedError: "let 'p': cannot convert value of type i32* to i64* without a cast",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ir, err := generateIRForProgram(t, tt.input)
+
+			if tt.expectedError != "" {
+				if err == nil {
+					t.Fatalf("Expected error containing %q, got nil.\nIR Generated:\n%s", tt.expectedError, ir)
+				}
+				if !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error containing %q, got: %v", tt.expectedError, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("generateIRForProgram failed: %v", err)
+			}
+
+			for _, subPattern := range tt.expectedIRSubstrings {
+				re := regexp.MustCompile(subPattern)
+				if !re.MatchString(ir) {
+					t.Errorf("Generated IR missing expected pattern for test %q.\nExpected pattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+		})
+	}
+}
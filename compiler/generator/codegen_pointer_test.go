@@ -0,0 +1,152 @@
+package generator
+
+import (
+	"compiler/lexer"
+	"compiler/parser"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestCodeGenPointers covers address-of, dereference, casts, pointer
+// arithmetic and the layout of extern, packed and default types.
+func TestCodeGenPointers(t *testing.T) {
+	tests := []struct {
+		name                 string
+		input                string
+		expectedIRSubstrings []string
+		expectedError        string // Substring of the expected error, empty if none
+	}{
+		{
+			name:  "Address Of And Store Through Pointer",
+			input: `bump(p: *i64) -> { *p = *p + 1; } main() -> { let x: i64 = 41; bump(&x); return 0; }`,
+			expectedIRSubstrings: []string{
+				`define i32 @bump\(i64\* %p\)`,
+				`load i64, i64\* %[0-9a-z_.]+\n(.*\n)*\s+store i64 %[0-9]+, i64\* %[0-9a-z_.]+`,
+				`call i32 @bump\(i64\* %[0-9a-z_.]+\)`,
+			},
+		},
+		{
+			name:  "Pointer Arithmetic Is Scaled",
+			input: `main() -> { let x: i64 = 0; let p: *u16 = &x as *u16; let q = p + 3; return 0; }`,
+			expectedIRSubstrings: []string{
+				`bitcast i64\* %[0-9a-z_.]+ to i16\*`,
+				`getelementptr i16, i16\* %[0-9]+, i64 3`,
+			},
+		},
+		{
+			name:  "Pointer Difference Counts Elements",
+			input: `main() -> { let x: i64 = 0; let p: *i32 = &x as *i32; let q = p + 2; let n = q - p; return 0; }`,
+			expectedIRSubstrings: []string{
+				`sdiv i64 %[0-9]+, ptrtoint \(i32\* getelementptr \(i32, i32\* null, i32 1\) to i64\)`,
+			},
+		},
+		{
+			name:  "Unsigned Casts Zero Extend",
+			input: `main() -> { let b: u8 = 200; let w = b as u64; let s = b as i64; return 0; }`,
+			expectedIRSubstrings: []string{
+				`zext i8 %[0-9]+ to i64`,
+				`sext i8 %[0-9]+ to i64`,
+			},
+		},
+		{
+			name:  "Integer To Pointer",
+			input: `main() -> { let a: i32 = 4096; let p = a as *u8; return 0; }`,
+			expectedIRSubstrings: []string{
+				`sext i32 %[0-9]+ to i64\n\s+%[0-9]+ = inttoptr i64 %[0-9]+ to i8\*`,
+			},
+		},
+		{
+			name:  "Constant Cast",
+			input: `const MASK = 255 as u8; main() -> { let w = MASK as u64; return 0; }`,
+			expectedIRSubstrings: []string{
+				`store i64 255, i64\* %[0-9]+`,
+			},
+		},
+		{
+			name: "Struct Layouts",
+			input: `extern type header { let tag: u8; let len: u16; let id: u64; }
+				packed type wire { let tag: u8; let len: u16; let id: u64; }
+				type loose { let tag: u8; let len: u16; let id: u64; }
+				main() -> { return 0; }`,
+			expectedIRSubstrings: []string{
+				`%header = type \{ i8, i16, i64 \}`,
+				`%wire = type <\{ i8, i16, i64 \}>`,
+				`%loose = type \{ i64, i16, i8 \}`,
+			},
+		},
+		{
+			name: "Field Access Through Cast Pointer",
+			input: `extern type dirent { let ino: u64; let off: i64; let reclen: u16; let kind: u8; let name: u8; }
+				main() -> { let buf: i64 = 0; let d: *dirent = buf as *dirent; let n = d.reclen as u64; let s: *u8 = &d.name; return 0; }`,
+			expectedIRSubstrings: []string{
+				`getelementptr %dirent, %dirent\* %[0-9]+, i32 0, i32 2`,
+				`zext i16 %[0-9a-z_.]+ to i64`,
+				`getelementptr %dirent, %dirent\* %[0-9]+, i32 0, i32 4`,
+			},
+		},
+		{
+			name: "Field Of A Call Result",
+			input: `extern type box { let v: i64; }
+				make(n: i64): *box -> { let b: *box = n as *box; return b; }
+				main() -> { let k: i64 = 8; return make(k).v; }`,
+			expectedIRSubstrings: []string{
+				`%1 = load i64, i64\* %0\n\s+%2 = call %box\* @make\(i64 %1\)`,
+				`getelementptr %box, %box\* %2, i32 0, i32 0`,
+			},
+		},
+		{
+			name:          "Dereference Of Non Pointer",
+			input:         `main() -> { let x: i64 = 1; return *x; }`,
+			expectedError: `cannot dereference 'x' of non-pointer type i64`,
+		},
+		{
+			name:          "Address Of Constant",
+			input:         `const K = 1; main() -> { let p = &K; return 0; }`,
+			expectedError: `cannot take the address of constant 'K'`,
+		},
+		{
+			name:          "Subtracting Unrelated Pointers",
+			input:         `main() -> { let x: i64 = 0; let a: *i64 = &x; let b: *u8 = &x as *u8; let n = a - b; return 0; }`,
+			expectedError: `cannot subtract i8* from i64*`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := lexer.NewLexerFromString(tt.input)
+			if err != nil {
+				t.Fatalf("Lexer creation failed: %v", err)
+			}
+			p := parser.NewParser(l)
+			prog := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("Parser errors: %v", p.Errors())
+			}
+
+			cg := NewCodeGenerator()
+			err = prog.Accept(cg)
+
+			if tt.expectedError != "" {
+				if err == nil {
+					t.Fatalf("Expected error containing %q, got nil", tt.expectedError)
+				}
+				if !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error containing %q, got: %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Code generation failed: %v", err)
+			}
+
+			ir := cg.Module.String()
+			for _, subPattern := range tt.expectedIRSubstrings {
+				re := regexp.MustCompile(subPattern)
+				if !re.MatchString(ir) {
+					t.Errorf("Generated IR missing expected pattern for test %q.\nExpected pattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+		})
+	}
+}
@@ -191,9 +191,7 @@ func TestCodeGenStringLiteralUnit(t *testing.T) {
 			expectedPtrLoad:   `getelementptr( inbounds)? \[4 x i8\].*@[a-zA-Z_0-9]+, i32 0, i32 0`,
 		},
 	}
-	// Reset global counter for predictable names (if feasible)
-	stringCounter = 0
-
+	// String globals are numbered per generator, so names are predictable.
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			node := parseExpr(t, tt.input)
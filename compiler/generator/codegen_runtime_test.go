@@ -0,0 +1,161 @@
+package generator
+
+import (
+	"compiler/compiler/target"
+	"compiler/lexer"
+	"compiler/parser"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestCodeGenRuntimeEntry covers the program entry point: the freestanding
+// _start, and how the command line reaches builtin.args().
+func TestCodeGenRuntimeEntry(t *testing.T) {
+	tests := []struct {
+		name                 string
+		input                string
+		target               *target.Target
+		freestanding         bool
+		expectedIRSubstrings []string
+		unexpectedIR         []string // Patterns that must not appear
+		expectedError        string   // Substring of the expected error, empty if none
+	}{
+		{
+			name:         "Freestanding Start On amd64",
+			input:        `main() -> { return 3; }`,
+			target:       target.AMD64,
+			freestanding: true,
+			expectedIRSubstrings: []string{
+				`define void @_start\(\) naked noreturn \{`,
+				`call void asm sideeffect "xor %ebp, %ebp\\0Amov %rsp, %rdi\\0Aand \$\$-16, %rsp\\0Acall __ylang_start\\0Ahlt", ""\(\)`,
+				`define void @__ylang_start\(i64\* %sp\)`,
+				`%[0-9]+ = call i32 @main\(\)\n\s+%[0-9]+ = sext i32 %[0-9]+ to i64`,
+				`asm sideeffect "syscall", "=\{rax\},\{rax\},\{rdi\},~\{rcx\},~\{r11\},~\{memory\}"\(i64 231, i64 %[0-9]+\)\n\s+unreachable`,
+			},
+			unexpectedIR: []string{`llvm.global_ctors`},
+		},
+		{
+			name:         "Freestanding Start On arm64",
+			input:        `main() -> { return 0; }`,
+			target:       target.ARM64,
+			freestanding: true,
+			expectedIRSubstrings: []string{
+				`"mov x29, #0\\0Amov x30, #0\\0Amov x0, sp\\0Abl __ylang_start\\0Abrk #0"`,
+				`asm sideeffect "svc #0", "=\{x0\},\{x8\},\{x0\},~\{memory\}"\(i64 94, i64 %[0-9]+\)`,
+			},
+		},
+		{
+			name:         "Freestanding Start On riscv64",
+			input:        `main() -> { return 0; }`,
+			target:       target.RISCV64,
+			freestanding: true,
+			expectedIRSubstrings: []string{
+				`lla gp, __global_pointer\$\$`,
+				`asm sideeffect "ecall", "=\{x10\},\{x17\},\{x10\},~\{memory\}"\(i64 94, i64 %[0-9]+\)`,
+			},
+		},
+		{
+			name:         "Freestanding Records Arguments",
+			input:        `main() -> { let a: []string = builtin.args(); return a.length; }`,
+			target:       target.AMD64,
+			freestanding: true,
+			expectedIRSubstrings: []string{
+				`@__ylang_args = internal global %Slice.string zeroinitializer`,
+				`@__ylang_environ = internal global i8\*\* null`,
+				`%[0-9]+ = load i64, i64\* %sp`,
+				`store i32 %[0-9]+, i32\* getelementptr \(%Slice.string, %Slice.string\* @__ylang_args, i32 0, i32 0\)|getelementptr %Slice.string, %Slice.string\* @__ylang_args, i32 0, i32 0`,
+				`store i8\*\* %[0-9]+, i8\*\*\* @__ylang_environ`,
+			},
+		},
+		{
+			name:   "Hosted Arguments Come From A Constructor",
+			input:  `main() -> { let a: []string = builtin.args(); let e: *i64 = builtin.environ(); return a.length; }`,
+			target: target.AMD64,
+			expectedIRSubstrings: []string{
+				`define internal void @__ylang_init\(i32 %argc, i8\*\* %argv, i8\*\* %envp\)`,
+				`@llvm.global_ctors = appending global \[1 x \{ i32, void \(\)\*, i8\* \}\] \[\{ i32, void \(\)\*, i8\* \} \{ i32 u0xFFFF, void \(\)\* bitcast \(void \(i32, i8\*\*, i8\*\*\)\* @__ylang_init to void \(\)\*\), i8\* null \}\]`,
+				`bitcast i8\*\* %[0-9]+ to i64\*`,
+			},
+			unexpectedIR: []string{`@_start`},
+		},
+		{
+			name:         "Exit Runs Finalizers Before exit_group",
+			input:        `main() -> { builtin.exit(2); return 0; }`,
+			target:       target.AMD64,
+			freestanding: true,
+			expectedIRSubstrings: []string{
+				`define internal void @__ylang_fini\(\) \{\nentry:\n\s+ret void`,
+				`call void @__ylang_fini\(\)\n\s+%[0-9]+ = call i64 asm sideeffect "syscall", "[^"]*"\(i64 231, i64 2\)`,
+			},
+			unexpectedIR: []string{`llvm.global_dtors`},
+		},
+		{
+			name:   "Hosted Finalizers Run As A Destructor",
+			input:  `main() -> { builtin.exit(0); return 0; }`,
+			target: target.AMD64,
+			expectedIRSubstrings: []string{
+				`@llvm.global_dtors = appending global \[1 x \{ i32, void \(\)\*, i8\* \}\] \[\{ i32, void \(\)\*, i8\* \} \{ i32 u0xFFFF, void \(\)\* @__ylang_fini, i8\* null \}\]`,
+			},
+		},
+		{
+			name:         "No Finalizers Without Exit",
+			input:        `main() -> { return 0; }`,
+			target:       target.AMD64,
+			freestanding: true,
+			unexpectedIR: []string{`__ylang_fini`},
+		},
+		{
+			name:          "Freestanding Needs Main",
+			input:         `helper() -> 1;`,
+			target:        target.AMD64,
+			freestanding:  true,
+			expectedError: "no main function",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := lexer.NewLexerFromString(tt.input)
+			if err != nil {
+				t.Fatalf("Lexer creation failed: %v", err)
+			}
+			p := parser.NewParser(l)
+			prog := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("Parser errors: %v", p.Errors())
+			}
+
+			cg := NewCodeGeneratorForTarget(tt.target)
+			cg.Freestanding = tt.freestanding
+			err = prog.Accept(cg)
+
+			if tt.expectedError != "" {
+				if err == nil {
+					t.Fatalf("Expected error containing %q, got nil", tt.expectedError)
+				}
+				if !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error containing %q, got: %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Code generation failed: %v", err)
+			}
+
+			ir := cg.Module.String()
+			for _, subPattern := range tt.expectedIRSubstrings {
+				re := regexp.MustCompile(subPattern)
+				if !re.MatchString(ir) {
+					t.Errorf("Generated IR missing expected pattern for test %q.\nExpected pattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+			for _, subPattern := range tt.unexpectedIR {
+				re := regexp.MustCompile(subPattern)
+				if re.MatchString(ir) {
+					t.Errorf("Generated IR contains unexpected pattern for test %q.\nPattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package generator
+
+import (
+	"compiler/compiler/target"
+	"compiler/lexer"
+	"compiler/parser"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestCodeGenSyscallTable covers resolving syscall numbers by name through the
+// SYS table of the target.
+func TestCodeGenSyscallTable(t *testing.T) {
+	tests := []struct {
+		name                 string
+		input                string
+		expectedIRSubstrings []string
+		expectedError        string // Substring of the expected error, empty if none
+	}{
+		{
+			name:  "Syscall Number By Name",
+			input: `main() -> { let pid = syscall(SYS.getpid); return 0; }`,
+			expectedIRSubstrings: []string{
+				`call i64 asm sideeffect "syscall", "=\{rax\},\{rax\},\{rdi\},\{rsi\},\{rdx\},\{r10\},\{r8\},\{r9\},~\{rcx\},~\{r11\},~\{memory\}"\(i64 39, i64 0, i64 0, i64 0, i64 0, i64 0, i64 0\)`,
+			},
+		},
+		{
+			name:  "Syscall Numbers Fold Into Constants",
+			input: `const OPEN = SYS.openat; const NEXT = SYS.openat + 1; main() -> { let a: i64 = OPEN; let b: i64 = NEXT; return 0; }`,
+			expectedIRSubstrings: []string{
+				`store i64 257, i64\* %`,
+				`store i64 258, i64\* %`,
+			},
+		},
+		{
+			name:  "Local Variable Shadows SYS",
+			input: `main() -> { let SYS = 5; return SYS; }`,
+			expectedIRSubstrings: []string{
+				`store i32 5, i32\* %`,
+			},
+		},
+		{
+			name:          "Unknown Syscall",
+			input:         `main() -> { return syscall(SYS.no_such_call); }`,
+			expectedError: "unknown syscall 'SYS.no_such_call'",
+		},
+		{
+			name:          "Syscall Numbers Are Constants",
+			input:         `main() -> { SYS.read = 1; return 0; }`,
+			expectedError: "cannot assign to constant",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ir, err := generateIRForProgram(t, tt.input)
+
+			if tt.expectedError != "" {
+				if err == nil {
+					t.Fatalf("Expected error containing %q, got nil.\nIR Generated:\n%s", tt.expectedError, ir)
+				}
+				if !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error containing %q, got: %v", tt.expectedError, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("generateIRForProgram failed: %v", err)
+			}
+
+			for _, subPattern := range tt.expectedIRSubstrings {
+				re := regexp.MustCompile(subPattern)
+				if !re.MatchString(ir) {
+					t.Errorf("Generated IR missing expected pattern for test %q.\nExpected pattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+		})
+	}
+}
+
+// TestCodeGenEpollEventSize checks that builtin.epollEventSize() follows the
+// layout of struct epoll_event, which is packed on amd64 only.
+func TestCodeGenEpollEventSize(t *testing.T) {
+	tests := []struct {
+		target   *target.Target
+		expected string
+	}{
+		{target.AMD64, `store i64 12, i64\* %`},
+		{target.ARM64, `store i64 16, i64\* %`},
+		{target.RISCV64, `store i64 16, i64\* %`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.target.Name, func(t *testing.T) {
+			l, err := lexer.NewLexerFromString(`main() -> { let size: i64 = builtin.epollEventSize(); return 0; }`)
+			if err != nil {
+				t.Fatalf("Lexer creation failed: %v", err)
+			}
+			p := parser.NewParser(l)
+			prog := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("Parser errors: %v", p.Errors())
+			}
+			cg := NewCodeGeneratorForTarget(tt.target)
+			if err := prog.Accept(cg); err != nil {
+				t.Fatalf("Code generation failed: %v", err)
+			}
+			ir := cg.Module.String()
+			if !regexp.MustCompile(tt.expected).MatchString(ir) {
+				t.Errorf("Generated IR missing expected pattern %s\nGot IR:\n%s", tt.expected, ir)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package generator
+
+import (
+	"compiler/compiler/target"
+	"compiler/lexer"
+	"compiler/parser"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden IR files in testdata/golden")
+
+// syscallGoldenProgram exercises syscalls by name and by number, the
+// per-target open flags, plus the builtins whose bodies issue a write syscall.
+const syscallGoldenProgram = `
+main() -> {
+	let fd = syscall(SYS.openat, -100, ".", builtin.oDirectory(), 0, 0, 0);
+	syscall(SYS.write, 1, "ok\n", 3);
+	asm("builtin_print_int", 7);
+	syscall(SYS.close, fd);
+	return 0;
+}`
+
+// TestCodeGenTargetGolden compiles the same program for every target and
+// compares the module with testdata/golden/syscall_<arch>.ll. Run with
+// -update to regenerate the files after an intended change.
+func TestCodeGenTargetGolden(t *testing.T) {
+	for _, tgt := range []*target.Target{target.AMD64, target.ARM64, target.RISCV64} {
+		t.Run(tgt.Name, func(t *testing.T) {
+			l, err := lexer.NewLexerFromString(syscallGoldenProgram)
+			if err != nil {
+				t.Fatalf("Lexer creation failed: %v", err)
+			}
+			p := parser.NewParser(l)
+			prog := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("Parser errors: %v", p.Errors())
+			}
+
+			cg := NewCodeGeneratorForTarget(tgt)
+			if err := prog.Accept(cg); err != nil {
+				t.Fatalf("Code generation failed: %v", err)
+			}
+			got := cg.Module.String()
+
+			golden := filepath.Join("testdata", "golden", "syscall_"+tgt.Name+".ll")
+			if *updateGolden {
+				if err := os.WriteFile(golden, []byte(got), 0644); err != nil {
+					t.Fatalf("Failed to update %s: %v", golden, err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("Failed to read %s (run with -update to create it): %v", golden, err)
+			}
+			if got != string(want) {
+				t.Errorf("IR for %s differs from %s.\nGot:\n%s", tgt.Name, golden, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package generator
+
+import (
+	"compiler/lexer"
+	"compiler/parser"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestCodeGenTestBlocks covers what becomes of test blocks and assertions
+// outside the test harness, which needs stdlib/testing.
+func TestCodeGenTestBlocks(t *testing.T) {
+	tests := []struct {
+		name                   string
+		input                  string
+		expectedIRSubstrings   []string
+		unexpectedIRSubstrings []string
+		expectedError          string // Substring of the expected error, empty if none
+	}{
+		{
+			name:                   "Test Blocks Are Left Out",
+			input:                  `test "one" { let x = 1; } main() -> { return 0; }`,
+			expectedIRSubstrings:   []string{`define i32 @main\(\)`},
+			unexpectedIRSubstrings: []string{`__test_`},
+		},
+		{
+			name:                 "Own Assert Function",
+			input:                `assert(ok: bool) -> { return 0; } main() -> { assert(true); return 0; }`,
+			expectedIRSubstrings: []string{`call i32 @assert\(i1 true\)`},
+		},
+		{
+			name:          "Assert Needs stdlib/testing",
+			input:         `main() -> { let x = 1; assert(x == 1); return 0; }`,
+			expectedError: `assert needs stdlib/testing; import it`,
+		},
+		{
+			name:          "AssertEq Argument Count",
+			input:         `main() -> { assertEq(1); return 0; }`,
+			expectedError: `assertEq expects 2 argument(s), got 1`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := lexer.NewLexerFromString(tt.input)
+			if err != nil {
+				t.Fatalf("Lexer creation failed: %v", err)
+			}
+			p := parser.NewParser(l)
+			prog := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("Parser errors: %v", p.Errors())
+			}
+
+			cg := NewCodeGenerator()
+			err = prog.Accept(cg)
+
+			if tt.expectedError != "" {
+				if err == nil {
+					t.Fatalf("Expected error containing %q, got nil", tt.expectedError)
+				}
+				if !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error containing %q, got: %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Code generation failed: %v", err)
+			}
+
+			ir := cg.Module.String()
+			for _, subPattern := range tt.expectedIRSubstrings {
+				re := regexp.MustCompile(subPattern)
+				if !re.MatchString(ir) {
+					t.Errorf("Generated IR missing expected pattern for test %q.\nExpected pattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+			for _, subPattern := range tt.unexpectedIRSubstrings {
+				re := regexp.MustCompile(subPattern)
+				if re.MatchString(ir) {
+					t.Errorf("Generated IR has unexpected pattern for test %q.\nPattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+		})
+	}
+}
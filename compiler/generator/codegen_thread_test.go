@@ -0,0 +1,194 @@
+package generator
+
+import (
+	"compiler/compiler/target"
+	"compiler/lexer"
+	"compiler/parser"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestCodeGenThreads covers the atomic intrinsics, thread_local globals and
+// the thread start and TLS intrinsics used by stdlib/thread.
+func TestCodeGenThreads(t *testing.T) {
+	tests := []struct {
+		name                 string
+		input                string
+		target               *target.Target
+		freestanding         bool
+		expectedIRSubstrings []string
+		unexpectedIR         []string // Patterns that must not appear
+		expectedError        string   // Substring of the expected error, empty if none
+	}{
+		{
+			name: "Atomic Operations With Constant Orderings",
+			input: `main() -> {
+				let x: i64 = 0;
+				let old: i64 = builtin.atomicAdd(&x, 2, 4);
+				let v: i64 = builtin.atomicLoad(&x, 1);
+				builtin.atomicStore(&x, 7, 2);
+				let ok: bool = builtin.atomicCas(&x, 7, 9, 3);
+				builtin.fence(4);
+				return 0;
+			}`,
+			expectedIRSubstrings: []string{
+				`atomicrmw add i64\* %[0-9]+, i64 2 seq_cst`,
+				`load atomic i64, i64\* %[0-9]+ acquire, align 8`,
+				`store atomic i64 7, i64\* %[0-9]+ release, align 8`,
+				`cmpxchg i64\* %[0-9]+, i64 7, i64 9 acq_rel acquire`,
+				`extractvalue \{ i64, i1 \} %[0-9]+, 1`,
+				`fence seq_cst`,
+			},
+			unexpectedIR: []string{`switch`},
+		},
+		{
+			name: "Atomic On i32 Converts Operands",
+			input: `main() -> {
+				let w: i32 = 0;
+				let old: i32 = builtin.atomicSwap(&w, 5, 0);
+				return old;
+			}`,
+			expectedIRSubstrings: []string{`atomicrmw xchg i32\* %[0-9]+, i32 5 monotonic`},
+		},
+		{
+			name: "Run Time Ordering Is Dispatched",
+			input: `function get(p: *i64, order: i64): i64 -> { return builtin.atomicLoad(p, order); }
+				main() -> { return 0; }`,
+			expectedIRSubstrings: []string{
+				`switch i64 %[0-9a-z]+, label %atomic.seq_cst \[\s*i64 0, label %atomic.monotonic\s*i64 1, label %atomic.acquire\s*\]`,
+				`load atomic i64, i64\* %[0-9a-z]+ seq_cst, align 8`,
+				`phi i64 \[ %[0-9]+, %atomic.monotonic \], \[ %[0-9]+, %atomic.acquire \], \[ %[0-9]+, %atomic.seq_cst \]`,
+			},
+		},
+		{
+			name:          "Load Rejects Release Ordering",
+			input:         `main() -> { let x: i64 = 0; let v: i64 = builtin.atomicLoad(&x, 2); return 0; }`,
+			expectedError: "memory ordering RELEASE is not valid",
+		},
+		{
+			name:          "Unknown Ordering",
+			input:         `main() -> { let x: i64 = 0; builtin.atomicAdd(&x, 1, 9); return 0; }`,
+			expectedError: "unknown memory ordering 9",
+		},
+		{
+			name:          "Atomic Needs A Pointer",
+			input:         `main() -> { builtin.atomicAdd(1, 1, 4); return 0; }`,
+			expectedError: "first argument must be a pointer",
+		},
+		{
+			name: "Thread Local Globals",
+			input: `thread_local let count: i64 = 5;
+				thread_local let big: i64 = count + 1;
+				main() -> { count = count + 1; return big; }`,
+			expectedIRSubstrings: []string{
+				`@count = thread_local\(localexec\) global i64 5`,
+				`@big.ready = internal thread_local\(localexec\) global i1 false`,
+				`@big = thread_local\(localexec\) global i64 zeroinitializer`,
+			},
+			unexpectedIR: []string{`__ylang_tls`},
+		},
+		{
+			name: "Freestanding Main Thread Gets A TLS Area",
+			input: `thread_local let count: i64 = 5;
+				main() -> { return count; }`,
+			target:       target.AMD64,
+			freestanding: true,
+			expectedIRSubstrings: []string{
+				`@__ehdr_start = external hidden global i8`,
+				`define internal i8\* @__ylang_tls_init\(i8\* %area\)`,
+				`call i8\* @__ylang_tls_init\(i8\* %[0-9]+\)\n\s+%[0-9]+ = ptrtoint i8\* %[0-9]+ to i64\n\s+%[0-9]+ = call i64 asm sideeffect "syscall", "=\{rax\},\{rax\},\{rdi\},\{rsi\},~\{rcx\},~\{r11\},~\{memory\}"\(i64 158, i64 4098, i64 %[0-9]+\)`,
+			},
+		},
+		{
+			name: "Freestanding arm64 Sets tpidr_el0",
+			input: `thread_local let count: i64 = 5;
+				main() -> { return count; }`,
+			target:               target.ARM64,
+			freestanding:         true,
+			expectedIRSubstrings: []string{`call void asm sideeffect "msr tpidr_el0, \$0", "r,~\{memory\}"\(i8\* %[0-9]+\)`},
+		},
+		{
+			name:         "No TLS Area Without Thread Locals",
+			input:        `let count: i64 = 5; main() -> { return count; }`,
+			target:       target.AMD64,
+			freestanding: true,
+			unexpectedIR: []string{`__ylang_tls`, `thread_local`},
+		},
+		{
+			name: "Thread Start On amd64",
+			input: `function run(p: *u8): i64 -> { return 0; }
+				main() -> {
+					let tid: i32 = 0;
+					let size: i64 = builtin.tlsSize();
+					let tid2: i64 = builtin.threadStart(1 as i64, 2 as i64, &tid, &tid, 3 as i64, run, 4 as i64);
+					return 0;
+				}`,
+			target: target.AMD64,
+			expectedIRSubstrings: []string{
+				`call i64 @__ylang_tls_size\(\)`,
+				`"=\{rax\},\{rax\},\{rdi\},\{rsi\},\{rdx\},\{r10\},\{r8\},\{r9\},\{r12\},~\{rcx\},~\{r11\},~\{memory\}"\(i64 56, i64 1, i64 2, i64 %[0-9]+, i64 %[0-9]+, i64 3, i64 %[0-9]+, i64 4\)`,
+			},
+		},
+		{
+			name: "Thread Start On arm64 Passes TLS Before Child Tid",
+			input: `function run(p: *u8): i64 -> { return 0; }
+				main() -> {
+					let tid: i32 = 0;
+					let r: i64 = builtin.threadStart(1 as i64, 2 as i64, &tid, 0 as i64, 3 as i64, run, 4 as i64);
+					return 0;
+				}`,
+			target:               target.ARM64,
+			expectedIRSubstrings: []string{`"=\{x0\},\{x8\},\{x0\},\{x1\},\{x2\},\{x3\},\{x4\},\{x9\},\{x10\},~\{memory\}"\(i64 220, i64 1, i64 2, i64 %[0-9]+, i64 3, i64 0, i64 %[0-9]+, i64 4\)`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := lexer.NewLexerFromString(tt.input)
+			if err != nil {
+				t.Fatalf("Lexer creation failed: %v", err)
+			}
+			p := parser.NewParser(l)
+			prog := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("Parser errors: %v", p.Errors())
+			}
+
+			tgt := tt.target
+			if tgt == nil {
+				tgt = target.Default
+			}
+			cg := NewCodeGeneratorForTarget(tgt)
+			cg.Freestanding = tt.freestanding
+			err = prog.Accept(cg)
+
+			if tt.expectedError != "" {
+				if err == nil {
+					t.Fatalf("Expected error containing %q, got nil", tt.expectedError)
+				}
+				if !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error containing %q, got: %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Code generation failed: %v", err)
+			}
+
+			ir := cg.Module.String()
+			for _, subPattern := range tt.expectedIRSubstrings {
+				re := regexp.MustCompile(subPattern)
+				if !re.MatchString(ir) {
+					t.Errorf("Generated IR missing expected pattern for test %q.\nExpected pattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+			for _, subPattern := range tt.unexpectedIR {
+				re := regexp.MustCompile(subPattern)
+				if re.MatchString(ir) {
+					t.Errorf("Generated IR contains unexpected pattern for test %q.\nPattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package generator
+
+import (
+	"compiler/ast"
+	"compiler/lexer"
+	"compiler/parser"
+	"testing"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+// TestCodeGenRecordedTypes covers the types recorded for let and expression
+// statements when Types is set, spelled as the program would write them.
+func TestCodeGenRecordedTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string // TypeName of each statement of main, in order
+	}{
+		{
+			name:     "Primitives",
+			input:    `main() -> { let a = 1; let b: i64 = 2; let c = 1.5; let d = a < 2; let e = "hi"; const K = 3; return 0; }`,
+			expected: []string{"i32", "i64", "f32", "bool", "string", "i32"},
+		},
+		{
+			name:     "Expressions",
+			input:    `function f(x: i64): i64 -> { return x; } function g() -> { } main() -> { f(2) + 1; g(); f; return 0; }`,
+			expected: []string{"i64", "void", "(i64)->i64"},
+		},
+		{
+			name:     "Pointers And Slices",
+			input:    `type P { let x: i64; } main() -> { let p = 0 as *P; let q = 0 as *i64; let s: []string = 0 as []string; let a = [1, 2]; return 0; }`,
+			expected: []string{"*P", "*i64", "[]string", "[]i32"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := lexer.NewLexerFromString(tt.input)
+			if err != nil {
+				t.Fatalf("lexer error: %v", err)
+			}
+			p := parser.NewParser(l)
+			program := p.ParseProgram()
+			if errs := p.Errors(); len(errs) != 0 {
+				t.Fatalf("parser errors: %v", errs)
+			}
+			cg := NewCodeGenerator()
+			cg.Types = make(map[ast.Statement]types.Type)
+			if err := program.Accept(cg); err != nil {
+				t.Fatalf("codegen error: %v", err)
+			}
+
+			body := program.MainFunction.Body.(*ast.BlockStatement)
+			var got []string
+			for _, stmt := range body.Statements {
+				if typ, ok := cg.Types[stmt]; ok {
+					got = append(got, TypeName(typ))
+				}
+			}
+			if len(got) != len(tt.expected) {
+				t.Fatalf("recorded %v, want %v", got, tt.expected)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Errorf("statement %d: got %s, want %s", i, got[i], tt.expected[i])
+				}
+			}
+		})
+	}
+}
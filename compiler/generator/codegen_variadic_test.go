@@ -0,0 +1,131 @@
+package generator
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestCodeGenVariadicAndAny covers variadic parameters, boxing into any and
+// floating point arithmetic.
+func TestCodeGenVariadicAndAny(t *testing.T) {
+	tests := []struct {
+		name                 string
+		input                string
+		expectedIRSubstrings []string
+		expectedError        string // Substring of the expected error, empty if none
+	}{
+		{
+			name:  "Variadic Pack Built At Call Site",
+			input: `count(xs: ...int): int -> xs.length; main() -> { return count(1, 2, 3); }`,
+			expectedIRSubstrings: []string{
+				`define i32 @count\(%Array\* %xs\)`,
+				`alloca \[3 x i32\]`,
+				`store i32 3, i32\* %`,
+				`call i32 @count\(%Array\* %varargs\)`,
+			},
+		},
+		{
+			name:  "Empty Variadic Pack",
+			input: `count(xs: ...int): int -> xs.length; main() -> { return count(); }`,
+			expectedIRSubstrings: []string{
+				`store i32 0, i32\* %`,
+				`call i32 @count\(%Array\* %varargs\)`,
+			},
+		},
+		{
+			name:  "Any Pack Boxes Each Kind",
+			input: `f(fmtStr: string, args: ...any) -> args.length; main() -> { return f("x", 42, "s", 1.5, true); }`,
+			expectedIRSubstrings: []string{
+				`%Any = type \{ i32, i64 \}`,
+				`%Slice.any = type \{ i32, %Any\* \}`,
+				`define i32 @f\(i8\* %fmtStr, %Slice.any\* %args\)`,
+				`%Any \{ i32 1, i64 42 \}`,
+				`insertvalue %Any \{ i32 3, i64 0 \}`,
+				`%Any \{ i32 2, i64 u0x3FF8000000000000 \}`,
+				`%Any \{ i32 4, i64 1 \}`,
+			},
+		},
+		{
+			name:  "Variadic Pack Forwarded Unchanged",
+			input: `inner(xs: ...any) -> xs.length; outer(xs: ...any) -> inner(xs); main() -> { return outer(1); }`,
+			expectedIRSubstrings: []string{
+				`call i32 @inner\(%Slice.any\* %[0-9]+\)`,
+			},
+		},
+		{
+			name:  "Any Views",
+			input: `kind(v: any): i64 -> v.int + v.kind; main() -> { return kind(7); }`,
+			expectedIRSubstrings: []string{
+				`getelementptr %Any, %Any\* %v.addr, i32 0, i32 1`,
+				`getelementptr %Any, %Any\* %v.addr, i32 0, i32 0`,
+			},
+		},
+		{
+			name:          "Variadic Parameter Cannot Be Named",
+			input:         `count(xs: ...int): int -> xs.length; main() -> { return count(xs = 1); }`,
+			expectedError: "cannot be passed by name",
+		},
+		{
+			name:  "Float Arithmetic And Comparison",
+			input: `half(x: f64): f64 -> x / 2; main() -> { if (half(3.0) > 1.0) { return 1; } return 0; }`,
+			expectedIRSubstrings: []string{
+				`fdiv double %[0-9]+, 2\.0`,
+				`fcmp ogt double`,
+			},
+		},
+		{
+			name:  "Assignment Converts To Slot Type",
+			input: `main() -> { let c: i8 = 0; c = 65; let w: i64 = 0; w = c; return 0; }`,
+			expectedIRSubstrings: []string{
+				`store i8 65, i8\* %`,
+				`sext i8 %[0-9]+ to i64`,
+			},
+		},
+		{
+			name:  "Builtin Print Int Has A Body",
+			input: `main() -> { asm("builtin_print_int", 5); return 0; }`,
+			expectedIRSubstrings: []string{
+				`define void @builtin_print_int\(i32 %val\)`,
+				`urem i64 %[0-9]+, 10`,
+				`call i64 asm sideeffect "syscall"`,
+			},
+		},
+		{
+			name:  "Array ForEach Boxes Elements For Any Callback",
+			input: `show(v: any) -> v.kind; main() -> { let xs = [1, 2]; xs.forEach(show); return 0; }`,
+			expectedIRSubstrings: []string{
+				`fe_loop_body:`,
+				`insertvalue %Any \{ i32 1, i64 0 \}`,
+				`call i32 @show\(%Any %[0-9]+\)`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ir, err := generateIRForProgram(t, tt.input)
+
+			if tt.expectedError != "" {
+				if err == nil {
+					t.Fatalf("Expected error containing %q, got nil.\nIR Generated:\n%s", tt.expectedError, ir)
+				}
+				if !strings.Contains(err.Error(), tt.expectedError) {
+					t.Errorf("Expected error containing %q, got: %v", tt.expectedError, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("generateIRForProgram failed: %v", err)
+			}
+
+			for _, subPattern := range tt.expectedIRSubstrings {
+				re := regexp.MustCompile(subPattern)
+				if !re.MatchString(ir) {
+					t.Errorf("Generated IR missing expected pattern for test %q.\nExpected pattern: %s\nGot IR:\n%s", tt.name, subPattern, ir)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,440 @@
+package generator
+
+import (
+	"compiler/ast"
+	"fmt"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// Modules the functions derived for data declarations call into.
+const (
+	jsonModule = "stdlib/json"
+	memModule  = "stdlib/mem"
+)
+
+// dataType is a data declaration whose struct has been defined. The
+// functions derived from it are emitted the first time they are used:
+//
+//	T.new()          a zeroed T from the heap of stdlib/mem
+//	T.fromJson(v)    a T read from a *json.JsonValue object, or from JSON text
+//	t.toJson()       t as a *json.JsonValue object
+//
+// JSON members are named after the fields. fromJson returns null, with the
+// reason in json.lastError(), when a member has the wrong kind; missing and
+// null members leave their field zero.
+type dataType struct {
+	decl *ast.DataStructure
+	st   *types.StructType
+}
+
+// defineDataType lays out the struct of a data declaration like that of a
+// type declaration. Fields without a type annotation are any.
+func (cg *CodeGenerator) defineDataType(ds *ast.DataStructure) error {
+	cd := &ast.ClassDeclaration{Token: ds.Token, Name: ds.Name}
+	for _, field := range ds.Fields {
+		fieldType := field.Type
+		if fieldType == nil {
+			fieldType = &ast.Identifier{Token: field.Name.Token, Value: "any"}
+		}
+		cd.Members = append(cd.Members, &ast.ClassMember{
+			VariableDeclaration: &ast.VariableDeclaration{Token: field.Token, Name: field.Name, Type: fieldType},
+		})
+	}
+	if err := cg.defineStructType(cd); err != nil {
+		return err
+	}
+	st, ok := cg.scope.types[ds.Name.Value].(*types.StructType)
+	if !ok {
+		return fmt.Errorf("data type '%s' is not a struct", ds.Name.Value)
+	}
+	cg.dataTypes[st.Name()] = &dataType{decl: ds, st: st}
+	return nil
+}
+
+// dataTypeRef returns the data type expr names, as the receiver of a call
+// such as Point.fromJson(v), or nil.
+func (cg *CodeGenerator) dataTypeRef(expr ast.ExpressionNode) *dataType {
+	ident, ok := expr.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	if _, isVar := cg.Variables[ident.Value]; isVar || cg.scope.declares(ident.Value) {
+		return nil
+	}
+	t, ok := cg.scope.types[ident.Value]
+	if !ok {
+		t, ok = cg.Structs[ident.Value]
+	}
+	if !ok {
+		return nil
+	}
+	st, ok := t.(*types.StructType)
+	if !ok {
+		return nil
+	}
+	return cg.dataTypes[st.Name()]
+}
+
+// visitDataTypeCall generates a call of a function derived for a data type
+// that is called on the type itself: new or fromJson.
+func (cg *CodeGenerator) visitDataTypeCall(dt *dataType, name string, argNodes []ast.ExpressionNode) error {
+	args, err := cg.evaluateArguments(argNodes)
+	if err != nil {
+		return fmt.Errorf("error evaluating arguments for call to '%s.%s': %w", dt.st.Name(), name, err)
+	}
+	var fn *ir.Func
+	switch name {
+	case "new":
+		if len(args) != 0 {
+			return fmt.Errorf("%s.new expects no arguments, got %d", dt.st.Name(), len(args))
+		}
+		fn, err = cg.dataFunc(dt, "new")
+	case "fromJson":
+		if len(args) != 1 {
+			return fmt.Errorf("%s.fromJson expects 1 argument, got %d", dt.st.Name(), len(args))
+		}
+		// JSON text is parsed first.
+		if args[0].Type().Equal(types.I8Ptr) {
+			fn, err = cg.dataFunc(dt, "fromJsonText")
+		} else {
+			fn, err = cg.dataFunc(dt, "fromJson")
+		}
+		if err == nil && !args[0].Type().Equal(fn.Params[0].Typ) {
+			return fmt.Errorf("%s.fromJson expects a *json.JsonValue or a string, got %s", dt.st.Name(), args[0].Type())
+		}
+	default:
+		return fmt.Errorf("data type '%s' has no function '%s'; data types provide new and fromJson, and values toJson", dt.st.Name(), name)
+	}
+	if err != nil {
+		return err
+	}
+	cg.lastValue = cg.Block.NewCall(fn, args...)
+	return nil
+}
+
+// dataFunc returns the function name derived for dt, emitting it on first
+// use, or nil if dt has no function of that name.
+func (cg *CodeGenerator) dataFunc(dt *dataType, name string) (*ir.Func, error) {
+	irName := dt.st.Name() + "_" + name
+	if fn, ok := cg.Functions[irName]; ok {
+		return fn, nil
+	}
+	switch name {
+	case "new":
+		return cg.deriveNew(dt, irName)
+	case "toJson":
+		return cg.deriveToJson(dt, irName)
+	case "fromJson":
+		return cg.deriveFromJson(dt, irName)
+	case "fromJsonText":
+		return cg.deriveFromJsonText(dt, irName)
+	}
+	return nil, nil
+}
+
+// moduleFunc returns the function name of the module at path, which the
+// derived function what calls.
+func (cg *CodeGenerator) moduleFunc(path, name, what string) (*ir.Func, error) {
+	if scope, ok := cg.modules[path]; ok {
+		if fn, ok := scope.functions[name]; ok {
+			return fn, nil
+		}
+	}
+	return nil, fmt.Errorf("%s needs %s; import it", what, path)
+}
+
+// moduleFuncs looks up the functions of the module path named in names.
+func (cg *CodeGenerator) moduleFuncs(path, what string, names ...string) (map[string]*ir.Func, error) {
+	fns := make(map[string]*ir.Func, len(names))
+	for _, name := range names {
+		fn, err := cg.moduleFunc(path, name, what)
+		if err != nil {
+			return nil, err
+		}
+		fns[name] = fn
+	}
+	return fns, nil
+}
+
+// jsonValuePtr returns the type *json.JsonValue, which the derived function
+// what works with.
+func (cg *CodeGenerator) jsonValuePtr(what string) (*types.PointerType, error) {
+	if scope, ok := cg.modules[jsonModule]; ok {
+		if t, ok := scope.types["JsonValue"]; ok {
+			return types.NewPointer(t), nil
+		}
+	}
+	return nil, fmt.Errorf("%s needs %s; import it", what, jsonModule)
+}
+
+// jsonFuncs looks up the functions of stdlib/json named in names.
+func (cg *CodeGenerator) jsonFuncs(what string, names ...string) (map[string]*ir.Func, error) {
+	return cg.moduleFuncs(jsonModule, what, names...)
+}
+
+// stringConst returns a pointer to a global holding s.
+func (cg *CodeGenerator) stringConst(s string) constant.Constant {
+	g, arrType := cg.newStringGlobal(s)
+	zero := constant.NewInt(types.I32, 0)
+	return constant.NewGetElementPtr(arrType, g, zero, zero)
+}
+
+// fieldPtr returns the address of field of the data value self.
+func (cg *CodeGenerator) fieldPtr(b *ir.Block, dt *dataType, self value.Value, field *ast.Field) (value.Value, types.Type) {
+	idx := 0
+	for i, name := range cg.structFields[dt.st.Name()] {
+		if name == field.Name.Value {
+			idx = i
+		}
+	}
+	zero := constant.NewInt(types.I32, 0)
+	return b.NewGetElementPtr(dt.st, self, zero, constant.NewInt(types.I32, int64(idx))), dt.st.Fields[idx]
+}
+
+// deriveNew emits T.new().
+func (cg *CodeGenerator) deriveNew(dt *dataType, irName string) (*ir.Func, error) {
+	heapAlloc, err := cg.moduleFunc(memModule, "heapAlloc", dt.st.Name()+".new")
+	if err != nil {
+		return nil, err
+	}
+	ptrType := types.NewPointer(dt.st)
+	fn := cg.Module.NewFunc(irName, ptrType)
+	cg.Functions[irName] = fn
+
+	entry := fn.NewBlock("entry")
+	// The size of T, as the offset of the T after the one at null
+	size := constant.NewPtrToInt(constant.NewGetElementPtr(dt.st, constant.NewNull(ptrType), constant.NewInt(types.I32, 1)), types.I64)
+	mem := entry.NewCall(heapAlloc, size)
+	entry.NewRet(entry.NewBitCast(mem, ptrType))
+	return fn, nil
+}
+
+// deriveToJson emits t.toJson(), which returns null for a null t.
+func (cg *CodeGenerator) deriveToJson(dt *dataType, irName string) (*ir.Func, error) {
+	what := dt.st.Name() + ".toJson"
+	jsonPtr, err := cg.jsonValuePtr(what)
+	if err != nil {
+		return nil, err
+	}
+	fns, err := cg.jsonFuncs(what, "newNull", "newObject", "set", "newBool", "newInt", "newNumber", "newString", "fromAny", "clone")
+	if err != nil {
+		return nil, err
+	}
+	self := ir.NewParam("self", types.NewPointer(dt.st))
+	fn := cg.Module.NewFunc(irName, jsonPtr, self)
+	cg.Functions[irName] = fn
+
+	entry := fn.NewBlock("entry")
+	isNull := entry.NewICmp(enum.IPredEQ, self, constant.NewNull(self.Typ.(*types.PointerType)))
+	null := fn.NewBlock("null")
+	body := fn.NewBlock("fields")
+	entry.NewCondBr(isNull, null, body)
+	null.NewRet(null.NewCall(fns["newNull"]))
+
+	obj := body.NewCall(fns["newObject"])
+	for _, field := range dt.decl.Fields {
+		ptr, fieldType := cg.fieldPtr(body, dt, self, field)
+		v := body.NewLoad(fieldType, ptr)
+		jv, err := cg.fieldToJson(body, fns, jsonPtr, v, field, dt)
+		if err != nil {
+			return nil, err
+		}
+		body.NewCall(fns["set"], obj, cg.stringConst(field.Name.Value), jv)
+	}
+	body.NewRet(obj)
+	return fn, nil
+}
+
+// fieldToJson converts the value v of field to a *json.JsonValue.
+func (cg *CodeGenerator) fieldToJson(b *ir.Block, fns map[string]*ir.Func, jsonPtr *types.PointerType, v value.Value, field *ast.Field, dt *dataType) (value.Value, error) {
+	switch t := v.Type().(type) {
+	case *types.IntType:
+		if t.BitSize == 1 {
+			return b.NewCall(fns["newBool"], v), nil
+		}
+		n := v
+		if t.BitSize < 64 {
+			if field.Type != nil && unsignedTypeName(field.Type.Value) {
+				n = b.NewZExt(v, types.I64)
+			} else {
+				n = b.NewSExt(v, types.I64)
+			}
+		}
+		return b.NewCall(fns["newInt"], n), nil
+	case *types.FloatType:
+		x := v
+		if t.Kind != types.FloatKindDouble {
+			x = b.NewFPExt(v, types.Double)
+		}
+		return b.NewCall(fns["newNumber"], x), nil
+	case *types.StructType:
+		if t.Equal(cg.anyType()) {
+			kind := b.NewSExt(b.NewExtractValue(v, 0), types.I64)
+			return b.NewCall(fns["fromAny"], kind, b.NewExtractValue(v, 1)), nil
+		}
+	case *types.PointerType:
+		if t.Equal(types.I8Ptr) {
+			return b.NewCall(fns["newString"], v), nil
+		}
+		if t.Equal(jsonPtr) {
+			return b.NewCall(fns["clone"], v), nil
+		}
+		if st, ok := t.ElemType.(*types.StructType); ok {
+			if nested, ok := cg.dataTypes[st.Name()]; ok {
+				toJson, err := cg.dataFunc(nested, "toJson")
+				if err != nil {
+					return nil, err
+				}
+				return b.NewCall(toJson, v), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("field '%s' of data type '%s' has type %s, which has no JSON form", field.Name.Value, dt.st.Name(), v.Type())
+}
+
+// deriveFromJson emits T.fromJson(v) for a *json.JsonValue v. It stops at
+// the first member that fails to decode, freeing the T.
+func (cg *CodeGenerator) deriveFromJson(dt *dataType, irName string) (*ir.Func, error) {
+	what := dt.st.Name() + ".fromJson"
+	jsonPtr, err := cg.jsonValuePtr(what)
+	if err != nil {
+		return nil, err
+	}
+	fns, err := cg.jsonFuncs(what, "errorCount", "expectObject", "get", "isNull", "decodeBool", "decodeInt",
+		"decodeNumber", "decodeString", "decodeValue", "anyKind", "decodeAnyBits")
+	if err != nil {
+		return nil, err
+	}
+	heapFree, err := cg.moduleFunc(memModule, "heapFree", what)
+	if err != nil {
+		return nil, err
+	}
+	ptrType := types.NewPointer(dt.st)
+	v := ir.NewParam("v", jsonPtr)
+	fn := cg.Module.NewFunc(irName, ptrType, v)
+	cg.Functions[irName] = fn
+	newT, err := cg.dataFunc(dt, "new")
+	if err != nil {
+		return nil, err
+	}
+
+	entry := fn.NewBlock("entry")
+	before := entry.NewCall(fns["errorCount"])
+	isObject := entry.NewCall(fns["expectObject"], v, cg.stringConst(dt.st.Name()))
+	notObject := fn.NewBlock("not.object")
+	b := fn.NewBlock("fields")
+	fail := fn.NewBlock("fail")
+	entry.NewCondBr(isObject, b, notObject)
+	notObject.NewRet(constant.NewNull(ptrType))
+
+	t := b.NewCall(newT)
+	for i, field := range dt.decl.Fields {
+		child := b.NewCall(fns["get"], v, cg.stringConst(field.Name.Value))
+		ptr, fieldType := cg.fieldPtr(b, dt, t, field)
+		var val value.Value
+		val, b, err = cg.fieldFromJson(fn, b, fns, jsonPtr, child, fieldType, field, dt, i)
+		if err != nil {
+			return nil, err
+		}
+		b.NewStore(val, ptr)
+		failed := b.NewICmp(enum.IPredNE, b.NewCall(fns["errorCount"]), before)
+		next := fn.NewBlock(fmt.Sprintf("field.%d.done", i))
+		b.NewCondBr(failed, fail, next)
+		b = next
+	}
+	b.NewRet(t)
+
+	fail.NewCall(heapFree, fail.NewBitCast(t, types.I8Ptr))
+	fail.NewRet(constant.NewNull(ptrType))
+	return fn, nil
+}
+
+// fieldFromJson decodes child, the member for field, into a value of the
+// field's type. It returns the block it leaves off in.
+func (cg *CodeGenerator) fieldFromJson(fn *ir.Func, b *ir.Block, fns map[string]*ir.Func, jsonPtr *types.PointerType,
+	child value.Value, fieldType types.Type, field *ast.Field, dt *dataType, i int) (value.Value, *ir.Block, error) {
+	what := cg.stringConst(dt.st.Name() + "." + field.Name.Value)
+	switch t := fieldType.(type) {
+	case *types.IntType:
+		if t.BitSize == 1 {
+			return b.NewCall(fns["decodeBool"], child, what), b, nil
+		}
+		var n value.Value = b.NewCall(fns["decodeInt"], child, what)
+		if t.BitSize < 64 {
+			n = b.NewTrunc(n, t)
+		}
+		return n, b, nil
+	case *types.FloatType:
+		var x value.Value = b.NewCall(fns["decodeNumber"], child, what)
+		if t.Kind != types.FloatKindDouble {
+			x = b.NewFPTrunc(x, t)
+		}
+		return x, b, nil
+	case *types.StructType:
+		if t.Equal(cg.anyType()) {
+			kind := b.NewTrunc(b.NewCall(fns["anyKind"], child), types.I32)
+			bits := b.NewCall(fns["decodeAnyBits"], child)
+			return b.NewInsertValue(b.NewInsertValue(constant.NewUndef(t), kind, 0), bits, 1), b, nil
+		}
+	case *types.PointerType:
+		if t.Equal(types.I8Ptr) {
+			return b.NewCall(fns["decodeString"], child, what), b, nil
+		}
+		if t.Equal(jsonPtr) {
+			return b.NewCall(fns["decodeValue"], child, what), b, nil
+		}
+		if st, ok := t.ElemType.(*types.StructType); ok {
+			if nested, ok := cg.dataTypes[st.Name()]; ok {
+				fromJson, err := cg.dataFunc(nested, "fromJson")
+				if err != nil {
+					return nil, nil, err
+				}
+				// A missing or null member is a null pointer rather than an
+				// error.
+				isNull := b.NewCall(fns["isNull"], child)
+				decode := fn.NewBlock(fmt.Sprintf("field.%d.decode", i))
+				join := fn.NewBlock(fmt.Sprintf("field.%d.join", i))
+				b.NewCondBr(isNull, join, decode)
+				decoded := decode.NewCall(fromJson, child)
+				decode.NewBr(join)
+				phi := join.NewPhi(ir.NewIncoming(constant.NewNull(t), b), ir.NewIncoming(decoded, decode))
+				return phi, join, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("field '%s' of data type '%s' has type %s, which has no JSON form", field.Name.Value, dt.st.Name(), fieldType)
+}
+
+// deriveFromJsonText emits T.fromJson(text), which parses text and decodes
+// the value. It returns null for malformed text, with the parse error in
+// json.lastError().
+func (cg *CodeGenerator) deriveFromJsonText(dt *dataType, irName string) (*ir.Func, error) {
+	what := dt.st.Name() + ".fromJson"
+	fns, err := cg.jsonFuncs(what, "parse", "freeValue")
+	if err != nil {
+		return nil, err
+	}
+	fromJson, err := cg.dataFunc(dt, "fromJson")
+	if err != nil {
+		return nil, err
+	}
+	ptrType := types.NewPointer(dt.st)
+	text := ir.NewParam("text", types.I8Ptr)
+	fn := cg.Module.NewFunc(irName, ptrType, text)
+	cg.Functions[irName] = fn
+
+	entry := fn.NewBlock("entry")
+	v := entry.NewCall(fns["parse"], text)
+	malformed := fn.NewBlock("malformed")
+	decode := fn.NewBlock("decode")
+	entry.NewCondBr(entry.NewICmp(enum.IPredEQ, v, constant.NewNull(v.Type().(*types.PointerType))), malformed, decode)
+	malformed.NewRet(constant.NewNull(ptrType))
+	t := decode.NewCall(fromJson, v)
+	decode.NewCall(fns["freeValue"], v)
+	decode.NewRet(t)
+	return fn, nil
+}
@@ -10,21 +10,23 @@ import (
 	"github.com/llir/llvm/ir/value"
 )
 
-// condAsBool converts any integer value into an i1 suitable for a conditional
-// branch.  If the value is already i1 (produced by a comparison instruction),
-// it is returned unchanged.  Otherwise an ICmp NE against the appropriate zero
-// constant is emitted.
+// condAsBool converts any integer, float or pointer value into an i1 suitable
+// for a conditional branch.  If the value is already i1 (produced by a
+// comparison instruction), it is returned unchanged.  Otherwise the value is
+// compared against the appropriate zero constant.
 func condAsBool(block *ir.Block, v value.Value) value.Value {
-	if iType, ok := v.Type().(*types.IntType); ok && iType.BitSize == 1 {
-		return v
-	}
-	var zero constant.Constant
-	if intType, ok := v.Type().(*types.IntType); ok {
-		zero = constant.NewInt(intType, 0)
-	} else {
-		zero = constant.NewInt(types.I32, 0)
+	switch t := v.Type().(type) {
+	case *types.IntType:
+		if t.BitSize == 1 {
+			return v
+		}
+		return block.NewICmp(enum.IPredNE, v, constant.NewInt(t, 0))
+	case *types.FloatType:
+		return block.NewFCmp(enum.FPredUNE, v, constant.NewFloat(t, 0))
+	case *types.PointerType:
+		return block.NewICmp(enum.IPredNE, v, constant.NewNull(t))
 	}
-	return block.NewICmp(enum.IPredNE, v, zero)
+	return block.NewICmp(enum.IPredNE, v, constant.NewInt(types.I32, 0))
 }
 
 func (cg *CodeGenerator) VisitExpressionStatement(es *ast.ExpressionStatement) error {
@@ -32,7 +34,19 @@ func (cg *CodeGenerator) VisitExpressionStatement(es *ast.ExpressionStatement) e
 	if es.Expression == nil {
 		return nil
 	}
-	return es.Expression.Accept(cg)
+	if cg.Types == nil {
+		return es.Expression.Accept(cg)
+	}
+	cg.lastValue = nil
+	if err := es.Expression.Accept(cg); err != nil {
+		return err
+	}
+	if cg.lastValue == nil {
+		cg.Types[es] = types.Void
+	} else {
+		cg.Types[es] = cg.lastValue.Type()
+	}
+	return nil
 }
 
 func (cg *CodeGenerator) VisitIfStatement(is *ast.IfStatement) error {
@@ -42,6 +56,27 @@ func (cg *CodeGenerator) VisitIfStatement(is *ast.IfStatement) error {
 	}
 	condVal := cg.lastValue
 
+	// A constant condition, such as a const or builtin.libm(), selects
+	// its branch at compile time; the other one is not generated at all.
+	if c, ok := condVal.(*constant.Int); ok {
+		branch := is.Consequence
+		if c.X.Sign() == 0 {
+			branch = is.Alternative
+		}
+		if branch != nil {
+			if err := branch.Accept(cg); err != nil {
+				return err
+			}
+		}
+		if cg.Block != nil && cg.Block.Term != nil {
+			// Code after a branch that returned is unreachable but still
+			// needs a block.
+			cg.Block = cg.newBlock("if_merge")
+		}
+		cg.lastValue = constant.NewInt(types.I32, 0)
+		return nil
+	}
+
 	thenBlock := cg.newBlock("if_then")
 	elseBlock := cg.newBlock("if_else")
 	mergeBlock := cg.newBlock("if_merge")
@@ -94,7 +129,10 @@ func (cg *CodeGenerator) VisitWhileStatement(ws *ast.WhileStatement) error {
 	// Body block — visit and loop back.
 	cg.Block = bodyBlock
 	if ws.Body != nil {
-		if err := ws.Body.Accept(cg); err != nil {
+		cg.loopDepth++
+		err := ws.Body.Accept(cg)
+		cg.loopDepth--
+		if err != nil {
 			return err
 		}
 	}
@@ -21,11 +21,15 @@ func (cg *CodeGenerator) VisitFunctionDefinition(fn *ast.FunctionDefinition) err
 	}
 
 	// 1. Find the pre-declared function.
-	irFunc, ok := cg.Functions[fnName]
+	irFunc, ok := cg.scope.functions[fnName]
 	if !ok {
 		return fmt.Errorf("function '%s' was not pre-declared before visiting definition", fnName)
 	}
 
+	if fn.Generator {
+		return cg.defineGenerator(fn, irFunc)
+	}
+
 	if len(irFunc.Blocks) > 0 && irFunc.Blocks[0].Term != nil {
 		fmt.Printf("[DEBUG] Function '%s' already has a body, skipping definition.\n", fnName)
 		return nil
@@ -80,6 +84,9 @@ func (cg *CodeGenerator) VisitFunctionDefinition(fn *ast.FunctionDefinition) err
 	if fn.Body != nil {
 		bodyErr = fn.Body.Accept(cg)
 	}
+	if bodyErr == nil && cg.Block != nil && cg.Block.Term == nil && cg.lastValue != nil && cg.lastValue.Type().Equal(cg.currentFunc.Sig.RetType) {
+		bodyErr = cg.checkSeqReturn(cg.lastValue)
+	}
 
 	// 5. Add a default return if the body didn't end with one.
 	if cg.Block != nil && cg.Block.Term == nil {
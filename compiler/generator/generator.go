@@ -0,0 +1,313 @@
+package generator
+
+import (
+	"compiler/ast"
+	"fmt"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// A generator function (function* name(...): T) is compiled to a state
+// machine without any runtime support. Calling it allocates a frame on the
+// caller's stack and runs name(frame, args...), which stores the arguments
+// and returns the frame as a seq<T>. Each request for a value calls
+// name.resume(frame): a switch on the frame's state jumps to the start of the
+// body or to the point after the last yield, and the function returns true
+// with the value in the frame's current field, or false once the body ends.
+//
+// Every stack slot of the resume function (parameters, locals, nested
+// generators) is moved into the frame after the body is generated, so locals
+// keep their values while the generator is suspended.
+
+// Fields of a generator frame. The first two form the header shared by
+// every seq<T>, so a frame can be used wherever a sequence is expected.
+const (
+	seqResumeField  = 0 // i1 (i8*)* advancing the sequence
+	seqCurrentField = 1 // the value produced last
+	genStateField   = 2 // i32 resume point
+	genParamsField  = 3 // first parameter; locals follow the parameters
+)
+
+// genDone is the state of a generator whose body has finished.
+const genDone = -1
+
+// seqResumeType is the type of the function advancing a sequence.
+var seqResumeType = types.NewPointer(types.NewFunc(types.I1, types.I8Ptr))
+
+// generatorInfo describes a declared generator function.
+type generatorInfo struct {
+	name   string
+	frame  *types.StructType
+	elem   types.Type
+	seq    *types.StructType
+	resume *ir.Func
+}
+
+// generatorState is the generator whose body is being generated.
+type generatorState struct {
+	info     *generatorInfo
+	frame    value.Value // the frame, in the resume function
+	dispatch *ir.TermSwitch
+	params   map[*ir.InstAlloca]int // parameter slots and their frame fields
+	yields   int
+}
+
+// declareGenerator declares the functions of a generator: the resume
+// function and irName itself, which initializes a frame passed by the caller
+// and returns it as a seq<T>.
+func (cg *CodeGenerator) declareGenerator(fn *ast.FunctionDefinition, irName string) (*ir.Func, error) {
+	fnName := fn.Name.Value
+	var elem types.Type = types.I32
+	if fn.ReturnType != nil {
+		mapped, err := cg.mapType(fn.ReturnType.Value)
+		if err != nil {
+			return nil, fmt.Errorf("generator '%s': %w", fnName, err)
+		}
+		elem = mapped
+	}
+	if elem.Equal(types.Void) {
+		return nil, fmt.Errorf("generator '%s' must yield values, not void", fnName)
+	}
+
+	fields := []types.Type{seqResumeType, elem, types.I32}
+	initParams := []*ir.Param{nil}
+	for _, paramAST := range fn.Parameters {
+		paramType, err := cg.paramType(paramAST)
+		if err != nil {
+			return nil, fmt.Errorf("parameter '%s' of generator '%s': %w", paramAST.Name.Value, fnName, err)
+		}
+		fields = append(fields, paramType)
+		initParams = append(initParams, ir.NewParam(paramAST.Name.Value, paramType))
+	}
+	frame := types.NewStruct(fields...)
+	cg.Module.NewTypeDef(irName+".frame", frame)
+	initParams[0] = ir.NewParam("gen.frame", types.NewPointer(frame))
+
+	info := &generatorInfo{name: fnName, frame: frame, elem: elem, seq: cg.seqType(elem)}
+	info.resume = cg.Module.NewFunc(irName+".resume", types.I1, ir.NewParam("gen.frame", types.I8Ptr))
+	info.resume.Linkage = enum.LinkageInternal
+
+	init := cg.Module.NewFunc(irName, types.NewPointer(info.seq), initParams...)
+	entry := init.NewBlock("entry")
+	framePtr := init.Params[0]
+	entry.NewStore(info.resume, frameField(entry, frame, framePtr, seqResumeField))
+	entry.NewStore(constant.NewInt(types.I32, 0), frameField(entry, frame, framePtr, genStateField))
+	for i, param := range init.Params[1:] {
+		entry.NewStore(param, frameField(entry, frame, framePtr, genParamsField+i))
+	}
+	entry.NewRet(entry.NewBitCast(framePtr, types.NewPointer(info.seq)))
+
+	cg.generators[init] = info
+	fmt.Printf("[DEBUG] declareGenerator '%s': frame %s, yields %s\n", fnName, frame.Name(), elem)
+	return init, nil
+}
+
+// frameField returns the address of field i of the struct at ptr.
+func frameField(block *ir.Block, frame types.Type, ptr value.Value, i int) value.Value {
+	return block.NewGetElementPtr(frame, ptr, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(i)))
+}
+
+// defineGenerator generates the resume function of a generator from its body.
+func (cg *CodeGenerator) defineGenerator(fn *ast.FunctionDefinition, init *ir.Func) error {
+	info := cg.generators[init]
+	resume := info.resume
+	if len(resume.Blocks) > 0 {
+		fmt.Printf("[DEBUG] Generator '%s' already has a body, skipping definition.\n", info.name)
+		return nil
+	}
+	fmt.Printf("[DEBUG] Generating body for generator '%s'\n", info.name)
+
+	oldBlock, oldFunc, oldVars, oldGen, oldDepth := cg.Block, cg.currentFunc, cg.Variables, cg.gen, cg.loopDepth
+	defer func() {
+		cg.Block, cg.currentFunc, cg.Variables, cg.gen, cg.loopDepth = oldBlock, oldFunc, oldVars, oldGen, oldDepth
+	}()
+
+	entry := resume.NewBlock("entry")
+	start := resume.NewBlock("gen.start")
+	framePtr := entry.NewBitCast(resume.Params[0], types.NewPointer(info.frame))
+	framePtr.SetName("self")
+	state := entry.NewLoad(types.I32, frameField(entry, info.frame, framePtr, genStateField))
+	gs := &generatorState{
+		info:     info,
+		frame:    framePtr,
+		dispatch: entry.NewSwitch(state, nil, ir.NewCase(constant.NewInt(types.I32, 0), start)),
+		params:   make(map[*ir.InstAlloca]int),
+	}
+
+	cg.currentFunc = resume
+	cg.Block = start
+	cg.Variables = make(map[string]value.Value)
+	cg.gen = gs
+	cg.loopDepth = 0
+
+	// Parameters are stored in the frame by the generator call; here they
+	// only need slots, which are mapped onto those fields.
+	for i, param := range init.Params[1:] {
+		slot := cg.newEntryAlloca(param.Typ)
+		slot.SetName(param.Name() + ".addr")
+		gs.params[slot] = genParamsField + i
+		cg.setVar(param.Name(), slot)
+	}
+
+	var bodyErr error
+	if fn.Body != nil {
+		bodyErr = fn.Body.Accept(cg)
+	}
+	if bodyErr != nil {
+		return fmt.Errorf("error generating body for generator '%s': %w", info.name, bodyErr)
+	}
+	if cg.Block != nil && cg.Block.Term == nil {
+		cg.finishGenerator()
+	}
+
+	done := resume.NewBlock("gen.done")
+	done.NewRet(constant.False)
+	gs.dispatch.TargetDefault = done
+
+	if err := cg.moveLocalsToFrame(gs); err != nil {
+		return fmt.Errorf("generator '%s': %w", info.name, err)
+	}
+	fmt.Printf("[DEBUG] Finished generating generator '%s' with %d yields\n", info.name, gs.yields)
+	return nil
+}
+
+// finishGenerator ends the generator: the state is set to done, so later
+// requests produce no more values.
+func (cg *CodeGenerator) finishGenerator() {
+	gs := cg.gen
+	cg.Block.NewStore(constant.NewInt(types.I32, genDone), frameField(cg.Block, gs.info.frame, gs.frame, genStateField))
+	cg.Block.NewRet(constant.False)
+}
+
+func (cg *CodeGenerator) VisitYieldStatement(ys *ast.YieldStatement) error {
+	gs := cg.gen
+	if gs == nil {
+		return fmt.Errorf("yield outside of a generator function at line %d", ys.Token.Line+1)
+	}
+	if err := ys.Value.Accept(cg); err != nil {
+		return err
+	}
+	if cg.lastValue == nil {
+		return fmt.Errorf("yield at line %d: value expression produced no value", ys.Token.Line+1)
+	}
+	v, err := cg.convertValue(cg.lastValue, gs.info.elem)
+	if err != nil {
+		return fmt.Errorf("yield in generator '%s' at line %d: %w", gs.info.name, ys.Token.Line+1, err)
+	}
+
+	// Publish the value, record where to resume and return to the caller.
+	gs.yields++
+	cg.Block.NewStore(v, frameField(cg.Block, gs.info.frame, gs.frame, seqCurrentField))
+	cg.Block.NewStore(constant.NewInt(types.I32, int64(gs.yields)), frameField(cg.Block, gs.info.frame, gs.frame, genStateField))
+	cg.Block.NewRet(constant.True)
+
+	resumed := cg.newBlock(fmt.Sprintf("gen.resume.%d", gs.yields))
+	gs.dispatch.Cases = append(gs.dispatch.Cases, ir.NewCase(constant.NewInt(types.I32, int64(gs.yields)), resumed))
+	cg.Block = resumed
+	cg.lastValue = nil
+	return nil
+}
+
+// returnFromGenerator handles 'return;' in a generator body, which ends it.
+func (cg *CodeGenerator) returnFromGenerator(rs *ast.ReturnStatement) error {
+	if rs.ReturnValue != nil {
+		return fmt.Errorf("return in generator '%s' at line %d cannot have a value; use yield", cg.gen.info.name, rs.Token.Line+1)
+	}
+	cg.finishGenerator()
+	// Statements after the return are unreachable; they get a block of their
+	// own so they cannot reuse the terminated one.
+	cg.Block = cg.newBlock("gen.unreachable")
+	return nil
+}
+
+// moveLocalsToFrame replaces every alloca of the resume function with the
+// address of a field of the frame, computed in the entry block so it
+// dominates every resume point.
+func (cg *CodeGenerator) moveLocalsToFrame(gs *generatorState) error {
+	frame := gs.info.frame
+	resume := gs.info.resume
+	slots := make(map[value.Value]value.Value)
+	var addrs []ir.Instruction
+
+	for _, block := range resume.Blocks {
+		kept := block.Insts[:0]
+		for _, inst := range block.Insts {
+			alloca, ok := inst.(*ir.InstAlloca)
+			if !ok {
+				kept = append(kept, inst)
+				continue
+			}
+			if alloca.NElems != nil {
+				if n, isConst := alloca.NElems.(*constant.Int); !isConst || n.X.Int64() != 1 {
+					return fmt.Errorf("a stack allocation whose size is only known at run time (such as array.map) cannot be kept in a generator")
+				}
+			}
+			field, isParam := gs.params[alloca]
+			if !isParam {
+				if typeContains(alloca.ElemType, frame, map[types.Type]bool{}) {
+					return fmt.Errorf("a generator cannot call itself, as its frame would contain itself")
+				}
+				field = len(frame.Fields)
+				frame.Fields = append(frame.Fields, alloca.ElemType)
+			}
+			addr := ir.NewGetElementPtr(frame, gs.frame, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(field)))
+			if !alloca.IsUnnamed() {
+				addr.SetName(alloca.Name())
+			}
+			slots[alloca] = addr
+			addrs = append(addrs, addr)
+		}
+		block.Insts = kept
+	}
+
+	// The entry block starts with the bitcast producing the frame pointer.
+	entry := resume.Blocks[0]
+	insts := append([]ir.Instruction{entry.Insts[0]}, addrs...)
+	entry.Insts = append(insts, entry.Insts[1:]...)
+
+	type user interface{ Operands() []*value.Value }
+	for _, block := range resume.Blocks {
+		users := make([]user, 0, len(block.Insts)+1)
+		for _, inst := range block.Insts {
+			if u, ok := inst.(user); ok {
+				users = append(users, u)
+			}
+		}
+		if u, ok := block.Term.(user); ok {
+			users = append(users, u)
+		}
+		for _, u := range users {
+			for _, operand := range u.Operands() {
+				if addr, ok := slots[*operand]; ok {
+					*operand = addr
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// typeContains reports whether t is or contains the struct s by value.
+func typeContains(t types.Type, s *types.StructType, seen map[types.Type]bool) bool {
+	if t == types.Type(s) {
+		return true
+	}
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t := t.(type) {
+	case *types.StructType:
+		for _, field := range t.Fields {
+			if typeContains(field, s, seen) {
+				return true
+			}
+		}
+	case *types.ArrayType:
+		return typeContains(t.ElemType, s, seen)
+	}
+	return false
+}
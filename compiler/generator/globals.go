@@ -0,0 +1,496 @@
+package generator
+
+import (
+	"compiler/ast"
+	"fmt"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"math"
+)
+
+// moduleScope holds the top-level constants, globals and function names
+// declared by one module. Unqualified names resolve in the scope of the module
+// being compiled; other modules are reached through their import alias, e.g.
+// fs.DT_DIR for a constant declared in stdlib/fs.
+type moduleScope struct {
+	path      string
+	file      string // Source file, for messages; "" for the main program
+	prefix    string // Prefix of the IR names of the module's globals ("" for the main program)
+	consts    map[string]constant.Constant
+	globals   map[string]*globalVar
+	functions map[string]*ir.Func
+	types     map[string]types.Type // Struct types, by their name in the source
+}
+
+// globalVar is a mutable module-level variable. Globals whose initializer is
+// not a compile-time constant get a lazy initializer that runs on first use.
+type globalVar struct {
+	global *ir.Global
+	init   *ir.Func // nil for statically initialized globals
+}
+
+func newModuleScope(path, prefix string) *moduleScope {
+	return &moduleScope{
+		path:      path,
+		prefix:    prefix,
+		consts:    make(map[string]constant.Constant),
+		globals:   make(map[string]*globalVar),
+		functions: make(map[string]*ir.Func),
+		types:     make(map[string]types.Type),
+	}
+}
+
+func (s *moduleScope) declares(name string) bool {
+	_, isConst := s.consts[name]
+	_, isGlobal := s.globals[name]
+	_, isFunc := s.functions[name]
+	return isConst || isGlobal || isFunc
+}
+
+// defineGlobal lowers a top-level let or const of the current module.
+// Constants are folded at compile time and never emitted; lets become LLVM
+// globals with a static initializer when one can be computed, and a lazy
+// initializer otherwise.
+func (cg *CodeGenerator) defineGlobal(ls *ast.LetStatement) error {
+	name := ls.Name.Value
+	kind := "let"
+	if ls.IsConst() {
+		kind = "const"
+	}
+	if _, isConst := cg.scope.consts[name]; isConst {
+		return fmt.Errorf("%s '%s': already declared at top level", kind, name)
+	}
+	if _, isGlobal := cg.scope.globals[name]; isGlobal {
+		return fmt.Errorf("%s '%s': already declared at top level", kind, name)
+	}
+
+	var declType types.Type
+	if ls.Type != nil {
+		t, err := cg.mapType(ls.Type.Value)
+		if err != nil {
+			return fmt.Errorf("%s '%s': %w", kind, name, err)
+		}
+		declType = t
+	}
+
+	init, constErr := cg.evalConst(ls.Value)
+	if constErr == nil && declType != nil {
+		converted, err := convertConst(init, declType)
+		if err != nil {
+			return fmt.Errorf("%s '%s': %w", kind, name, err)
+		}
+		init = converted
+	}
+
+	if ls.IsConst() {
+		if constErr != nil {
+			return fmt.Errorf("const '%s': %w", name, constErr)
+		}
+		cg.scope.consts[name] = init
+		fmt.Printf("[DEBUG] Constant '%s' = %s\n", name, init.Ident())
+		return nil
+	}
+
+	irName := cg.scope.prefix + name
+	if constErr == nil {
+		g := cg.Module.NewGlobalDef(irName, init)
+		if ls.ThreadLocal {
+			cg.markThreadLocal(g)
+		}
+		cg.scope.globals[name] = &globalVar{global: g}
+		fmt.Printf("[DEBUG] Global '%s' with static initializer %s\n", irName, init.Ident())
+		return nil
+	}
+	return cg.defineLazyGlobal(ls, irName, declType)
+}
+
+// defineLazyGlobal emits a global together with an initializer function
+//
+//	@name.init: if (!@name.ready) { @name.ready = true; @name = <value> }
+//
+// which every access calls first. The ready flag of a thread-local global is
+// thread-local too, so each thread initializes its own copy.
+func (cg *CodeGenerator) defineLazyGlobal(ls *ast.LetStatement, irName string, declType types.Type) error {
+	ready := cg.Module.NewGlobalDef(irName+".ready", constant.False)
+	ready.Linkage = enum.LinkageInternal
+	if ls.ThreadLocal {
+		cg.markThreadLocal(ready)
+	}
+	initFn := cg.Module.NewFunc(irName+".init", types.Void)
+	initFn.Linkage = enum.LinkageInternal
+
+	oldBlock, oldFunc, oldVars, oldLoopDepth := cg.Block, cg.currentFunc, cg.Variables, cg.loopDepth
+	defer func() {
+		cg.Block, cg.currentFunc, cg.Variables, cg.loopDepth = oldBlock, oldFunc, oldVars, oldLoopDepth
+	}()
+
+	entry := initFn.NewBlock("entry")
+	run := initFn.NewBlock("init")
+	done := initFn.NewBlock("done")
+	entry.NewCondBr(entry.NewLoad(types.I1, ready), done, run)
+	done.NewRet(nil)
+
+	cg.Block, cg.currentFunc, cg.Variables, cg.loopDepth = run, initFn, make(map[string]value.Value), 0
+	// Mark the global ready first so an initializer reaching the global again
+	// through a function it calls cannot recurse.
+	cg.Block.NewStore(constant.True, ready)
+	if err := ls.Value.Accept(cg); err != nil {
+		return fmt.Errorf("let '%s': %w", ls.Name.Value, err)
+	}
+	v := cg.lastValue
+	if v == nil {
+		return fmt.Errorf("let '%s': initializer does not produce a value", ls.Name.Value)
+	}
+	if declType != nil {
+		converted, err := cg.convertValue(v, declType)
+		if err != nil {
+			return fmt.Errorf("let '%s': %w", ls.Name.Value, err)
+		}
+		v = converted
+	}
+
+	g := cg.Module.NewGlobalDef(irName, constant.NewZeroInitializer(v.Type()))
+	if ls.ThreadLocal {
+		cg.markThreadLocal(g)
+	}
+	cg.Block.NewStore(v, g)
+	cg.Block.NewBr(done)
+	cg.scope.globals[ls.Name.Value] = &globalVar{global: g, init: initFn}
+	fmt.Printf("[DEBUG] Global '%s' with lazy initializer %s\n", irName, initFn.Ident())
+	return nil
+}
+
+// visitScopedName resolves name among the constants and globals of scope. It
+// reports false when the scope declares no such constant or global.
+func (cg *CodeGenerator) visitScopedName(scope *moduleScope, name string) (bool, error) {
+	if c, ok := scope.consts[name]; ok {
+		if cg.inAssignmentLHS {
+			// Indexing a constant (e.g. a string) needs an address to start from.
+			tmp := cg.newLocalAlloca(c.Type())
+			cg.Block.NewStore(c, tmp)
+			cg.lastValue = tmp
+			return true, nil
+		}
+		cg.lastValue = c
+		return true, nil
+	}
+	if gv, ok := scope.globals[name]; ok {
+		if gv.init != nil {
+			cg.Block.NewCall(gv.init)
+		}
+		if cg.inAssignmentLHS {
+			cg.lastValue = gv.global
+		} else {
+			cg.lastValue = cg.Block.NewLoad(gv.global.ContentType, gv.global)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// moduleRef returns the scope of the module an expression names through its
+// import alias (the 'fs' in fs.DT_DIR), or nil if the expression is not a
+// module reference. Local variables and the current module's own names take
+// precedence over aliases.
+func (cg *CodeGenerator) moduleRef(expr ast.ExpressionNode) *moduleScope {
+	ident, ok := expr.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	if _, isVar := cg.Variables[ident.Value]; isVar || cg.scope.declares(ident.Value) {
+		return nil
+	}
+	path, ok := cg.moduleAliases[ident.Value]
+	if !ok {
+		return nil
+	}
+	return cg.modules[path]
+}
+
+// visitModuleMember resolves alias.member to a constant, global or function
+// declared by the referenced module.
+func (cg *CodeGenerator) visitModuleMember(scope *moduleScope, mae *ast.MemberAccessExpression) error {
+	member := mae.Member.Value
+	if handled, err := cg.visitScopedName(scope, member); handled {
+		return err
+	}
+	if fn, ok := scope.functions[member]; ok {
+		cg.lastValue = fn
+		return nil
+	}
+	if scope.path == syscallTableName {
+		return fmt.Errorf("unknown syscall '%s.%s' on linux/%s", syscallTableName, member, cg.target.Name)
+	}
+	return fmt.Errorf("module '%s' has no member '%s'", scope.path, member)
+}
+
+// isConstRef reports whether expr names a constant, either unqualified or
+// through a module alias.
+func (cg *CodeGenerator) isConstRef(expr ast.ExpressionNode) bool {
+	switch e := expr.(type) {
+	case *ast.Identifier:
+		if v, isVar := cg.Variables[e.Value]; isVar {
+			return isConstValue(v)
+		}
+		_, ok := cg.scope.consts[e.Value]
+		return ok
+	case *ast.MemberAccessExpression:
+		if scope := cg.moduleRef(e.Left); scope != nil {
+			_, ok := scope.consts[e.Member.Value]
+			return ok
+		}
+	}
+	return false
+}
+
+// isConstValue reports whether v is a folded constant (int, float or string).
+func isConstValue(v value.Value) bool {
+	switch v.(type) {
+	case *constant.Int, *constant.Float, *constant.ExprGetElementPtr:
+		return true
+	}
+	return false
+}
+
+// evalConst evaluates expr at compile time. Literals, other constants, the
+// constant intrinsics and arithmetic, bitwise, comparison and logical
+// operators over them are supported.
+func (cg *CodeGenerator) evalConst(expr ast.ExpressionNode) (constant.Constant, error) {
+	switch e := expr.(type) {
+	case *ast.NumberLiteral, *ast.BooleanLiteral:
+		if err := e.Accept(cg); err != nil {
+			return nil, err
+		}
+		return cg.lastValue.(constant.Constant), nil
+	case *ast.StringLiteral:
+		return cg.stringConstant(e.Value), nil
+	case *ast.Identifier:
+		if v, isVar := cg.Variables[e.Value]; isVar {
+			if isConstValue(v) {
+				return v.(constant.Constant), nil
+			}
+		} else if c, ok := cg.scope.consts[e.Value]; ok {
+			return c, nil
+		}
+	case *ast.MemberAccessExpression:
+		if scope := cg.moduleRef(e.Left); scope != nil {
+			if c, ok := scope.consts[e.Member.Value]; ok {
+				return c, nil
+			}
+		}
+	case *ast.CallExpression:
+		if c, ok, err := cg.evalConstIntrinsic(e); ok {
+			return c, err
+		}
+	case *ast.PrefixExpression:
+		right, err := cg.evalConst(e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return foldPrefix(e.Operator, right)
+	case *ast.CastExpression:
+		value, err := cg.evalConst(e.Value)
+		if err != nil {
+			return nil, err
+		}
+		target, err := cg.mapType(e.Type.Value)
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := value.(*constant.Int); ok && unsignedTypeName(e.Type.Value) {
+			if dst, ok := target.(*types.IntType); ok && c.Typ.BitSize < dst.BitSize {
+				return zextConst(c, dst), nil
+			}
+		}
+		return convertConst(value, target)
+	case *ast.InfixExpression:
+		left, err := cg.evalConst(e.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := cg.evalConst(e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return foldInfix(e.Operator, left, right)
+	}
+	return nil, fmt.Errorf("'%s' is not a compile-time constant", expr.String())
+}
+
+// wrapInt truncates v to a signed integer of the given width.
+func wrapInt(v int64, bits uint64) int64 {
+	if bits >= 64 {
+		return v
+	}
+	shift := 64 - bits
+	return (v << shift) >> shift
+}
+
+func constFloat(c constant.Constant) (float64, bool) {
+	switch v := c.(type) {
+	case *constant.Float:
+		f, _ := v.X.Float64()
+		return f, true
+	case *constant.Int:
+		return float64(v.X.Int64()), true
+	}
+	return 0, false
+}
+
+func foldPrefix(op string, c constant.Constant) (constant.Constant, error) {
+	switch v := c.(type) {
+	case *constant.Int:
+		t := v.Typ
+		switch op {
+		case "-":
+			return constant.NewInt(t, wrapInt(-v.X.Int64(), t.BitSize)), nil
+		case "!":
+			return constant.NewBool(v.X.Sign() == 0), nil
+		case "~":
+			return constant.NewInt(t, wrapInt(^v.X.Int64(), t.BitSize)), nil
+		}
+	case *constant.Float:
+		if op == "-" {
+			f, _ := v.X.Float64()
+			return constant.NewFloat(v.Typ, -f), nil
+		}
+	}
+	return nil, fmt.Errorf("operator '%s' cannot be applied to constant %s", op, c.Ident())
+}
+
+func foldInfix(op string, l, r constant.Constant) (constant.Constant, error) {
+	li, lInt := l.(*constant.Int)
+	ri, rInt := r.(*constant.Int)
+	if lInt && rInt {
+		t := li.Typ
+		if ri.Typ.BitSize > t.BitSize {
+			t = ri.Typ
+		}
+		a, b := li.X.Int64(), ri.X.Int64()
+		if li.Typ.BitSize == 1 && t.BitSize > 1 {
+			a = a & 1
+		}
+		if ri.Typ.BitSize == 1 && t.BitSize > 1 {
+			b = b & 1
+		}
+		switch op {
+		case "+":
+			return constant.NewInt(t, wrapInt(a+b, t.BitSize)), nil
+		case "-":
+			return constant.NewInt(t, wrapInt(a-b, t.BitSize)), nil
+		case "*":
+			return constant.NewInt(t, wrapInt(a*b, t.BitSize)), nil
+		case "/", "%":
+			if b == 0 {
+				return nil, fmt.Errorf("division by zero in constant expression")
+			}
+			if op == "/" {
+				return constant.NewInt(t, wrapInt(a/b, t.BitSize)), nil
+			}
+			return constant.NewInt(t, wrapInt(a%b, t.BitSize)), nil
+		case "&":
+			return constant.NewInt(t, wrapInt(a&b, t.BitSize)), nil
+		case "|":
+			return constant.NewInt(t, wrapInt(a|b, t.BitSize)), nil
+		case "^":
+			return constant.NewInt(t, wrapInt(a^b, t.BitSize)), nil
+		case "<<", ">>", ">>>":
+			if b < 0 || b >= int64(t.BitSize) {
+				return nil, fmt.Errorf("shift count %d out of range in constant expression", b)
+			}
+			switch op {
+			case "<<":
+				return constant.NewInt(t, wrapInt(a<<uint(b), t.BitSize)), nil
+			case ">>":
+				return constant.NewInt(t, wrapInt(a>>uint(b), t.BitSize)), nil
+			}
+			// Logical shift: drop the sign bits above the value's width first.
+			u := uint64(a)
+			if t.BitSize < 64 {
+				u &= 1<<uint(t.BitSize) - 1
+			}
+			return constant.NewInt(t, wrapInt(int64(u>>uint(b)), t.BitSize)), nil
+		case "==":
+			return constant.NewBool(a == b), nil
+		case "!=":
+			return constant.NewBool(a != b), nil
+		case "<":
+			return constant.NewBool(a < b), nil
+		case ">":
+			return constant.NewBool(a > b), nil
+		case "<=":
+			return constant.NewBool(a <= b), nil
+		case ">=":
+			return constant.NewBool(a >= b), nil
+		case "&&":
+			return constant.NewBool(a != 0 && b != 0), nil
+		case "||":
+			return constant.NewBool(a != 0 || b != 0), nil
+		}
+		return nil, fmt.Errorf("operator '%s' is not supported in constant expressions", op)
+	}
+
+	a, lNum := constFloat(l)
+	b, rNum := constFloat(r)
+	if !lNum || !rNum {
+		return nil, fmt.Errorf("operator '%s' cannot be applied to constants %s and %s", op, l.Ident(), r.Ident())
+	}
+	t := types.Float
+	if types.Equal(l.Type(), types.Double) || types.Equal(r.Type(), types.Double) {
+		t = types.Double
+	}
+	switch op {
+	case "+":
+		return constant.NewFloat(t, a+b), nil
+	case "-":
+		return constant.NewFloat(t, a-b), nil
+	case "*":
+		return constant.NewFloat(t, a*b), nil
+	case "/":
+		return constant.NewFloat(t, a/b), nil
+	case "%":
+		return constant.NewFloat(t, math.Mod(a, b)), nil
+	case "==":
+		return constant.NewBool(a == b), nil
+	case "!=":
+		return constant.NewBool(a != b), nil
+	case "<":
+		return constant.NewBool(a < b), nil
+	case ">":
+		return constant.NewBool(a > b), nil
+	case "<=":
+		return constant.NewBool(a <= b), nil
+	case ">=":
+		return constant.NewBool(a >= b), nil
+	}
+	return nil, fmt.Errorf("operator '%s' is not supported in constant expressions", op)
+}
+
+// convertConst converts a folded constant to t without emitting instructions.
+func convertConst(c constant.Constant, t types.Type) (constant.Constant, error) {
+	if c.Type().Equal(t) {
+		return c, nil
+	}
+	switch target := t.(type) {
+	case *types.IntType:
+		switch v := c.(type) {
+		case *constant.Int:
+			x := v.X.Int64()
+			if v.Typ.BitSize == 1 {
+				x &= 1
+			}
+			return constant.NewInt(target, wrapInt(x, target.BitSize)), nil
+		case *constant.Float:
+			f, _ := v.X.Float64()
+			return constant.NewInt(target, wrapInt(int64(f), target.BitSize)), nil
+		}
+	case *types.FloatType:
+		if f, ok := constFloat(c); ok {
+			return constant.NewFloat(target, f), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert constant %s to %s", c.Ident(), t)
+}
@@ -31,16 +31,26 @@ func (cg *CodeGenerator) VisitIdentifier(id *ast.Identifier) error {
 		return nil
 	}
 
-	// 2. Check global functions
+	// 2. Check constants and global variables of the current module
+	if found, err := cg.visitScopedName(cg.scope, identName); found {
+		if err == nil {
+			fmt.Printf("[DEBUG] Identifier '%s' resolved to module-level name: %s\n", identName, cg.lastValue.Ident())
+		}
+		return err
+	}
+
+	// 3. Check functions, preferring those of the current module
+	if fn, ok := cg.scope.functions[identName]; ok {
+		cg.lastValue = fn
+		fmt.Printf("[DEBUG] Identifier '%s' resolved to module function: %s\n", identName, fn.Ident())
+		return nil
+	}
 	if fn, ok := cg.Functions[identName]; ok {
 		cg.lastValue = fn
 		fmt.Printf("[DEBUG] Identifier '%s' resolved to function: %s\n", identName, fn.Ident())
 		return nil
 	}
 
-	// 3. Check global variables (if any added later)
-	// todo!!!
-
 	// 4. Not found - Implicit declaration (WARN)
 	// Log the content of the scope map where it wasn't found
 	fmt.Printf("[WARN] Identifier '%s' not found in current scope %p (Vars: %v), creating implicit external declaration i32()\n", identName, currentScope, currentScope)
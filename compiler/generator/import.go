@@ -1,13 +1,44 @@
 package generator
 
-import "compiler/ast"
+import (
+	"compiler/ast"
+	"fmt"
+	"path"
+)
 
 func (cg *CodeGenerator) VisitImportStatement(is *ast.ImportStatement) error {
+	// The module's top-level names are reachable through the last element of
+	// its path, e.g. fs.DT_DIR after import "stdlib/fs".
+	cg.moduleAliases[path.Base(is.Path)] = is.Path
+	if _, done := cg.modules[is.Path]; done {
+		// Already compiled (or being compiled, for import cycles).
+		return nil
+	}
+
 	// load the module's AST, then compile it if not compiled
 	mod, err := cg.ModuleManager.LoadModule(is.Path)
 	if err != nil {
 		return err
 	}
+	scope := newModuleScope(is.Path, path.Base(is.Path)+".")
+	scope.file = mod.Path
+	cg.modules[is.Path] = scope
+
 	// Now visit the module’s AST to generate IR for all its top-level items
-	return mod.AST.Accept(cg)
+	outer := cg.scope
+	cg.scope = scope
+	defer func() { cg.scope = outer }()
+	if err := mod.AST.Accept(cg); err != nil {
+		return err
+	}
+
+	// A module's fini function runs when the program exits, e.g. to flush
+	// buffered output.
+	if fini, ok := scope.functions["fini"]; ok {
+		if len(fini.Params) != 0 {
+			return fmt.Errorf("fini of module %s must not take parameters", is.Path)
+		}
+		cg.finalizers = append(cg.finalizers, fini)
+	}
+	return nil
 }
@@ -2,14 +2,18 @@ package generator
 
 import (
 	"compiler/ast"
+	"fmt"
 	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/enum"
 	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
 )
 
-// coerceToSameIntType zero-extends the narrower of two integer values to
-// match the wider one. Pointer types are left unchanged (returned as-is).
+// coerceToSameIntType widens the narrower of two integer values to match the
+// wider one: i1 comparison results are zero-extended, everything else is
+// sign-extended so negative constants keep their value. Pointer types are
+// left unchanged (returned as-is).
 func coerceToSameIntType(block *ir.Block, a, b value.Value) (value.Value, value.Value) {
 	aInt, aOk := a.Type().(*types.IntType)
 	bInt, bOk := b.Type().(*types.IntType)
@@ -20,12 +24,62 @@ func coerceToSameIntType(block *ir.Block, a, b value.Value) (value.Value, value.
 		return a, b
 	}
 	if aInt.BitSize > bInt.BitSize {
-		return a, block.NewZExt(b, a.Type())
+		return a, widenInt(block, b, aInt)
 	}
-	return block.NewZExt(a, b.Type()), b
+	return widenInt(block, a, bInt), b
+}
+
+func widenInt(block *ir.Block, v value.Value, to *types.IntType) value.Value {
+	if c, ok := v.(*constant.Int); ok {
+		return constant.NewInt(to, c.X.Int64())
+	}
+	if v.Type().(*types.IntType).BitSize == 1 {
+		return block.NewZExt(v, to)
+	}
+	return block.NewSExt(v, to)
+}
+
+// coerceToSameFloatType promotes two operands to a common floating point type
+// when at least one of them is a float. Integers are converted with sitofp and
+// float is extended to double when mixed with a double.
+func coerceToSameFloatType(block *ir.Block, a, b value.Value) (value.Value, value.Value, bool) {
+	aFloat, aOk := a.Type().(*types.FloatType)
+	bFloat, bOk := b.Type().(*types.FloatType)
+	if !aOk && !bOk {
+		return a, b, false
+	}
+	target := types.Float
+	if (aOk && aFloat.Kind == types.FloatKindDouble) || (bOk && bFloat.Kind == types.FloatKindDouble) {
+		target = types.Double
+	}
+	return toFloat(block, a, target), toFloat(block, b, target), true
+}
+
+func toFloat(block *ir.Block, v value.Value, target *types.FloatType) value.Value {
+	switch t := v.Type().(type) {
+	case *types.FloatType:
+		if t.Kind == target.Kind {
+			return v
+		}
+		if c, ok := v.(*constant.Float); ok {
+			f, _ := c.X.Float64()
+			return constant.NewFloat(target, f)
+		}
+		return block.NewFPExt(v, target)
+	case *types.IntType:
+		if c, ok := v.(*constant.Int); ok {
+			return constant.NewFloat(target, float64(c.X.Int64()))
+		}
+		return block.NewSIToFP(v, target)
+	}
+	return v
 }
 
 func (cg *CodeGenerator) VisitInfixExpression(ie *ast.InfixExpression) error {
+	if ie.Operator == "&&" || ie.Operator == "||" {
+		return cg.visitLogicalExpression(ie)
+	}
+
 	// Generate left
 	if err := ie.Left.Accept(cg); err != nil {
 		return err
@@ -44,7 +98,23 @@ func (cg *CodeGenerator) VisitInfixExpression(ie *ast.InfixExpression) error {
 		return nil
 	}
 
-	// Promote operands to the same width before applying the operator.
+	if result, isPtr, err := cg.pointerInfix(ie.Operator, leftVal, rightVal); isPtr {
+		if err != nil {
+			return err
+		}
+		cg.lastValue = result
+		return nil
+	}
+
+	// Promote operands to a common type before applying the operator.
+	if l, r, isFloat := coerceToSameFloatType(cg.Block, leftVal, rightVal); isFloat {
+		result, err := cg.floatInfix(ie.Operator, l, r)
+		if err != nil {
+			return err
+		}
+		cg.lastValue = result
+		return nil
+	}
 	leftVal, rightVal = coerceToSameIntType(cg.Block, leftVal, rightVal)
 
 	var result value.Value
@@ -57,14 +127,33 @@ func (cg *CodeGenerator) VisitInfixExpression(ie *ast.InfixExpression) error {
 		result = cg.Block.NewMul(leftVal, rightVal)
 	case "/":
 		result = cg.Block.NewSDiv(leftVal, rightVal)
+	case "%":
+		result = cg.Block.NewSRem(leftVal, rightVal)
+	case "&":
+		result = cg.Block.NewAnd(leftVal, rightVal)
+	case "|":
+		result = cg.Block.NewOr(leftVal, rightVal)
+	case "^":
+		result = cg.Block.NewXor(leftVal, rightVal)
+	case "<<":
+		result = cg.Block.NewShl(leftVal, rightVal)
+	case ">>":
+		// Integers are signed, so >> keeps the sign; >>> shifts in zeros.
+		result = cg.Block.NewAShr(leftVal, rightVal)
+	case ">>>":
+		result = cg.Block.NewLShr(leftVal, rightVal)
 	case "==":
 		result = cg.Block.NewICmp(enum.IPredEQ, leftVal, rightVal)
+	case "!=":
+		result = cg.Block.NewICmp(enum.IPredNE, leftVal, rightVal)
 	case "<":
 		result = cg.Block.NewICmp(enum.IPredSLT, leftVal, rightVal)
 	case ">":
 		result = cg.Block.NewICmp(enum.IPredSGT, leftVal, rightVal)
 	case "<=":
 		result = cg.Block.NewICmp(enum.IPredSLE, leftVal, rightVal)
+	case ">=":
+		result = cg.Block.NewICmp(enum.IPredSGE, leftVal, rightVal)
 	default:
 		// fallback
 		result = leftVal
@@ -72,3 +161,72 @@ func (cg *CodeGenerator) VisitInfixExpression(ie *ast.InfixExpression) error {
 	cg.lastValue = result
 	return nil
 }
+
+func (cg *CodeGenerator) floatInfix(operator string, l, r value.Value) (value.Value, error) {
+	switch operator {
+	case "+":
+		return cg.Block.NewFAdd(l, r), nil
+	case "-":
+		return cg.Block.NewFSub(l, r), nil
+	case "*":
+		return cg.Block.NewFMul(l, r), nil
+	case "/":
+		return cg.Block.NewFDiv(l, r), nil
+	case "%":
+		return cg.Block.NewFRem(l, r), nil
+	case "==":
+		return cg.Block.NewFCmp(enum.FPredOEQ, l, r), nil
+	case "!=":
+		return cg.Block.NewFCmp(enum.FPredUNE, l, r), nil
+	case "<":
+		return cg.Block.NewFCmp(enum.FPredOLT, l, r), nil
+	case ">":
+		return cg.Block.NewFCmp(enum.FPredOGT, l, r), nil
+	case "<=":
+		return cg.Block.NewFCmp(enum.FPredOLE, l, r), nil
+	case ">=":
+		return cg.Block.NewFCmp(enum.FPredOGE, l, r), nil
+	}
+	return nil, fmt.Errorf("operator '%s' is not supported for floating point operands", operator)
+}
+
+// visitLogicalExpression generates short-circuit code for && and ||. The
+// right operand is only evaluated when the left one does not decide the
+// result; the value is an i1 merged with a phi.
+func (cg *CodeGenerator) visitLogicalExpression(ie *ast.InfixExpression) error {
+	if err := ie.Left.Accept(cg); err != nil {
+		return err
+	}
+	if cg.lastValue == nil {
+		return fmt.Errorf("left operand of '%s' produced no value", ie.Operator)
+	}
+	leftBool := condAsBool(cg.Block, cg.lastValue)
+	leftEnd := cg.Block
+
+	rhsBlock := cg.newBlock("logic_rhs")
+	mergeBlock := cg.newBlock("logic_merge")
+	if ie.Operator == "&&" {
+		leftEnd.NewCondBr(leftBool, rhsBlock, mergeBlock)
+	} else {
+		leftEnd.NewCondBr(leftBool, mergeBlock, rhsBlock)
+	}
+
+	cg.Block = rhsBlock
+	if err := ie.Right.Accept(cg); err != nil {
+		return err
+	}
+	if cg.lastValue == nil {
+		return fmt.Errorf("right operand of '%s' produced no value", ie.Operator)
+	}
+	rightBool := condAsBool(cg.Block, cg.lastValue)
+	rhsEnd := cg.Block
+	rhsEnd.NewBr(mergeBlock)
+
+	cg.Block = mergeBlock
+	shortCircuit := constant.False
+	if ie.Operator == "||" {
+		shortCircuit = constant.True
+	}
+	cg.lastValue = cg.Block.NewPhi(ir.NewIncoming(shortCircuit, leftEnd), ir.NewIncoming(rightBool, rhsEnd))
+	return nil
+}
@@ -0,0 +1,250 @@
+package generator
+
+import (
+	"compiler/ast"
+	"compiler/common"
+	"fmt"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"strconv"
+	"strings"
+)
+
+// genericAsmConstraints are the operand constraint letters every target
+// accepts: register, memory, immediate, known integer, general, anything.
+const genericAsmConstraints = "rmingXEFo"
+
+// visitAsmBlock lowers an asm block to an ir.InlineAsm call. Outputs are
+// returned by the call (as a struct when there are several) and stored back
+// to their variables; "+" outputs are also passed in through a tied input;
+// "m" operands are passed by address.
+func (cg *CodeGenerator) visitAsmBlock(ae *ast.AssemblyExpression) error {
+	var (
+		outConstraints []string
+		inConstraints  []string
+		outTypes       []types.Type
+		outAddrs       []value.Value // Where each direct output is stored
+		indirectArgs   []value.Value // Addresses of "=m" outputs
+		args           []value.Value
+		tiedInputs     []string
+		tiedArgs       []value.Value
+	)
+
+	for i, op := range ae.Outputs {
+		code := op.Constraint.Value
+		if err := cg.checkAsmConstraint(code, true, len(ae.Outputs)); err != nil {
+			return fmt.Errorf("asm output %d: %w", i, err)
+		}
+		addr, err := cg.asmOperandAddress(op.Value)
+		if err != nil {
+			return fmt.Errorf("asm output %d: %w", i, err)
+		}
+		elemType := addr.Type().(*types.PointerType).ElemType
+		readWrite := strings.HasPrefix(code, "+")
+		code = strings.TrimLeft(code, "=+")
+
+		if code == "m" || code == "&m" {
+			if readWrite {
+				return fmt.Errorf("asm output %d: memory operands are already read-write, use \"=m\"", i)
+			}
+			outConstraints = append(outConstraints, "=*m")
+			indirectArgs = append(indirectArgs, indirectAsmArg(addr))
+			continue
+		}
+		if readWrite {
+			tiedInputs = append(tiedInputs, strconv.Itoa(len(outConstraints)))
+			tiedArgs = append(tiedArgs, cg.Block.NewLoad(elemType, addr))
+		}
+		outConstraints = append(outConstraints, "="+code)
+		outTypes = append(outTypes, elemType)
+		outAddrs = append(outAddrs, addr)
+	}
+
+	args = append(args, indirectArgs...)
+	for i, op := range ae.Inputs {
+		code := op.Constraint.Value
+		if err := cg.checkAsmConstraint(code, false, len(ae.Outputs)); err != nil {
+			return fmt.Errorf("asm input %d: %w", i, err)
+		}
+		if code == "m" {
+			addr, err := cg.asmOperandAddress(op.Value)
+			if err != nil {
+				return fmt.Errorf("asm input %d: %w", i, err)
+			}
+			inConstraints = append(inConstraints, "*m")
+			args = append(args, indirectAsmArg(addr))
+			continue
+		}
+		if err := op.Value.Accept(cg); err != nil {
+			return fmt.Errorf("asm input %d: %w", i, err)
+		}
+		if cg.lastValue == nil {
+			return fmt.Errorf("asm input %d: expression produced no value", i)
+		}
+		inConstraints = append(inConstraints, code)
+		args = append(args, cg.lastValue)
+	}
+	inConstraints = append(inConstraints, tiedInputs...)
+	args = append(args, tiedArgs...)
+
+	constraints := append(outConstraints, inConstraints...)
+	for _, c := range ae.Clobbers {
+		if err := cg.checkAsmClobber(c.Value); err != nil {
+			return err
+		}
+		constraints = append(constraints, "~{"+c.Value+"}")
+	}
+
+	var retType types.Type = types.Void
+	switch len(outTypes) {
+	case 0:
+	case 1:
+		retType = outTypes[0]
+	default:
+		retType = types.NewStruct(outTypes...)
+	}
+	argTypes := make([]types.Type, len(args))
+	for i, arg := range args {
+		argTypes[i] = arg.Type()
+	}
+
+	text, err := asmTemplate(ae.Code.Value, len(constraints) > 0, len(ae.Outputs)+len(ae.Inputs))
+	if err != nil {
+		return err
+	}
+	inlineAsm := ir.NewInlineAsm(types.NewPointer(types.NewFunc(retType, argTypes...)), text, strings.Join(constraints, ","))
+	// Like GCC, an asm without outputs is only there for its side effects.
+	inlineAsm.SideEffect = ae.Volatile || len(outTypes) == 0
+	inlineAsm.IntelDialect = ae.Dialect == "intel"
+	call := cg.Block.NewCall(inlineAsm, args...)
+
+	if len(outAddrs) == 1 {
+		cg.Block.NewStore(call, outAddrs[0])
+	} else {
+		for i, addr := range outAddrs {
+			cg.Block.NewStore(cg.Block.NewExtractValue(call, uint64(i)), addr)
+		}
+	}
+	fmt.Printf("[DEBUG] asm block: %d outputs, %d inputs, constraints %q\n", len(ae.Outputs), len(ae.Inputs), inlineAsm.Constraint)
+	cg.lastValue = nil
+	return nil
+}
+
+// asmOperandAddress evaluates an operand that must name storage, such as a
+// variable or an array element, and returns its address.
+func (cg *CodeGenerator) asmOperandAddress(expr ast.ExpressionNode) (value.Value, error) {
+	if cg.isConstRef(expr) {
+		return nil, fmt.Errorf("cannot bind constant '%s' to an asm operand", expr.String())
+	}
+	return cg.addressOf(expr)
+}
+
+// indirectAsmArg passes a memory operand's address, tagged with the type it
+// points to as LLVM requires for indirect constraints.
+func indirectAsmArg(addr value.Value) value.Value {
+	return ir.NewArg(addr, ir.ElementType{Typ: addr.Type().(*types.PointerType).ElemType})
+}
+
+// checkAsmConstraint validates an operand constraint: one or more operand
+// kinds for the target, or an explicit register such as "{rax}". Outputs
+// start with '=' or '+' and may be early-clobber ('&'); inputs may instead
+// name an output by number to share its location.
+func (cg *CodeGenerator) checkAsmConstraint(c string, output bool, numOutputs int) error {
+	rest := c
+	if output {
+		if !strings.HasPrefix(rest, "=") && !strings.HasPrefix(rest, "+") {
+			return fmt.Errorf("invalid constraint %q: outputs must start with '=' or '+'", c)
+		}
+		rest = strings.TrimPrefix(rest[1:], "&")
+	} else if strings.HasPrefix(rest, "=") || strings.HasPrefix(rest, "+") || strings.HasPrefix(rest, "&") {
+		return fmt.Errorf("invalid constraint %q: '%c' is only allowed on outputs", c, rest[0])
+	}
+	if rest == "" {
+		return fmt.Errorf("invalid constraint %q: missing operand kind", c)
+	}
+
+	if strings.HasPrefix(rest, "{") {
+		if !strings.HasSuffix(rest, "}") || len(rest) < 3 || strings.ContainsAny(rest[1:len(rest)-1], "{},") {
+			return fmt.Errorf("invalid constraint %q: malformed register name", c)
+		}
+		return nil
+	}
+	if n, err := strconv.Atoi(rest); err == nil {
+		if output || n < 0 || n >= numOutputs {
+			return fmt.Errorf("invalid constraint %q: no output operand %d to tie to", c, n)
+		}
+		return nil
+	}
+
+	allowed := genericAsmConstraints + cg.target.AsmConstraints
+	for _, r := range rest {
+		if !strings.ContainsRune(allowed, r) {
+			return fmt.Errorf("invalid constraint %q: unknown operand kind '%c' on %s", c, r, cg.target.Name)
+		}
+	}
+	if rest != "m" && strings.Contains(rest, "m") {
+		return fmt.Errorf("invalid constraint %q: memory cannot be combined with other operand kinds", c)
+	}
+	return nil
+}
+
+// checkAsmClobber validates a clobber: "memory", "cc" or the name of a
+// register of the target.
+func (cg *CodeGenerator) checkAsmClobber(c string) error {
+	if c == "" {
+		return fmt.Errorf("invalid asm clobber: empty string")
+	}
+	if c == "memory" || c == "cc" || cg.target.IsAsmRegister(c) {
+		return nil
+	}
+	return fmt.Errorf("invalid asm clobber %q: expected \"memory\", \"cc\" or a register of %s", c, cg.target.Name)
+}
+
+// asmTemplate converts GCC-style assembly text to an LLVM inline asm
+// template. A literal '$' becomes "$$". With operands, %N and %cN refer to
+// operand N (LLVM's $N and ${N:c}) and %% is a literal '%'; without operands
+// '%' is taken literally, as in GCC's basic asm. Operand numbers must be
+// below operands, the number of outputs and inputs.
+func asmTemplate(text string, hasOperands bool, operands int) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case ch == '$':
+			sb.WriteString("$$")
+		case ch == '%' && hasOperands && i+1 < len(text):
+			j := i + 1
+			if text[j] == '%' {
+				sb.WriteByte('%')
+				i = j
+				continue
+			}
+			modifier := ""
+			if common.IsLetter(rune(text[j])) && j+1 < len(text) && common.IsDigit(rune(text[j+1])) {
+				modifier = string(text[j])
+				j++
+			}
+			k := j
+			for k < len(text) && common.IsDigit(rune(text[k])) {
+				k++
+			}
+			if k == j {
+				sb.WriteByte(ch)
+				continue
+			}
+			if n, err := strconv.Atoi(text[j:k]); err != nil || n >= operands {
+				return "", fmt.Errorf("asm operand %s out of range: the block has %d operand(s)", text[i:k], operands)
+			}
+			if modifier != "" {
+				sb.WriteString("${" + text[j:k] + ":" + modifier + "}")
+			} else {
+				sb.WriteString("$" + text[j:k])
+			}
+			i = k - 1
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,175 @@
+package generator
+
+import (
+	"compiler/ast"
+	"fmt"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// builtinModule is the name through which programs reach the intrinsics of
+// the compiler, e.g. builtin.atomicAdd(&n, 1, SEQ_CST). Like SYS it needs no
+// import; a variable or top-level name called builtin hides it.
+const builtinModule = "builtin"
+
+// intrinsic is a function of the builtin module. Its arguments are evaluated
+// as values and counted before lower emits it; lower gets the intrinsic's
+// name without the module.
+type intrinsic struct {
+	arity int
+	lower func(cg *CodeGenerator, name string, args []value.Value) error
+}
+
+// intrinsics are the functions of the builtin module, by name.
+var intrinsics = map[string]intrinsic{
+	// The process: its command line and environment, and exit.
+	"args":    {0, (*CodeGenerator).visitArgs},
+	"environ": {0, (*CodeGenerator).visitEnviron},
+	"exit":    {1, (*CodeGenerator).visitExit},
+
+	// Threads, for stdlib/thread; see tls.go.
+	"threadStart": {7, (*CodeGenerator).visitThreadStart},
+	"tlsSize":     {0, (*CodeGenerator).visitTLSIntrinsic},
+	"tlsInit":     {1, (*CodeGenerator).visitTLSIntrinsic},
+
+	// Atomics, for stdlib/atomic; see atomic.go.
+	"atomicLoad":  {2, (*CodeGenerator).visitAtomic},
+	"atomicStore": {3, (*CodeGenerator).visitAtomic},
+	"atomicCas":   {4, (*CodeGenerator).visitAtomic},
+	"atomicAdd":   {3, (*CodeGenerator).visitAtomic},
+	"atomicSub":   {3, (*CodeGenerator).visitAtomic},
+	"atomicAnd":   {3, (*CodeGenerator).visitAtomic},
+	"atomicOr":    {3, (*CodeGenerator).visitAtomic},
+	"atomicXor":   {3, (*CodeGenerator).visitAtomic},
+	"atomicSwap":  {3, (*CodeGenerator).visitAtomic},
+	"atomicMax":   {3, (*CodeGenerator).visitAtomic},
+	"atomicMin":   {3, (*CodeGenerator).visitAtomic},
+	"fence":       {1, (*CodeGenerator).visitAtomic},
+
+	// Target-specific values of the kernel ABI, for stdlib/async and
+	// stdlib/sys.
+	"epollEventSize": {0, (*CodeGenerator).visitEpollEventSize},
+	"oDirectory":     {0, (*CodeGenerator).visitODirectory},
+
+	// Math, for stdlib/math; see math.go.
+	"libm":          {0, (*CodeGenerator).visitLibm},
+	"sqrt":          {1, (*CodeGenerator).visitFloatIntrinsic},
+	"fabs":          {1, (*CodeGenerator).visitFloatIntrinsic},
+	"floor":         {1, (*CodeGenerator).visitFloatIntrinsic},
+	"ceil":          {1, (*CodeGenerator).visitFloatIntrinsic},
+	"trunc":         {1, (*CodeGenerator).visitFloatIntrinsic},
+	"exp":           {1, (*CodeGenerator).visitFloatIntrinsic},
+	"log":           {1, (*CodeGenerator).visitFloatIntrinsic},
+	"sin":           {1, (*CodeGenerator).visitFloatIntrinsic},
+	"cos":           {1, (*CodeGenerator).visitFloatIntrinsic},
+	"pow":           {2, (*CodeGenerator).visitFloatIntrinsic},
+	"addOverflow":   {3, (*CodeGenerator).visitOverflow},
+	"subOverflow":   {3, (*CodeGenerator).visitOverflow},
+	"mulOverflow":   {3, (*CodeGenerator).visitOverflow},
+	"floatBits":     {1, (*CodeGenerator).visitFloatBits},
+	"floatFromBits": {1, (*CodeGenerator).visitFloatBits},
+}
+
+// constantIntrinsics are the intrinsics whose value is fixed for the target,
+// which may therefore initialize constants, e.g.
+// const O_DIRECTORY = builtin.oDirectory().
+var constantIntrinsics = map[string]bool{
+	"libm":           true,
+	"epollEventSize": true,
+	"oDirectory":     true,
+}
+
+// intrinsicCall returns the name of the intrinsic ce calls, builtin.name(...),
+// and false when ce calls something else.
+func (cg *CodeGenerator) intrinsicCall(ce *ast.CallExpression) (string, bool) {
+	mae, ok := ce.Function.(*ast.MemberAccessExpression)
+	if !ok {
+		return "", false
+	}
+	ident, ok := mae.Left.(*ast.Identifier)
+	if !ok || ident.Value != builtinModule {
+		return "", false
+	}
+	_, isVar := cg.Variables[ident.Value]
+	_, isFunc := cg.Functions[ident.Value]
+	if isVar || isFunc || cg.scope.declares(ident.Value) {
+		return "", false
+	}
+	return mae.Member.Value, true
+}
+
+// evalConstIntrinsic returns the value of a call to a constant intrinsic,
+// and false when ce calls something else.
+func (cg *CodeGenerator) evalConstIntrinsic(ce *ast.CallExpression) (constant.Constant, bool, error) {
+	name, ok := cg.intrinsicCall(ce)
+	if !ok || !constantIntrinsics[name] || len(ce.Arguments) != 0 {
+		return nil, false, nil
+	}
+	if err := intrinsics[name].lower(cg, name, nil); err != nil {
+		return nil, true, err
+	}
+	return cg.lastValue.(constant.Constant), true, nil
+}
+
+// visitIntrinsic emits a call to the intrinsic name.
+func (cg *CodeGenerator) visitIntrinsic(name string, ce *ast.CallExpression) error {
+	in, ok := intrinsics[name]
+	if !ok {
+		return fmt.Errorf("unknown intrinsic '%s.%s'", builtinModule, name)
+	}
+	if len(ce.Arguments) != in.arity {
+		return fmt.Errorf("%s.%s expects %d argument(s), got %d", builtinModule, name, in.arity, len(ce.Arguments))
+	}
+	args, err := cg.evaluateArguments(ce.Arguments)
+	if err != nil {
+		return fmt.Errorf("error evaluating arguments for call to '%s.%s': %w", builtinModule, name, err)
+	}
+	cg.lastValue = nil
+	return in.lower(cg, name, args)
+}
+
+// visitArgs returns the command line as a []string, program name first.
+func (cg *CodeGenerator) visitArgs(name string, args []value.Value) error {
+	pa, err := cg.processArgs()
+	if err != nil {
+		return err
+	}
+	cg.lastValue = pa.args
+	return nil
+}
+
+// visitEnviron returns the environment as a null-terminated array of
+// "NAME=value" addresses, viewed as *i64 so entries can be compared with 0.
+func (cg *CodeGenerator) visitEnviron(name string, args []value.Value) error {
+	pa, err := cg.processArgs()
+	if err != nil {
+		return err
+	}
+	envp := cg.Block.NewLoad(pa.environ.ContentType, pa.environ)
+	cg.lastValue = cg.Block.NewBitCast(envp, types.NewPointer(types.I64))
+	return nil
+}
+
+// visitExit ends the process with the given status after running the module
+// finalizers, as returning from main does.
+func (cg *CodeGenerator) visitExit(name string, args []value.Value) error {
+	status, err := cg.convertValue(args[0], types.I64)
+	if err != nil {
+		return fmt.Errorf("%s.%s: %w", builtinModule, name, err)
+	}
+	cg.exitProcess(cg.Block, status, true)
+	return nil
+}
+
+// visitEpollEventSize returns the size of struct epoll_event on the target.
+func (cg *CodeGenerator) visitEpollEventSize(name string, args []value.Value) error {
+	cg.lastValue = constant.NewInt(types.I64, cg.target.Syscall.EpollEventSize)
+	return nil
+}
+
+// visitODirectory returns the O_DIRECTORY flag of open on the target.
+func (cg *CodeGenerator) visitODirectory(name string, args []value.Value) error {
+	cg.lastValue = constant.NewInt(types.I64, cg.target.Syscall.ODirectory)
+	return nil
+}
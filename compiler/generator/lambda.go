@@ -18,10 +18,14 @@ func (cg *CodeGenerator) VisitLambdaExpression(le *ast.LambdaExpression) error {
 	paramTypes := make([]types.Type, len(le.Parameters))
 	paramNames := make([]string, len(le.Parameters))
 	for i, paramAST := range le.Parameters {
-		paramTypes[i] = types.I32 // Assuming i32 for now
-		paramNames[i] = paramAST.Value
+		paramType, err := cg.paramType(paramAST)
+		if err != nil {
+			return fmt.Errorf("parameter '%s' of lambda '%s': %w", paramAST.Name.Value, fnName, err)
+		}
+		paramTypes[i] = paramType
+		paramNames[i] = paramAST.Name.Value
 	}
-	retType := types.I32 // Assuming i32 return for now
+	var retType types.Type = types.I32 // Block bodies return i32 for now
 
 	funcParams := make([]*ir.Param, len(paramNames))
 	for i, pName := range paramNames {
@@ -30,6 +34,7 @@ func (cg *CodeGenerator) VisitLambdaExpression(le *ast.LambdaExpression) error {
 
 	irFunc := cg.Module.NewFunc(fnName, retType, funcParams...)
 	irFunc.Linkage = enum.LinkageInternal
+	cg.signatures[irFunc] = &funcSignature{Name: fnName, Params: le.Parameters, Token: le.Token}
 
 	fmt.Printf("[DEBUG] Lambda '%s': Created Func. Checking Sig(): %s\n", fnName, irFunc.Sig.String())
 	fmt.Printf("[DEBUG] Lambda '%s': Func.Params field has %d entries.\n", fnName, len(irFunc.Params))
@@ -37,8 +42,11 @@ func (cg *CodeGenerator) VisitLambdaExpression(le *ast.LambdaExpression) error {
 	oldBlock := cg.Block
 	oldFunc := cg.currentFunc
 	oldVars := cg.Variables
+	oldGen := cg.gen
 	lambdaScopeVars := make(map[string]value.Value)
 	cg.Variables = lambdaScopeVars
+	// A lambda in a generator body is an ordinary function.
+	cg.gen = nil
 
 	// lambda context
 	entry := irFunc.NewBlock("entry")
@@ -51,6 +59,7 @@ func (cg *CodeGenerator) VisitLambdaExpression(le *ast.LambdaExpression) error {
 		cg.Variables = oldVars
 		cg.Block = oldBlock
 		cg.currentFunc = oldFunc
+		cg.gen = oldGen
 		return fmt.Errorf("internal error: parameter count mismatch for lambda %s", fnName)
 	}
 	for _, param := range irFunc.Params {
@@ -71,6 +80,12 @@ func (cg *CodeGenerator) VisitLambdaExpression(le *ast.LambdaExpression) error {
 
 	if cg.Block != nil && cg.Block.Term == nil {
 		if bodyErr == nil {
+			if _, isBlock := le.Body.(*ast.BlockStatement); !isBlock && cg.lastValue != nil && isFirstClass(cg.lastValue.Type()) {
+				// An expression body determines the return type, so
+				// (x: i64) -> x * x returns i64 and (x) -> x > 2 returns bool.
+				retType = cg.lastValue.Type()
+				irFunc.Sig.RetType = retType
+			}
 			if cg.lastValue != nil && cg.lastValue.Type().Equal(retType) {
 				cg.Block.NewRet(cg.lastValue)
 				fmt.Printf("[DEBUG] Lambda '%s': Added implicit return from last expression value\n", fnName)
@@ -92,6 +107,7 @@ func (cg *CodeGenerator) VisitLambdaExpression(le *ast.LambdaExpression) error {
 	cg.Block = oldBlock
 	cg.currentFunc = oldFunc
 	cg.Variables = oldVars
+	cg.gen = oldGen
 
 	if bodyErr != nil {
 		return fmt.Errorf("error generating body for lambda '%s': %w", fnName, bodyErr)
@@ -102,6 +118,16 @@ func (cg *CodeGenerator) VisitLambdaExpression(le *ast.LambdaExpression) error {
 	return nil
 }
 
+// isFirstClass reports whether t can be returned from a lambda: an integer,
+// float, pointer or struct value.
+func isFirstClass(t types.Type) bool {
+	switch t.(type) {
+	case *types.IntType, *types.FloatType, *types.PointerType, *types.StructType:
+		return true
+	}
+	return false
+}
+
 func (cg *CodeGenerator) newLambdaName() string {
 	name := fmt.Sprintf("lambda_%d", lambdaCount)
 	lambdaCount++
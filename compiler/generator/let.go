@@ -2,12 +2,17 @@ package generator
 
 import (
 	"compiler/ast"
+	"fmt"
 	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/types"
 	"github.com/llir/llvm/ir/value"
 )
 
 func (cg *CodeGenerator) VisitLetStatement(ls *ast.LetStatement) error {
+	if ls.IsConst() {
+		return cg.defineLocalConst(ls)
+	}
+
 	// We'll pick a default initializer type/value if there's no explicit one.
 	var allocaType types.Type = types.I32
 	var initValue value.Value = constant.NewInt(types.I32, 0)
@@ -23,6 +28,19 @@ func (cg *CodeGenerator) VisitLetStatement(ls *ast.LetStatement) error {
 		}
 		initValue = cg.lastValue
 
+		if ls.Type != nil {
+			// Explicit annotation: convert the initializer to the declared type.
+			declType, err := cg.mapType(ls.Type.Value)
+			if err != nil {
+				return fmt.Errorf("let '%s': %w", ls.Name.Value, err)
+			}
+			converted, err := cg.convertValue(initValue, declType)
+			if err != nil {
+				return fmt.Errorf("let '%s': %w", ls.Name.Value, err)
+			}
+			initValue = converted
+		}
+
 		valType := initValue.Type()
 		switch t := valType.(type) {
 		case *types.FuncType:
@@ -44,8 +62,15 @@ func (cg *CodeGenerator) VisitLetStatement(ls *ast.LetStatement) error {
 	}
 
 	// Allocate space for the variable (a stack allocation).
-	allocaInst := cg.Block.NewAlloca(allocaType)
+	allocaInst := cg.newLocalAlloca(allocaType)
 	cg.setVar(ls.Name.Value, allocaInst)
+	if cg.Types != nil {
+		cg.Types[ls] = allocaType
+	}
+	if sig, ok := cg.signatures[initValue]; ok {
+		// Calls through the variable can still use named and default arguments.
+		cg.signatures[allocaInst] = sig
+	}
 
 	// Store the initializer value if we had one.
 	if ls.Value != nil {
@@ -53,3 +78,26 @@ func (cg *CodeGenerator) VisitLetStatement(ls *ast.LetStatement) error {
 	}
 	return nil
 }
+
+// defineLocalConst folds a const declared inside a function body and binds the
+// name directly to the constant, so no stack slot is allocated for it.
+func (cg *CodeGenerator) defineLocalConst(ls *ast.LetStatement) error {
+	c, err := cg.evalConst(ls.Value)
+	if err != nil {
+		return fmt.Errorf("const '%s': %w", ls.Name.Value, err)
+	}
+	if ls.Type != nil {
+		declType, err := cg.mapType(ls.Type.Value)
+		if err != nil {
+			return fmt.Errorf("const '%s': %w", ls.Name.Value, err)
+		}
+		if c, err = convertConst(c, declType); err != nil {
+			return fmt.Errorf("const '%s': %w", ls.Name.Value, err)
+		}
+	}
+	cg.setVar(ls.Name.Value, c)
+	if cg.Types != nil {
+		cg.Types[ls] = c.Type()
+	}
+	return nil
+}
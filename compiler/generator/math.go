@@ -0,0 +1,153 @@
+package generator
+
+import (
+	"fmt"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// floatIntrinsics maps the floating point intrinsics of stdlib/math to the
+// LLVM intrinsics they lower to, and records whether the back end may turn
+// them into calls to libm. Those are unavailable to freestanding programs,
+// which use the software versions in stdlib/math instead; builtin.libm()
+// tells the two apart.
+var floatIntrinsics = map[string]struct {
+	llvm string
+	libm bool
+}{
+	"sqrt":  {"llvm.sqrt", false},
+	"fabs":  {"llvm.fabs", false},
+	"floor": {"llvm.floor", true},
+	"ceil":  {"llvm.ceil", true},
+	"trunc": {"llvm.trunc", true},
+	"exp":   {"llvm.exp", true},
+	"log":   {"llvm.log", true},
+	"sin":   {"llvm.sin", true},
+	"cos":   {"llvm.cos", true},
+	"pow":   {"llvm.pow", true},
+}
+
+// overflowIntrinsics maps the checked arithmetic intrinsics to the LLVM
+// operation reporting signed overflow.
+var overflowIntrinsics = map[string]string{
+	"addOverflow": "sadd",
+	"subOverflow": "ssub",
+	"mulOverflow": "smul",
+}
+
+// The math intrinsics are
+//
+//	builtin.sqrt(x)               llvm.sqrt, likewise fabs, floor, ceil, trunc, exp, log, sin and cos
+//	builtin.pow(x, y)             llvm.pow
+//	builtin.libm()                true when libm is linked, i.e. the program is not freestanding
+//	builtin.addOverflow(a, b, p)  stores a + b to *p and returns whether it overflowed; also sub and mul
+//	builtin.floatBits(x)          the bits of a float as an integer of the same width
+//	builtin.floatFromBits(b)      the float with the bits of b, an i32 or i64
+//
+// Float operands keep their type, f32 or f64; integers are converted to f64.
+
+// visitLibm lowers builtin.libm() to a constant, so that a condition on it
+// selects its branch at compile time.
+func (cg *CodeGenerator) visitLibm(name string, args []value.Value) error {
+	cg.lastValue = constant.NewBool(!cg.Freestanding)
+	return nil
+}
+
+// visitFloatIntrinsic lowers a floating point intrinsic to the LLVM
+// intrinsic floatIntrinsics maps it to.
+func (cg *CodeGenerator) visitFloatIntrinsic(name string, args []value.Value) error {
+	fi := floatIntrinsics[name]
+	if fi.libm && cg.Freestanding {
+		return fmt.Errorf("%s.%s needs libm, which freestanding programs do not link; use stdlib/math", builtinModule, name)
+	}
+	var t *types.FloatType = types.Double
+	if ft, ok := args[0].Type().(*types.FloatType); ok && ft.Kind == types.FloatKindFloat {
+		t = types.Float
+	}
+	operands := make([]value.Value, len(args))
+	params := make([]types.Type, len(args))
+	for i, arg := range args {
+		v, err := cg.convertValue(arg, t)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", builtinModule, name, err)
+		}
+		operands[i], params[i] = v, t
+	}
+	fn := cg.llvmIntrinsic(fi.llvm+"."+floatSuffix(t), t, params...)
+	cg.lastValue = cg.Block.NewCall(fn, operands...)
+	return nil
+}
+
+// visitOverflow lowers builtin.<op>Overflow(a, b, p) to
+// llvm.<op>.with.overflow on the integer type p points to.
+func (cg *CodeGenerator) visitOverflow(name string, args []value.Value) error {
+	qualified := builtinModule + "." + name
+	ptrType, ok := args[2].Type().(*types.PointerType)
+	if !ok {
+		return fmt.Errorf("%s: third argument must point to an integer, got %s", qualified, args[2].Type())
+	}
+	t, ok := ptrType.ElemType.(*types.IntType)
+	if !ok {
+		return fmt.Errorf("%s: third argument must point to an integer, got %s", qualified, ptrType)
+	}
+	a, err := cg.convertValue(args[0], t)
+	if err != nil {
+		return fmt.Errorf("%s: %w", qualified, err)
+	}
+	b, err := cg.convertValue(args[1], t)
+	if err != nil {
+		return fmt.Errorf("%s: %w", qualified, err)
+	}
+	pair := types.NewStruct(t, types.I1)
+	fn := cg.llvmIntrinsic(fmt.Sprintf("llvm.%s.with.overflow.%s", overflowIntrinsics[name], t), pair, t, t)
+	res := cg.Block.NewCall(fn, a, b)
+	cg.Block.NewStore(cg.Block.NewExtractValue(res, 0), args[2])
+	cg.lastValue = cg.Block.NewExtractValue(res, 1)
+	return nil
+}
+
+// visitFloatBits reinterprets a float as an integer of the same width, or
+// the other way round.
+func (cg *CodeGenerator) visitFloatBits(name string, args []value.Value) error {
+	v := args[0]
+	if name == "floatBits" {
+		ft, ok := v.Type().(*types.FloatType)
+		if !ok {
+			return fmt.Errorf("%s.%s: argument must be a float, got %s", builtinModule, name, v.Type())
+		}
+		cg.lastValue = cg.Block.NewBitCast(v, types.NewInt(uint64(floatBits(ft))))
+		return nil
+	}
+	it, ok := v.Type().(*types.IntType)
+	if !ok || (it.BitSize != 32 && it.BitSize != 64) {
+		return fmt.Errorf("%s.%s: argument must be an i32 or i64, got %s", builtinModule, name, v.Type())
+	}
+	var t types.Type = types.Double
+	if it.BitSize == 32 {
+		t = types.Float
+	}
+	cg.lastValue = cg.Block.NewBitCast(v, t)
+	return nil
+}
+
+// llvmIntrinsic returns the declaration of the LLVM intrinsic name, adding
+// it to the module on first use.
+func (cg *CodeGenerator) llvmIntrinsic(name string, ret types.Type, params ...types.Type) *ir.Func {
+	for _, fn := range cg.Module.Funcs {
+		if fn.Name() == name {
+			return fn
+		}
+	}
+	var ps []*ir.Param
+	for i, t := range params {
+		ps = append(ps, ir.NewParam(fmt.Sprintf("p%d", i), t))
+	}
+	return cg.Module.NewFunc(name, ret, ps...)
+}
+
+// floatSuffix returns the type suffix of a float intrinsic, f32 or f64.
+func floatSuffix(t *types.FloatType) string {
+	return fmt.Sprintf("f%d", floatBits(t))
+}
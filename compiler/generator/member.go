@@ -76,8 +76,10 @@ func (cg *CodeGenerator) VisitIndexExpression(ie *ast.IndexExpression) error {
 		}
 	}
 
-	// 2. Evaluate the index expression
+	// 2. Evaluate the index expression (always as a value, even on the LHS)
+	cg.inAssignmentLHS = false
 	err = ie.Index.Accept(cg)
+	cg.inAssignmentLHS = savedLHS
 	if err != nil {
 		return fmt.Errorf("error evaluating index for index expression: %w", err)
 	}
@@ -118,12 +120,19 @@ func (cg *CodeGenerator) VisitIndexExpression(ie *ast.IndexExpression) error {
 	return nil
 }
 
-// very hard coded to arrays for now, next stage is to make this more generic
+// VisitMemberAccessExpression resolves a field of a named struct by the field
+// names recorded when the struct was defined.
 func (cg *CodeGenerator) VisitMemberAccessExpression(mae *ast.MemberAccessExpression) error {
+	// 0. A qualified name such as fs.DT_DIR refers to an imported module
+	if scope := cg.moduleRef(mae.Left); scope != nil {
+		return cg.visitModuleMember(scope, mae)
+	}
+
 	// 1. Evaluate the left expression (the object/struct instance) - get alloca
 	isLHSOuter := cg.inAssignmentLHS
 	cg.inAssignmentLHS = true
 	err := mae.Left.Accept(cg)
+	cg.inAssignmentLHS = isLHSOuter
 
 	if err != nil {
 		return fmt.Errorf("error evaluating base for member access '%s': %w", mae.Member, err)
@@ -161,14 +170,17 @@ func (cg *CodeGenerator) VisitMemberAccessExpression(mae *ast.MemberAccessExpres
 	fieldName := mae.Member
 	fieldIndex := -1
 
-	// TODO needs proper mapping from AST node to LLVM struct layout.
-	// hardcode for Array for now: 0=length, 1=data
-	if structType.Name() == "Array" { // Use Name() which we set
-		switch fieldName.Value {
-		case "length":
-			fieldIndex = 0
-		case "data":
-			fieldIndex = 1
+	for i, name := range cg.structFields[structType.Name()] {
+		if name == fieldName.Value {
+			fieldIndex = i
+			break
+		}
+	}
+
+	if fieldIndex == -1 && structType.Name() == "Any" && !isLHSOuter {
+		if view, ok := cg.anyView(basePtrVal, fieldName.Value); ok {
+			cg.lastValue = view
+			return nil
 		}
 	}
 
@@ -182,7 +194,7 @@ func (cg *CodeGenerator) VisitMemberAccessExpression(mae *ast.MemberAccessExpres
 		constant.NewInt(types.I32, 0),                 // Index for the struct
 		constant.NewInt(types.I32, int64(fieldIndex)), // Index for the field
 	)
-	memberAddr.SetName(fieldName.Value + "_addr")
+	cg.trySetName(memberAddr, fieldName.Value+"_addr")
 
 	// 4. Handle LHS vs RHS context
 	if isLHSOuter { // If the *overall* expression is LHS (e.g., self.length = ...)
@@ -190,7 +202,7 @@ func (cg *CodeGenerator) VisitMemberAccessExpression(mae *ast.MemberAccessExpres
 		fmt.Printf("[DEBUG] MemberAccess '%s' (LHS): GEP -> %s\n", fieldName, memberAddr.Ident())
 	} else { // If RHS (e.g., let x = self.length)
 		loadedVal := cg.Block.NewLoad(structType.Fields[fieldIndex], memberAddr)
-		loadedVal.SetName(fieldName.Value + "_val")
+		cg.trySetName(loadedVal, fieldName.Value+"_val")
 		cg.lastValue = loadedVal // Return the loaded value
 		fmt.Printf("[DEBUG] MemberAccess '%s' (RHS): GEP -> %s, Load -> %s\n", fieldName, memberAddr.Ident(), loadedVal.Ident())
 	}
@@ -5,13 +5,23 @@ import (
 	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/types"
 	"math"
+	"strings"
 )
 
 func (cg *CodeGenerator) VisitNumberLiteral(nl *ast.NumberLiteral) error {
 	f := nl.Value
 	intPart, frac := math.Modf(f)
-	if frac == 0.0 {
-		cg.lastValue = constant.NewInt(types.I32, int64(intPart))
+	if frac == 0.0 && !strings.Contains(nl.Token.Literal, ".") {
+		v, ok := nl.Int()
+		if !ok {
+			v = int64(intPart)
+		}
+		if v > math.MaxInt32 || v < math.MinInt32 {
+			// Too wide for the default int; keep the value intact as i64.
+			cg.lastValue = constant.NewInt(types.I64, v)
+		} else {
+			cg.lastValue = constant.NewInt(types.I32, v)
+		}
 	} else {
 		cg.lastValue = constant.NewFloat(types.Float, f)
 	}
@@ -2,8 +2,8 @@ package generator
 
 import (
 	"compiler/ast"
+	"fmt"
 	"github.com/llir/llvm/ir/constant"
-	"github.com/llir/llvm/ir/enum"
 	"github.com/llir/llvm/ir/types"
 )
 
@@ -13,6 +13,12 @@ func (cg *CodeGenerator) VisitAssignmentStatement(as *ast.AssignmentStatement) e
 }
 
 func (cg *CodeGenerator) VisitPrefixExpression(pe *ast.PrefixExpression) error {
+	switch pe.Operator {
+	case "&":
+		return cg.visitAddressOf(pe)
+	case "*":
+		return cg.visitDereference(pe)
+	}
 	if err := pe.Right.Accept(cg); err != nil {
 		return err
 	}
@@ -22,12 +28,29 @@ func (cg *CodeGenerator) VisitPrefixExpression(pe *ast.PrefixExpression) error {
 	}
 	switch pe.Operator {
 	case "-":
-		// Negate: 0 - operand
-		zero := constant.NewInt(types.I32, 0)
-		cg.lastValue = cg.Block.NewSub(zero, operand)
+		switch t := operand.Type().(type) {
+		case *types.FloatType:
+			cg.lastValue = cg.Block.NewFNeg(operand)
+		case *types.IntType:
+			if c, ok := operand.(*constant.Int); ok {
+				cg.lastValue = constant.NewInt(t, -c.X.Int64())
+			} else {
+				// Negate: 0 - operand
+				cg.lastValue = cg.Block.NewSub(constant.NewInt(t, 0), operand)
+			}
+		default:
+			return fmt.Errorf("cannot negate value of type %s", operand.Type())
+		}
 	case "!":
-		// Logical not: icmp eq operand, 0
-		cg.lastValue = cg.Block.NewICmp(enum.IPredEQ, operand, constant.NewInt(types.I32, 0))
+		// Logical not: flip the operand's truth value
+		cg.lastValue = cg.Block.NewXor(condAsBool(cg.Block, operand), constant.True)
+	case "~":
+		// Bitwise not: xor with all ones
+		t, ok := operand.Type().(*types.IntType)
+		if !ok {
+			return fmt.Errorf("operator '~' cannot be applied to value of type %s", operand.Type())
+		}
+		cg.lastValue = cg.Block.NewXor(operand, constant.NewInt(t, -1))
 	default:
 		cg.lastValue = operand
 	}
@@ -0,0 +1,215 @@
+package generator
+
+import (
+	"compiler/ast"
+	"fmt"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// addressOf evaluates an expression that names storage, such as a variable,
+// a field or an array element, and returns its address.
+func (cg *CodeGenerator) addressOf(expr ast.ExpressionNode) (value.Value, error) {
+	if cg.isConstRef(expr) {
+		return nil, fmt.Errorf("cannot take the address of constant '%s'", expr.String())
+	}
+	saved := cg.inAssignmentLHS
+	cg.inAssignmentLHS = true
+	err := expr.Accept(cg)
+	cg.inAssignmentLHS = saved
+	if err != nil {
+		return nil, err
+	}
+	if cg.lastValue == nil {
+		return nil, fmt.Errorf("'%s' is not addressable", expr.String())
+	}
+	if _, ok := cg.lastValue.Type().(*types.PointerType); !ok {
+		return nil, fmt.Errorf("'%s' is not addressable", expr.String())
+	}
+	return cg.lastValue, nil
+}
+
+// visitAddressOf lowers &x to the address of x.
+func (cg *CodeGenerator) visitAddressOf(pe *ast.PrefixExpression) error {
+	addr, err := cg.addressOf(pe.Right)
+	if err != nil {
+		return err
+	}
+	cg.lastValue = addr
+	return nil
+}
+
+// visitDereference lowers *p: a typed load, or on the left of an assignment
+// the pointer itself, so that *p = v stores through it.
+func (cg *CodeGenerator) visitDereference(pe *ast.PrefixExpression) error {
+	isLHS := cg.inAssignmentLHS
+	cg.inAssignmentLHS = false
+	err := pe.Right.Accept(cg)
+	cg.inAssignmentLHS = isLHS
+	if err != nil {
+		return err
+	}
+	ptr := cg.lastValue
+	if ptr == nil {
+		return fmt.Errorf("cannot dereference '%s': it has no value", pe.Right.String())
+	}
+	ptrType, ok := ptr.Type().(*types.PointerType)
+	if !ok {
+		return fmt.Errorf("cannot dereference '%s' of non-pointer type %s", pe.Right.String(), ptr.Type())
+	}
+	if isLHS {
+		cg.lastValue = ptr
+		return nil
+	}
+	cg.lastValue = cg.Block.NewLoad(ptrType.ElemType, ptr)
+	return nil
+}
+
+// VisitCastExpression converts a value with `as`. Integers are truncated or
+// widened, with zero extension when the target type is unsigned (u8..u64)
+// and sign extension otherwise; integers and pointers convert both ways and
+// pointers are reinterpreted as other pointer types.
+func (cg *CodeGenerator) VisitCastExpression(ce *ast.CastExpression) error {
+	target, err := cg.mapType(ce.Type.Value)
+	if err != nil {
+		return fmt.Errorf("cast to '%s': %w", ce.Type.Value, err)
+	}
+	if err := ce.Value.Accept(cg); err != nil {
+		return err
+	}
+	v := cg.lastValue
+	if v == nil {
+		return fmt.Errorf("cast of '%s': expression produced no value", ce.Value.String())
+	}
+
+	if unsignedTypeName(ce.Type.Value) {
+		dst, dstIsInt := target.(*types.IntType)
+		switch src := v.Type().(type) {
+		case *types.IntType:
+			if dstIsInt && src.BitSize < dst.BitSize {
+				if c, ok := v.(*constant.Int); ok {
+					cg.lastValue = zextConst(c, dst)
+				} else {
+					cg.lastValue = cg.Block.NewZExt(v, dst)
+				}
+				return nil
+			}
+		case *types.FloatType:
+			if dstIsInt {
+				cg.lastValue = cg.Block.NewFPToUI(v, dst)
+				return nil
+			}
+		}
+	}
+	if dst, toPtr := target.(*types.PointerType); toPtr {
+		switch src := v.Type().(type) {
+		case *types.IntType:
+			// Addresses are 64 bits wide on every target.
+			if src.BitSize < 64 {
+				v = cg.Block.NewSExt(v, types.I64)
+			}
+		case *types.PointerType:
+			// An explicit cast reinterprets what the pointer points at.
+			if !src.Equal(dst) {
+				cg.lastValue = cg.Block.NewBitCast(v, dst)
+				return nil
+			}
+		}
+	}
+
+	converted, err := cg.convertValue(v, target)
+	if err != nil {
+		return fmt.Errorf("cannot cast '%s' to %s: %w", ce.Value.String(), ce.Type.Value, err)
+	}
+	cg.lastValue = converted
+	return nil
+}
+
+// zextConst widens an integer constant, reading its bits as unsigned.
+func zextConst(c *constant.Int, dst *types.IntType) *constant.Int {
+	x := c.X.Int64()
+	if c.Typ.BitSize < 64 {
+		x &= int64(1)<<c.Typ.BitSize - 1
+	}
+	return constant.NewInt(dst, x)
+}
+
+// unsignedTypeName reports whether a type name denotes an unsigned integer.
+func unsignedTypeName(name string) bool {
+	switch name {
+	case "u8", "u16", "u32", "u64", "byte":
+		return true
+	}
+	return false
+}
+
+// sizeOf returns the allocation size of t in bytes as an i64 constant,
+// computed by LLVM from the data layout (the offset of element 1 of a t
+// array starting at null).
+func sizeOf(t types.Type) constant.Constant {
+	null := constant.NewNull(types.NewPointer(t))
+	end := constant.NewGetElementPtr(t, null, constant.NewInt(types.I32, 1))
+	return constant.NewPtrToInt(end, types.I64)
+}
+
+// pointerInfix handles the operators with a pointer operand. Adding or
+// subtracting an integer moves the pointer by that many elements; the
+// difference of two pointers counts elements; comparisons compare addresses.
+// It reports false when neither operand is a pointer.
+func (cg *CodeGenerator) pointerInfix(op string, l, r value.Value) (value.Value, bool, error) {
+	lPtr, lIsPtr := l.Type().(*types.PointerType)
+	rPtr, rIsPtr := r.Type().(*types.PointerType)
+	if !lIsPtr && !rIsPtr {
+		return nil, false, nil
+	}
+	_, lIsInt := l.Type().(*types.IntType)
+	_, rIsInt := r.Type().(*types.IntType)
+
+	switch op {
+	case "+":
+		if lIsPtr && rIsInt {
+			return cg.Block.NewGetElementPtr(lPtr.ElemType, l, cg.toI64(r)), true, nil
+		}
+		if lIsInt && rIsPtr {
+			return cg.Block.NewGetElementPtr(rPtr.ElemType, r, cg.toI64(l)), true, nil
+		}
+	case "-":
+		if lIsPtr && rIsInt {
+			offset := cg.Block.NewSub(constant.NewInt(types.I64, 0), cg.toI64(r))
+			return cg.Block.NewGetElementPtr(lPtr.ElemType, l, offset), true, nil
+		}
+		if lIsPtr && rIsPtr {
+			if !lPtr.Equal(rPtr) {
+				return nil, true, fmt.Errorf("cannot subtract %s from %s", r.Type(), l.Type())
+			}
+			diff := cg.Block.NewSub(cg.Block.NewPtrToInt(l, types.I64), cg.Block.NewPtrToInt(r, types.I64))
+			return cg.Block.NewSDiv(diff, sizeOf(lPtr.ElemType)), true, nil
+		}
+	case "==", "!=", "<", ">", "<=", ">=":
+		if !(lIsPtr || lIsInt) || !(rIsPtr || rIsInt) {
+			break
+		}
+		preds := map[string]enum.IPred{
+			"==": enum.IPredEQ, "!=": enum.IPredNE,
+			"<": enum.IPredULT, ">": enum.IPredUGT,
+			"<=": enum.IPredULE, ">=": enum.IPredUGE,
+		}
+		return cg.Block.NewICmp(preds[op], cg.toI64(l), cg.toI64(r)), true, nil
+	}
+	return nil, true, fmt.Errorf("operator '%s' is not defined for %s and %s", op, l.Type(), r.Type())
+}
+
+// toI64 widens an integer to i64, or converts a pointer to its address.
+func (cg *CodeGenerator) toI64(v value.Value) value.Value {
+	switch t := v.Type().(type) {
+	case *types.PointerType:
+		return cg.Block.NewPtrToInt(v, types.I64)
+	case *types.IntType:
+		if t.BitSize < 64 {
+			return widenInt(cg.Block, v, types.I64)
+		}
+	}
+	return v
+}
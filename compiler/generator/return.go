@@ -7,12 +7,24 @@ import (
 )
 
 func (cg *CodeGenerator) VisitReturnStatement(rs *ast.ReturnStatement) error {
+	if cg.gen != nil {
+		return cg.returnFromGenerator(rs)
+	}
 	if rs.ReturnValue != nil {
 		if err := rs.ReturnValue.Accept(cg); err != nil {
 			return err
 		}
 		if cg.lastValue != nil {
-			cg.Block.NewRet(cg.lastValue)
+			retVal := cg.lastValue
+			if err := cg.checkSeqReturn(retVal); err != nil {
+				return err
+			}
+			if retType := cg.currentFunc.Sig.RetType; !retType.Equal(types.Void) {
+				if converted, err := cg.convertValue(retVal, retType); err == nil {
+					retVal = converted
+				}
+			}
+			cg.Block.NewRet(retVal)
 			return nil
 		}
 	}
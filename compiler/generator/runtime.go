@@ -0,0 +1,186 @@
+package generator
+
+import (
+	"compiler/compiler/target"
+	"fmt"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// processArgs holds the globals recording the command line and environment
+// of the running program, read by builtin.args() and builtin.environ().
+type processArgs struct {
+	args    *ir.Global // %Slice.string {argc, argv}
+	environ *ir.Global // i8** envp, null-terminated
+}
+
+// processArgs returns the argument globals, creating them on first use. In a
+// hosted program they are filled by a constructor that the C runtime calls
+// with argc, argv and envp before main (as glibc does for .init_array
+// entries); freestanding programs fill them in _start.
+func (cg *CodeGenerator) processArgs() (*processArgs, error) {
+	if cg.procArgs != nil {
+		return cg.procArgs, nil
+	}
+	sliceType, err := cg.sliceType("string")
+	if err != nil {
+		return nil, err
+	}
+	strPtrPtr := types.NewPointer(types.NewPointer(types.I8))
+
+	pa := &processArgs{
+		args:    cg.Module.NewGlobalDef("__ylang_args", constant.NewZeroInitializer(sliceType)),
+		environ: cg.Module.NewGlobalDef("__ylang_environ", constant.NewNull(strPtrPtr)),
+	}
+	pa.args.Linkage = enum.LinkageInternal
+	pa.environ.Linkage = enum.LinkageInternal
+	cg.procArgs = pa
+
+	if !cg.Freestanding {
+		argc := ir.NewParam("argc", types.I32)
+		argv := ir.NewParam("argv", strPtrPtr)
+		envp := ir.NewParam("envp", strPtrPtr)
+		init := cg.Module.NewFunc("__ylang_init", types.Void, argc, argv, envp)
+		init.Linkage = enum.LinkageInternal
+		entry := init.NewBlock("entry")
+		pa.store(entry, argc, argv, envp)
+		entry.NewRet(nil)
+		cg.addConstructor(init)
+	}
+	return pa, nil
+}
+
+// store records argc, argv and envp in the argument globals.
+func (pa *processArgs) store(block *ir.Block, argc, argv, envp value.Value) {
+	sliceType := pa.args.ContentType
+	zero := constant.NewInt(types.I32, 0)
+	block.NewStore(argc, block.NewGetElementPtr(sliceType, pa.args, zero, zero))
+	block.NewStore(argv, block.NewGetElementPtr(sliceType, pa.args, zero, constant.NewInt(types.I32, 1)))
+	block.NewStore(envp, pa.environ)
+}
+
+// addConstructor registers fn in @llvm.global_ctors. The C runtime calls it
+// with (argc, argv, envp), so its real signature is cast to void ().
+func (cg *CodeGenerator) addConstructor(fn *ir.Func) {
+	ctorFn := types.NewPointer(types.NewFunc(types.Void))
+	entryType := types.NewStruct(types.I32, ctorFn, types.NewPointer(types.I8))
+	entry := constant.NewStruct(entryType,
+		constant.NewInt(types.I32, 65535),
+		constant.NewBitCast(fn, ctorFn),
+		constant.NewNull(types.NewPointer(types.I8)))
+	ctors := cg.Module.NewGlobalDef("llvm.global_ctors", constant.NewArray(types.NewArray(1, entryType), entry))
+	ctors.Linkage = enum.LinkageAppending
+}
+
+// addDestructor registers fn in @llvm.global_dtors, which the C runtime runs
+// when main returns or exit is called.
+func (cg *CodeGenerator) addDestructor(fn *ir.Func) {
+	dtorFn := types.NewPointer(types.NewFunc(types.Void))
+	entryType := types.NewStruct(types.I32, dtorFn, types.NewPointer(types.I8))
+	entry := constant.NewStruct(entryType,
+		constant.NewInt(types.I32, 65535),
+		fn,
+		constant.NewNull(types.NewPointer(types.I8)))
+	dtors := cg.Module.NewGlobalDef("llvm.global_dtors", constant.NewArray(types.NewArray(1, entryType), entry))
+	dtors.Linkage = enum.LinkageAppending
+}
+
+// finiFunc returns __ylang_fini, which runs the module finalizers, declaring
+// it on first use. Its body is emitted by defineFini once every module has
+// been compiled.
+func (cg *CodeGenerator) finiFunc() *ir.Func {
+	if cg.finiFn == nil {
+		cg.finiFn = cg.Module.NewFunc("__ylang_fini", types.Void)
+		cg.finiFn.Linkage = enum.LinkageInternal
+	}
+	return cg.finiFn
+}
+
+// defineFini emits the body of __ylang_fini, calling the fini function of
+// each imported module, last imported first, so a module is finalized before
+// the modules it uses. Hosted programs run it from the C runtime's exit path;
+// freestanding programs from _start. Nothing is emitted when no module has a
+// finalizer and nothing asked for the exit path.
+func (cg *CodeGenerator) defineFini() {
+	if len(cg.finalizers) == 0 && cg.finiFn == nil {
+		return
+	}
+	fini := cg.finiFunc()
+	entry := fini.NewBlock("entry")
+	for i := len(cg.finalizers) - 1; i >= 0; i-- {
+		entry.NewCall(cg.finalizers[i])
+	}
+	entry.NewRet(nil)
+	if !cg.Freestanding {
+		cg.addDestructor(fini)
+	}
+}
+
+// exitProcess runs the module finalizers and ends the process with status
+// through exit_group. Code compiled before every module is known (such as
+// an imported module's exit function) passes always, as later imports may
+// still add finalizers.
+func (cg *CodeGenerator) exitProcess(block *ir.Block, status value.Value, always bool) {
+	if always || len(cg.finalizers) > 0 || cg.finiFn != nil {
+		block.NewCall(cg.finiFunc())
+	}
+	abi := cg.target.Syscall
+	exitAsm := makeSyscallInlineAsm(abi, types.I64, types.I64)
+	block.NewCall(exitAsm, constant.NewInt(types.I64, abi.Numbers["exit_group"]), status)
+}
+
+// defineStart emits the entry point of a freestanding program: a naked _start
+// that hands the initial stack pointer to __ylang_start, which records argc,
+// argv and envp, sets up thread-local storage if the program has any, calls
+// main, runs the module finalizers and exits with main's
+// result through exit_group.
+func (cg *CodeGenerator) defineStart() error {
+	mainFn, ok := cg.scope.functions["main"]
+	if !ok {
+		return fmt.Errorf("freestanding program has no main function")
+	}
+	pa, err := cg.processArgs()
+	if err != nil {
+		return err
+	}
+
+	// __ylang_start(i64* sp): sp[0] is argc, argv follows, then a null and envp.
+	sp := ir.NewParam("sp", types.NewPointer(types.I64))
+	start := cg.Module.NewFunc(target.StartFunc, types.Void, sp)
+	entry := start.NewBlock("entry")
+	argc := entry.NewLoad(types.I64, sp)
+	argvAddr := entry.NewGetElementPtr(types.I64, sp, constant.NewInt(types.I64, 1))
+	strPtrPtr := types.NewPointer(types.NewPointer(types.I8))
+	argv := entry.NewBitCast(argvAddr, strPtrPtr)
+	envpIndex := entry.NewAdd(argc, constant.NewInt(types.I64, 1))
+	envp := entry.NewGetElementPtr(types.NewPointer(types.I8), argv, envpIndex)
+	pa.store(entry, entry.NewTrunc(argc, types.I32), argv, envp)
+	if cg.usesTLS {
+		cg.setupMainTLS(entry)
+	}
+
+	var status value.Value = constant.NewInt(types.I64, 0)
+	result := entry.NewCall(mainFn)
+	if retType, isInt := mainFn.Sig.RetType.(*types.IntType); isInt {
+		if retType.BitSize < 64 {
+			status = entry.NewSExt(result, types.I64)
+		} else {
+			status = result
+		}
+	}
+	cg.exitProcess(entry, status, false)
+	entry.NewUnreachable()
+
+	// _start has no prologue: the stack pointer must still point at argc.
+	startAsm := ir.NewInlineAsm(types.NewPointer(types.NewFunc(types.Void)), cg.target.StartAsm, "")
+	startAsm.SideEffect = true
+	naked := cg.Module.NewFunc("_start", types.Void)
+	naked.FuncAttrs = append(naked.FuncAttrs, enum.FuncAttrNaked, enum.FuncAttrNoReturn)
+	nakedEntry := naked.NewBlock("entry")
+	nakedEntry.NewCall(startAsm)
+	nakedEntry.NewUnreachable()
+	return nil
+}
@@ -0,0 +1,442 @@
+package generator
+
+import (
+	"compiler/ast"
+	"fmt"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"strings"
+)
+
+// A seq<T> is a pointer to a %Seq.T header { i1 (i8*)* resume, T current }
+// at the start of a larger frame. Calling resume with the frame produces the
+// next value in current and returns true, or returns false at the end.
+// Generators and the lazy adapters (array.lazy(), seq.map, seq.filter and
+// seq.take) all have such frames, which live on the stack of the function
+// creating them, so a sequence must not outlive that function: seqOnStack
+// finds those returned or passed to a function that keeps them.
+
+// seqTypeNames turns an LLVM type into a fragment usable in a type name.
+var seqTypeNames = strings.NewReplacer("%", "", "*", ".ptr", "\"", "", " ", "", "{", "", "}", "", ",", ".", "(", "", ")", "")
+
+// seqType returns the header struct of seq<T> for the element type elem.
+func (cg *CodeGenerator) seqType(elem types.Type) *types.StructType {
+	key := elem.String()
+	if st, ok := cg.seqTypes[key]; ok {
+		return st
+	}
+	st := types.NewStruct(seqResumeType, elem)
+	cg.Module.NewTypeDef("Seq."+seqTypeNames.Replace(key), st)
+	cg.seqTypes[key] = st
+	return st
+}
+
+// seqElem returns the element type of t if t is a seq<T>.
+func (cg *CodeGenerator) seqElem(t types.Type) (types.Type, bool) {
+	ptr, ok := t.(*types.PointerType)
+	if !ok {
+		return nil, false
+	}
+	st, ok := ptr.ElemType.(*types.StructType)
+	if !ok || len(st.Fields) != 2 {
+		return nil, false
+	}
+	if cg.seqTypes[st.Fields[seqCurrentField].String()] != st {
+		return nil, false
+	}
+	return st.Fields[seqCurrentField], true
+}
+
+// seqOnStack reports whether the seq v has its frame on the stack of the
+// function being generated: it comes from a generator call or a lazy
+// adapter in this function, directly, through a local variable or through
+// a function returning its argument.
+func (cg *CodeGenerator) seqOnStack(v value.Value) bool {
+	return cg.seqOnStackSeen(v, make(map[value.Value]bool))
+}
+
+func (cg *CodeGenerator) seqOnStackSeen(v value.Value, seen map[value.Value]bool) bool {
+	if seen[v] {
+		return false
+	}
+	seen[v] = true
+	switch inst := v.(type) {
+	case *ir.InstCall:
+		if _, isGen := cg.generators[inst.Callee]; isGen {
+			return true
+		}
+		// A function may return a seq it was passed, e.g. id(count(n)).
+		sig := cg.signatures[inst.Callee]
+		if sig == nil || sig.Def == nil || len(inst.Args) < sig.Offset {
+			return false
+		}
+		for i, arg := range inst.Args[sig.Offset:] {
+			if _, isSeq := cg.seqElem(arg.Type()); isSeq && cg.seqOnStackSeen(arg, seen) && cg.keeper.Returns(sig.Def, i) {
+				return true
+			}
+		}
+	case *ir.InstBitCast:
+		if _, isAlloca := inst.From.(*ir.InstAlloca); isAlloca {
+			return true
+		}
+		return cg.seqOnStackSeen(inst.From, seen)
+	case *ir.InstLoad:
+		slot, isAlloca := inst.Src.(*ir.InstAlloca)
+		if !isAlloca {
+			return false
+		}
+		for _, block := range cg.currentFunc.Blocks {
+			for _, i := range block.Insts {
+				if store, ok := i.(*ir.InstStore); ok && store.Dst == slot && cg.seqOnStackSeen(store.Src, seen) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// checkSeqReturn rejects returning a seq whose frame is on the stack of the
+// function being generated.
+func (cg *CodeGenerator) checkSeqReturn(v value.Value) error {
+	if _, isSeq := cg.seqElem(v.Type()); isSeq && cg.seqOnStack(v) {
+		return fmt.Errorf("cannot return a seq created in this function: its frame lives on the function's stack")
+	}
+	return nil
+}
+
+// checkSeqArguments rejects passing a seq whose frame is on the stack of the
+// function being generated to a function that keeps it, e.g. async.spawn.
+// main and the tests are exempt, as nothing they call outlives them.
+func (cg *CodeGenerator) checkSeqArguments(ce *ast.CallExpression, sig *funcSignature, args []value.Value) error {
+	if cg.gen != nil || sig == nil || sig.Def == nil {
+		return nil
+	}
+	if cur := cg.signatures[cg.currentFunc]; cur == nil || cur.Name == "main" && cur.Scope != nil && cur.Scope.prefix == "" || strings.HasPrefix(cur.Name, "__test_") {
+		return nil
+	}
+	name := sig.Name
+	if mae, ok := ce.Function.(*ast.MemberAccessExpression); ok {
+		if alias, ok := mae.Left.(*ast.Identifier); ok {
+			name = alias.Value + "." + name
+		}
+	}
+	for i, arg := range args {
+		if _, isSeq := cg.seqElem(arg.Type()); isSeq && cg.seqOnStack(arg) && cg.keeper.Keeps(sig.Def, i) {
+			return fmt.Errorf("cannot pass a seq created in this function to '%s', which keeps it: its frame lives on the function's stack", name)
+		}
+	}
+	return nil
+}
+
+// keptCallee resolves the function a call in the body of fn names for
+// keeper, in the scope of the module declaring fn.
+func (cg *CodeGenerator) keptCallee(fn *ast.FunctionDefinition, call *ast.CallExpression) *ast.FunctionDefinition {
+	sig := cg.definitions[fn]
+	if sig == nil {
+		return nil
+	}
+	var callee *ir.Func
+	switch f := call.Function.(type) {
+	case *ast.Identifier:
+		if callee = sig.Scope.functions[f.Value]; callee == nil {
+			callee = cg.Functions[f.Value]
+		}
+	case *ast.MemberAccessExpression:
+		alias, ok := f.Left.(*ast.Identifier)
+		if !ok {
+			return nil
+		}
+		if scope := cg.modules[cg.moduleAliases[alias.Value]]; scope != nil {
+			callee = scope.functions[f.Member.Value]
+		}
+	}
+	if calleeSig := cg.signatures[callee]; calleeSig != nil {
+		return calleeSig.Def
+	}
+	return nil
+}
+
+// isSliceType reports whether st is %Array or the struct of a []T.
+func isSliceType(st *types.StructType) bool {
+	return st.Name() == "Array" || strings.HasPrefix(st.Name(), "Slice.")
+}
+
+// seqNext advances the sequence at handle and returns whether it produced a
+// value.
+func seqNext(block *ir.Block, handle value.Value) value.Value {
+	seq := handle.Type().(*types.PointerType).ElemType
+	resume := block.NewLoad(seqResumeType, frameField(block, seq, handle, seqResumeField))
+	return block.NewCall(resume, block.NewBitCast(handle, types.I8Ptr))
+}
+
+// seqCurrent loads the value the sequence at handle produced last.
+func seqCurrent(block *ir.Block, handle value.Value) value.Value {
+	seq := handle.Type().(*types.PointerType).ElemType.(*types.StructType)
+	return block.NewLoad(seq.Fields[seqCurrentField], frameField(block, seq, handle, seqCurrentField))
+}
+
+func (cg *CodeGenerator) VisitForInStatement(fs *ast.ForInStatement) error {
+	if err := fs.Iterable.Accept(cg); err != nil {
+		return err
+	}
+	iterable := cg.lastValue
+	if iterable == nil {
+		return fmt.Errorf("for loop at line %d: '%s' produced no value", fs.Token.Line+1, fs.Iterable.String())
+	}
+
+	var item *ir.InstAlloca
+	body := func(elem value.Value) error {
+		if item == nil {
+			item = cg.newEntryAlloca(elem.Type())
+			cg.trySetName(item, fs.Variable.Value)
+			cg.setVar(fs.Variable.Value, item)
+		}
+		cg.Block.NewStore(elem, item)
+		return fs.Body.Accept(cg)
+	}
+
+	cg.loopDepth++
+	defer func() { cg.loopDepth-- }()
+	if _, ok := cg.seqElem(iterable.Type()); ok {
+		if err := cg.forEachSeq(iterable, "for", body); err != nil {
+			return err
+		}
+	} else if ptr, ok := iterable.Type().(*types.PointerType); ok && isStruct(ptr.ElemType, isSliceType) {
+		err := cg.forEachElement(iterable, "for", func(index, elem value.Value) error {
+			return body(elem)
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		return fmt.Errorf("for loop at line %d: cannot iterate over '%s' of type %s; expected an array, a slice or a seq", fs.Token.Line+1, fs.Iterable.String(), iterable.Type())
+	}
+	cg.lastValue = constant.NewInt(types.I32, 0)
+	return nil
+}
+
+// isStruct reports whether t is a struct type satisfying pred.
+func isStruct(t types.Type, pred func(*types.StructType) bool) bool {
+	st, ok := t.(*types.StructType)
+	return ok && pred(st)
+}
+
+// forEachSeq emits a loop requesting values from the sequence at handle and
+// calls body with each. The handle lives in a stack slot, so the body may
+// suspend a generator. On return cg.Block is the block after the loop.
+func (cg *CodeGenerator) forEachSeq(handle value.Value, prefix string, body func(elem value.Value) error) error {
+	handleAlloca := cg.newEntryAlloca(handle.Type())
+	cg.Block.NewStore(handle, handleAlloca)
+
+	loopCondBlock := cg.newBlock(prefix + "_loop_cond")
+	loopBodyBlock := cg.newBlock(prefix + "_loop_body")
+	loopEndBlock := cg.newBlock(prefix + "_loop_end")
+	cg.Block.NewBr(loopCondBlock)
+
+	cg.Block = loopCondBlock
+	more := seqNext(cg.Block, cg.Block.NewLoad(handle.Type(), handleAlloca))
+	cg.Block.NewCondBr(more, loopBodyBlock, loopEndBlock)
+
+	cg.Block = loopBodyBlock
+	if err := body(seqCurrent(cg.Block, cg.Block.NewLoad(handle.Type(), handleAlloca))); err != nil {
+		return err
+	}
+	if cg.Block != nil && cg.Block.Term == nil {
+		cg.Block.NewBr(loopCondBlock)
+	}
+
+	cg.Block = loopEndBlock
+	return nil
+}
+
+// generateSeqMethod generates the methods of a seq<T>: map, filter and take
+// return a new lazy sequence and forEach consumes it.
+func (cg *CodeGenerator) generateSeqMethod(handle value.Value, methodName string, args []value.Value) error {
+	switch methodName {
+	case "map", "filter":
+		callbackFnVal, callbackFnSig, err := arrayCallback(methodName, args)
+		if err != nil {
+			return err
+		}
+		if callbackFnSig.RetType.Equal(types.Void) {
+			return fmt.Errorf("%s callback must return a value, got %s", methodName, callbackFnSig)
+		}
+		return cg.newSeqAdapter(methodName, handle, callbackFnVal)
+	case "take":
+		if len(args) != 1 {
+			return fmt.Errorf("seq.take expects exactly 1 argument (count), got %d", len(args))
+		}
+		n, err := cg.convertValue(args[0], types.I64)
+		if err != nil {
+			return fmt.Errorf("seq.take: %w", err)
+		}
+		return cg.newSeqAdapter(methodName, handle, n)
+	case "next", "current":
+		// Step a sequence by hand, e.g. from an event loop driving it.
+		if len(args) != 0 {
+			return fmt.Errorf("seq.%s expects no arguments, got %d", methodName, len(args))
+		}
+		if methodName == "next" {
+			cg.lastValue = seqNext(cg.Block, handle)
+		} else {
+			cg.lastValue = seqCurrent(cg.Block, handle)
+		}
+		return nil
+	case "forEach":
+		callbackFnVal, callbackFnSig, err := arrayCallback(methodName, args)
+		if err != nil {
+			return err
+		}
+		err = cg.forEachSeq(handle, "fe", func(elem value.Value) error {
+			arg, err := cg.convertValue(elem, callbackFnSig.Params[0])
+			if err != nil {
+				return fmt.Errorf("forEach callback: %w", err)
+			}
+			cg.Block.NewCall(callbackFnVal, arg)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		cg.lastValue = handle
+		return nil
+	}
+	return fmt.Errorf("method '%s' not found for seq; expected map, filter, take, forEach, next or current", methodName)
+}
+
+// generateArrayLazy generates array.lazy(), a seq<T> over the elements of an
+// array or slice, so map and filter run element by element.
+func (cg *CodeGenerator) generateArrayLazy(arrayPtr value.Value, args []value.Value) error {
+	if len(args) != 0 {
+		return fmt.Errorf("array.lazy expects no arguments, got %d", len(args))
+	}
+	return cg.newSeqAdapter("lazy", arrayPtr, nil)
+}
+
+// seqAdapter is the resume function and frame type of a kind of lazy
+// sequence for particular source and argument types.
+type seqAdapter struct {
+	resume *ir.Func
+	frame  *types.StructType
+}
+
+// newSeqAdapter allocates the frame of a lazy sequence of the given kind
+// reading from source: { resume, current, source, arg }, where arg is the
+// callback of map and filter, the count of take and the index of lazy.
+func (cg *CodeGenerator) newSeqAdapter(kind string, source, arg value.Value) error {
+	if arg == nil {
+		arg = constant.NewInt(types.I64, 0)
+	}
+	adapter, err := cg.seqAdapter(kind, source.Type(), arg.Type())
+	if err != nil {
+		return err
+	}
+	frame := adapter.frame
+	framePtr := cg.newEntryAlloca(frame)
+	cg.trySetName(framePtr, "seq."+kind)
+	cg.Block.NewStore(adapter.resume, frameField(cg.Block, frame, framePtr, seqResumeField))
+	cg.Block.NewStore(source, frameField(cg.Block, frame, framePtr, 2))
+	cg.Block.NewStore(arg, frameField(cg.Block, frame, framePtr, 3))
+	cg.lastValue = cg.Block.NewBitCast(framePtr, types.NewPointer(cg.seqType(frame.Fields[seqCurrentField])))
+	return nil
+}
+
+// seqAdapter returns the adapter of a kind of lazy sequence, emitting its
+// resume function the first time the kind is used with these types.
+func (cg *CodeGenerator) seqAdapter(kind string, sourceType, argType types.Type) (*seqAdapter, error) {
+	key := kind + " " + sourceType.String() + " " + argType.String()
+	if adapter, ok := cg.seqAdapters[key]; ok {
+		return adapter, nil
+	}
+
+	var elem types.Type
+	if kind == "lazy" {
+		elem = sourceType.(*types.PointerType).ElemType.(*types.StructType).Fields[1].(*types.PointerType).ElemType
+	} else {
+		elem, _ = cg.seqElem(sourceType)
+	}
+	var callback *types.FuncType
+	if kind == "map" || kind == "filter" {
+		callback = argType.(*types.PointerType).ElemType.(*types.FuncType)
+		if kind == "map" {
+			elem = callback.RetType
+		}
+	}
+	frame := types.NewStruct(seqResumeType, elem, sourceType, argType)
+	fn := cg.Module.NewFunc(fmt.Sprintf("seq.%s.%d", kind, len(cg.seqAdapters)), types.I1, ir.NewParam("gen.frame", types.I8Ptr))
+	fn.Linkage = enum.LinkageInternal
+	adapter := &seqAdapter{resume: fn, frame: frame}
+	cg.seqAdapters[key] = adapter
+
+	oldBlock, oldFunc, oldVars, oldGen := cg.Block, cg.currentFunc, cg.Variables, cg.gen
+	defer func() { cg.Block, cg.currentFunc, cg.Variables, cg.gen = oldBlock, oldFunc, oldVars, oldGen }()
+	cg.currentFunc = fn
+	cg.Variables = make(map[string]value.Value)
+	cg.gen = nil
+
+	entry := fn.NewBlock("entry")
+	self := entry.NewBitCast(fn.Params[0], types.NewPointer(frame))
+	self.SetName("self")
+	end := fn.NewBlock("end")
+	end.NewRet(constant.False)
+
+	switch kind {
+	case "lazy":
+		produce := fn.NewBlock("produce")
+		array := entry.NewLoad(sourceType, frameField(entry, frame, self, 2))
+		index := entry.NewLoad(types.I64, frameField(entry, frame, self, 3))
+		arrayType := sourceType.(*types.PointerType).ElemType.(*types.StructType)
+		length := entry.NewSExt(entry.NewLoad(types.I32, frameField(entry, arrayType, array, 0)), types.I64)
+		entry.NewCondBr(entry.NewICmp(enum.IPredSLT, index, length), produce, end)
+
+		data := produce.NewLoad(arrayType.Fields[1], frameField(produce, arrayType, array, 1))
+		produce.NewStore(produce.NewLoad(elem, produce.NewGetElementPtr(elem, data, index)), frameField(produce, frame, self, seqCurrentField))
+		produce.NewStore(produce.NewAdd(index, constant.NewInt(types.I64, 1)), frameField(produce, frame, self, 3))
+		produce.NewRet(constant.True)
+
+	case "take":
+		next := fn.NewBlock("next")
+		produce := fn.NewBlock("produce")
+		left := entry.NewLoad(types.I64, frameField(entry, frame, self, 3))
+		entry.NewCondBr(entry.NewICmp(enum.IPredSGT, left, constant.NewInt(types.I64, 0)), next, end)
+
+		next.NewStore(next.NewSub(left, constant.NewInt(types.I64, 1)), frameField(next, frame, self, 3))
+		source := next.NewLoad(sourceType, frameField(next, frame, self, 2))
+		next.NewCondBr(seqNext(next, source), produce, end)
+
+		produce.NewStore(seqCurrent(produce, source), frameField(produce, frame, self, seqCurrentField))
+		produce.NewRet(constant.True)
+
+	case "map", "filter":
+		// Request values from the source until one is produced: map produces
+		// every value, filter only those the callback accepts.
+		pull := fn.NewBlock("pull")
+		apply := fn.NewBlock("apply")
+		entry.NewBr(pull)
+		source := pull.NewLoad(sourceType, frameField(pull, frame, self, 2))
+		pull.NewCondBr(seqNext(pull, source), apply, end)
+
+		cg.Block = apply
+		v := seqCurrent(cg.Block, source)
+		arg, err := cg.convertValue(v, callback.Params[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s callback: %w", kind, err)
+		}
+		callbackFn := cg.Block.NewLoad(argType, frameField(cg.Block, frame, self, 3))
+		result := cg.Block.NewCall(callbackFn, arg)
+		if kind == "map" {
+			cg.Block.NewStore(result, frameField(cg.Block, frame, self, seqCurrentField))
+			cg.Block.NewRet(constant.True)
+			break
+		}
+		produce := fn.NewBlock("produce")
+		cg.Block.NewCondBr(condAsBool(cg.Block, result), produce, pull)
+		produce.NewStore(v, frameField(produce, frame, self, seqCurrentField))
+		produce.NewRet(constant.True)
+	}
+	return adapter, nil
+}
@@ -3,20 +3,30 @@ package generator
 import (
 	"compiler/ast"
 	"fmt"
+	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/enum"
 	"github.com/llir/llvm/ir/types"
 )
 
 // We'll store string constants globally and return an i8* pointer to them.
-var stringCounter int
-
 func (cg *CodeGenerator) VisitStringLiteral(sl *ast.StringLiteral) error {
-	strName := fmt.Sprintf("str_%d", stringCounter)
-	stringCounter++
+	g, arrType := cg.newStringGlobal(sl.Value)
+
+	zero := constant.NewInt(types.I32, 0)
+	// GEP to get i8* pointer
+	gep := cg.Block.NewGetElementPtr(arrType, g, zero, zero)
+	cg.lastValue = gep
+	return nil
+}
+
+// newStringGlobal creates a private, null-terminated global holding s.
+func (cg *CodeGenerator) newStringGlobal(s string) (*ir.Global, *types.ArrayType) {
+	strName := fmt.Sprintf("str_%d", cg.stringCounter)
+	cg.stringCounter++
 
 	// Null-terminate
-	raw := []byte(sl.Value + "\x00")
+	raw := []byte(s + "\x00")
 	arrType := types.NewArray(uint64(len(raw)), types.I8)
 
 	// Create global definition
@@ -24,12 +34,15 @@ func (cg *CodeGenerator) VisitStringLiteral(sl *ast.StringLiteral) error {
 	// Use Linkage = enum.LinkagePrivate or another suitable value
 	g.Linkage = enum.LinkagePrivate
 	g.Immutable = true
+	return g, arrType
+}
 
+// stringConstant returns a constant i8* to a new global holding s, usable
+// where no basic block is available (e.g. global initializers).
+func (cg *CodeGenerator) stringConstant(s string) constant.Constant {
+	g, arrType := cg.newStringGlobal(s)
 	zero := constant.NewInt(types.I32, 0)
-	// GEP to get i8* pointer
-	gep := cg.Block.NewGetElementPtr(arrType, g, zero, zero)
-	cg.lastValue = gep
-	return nil
+	return constant.NewGetElementPtr(arrType, g, zero, zero)
 }
 
 func bytesToConstants(data []byte) []constant.Constant {
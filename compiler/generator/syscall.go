@@ -2,6 +2,7 @@ package generator
 
 import (
 	"compiler/ast"
+	"compiler/compiler/target"
 	"fmt"
 	"strings"
 
@@ -11,22 +12,40 @@ import (
 	"github.com/llir/llvm/ir/value"
 )
 
-// makeSyscallInlineAsm builds an *ir.InlineAsm for a Linux x86-64 syscall with
-// the provided operand types.  The first element of argTypes is always the
-// syscall number (rax); subsequent elements map to rdi, rsi, rdx, r10, r8, r9.
-// The return value is the i64 result left in rax after the instruction.
-func makeSyscallInlineAsm(argTypes ...types.Type) *ir.InlineAsm {
-	regNames := []string{"{rax}", "{rdi}", "{rsi}", "{rdx}", "{r10}", "{r8}", "{r9}"}
-	parts := []string{"={rax}"}
+// syscallTableName is the name through which programs reach the syscall
+// numbers of the target, e.g. syscall(SYS.openat, ...).
+const syscallTableName = "SYS"
+
+// newSyscallScope exposes a syscall table as a module of i64 constants, so
+// SYS.name folds to a number at compile time like any other constant.
+func newSyscallScope(numbers map[string]int64) *moduleScope {
+	scope := newModuleScope(syscallTableName, "")
+	for name, n := range numbers {
+		scope.consts[name] = constant.NewInt(types.I64, n)
+	}
+	return scope
+}
+
+// makeSyscallInlineAsm builds an *ir.InlineAsm for a Linux syscall on the
+// architecture described by abi, with the provided operand types.  The first
+// element of argTypes is always the syscall number (rax on x86-64, x8 on
+// AArch64, a7 on RISC-V); subsequent elements map to the argument registers in
+// order.  The return value is the i64 result register after the instruction.
+func makeSyscallInlineAsm(abi target.SyscallABI, argTypes ...types.Type) *ir.InlineAsm {
+	regNames := append([]string{abi.Number}, abi.Args...)
+	parts := []string{"={" + abi.Result + "}"}
 	for i := range argTypes {
 		if i < len(regNames) {
-			parts = append(parts, regNames[i])
+			parts = append(parts, "{"+regNames[i]+"}")
 		}
 	}
-	parts = append(parts, "~{rcx}", "~{r11}", "~{memory}")
+	for _, reg := range abi.Clobbers {
+		parts = append(parts, "~{"+reg+"}")
+	}
+	parts = append(parts, "~{memory}")
 
 	funcType := types.NewFunc(types.I64, argTypes...)
-	asm := ir.NewInlineAsm(types.NewPointer(funcType), "syscall", strings.Join(parts, ","))
+	asm := ir.NewInlineAsm(types.NewPointer(funcType), abi.Instruction, strings.Join(parts, ","))
 	asm.SideEffect = true
 	return asm
 }
@@ -79,7 +98,7 @@ func (cg *CodeGenerator) VisitSyscallExpression(se *ast.SyscallExpression) error
 	}
 	allArgs := append([]value.Value{numVal}, argVals...)
 
-	asm := makeSyscallInlineAsm(allArgTypes...)
+	asm := makeSyscallInlineAsm(cg.target.Syscall, allArgTypes...)
 	call := cg.Block.NewCall(asm, allArgs...)
 	cg.lastValue = call
 	return nil
@@ -0,0 +1,78 @@
+target datalayout = "e-m:e-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128"
+target triple = "x86_64-unknown-linux-gnu"
+
+%Array = type { i32, i32* }
+
+@builtin_newline = private constant [1 x i8] [i8 10]
+@str_0 = private constant [2 x i8] [i8 46, i8 0]
+@str_1 = private constant [4 x i8] [i8 111, i8 107, i8 10, i8 0]
+
+declare i8* @malloc(i64 %size)
+
+define void @builtin_print_int(i32 %val) {
+entry:
+	%buf = alloca [21 x i8]
+	%0 = sext i32 %val to i64
+	%1 = icmp slt i64 %0, 0
+	%2 = sub i64 0, %0
+	%3 = select i1 %1, i64 %2, i64 %0
+	br label %digits
+
+digits:
+	%4 = phi i64 [ 21, %entry ], [ %9, %digits ]
+	%5 = phi i64 [ %3, %entry ], [ %11, %digits ]
+	%6 = urem i64 %5, 10
+	%7 = add i64 %6, 48
+	%8 = trunc i64 %7 to i8
+	%9 = sub i64 %4, 1
+	%10 = getelementptr [21 x i8], [21 x i8]* %buf, i64 0, i64 %9
+	store i8 %8, i8* %10
+	%11 = udiv i64 %5, 10
+	%12 = icmp ne i64 %11, 0
+	br i1 %12, label %digits, label %write
+
+write:
+	%13 = sub i64 %9, 1
+	%14 = getelementptr [21 x i8], [21 x i8]* %buf, i64 0, i64 %13
+	store i8 45, i8* %14
+	%15 = select i1 %1, i64 %13, i64 %9
+	%16 = getelementptr [21 x i8], [21 x i8]* %buf, i64 0, i64 %15
+	%17 = sub i64 21, %15
+	%18 = ptrtoint i8* %16 to i64
+	%19 = call i64 asm sideeffect "syscall", "={rax},{rax},{rdi},{rsi},{rdx},~{rcx},~{r11},~{memory}"(i64 1, i64 1, i64 %18, i64 %17)
+	ret void
+}
+
+define void @builtin_print_newline() {
+entry:
+	%0 = getelementptr [1 x i8], [1 x i8]* @builtin_newline, i64 0, i64 0
+	%1 = ptrtoint i8* %0 to i64
+	%2 = call i64 asm sideeffect "syscall", "={rax},{rax},{rdi},{rsi},{rdx},~{rcx},~{r11},~{memory}"(i64 1, i64 1, i64 %1, i64 1)
+	ret void
+}
+
+declare i32* @builtin_array_map()
+
+declare void @builtin_array_forEach()
+
+define i32 @main() {
+entry:
+	%0 = sext i32 -100 to i64
+	%1 = getelementptr [2 x i8], [2 x i8]* @str_0, i32 0, i32 0
+	%2 = ptrtoint i8* %1 to i64
+	%3 = sext i32 0 to i64
+	%4 = sext i32 0 to i64
+	%5 = sext i32 0 to i64
+	%6 = call i64 asm sideeffect "syscall", "={rax},{rax},{rdi},{rsi},{rdx},{r10},{r8},{r9},~{rcx},~{r11},~{memory}"(i64 257, i64 %0, i64 %2, i64 65536, i64 %3, i64 %4, i64 %5)
+	%7 = alloca i64
+	store i64 %6, i64* %7
+	%8 = sext i32 1 to i64
+	%9 = getelementptr [4 x i8], [4 x i8]* @str_1, i32 0, i32 0
+	%10 = ptrtoint i8* %9 to i64
+	%11 = sext i32 3 to i64
+	%12 = call i64 asm sideeffect "syscall", "={rax},{rax},{rdi},{rsi},{rdx},{r10},{r8},{r9},~{rcx},~{r11},~{memory}"(i64 1, i64 %8, i64 %10, i64 %11, i64 0, i64 0, i64 0)
+	call void @builtin_print_int(i32 7)
+	%13 = load i64, i64* %7
+	%14 = call i64 asm sideeffect "syscall", "={rax},{rax},{rdi},{rsi},{rdx},{r10},{r8},{r9},~{rcx},~{r11},~{memory}"(i64 3, i64 %13, i64 0, i64 0, i64 0, i64 0, i64 0)
+	ret i32 0
+}
@@ -0,0 +1,175 @@
+package generator
+
+import (
+	"compiler/ast"
+	"fmt"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// testingModule implements the test harness and the reporting of failed
+// assertions.
+const testingModule = "stdlib/testing"
+
+// pendingTest is a test block of the module under test, with the scope its
+// body resolves names in.
+type pendingTest struct {
+	block *ast.TestBlock
+	scope *moduleScope
+}
+
+// defineTestHarness compiles the collected test blocks, each into a function
+// of its module, and emits a main that hands them to testing.run:
+//
+//	testing.start(); testing.run("name", test); ...; return testing.finish();
+func (cg *CodeGenerator) defineTestHarness() error {
+	fns, err := cg.moduleFuncs(testingModule, "the test harness", "start", "run", "finish")
+	if err != nil {
+		return err
+	}
+	if _, declared := cg.scope.functions["main"]; declared {
+		return fmt.Errorf("the test harness replaces main, which is already declared")
+	}
+
+	tests := make([]*ir.Func, len(cg.tests))
+	root := cg.scope
+	for i, pt := range cg.tests {
+		fn := &ast.FunctionDefinition{
+			Token:      pt.block.Token,
+			Name:       &ast.Identifier{Token: pt.block.Token, Value: fmt.Sprintf("__test_%d", i)},
+			Body:       pt.block.Body,
+			ReturnType: &ast.Identifier{Token: pt.block.Token, Value: "void"},
+		}
+		cg.scope = pt.scope
+		err := cg.declareFunction(fn)
+		if err == nil {
+			err = fn.Accept(cg)
+		}
+		tests[i] = cg.scope.functions[fn.Name.Value]
+		cg.scope = root
+		if err != nil {
+			return fmt.Errorf("error visiting test \"%s\": %w", pt.block.Name.Value, err)
+		}
+	}
+
+	main := cg.Module.NewFunc("main", types.I32)
+	cg.registerFunction("main", main, &funcSignature{Name: "main", Scope: cg.scope})
+	entry := main.NewBlock("entry")
+	entry.NewCall(fns["start"])
+	testType := fns["run"].Sig.Params[1]
+	for i, pt := range cg.tests {
+		var fn value.Value = tests[i]
+		if !fn.Type().Equal(testType) {
+			fn = entry.NewBitCast(fn, testType)
+		}
+		entry.NewCall(fns["run"], cg.stringConst(pt.block.Name.Value), fn)
+	}
+	var status value.Value = entry.NewCall(fns["finish"])
+	if !status.Type().Equal(types.I32) {
+		status = entry.NewTrunc(status, types.I32)
+	}
+	entry.NewRet(status)
+	return nil
+}
+
+// isAssertion reports whether ce calls the assert or assertEq intrinsic,
+// which a program may shadow with a name of its own.
+func (cg *CodeGenerator) isAssertion(ce *ast.CallExpression) bool {
+	ident, ok := ce.Function.(*ast.Identifier)
+	if !ok || (ident.Value != "assert" && ident.Value != "assertEq") {
+		return false
+	}
+	_, isVar := cg.Variables[ident.Value]
+	_, isFunc := cg.Functions[ident.Value]
+	return !isVar && !isFunc && !cg.scope.declares(ident.Value)
+}
+
+// visitAssertion emits assert(cond) or assertEq(got, want). When the check
+// fails, testing.fail or testing.failEq reports the file, the line and the
+// source of the call, and ends the program (or the test) with status 1.
+func (cg *CodeGenerator) visitAssertion(ce *ast.CallExpression) error {
+	name := ce.Function.(*ast.Identifier).Value
+	want := 1
+	if name == "assertEq" {
+		want = 2
+	}
+	if len(ce.Arguments) != want {
+		return fmt.Errorf("%s expects %d argument(s), got %d", name, want, len(ce.Arguments))
+	}
+	fns, err := cg.moduleFuncs(testingModule, name, "fail", "failEq", "equal")
+	if err != nil {
+		return err
+	}
+
+	args := make([]value.Value, len(ce.Arguments))
+	for i, arg := range ce.Arguments {
+		if err := arg.Accept(cg); err != nil {
+			return err
+		}
+		if cg.lastValue == nil {
+			return fmt.Errorf("argument %d of %s has no value", i+1, name)
+		}
+		args[i] = cg.lastValue
+	}
+
+	var ok value.Value
+	if name == "assert" {
+		ok = condAsBool(cg.Block, args[0])
+	} else {
+		for i := range args {
+			if args[i], err = cg.boxAny(args[i]); err != nil {
+				return fmt.Errorf("argument %d of %s: %w", i+1, name, err)
+			}
+		}
+		ok = condAsBool(cg.Block, cg.Block.NewCall(fns["equal"], args[0], args[1]))
+	}
+
+	failBlock := cg.newBlock("assert_fail")
+	doneBlock := cg.newBlock("assert_ok")
+	cg.Block.NewCondBr(ok, doneBlock, failBlock)
+	where := cg.stringConst(fmt.Sprintf("%s:%d", cg.sourceFile(), ce.Token.Line+1))
+	if name == "assert" {
+		failBlock.NewCall(fns["fail"], where, cg.stringConst(unparen(ce.Arguments[0].String())))
+	} else {
+		failBlock.NewCall(fns["failEq"], where, cg.stringConst(ce.String()), args[0], args[1])
+	}
+	failBlock.NewBr(doneBlock)
+
+	cg.Block = doneBlock
+	cg.lastValue = nil
+	return nil
+}
+
+// sourceFile names the file of the module being compiled.
+func (cg *CodeGenerator) sourceFile() string {
+	if cg.scope.path == "" {
+		if cg.SourceFile == "" {
+			return "<input>"
+		}
+		return cg.SourceFile
+	}
+	return cg.scope.file
+}
+
+// unparen drops the parentheses the printed form of an infix expression is
+// wrapped in, so assert(x == 5) is reported as "x == 5".
+func unparen(s string) string {
+	if len(s) < 2 || s[0] != '(' || s[len(s)-1] != ')' {
+		return s
+	}
+	depth := 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 && i != len(s)-1 {
+				// The first parenthesis closes early, as in (a) + (b).
+				return s
+			}
+		}
+	}
+	return s[1 : len(s)-1]
+}
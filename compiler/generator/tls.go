@@ -0,0 +1,313 @@
+package generator
+
+import (
+	"fmt"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// Thread-local variables are ELF TLS: the linker collects their initial
+// values into the PT_TLS segment and addresses them relative to the thread
+// pointer. Every thread needs a TLS area holding a copy of that segment laid
+// out as the target's TLS ABI requires. The C runtime sets one up for the
+// main thread of a hosted program; freestanding programs get one from
+// _start, and stdlib/thread creates one for each thread with
+// builtin.tlsSize() and builtin.tlsInit(area).
+
+// ELF program header fields read to find the PT_TLS segment.
+const (
+	elfPhoff     = 32 // e_phoff in the ELF header
+	elfPhnum     = 56 // e_phnum in the ELF header
+	elfPhdrSize  = 56
+	elfPtLoad    = 1
+	elfPtTLS     = 7
+	phdrOffset   = 8
+	phdrVaddr    = 16
+	phdrFilesz   = 32
+	phdrMemsz    = 40
+	phdrAlign    = 48
+	archSetFS    = 0x1002 // arch_prctl code setting the x86-64 thread pointer
+	minTLSAlign  = 16
+	tlsImageSize = 4 // fields of %__ylang_tls_image
+)
+
+// tlsRuntime holds the functions laying out TLS areas, created on first use.
+type tlsRuntime struct {
+	image *ir.Func // __ylang_tls_image(): {addr, filesz, memsz, align} of PT_TLS
+	size  *ir.Func // __ylang_tls_size(): bytes of a TLS area
+	init  *ir.Func // __ylang_tls_init(area): lays out a zeroed area, returns the thread pointer
+}
+
+// markThreadLocal makes g a thread-local global and notes that the main
+// thread of a freestanding program needs a TLS area. Thread-locals are only
+// reached from the executable itself, so the local-exec model applies.
+func (cg *CodeGenerator) markThreadLocal(g *ir.Global) {
+	g.TLSModel = enum.TLSModelLocalExec
+	cg.usesTLS = true
+}
+
+// tlsFuncs returns the TLS runtime functions, emitting them on first use.
+func (cg *CodeGenerator) tlsFuncs() *tlsRuntime {
+	if cg.tls != nil {
+		return cg.tls
+	}
+	cg.tls = &tlsRuntime{}
+	cg.tls.image = cg.defineTLSImage()
+	cg.tls.size = cg.defineTLSSize()
+	cg.tls.init = cg.defineTLSInit()
+	return cg.tls
+}
+
+// defineTLSImage emits __ylang_tls_image, which walks the program headers
+// found through the linker-defined __ehdr_start and returns the run-time
+// address, file size, memory size and alignment of the PT_TLS segment, all
+// zero but an alignment of 1 when there is none. The load bias is the
+// distance between the ELF header in memory and the address of the segment
+// mapping it, so position-independent executables work too.
+func (cg *CodeGenerator) defineTLSImage() *ir.Func {
+	imageType := types.NewStruct(types.I64, types.I64, types.I64, types.I64)
+	fn := cg.Module.NewFunc("__ylang_tls_image", imageType)
+	fn.Linkage = enum.LinkageInternal
+
+	ehdr := cg.Module.NewGlobal("__ehdr_start", types.I8)
+	ehdr.Linkage = enum.LinkageExternal
+	ehdr.Visibility = enum.VisibilityHidden
+
+	entry := fn.NewBlock("entry")
+	loop := fn.NewBlock("loop")
+	body := fn.NewBlock("phdr")
+	load := fn.NewBlock("load")
+	tls := fn.NewBlock("tls")
+	next := fn.NewBlock("next")
+	done := fn.NewBlock("done")
+
+	i64 := func(v int64) constant.Constant { return constant.NewInt(types.I64, v) }
+	field := func(block *ir.Block, base value.Value, offset int64, t types.Type) value.Value {
+		addr := block.NewGetElementPtr(types.I8, base, i64(offset))
+		return block.NewLoad(t, block.NewBitCast(addr, types.NewPointer(t)))
+	}
+
+	base := entry.NewPtrToInt(ehdr, types.I64)
+	phoff := field(entry, ehdr, elfPhoff, types.I64)
+	phnum := entry.NewZExt(field(entry, ehdr, elfPhnum, types.I16), types.I64)
+	index := entry.NewAlloca(types.I64)
+	bias := entry.NewAlloca(types.I64)
+	vars := make([]*ir.InstAlloca, tlsImageSize) // addr, filesz, memsz, align
+	for i := range vars {
+		vars[i] = entry.NewAlloca(types.I64)
+		entry.NewStore(i64(0), vars[i])
+	}
+	entry.NewStore(i64(1), vars[3])
+	entry.NewStore(i64(0), index)
+	entry.NewStore(i64(0), bias)
+	entry.NewBr(loop)
+
+	i := loop.NewLoad(types.I64, index)
+	loop.NewCondBr(loop.NewICmp(enum.IPredSLT, i, phnum), body, done)
+
+	offset := body.NewAdd(phoff, body.NewMul(i, i64(elfPhdrSize)))
+	phdr := body.NewGetElementPtr(types.I8, ehdr, offset)
+	ptype := field(body, phdr, 0, types.I32)
+	body.NewSwitch(ptype, next,
+		ir.NewCase(constant.NewInt(types.I32, elfPtLoad), load),
+		ir.NewCase(constant.NewInt(types.I32, elfPtTLS), tls))
+
+	// The segment at file offset 0 maps the ELF header.
+	isFirst := load.NewICmp(enum.IPredEQ, field(load, phdr, phdrOffset, types.I64), i64(0))
+	loadBias := load.NewSub(base, field(load, phdr, phdrVaddr, types.I64))
+	load.NewStore(load.NewSelect(isFirst, loadBias, load.NewLoad(types.I64, bias)), bias)
+	load.NewBr(next)
+
+	for j, off := range []int64{phdrVaddr, phdrFilesz, phdrMemsz, phdrAlign} {
+		tls.NewStore(field(tls, phdr, off, types.I64), vars[j])
+	}
+	tls.NewBr(next)
+
+	next.NewStore(next.NewAdd(i, i64(1)), index)
+	next.NewBr(loop)
+
+	addr := done.NewAdd(done.NewLoad(types.I64, vars[0]), done.NewLoad(types.I64, bias))
+	var result value.Value = done.NewInsertValue(constant.NewUndef(imageType), addr, 0)
+	for j := 1; j < tlsImageSize; j++ {
+		result = done.NewInsertValue(result, done.NewLoad(types.I64, vars[j]), uint64(j))
+	}
+	done.NewRet(result)
+	return fn
+}
+
+// tlsLayout computes, from the PT_TLS image, the alignment of a TLS area and
+// the offsets of the TLS block and of the thread pointer from its start.
+func (cg *CodeGenerator) tlsLayout(block *ir.Block, image value.Value) (align, blockOff, tpOff, memsz value.Value) {
+	abi := cg.target.Thread
+	i64 := func(v int64) constant.Constant { return constant.NewInt(types.I64, v) }
+	roundUp := func(v, a value.Value) value.Value {
+		sum := block.NewAdd(v, block.NewSub(a, i64(1)))
+		return block.NewMul(block.NewUDiv(sum, a), a)
+	}
+
+	memsz = block.NewExtractValue(image, 2)
+	// Offsets use the segment's alignment, as the linker does; the area
+	// itself is aligned to at least minTLSAlign.
+	pAlign := block.NewExtractValue(image, 3)
+	imageAlign := block.NewSelect(block.NewICmp(enum.IPredEQ, pAlign, i64(0)), i64(1), pAlign)
+	align = block.NewSelect(block.NewICmp(enum.IPredUGT, imageAlign, i64(minTLSAlign)), imageAlign, i64(minTLSAlign))
+	if abi.TLSAbove {
+		// [TCB][TLS block], the thread pointer at the TCB.
+		blockOff = roundUp(i64(abi.TCBSize), imageAlign)
+		tpOff = i64(0)
+	} else {
+		// [TLS block][TCB], the thread pointer at the TCB.
+		blockOff = i64(0)
+		tpOff = roundUp(memsz, imageAlign)
+	}
+	return align, blockOff, tpOff, memsz
+}
+
+// defineTLSSize emits __ylang_tls_size, the size of a TLS area including
+// room to align its start.
+func (cg *CodeGenerator) defineTLSSize() *ir.Func {
+	fn := cg.Module.NewFunc("__ylang_tls_size", types.I64)
+	fn.Linkage = enum.LinkageInternal
+	entry := fn.NewBlock("entry")
+	image := entry.NewCall(cg.tls.image)
+	align, blockOff, tpOff, memsz := cg.tlsLayout(entry, image)
+	var size value.Value
+	if cg.target.Thread.TLSAbove {
+		size = entry.NewAdd(blockOff, memsz)
+	} else {
+		size = entry.NewAdd(tpOff, constant.NewInt(types.I64, cg.target.Thread.TCBSize))
+	}
+	entry.NewRet(entry.NewAdd(size, align))
+	return fn
+}
+
+// defineTLSInit emits __ylang_tls_init(area), which copies the initialized
+// part of the PT_TLS image into a zeroed area of __ylang_tls_size bytes and
+// returns the thread pointer for it. On variant II the TCB points to itself.
+func (cg *CodeGenerator) defineTLSInit() *ir.Func {
+	i8Ptr := types.NewPointer(types.I8)
+	area := ir.NewParam("area", i8Ptr)
+	fn := cg.Module.NewFunc("__ylang_tls_init", i8Ptr, area)
+	fn.Linkage = enum.LinkageInternal
+	entry := fn.NewBlock("entry")
+	loop := fn.NewBlock("copy")
+	body := fn.NewBlock("byte")
+	done := fn.NewBlock("done")
+	i64 := func(v int64) constant.Constant { return constant.NewInt(types.I64, v) }
+
+	image := entry.NewCall(cg.tls.image)
+	align, blockOff, tpOff, _ := cg.tlsLayout(entry, image)
+	areaAddr := entry.NewPtrToInt(area, types.I64)
+	start := entry.NewMul(entry.NewUDiv(entry.NewAdd(areaAddr, entry.NewSub(align, i64(1))), align), align)
+	dst := entry.NewIntToPtr(entry.NewAdd(start, blockOff), i8Ptr)
+	src := entry.NewIntToPtr(entry.NewExtractValue(image, 0), i8Ptr)
+	filesz := entry.NewExtractValue(image, 1)
+	tp := entry.NewIntToPtr(entry.NewAdd(start, tpOff), i8Ptr)
+	index := entry.NewAlloca(types.I64)
+	entry.NewStore(i64(0), index)
+	entry.NewBr(loop)
+
+	i := loop.NewLoad(types.I64, index)
+	loop.NewCondBr(loop.NewICmp(enum.IPredULT, i, filesz), body, done)
+
+	b := body.NewLoad(types.I8, body.NewGetElementPtr(types.I8, src, i))
+	body.NewStore(b, body.NewGetElementPtr(types.I8, dst, i))
+	body.NewStore(body.NewAdd(i, i64(1)), index)
+	body.NewBr(loop)
+
+	if !cg.target.Thread.TLSAbove {
+		done.NewStore(tp, done.NewBitCast(tp, types.NewPointer(i8Ptr)))
+	}
+	done.NewRet(tp)
+	return fn
+}
+
+// setupMainTLS gives the main thread of a freestanding program its TLS area,
+// mapped with mmap, and points the thread pointer at it.
+func (cg *CodeGenerator) setupMainTLS(block *ir.Block) {
+	rt := cg.tlsFuncs()
+	abi := cg.target.Syscall
+	i64 := func(v int64) constant.Constant { return constant.NewInt(types.I64, v) }
+
+	size := block.NewCall(rt.size)
+	mmapAsm := makeSyscallInlineAsm(abi, types.I64, types.I64, types.I64, types.I64, types.I64, types.I64, types.I64)
+	// PROT_READ|PROT_WRITE, MAP_PRIVATE|MAP_ANONYMOUS
+	addr := block.NewCall(mmapAsm, i64(abi.Numbers["mmap"]), i64(0), size, i64(3), i64(0x22), i64(-1), i64(0))
+	tp := block.NewCall(rt.init, block.NewIntToPtr(addr, types.NewPointer(types.I8)))
+	cg.setThreadPointer(block, tp)
+}
+
+// setThreadPointer points the thread pointer of the calling thread at tp.
+func (cg *CodeGenerator) setThreadPointer(block *ir.Block, tp value.Value) {
+	if asm := cg.target.Thread.SetPointerAsm; asm != "" {
+		set := ir.NewInlineAsm(types.NewPointer(types.NewFunc(types.Void, tp.Type())), asm, "r,~{memory}")
+		set.SideEffect = true
+		block.NewCall(set, tp)
+		return
+	}
+	abi := cg.target.Syscall
+	prctl := makeSyscallInlineAsm(abi, types.I64, types.I64, types.I64)
+	block.NewCall(prctl, constant.NewInt(types.I64, abi.Numbers["arch_prctl"]),
+		constant.NewInt(types.I64, archSetFS), block.NewPtrToInt(tp, types.I64))
+}
+
+// visitThreadStart lowers
+//
+//	builtin.threadStart(flags, stack, ptid, ctid, tls, fn, arg)
+//
+// to a clone syscall whose child runs fn(arg) on stack and then exits. The
+// arguments are passed in clone's order for the target, which differs in
+// where tls goes.
+func (cg *CodeGenerator) visitThreadStart(name string, args []value.Value) error {
+	abi := cg.target.Syscall
+	thread := cg.target.Thread
+	ops := make([]value.Value, 0, 8)
+	ops = append(ops, constant.NewInt(types.I64, abi.Numbers["clone"]))
+	for _, a := range args[:3] {
+		ops = append(ops, coerceToI64(cg.Block, a))
+	}
+	ctid, tls := coerceToI64(cg.Block, args[3]), coerceToI64(cg.Block, args[4])
+	if thread.CloneTLSArg == 3 {
+		ops = append(ops, tls, ctid)
+	} else {
+		ops = append(ops, ctid, tls)
+	}
+	ops = append(ops, coerceToI64(cg.Block, args[5]), coerceToI64(cg.Block, args[6]))
+
+	constraints := "={" + abi.Result + "},{" + abi.Number + "}"
+	for _, reg := range abi.Args[:5] {
+		constraints += ",{" + reg + "}"
+	}
+	constraints += ",{" + thread.StartRegs[0] + "},{" + thread.StartRegs[1] + "}"
+	for _, reg := range abi.Clobbers {
+		constraints += ",~{" + reg + "}"
+	}
+	constraints += ",~{memory}"
+
+	argTypes := make([]types.Type, len(ops))
+	for i := range argTypes {
+		argTypes[i] = types.I64
+	}
+	start := ir.NewInlineAsm(types.NewPointer(types.NewFunc(types.I64, argTypes...)), thread.StartAsm, constraints)
+	start.SideEffect = true
+	cg.lastValue = cg.Block.NewCall(start, ops...)
+	return nil
+}
+
+// visitTLSIntrinsic lowers builtin.tlsSize() and builtin.tlsInit(area).
+func (cg *CodeGenerator) visitTLSIntrinsic(name string, args []value.Value) error {
+	rt := cg.tlsFuncs()
+	if name == "tlsSize" {
+		cg.lastValue = cg.Block.NewCall(rt.size)
+		return nil
+	}
+	area, err := cg.convertValue(args[0], types.NewPointer(types.I8))
+	if err != nil {
+		return fmt.Errorf("%s.%s: %w", builtinModule, name, err)
+	}
+	cg.lastValue = cg.Block.NewCall(rt.init, area)
+	return nil
+}
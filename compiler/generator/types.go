@@ -1,14 +1,41 @@
 package generator
 
 import (
+	"compiler/ast"
 	"fmt"
+	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
 	"strings"
 )
 
 // Ensure mapType can handle pointers and basic types including void.
 // Needs to look up defined struct types from cg.Structs.
 func (cg *CodeGenerator) mapType(typeName string) (types.Type, error) {
+	// Function types '(int,string)->int' are passed around as function pointers.
+	if strings.HasPrefix(typeName, "(") {
+		return cg.mapFuncType(typeName)
+	}
+
+	// Sequences 'seq<T>' are pointers to the header of a generator frame.
+	if strings.HasPrefix(typeName, "seq<") && strings.HasSuffix(typeName, ">") {
+		elemType, err := cg.mapType(typeName[len("seq<") : len(typeName)-1])
+		if err != nil {
+			return nil, err
+		}
+		return types.NewPointer(cg.seqType(elemType)), nil
+	}
+
+	// Slices '[]T' and variadic packs '...T' are pointers to a {length, data} struct.
+	if strings.HasPrefix(typeName, "[]") || strings.HasPrefix(typeName, "...") {
+		elemName := strings.TrimPrefix(strings.TrimPrefix(typeName, "[]"), "...")
+		sliceType, err := cg.sliceType(elemName)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewPointer(sliceType), nil
+	}
+
 	// Handle pointer types: both prefix '*int' and suffix 'int*' notations
 	if strings.HasPrefix(typeName, "*") {
 		baseTypeName := strings.TrimPrefix(typeName, "*")
@@ -33,8 +60,10 @@ func (cg *CodeGenerator) mapType(typeName string) (types.Type, error) {
 		// Let's use i32 consistently for now, matches test cases better.
 		// Consider i64 for sizes/indices if needed later.
 		return types.I32, nil
-	case "float":
+	case "float", "f32":
 		return types.Float, nil
+	case "double", "f64":
+		return types.Double, nil
 	case "bool":
 		return types.I1, nil
 	case "string":
@@ -42,18 +71,24 @@ func (cg *CodeGenerator) mapType(typeName string) (types.Type, error) {
 		return types.NewPointer(types.I8), nil
 	case "void": // For function return types
 		return types.Void, nil
-	// Add other primitives like uint8, i64 etc. as needed
-	case "i8":
+	case "any":
+		return cg.anyType(), nil
+	// LLVM integers are signless, so unsigned names share the same types.
+	case "i8", "u8", "byte":
 		return types.I8, nil
-	case "i16":
+	case "i16", "u16":
 		return types.I16, nil
-	case "i32":
+	case "i32", "u32":
 		return types.I32, nil
-	case "i64":
+	case "i64", "u64":
 		return types.I64, nil
 
 	default:
-		// Check if it's a user-defined struct type we've already processed
+		// Check if it's a user-defined struct type we've already processed:
+		// one of the current module, or a qualified name such as fs.Stat.
+		if definedType, exists := cg.scope.types[typeName]; exists {
+			return definedType, nil
+		}
 		if definedType, exists := cg.Structs[typeName]; exists {
 			return definedType, nil
 		}
@@ -63,3 +98,261 @@ func (cg *CodeGenerator) mapType(typeName string) (types.Type, error) {
 		return nil, fmt.Errorf("unsupported or undefined type: %s", typeName)
 	}
 }
+
+// mapFuncType maps a function type spelled '(T1,T2)->R' to a pointer to the
+// corresponding LLVM function type.
+func (cg *CodeGenerator) mapFuncType(typeName string) (types.Type, error) {
+	depth := 0
+	closing := -1
+	for i, r := range typeName {
+		if r == '(' {
+			depth++
+		} else if r == ')' {
+			depth--
+			if depth == 0 {
+				closing = i
+				break
+			}
+		}
+	}
+	if closing < 0 || !strings.HasPrefix(typeName[closing+1:], "->") {
+		return nil, fmt.Errorf("malformed function type: %s", typeName)
+	}
+
+	var paramTypes []types.Type
+	for _, paramName := range splitTypeList(typeName[1:closing]) {
+		paramType, err := cg.mapType(paramName)
+		if err != nil {
+			return nil, err
+		}
+		paramTypes = append(paramTypes, paramType)
+	}
+	retType, err := cg.mapType(typeName[closing+3:])
+	if err != nil {
+		return nil, err
+	}
+	return types.NewPointer(types.NewFunc(retType, paramTypes...)), nil
+}
+
+// splitTypeList splits a comma separated list of type names, ignoring commas
+// nested inside function types.
+func splitTypeList(list string) []string {
+	var parts []string
+	depth := 0
+	start := 0
+	for i, r := range list {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(list) {
+		parts = append(parts, list[start:])
+	}
+	return parts
+}
+
+// paramType returns the LLVM type of a declared parameter. Parameters without
+// an annotation are i32.
+func (cg *CodeGenerator) paramType(param *ast.Parameter) (types.Type, error) {
+	if param.Type == nil {
+		return types.I32, nil
+	}
+	return cg.mapType(param.Type.Value)
+}
+
+// convertValue converts v to the target type using the usual implicit
+// conversions: integer widening (sign extending) and narrowing, int<->float,
+// int<->pointer, pointer bitcasts and boxing into any.
+func (cg *CodeGenerator) convertValue(v value.Value, target types.Type) (value.Value, error) {
+	if v.Type().Equal(target) {
+		return v, nil
+	}
+	if target.Equal(cg.anyType()) {
+		return cg.boxAny(v)
+	}
+
+	switch dst := target.(type) {
+	case *types.IntType:
+		switch src := v.Type().(type) {
+		case *types.IntType:
+			if c, ok := v.(*constant.Int); ok {
+				return constant.NewInt(dst, c.X.Int64()), nil
+			}
+			if src.BitSize == 1 {
+				return cg.Block.NewZExt(v, dst), nil
+			}
+			if src.BitSize < dst.BitSize {
+				return cg.Block.NewSExt(v, dst), nil
+			}
+			return cg.Block.NewTrunc(v, dst), nil
+		case *types.FloatType:
+			return cg.Block.NewFPToSI(v, dst), nil
+		case *types.PointerType:
+			return cg.Block.NewPtrToInt(v, dst), nil
+		}
+	case *types.FloatType:
+		switch src := v.Type().(type) {
+		case *types.IntType:
+			return cg.Block.NewSIToFP(v, dst), nil
+		case *types.FloatType:
+			if c, ok := v.(*constant.Float); ok {
+				// Literals keep their full precision until given a type.
+				f, _ := c.X.Float64()
+				return constant.NewFloat(dst, f), nil
+			}
+			if floatBits(src) < floatBits(dst) {
+				return cg.Block.NewFPExt(v, dst), nil
+			}
+			return cg.Block.NewFPTrunc(v, dst), nil
+		}
+	case *types.PointerType:
+		switch src := v.Type().(type) {
+		case *types.IntType:
+			return cg.Block.NewIntToPtr(v, dst), nil
+		case *types.PointerType:
+			if isSlicePointer(src) && isSlicePointer(dst) {
+				return cg.convertSlice(v, dst)
+			}
+			if !pointerConvertible(src, dst) {
+				return nil, fmt.Errorf("cannot convert value of type %s to %s without a cast", v.Type(), target)
+			}
+			return cg.Block.NewBitCast(v, dst), nil
+		}
+	}
+	return nil, fmt.Errorf("cannot convert value of type %s to %s", v.Type(), target)
+}
+
+// pointerConvertible reports whether a pointer of type src converts to dst
+// without a cast: *u8 stands for any pointer, and a function converts to
+// another function type so lambdas whose result type is inferred can be
+// passed as callbacks. Anything else would reinterpret the memory pointed
+// at, which takes an explicit `as`.
+func pointerConvertible(src, dst *types.PointerType) bool {
+	if src.ElemType.Equal(types.I8) || dst.ElemType.Equal(types.I8) {
+		return true
+	}
+	_, srcFunc := src.ElemType.(*types.FuncType)
+	_, dstFunc := dst.ElemType.(*types.FuncType)
+	return srcFunc && dstFunc
+}
+
+// isSlicePointer reports whether t points to the struct of a slice.
+func isSlicePointer(t *types.PointerType) bool {
+	st, ok := t.ElemType.(*types.StructType)
+	return ok && isSliceType(st)
+}
+
+// convertSlice copies the slice at v into a new slice of type dst on the
+// stack, converting each element, so that an array literal (a []int) can
+// initialise a []i64 or be passed for a []f64 parameter. Only numeric
+// elements convert, and any element converts to any.
+func (cg *CodeGenerator) convertSlice(v value.Value, dst *types.PointerType) (value.Value, error) {
+	srcType := v.Type().(*types.PointerType).ElemType.(*types.StructType)
+	dstType := dst.ElemType.(*types.StructType)
+	srcElem := srcType.Fields[1].(*types.PointerType).ElemType
+	dstElem := dstType.Fields[1].(*types.PointerType).ElemType
+	if !dstElem.Equal(cg.anyType()) && !(isNumeric(srcElem) && isNumeric(dstElem)) {
+		return nil, fmt.Errorf("cannot convert value of type %s to %s", v.Type(), dst)
+	}
+
+	zero := constant.NewInt(types.I32, 0)
+	length := cg.Block.NewLoad(types.I32, cg.Block.NewGetElementPtr(srcType, v, zero, zero))
+	data := cg.Block.NewAlloca(dstElem)
+	data.NElems = length
+	result := cg.newEntryAlloca(dstType)
+	cg.Block.NewStore(length, cg.Block.NewGetElementPtr(dstType, result, zero, zero))
+	cg.Block.NewStore(data, cg.Block.NewGetElementPtr(dstType, result, zero, constant.NewInt(types.I32, 1)))
+
+	err := cg.forEachElement(v, "convert", func(index, elem value.Value) error {
+		converted, err := cg.convertValue(elem, dstElem)
+		if err != nil {
+			return err
+		}
+		cg.Block.NewStore(converted, cg.Block.NewGetElementPtr(dstElem, data, index))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// isNumeric reports whether t is an integer or floating point type.
+func isNumeric(t types.Type) bool {
+	switch t.(type) {
+	case *types.IntType, *types.FloatType:
+		return true
+	}
+	return false
+}
+
+func floatBits(t *types.FloatType) int {
+	switch t.Kind {
+	case types.FloatKindHalf:
+		return 16
+	case types.FloatKindFloat:
+		return 32
+	case types.FloatKindDouble:
+		return 64
+	default:
+		return 128
+	}
+}
+
+// TypeName spells t the way a program writes it: i1 is bool, i8* is string,
+// pointers to the struct of a slice or to the header of a sequence are []T
+// and seq<T>, and function pointers are (T1,T2)->R. LLVM integers are
+// signless, so u8 reads as i8.
+func TypeName(t types.Type) string {
+	switch t := t.(type) {
+	case *types.VoidType:
+		return "void"
+	case *types.IntType:
+		if t.BitSize == 1 {
+			return "bool"
+		}
+		return fmt.Sprintf("i%d", t.BitSize)
+	case *types.FloatType:
+		if t.Kind == types.FloatKindFloat {
+			return "f32"
+		}
+		return "f64"
+	case *types.PointerType:
+		switch elem := t.ElemType.(type) {
+		case *types.IntType:
+			if elem.BitSize == 8 {
+				return "string"
+			}
+		case *types.FuncType:
+			params := make([]string, len(elem.Params))
+			for i, p := range elem.Params {
+				params[i] = TypeName(p)
+			}
+			return "(" + strings.Join(params, ",") + ")->" + TypeName(elem.RetType)
+		case *types.StructType:
+			if isSliceType(elem) {
+				return "[]" + TypeName(elem.Fields[1].(*types.PointerType).ElemType)
+			}
+			if strings.HasPrefix(elem.Name(), "Seq.") {
+				return "seq<" + TypeName(elem.Fields[seqCurrentField]) + ">"
+			}
+		}
+		return "*" + TypeName(t.ElemType)
+	case *types.StructType:
+		if t.Name() == "Any" {
+			return "any"
+		}
+		if t.Name() != "" {
+			return t.Name()
+		}
+	}
+	return t.String()
+}
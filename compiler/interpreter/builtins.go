@@ -0,0 +1,351 @@
+package interpreter
+
+import (
+	"compiler/ast"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+// VisitAssemblyExpression runs asm("builtin_name", args...) runtime helpers.
+// Inline assembly cannot be interpreted, apart from the hints that have no
+// effect on a program's behaviour.
+func (in *Interpreter) VisitAssemblyExpression(ae *ast.AssemblyExpression) error {
+	if ae.Block {
+		return in.visitAsmBlock(ae)
+	}
+	asmCode := ae.Code.Value
+
+	var args []Value
+	for _, argExpr := range ae.Args {
+		if err := argExpr.Accept(in); err != nil {
+			return fmt.Errorf("error evaluating argument for asm '%s': %w", asmCode, err)
+		}
+		if !in.last.valid() {
+			return fmt.Errorf("argument expression for asm '%s' produced no value", asmCode)
+		}
+		args = append(args, in.last)
+	}
+
+	switch asmCode {
+	case "builtin_print_int", "builtin_print_newline":
+		f := in.functions[asmCode]
+		if len(args) != len(f.typ.Params) {
+			return fmt.Errorf("asm '%s' expects %d %s, got %d", asmCode, len(f.typ.Params), plural(len(f.typ.Params), "argument"), len(args))
+		}
+		if _, err := in.call(f, args); err != nil {
+			return err
+		}
+		in.last = noValue
+		return nil
+
+	case "builtin_map":
+		in.last = pointerValue(types.NewPointer(types.I32), 0)
+		return nil
+	case "builtin_forEach":
+		in.last = noValue
+		return nil
+
+	default:
+		if strings.HasPrefix(asmCode, "builtin_") || len(args) > 0 {
+			return fmt.Errorf("unsupported or unknown asm code: '%s'", asmCode)
+		}
+		return in.visitAsmBlock(&ast.AssemblyExpression{Token: ae.Token, Code: ae.Code, Block: true})
+	}
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
+
+// visitAsmBlock accepts the assembly that only hints the processor, such as
+// the "pause" of a spin loop, and rejects everything else.
+func (in *Interpreter) visitAsmBlock(ae *ast.AssemblyExpression) error {
+	in.last = noValue
+	if len(ae.Outputs) == 0 && len(ae.Inputs) == 0 {
+		hint := true
+		for _, line := range strings.Split(ae.Code.Value, "\n") {
+			switch strings.TrimSpace(line) {
+			case "", "nop", "pause", "yield":
+			default:
+				hint = false
+			}
+		}
+		if hint {
+			return nil
+		}
+	}
+	return fmt.Errorf("inline assembly at line %d cannot be interpreted", ae.Token.Line+1)
+}
+
+// processArgs returns the command line as the []string of builtin.args()
+// and the address of the environment, a null-terminated array of
+// "NAME=value" strings, laying them out in data memory on first use.
+func (in *Interpreter) processArgs() (Value, uint64) {
+	sliceType, _ := in.sliceType("string")
+	if in.procArgs == 0 {
+		strs := func(list []string) uint64 {
+			array := in.mem.allocData(uint64(len(list)+1)*8, 8)
+			for i, s := range list {
+				addr := in.mem.allocData(uint64(len(s))+1, 1)
+				in.mem.writeBytes(addr, []byte(s))
+				in.mem.writeUint(array+uint64(i)*8, 8, addr)
+			}
+			return array
+		}
+		argv := strs(in.Args)
+		in.environ = strs(in.Env)
+		in.procArgs = in.mem.allocData(in.sizeOf(sliceType), in.alignOf(sliceType))
+		in.store(in.fieldAddr(sliceType, in.procArgs, 0), intValue(types.I32, int64(len(in.Args))))
+		in.store(in.fieldAddr(sliceType, in.procArgs, 1), pointerValue(sliceType.Fields[1], argv))
+	}
+	return pointerValue(types.NewPointer(sliceType), in.procArgs), in.environ
+}
+
+var floatIntrinsics = map[string]struct {
+	fn   func(args ...float64) float64
+	libm bool
+}{
+	"sqrt":  {func(a ...float64) float64 { return math.Sqrt(a[0]) }, false},
+	"fabs":  {func(a ...float64) float64 { return math.Abs(a[0]) }, false},
+	"floor": {func(a ...float64) float64 { return math.Floor(a[0]) }, true},
+	"ceil":  {func(a ...float64) float64 { return math.Ceil(a[0]) }, true},
+	"trunc": {func(a ...float64) float64 { return math.Trunc(a[0]) }, true},
+	"exp":   {func(a ...float64) float64 { return math.Exp(a[0]) }, true},
+	"log":   {func(a ...float64) float64 { return math.Log(a[0]) }, true},
+	"sin":   {func(a ...float64) float64 { return math.Sin(a[0]) }, true},
+	"cos":   {func(a ...float64) float64 { return math.Cos(a[0]) }, true},
+	"pow":   {func(a ...float64) float64 { return math.Pow(a[0], a[1]) }, true},
+}
+
+type overflowOp struct {
+	wrapped func(a, b int64) int64
+	exact   func(z, a, b *big.Int) *big.Int
+}
+
+var overflowIntrinsics = map[string]overflowOp{
+	"addOverflow": {func(a, b int64) int64 { return a + b }, (*big.Int).Add},
+	"subOverflow": {func(a, b int64) int64 { return a - b }, (*big.Int).Sub},
+	"mulOverflow": {func(a, b int64) int64 { return a * b }, (*big.Int).Mul},
+}
+
+// visitLibm reports whether libm is linked: a constant, as in the code
+// generator, so that conditions on it are constant too.
+func (in *Interpreter) visitLibm(name string, args []Value) error {
+	in.last = constBool(!in.Freestanding)
+	return nil
+}
+
+// visitFloatIntrinsic runs a floating point intrinsic; see the code
+// generator's visitFloatIntrinsic.
+func (in *Interpreter) visitFloatIntrinsic(name string, args []Value) error {
+	fi := floatIntrinsics[name]
+	if fi.libm && in.Freestanding {
+		return fmt.Errorf("%s.%s needs libm, which freestanding programs do not link; use stdlib/math", builtinModule, name)
+	}
+	var t *types.FloatType = types.Double
+	if ft, ok := args[0].T.(*types.FloatType); ok && ft.Kind == types.FloatKindFloat {
+		t = types.Float
+	}
+	operands := make([]float64, len(args))
+	for i, arg := range args {
+		v, err := in.convert(arg, t)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", builtinModule, name, err)
+		}
+		operands[i] = roundTo(t, v.F)
+	}
+	in.last = floatValue(t, fi.fn(operands...))
+	return nil
+}
+
+// visitOverflow stores the wrapped result of a op b to the integer the
+// third argument points to and returns whether the operation overflowed
+// as signed arithmetic.
+func (in *Interpreter) visitOverflow(name string, args []Value) error {
+	qualified := builtinModule + "." + name
+	op := overflowIntrinsics[name]
+	ptrType, ok := args[2].T.(*types.PointerType)
+	if !ok {
+		return fmt.Errorf("%s: third argument must point to an integer, got %s", qualified, args[2].T)
+	}
+	t, ok := ptrType.ElemType.(*types.IntType)
+	if !ok {
+		return fmt.Errorf("%s: third argument must point to an integer, got %s", qualified, ptrType)
+	}
+	a, err := in.convert(args[0], t)
+	if err != nil {
+		return fmt.Errorf("%s: %w", qualified, err)
+	}
+	b, err := in.convert(args[1], t)
+	if err != nil {
+		return fmt.Errorf("%s: %w", qualified, err)
+	}
+	// Go's int64 arithmetic wraps like the machine's; the exact result
+	// tells whether the wrapped one overflowed.
+	result := intValue(t, op.wrapped(a.signed(), b.signed()))
+	exact := op.exact(new(big.Int), big.NewInt(a.signed()), big.NewInt(b.signed()))
+	overflow := exact.Cmp(big.NewInt(result.signed())) != 0
+	in.store(args[2].addr(), result)
+	in.last = boolValue(overflow)
+	return nil
+}
+
+// visitFloatBits reinterprets a float as an integer of the same width, or
+// the other way round.
+func (in *Interpreter) visitFloatBits(name string, args []Value) error {
+	v := args[0]
+	if name == "floatBits" {
+		ft, ok := v.T.(*types.FloatType)
+		if !ok {
+			return fmt.Errorf("%s.%s: argument must be a float, got %s", builtinModule, name, v.T)
+		}
+		if ft.Kind == types.FloatKindFloat {
+			in.last = intValue(types.I32, int64(math.Float32bits(float32(v.F))))
+		} else {
+			in.last = intValue(types.I64, int64(math.Float64bits(v.F)))
+		}
+		return nil
+	}
+	it, ok := v.T.(*types.IntType)
+	if !ok || (it.BitSize != 32 && it.BitSize != 64) {
+		return fmt.Errorf("%s.%s: argument must be an i32 or i64, got %s", builtinModule, name, v.T)
+	}
+	if it.BitSize == 32 {
+		in.last = floatValue(types.Float, float64(math.Float32frombits(uint32(v.unsigned()))))
+	} else {
+		in.last = floatValue(types.Double, math.Float64frombits(v.unsigned()))
+	}
+	return nil
+}
+
+var atomicOrderingNames = []string{"RELAXED", "ACQUIRE", "RELEASE", "ACQ_REL", "SEQ_CST"}
+
+var atomicRMWOps = map[string]func(old, v Value) int64{
+	"atomicAdd":  func(old, v Value) int64 { return old.I + v.I },
+	"atomicSub":  func(old, v Value) int64 { return old.I - v.I },
+	"atomicAnd":  func(old, v Value) int64 { return old.I & v.I },
+	"atomicOr":   func(old, v Value) int64 { return old.I | v.I },
+	"atomicXor":  func(old, v Value) int64 { return old.I ^ v.I },
+	"atomicSwap": func(old, v Value) int64 { return v.I },
+	"atomicMax": func(old, v Value) int64 {
+		if v.signed() > old.signed() {
+			return v.I
+		}
+		return old.I
+	},
+	"atomicMin": func(old, v Value) int64 {
+		if v.signed() < old.signed() {
+			return v.I
+		}
+		return old.I
+	},
+}
+
+// visitAtomic runs an atomic intrinsic. With a single thread every ordering
+// behaves the same, but the operands are checked as the code generator does.
+func (in *Interpreter) visitAtomic(name string, args []Value) error {
+	qualified := builtinModule + "." + name
+	order := args[len(args)-1]
+
+	if name == "fence" {
+		in.last = noValue
+		return in.checkOrdering(qualified, order, []bool{false, true, true, true, true})
+	}
+
+	ptrType, ok := args[0].T.(*types.PointerType)
+	if !ok {
+		return fmt.Errorf("%s: first argument must be a pointer, got %s", qualified, args[0].T)
+	}
+	elem := ptrType.ElemType
+	if err := atomicType(elem); err != nil {
+		return fmt.Errorf("%s: %w", qualified, err)
+	}
+	ptr := args[0].addr()
+	var operands []Value
+	for _, arg := range args[1 : len(args)-1] {
+		v, err := in.convert(arg, elem)
+		if err != nil {
+			return fmt.Errorf("%s: %w", qualified, err)
+		}
+		operands = append(operands, v)
+	}
+
+	switch name {
+	case "atomicLoad":
+		if err := in.checkOrdering(qualified, order, []bool{true, true, false, false, true}); err != nil {
+			return err
+		}
+		in.last = in.load(ptr, elem)
+	case "atomicStore":
+		if err := in.checkOrdering(qualified, order, []bool{true, false, true, false, true}); err != nil {
+			return err
+		}
+		in.store(ptr, operands[0])
+		in.last = noValue
+	case "atomicCas":
+		if err := in.checkOrdering(qualified, order, nil); err != nil {
+			return err
+		}
+		swapped := in.load(ptr, elem).I == operands[0].I
+		if swapped {
+			in.store(ptr, operands[1])
+		}
+		in.last = boolValue(swapped)
+	default:
+		if _, isInt := elem.(*types.IntType); !isInt && name != "atomicSwap" {
+			return fmt.Errorf("%s: operand must point to an integer, got %s", qualified, ptrType)
+		}
+		if err := in.checkOrdering(qualified, order, nil); err != nil {
+			return err
+		}
+		old := in.load(ptr, elem)
+		updated := atomicRMWOps[name](old, operands[0])
+		if it, ok := elem.(*types.IntType); ok {
+			in.store(ptr, intValue(it, updated))
+		} else {
+			in.store(ptr, pointerValue(elem, uint64(updated)))
+		}
+		in.last = old
+	}
+	return nil
+}
+
+// atomicType checks that t can be accessed atomically.
+func atomicType(t types.Type) error {
+	switch t := t.(type) {
+	case *types.IntType:
+		if t.BitSize >= 8 && t.BitSize <= 64 && t.BitSize&(t.BitSize-1) == 0 {
+			return nil
+		}
+	case *types.PointerType:
+		return nil
+	}
+	return fmt.Errorf("atomic access to %s is not supported (want an 8 to 64-bit integer or a pointer)", t)
+}
+
+// checkOrdering checks a constant memory ordering against the orderings an
+// operation accepts (nil for all of them). Orderings computed at run time
+// are not checked: the code generator falls back to SEQ_CST for them.
+func (in *Interpreter) checkOrdering(name string, order Value, valid []bool) error {
+	if !order.konst || !isInt(order.T) {
+		if _, err := in.convert(order, types.I64); err != nil {
+			return fmt.Errorf("%s: memory ordering: %w", name, err)
+		}
+		return nil
+	}
+	i := order.signed()
+	if i < 0 || i >= int64(len(atomicOrderingNames)) {
+		return fmt.Errorf("%s: unknown memory ordering %d", name, i)
+	}
+	if valid != nil && !valid[i] {
+		return fmt.Errorf("%s: memory ordering %s is not valid for this operation", name, atomicOrderingNames[i])
+	}
+	return nil
+}
@@ -0,0 +1,391 @@
+package interpreter
+
+import (
+	"compiler/ast"
+	"fmt"
+	"strings"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+func (in *Interpreter) VisitCallExpression(ce *ast.CallExpression) error {
+	// A call is evaluated as a value, arguments included, even as the base
+	// of a member access such as f(x).field
+	isLHS, site := in.lhs, in.site
+	in.lhs = false
+	defer func() { in.lhs, in.site = isLHS, site }()
+
+	if in.isAssertion(ce) {
+		return in.visitAssertion(ce)
+	}
+	if name, ok := in.intrinsicCall(ce); ok {
+		return in.visitIntrinsic(name, ce)
+	}
+	if mae, ok := ce.Function.(*ast.MemberAccessExpression); ok {
+		// Functions derived for a data type, e.g. Point.fromJson(text)
+		if dt := in.dataTypeRef(mae.Left); dt != nil {
+			in.site = ce
+			return in.visitDataTypeCall(dt, mae.Member.Value, ce.Arguments)
+		}
+		if in.moduleRef(mae.Left) == nil {
+			return in.visitMethodCall(ce, mae)
+		}
+	}
+
+	if err := ce.Function.Accept(in); err != nil {
+		return fmt.Errorf("error evaluating function expression '%s': %w", ce.Function.String(), err)
+	}
+	fnVal := in.last
+	if !fnVal.valid() {
+		return fmt.Errorf("function expression '%s' evaluated to nil", ce.Function.String())
+	}
+	ptrType, ok := fnVal.T.(*types.PointerType)
+	if !ok {
+		return fmt.Errorf("cannot call value of type %T, not a function or pointer", fnVal.T)
+	}
+	fnType, ok := ptrType.ElemType.(*types.FuncType)
+	if !ok {
+		return fmt.Errorf("cannot call pointer value of type %s, does not point to a function", fnVal.T)
+	}
+
+	var args []Value
+	var err error
+	sig := in.signatureFor(ce.Function)
+	if sig != nil && len(sig.Params)+sig.Offset == len(fnType.Params) {
+		args, err = in.bindArguments(sig, ce.Arguments, fnType.Params[sig.Offset:])
+	} else {
+		sig = nil
+		args, err = in.evaluateArguments(ce.Arguments)
+	}
+	if err != nil {
+		return fmt.Errorf("error evaluating arguments for call to '%s': %w", ce.Function.String(), err)
+	}
+	if err := in.checkSeqArguments(ce, sig, args); err != nil {
+		return err
+	}
+	in.site = ce
+
+	// A generator's frame is allocated by its caller.
+	if f := fnVal.fn; f != nil && f.gen != nil {
+		if gen := in.fr.gen; gen != nil && gen.info == f.gen {
+			return f.gen.frameError(fmt.Errorf("a generator cannot call itself, as its frame would contain itself"))
+		}
+		frame := in.entryAlloca(slotKey{node: ce, kind: slotGenFrame}, f.gen.frame)
+		args = append([]Value{pointerValue(types.NewPointer(f.gen.frame), frame)}, args...)
+	}
+
+	f := in.funcs[fnVal.addr()]
+	if f == nil {
+		// Calling anything but a function crashes.
+		panic(fault{fnVal.addr()})
+	}
+	if len(fnType.Params) != len(args) {
+		return fmt.Errorf("argument count mismatch for call to '%s @%s': expected %d, got %d", fnVal.T, f.name, len(fnType.Params), len(args))
+	}
+	result, err := in.call(f, args)
+	if err != nil {
+		return err
+	}
+	if fnType.RetType.Equal(types.Void) {
+		result = noValue
+	}
+	in.last = result
+	return nil
+}
+
+// visitMethodCall calls a method on the value of mae.Left, which is passed as
+// self.
+func (in *Interpreter) visitMethodCall(ce *ast.CallExpression, mae *ast.MemberAccessExpression) error {
+	if err := mae.Left.Accept(in); err != nil {
+		return fmt.Errorf("error evaluating receiver for method call '%s': %w", mae.Member.Value, err)
+	}
+	receiver := in.last
+	if !receiver.valid() {
+		return fmt.Errorf("method call receiver '%s' evaluated to nil", mae.Left.String())
+	}
+
+	methodName := mae.Member.Value
+	var args []Value
+	var err error
+	if method := in.lookupMethod(receiver, methodName); method != nil && method.sig != nil {
+		args, err = in.bindArguments(method.sig, ce.Arguments, method.typ.Params[method.sig.Offset:])
+	} else {
+		args, err = in.evaluateArguments(ce.Arguments)
+	}
+	if err != nil {
+		return fmt.Errorf("error evaluating arguments for call to '%s': %w", ce.Function.String(), err)
+	}
+	in.site = ce
+	return in.handleMethodCall(receiver, methodName, args)
+}
+
+func (in *Interpreter) evaluateArgument(argExpr ast.ExpressionNode) (Value, error) {
+	if err := argExpr.Accept(in); err != nil {
+		return noValue, err
+	}
+	if !in.last.valid() {
+		return noValue, fmt.Errorf("argument expression produced no value")
+	}
+	return in.last, nil
+}
+
+func (in *Interpreter) evaluateArguments(argNodes []ast.ExpressionNode) ([]Value, error) {
+	args := make([]Value, 0, len(argNodes))
+	for _, argExpr := range argNodes {
+		if _, isNamed := argExpr.(*ast.NamedArgument); isNamed {
+			return nil, fmt.Errorf("named argument '%s' used in a call to a function without a known parameter list", argExpr.String())
+		}
+		argVal, err := in.evaluateArgument(argExpr)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, argVal)
+	}
+	return args, nil
+}
+
+// bindArguments matches the call-site arguments against the declared
+// parameters of sig: positional arguments fill parameters left to right,
+// named arguments bind by name and missing parameters take their default.
+// A trailing variadic parameter collects the remaining positional arguments
+// into a pack; a single argument that already is a pack of the right type is
+// forwarded as-is. Provided arguments are evaluated in source order, then any
+// defaults, and every value is converted to the parameter type.
+func (in *Interpreter) bindArguments(sig *funcSignature, argNodes []ast.ExpressionNode, paramTypes []types.Type) ([]Value, error) {
+	declaredAt := func() string { return fmt.Sprintf("declared at line %d", sig.Token.Line+1) }
+	params := sig.Params
+	variadic := len(params) > 0 && params[len(params)-1].IsVariadic()
+	fixed := len(params)
+	if variadic {
+		fixed--
+	}
+
+	slots := make([]ast.ExpressionNode, fixed)
+	fromDefault := make([]bool, fixed)
+	var extras []ast.ExpressionNode
+	order := make([]int, 0, len(argNodes)) // parameter index per evaluated argument, -1 for extras
+
+	position := 0
+	for _, argExpr := range argNodes {
+		if named, isNamed := argExpr.(*ast.NamedArgument); isNamed {
+			idx := -1
+			for i, param := range params {
+				if param.Name.Value == named.Name.Value {
+					idx = i
+					break
+				}
+			}
+			if idx < 0 {
+				return nil, fmt.Errorf("unknown parameter '%s' in call to '%s' (%s)", named.Name.Value, sig.Name, declaredAt())
+			}
+			if idx >= fixed {
+				return nil, fmt.Errorf("variadic parameter '%s' cannot be passed by name in call to '%s'", named.Name.Value, sig.Name)
+			}
+			if slots[idx] != nil {
+				return nil, fmt.Errorf("parameter '%s' given more than once in call to '%s'", named.Name.Value, sig.Name)
+			}
+			slots[idx] = named.Value
+			order = append(order, idx)
+			continue
+		}
+		if position >= fixed {
+			if !variadic {
+				return nil, fmt.Errorf("too many arguments in call to '%s': expected at most %d, got %d (%s)", sig.Name, len(params), len(argNodes), declaredAt())
+			}
+			extras = append(extras, argExpr)
+			order = append(order, -1)
+			continue
+		}
+		slots[position] = argExpr
+		order = append(order, position)
+		position++
+	}
+
+	for i := 0; i < fixed; i++ {
+		if slots[i] != nil {
+			continue
+		}
+		if params[i].Default == nil {
+			return nil, fmt.Errorf("missing argument for parameter '%s' in call to '%s' (%s)", params[i].Name.Value, sig.Name, declaredAt())
+		}
+		slots[i] = params[i].Default
+		fromDefault[i] = true
+		order = append(order, i)
+	}
+
+	args := make([]Value, len(params))
+	var extraVals []Value
+	extraIdx := 0
+	for _, idx := range order {
+		var argExpr ast.ExpressionNode
+		var paramName string
+		if idx < 0 {
+			argExpr = extras[extraIdx]
+			extraIdx++
+			paramName = params[fixed].Name.Value
+		} else {
+			argExpr = slots[idx]
+			paramName = params[idx].Name.Value
+		}
+		callerScope := in.scope
+		if idx >= 0 && fromDefault[idx] && sig.Scope != nil {
+			in.scope = sig.Scope
+		}
+		argVal, err := in.evaluateArgument(argExpr)
+		in.scope = callerScope
+		if err != nil {
+			return nil, fmt.Errorf("argument '%s': %w", paramName, err)
+		}
+		if idx < 0 {
+			extraVals = append(extraVals, argVal)
+			continue
+		}
+		converted, err := in.convert(argVal, paramTypes[idx])
+		if err != nil {
+			return nil, fmt.Errorf("argument '%s' in call to '%s': %w", paramName, sig.Name, err)
+		}
+		args[idx] = converted
+	}
+
+	if variadic {
+		packType, ok := paramTypes[fixed].(*types.PointerType)
+		if !ok {
+			return nil, fmt.Errorf("variadic parameter '%s' of '%s' has non-pack type %s", params[fixed].Name.Value, sig.Name, paramTypes[fixed])
+		}
+		if len(extraVals) == 1 && extraVals[0].T.Equal(packType) {
+			args[fixed] = extraVals[0]
+		} else {
+			elemType := pointee(packType.ElemType.(*types.StructType).Fields[1])
+			elems := make([]Value, len(extraVals))
+			for i, v := range extraVals {
+				converted, err := in.convert(v, elemType)
+				if err != nil {
+					return nil, fmt.Errorf("variadic argument %d in call to '%s': %w", i+1, sig.Name, err)
+				}
+				elems[i] = converted
+			}
+			args[fixed] = in.buildPack(packType, elems)
+		}
+	}
+	return args, nil
+}
+
+// buildPack stores the arguments passed to a variadic parameter in a slice
+// on the stack and returns a pointer to it.
+func (in *Interpreter) buildPack(packType *types.PointerType, elems []Value) Value {
+	sliceType := packType.ElemType.(*types.StructType)
+	pack := in.entryAlloca(slotKey{node: in.site, kind: slotPack}, sliceType)
+	in.store(in.fieldAddr(sliceType, pack, 0), intValue(types.I32, int64(len(elems))))
+
+	var data uint64
+	if len(elems) > 0 {
+		elemType := pointee(sliceType.Fields[1])
+		data = in.entryAlloca(slotKey{node: in.site, name: "data", kind: slotPack}, types.NewArray(uint64(len(elems)), elemType))
+		size := in.sizeOf(elemType)
+		for i, elem := range elems {
+			in.store(data+uint64(i)*size, elem)
+		}
+	}
+	in.store(in.fieldAddr(sliceType, pack, 1), pointerValue(sliceType.Fields[1], data))
+	return pointerValue(packType, pack)
+}
+
+// signatureFor returns the recorded parameter list for the callee expression
+// of a call, if it names a declared function or a variable bound to one.
+func (in *Interpreter) signatureFor(callee ast.ExpressionNode) *funcSignature {
+	if mae, ok := callee.(*ast.MemberAccessExpression); ok {
+		// A function called through its module alias, e.g. fs.open(path)
+		if scope := in.moduleRef(mae.Left); scope != nil {
+			if fn, ok := scope.functions[mae.Member.Value]; ok {
+				return fn.sig
+			}
+		}
+		return nil
+	}
+	ident, ok := callee.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	if b, ok := in.fr.vars[ident.Value]; ok {
+		return b.sig
+	}
+	if fn := in.lookupFunction(ident.Value); fn != nil {
+		return fn.sig
+	}
+	return nil
+}
+
+// lookupFunction returns the function a name refers to in the current
+// module, or nil.
+func (in *Interpreter) lookupFunction(name string) *function {
+	if fn, ok := in.scope.functions[name]; ok {
+		return fn
+	}
+	return in.functions[name]
+}
+
+// lookupMethod returns the function implementing methodName for the struct
+// type the receiver points to, or nil if there is none.
+func (in *Interpreter) lookupMethod(receiver Value, methodName string) *function {
+	st := pointeeStruct(receiver.T)
+	if st == nil {
+		return nil
+	}
+	return in.functions[st.Name()+"_"+methodName]
+}
+
+func (in *Interpreter) handleMethodCall(receiver Value, methodName string, args []Value) error {
+	objPtrType, ok := receiver.T.(*types.PointerType)
+	if !ok {
+		return fmt.Errorf("method call receiver is not a pointer, but %T", receiver.T)
+	}
+	objStructType, ok := objPtrType.ElemType.(*types.StructType)
+	if !ok {
+		return fmt.Errorf("method call receiver does not point to a struct, but %T", objPtrType.ElemType)
+	}
+	typeName := objStructType.Name()
+
+	// Sequences and Array.lazy are provided by the language.
+	if _, isSeq := in.seqElem(receiver.T); isSeq {
+		return in.seqMethod(receiver, methodName, args)
+	}
+	if methodName == "lazy" && isSliceType(objStructType) {
+		return in.arrayLazy(receiver, args)
+	}
+
+	mangledName := typeName + "_" + methodName
+	method, exists := in.functions[mangledName]
+	if dt, isData := in.dataTypes[typeName]; !exists && isData && methodName == "toJson" {
+		fn, err := in.dataFunc(dt, methodName)
+		if err != nil {
+			return err
+		}
+		method, exists = fn, true
+	}
+	if !exists {
+		return fmt.Errorf("method '%s' not found for type '%s' (tried mangled name '%s')", methodName, typeName, mangledName)
+	}
+
+	if typeName == "Array" && strings.HasPrefix(method.name, "builtin_array_") {
+		switch methodName {
+		case "map":
+			return in.arrayMap(receiver, args)
+		case "forEach":
+			return in.arrayForEach(receiver, args)
+		}
+	}
+
+	allArgs := append([]Value{receiver}, args...)
+	if len(method.typ.Params) != len(allArgs) {
+		return fmt.Errorf("argument count mismatch for method call '%s.%s': expected %d (including self), got %d", typeName, methodName, len(method.typ.Params), len(allArgs))
+	}
+	result, err := in.call(method, allArgs)
+	if err != nil {
+		return err
+	}
+	if method.typ.RetType.Equal(types.Void) {
+		result = noValue
+	}
+	in.last = result
+	return nil
+}
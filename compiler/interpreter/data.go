@@ -0,0 +1,443 @@
+package interpreter
+
+import (
+	"compiler/ast"
+	"fmt"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+// Modules the functions derived for data declarations call into.
+const (
+	jsonModule = "stdlib/json"
+	memModule  = "stdlib/mem"
+)
+
+// dataType is a data declaration whose struct has been defined. Its derived
+// functions, new, fromJson and toJson, are provided by the interpreter on
+// top of stdlib/json and stdlib/mem, as the code generator emits them.
+type dataType struct {
+	decl *ast.DataStructure
+	st   *types.StructType
+}
+
+// defineDataType lays out the struct of a data declaration like that of a
+// type declaration. Fields without a type annotation are any.
+func (in *Interpreter) defineDataType(ds *ast.DataStructure) error {
+	cd := &ast.ClassDeclaration{Token: ds.Token, Name: ds.Name}
+	for _, field := range ds.Fields {
+		fieldType := field.Type
+		if fieldType == nil {
+			fieldType = &ast.Identifier{Token: field.Name.Token, Value: "any"}
+		}
+		cd.Members = append(cd.Members, &ast.ClassMember{
+			VariableDeclaration: &ast.VariableDeclaration{Token: field.Token, Name: field.Name, Type: fieldType},
+		})
+	}
+	if err := in.defineStructType(cd); err != nil {
+		return err
+	}
+	st, ok := in.scope.types[ds.Name.Value].(*types.StructType)
+	if !ok {
+		return fmt.Errorf("data type '%s' is not a struct", ds.Name.Value)
+	}
+	in.dataTypes[st.Name()] = &dataType{decl: ds, st: st}
+	return nil
+}
+
+// dataTypeRef returns the data type expr names, as the receiver of a call
+// such as Point.fromJson(v), or nil.
+func (in *Interpreter) dataTypeRef(expr ast.ExpressionNode) *dataType {
+	ident, ok := expr.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	if _, isVar := in.fr.vars[ident.Value]; isVar || in.scope.declares(ident.Value) {
+		return nil
+	}
+	t, ok := in.scope.types[ident.Value]
+	if !ok {
+		t, ok = in.structs[ident.Value]
+	}
+	if !ok {
+		return nil
+	}
+	st, ok := t.(*types.StructType)
+	if !ok {
+		return nil
+	}
+	return in.dataTypes[st.Name()]
+}
+
+// visitDataTypeCall calls a function derived for a data type that is called
+// on the type itself: new or fromJson.
+func (in *Interpreter) visitDataTypeCall(dt *dataType, name string, argNodes []ast.ExpressionNode) error {
+	args, err := in.evaluateArguments(argNodes)
+	if err != nil {
+		return fmt.Errorf("error evaluating arguments for call to '%s.%s': %w", dt.st.Name(), name, err)
+	}
+	var fn *function
+	switch name {
+	case "new":
+		if len(args) != 0 {
+			return fmt.Errorf("%s.new expects no arguments, got %d", dt.st.Name(), len(args))
+		}
+		fn, err = in.dataFunc(dt, "new")
+	case "fromJson":
+		if len(args) != 1 {
+			return fmt.Errorf("%s.fromJson expects 1 argument, got %d", dt.st.Name(), len(args))
+		}
+		// JSON text is parsed first.
+		if args[0].T.Equal(i8Ptr) {
+			fn, err = in.dataFunc(dt, "fromJsonText")
+		} else {
+			fn, err = in.dataFunc(dt, "fromJson")
+		}
+		if err == nil && !args[0].T.Equal(fn.typ.Params[0]) {
+			return fmt.Errorf("%s.fromJson expects a *json.JsonValue or a string, got %s", dt.st.Name(), args[0].T)
+		}
+	default:
+		return fmt.Errorf("data type '%s' has no function '%s'; data types provide new and fromJson, and values toJson", dt.st.Name(), name)
+	}
+	if err != nil {
+		return err
+	}
+	in.last, err = in.call(fn, args)
+	return err
+}
+
+// dataFunc returns the function name derived for dt, deriving it on first
+// use, or nil if dt has no function of that name.
+func (in *Interpreter) dataFunc(dt *dataType, name string) (*function, error) {
+	irName := dt.st.Name() + "_" + name
+	if fn, ok := in.functions[irName]; ok {
+		return fn, nil
+	}
+	switch name {
+	case "new":
+		return in.deriveNew(dt, irName)
+	case "toJson":
+		return in.deriveToJson(dt, irName)
+	case "fromJson":
+		return in.deriveFromJson(dt, irName)
+	case "fromJsonText":
+		return in.deriveFromJsonText(dt, irName)
+	}
+	return nil, nil
+}
+
+// derived declares the function irName of type t derived for a data type.
+// Its implementation is filled in by the caller, once the functions it
+// calls have been derived in turn.
+func (in *Interpreter) derived(irName string, t *types.FuncType) *function {
+	fn := in.newFunction(&function{name: irName, typ: t})
+	in.functions[irName] = fn
+	return fn
+}
+
+// moduleFuncs looks up the functions of the module path named in names,
+// which the derived function what calls.
+func (in *Interpreter) moduleFuncs(path, what string, names ...string) (map[string]*function, error) {
+	fns := make(map[string]*function, len(names))
+	for _, name := range names {
+		scope, ok := in.modules[path]
+		if !ok || scope.functions[name] == nil {
+			return nil, fmt.Errorf("%s needs %s; import it", what, path)
+		}
+		fns[name] = scope.functions[name]
+	}
+	return fns, nil
+}
+
+// jsonValuePtr returns the type *json.JsonValue, which the derived function
+// what works with.
+func (in *Interpreter) jsonValuePtr(what string) (*types.PointerType, error) {
+	if scope, ok := in.modules[jsonModule]; ok {
+		if t, ok := scope.types["JsonValue"]; ok {
+			return types.NewPointer(t), nil
+		}
+	}
+	return nil, fmt.Errorf("%s needs %s; import it", what, jsonModule)
+}
+
+// cString returns the address of a NUL-terminated copy of s in data memory,
+// one per distinct string.
+func (in *Interpreter) cString(s string) Value {
+	addr, ok := in.cstrings[s]
+	if !ok {
+		addr = in.mem.allocData(uint64(len(s))+1, 1)
+		in.mem.writeBytes(addr, []byte(s))
+		in.cstrings[s] = addr
+	}
+	return pointerValue(i8Ptr, addr)
+}
+
+// calls calls the functions of fns, stopping at the first error.
+type calls struct {
+	in  *Interpreter
+	fns map[string]*function
+	err error
+}
+
+func (c *calls) call(name string, args ...Value) Value {
+	if c.err != nil {
+		return noValue
+	}
+	var v Value
+	v, c.err = c.in.call(c.fns[name], args)
+	return v
+}
+
+// dataFieldAddr returns the address of field of the data value at self.
+func (in *Interpreter) dataFieldAddr(dt *dataType, self uint64, field *ast.Field) (uint64, types.Type) {
+	idx, _ := in.fieldIndex(dt.st, field.Name.Value)
+	return in.fieldAddr(dt.st, self, idx), dt.st.Fields[idx]
+}
+
+// deriveNew derives T.new(), a zeroed T from the heap of stdlib/mem.
+func (in *Interpreter) deriveNew(dt *dataType, irName string) (*function, error) {
+	fns, err := in.moduleFuncs(memModule, dt.st.Name()+".new", "heapAlloc")
+	if err != nil {
+		return nil, err
+	}
+	ptrType := types.NewPointer(dt.st)
+	fn := in.derived(irName, types.NewFunc(ptrType))
+	fn.native = func([]Value) (Value, error) {
+		c := &calls{in: in, fns: fns}
+		p := c.call("heapAlloc", intValue(types.I64, int64(in.sizeOf(dt.st))))
+		return pointerValue(ptrType, p.addr()), c.err
+	}
+	return fn, nil
+}
+
+// deriveToJson derives t.toJson(), which returns null for a null t.
+func (in *Interpreter) deriveToJson(dt *dataType, irName string) (*function, error) {
+	what := dt.st.Name() + ".toJson"
+	jsonPtr, err := in.jsonValuePtr(what)
+	if err != nil {
+		return nil, err
+	}
+	fns, err := in.moduleFuncs(jsonModule, what, "newNull", "newObject", "set", "newBool", "newInt", "newNumber", "newString", "fromAny", "clone")
+	if err != nil {
+		return nil, err
+	}
+	fn := in.derived(irName, types.NewFunc(jsonPtr, types.NewPointer(dt.st)))
+
+	encoders := make([]func(c *calls, v Value) Value, len(dt.decl.Fields))
+	for i, field := range dt.decl.Fields {
+		_, fieldType := in.dataFieldAddr(dt, 0, field)
+		if encoders[i], err = in.fieldToJson(jsonPtr, fieldType, field, dt); err != nil {
+			return nil, err
+		}
+	}
+	fn.native = func(args []Value) (Value, error) {
+		c := &calls{in: in, fns: fns}
+		self := args[0].addr()
+		if self == 0 {
+			return c.call("newNull"), c.err
+		}
+		obj := c.call("newObject")
+		for i, field := range dt.decl.Fields {
+			addr, fieldType := in.dataFieldAddr(dt, self, field)
+			jv := encoders[i](c, in.load(addr, fieldType))
+			c.call("set", obj, in.cString(field.Name.Value), jv)
+		}
+		return obj, c.err
+	}
+	return fn, nil
+}
+
+// fieldToJson returns how the value of field, of type t, is converted to a
+// *json.JsonValue.
+func (in *Interpreter) fieldToJson(jsonPtr *types.PointerType, t types.Type, field *ast.Field, dt *dataType) (func(c *calls, v Value) Value, error) {
+	switch t := t.(type) {
+	case *types.IntType:
+		if t.BitSize == 1 {
+			return func(c *calls, v Value) Value { return c.call("newBool", v) }, nil
+		}
+		unsigned := field.Type != nil && unsignedTypeName(field.Type.Value)
+		return func(c *calls, v Value) Value {
+			n := v.signed()
+			if unsigned {
+				n = int64(v.unsigned())
+			}
+			return c.call("newInt", intValue(types.I64, n))
+		}, nil
+	case *types.FloatType:
+		return func(c *calls, v Value) Value { return c.call("newNumber", floatValue(types.Double, v.F)) }, nil
+	case *types.StructType:
+		if t.Equal(in.anyType()) {
+			return func(c *calls, v Value) Value {
+				kind, bits := in.unboxAny(v)
+				return c.call("fromAny", intValue(types.I64, kind), intValue(types.I64, int64(bits)))
+			}, nil
+		}
+	case *types.PointerType:
+		if t.Equal(i8Ptr) {
+			return func(c *calls, v Value) Value { return c.call("newString", v) }, nil
+		}
+		if t.Equal(jsonPtr) {
+			return func(c *calls, v Value) Value { return c.call("clone", v) }, nil
+		}
+		if st, ok := t.ElemType.(*types.StructType); ok {
+			if nested, ok := in.dataTypes[st.Name()]; ok {
+				toJson, err := in.dataFunc(nested, "toJson")
+				if err != nil {
+					return nil, err
+				}
+				return func(c *calls, v Value) Value {
+					if c.err != nil {
+						return noValue
+					}
+					var jv Value
+					jv, c.err = in.call(toJson, []Value{v})
+					return jv
+				}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("field '%s' of data type '%s' has type %s, which has no JSON form", field.Name.Value, dt.st.Name(), t)
+}
+
+// deriveFromJson derives T.fromJson(v) for a *json.JsonValue v. It stops at
+// the first member that fails to decode, freeing the T.
+func (in *Interpreter) deriveFromJson(dt *dataType, irName string) (*function, error) {
+	what := dt.st.Name() + ".fromJson"
+	jsonPtr, err := in.jsonValuePtr(what)
+	if err != nil {
+		return nil, err
+	}
+	fns, err := in.moduleFuncs(jsonModule, what, "errorCount", "expectObject", "get", "isNull", "decodeBool", "decodeInt",
+		"decodeNumber", "decodeString", "decodeValue", "anyKind", "decodeAnyBits")
+	if err != nil {
+		return nil, err
+	}
+	mem, err := in.moduleFuncs(memModule, what, "heapFree")
+	if err != nil {
+		return nil, err
+	}
+	fns["heapFree"] = mem["heapFree"]
+	ptrType := types.NewPointer(dt.st)
+	fn := in.derived(irName, types.NewFunc(ptrType, jsonPtr))
+	newT, err := in.dataFunc(dt, "new")
+	if err != nil {
+		return nil, err
+	}
+
+	decoders := make([]func(c *calls, child Value) Value, len(dt.decl.Fields))
+	for i, field := range dt.decl.Fields {
+		_, fieldType := in.dataFieldAddr(dt, 0, field)
+		if decoders[i], err = in.fieldFromJson(jsonPtr, fieldType, field, dt); err != nil {
+			return nil, err
+		}
+	}
+	fn.native = func(args []Value) (Value, error) {
+		c := &calls{in: in, fns: fns}
+		v := args[0]
+		before := c.call("errorCount")
+		if isObject := c.call("expectObject", v, in.cString(dt.st.Name())); c.err != nil || !condAsBool(isObject) {
+			return pointerValue(ptrType, 0), c.err
+		}
+		t, err := in.call(newT, nil)
+		if err != nil {
+			return noValue, err
+		}
+		for i, field := range dt.decl.Fields {
+			child := c.call("get", v, in.cString(field.Name.Value))
+			val := decoders[i](c, child)
+			if c.err != nil {
+				return noValue, c.err
+			}
+			addr, _ := in.dataFieldAddr(dt, t.addr(), field)
+			in.store(addr, val)
+			if c.call("errorCount").I != before.I {
+				c.call("heapFree", pointerValue(i8Ptr, t.addr()))
+				return pointerValue(ptrType, 0), c.err
+			}
+		}
+		return t, c.err
+	}
+	return fn, nil
+}
+
+// fieldFromJson returns how child, the member for field, is decoded into a
+// value of the field's type t.
+func (in *Interpreter) fieldFromJson(jsonPtr *types.PointerType, t types.Type, field *ast.Field, dt *dataType) (func(c *calls, child Value) Value, error) {
+	what := dt.st.Name() + "." + field.Name.Value
+	switch t := t.(type) {
+	case *types.IntType:
+		if t.BitSize == 1 {
+			return func(c *calls, child Value) Value { return c.call("decodeBool", child, in.cString(what)) }, nil
+		}
+		return func(c *calls, child Value) Value {
+			return intValue(t, c.call("decodeInt", child, in.cString(what)).I)
+		}, nil
+	case *types.FloatType:
+		return func(c *calls, child Value) Value {
+			return floatValue(t, c.call("decodeNumber", child, in.cString(what)).F)
+		}, nil
+	case *types.StructType:
+		if t.Equal(in.anyType()) {
+			return func(c *calls, child Value) Value {
+				kind := int64(int32(c.call("anyKind", child).I))
+				bits := c.call("decodeAnyBits", child)
+				return in.makeAny(kind, uint64(bits.I))
+			}, nil
+		}
+	case *types.PointerType:
+		if t.Equal(i8Ptr) {
+			return func(c *calls, child Value) Value { return c.call("decodeString", child, in.cString(what)) }, nil
+		}
+		if t.Equal(jsonPtr) {
+			return func(c *calls, child Value) Value { return c.call("decodeValue", child, in.cString(what)) }, nil
+		}
+		if st, ok := t.ElemType.(*types.StructType); ok {
+			if nested, ok := in.dataTypes[st.Name()]; ok {
+				fromJson, err := in.dataFunc(nested, "fromJson")
+				if err != nil {
+					return nil, err
+				}
+				// A missing or null member is a null pointer rather than an
+				// error.
+				return func(c *calls, child Value) Value {
+					if isNull := c.call("isNull", child); c.err != nil || condAsBool(isNull) {
+						return pointerValue(t, 0)
+					}
+					var v Value
+					v, c.err = in.call(fromJson, []Value{child})
+					return v
+				}, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("field '%s' of data type '%s' has type %s, which has no JSON form", field.Name.Value, dt.st.Name(), t)
+}
+
+// deriveFromJsonText derives T.fromJson(text), which parses text and decodes
+// the value. It returns null for malformed text, with the parse error in
+// json.lastError().
+func (in *Interpreter) deriveFromJsonText(dt *dataType, irName string) (*function, error) {
+	what := dt.st.Name() + ".fromJson"
+	fns, err := in.moduleFuncs(jsonModule, what, "parse", "freeValue")
+	if err != nil {
+		return nil, err
+	}
+	fromJson, err := in.dataFunc(dt, "fromJson")
+	if err != nil {
+		return nil, err
+	}
+	ptrType := types.NewPointer(dt.st)
+	fn := in.derived(irName, types.NewFunc(ptrType, i8Ptr))
+	fns["fromJson"] = fromJson
+	fn.native = func(args []Value) (Value, error) {
+		c := &calls{in: in, fns: fns}
+		v := c.call("parse", args[0])
+		if c.err != nil || v.addr() == 0 {
+			return pointerValue(ptrType, 0), c.err
+		}
+		t := c.call("fromJson", v)
+		c.call("freeValue", v)
+		return t, c.err
+	}
+	return fn, nil
+}
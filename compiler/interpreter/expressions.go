@@ -0,0 +1,682 @@
+package interpreter
+
+import (
+	"compiler/ast"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+// numberValue returns the constant a number literal denotes: an i32, an i64
+// when it does not fit, or a float constant keeping the literal's precision.
+func numberValue(nl *ast.NumberLiteral) Value {
+	f := nl.Value
+	intPart, frac := math.Modf(f)
+	if frac == 0.0 && !strings.Contains(nl.Token.Literal, ".") {
+		v, ok := nl.Int()
+		if !ok {
+			v = int64(intPart)
+		}
+		if v > math.MaxInt32 || v < math.MinInt32 {
+			return constInt(types.I64, v)
+		}
+		return constInt(types.I32, v)
+	}
+	return Value{T: types.Float, F: f, konst: true}
+}
+
+// stringAddr returns the address of the NUL-terminated data of a string
+// literal, allocated the first time the literal is evaluated.
+func (in *Interpreter) stringAddr(sl *ast.StringLiteral) uint64 {
+	if addr, ok := in.strings[sl]; ok {
+		return addr
+	}
+	addr := in.mem.allocData(uint64(len(sl.Value))+1, 1)
+	in.mem.writeBytes(addr, []byte(sl.Value))
+	in.strings[sl] = addr
+	return addr
+}
+
+func (in *Interpreter) VisitNumberLiteral(nl *ast.NumberLiteral) error {
+	v, ok := in.numbers[nl]
+	if !ok {
+		v = numberValue(nl)
+		in.numbers[nl] = v
+	}
+	in.last = v
+	return nil
+}
+
+func (in *Interpreter) VisitStringLiteral(sl *ast.StringLiteral) error {
+	in.last = pointerValue(i8Ptr, in.stringAddr(sl))
+	return nil
+}
+
+func (in *Interpreter) VisitBooleanLiteral(bl *ast.BooleanLiteral) error {
+	in.last = constBool(bl.Value)
+	return nil
+}
+
+func (in *Interpreter) VisitIdentifier(ident *ast.Identifier) error {
+	name := ident.Value
+	if b, ok := in.fr.vars[name]; ok {
+		switch {
+		case b.typ == nil:
+			in.last = b.konst
+		case in.lhs:
+			in.last = pointerValue(types.NewPointer(b.typ), b.addr)
+		default:
+			in.last = in.load(b.addr, b.typ)
+		}
+		return nil
+	}
+	if found, err := in.visitScopedName(in.scope, name); found {
+		return err
+	}
+	if f, ok := in.scope.functions[name]; ok {
+		in.last = f.value()
+		return nil
+	}
+	if f, ok := in.functions[name]; ok {
+		in.last = f.value()
+		return nil
+	}
+	// Compiled code declares unknown names as external functions returning
+	// int, which fail to link; here calling one fails.
+	if in.interactive {
+		return fmt.Errorf("undefined name '%s'", name)
+	}
+	f := in.newFunction(&function{name: name, typ: types.NewFunc(types.I32)})
+	in.functions[name] = f
+	in.last = f.value()
+	return nil
+}
+
+func (in *Interpreter) VisitPrefixExpression(pe *ast.PrefixExpression) error {
+	switch pe.Operator {
+	case "&":
+		return in.addressOf(pe.Right)
+	case "*":
+		return in.dereference(pe.Right)
+	}
+	if err := pe.Right.Accept(in); err != nil {
+		return err
+	}
+	operand := in.last
+	if !operand.valid() {
+		return nil
+	}
+	switch pe.Operator {
+	case "-":
+		switch t := operand.T.(type) {
+		case *types.FloatType:
+			in.last = floatValue(t, -operand.F)
+		case *types.IntType:
+			if operand.konst {
+				in.last = constInt(t, -operand.I)
+			} else {
+				in.last = intValue(t, -operand.I)
+			}
+		default:
+			return fmt.Errorf("cannot negate value of type %s", operand.T)
+		}
+	case "!":
+		in.last = boolValue(!condAsBool(operand))
+	case "~":
+		t, ok := operand.T.(*types.IntType)
+		if !ok {
+			return fmt.Errorf("operator '~' cannot be applied to value of type %s", operand.T)
+		}
+		in.last = intValue(t, ^operand.I)
+	default:
+		in.last = operand
+	}
+	return nil
+}
+
+// addressOf evaluates &expr: the address of a variable, field or element.
+func (in *Interpreter) addressOf(expr ast.ExpressionNode) error {
+	if in.isConstRef(expr) {
+		return fmt.Errorf("cannot take the address of constant '%s'", expr.String())
+	}
+	savedLHS := in.lhs
+	in.lhs = true
+	err := expr.Accept(in)
+	in.lhs = savedLHS
+	if err != nil {
+		return err
+	}
+	if !in.last.valid() || !isPointer(in.last.T) {
+		return fmt.Errorf("'%s' is not addressable", expr.String())
+	}
+	return nil
+}
+
+// dereference evaluates *expr, or the address it denotes when assigning
+// through it.
+func (in *Interpreter) dereference(expr ast.ExpressionNode) error {
+	isLHS := in.lhs
+	in.lhs = false
+	err := expr.Accept(in)
+	in.lhs = isLHS
+	if err != nil {
+		return err
+	}
+	ptr := in.last
+	if !ptr.valid() {
+		return fmt.Errorf("cannot dereference '%s': it has no value", expr.String())
+	}
+	elem := pointee(ptr.T)
+	if elem == nil {
+		return fmt.Errorf("cannot dereference '%s' of non-pointer type %s", expr.String(), ptr.T)
+	}
+	if isLHS {
+		in.last = ptr
+		return nil
+	}
+	in.last = in.load(ptr.addr(), elem)
+	return nil
+}
+
+func (in *Interpreter) VisitInfixExpression(ie *ast.InfixExpression) error {
+	if ie.Operator == "&&" || ie.Operator == "||" {
+		return in.logical(ie)
+	}
+	if err := ie.Left.Accept(in); err != nil {
+		return err
+	}
+	left := in.last
+	if err := ie.Right.Accept(in); err != nil {
+		return err
+	}
+	right := in.last
+	if !left.valid() || !right.valid() {
+		in.last = noValue
+		return nil
+	}
+	result, err := in.infix(ie.Operator, left, right)
+	if err != nil {
+		return err
+	}
+	in.last = result
+	return nil
+}
+
+// logical evaluates && and ||, skipping the right operand when the left one
+// decides the result.
+func (in *Interpreter) logical(ie *ast.InfixExpression) error {
+	if err := ie.Left.Accept(in); err != nil {
+		return err
+	}
+	if !in.last.valid() {
+		return fmt.Errorf("left operand of '%s' produced no value", ie.Operator)
+	}
+	left := condAsBool(in.last)
+	if left == (ie.Operator == "||") {
+		in.last = boolValue(left)
+		return nil
+	}
+	if err := ie.Right.Accept(in); err != nil {
+		return err
+	}
+	if !in.last.valid() {
+		return fmt.Errorf("right operand of '%s' produced no value", ie.Operator)
+	}
+	in.last = boolValue(condAsBool(in.last))
+	return nil
+}
+
+// infix applies a binary operator to two values.
+func (in *Interpreter) infix(op string, left, right Value) (Value, error) {
+	if isPointer(left.T) || isPointer(right.T) {
+		return in.pointerInfix(op, left, right)
+	}
+	if isFloat(left.T) || isFloat(right.T) {
+		return floatInfix(op, left, right)
+	}
+	lt, lInt := left.T.(*types.IntType)
+	rt, rInt := right.T.(*types.IntType)
+	if !lInt || !rInt {
+		return noValue, fmt.Errorf("operator '%s' is not defined for %s and %s", op, left.T, right.T)
+	}
+	// The narrower operand is widened: constants keep their value, booleans
+	// are zero extended and other integers sign extended.
+	t := lt
+	if rt.BitSize > t.BitSize {
+		t = rt
+	}
+	if lt != t {
+		left = intValue(t, left.I)
+	}
+	if rt != t {
+		right = intValue(t, right.I)
+	}
+	return intInfix(op, t, left, right), nil
+}
+
+// sigfpe is the signal x86 raises for an integer division by zero or an
+// overflowing division.
+const sigfpe = 8
+
+func intInfix(op string, t *types.IntType, left, right Value) Value {
+	a, b := left.signed(), right.signed()
+	switch op {
+	case "+":
+		return intValue(t, a+b)
+	case "-":
+		return intValue(t, a-b)
+	case "*":
+		return intValue(t, a*b)
+	case "/", "%":
+		// Narrow divisions are done in 32 bits, so only 32 and 64-bit ones
+		// overflow.
+		if b == 0 || (b == -1 && t.BitSize >= 32 && a == -1<<(t.BitSize-1)) {
+			panic(signal(sigfpe))
+		}
+		if op == "/" {
+			return intValue(t, a/b)
+		}
+		return intValue(t, a%b)
+	case "&":
+		return intValue(t, a&b)
+	case "|":
+		return intValue(t, a|b)
+	case "^":
+		return intValue(t, a^b)
+	case "<<", ">>", ">>>":
+		// x86 masks shift counts to 5 bits, or 6 for 64-bit operands.
+		count := uint64(b) & 31
+		if t.BitSize == 64 {
+			count = uint64(b) & 63
+		}
+		switch op {
+		case "<<":
+			return intValue(t, a<<count)
+		case ">>":
+			return intValue(t, a>>count)
+		}
+		return intValue(t, int64(left.unsigned()>>count))
+	case "==":
+		return boolValue(a == b)
+	case "!=":
+		return boolValue(a != b)
+	case "<":
+		return boolValue(a < b)
+	case ">":
+		return boolValue(a > b)
+	case "<=":
+		return boolValue(a <= b)
+	case ">=":
+		return boolValue(a >= b)
+	}
+	return left
+}
+
+// floatInfix applies an operator to operands at least one of which is a
+// float. The result is a double if either operand is.
+func floatInfix(op string, left, right Value) (Value, error) {
+	t := types.Float
+	if isDouble(left.T) || isDouble(right.T) {
+		t = types.Double
+	}
+	a, b := toFloat(left, t), toFloat(right, t)
+	switch op {
+	case "+":
+		return floatValue(t, a+b), nil
+	case "-":
+		return floatValue(t, a-b), nil
+	case "*":
+		return floatValue(t, a*b), nil
+	case "/":
+		return floatValue(t, a/b), nil
+	case "%":
+		return floatValue(t, math.Mod(a, b)), nil
+	case "==":
+		return boolValue(a == b), nil
+	case "!=":
+		return boolValue(a != b), nil
+	case "<":
+		return boolValue(a < b), nil
+	case ">":
+		return boolValue(a > b), nil
+	case "<=":
+		return boolValue(a <= b), nil
+	case ">=":
+		return boolValue(a >= b), nil
+	}
+	return noValue, fmt.Errorf("operator '%s' is not supported for floating point operands", op)
+}
+
+// toFloat returns the value of an operand of a floating point operation of
+// type t.
+func toFloat(v Value, t *types.FloatType) float64 {
+	var f float64
+	switch {
+	case isFloat(v.T):
+		f = v.F
+	case v.konst:
+		f = float64(v.I)
+	default:
+		f = float64(v.signed())
+	}
+	return roundTo(t, f)
+}
+
+// pointerInfix applies an operator to a pointer and an integer or two
+// pointers: pointer arithmetic is scaled by the size of the element, the
+// difference of two pointers counts elements and comparisons are unsigned.
+func (in *Interpreter) pointerInfix(op string, left, right Value) (Value, error) {
+	lPtr, rPtr := isPointer(left.T), isPointer(right.T)
+	lInt, rInt := isInt(left.T), isInt(right.T)
+	switch {
+	case op == "+" && lPtr && rInt:
+		return in.offset(left, right.I), nil
+	case op == "+" && lInt && rPtr:
+		return in.offset(right, left.I), nil
+	case op == "-" && lPtr && rInt:
+		return in.offset(left, -right.I), nil
+	case op == "-" && lPtr && rPtr:
+		if !left.T.Equal(right.T) {
+			return noValue, fmt.Errorf("cannot subtract %s from %s", right.T, left.T)
+		}
+		diff := left.I - right.I
+		if size := int64(in.sizeOf(pointee(left.T))); size > 1 {
+			diff /= size
+		}
+		return intValue(types.I64, diff), nil
+	}
+	if (lPtr || lInt) && (rPtr || rInt) {
+		a, b := uint64(left.I), uint64(right.I)
+		switch op {
+		case "==":
+			return boolValue(a == b), nil
+		case "!=":
+			return boolValue(a != b), nil
+		case "<":
+			return boolValue(a < b), nil
+		case ">":
+			return boolValue(a > b), nil
+		case "<=":
+			return boolValue(a <= b), nil
+		case ">=":
+			return boolValue(a >= b), nil
+		}
+	}
+	return noValue, fmt.Errorf("operator '%s' is not defined for %s and %s", op, left.T, right.T)
+}
+
+// offset returns ptr advanced by n elements.
+func (in *Interpreter) offset(ptr Value, n int64) Value {
+	size := int64(in.sizeOf(pointee(ptr.T)))
+	return pointerValue(ptr.T, ptr.addr()+uint64(n*size))
+}
+
+func (in *Interpreter) VisitCastExpression(ce *ast.CastExpression) error {
+	target, err := in.mapType(ce.Type.Value)
+	if err != nil {
+		return fmt.Errorf("cast to '%s': %w", ce.Type.Value, err)
+	}
+	if err := ce.Value.Accept(in); err != nil {
+		return err
+	}
+	v := in.last
+	if !v.valid() {
+		return fmt.Errorf("cast of '%s': expression produced no value", ce.Value.String())
+	}
+
+	if dst, ok := target.(*types.IntType); ok && unsignedTypeName(ce.Type.Value) {
+		switch src := v.T.(type) {
+		case *types.IntType:
+			if src.BitSize < dst.BitSize {
+				if v.konst {
+					v = constInt(dst, int64(v.unsigned()))
+				} else {
+					v = intValue(dst, int64(v.unsigned()))
+				}
+			}
+		case *types.FloatType:
+			v = Value{T: dst, I: fptoui(v.F, dst.BitSize)}
+		}
+	}
+	if src, ok := v.T.(*types.IntType); ok && isPointer(target) && src.BitSize < 64 {
+		v = intValue(types.I64, v.signed())
+	}
+	if dst, ok := target.(*types.PointerType); ok && isPointer(v.T) {
+		// An explicit cast reinterprets what the pointer points at.
+		in.last = pointerValue(dst, v.addr())
+		return nil
+	}
+
+	converted, err := in.convert(v, target)
+	if err != nil {
+		return fmt.Errorf("cannot cast '%s' to %s: %w", ce.Value.String(), target, err)
+	}
+	in.last = converted
+	return nil
+}
+
+func (in *Interpreter) VisitAssignmentExpression(ae *ast.AssignmentExpression) error {
+	if in.isConstRef(ae.Left) {
+		return fmt.Errorf("cannot assign to constant '%s'", ae.Left.String())
+	}
+	in.lhs = true
+	err := ae.Left.Accept(in)
+	in.lhs = false
+	if err != nil {
+		return err
+	}
+	ptr := in.last
+	if err := ae.Right.Accept(in); err != nil {
+		return err
+	}
+	rhs := in.last
+
+	elem := pointee(ptr.T)
+	if elem == nil {
+		return fmt.Errorf("cannot assign to '%s': it is not addressable", ae.Left.String())
+	}
+	if !rhs.valid() {
+		return fmt.Errorf("assignment to '%s': value expression produced no value", ae.Left.String())
+	}
+	stored := rhs
+	if !isPointer(elem) {
+		converted, err := in.convert(rhs, elem)
+		if err != nil {
+			return fmt.Errorf("assignment to '%s': %w", ae.Left.String(), err)
+		}
+		stored = converted
+	} else if converted, err := in.convert(rhs, elem); err == nil {
+		stored = converted
+	}
+	in.store(ptr.addr(), stored)
+	in.last = rhs
+	return nil
+}
+
+func (in *Interpreter) VisitMemberAccessExpression(mae *ast.MemberAccessExpression) error {
+	if scope := in.moduleRef(mae.Left); scope != nil {
+		return in.visitModuleMember(scope, mae)
+	}
+
+	isLHS := in.lhs
+	in.lhs = true
+	err := mae.Left.Accept(in)
+	in.lhs = isLHS
+	if err != nil {
+		return fmt.Errorf("error evaluating base for member access '%s': %w", mae.Member, err)
+	}
+	base := in.last
+	if !base.valid() {
+		return fmt.Errorf("error evaluating base for member access '%s': it produced no value", mae.Member)
+	}
+
+	var st *types.StructType
+	var addr uint64
+	if s := pointeeStruct(base.T); s != nil {
+		st, addr = s, base.addr()
+	} else if s := pointeeStruct(pointee(base.T)); s != nil {
+		st, addr = s, in.mem.readUint(base.addr(), 8)
+	}
+	if st == nil {
+		return fmt.Errorf("member access base does not resolve to a struct pointer, got %T", base.T)
+	}
+
+	name := mae.Member.Value
+	index, ok := in.fieldIndex(st, name)
+	if !ok {
+		if st.Name() == "Any" && !isLHS {
+			if v, ok := in.anyView(addr, name); ok {
+				in.last = v
+				return nil
+			}
+		}
+		return fmt.Errorf("field '%s' not found in struct type '%s'", name, st.Name())
+	}
+	fieldType := st.Fields[index]
+	fieldAddr := in.fieldAddr(st, addr, index)
+	if isLHS {
+		in.last = pointerValue(types.NewPointer(fieldType), fieldAddr)
+	} else {
+		in.last = in.load(fieldAddr, fieldType)
+	}
+	return nil
+}
+
+func (in *Interpreter) VisitIndexExpression(ie *ast.IndexExpression) error {
+	isLHS := in.lhs
+	in.lhs = true
+	err := ie.Left.Accept(in)
+	in.lhs = isLHS
+	if err != nil {
+		return fmt.Errorf("error evaluating base for index expression: %w", err)
+	}
+	base := in.last
+	stored := pointee(base.T)
+	if stored == nil {
+		return fmt.Errorf("index expression base alloca is not a pointer, but %T", base.T)
+	}
+
+	// The base holds a slice (a pointer to {length, data} or the struct
+	// itself), a pointer, or an address held in an integer.
+	var data Value
+	if st := pointeeStruct(stored); st != nil && len(st.Fields) > 1 {
+		structAddr := in.mem.readUint(base.addr(), 8)
+		data = in.load(in.fieldAddr(st, structAddr, 1), st.Fields[1])
+	} else if st, ok := stored.(*types.StructType); ok && len(st.Fields) > 1 {
+		data = in.load(in.fieldAddr(st, base.addr(), 1), st.Fields[1])
+	} else {
+		data = in.load(base.addr(), stored)
+		if isInt(data.T) {
+			data = pointerValue(i8Ptr, data.unsigned())
+		}
+	}
+
+	in.lhs = false
+	err = ie.Index.Accept(in)
+	in.lhs = isLHS
+	if err != nil {
+		return fmt.Errorf("error evaluating index for index expression: %w", err)
+	}
+	index := in.last
+	if !index.valid() {
+		return fmt.Errorf("error evaluating index for index expression: it produced no value")
+	}
+	elem := pointee(data.T)
+	if elem == nil {
+		return fmt.Errorf("data pointer for index expression is not a pointer type: %T", data.T)
+	}
+
+	elemAddr := in.offset(data, index.signed()).addr()
+	if isLHS {
+		in.last = pointerValue(data.T, elemAddr)
+	} else {
+		in.last = in.load(elemAddr, elem)
+	}
+	return nil
+}
+
+func (in *Interpreter) VisitArrayLiteral(al *ast.ArrayLiteral) error {
+	arrayType := in.structs["Array"].(*types.StructType)
+	count := len(al.Elements)
+	values := make([]Value, count)
+	for i, element := range al.Elements {
+		if err := element.Accept(in); err != nil {
+			return fmt.Errorf("error evaluating element %d for array literal: %w", i, err)
+		}
+		v := in.last
+		if !v.valid() || !v.konst {
+			return fmt.Errorf("non-constant element found in array literal - stack allocation needed (not fully implemented yet)")
+		}
+		converted, err := in.convert(v, types.I32)
+		if err != nil {
+			return fmt.Errorf("error evaluating element %d for array literal: %w", i, err)
+		}
+		values[i] = converted
+	}
+
+	var dataAddr uint64
+	if count > 0 {
+		dataAddr = in.alloca(types.NewArray(uint64(count), types.I32))
+		for i, v := range values {
+			in.store(dataAddr+uint64(4*i), v)
+		}
+	}
+	addr := in.alloca(arrayType)
+	in.store(in.fieldAddr(arrayType, addr, 0), intValue(types.I32, int64(count)))
+	in.store(in.fieldAddr(arrayType, addr, 1), pointerValue(arrayType.Fields[1], dataAddr))
+	in.last = pointerValue(types.NewPointer(arrayType), addr)
+	return nil
+}
+
+func (in *Interpreter) VisitNamedArgument(na *ast.NamedArgument) error {
+	return fmt.Errorf("named argument '%s' used outside of a call argument list at line %d", na.Name.Value, na.Token.Line+1)
+}
+
+// ternary evaluates cond ? a : b and its other spellings.
+func (in *Interpreter) ternary(cond, ifTrue, ifFalse ast.ExpressionNode) error {
+	if err := cond.Accept(in); err != nil {
+		return err
+	}
+	if !in.last.valid() {
+		return fmt.Errorf("condition '%s' produced no value", cond.String())
+	}
+	if condAsBool(in.last) {
+		return ifTrue.Accept(in)
+	}
+	return ifFalse.Accept(in)
+}
+
+func (in *Interpreter) VisitTraditionalTernaryExpression(tte *ast.TraditionalTernaryExpression) error {
+	return in.ternary(tte.Condition, tte.TrueExpr, tte.FalseExpr)
+}
+
+func (in *Interpreter) VisitLambdaStyleTernaryExpression(lte *ast.LambdaStyleTernaryExpression) error {
+	return in.ternary(lte.Condition, lte.TrueExpr, lte.FalseExpr)
+}
+
+func (in *Interpreter) VisitInlineIfElseTernaryExpression(iite *ast.InlineIfElseTernaryExpression) error {
+	return in.ternary(iite.Condition, iite.TrueExpr, iite.FalseExpr)
+}
+
+func (in *Interpreter) VisitDotOperator(do *ast.DotOperator) error {
+	return do.Left.Accept(in)
+}
+
+func (in *Interpreter) VisitSyscallExpression(se *ast.SyscallExpression) error {
+	var args [7]int64
+	for i, expr := range append([]ast.ExpressionNode{se.Num}, se.Args...) {
+		if err := expr.Accept(in); err != nil {
+			return err
+		}
+		if !in.last.valid() {
+			return fmt.Errorf("syscall argument produced no value")
+		}
+		if i < len(args) {
+			args[i] = in.last.signed()
+		}
+	}
+	in.last = intValue(types.I64, in.sys.call(args[0], args[1:]))
+	return nil
+}
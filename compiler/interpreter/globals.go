@@ -0,0 +1,453 @@
+package interpreter
+
+import (
+	"compiler/ast"
+	"fmt"
+	"math"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+// syscallTableName is the name through which programs reach the syscall
+// numbers of the target, e.g. syscall(SYS.openat, ...).
+const syscallTableName = "SYS"
+
+// newSyscallScope exposes a syscall table as a module of i64 constants.
+func newSyscallScope(numbers map[string]int64) *moduleScope {
+	scope := newModuleScope(syscallTableName, "")
+	for name, n := range numbers {
+		scope.consts[name] = constInt(types.I64, n)
+	}
+	return scope
+}
+
+// globalVar is a mutable module-level variable. Globals whose initializer is
+// not a compile-time constant are initialized on first use.
+type globalVar struct {
+	addr uint64
+	typ  types.Type
+
+	ls       *ast.LetStatement // the declaration of a lazy global
+	declType types.Type
+	scope    *moduleScope
+	ready    bool
+}
+
+// defineGlobal defines a top-level let or const of the current module.
+// Constants are folded; lets whose initializer is a constant are initialized
+// at once, others on first use.
+func (in *Interpreter) defineGlobal(ls *ast.LetStatement) error {
+	name := ls.Name.Value
+	kind := "let"
+	if ls.IsConst() {
+		kind = "const"
+	}
+	if _, isConst := in.scope.consts[name]; isConst {
+		return in.scope.qualify(fmt.Errorf("%s '%s': already declared at top level", kind, name))
+	}
+	if _, isGlobal := in.scope.globals[name]; isGlobal {
+		return in.scope.qualify(fmt.Errorf("%s '%s': already declared at top level", kind, name))
+	}
+
+	var declType types.Type
+	if ls.Type != nil {
+		t, err := in.mapType(ls.Type.Value)
+		if err != nil {
+			return in.scope.qualify(fmt.Errorf("%s '%s': %w", kind, name, err))
+		}
+		declType = t
+	}
+
+	init, constErr := in.evalConst(ls.Value)
+	if constErr == nil && declType != nil {
+		converted, err := in.convertConst(init, declType)
+		if err != nil {
+			return in.scope.qualify(fmt.Errorf("%s '%s': %w", kind, name, err))
+		}
+		init = converted
+	}
+
+	if ls.IsConst() {
+		if constErr != nil {
+			return in.scope.qualify(fmt.Errorf("const '%s': %w", name, constErr))
+		}
+		in.scope.consts[name] = init
+		return nil
+	}
+	if constErr == nil {
+		gv := &globalVar{typ: init.T, ready: true}
+		gv.addr = in.mem.allocData(in.sizeOf(init.T), in.alignOf(init.T))
+		in.store(gv.addr, init)
+		in.scope.globals[name] = gv
+		return nil
+	}
+	in.scope.globals[name] = &globalVar{ls: ls, declType: declType, scope: in.scope}
+	return nil
+}
+
+// initGlobal runs the initializer of a lazy global the first time it is used.
+func (in *Interpreter) initGlobal(gv *globalVar) error {
+	if gv.ready {
+		return nil
+	}
+	// Mark the global ready first so an initializer reaching the global again
+	// through a function it calls cannot recurse.
+	gv.ready = true
+	name := gv.ls.Name.Value
+
+	f := &function{name: gv.scope.prefix + name + ".init", typ: types.NewFunc(types.Void), scope: gv.scope}
+	savedFr, savedScope, savedLHS, savedLast := in.fr, in.scope, in.lhs, in.last
+	in.fr, in.scope, in.lhs, in.last = newFrame(f), gv.scope, false, noValue
+	defer func() { in.fr, in.scope, in.lhs, in.last = savedFr, savedScope, savedLHS, savedLast }()
+
+	qualify := func(err error) error {
+		return &compileError{gv.scope.qualify(err)}
+	}
+	if err := gv.ls.Value.Accept(in); err != nil {
+		return qualify(fmt.Errorf("let '%s': %w", name, err))
+	}
+	v := in.last
+	if !v.valid() {
+		return qualify(fmt.Errorf("let '%s': initializer does not produce a value", name))
+	}
+	if gv.declType != nil {
+		converted, err := in.convert(v, gv.declType)
+		if err != nil {
+			return qualify(fmt.Errorf("let '%s': %w", name, err))
+		}
+		v = converted
+	}
+	gv.typ = v.T
+	gv.addr = in.mem.allocData(in.sizeOf(v.T), in.alignOf(v.T))
+	in.store(gv.addr, v)
+	return nil
+}
+
+// visitScopedName resolves name among the constants and globals of scope. It
+// reports false when the scope declares no such constant or global.
+func (in *Interpreter) visitScopedName(scope *moduleScope, name string) (bool, error) {
+	if c, ok := scope.consts[name]; ok {
+		if in.lhs {
+			// Indexing a constant (e.g. a string) needs an address to start from.
+			tmp := in.localAlloca(slotKey{name: scope.path + "." + name, kind: slotConstCopy}, c.T)
+			in.store(tmp, c)
+			in.last = pointerValue(types.NewPointer(c.T), tmp)
+			return true, nil
+		}
+		in.last = c
+		return true, nil
+	}
+	if gv, ok := scope.globals[name]; ok {
+		if err := in.initGlobal(gv); err != nil {
+			return true, err
+		}
+		if gv.typ == nil {
+			// Used by its own initializer: still zero.
+			gv.typ = types.I32
+			gv.addr = in.mem.allocData(4, 4)
+		}
+		if in.lhs {
+			in.last = pointerValue(types.NewPointer(gv.typ), gv.addr)
+		} else {
+			in.last = in.load(gv.addr, gv.typ)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// moduleRef returns the scope of the module an expression names through its
+// import alias, or nil if the expression is not a module reference. Local
+// variables and the current module's own names take precedence over aliases.
+func (in *Interpreter) moduleRef(expr ast.ExpressionNode) *moduleScope {
+	ident, ok := expr.(*ast.Identifier)
+	if !ok {
+		return nil
+	}
+	if _, isVar := in.fr.vars[ident.Value]; isVar || in.scope.declares(ident.Value) {
+		return nil
+	}
+	path, ok := in.moduleAliases[ident.Value]
+	if !ok {
+		return nil
+	}
+	return in.modules[path]
+}
+
+// visitModuleMember resolves alias.member to a constant, global or function
+// declared by the referenced module.
+func (in *Interpreter) visitModuleMember(scope *moduleScope, mae *ast.MemberAccessExpression) error {
+	member := mae.Member.Value
+	if handled, err := in.visitScopedName(scope, member); handled {
+		return err
+	}
+	if fn, ok := scope.functions[member]; ok {
+		in.last = fn.value()
+		return nil
+	}
+	if scope.path == syscallTableName {
+		return fmt.Errorf("unknown syscall '%s.%s' on linux/%s", syscallTableName, member, in.target.Name)
+	}
+	return fmt.Errorf("module '%s' has no member '%s'", scope.path, member)
+}
+
+// isConstRef reports whether expr names a constant, either unqualified or
+// through a module alias.
+func (in *Interpreter) isConstRef(expr ast.ExpressionNode) bool {
+	switch e := expr.(type) {
+	case *ast.Identifier:
+		if b, isVar := in.fr.vars[e.Value]; isVar {
+			return b.typ == nil
+		}
+		_, ok := in.scope.consts[e.Value]
+		return ok
+	case *ast.MemberAccessExpression:
+		if scope := in.moduleRef(e.Left); scope != nil {
+			_, ok := scope.consts[e.Member.Value]
+			return ok
+		}
+	}
+	return false
+}
+
+// evalConst evaluates expr as a compile-time constant. Literals, other
+// constants, the constant intrinsics and arithmetic, bitwise, comparison and
+// logical operators over them are supported.
+func (in *Interpreter) evalConst(expr ast.ExpressionNode) (Value, error) {
+	switch e := expr.(type) {
+	case *ast.NumberLiteral:
+		return numberValue(e), nil
+	case *ast.BooleanLiteral:
+		v := boolValue(e.Value)
+		v.konst = true
+		return v, nil
+	case *ast.StringLiteral:
+		v := pointerValue(i8Ptr, in.stringAddr(e))
+		v.konst = true
+		return v, nil
+	case *ast.Identifier:
+		if b, isVar := in.fr.vars[e.Value]; isVar {
+			if b.typ == nil {
+				return b.konst, nil
+			}
+		} else if c, ok := in.scope.consts[e.Value]; ok {
+			return c, nil
+		}
+	case *ast.MemberAccessExpression:
+		if scope := in.moduleRef(e.Left); scope != nil {
+			if c, ok := scope.consts[e.Member.Value]; ok {
+				return c, nil
+			}
+		}
+	case *ast.CallExpression:
+		if v, ok, err := in.evalConstIntrinsic(e); ok {
+			return v, err
+		}
+	case *ast.PrefixExpression:
+		right, err := in.evalConst(e.Right)
+		if err != nil {
+			return noValue, err
+		}
+		return foldPrefix(e.Operator, right)
+	case *ast.CastExpression:
+		v, err := in.evalConst(e.Value)
+		if err != nil {
+			return noValue, err
+		}
+		target, err := in.mapType(e.Type.Value)
+		if err != nil {
+			return noValue, err
+		}
+		if src, ok := v.T.(*types.IntType); ok && unsignedTypeName(e.Type.Value) {
+			if dst, ok := target.(*types.IntType); ok && src.BitSize < dst.BitSize {
+				return constInt(dst, int64(v.unsigned())), nil
+			}
+		}
+		return in.convertConst(v, target)
+	case *ast.InfixExpression:
+		left, err := in.evalConst(e.Left)
+		if err != nil {
+			return noValue, err
+		}
+		right, err := in.evalConst(e.Right)
+		if err != nil {
+			return noValue, err
+		}
+		return foldInfix(e.Operator, left, right)
+	}
+	return noValue, fmt.Errorf("'%s' is not a compile-time constant", expr.String())
+}
+
+// constIdent spells a constant the way LLVM does in messages.
+func constIdent(v Value) string {
+	switch t := v.T.(type) {
+	case *types.IntType:
+		if t.BitSize == 1 {
+			return fmt.Sprint(v.I != 0)
+		}
+		return fmt.Sprint(v.I)
+	case *types.FloatType:
+		return fmt.Sprintf("%g", v.F)
+	}
+	return fmt.Sprintf("%s %d", v.T, v.I)
+}
+
+func foldPrefix(op string, c Value) (Value, error) {
+	switch t := c.T.(type) {
+	case *types.IntType:
+		switch op {
+		case "-":
+			return constInt(t, -c.I), nil
+		case "!":
+			v := boolValue(c.I == 0)
+			v.konst = true
+			return v, nil
+		case "~":
+			return constInt(t, ^c.I), nil
+		}
+	case *types.FloatType:
+		if op == "-" {
+			return Value{T: t, F: -c.F, konst: true}, nil
+		}
+	}
+	return noValue, fmt.Errorf("operator '%s' cannot be applied to constant %s", op, constIdent(c))
+}
+
+func constBool(b bool) Value {
+	v := boolValue(b)
+	v.konst = true
+	return v
+}
+
+func foldInfix(op string, l, r Value) (Value, error) {
+	li, lInt := l.T.(*types.IntType)
+	ri, rInt := r.T.(*types.IntType)
+	if lInt && rInt {
+		t := li
+		if ri.BitSize > t.BitSize {
+			t = ri
+		}
+		a, b := l.I, r.I
+		switch op {
+		case "+":
+			return constInt(t, a+b), nil
+		case "-":
+			return constInt(t, a-b), nil
+		case "*":
+			return constInt(t, a*b), nil
+		case "/", "%":
+			if b == 0 {
+				return noValue, fmt.Errorf("division by zero in constant expression")
+			}
+			if op == "/" {
+				return constInt(t, a/b), nil
+			}
+			return constInt(t, a%b), nil
+		case "&":
+			return constInt(t, a&b), nil
+		case "|":
+			return constInt(t, a|b), nil
+		case "^":
+			return constInt(t, a^b), nil
+		case "<<", ">>", ">>>":
+			if b < 0 || b >= int64(t.BitSize) {
+				return noValue, fmt.Errorf("shift count %d out of range in constant expression", b)
+			}
+			switch op {
+			case "<<":
+				return constInt(t, a<<uint(b)), nil
+			case ">>":
+				return constInt(t, a>>uint(b)), nil
+			}
+			u := uint64(a)
+			if t.BitSize < 64 {
+				u &= 1<<t.BitSize - 1
+			}
+			return constInt(t, int64(u>>uint(b))), nil
+		case "==":
+			return constBool(a == b), nil
+		case "!=":
+			return constBool(a != b), nil
+		case "<":
+			return constBool(a < b), nil
+		case ">":
+			return constBool(a > b), nil
+		case "<=":
+			return constBool(a <= b), nil
+		case ">=":
+			return constBool(a >= b), nil
+		case "&&":
+			return constBool(a != 0 && b != 0), nil
+		case "||":
+			return constBool(a != 0 || b != 0), nil
+		}
+		return noValue, fmt.Errorf("operator '%s' is not supported in constant expressions", op)
+	}
+
+	a, lNum := constFloat(l)
+	b, rNum := constFloat(r)
+	if !lNum || !rNum {
+		return noValue, fmt.Errorf("operator '%s' cannot be applied to constants %s and %s", op, constIdent(l), constIdent(r))
+	}
+	t := types.Float
+	if isDouble(l.T) || isDouble(r.T) {
+		t = types.Double
+	}
+	konst := func(f float64) (Value, error) { return Value{T: t, F: f, konst: true}, nil }
+	switch op {
+	case "+":
+		return konst(a + b)
+	case "-":
+		return konst(a - b)
+	case "*":
+		return konst(a * b)
+	case "/":
+		return konst(a / b)
+	case "%":
+		return konst(math.Mod(a, b))
+	case "==":
+		return constBool(a == b), nil
+	case "!=":
+		return constBool(a != b), nil
+	case "<":
+		return constBool(a < b), nil
+	case ">":
+		return constBool(a > b), nil
+	case "<=":
+		return constBool(a <= b), nil
+	case ">=":
+		return constBool(a >= b), nil
+	}
+	return noValue, fmt.Errorf("operator '%s' is not supported in constant expressions", op)
+}
+
+func constFloat(c Value) (float64, bool) {
+	switch c.T.(type) {
+	case *types.FloatType:
+		return c.F, true
+	case *types.IntType:
+		return float64(c.I), true
+	}
+	return 0, false
+}
+
+// convertConst converts a folded constant to t.
+func (in *Interpreter) convertConst(c Value, t types.Type) (Value, error) {
+	if c.T.Equal(t) {
+		return c, nil
+	}
+	switch target := t.(type) {
+	case *types.IntType:
+		switch c.T.(type) {
+		case *types.IntType:
+			return constInt(target, c.I), nil
+		case *types.FloatType:
+			return constInt(target, int64(c.F)), nil
+		}
+	case *types.FloatType:
+		if f, ok := constFloat(c); ok {
+			return Value{T: target, F: f, konst: true}, nil
+		}
+	}
+	return noValue, fmt.Errorf("cannot convert constant %s to %s", constIdent(c), t)
+}
@@ -0,0 +1,768 @@
+// Package interpreter runs Y programs by walking their syntax tree, without
+// generating code. It mirrors the semantics of the LLVM code generator: values
+// have the same types, integers wrap at the same widths, structs have the same
+// layout and pointers address an emulated memory, so the standard library,
+// which is written in Y on top of raw system calls, runs unchanged. The system
+// calls themselves are carried out with the host's, through the streams and
+// files of the Interpreter.
+//
+// Errors the code generator reports while compiling a function are reported
+// when the interpreter reaches the offending code, with the same message.
+package interpreter
+
+import (
+	"compiler/ast"
+	"compiler/compiler/target"
+	"compiler/lexer"
+	"compiler/module"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+// maxCallDepth bounds the nesting of calls, standing in for the stack of a
+// compiled program: deeper recursion crashes like a stack overflow.
+const maxCallDepth = 20000
+
+// Interpreter runs a program. Its exported fields configure the environment
+// the program sees and are read when Run starts.
+type Interpreter struct {
+	ModuleManager *module.ModuleManager
+	// Freestanding makes builtin.libm() false, as for programs linked
+	// without a C runtime.
+	Freestanding bool
+	// SourceFile names the program's source file in assertion failures.
+	SourceFile string
+	// Stdin, Stdout and Stderr are the program's file descriptors 0, 1 and
+	// 2; nil means the interpreter's own.
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+	// Args is the program's command line, its name first, and Env its
+	// environment.
+	Args []string
+	Env  []string
+
+	target    *target.Target
+	mem       *memory
+	layouts   map[*types.StructType]*structLayout
+	stackNext uint64
+
+	structs      map[string]types.Type
+	structFields map[string][]string
+	seqTypes     map[string]*types.StructType
+	dataTypes    map[string]*dataType
+
+	// functions holds the functions callable by their unqualified name, and
+	// funcs every function by its address.
+	functions map[string]*function
+	funcs     map[uint64]*function
+	textNext  uint64
+
+	scope         *moduleScope
+	modules       map[string]*moduleScope
+	moduleAliases map[string]string
+	finalizers    []*function
+
+	numbers       map[*ast.NumberLiteral]Value
+	strings       map[*ast.StringLiteral]uint64
+	cstrings      map[string]uint64
+	lambdas       map[*ast.LambdaExpression]*function
+	lambdaCounter int
+	seqAdapters   map[string]*function
+
+	// keeper tells which functions keep a seq argument past the call;
+	// definitions maps each declared function to its signature for it.
+	keeper      ast.ParamKeeper
+	definitions map[*ast.FunctionDefinition]*funcSignature
+
+	// The state of the code being run: its frame and stack, the value of
+	// the last expression and whether an address rather than a value is
+	// wanted.
+	fr    *frame
+	stack *stack
+	last  Value
+	lhs   bool
+	depth int
+	// site is the call being made, for the stack slots allocated for it.
+	site ast.Node
+	// interactive is set for a Session, where a name that is not defined is
+	// more likely mistyped than an external function.
+	interactive bool
+
+	sys        *system
+	procArgs   uint64 // the []string of the command line, once laid out
+	environ    uint64
+	coroutines map[uint64]*coroutine
+	stackPool  []*stack // stacks of finished generators
+}
+
+// New creates an interpreter for programs built for t, or for target.Default
+// when t is nil.
+func New(t *target.Target) *Interpreter {
+	if t == nil {
+		t = target.Default
+	}
+	in := &Interpreter{
+		ModuleManager: module.NewModuleManager(),
+		target:        t,
+		mem:           newMemory(),
+		layouts:       make(map[*types.StructType]*structLayout),
+		stackNext:     stackBase,
+		structs:       make(map[string]types.Type),
+		structFields:  make(map[string][]string),
+		seqTypes:      make(map[string]*types.StructType),
+		dataTypes:     make(map[string]*dataType),
+		functions:     make(map[string]*function),
+		funcs:         make(map[uint64]*function),
+		textNext:      textBase,
+		scope:         newModuleScope("", ""),
+		modules:       make(map[string]*moduleScope),
+		moduleAliases: make(map[string]string),
+		numbers:       make(map[*ast.NumberLiteral]Value),
+		strings:       make(map[*ast.StringLiteral]uint64),
+		cstrings:      make(map[string]uint64),
+		lambdas:       make(map[*ast.LambdaExpression]*function),
+		seqAdapters:   make(map[string]*function),
+		coroutines:    make(map[uint64]*coroutine),
+		definitions:   make(map[*ast.FunctionDefinition]*funcSignature),
+	}
+	in.keeper.Callee = in.keptCallee
+	in.stack = in.newStack()
+	in.fr = newFrame(nil)
+	in.newNamedStruct("Array", []string{"length", "data"}, types.I32, types.NewPointer(types.I32))
+	in.modules[syscallTableName] = newSyscallScope(t.Syscall.Numbers)
+	in.moduleAliases[syscallTableName] = syscallTableName
+	in.declareBuiltins()
+	return in
+}
+
+// exitStatus ends the program, as a panic unwinding the interpreter, when
+// it calls exit or exit_group.
+type exitStatus int
+
+// signal ends the program as if killed by a signal, as a panic unwinding the
+// interpreter. Faults are SIGSEGV.
+type signal int
+
+const sigsegv = 11
+
+// compileError is an error the code generator would report, already
+// qualified with the function it occurs in.
+type compileError struct {
+	err error
+}
+
+func (e *compileError) Error() string { return e.err.Error() }
+func (e *compileError) Unwrap() error { return e.err }
+
+// Run runs program: it declares the program and the modules it imports,
+// calls main, runs the finalizers of the modules and returns the exit status,
+// which is 128 plus the signal number when the program crashes. An error is
+// returned when the program does not compile.
+func (in *Interpreter) Run(program *ast.Program) (status int, err error) {
+	in.sys = newSystem(in)
+	defer in.sys.close()
+	defer in.stopCoroutines()
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case exitStatus:
+			status, err = int(r)&0xff, nil
+		case signal:
+			status, err = 128+int(r), nil
+		case fault:
+			status, err = 128+sigsegv, nil
+		default:
+			panic(r)
+		}
+	}()
+
+	if err := program.Accept(in); err != nil {
+		return -1, err
+	}
+	mainFn, ok := in.scope.functions["main"]
+	if !ok {
+		return -1, fmt.Errorf("program has no main function")
+	}
+	result, err := in.call(mainFn, nil)
+	if err != nil {
+		return -1, err
+	}
+	if result.valid() && isInt(result.T) {
+		status = int(int32(result.signed()))
+	}
+	if err := in.runFinalizers(); err != nil {
+		return -1, err
+	}
+	return status & 0xff, nil
+}
+
+// runFinalizers calls the fini functions of the imported modules, last
+// imported first.
+func (in *Interpreter) runFinalizers() error {
+	for i := len(in.finalizers) - 1; i >= 0; i-- {
+		if _, err := in.call(in.finalizers[i], nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moduleScope holds the top-level constants, globals, functions and types
+// declared by one module.
+type moduleScope struct {
+	path      string
+	file      string // Source file, for messages; "" for the main program
+	prefix    string // Prefix of the qualified names of the module's functions and types
+	consts    map[string]Value
+	globals   map[string]*globalVar
+	functions map[string]*function
+	types     map[string]types.Type
+	// imports are the paths of the imports through which the module was
+	// first reached, outermost first, which qualify its errors.
+	imports []string
+}
+
+func newModuleScope(path, prefix string) *moduleScope {
+	return &moduleScope{
+		path:      path,
+		prefix:    prefix,
+		consts:    make(map[string]Value),
+		globals:   make(map[string]*globalVar),
+		functions: make(map[string]*function),
+		types:     make(map[string]types.Type),
+	}
+}
+
+func (s *moduleScope) declares(name string) bool {
+	_, isConst := s.consts[name]
+	_, isGlobal := s.globals[name]
+	_, isFunc := s.functions[name]
+	return isConst || isGlobal || isFunc
+}
+
+// qualify wraps err the way compiling the module from the main program
+// would: in the imports that led to it.
+func (s *moduleScope) qualify(err error) error {
+	for i := len(s.imports) - 1; i >= 0; i-- {
+		err = fmt.Errorf("error visiting import %s: %w", s.imports[i], err)
+	}
+	return err
+}
+
+func (in *Interpreter) VisitProgram(program *ast.Program) error {
+	for _, is := range program.ImportStatements {
+		if err := is.Accept(in); err != nil {
+			return fmt.Errorf("error visiting import %s: %w", is.Path, err)
+		}
+	}
+	for _, cd := range program.ClassDeclarations {
+		if err := in.defineStructType(cd); err != nil {
+			return fmt.Errorf("error defining type %s: %w", cd.Name.Value, err)
+		}
+	}
+	for _, ds := range program.DataStructures {
+		if err := in.defineDataType(ds); err != nil {
+			return fmt.Errorf("error defining data type %s: %w", ds.Name.Value, err)
+		}
+	}
+	if program.MainFunction != nil {
+		if err := in.declareFunction(program.MainFunction); err != nil {
+			return fmt.Errorf("error declaring main function: %w", err)
+		}
+	}
+	for _, fn := range program.Functions {
+		if err := in.declareFunction(fn); err != nil {
+			return fmt.Errorf("error declaring function %s: %w", fn.Name.Value, err)
+		}
+	}
+	globals, err := ast.InitOrder(program.Globals)
+	if err != nil {
+		return in.scope.qualify(err)
+	}
+	for _, ls := range globals {
+		if err := in.defineGlobal(ls); err != nil {
+			return err
+		}
+	}
+	for _, cd := range program.ClassDeclarations {
+		if err := in.declareMethods(cd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (in *Interpreter) VisitImportStatement(is *ast.ImportStatement) error {
+	in.moduleAliases[path.Base(is.Path)] = is.Path
+	if _, done := in.modules[is.Path]; done {
+		return nil
+	}
+	mod, err := in.ModuleManager.LoadModule(is.Path)
+	if err != nil {
+		return err
+	}
+	scope := newModuleScope(is.Path, path.Base(is.Path)+".")
+	scope.file = mod.Path
+	scope.imports = append(append([]string(nil), in.scope.imports...), is.Path)
+	in.modules[is.Path] = scope
+
+	outer := in.scope
+	in.scope = scope
+	defer func() { in.scope = outer }()
+	if err := mod.AST.Accept(in); err != nil {
+		return err
+	}
+
+	if fini, ok := scope.functions["fini"]; ok {
+		if len(fini.typ.Params) != 0 {
+			return fmt.Errorf("fini of module %s must not take parameters", is.Path)
+		}
+		in.finalizers = append(in.finalizers, fini)
+	}
+	return nil
+}
+
+// function is a function of the program: a declared function, a method, a
+// lambda, the start of a generator or a function provided by the
+// interpreter.
+type function struct {
+	name string // qualified name, as in compiled code
+	typ  *types.FuncType
+	addr uint64
+
+	params []string
+	body   ast.ExpressionNode
+	scope  *moduleScope
+	sig    *funcSignature
+
+	// method functions return zero when their body does not return.
+	method bool
+	// exprBody marks lambdas whose body is an expression of a type not yet
+	// known; the value of the first call gives the return type.
+	exprBody bool
+	// gen is set on the function starting a generator.
+	gen *generatorInfo
+	// native implements functions provided by the interpreter.
+	native func(args []Value) (Value, error)
+
+	// wrap qualifies errors in the body the way the code generator does.
+	wrap func(error) error
+}
+
+// funcSignature records the source-level parameter list of a function so
+// that call sites can bind named arguments and fill in default values. Offset
+// is the number of implicit leading parameters (1 for a method's self or a
+// generator's frame).
+type funcSignature struct {
+	Name   string
+	Params []*ast.Parameter
+	Token  lexer.LangToken
+	Offset int
+	Scope  *moduleScope
+	// Def is the function's declaration, nil for generated functions.
+	Def *ast.FunctionDefinition
+}
+
+// newFunction gives f an address and returns it.
+func (in *Interpreter) newFunction(f *function) *function {
+	f.addr = in.textNext
+	in.textNext += 16
+	in.funcs[f.addr] = f
+	return f
+}
+
+// value returns the value naming f.
+func (f *function) value() Value {
+	return Value{T: types.NewPointer(f.typ), I: int64(f.addr), fn: f}
+}
+
+func (in *Interpreter) declareFunction(fn *ast.FunctionDefinition) error {
+	if fn.Name == nil || fn.Name.Value == "" {
+		return fmt.Errorf("declareFunction received anonymous function AST node")
+	}
+	fnName := fn.Name.Value
+	irName := in.scope.prefix + fnName
+	if _, exists := in.scope.functions[fnName]; exists {
+		return nil
+	}
+	if existing, exists := in.functions[fnName]; exists && existing.name == irName {
+		return nil
+	}
+
+	scope := in.scope
+	wrap := func(err error) error {
+		if fnName == "main" && scope.path == "" {
+			return fmt.Errorf("error visiting main function: error generating body for function 'main': %w", err)
+		}
+		return scope.qualify(fmt.Errorf("error visiting function %s: error generating body for function '%s': %w", fnName, fnName, err))
+	}
+
+	if fn.Generator {
+		f, err := in.declareGenerator(fn, irName)
+		if err != nil {
+			return err
+		}
+		f.wrap = func(err error) error {
+			return scope.qualify(fmt.Errorf("error visiting function %s: error generating body for generator '%s': %w", fnName, fnName, err))
+		}
+		in.registerFunction(fnName, f, &funcSignature{Name: fnName, Params: fn.Parameters, Token: fn.Token, Offset: 1, Scope: in.scope, Def: fn})
+		return nil
+	}
+
+	paramTypes := make([]types.Type, len(fn.Parameters))
+	paramNames := make([]string, len(fn.Parameters))
+	for i, p := range fn.Parameters {
+		t, err := in.paramType(p)
+		if err != nil {
+			return fmt.Errorf("parameter '%s' of function '%s': %w", p.Name.Value, fnName, err)
+		}
+		paramTypes[i] = t
+		paramNames[i] = p.Name.Value
+	}
+
+	var retType types.Type = types.I32
+	if fn.ReturnType != nil {
+		if t, err := in.mapType(fn.ReturnType.Value); err == nil {
+			retType = t
+		}
+	} else if in.infersVoid(fn.Body) {
+		retType = types.Void
+	}
+
+	f := in.newFunction(&function{
+		name:   irName,
+		typ:    types.NewFunc(retType, paramTypes...),
+		params: paramNames,
+		body:   fn.Body,
+		scope:  in.scope,
+		wrap:   wrap,
+	})
+	in.registerFunction(fnName, f, &funcSignature{Name: fnName, Params: fn.Parameters, Token: fn.Token, Scope: in.scope, Def: fn})
+	return nil
+}
+
+// infersVoid reports whether a function without a declared return type
+// returns void: when its body is empty or only runs asm that produces no
+// value.
+func (in *Interpreter) infersVoid(body ast.ExpressionNode) bool {
+	if body == nil {
+		return true
+	}
+	block, ok := body.(*ast.BlockStatement)
+	if !ok {
+		return false
+	}
+	for _, stmt := range block.Statements {
+		es, ok := stmt.(*ast.ExpressionStatement)
+		if !ok {
+			return false
+		}
+		ae, ok := es.Expression.(*ast.AssemblyExpression)
+		if !ok {
+			return false
+		}
+		isVoid := ae.Block
+		if builtin, exists := in.functions[ae.Code.Value]; exists && builtin.typ.RetType.Equal(types.Void) {
+			isVoid = true
+		}
+		if !isVoid {
+			return false
+		}
+	}
+	return true
+}
+
+// registerFunction makes a declared function callable by name from the
+// current module.
+func (in *Interpreter) registerFunction(fnName string, f *function, sig *funcSignature) {
+	in.scope.functions[fnName] = f
+	if _, taken := in.functions[fnName]; !taken || in.scope.prefix == "" {
+		in.functions[fnName] = f
+	}
+	f.sig = sig
+	if sig.Def != nil {
+		in.definitions[sig.Def] = sig
+	}
+}
+
+// declareMethods declares the methods of a type declaration, as functions
+// named Type_method taking self first.
+func (in *Interpreter) declareMethods(cd *ast.ClassDeclaration) error {
+	className := cd.Name.Value
+	for _, member := range cd.Members {
+		md := member.MethodDeclaration
+		if md == nil {
+			continue
+		}
+		if err := in.declareMethod(className, md); err != nil {
+			return in.scope.qualify(fmt.Errorf("error generating method '%s' for type '%s': %w", md.Name.Value, className, err))
+		}
+	}
+	return nil
+}
+
+func (in *Interpreter) declareMethod(className string, md *ast.MethodDeclaration) error {
+	methodName := md.Name.Value
+	mangledName := className + "_" + methodName
+	if _, exists := in.functions[mangledName]; exists {
+		return nil
+	}
+	selfType, ok := in.scope.types[className].(*types.StructType)
+	if !ok {
+		return fmt.Errorf("internal error: struct type '%s' not found when generating method '%s'", className, methodName)
+	}
+	selfPtrType := types.NewPointer(selfType)
+	paramTypes := []types.Type{selfPtrType}
+	paramNames := []string{"self"}
+	for _, p := range md.Parameters {
+		if p.Type == nil {
+			return fmt.Errorf("type annotation missing for parameter '%s' in method '%s'", p.Name.Value, methodName)
+		}
+		t, err := in.mapType(p.Type.Value)
+		if err != nil {
+			return fmt.Errorf("could not map type '%s' for parameter '%s' in method '%s': %w", p.Type.Value, p.Name.Value, methodName, err)
+		}
+		paramTypes = append(paramTypes, t)
+		paramNames = append(paramNames, p.Name.Value)
+	}
+	var retType types.Type = types.Void
+	if md.ReturnType != nil {
+		t, err := in.mapType(md.ReturnType.Value)
+		if err != nil {
+			return fmt.Errorf("could not map return type '%s' for method '%s': %w", md.ReturnType.Value, methodName, err)
+		}
+		retType = t
+		if md.ReturnType.Value == className {
+			retType = selfPtrType
+		}
+	}
+
+	scope := in.scope
+	f := in.newFunction(&function{
+		name:   mangledName,
+		typ:    types.NewFunc(retType, paramTypes...),
+		params: paramNames,
+		body:   md.Body,
+		scope:  scope,
+		method: true,
+		wrap: func(err error) error {
+			return scope.qualify(fmt.Errorf("error generating method '%s' for type '%s': error generating body for method '%s': %w", methodName, className, mangledName, err))
+		},
+	})
+	f.sig = &funcSignature{Name: className + "." + methodName, Params: md.Parameters, Token: md.Token, Offset: 1, Scope: scope}
+	in.functions[mangledName] = f
+	return nil
+}
+
+// frame is the activation of a function.
+type frame struct {
+	fn   *function
+	vars map[string]*binding
+	// slots holds the stack slots the code generator allocates once per
+	// function, at its entry, keyed by what they are for, so loops reuse
+	// them.
+	slots map[slotKey]slot
+
+	returned  bool
+	ret       Value
+	loopDepth int
+	gen       *coroutine // the generator the frame runs, if any
+	base      uint64     // the stack pointer on entry, below the frame's slots
+}
+
+// slotKey identifies a slot allocated once per function: the node it is for,
+// a name when one node needs several, and its kind.
+type slotKey struct {
+	node ast.Node
+	name string
+	kind int
+}
+
+const (
+	slotLocal = iota
+	slotConstCopy
+	slotLoopItem
+	slotGenFrame
+	slotAdapter
+	slotPack
+)
+
+type slot struct {
+	addr uint64
+	typ  types.Type
+}
+
+// binding is what a local name stands for: a stack slot holding a value of
+// type typ, or a constant.
+type binding struct {
+	addr  uint64
+	typ   types.Type // nil for a constant
+	konst Value
+	sig   *funcSignature
+}
+
+func newFrame(f *function) *frame {
+	return &frame{fn: f, vars: make(map[string]*binding, 8)}
+}
+
+// alloca reserves a stack slot for a value of type t.
+func (in *Interpreter) alloca(t types.Type) uint64 {
+	return in.stack.alloc(in.sizeOf(t), in.alignOf(t))
+}
+
+// entryAlloca returns the slot of type t the current function allocates
+// once for key.
+func (in *Interpreter) entryAlloca(key slotKey, t types.Type) uint64 {
+	if s, ok := in.fr.slots[key]; ok && s.typ.Equal(t) {
+		return s.addr
+	}
+	addr := in.alloca(t)
+	if in.fr.slots == nil {
+		in.fr.slots = make(map[slotKey]slot)
+	}
+	in.fr.slots[key] = slot{addr, t}
+	return addr
+}
+
+// localAlloca allocates the slot of a local variable: inside a loop the code
+// generator hoists it to the function's entry, so it is allocated once.
+func (in *Interpreter) localAlloca(key slotKey, t types.Type) uint64 {
+	if in.fr.loopDepth > 0 {
+		return in.entryAlloca(key, t)
+	}
+	return in.alloca(t)
+}
+
+// call calls f with args, which must already have the types of its
+// parameters, and returns its result, invalid for void functions.
+func (in *Interpreter) call(f *function, args []Value) (Value, error) {
+	if f.body == nil && f.wrap == nil && f.native == nil {
+		return noValue, fmt.Errorf("undefined function '%s'", f.name)
+	}
+	if len(args) != len(f.typ.Params) {
+		return noValue, fmt.Errorf("argument count mismatch for call to '%s': expected %d, got %d", f.name, len(f.typ.Params), len(args))
+	}
+	args = append([]Value(nil), args...)
+	for i, t := range f.typ.Params {
+		if converted, err := in.convert(args[i], t); err == nil {
+			args[i] = converted
+		}
+	}
+	if f.native != nil {
+		return f.native(args)
+	}
+	if f.gen != nil {
+		return in.startGenerator(f, args)
+	}
+	in.depth++
+	if in.depth > maxCallDepth {
+		panic(fault{in.stack.sp})
+	}
+	savedFr, savedScope, savedLHS, savedSP := in.fr, in.scope, in.lhs, in.stack.sp
+	defer func() {
+		in.fr, in.scope, in.lhs, in.stack.sp = savedFr, savedScope, savedLHS, savedSP
+		in.depth--
+	}()
+
+	fr := newFrame(f)
+	fr.base = savedSP
+	in.fr, in.scope, in.lhs, in.last = fr, f.scope, false, noValue
+	params := make([]binding, len(f.params))
+	for i, name := range f.params {
+		t := f.typ.Params[i]
+		params[i] = binding{addr: in.alloca(t), typ: t}
+		in.store(params[i].addr, args[i])
+		fr.vars[name] = &params[i]
+	}
+
+	if err := in.runBody(f); err != nil {
+		return noValue, err
+	}
+	if f.exprBody {
+		if in.last.valid() && firstClass(in.last.T) {
+			f.typ.RetType = in.last.T
+		}
+		f.exprBody = false
+	}
+	retType := f.typ.RetType
+	if fr.returned {
+		if retType.Equal(types.Void) {
+			return noValue, nil
+		}
+		return fr.ret, nil
+	}
+	if !f.method && in.last.valid() && in.last.T.Equal(retType) {
+		if err := in.checkSeqReturn(in.last); err != nil {
+			return noValue, &compileError{f.wrap(err)}
+		}
+		return in.last, nil
+	}
+	if retType.Equal(types.Void) {
+		return noValue, nil
+	}
+	return in.zeroValue(retType), nil
+}
+
+// runBody runs the body of f in the current frame, qualifying the errors it
+// raises with f.
+func (in *Interpreter) runBody(f *function) error {
+	if f.body == nil {
+		return nil
+	}
+	err := f.body.Accept(in)
+	if err == nil {
+		return nil
+	}
+	// Errors of a function called from here are already qualified.
+	var ce *compileError
+	if errors.As(err, &ce) {
+		return ce
+	}
+	return &compileError{f.wrap(err)}
+}
+
+// declareBuiltins declares the functions the code generator provides
+// without a definition in Y.
+func (in *Interpreter) declareBuiltins() {
+	add := func(name string, t *types.FuncType, native func(args []Value) (Value, error)) *function {
+		f := in.newFunction(&function{name: name, typ: t, native: native})
+		in.functions[name] = f
+		return f
+	}
+	add("malloc", types.NewFunc(i8Ptr, types.I64), func(args []Value) (Value, error) {
+		return pointerValue(i8Ptr, in.mem.mmap(uint64(args[0].I))), nil
+	})
+	add("builtin_print_int", types.NewFunc(types.Void, types.I32), func(args []Value) (Value, error) {
+		in.sys.writeFD(1, []byte(fmt.Sprint(args[0].signed())))
+		return noValue, nil
+	})
+	add("builtin_print_newline", types.NewFunc(types.Void), func(args []Value) (Value, error) {
+		in.sys.writeFD(1, []byte("\n"))
+		return noValue, nil
+	})
+	arrayMap := add("builtin_array_map", types.NewFunc(types.NewPointer(types.I32)), nil)
+	in.functions["Array_map"] = arrayMap
+	arrayForEach := add("builtin_array_forEach", types.NewFunc(types.Void), nil)
+	in.functions["Array_forEach"] = arrayForEach
+}
+
+// stdio returns the streams of the program, defaulting to the
+// interpreter's own.
+func (in *Interpreter) stdio() (io.Reader, io.Writer, io.Writer) {
+	stdin, stdout, stderr := in.Stdin, in.Stdout, in.Stderr
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	return stdin, stdout, stderr
+}
@@ -0,0 +1,202 @@
+package interpreter
+
+import (
+	"bytes"
+	"compiler/lexer"
+	"compiler/parser"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runSource interprets src with stdin as its standard input and returns what
+// it wrote to stdout and stderr, and its exit status.
+func runSource(t *testing.T, src, stdin string, args ...string) (string, string, int, error) {
+	t.Helper()
+	l, err := lexer.NewLexerFromString(src)
+	if err != nil {
+		t.Fatalf("lexer error: %v", err)
+	}
+	p := parser.NewParser(l)
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) != 0 {
+		t.Fatalf("parser errors: %v", errs)
+	}
+
+	var stdout, stderr bytes.Buffer
+	in := New(nil)
+	in.Freestanding = true
+	in.Stdin = strings.NewReader(stdin)
+	in.Stdout, in.Stderr = &stdout, &stderr
+	in.Args = append([]string{"program"}, args...)
+	in.ModuleManager.AddSearchPath(filepath.Join("..", "..", "lib"))
+	status, err := in.Run(program)
+	return stdout.String(), stderr.String(), status, err
+}
+
+// TestInterpreterSystemCalls covers the system calls the interpreter carries
+// out for a program: the standard streams, descriptors of the host, memory
+// mappings and the calls it cannot make.
+func TestInterpreterSystemCalls(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "data.txt")
+	if err := os.WriteFile(file, []byte("mapped file\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		input          string
+		stdin          string
+		args           []string
+		expectedStdout string
+		expectedStderr string
+		expectedStatus int
+	}{
+		{
+			name: "Standard Streams",
+			input: `
+			import "stdlib/mem";
+			main() -> {
+				let buf = alloc(16);
+				let n = syscall(SYS.read, 0, buf, 16, 0, 0, 0);
+				syscall(SYS.write, 1, buf, n, 0, 0, 0);
+				syscall(SYS.write, 2, "oops\n", 5, 0, 0, 0);
+				return n;
+			}`,
+			stdin:          "echo\n",
+			expectedStdout: "echo\n",
+			expectedStderr: "oops\n",
+			expectedStatus: 5,
+		},
+		{
+			name: "Bad Descriptor",
+			input: `main() -> {
+				return -syscall(SYS.write, 9, "x", 1, 0, 0, 0);
+			}`,
+			expectedStatus: 9, // EBADF
+		},
+		{
+			name: "Host Files Take The Lowest Free Descriptor",
+			input: `
+			import "stdlib/fmt";
+			import "stdlib/mem";
+			import "stdlib/os";
+			main() -> {
+				let fd = syscall(SYS.openat, -100, os.args()[1], 0, 0, 0, 0);
+				let buf = alloc(64);
+				let n = syscall(SYS.read, fd, buf, 64, 0, 0, 0);
+				syscall(SYS.write, 1, buf, n, 0, 0, 0);
+				printf("%d %d\n", fd, n);
+				syscall(SYS.close, fd, 0, 0, 0, 0, 0);
+				return syscall(SYS.read, fd, buf, 64, 0, 0, 0);
+			}`,
+			args:           []string{file},
+			expectedStdout: "mapped file\n3 12\n",
+			expectedStatus: 256 - 9,
+		},
+		{
+			name: "File Mapping",
+			input: `
+			import "stdlib/os";
+			main() -> {
+				let fd = syscall(SYS.openat, -100, os.args()[1], 0, 0, 0, 0);
+				let p = syscall(SYS.mmap, 0, 4096, 1, 2, fd, 0) as *u8;
+				syscall(SYS.write, 1, p, 6, 0, 0, 0);
+				return p[12];
+			}`,
+			args:           []string{file},
+			expectedStdout: "mapped",
+		},
+		{
+			name: "Exit",
+			input: `main() -> {
+				syscall(SYS.write, 1, "bye\n", 4, 0, 0, 0);
+				syscall(SYS.exit_group, 3, 0, 0, 0, 0, 0);
+				return 0;
+			}`,
+			expectedStdout: "bye\n",
+			expectedStatus: 3,
+		},
+		{
+			name: "Signal To Self",
+			input: `main() -> {
+				syscall(SYS.kill, syscall(SYS.getpid, 0, 0, 0, 0, 0, 0), 15, 0, 0, 0, 0);
+				return 0;
+			}`,
+			expectedStatus: 128 + 15,
+		},
+		{
+			name: "No Threads Or Processes",
+			input: `main() -> {
+				return -syscall(SYS.clone, 0, 0, 0, 0, 0, 0);
+			}`,
+			expectedStatus: 38, // ENOSYS
+		},
+		{
+			name: "Null Dereference",
+			input: `main() -> {
+				let p = 0 as *i64;
+				return p[0];
+			}`,
+			expectedStatus: 128 + 11,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, status, err := runSource(t, tt.input, tt.stdin, tt.args...)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if stdout != tt.expectedStdout {
+				t.Errorf("stdout mismatch\nGot:  %q\nWant: %q", stdout, tt.expectedStdout)
+			}
+			if stderr != tt.expectedStderr {
+				t.Errorf("stderr mismatch\nGot:  %q\nWant: %q", stderr, tt.expectedStderr)
+			}
+			if status != tt.expectedStatus {
+				t.Errorf("exit status %d, want %d", status, tt.expectedStatus)
+			}
+		})
+	}
+}
+
+// TestInterpreterCompileErrors covers programs the code generator rejects,
+// which the interpreter reports the same way before running them.
+func TestInterpreterCompileErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expectedError string
+	}{
+		{
+			name:          "Unknown Syscall",
+			input:         `main() -> { return syscall(SYS.no_such_call); }`,
+			expectedError: "unknown syscall 'SYS.no_such_call'",
+		},
+		{
+			name:          "Missing Main",
+			input:         `function f(): i64 -> { return 1; }`,
+			expectedError: "program has no main function",
+		},
+		{
+			name:          "Inline Assembly",
+			input:         `main() -> { asm { "movl $1, %eax" } return 0; }`,
+			expectedError: "cannot be interpreted",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, _, err := runSource(t, tt.input, "")
+			if err == nil {
+				t.Fatalf("expected error containing %q, got none", tt.expectedError)
+			}
+			if !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("error %q does not contain %q", err, tt.expectedError)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package interpreter
+
+import (
+	"compiler/ast"
+	"fmt"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+// builtinModule is the name through which programs reach the intrinsics of
+// the compiler, e.g. builtin.atomicAdd(&n, 1, SEQ_CST); see the code
+// generator's intrinsics.go.
+const builtinModule = "builtin"
+
+// intrinsic is a function of the builtin module. Its arguments are evaluated
+// as values and counted before run is called with the intrinsic's name
+// without the module.
+type intrinsic struct {
+	arity int
+	run   func(in *Interpreter, name string, args []Value) error
+}
+
+// intrinsics are the functions of the builtin module, by name.
+var intrinsics = map[string]intrinsic{
+	"args":    {0, (*Interpreter).visitArgs},
+	"environ": {0, (*Interpreter).visitEnviron},
+	"exit":    {1, (*Interpreter).visitExit},
+
+	"threadStart": {7, (*Interpreter).visitThreadStart},
+	"tlsSize":     {0, (*Interpreter).visitTLSIntrinsic},
+	"tlsInit":     {1, (*Interpreter).visitTLSIntrinsic},
+
+	"atomicLoad":  {2, (*Interpreter).visitAtomic},
+	"atomicStore": {3, (*Interpreter).visitAtomic},
+	"atomicCas":   {4, (*Interpreter).visitAtomic},
+	"atomicAdd":   {3, (*Interpreter).visitAtomic},
+	"atomicSub":   {3, (*Interpreter).visitAtomic},
+	"atomicAnd":   {3, (*Interpreter).visitAtomic},
+	"atomicOr":    {3, (*Interpreter).visitAtomic},
+	"atomicXor":   {3, (*Interpreter).visitAtomic},
+	"atomicSwap":  {3, (*Interpreter).visitAtomic},
+	"atomicMax":   {3, (*Interpreter).visitAtomic},
+	"atomicMin":   {3, (*Interpreter).visitAtomic},
+	"fence":       {1, (*Interpreter).visitAtomic},
+
+	"epollEventSize": {0, (*Interpreter).visitEpollEventSize},
+	"oDirectory":     {0, (*Interpreter).visitODirectory},
+
+	"libm":          {0, (*Interpreter).visitLibm},
+	"sqrt":          {1, (*Interpreter).visitFloatIntrinsic},
+	"fabs":          {1, (*Interpreter).visitFloatIntrinsic},
+	"floor":         {1, (*Interpreter).visitFloatIntrinsic},
+	"ceil":          {1, (*Interpreter).visitFloatIntrinsic},
+	"trunc":         {1, (*Interpreter).visitFloatIntrinsic},
+	"exp":           {1, (*Interpreter).visitFloatIntrinsic},
+	"log":           {1, (*Interpreter).visitFloatIntrinsic},
+	"sin":           {1, (*Interpreter).visitFloatIntrinsic},
+	"cos":           {1, (*Interpreter).visitFloatIntrinsic},
+	"pow":           {2, (*Interpreter).visitFloatIntrinsic},
+	"addOverflow":   {3, (*Interpreter).visitOverflow},
+	"subOverflow":   {3, (*Interpreter).visitOverflow},
+	"mulOverflow":   {3, (*Interpreter).visitOverflow},
+	"floatBits":     {1, (*Interpreter).visitFloatBits},
+	"floatFromBits": {1, (*Interpreter).visitFloatBits},
+}
+
+// constantIntrinsics are the intrinsics whose value is fixed for the target,
+// which may therefore initialize constants.
+var constantIntrinsics = map[string]bool{
+	"libm":           true,
+	"epollEventSize": true,
+	"oDirectory":     true,
+}
+
+// intrinsicCall returns the name of the intrinsic ce calls, builtin.name(...),
+// and false when ce calls something else.
+func (in *Interpreter) intrinsicCall(ce *ast.CallExpression) (string, bool) {
+	mae, ok := ce.Function.(*ast.MemberAccessExpression)
+	if !ok {
+		return "", false
+	}
+	ident, ok := mae.Left.(*ast.Identifier)
+	if !ok || ident.Value != builtinModule {
+		return "", false
+	}
+	_, isVar := in.fr.vars[ident.Value]
+	_, isFunc := in.functions[ident.Value]
+	if isVar || isFunc || in.scope.declares(ident.Value) {
+		return "", false
+	}
+	return mae.Member.Value, true
+}
+
+// evalConstIntrinsic returns the value of a call to a constant intrinsic,
+// and false when ce calls something else.
+func (in *Interpreter) evalConstIntrinsic(ce *ast.CallExpression) (Value, bool, error) {
+	name, ok := in.intrinsicCall(ce)
+	if !ok || !constantIntrinsics[name] || len(ce.Arguments) != 0 {
+		return noValue, false, nil
+	}
+	if err := intrinsics[name].run(in, name, nil); err != nil {
+		return noValue, true, err
+	}
+	return in.last, true, nil
+}
+
+// visitIntrinsic runs the intrinsic name.
+func (in *Interpreter) visitIntrinsic(name string, ce *ast.CallExpression) error {
+	intr, ok := intrinsics[name]
+	if !ok {
+		return fmt.Errorf("unknown intrinsic '%s.%s'", builtinModule, name)
+	}
+	if len(ce.Arguments) != intr.arity {
+		return fmt.Errorf("%s.%s expects %d argument(s), got %d", builtinModule, name, intr.arity, len(ce.Arguments))
+	}
+	args, err := in.evaluateArguments(ce.Arguments)
+	if err != nil {
+		return fmt.Errorf("error evaluating arguments for call to '%s.%s': %w", builtinModule, name, err)
+	}
+	in.last = noValue
+	return intr.run(in, name, args)
+}
+
+func (in *Interpreter) visitArgs(name string, args []Value) error {
+	in.last, _ = in.processArgs()
+	return nil
+}
+
+func (in *Interpreter) visitEnviron(name string, args []Value) error {
+	_, environ := in.processArgs()
+	in.last = pointerValue(i64Ptr, environ)
+	return nil
+}
+
+func (in *Interpreter) visitExit(name string, args []Value) error {
+	status, err := in.convert(args[0], types.I64)
+	if err != nil {
+		return fmt.Errorf("%s.%s: %w", builtinModule, name, err)
+	}
+	if err := in.runFinalizers(); err != nil {
+		return err
+	}
+	panic(exitStatus(status.I))
+}
+
+// visitThreadStart fails as if the kernel did not support clone: the
+// interpreter runs a single thread.
+func (in *Interpreter) visitThreadStart(name string, args []Value) error {
+	in.last = intValue(types.I64, -enosys)
+	return nil
+}
+
+// visitTLSIntrinsic runs builtin.tlsSize() and builtin.tlsInit(area).
+// Thread-local globals are plain globals of the one thread, so a new thread
+// needs no TLS area.
+func (in *Interpreter) visitTLSIntrinsic(name string, args []Value) error {
+	if name == "tlsSize" {
+		in.last = intValue(types.I64, 0)
+		return nil
+	}
+	area, err := in.convert(args[0], i8Ptr)
+	if err != nil {
+		return fmt.Errorf("%s.%s: %w", builtinModule, name, err)
+	}
+	in.last = area
+	return nil
+}
+
+func (in *Interpreter) visitEpollEventSize(name string, args []Value) error {
+	in.last = constInt(types.I64, in.target.Syscall.EpollEventSize)
+	return nil
+}
+
+func (in *Interpreter) visitODirectory(name string, args []Value) error {
+	in.last = constInt(types.I64, in.target.Syscall.ODirectory)
+	return nil
+}
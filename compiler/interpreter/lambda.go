@@ -0,0 +1,221 @@
+package interpreter
+
+import (
+	"compiler/ast"
+	"fmt"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+func (in *Interpreter) VisitLambdaExpression(le *ast.LambdaExpression) error {
+	if f, ok := in.lambdas[le]; ok {
+		in.last = f.value()
+		return nil
+	}
+	fnName := fmt.Sprintf("lambda_%d", in.lambdaCounter)
+	in.lambdaCounter++
+
+	paramTypes := make([]types.Type, len(le.Parameters))
+	paramNames := make([]string, len(le.Parameters))
+	paramVars := make(map[string]types.Type)
+	for i, paramAST := range le.Parameters {
+		paramType, err := in.paramType(paramAST)
+		if err != nil {
+			return fmt.Errorf("parameter '%s' of lambda '%s': %w", paramAST.Name.Value, fnName, err)
+		}
+		paramTypes[i] = paramType
+		paramNames[i] = paramAST.Name.Value
+		paramVars[paramAST.Name.Value] = paramType
+	}
+
+	// Block bodies return i32. An expression body determines the return
+	// type, so (x: i64) -> x * x returns i64 and (x) -> x > 2 returns bool;
+	// when it cannot be told without running the body, the first call
+	// settles it.
+	var retType types.Type = types.I32
+	_, isBlock := le.Body.(*ast.BlockStatement)
+	exprBody := le.Body != nil && !isBlock
+	inferred := false
+	if exprBody {
+		if t := in.exprType(le.Body, paramVars); t != nil && firstClass(t) {
+			retType, inferred = t, true
+		}
+	}
+
+	wrap := in.enclosingWrap()
+	f := in.newFunction(&function{
+		name:     fnName,
+		typ:      types.NewFunc(retType, paramTypes...),
+		params:   paramNames,
+		body:     le.Body,
+		scope:    in.scope,
+		exprBody: exprBody && !inferred,
+		wrap: func(err error) error {
+			return wrap(fmt.Errorf("error generating body for lambda '%s': %w", fnName, err))
+		},
+	})
+	f.sig = &funcSignature{Name: fnName, Params: le.Parameters, Token: le.Token}
+	in.lambdas[le] = f
+	in.last = f.value()
+	return nil
+}
+
+// enclosingWrap returns how errors in code nested in the running function,
+// such as the body of a lambda, are qualified.
+func (in *Interpreter) enclosingWrap() func(error) error {
+	if f := in.fr.fn; f != nil && f.wrap != nil {
+		return f.wrap
+	}
+	scope := in.scope
+	return scope.qualify
+}
+
+// exprType infers the type of an expression from the types of the variables
+// in scope without evaluating it, or returns nil when it cannot.
+func (in *Interpreter) exprType(expr ast.ExpressionNode, vars map[string]types.Type) types.Type {
+	switch e := expr.(type) {
+	case *ast.NumberLiteral:
+		return numberValue(e).T
+	case *ast.StringLiteral:
+		return i8Ptr
+	case *ast.BooleanLiteral:
+		return types.I1
+	case *ast.Identifier:
+		if t, ok := vars[e.Value]; ok {
+			return t
+		}
+		if c, ok := in.scope.consts[e.Value]; ok {
+			return c.T
+		}
+		if gv, ok := in.scope.globals[e.Value]; ok {
+			return gv.typ
+		}
+		if f := in.lookupFunction(e.Value); f != nil {
+			return types.NewPointer(f.typ)
+		}
+	case *ast.PrefixExpression:
+		switch e.Operator {
+		case "!":
+			return types.I1
+		case "&":
+			return nil
+		case "*":
+			return pointee(in.exprType(e.Right, vars))
+		}
+		return in.exprType(e.Right, vars)
+	case *ast.InfixExpression:
+		switch e.Operator {
+		case "==", "!=", "<", ">", "<=", ">=", "&&", "||":
+			return types.I1
+		}
+		l, r := in.exprType(e.Left, vars), in.exprType(e.Right, vars)
+		switch {
+		case l == nil || r == nil:
+			return nil
+		case isPointer(l) && isPointer(r):
+			return types.I64
+		case isPointer(l):
+			return l
+		case isPointer(r):
+			return r
+		case isDouble(l) || isDouble(r):
+			return types.Double
+		case isFloat(l) || isFloat(r):
+			return types.Float
+		}
+		li, lInt := l.(*types.IntType)
+		ri, rInt := r.(*types.IntType)
+		if !lInt || !rInt {
+			return nil
+		}
+		if ri.BitSize > li.BitSize {
+			return ri
+		}
+		return li
+	case *ast.CastExpression:
+		if t, err := in.mapType(e.Type.Value); err == nil {
+			return t
+		}
+	case *ast.CallExpression:
+		return in.callType(e, vars)
+	case *ast.MemberAccessExpression:
+		if scope := in.moduleRef(e.Left); scope != nil {
+			member := e.Member.Value
+			if c, ok := scope.consts[member]; ok {
+				return c.T
+			}
+			if gv, ok := scope.globals[member]; ok {
+				return gv.typ
+			}
+			if f, ok := scope.functions[member]; ok {
+				return types.NewPointer(f.typ)
+			}
+			return nil
+		}
+		st := pointeeStruct(in.exprType(e.Left, vars))
+		if st == nil {
+			return nil
+		}
+		if i, ok := in.fieldIndex(st, e.Member.Value); ok {
+			return st.Fields[i]
+		}
+		if st.Name() == "Any" {
+			switch e.Member.Value {
+			case "int":
+				return types.I64
+			case "float":
+				return types.Double
+			case "string":
+				return i8Ptr
+			case "bool":
+				return types.I1
+			}
+		}
+	case *ast.IndexExpression:
+		base := in.exprType(e.Left, vars)
+		if st := pointeeStruct(base); st != nil && len(st.Fields) > 1 {
+			return pointee(st.Fields[1])
+		}
+		if st, ok := base.(*types.StructType); ok && len(st.Fields) > 1 {
+			return pointee(st.Fields[1])
+		}
+		if isInt(base) {
+			return types.I8
+		}
+		return pointee(base)
+	case *ast.TraditionalTernaryExpression:
+		return in.exprType(e.TrueExpr, vars)
+	case *ast.LambdaStyleTernaryExpression:
+		return in.exprType(e.TrueExpr, vars)
+	case *ast.InlineIfElseTernaryExpression:
+		return in.exprType(e.TrueExpr, vars)
+	}
+	return nil
+}
+
+// callType infers the type of the value a call returns, or returns nil.
+func (in *Interpreter) callType(ce *ast.CallExpression, vars map[string]types.Type) types.Type {
+	var fnType *types.FuncType
+	switch callee := ce.Function.(type) {
+	case *ast.Identifier:
+		if t, ok := vars[callee.Value]; ok {
+			fnType, _ = pointee(t).(*types.FuncType)
+		} else if f := in.lookupFunction(callee.Value); f != nil {
+			fnType = f.typ
+		}
+	case *ast.MemberAccessExpression:
+		if scope := in.moduleRef(callee.Left); scope != nil {
+			if f, ok := scope.functions[callee.Member.Value]; ok {
+				fnType = f.typ
+			}
+		} else if st := pointeeStruct(in.exprType(callee.Left, vars)); st != nil {
+			if f, ok := in.functions[st.Name()+"_"+callee.Member.Value]; ok && f.native == nil {
+				fnType = f.typ
+			}
+		}
+	}
+	if fnType == nil || fnType.RetType.Equal(types.Void) {
+		return nil
+	}
+	return fnType.RetType
+}
@@ -0,0 +1,386 @@
+package interpreter
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+// The interpreter gives a program a flat 64-bit address space made of
+// regions: stacks, the data of string literals and globals, and anonymous
+// mappings created by mmap. Pointers are plain addresses into it, so pointer
+// arithmetic, casts between pointers and integers and raw memory access in
+// the standard library behave as in a compiled program. Touching an address
+// outside every region is a segmentation fault.
+const (
+	dataBase  uint64 = 0x0000_1000_0000
+	textBase  uint64 = 0x0000_4000_0000
+	stackBase uint64 = 0x0000_7000_0000_0000
+	mmapBase  uint64 = 0x0000_7f00_0000_0000
+
+	pageSize  = 4096
+	stackSize = 8 << 20
+	dataChunk = 64 << 10
+)
+
+// region is a mapped range of addresses [start, end).
+type region struct {
+	start, end uint64
+	data       []byte
+}
+
+// memory is the program's address space.
+type memory struct {
+	regions []*region // sorted by start
+	last    *region   // the region hit by the last access
+
+	data     *region // the data region being filled
+	dataTop  uint64  // first free address of data
+	dataNext uint64  // start of the next data region
+	mmapNext uint64  // address of the next anonymous mapping
+}
+
+func newMemory() *memory {
+	return &memory{dataNext: dataBase, mmapNext: mmapBase}
+}
+
+// fault is raised, as a panic, by an access to unmapped memory.
+type fault struct {
+	addr uint64
+}
+
+// mapRegion maps size zeroed bytes at start.
+func (m *memory) mapRegion(start, size uint64) *region {
+	r := &region{start: start, end: start + size, data: make([]byte, size)}
+	i := sort.Search(len(m.regions), func(i int) bool { return m.regions[i].start >= start })
+	m.regions = append(m.regions, nil)
+	copy(m.regions[i+1:], m.regions[i:])
+	m.regions[i] = r
+	return r
+}
+
+// unmap removes [start, start+size) from the address space, splitting the
+// regions it partly covers.
+func (m *memory) unmap(start, size uint64) {
+	end := start + size
+	var kept []*region
+	for _, r := range m.regions {
+		if r.end <= start || r.start >= end {
+			kept = append(kept, r)
+			continue
+		}
+		if r.start < start {
+			kept = append(kept, &region{start: r.start, end: start, data: r.data[:start-r.start]})
+		}
+		if r.end > end {
+			kept = append(kept, &region{start: end, end: r.end, data: r.data[end-r.start:]})
+		}
+	}
+	m.regions = kept
+	m.last = nil
+}
+
+// mapped reports whether every byte of [addr, addr+size) is mapped.
+func (m *memory) mapped(addr, size uint64) bool {
+	for size > 0 {
+		r := m.find(addr)
+		if r == nil {
+			return false
+		}
+		if r.end-addr >= size {
+			return true
+		}
+		size -= r.end - addr
+		addr = r.end
+	}
+	return true
+}
+
+// mmap maps size bytes of zeroed memory at a fresh page-aligned address.
+func (m *memory) mmap(size uint64) uint64 {
+	size = alignUp(size, pageSize)
+	addr := m.mmapNext
+	m.mmapNext += size + pageSize // leave a guard page
+	m.mapRegion(addr, size)
+	return addr
+}
+
+// allocData reserves size bytes for a string literal or a global.
+func (m *memory) allocData(size, align uint64) uint64 {
+	if m.data != nil {
+		addr := alignUp(m.dataTop, align)
+		if addr+size <= m.data.end {
+			m.dataTop = addr + size
+			return addr
+		}
+	}
+	chunk := alignUp(size, dataChunk)
+	m.data = m.mapRegion(m.dataNext, chunk)
+	m.dataNext += chunk + pageSize
+	m.dataTop = m.data.start + size
+	return m.data.start
+}
+
+func (m *memory) find(addr uint64) *region {
+	if r := m.last; r != nil && addr >= r.start && addr < r.end {
+		return r
+	}
+	i := sort.Search(len(m.regions), func(i int) bool { return m.regions[i].end > addr })
+	if i < len(m.regions) {
+		r := m.regions[i]
+		if addr >= r.start && addr < r.end {
+			m.last = r
+			return r
+		}
+	}
+	return nil
+}
+
+// bytes returns the n bytes at addr, faulting unless they are all mapped
+// in one region.
+func (m *memory) bytes(addr, n uint64) []byte {
+	if n == 0 {
+		return nil
+	}
+	r := m.find(addr)
+	if r == nil || addr+n > r.end || addr+n < addr {
+		panic(fault{addr})
+	}
+	off := addr - r.start
+	return r.data[off : off+n : off+n]
+}
+
+// readBytes copies n bytes starting at addr, which may span regions.
+func (m *memory) readBytes(addr, n uint64) []byte {
+	out := make([]byte, 0, n)
+	for n > 0 {
+		r := m.find(addr)
+		if r == nil {
+			panic(fault{addr})
+		}
+		chunk := r.end - addr
+		if chunk > n {
+			chunk = n
+		}
+		out = append(out, m.bytes(addr, chunk)...)
+		addr += chunk
+		n -= chunk
+	}
+	return out
+}
+
+// writeBytes copies b to addr, which may span regions.
+func (m *memory) writeBytes(addr uint64, b []byte) {
+	for len(b) > 0 {
+		r := m.find(addr)
+		if r == nil {
+			panic(fault{addr})
+		}
+		chunk := r.end - addr
+		if chunk > uint64(len(b)) {
+			chunk = uint64(len(b))
+		}
+		copy(m.bytes(addr, chunk), b[:chunk])
+		addr += chunk
+		b = b[chunk:]
+	}
+}
+
+// cString reads the NUL-terminated string at addr.
+func (m *memory) cString(addr uint64) string {
+	var out []byte
+	for {
+		r := m.find(addr)
+		if r == nil {
+			panic(fault{addr})
+		}
+		chunk := r.data[addr-r.start:]
+		for i, c := range chunk {
+			if c == 0 {
+				return string(append(out, chunk[:i]...))
+			}
+		}
+		out = append(out, chunk...)
+		addr += uint64(len(chunk))
+	}
+}
+
+func (m *memory) readUint(addr, size uint64) uint64 {
+	b := m.bytes(addr, size)
+	switch size {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(binary.LittleEndian.Uint16(b))
+	case 4:
+		return uint64(binary.LittleEndian.Uint32(b))
+	default:
+		return binary.LittleEndian.Uint64(b)
+	}
+}
+
+func (m *memory) writeUint(addr, size, x uint64) {
+	b := m.bytes(addr, size)
+	switch size {
+	case 1:
+		b[0] = byte(x)
+	case 2:
+		binary.LittleEndian.PutUint16(b, uint16(x))
+	case 4:
+		binary.LittleEndian.PutUint32(b, uint32(x))
+	default:
+		binary.LittleEndian.PutUint64(b, x)
+	}
+}
+
+// stack is the call stack of the main program or of one generator. Slots
+// are bump allocated and released when the function owning them returns.
+type stack struct {
+	r  *region
+	sp uint64
+}
+
+func (in *Interpreter) newStack() *stack {
+	base := in.stackNext
+	in.stackNext += stackSize + pageSize
+	return &stack{r: in.mem.mapRegion(base, stackSize), sp: base}
+}
+
+// alloc reserves a slot of type t on the stack.
+func (s *stack) alloc(size, align uint64) uint64 {
+	addr := alignUp(s.sp, align)
+	if addr+size > s.r.end {
+		// Like a native stack overflow, running off the end is a crash.
+		panic(fault{addr})
+	}
+	s.sp = addr + size
+	return addr
+}
+
+func alignUp(x, align uint64) uint64 {
+	if align <= 1 {
+		return x
+	}
+	return (x + align - 1) &^ (align - 1)
+}
+
+// Type layout follows the x86-64 System V ABI, which the LLVM data layouts
+// of all supported targets agree with for the types the language has.
+
+// structLayout is the layout of a struct type: its field offsets, size and
+// alignment.
+type structLayout struct {
+	offsets []uint64
+	size    uint64
+	align   uint64
+}
+
+func (in *Interpreter) sizeOf(t types.Type) uint64 {
+	switch t := t.(type) {
+	case *types.IntType:
+		switch {
+		case t.BitSize <= 8:
+			return 1
+		case t.BitSize <= 16:
+			return 2
+		case t.BitSize <= 32:
+			return 4
+		default:
+			return 8
+		}
+	case *types.FloatType:
+		if t.Kind == types.FloatKindFloat {
+			return 4
+		}
+		return 8
+	case *types.PointerType:
+		return 8
+	case *types.ArrayType:
+		return t.Len * in.sizeOf(t.ElemType)
+	case *types.StructType:
+		return in.layout(t).size
+	}
+	return 0
+}
+
+func (in *Interpreter) alignOf(t types.Type) uint64 {
+	switch t := t.(type) {
+	case *types.ArrayType:
+		return in.alignOf(t.ElemType)
+	case *types.StructType:
+		return in.layout(t).align
+	case *types.VoidType:
+		return 1
+	}
+	return in.sizeOf(t)
+}
+
+func (in *Interpreter) layout(st *types.StructType) *structLayout {
+	if l, ok := in.layouts[st]; ok {
+		return l
+	}
+	l := &structLayout{align: 1}
+	var off uint64
+	for _, f := range st.Fields {
+		align := in.alignOf(f)
+		if st.Packed {
+			align = 1
+		}
+		off = alignUp(off, align)
+		l.offsets = append(l.offsets, off)
+		off += in.sizeOf(f)
+		if align > l.align {
+			l.align = align
+		}
+	}
+	l.size = alignUp(off, l.align)
+	in.layouts[st] = l
+	return l
+}
+
+// fieldAddr returns the address of field i of the struct of type st at
+// addr.
+func (in *Interpreter) fieldAddr(st *types.StructType, addr uint64, i int) uint64 {
+	return addr + in.layout(st).offsets[i]
+}
+
+// load reads a value of type t from addr.
+func (in *Interpreter) load(addr uint64, t types.Type) Value {
+	switch t := t.(type) {
+	case *types.IntType:
+		x := in.mem.readUint(addr, in.sizeOf(t))
+		return intValue(t, int64(x))
+	case *types.FloatType:
+		if t.Kind == types.FloatKindFloat {
+			return Value{T: t, F: float64(math.Float32frombits(uint32(in.mem.readUint(addr, 4))))}
+		}
+		return Value{T: t, F: math.Float64frombits(in.mem.readUint(addr, 8))}
+	case *types.PointerType:
+		return Value{T: t, I: int64(in.mem.readUint(addr, 8))}
+	case *types.StructType, *types.ArrayType:
+		return Value{T: t, B: in.mem.readBytes(addr, in.sizeOf(t))}
+	}
+	panic(fault{addr})
+}
+
+// store writes v to addr, in the representation of its type.
+func (in *Interpreter) store(addr uint64, v Value) {
+	switch t := v.T.(type) {
+	case *types.IntType:
+		in.mem.writeUint(addr, in.sizeOf(t), uint64(v.I))
+	case *types.FloatType:
+		if t.Kind == types.FloatKindFloat {
+			in.mem.writeUint(addr, 4, uint64(math.Float32bits(float32(v.F))))
+		} else {
+			in.mem.writeUint(addr, 8, math.Float64bits(v.F))
+		}
+	case *types.PointerType:
+		in.mem.writeUint(addr, 8, uint64(v.I))
+	case *types.StructType, *types.ArrayType:
+		in.mem.writeBytes(addr, v.B)
+	default:
+		panic(fault{addr})
+	}
+}
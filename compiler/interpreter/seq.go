@@ -0,0 +1,742 @@
+package interpreter
+
+import (
+	"compiler/ast"
+	"fmt"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+// A generator function (function* name(...): T) runs as a coroutine on a
+// goroutine and a stack of its own, handing control back and forth with the
+// code requesting its values, so its locals keep their values while it is
+// suspended. As in compiled code, calling it fills a frame the caller
+// allocates, { resume, current, state, params... }, and returns the frame as
+// a seq<T>: resume is a function producing the next value in current.
+//
+// The lazy adapters (array.lazy(), seq.map, seq.filter and seq.take) have
+// frames { resume, current, source, arg } whose resume function is provided
+// by the interpreter.
+
+// Fields of a generator frame after the seq<T> header.
+const (
+	genStateField  = 2 // i32: 0 before the first value, genDone at the end
+	genParamsField = 3 // first parameter
+)
+
+// genDone is the state of a generator whose body has finished.
+const genDone = -1
+
+// coroutineStackSize is the size of the stack of a generator's body.
+const coroutineStackSize = 256 << 10
+
+// generatorInfo describes a declared generator function.
+type generatorInfo struct {
+	name   string
+	frame  *types.StructType
+	elem   types.Type
+	seq    *types.StructType
+	fn     *function // the generator function, which holds the body
+	resume *function
+}
+
+// frameError reports an error the code generator raises while moving the
+// locals of the generator into its frame.
+func (gi *generatorInfo) frameError(err error) error {
+	return &compileError{gi.fn.scope.qualify(fmt.Errorf("error visiting function %s: generator '%s': %w", gi.name, gi.name, err))}
+}
+
+// declareGenerator declares a generator: irName, which initializes a frame
+// passed by the caller and returns it as a seq<T>, and the resume function
+// stored in the frame.
+func (in *Interpreter) declareGenerator(fn *ast.FunctionDefinition, irName string) (*function, error) {
+	fnName := fn.Name.Value
+	var elem types.Type = types.I32
+	if fn.ReturnType != nil {
+		mapped, err := in.mapType(fn.ReturnType.Value)
+		if err != nil {
+			return nil, fmt.Errorf("generator '%s': %w", fnName, err)
+		}
+		elem = mapped
+	}
+	if elem.Equal(types.Void) {
+		return nil, fmt.Errorf("generator '%s' must yield values, not void", fnName)
+	}
+
+	fields := []types.Type{seqResumeType, elem, types.I32}
+	paramNames := make([]string, len(fn.Parameters))
+	for i, paramAST := range fn.Parameters {
+		paramType, err := in.paramType(paramAST)
+		if err != nil {
+			return nil, fmt.Errorf("parameter '%s' of generator '%s': %w", paramAST.Name.Value, fnName, err)
+		}
+		fields = append(fields, paramType)
+		paramNames[i] = paramAST.Name.Value
+	}
+	frame := types.NewStruct(fields...)
+	frame.SetName(irName + ".frame")
+
+	info := &generatorInfo{name: fnName, frame: frame, elem: elem, seq: in.seqType(elem)}
+	info.resume = in.newFunction(&function{
+		name: irName + ".resume",
+		typ:  types.NewFunc(types.I1, i8Ptr),
+		native: func(args []Value) (Value, error) {
+			more, err := in.resumeGenerator(info, args[0].addr())
+			return boolValue(more), err
+		},
+	})
+	initParams := append([]types.Type{types.NewPointer(frame)}, fields[genParamsField:]...)
+	info.fn = in.newFunction(&function{
+		name:   irName,
+		typ:    types.NewFunc(types.NewPointer(info.seq), initParams...),
+		params: paramNames,
+		body:   fn.Body,
+		scope:  in.scope,
+		gen:    info,
+	})
+	return info.fn, nil
+}
+
+// startGenerator runs a generator function: it initializes the frame passed
+// first and returns it as a seq<T>. The body runs when values are requested.
+func (in *Interpreter) startGenerator(f *function, args []Value) (Value, error) {
+	info := f.gen
+	frame := args[0].addr()
+	in.store(in.fieldAddr(info.frame, frame, seqResumeField), pointerValue(seqResumeType, info.resume.addr))
+	in.store(in.fieldAddr(info.frame, frame, genStateField), intValue(types.I32, 0))
+	for i, arg := range args[1:] {
+		in.store(in.fieldAddr(info.frame, frame, genParamsField+i), arg)
+	}
+	return pointerValue(types.NewPointer(info.seq), frame), nil
+}
+
+// resumeGenerator produces the next value of the generator whose frame is at
+// frame, starting its body on the first request.
+func (in *Interpreter) resumeGenerator(info *generatorInfo, frame uint64) (bool, error) {
+	state := int32(in.mem.readUint(in.fieldAddr(info.frame, frame, genStateField), 4))
+	co := in.coroutines[frame]
+	switch {
+	case state == 0:
+		// A frame is reused when the generator is called again from the
+		// same place, which restarts it.
+		if co != nil {
+			co.abort()
+		}
+		co = in.newCoroutine(info, frame)
+		in.coroutines[frame] = co
+	case state == genDone || co == nil || co.info != info:
+		return false, nil
+	}
+	return co.resume()
+}
+
+func (in *Interpreter) VisitYieldStatement(ys *ast.YieldStatement) error {
+	co := in.fr.gen
+	if co == nil {
+		return fmt.Errorf("yield outside of a generator function at line %d", ys.Token.Line+1)
+	}
+	if err := ys.Value.Accept(in); err != nil {
+		return err
+	}
+	if !in.last.valid() {
+		return fmt.Errorf("yield at line %d: value expression produced no value", ys.Token.Line+1)
+	}
+	info := co.info
+	v, err := in.convert(in.last, info.elem)
+	if err != nil {
+		return fmt.Errorf("yield in generator '%s' at line %d: %w", info.name, ys.Token.Line+1, err)
+	}
+	in.store(in.fieldAddr(info.frame, co.frame, seqCurrentField), v)
+	in.store(in.fieldAddr(info.frame, co.frame, genStateField), intValue(types.I32, 1))
+	co.yield()
+	in.last = noValue
+	return nil
+}
+
+// execState is the state of the code an interpreter runs, saved while it
+// switches to or from a coroutine.
+type execState struct {
+	fr    *frame
+	stack *stack
+	scope *moduleScope
+	last  Value
+	lhs   bool
+	depth int
+	site  ast.Node
+}
+
+func (in *Interpreter) saveState() execState {
+	return execState{in.fr, in.stack, in.scope, in.last, in.lhs, in.depth, in.site}
+}
+
+func (in *Interpreter) restoreState(s execState) {
+	in.fr, in.stack, in.scope, in.last, in.lhs, in.depth, in.site = s.fr, s.stack, s.scope, s.last, s.lhs, s.depth, s.site
+}
+
+// coroutine runs the body of one generator. Control passes strictly back and
+// forth between it and the code resuming it, so only one of them uses the
+// interpreter at any time.
+type coroutine struct {
+	in    *Interpreter
+	info  *generatorInfo
+	frame uint64
+	stack *stack
+
+	resumed chan bool // true to run to the next yield, false to abort
+	yielded chan yieldResult
+
+	started, running, done bool
+}
+
+// yieldResult is what a coroutine hands back: whether it produced a value,
+// or the error or panic that ended it.
+type yieldResult struct {
+	more  bool
+	err   error
+	panic interface{}
+}
+
+// abortCoroutine unwinds a suspended coroutine that is not resumed again.
+type abortCoroutine struct{}
+
+func (in *Interpreter) newCoroutine(info *generatorInfo, frame uint64) *coroutine {
+	return &coroutine{
+		in:      in,
+		info:    info,
+		frame:   frame,
+		stack:   in.coroutineStack(),
+		resumed: make(chan bool),
+		yielded: make(chan yieldResult),
+	}
+}
+
+// coroutineStack returns a stack for a coroutine, reusing those of finished
+// ones.
+func (in *Interpreter) coroutineStack() *stack {
+	if n := len(in.stackPool); n > 0 {
+		s := in.stackPool[n-1]
+		in.stackPool = in.stackPool[:n-1]
+		s.sp = s.r.start
+		return s
+	}
+	base := in.stackNext
+	in.stackNext += coroutineStackSize + pageSize
+	return &stack{r: in.mem.mapRegion(base, coroutineStackSize), sp: base}
+}
+
+// resume runs the coroutine until it yields or ends and reports whether it
+// produced a value.
+func (co *coroutine) resume() (bool, error) {
+	in := co.in
+	saved := in.saveState()
+	if !co.started {
+		co.started = true
+		go co.run()
+	}
+	co.running = true
+	co.resumed <- true
+	r := <-co.yielded
+	co.running = false
+	in.restoreState(saved)
+	if co.done {
+		co.release()
+	}
+	if r.panic != nil {
+		panic(r.panic)
+	}
+	return r.more, r.err
+}
+
+// run is the goroutine of the coroutine.
+func (co *coroutine) run() {
+	in := co.in
+	var result yieldResult
+	defer func() {
+		if r := recover(); r != nil {
+			if _, aborted := r.(abortCoroutine); !aborted {
+				result = yieldResult{panic: r}
+			}
+		}
+		co.done = true
+		co.yielded <- result
+	}()
+	if !<-co.resumed {
+		return
+	}
+
+	info := co.info
+	fr := newFrame(info.fn)
+	fr.gen = co
+	for i, name := range info.fn.params {
+		field := genParamsField + i
+		fr.vars[name] = &binding{addr: in.fieldAddr(info.frame, co.frame, field), typ: info.frame.Fields[field]}
+	}
+	in.fr, in.stack, in.scope, in.lhs, in.last = fr, co.stack, info.fn.scope, false, noValue
+	if err := in.runBody(info.fn); err != nil {
+		result.err = err
+		return
+	}
+	in.store(in.fieldAddr(info.frame, co.frame, genStateField), intValue(types.I32, genDone))
+}
+
+// yield hands the value in the frame to the code that resumed the coroutine
+// and waits to be resumed.
+func (co *coroutine) yield() {
+	in := co.in
+	saved := in.saveState()
+	co.yielded <- yieldResult{more: true}
+	if !<-co.resumed {
+		panic(abortCoroutine{})
+	}
+	in.restoreState(saved)
+}
+
+// abort ends a suspended coroutine.
+func (co *coroutine) abort() {
+	if co.started && !co.done && !co.running {
+		in := co.in
+		saved := in.saveState()
+		co.resumed <- false
+		<-co.yielded
+		in.restoreState(saved)
+	}
+	co.release()
+}
+
+// release returns the stack of a finished coroutine.
+func (co *coroutine) release() {
+	in := co.in
+	if in.coroutines[co.frame] == co {
+		delete(in.coroutines, co.frame)
+	}
+	if co.stack != nil && !co.running {
+		in.stackPool = append(in.stackPool, co.stack)
+		co.stack = nil
+	}
+}
+
+// stopCoroutines ends the coroutines still suspended when the program ends.
+func (in *Interpreter) stopCoroutines() {
+	for len(in.coroutines) > 0 {
+		for _, co := range in.coroutines {
+			co.abort()
+			break
+		}
+	}
+}
+
+// seqNext advances the sequence at handle and reports whether it produced a
+// value.
+func (in *Interpreter) seqNext(handle Value) (bool, error) {
+	seq := pointeeStruct(handle.T)
+	resume := in.load(in.fieldAddr(seq, handle.addr(), seqResumeField), seqResumeType)
+	f := in.funcs[resume.addr()]
+	if f == nil {
+		panic(fault{resume.addr()})
+	}
+	more, err := in.call(f, []Value{pointerValue(i8Ptr, handle.addr())})
+	if err != nil {
+		return false, err
+	}
+	return condAsBool(more), nil
+}
+
+// seqCurrent loads the value the sequence at handle produced last.
+func (in *Interpreter) seqCurrent(handle Value) Value {
+	seq := pointeeStruct(handle.T)
+	return in.load(in.fieldAddr(seq, handle.addr(), seqCurrentField), seq.Fields[seqCurrentField])
+}
+
+// forEachSeq requests values from the sequence at handle and calls body with
+// each, until the sequence ends or body reports false.
+func (in *Interpreter) forEachSeq(handle Value, body func(elem Value) (bool, error)) error {
+	for {
+		more, err := in.seqNext(handle)
+		if err != nil || !more {
+			return err
+		}
+		if cont, err := body(in.seqCurrent(handle)); err != nil || !cont {
+			return err
+		}
+	}
+}
+
+// forEachElement calls body with the index and value of each element of the
+// Array (or slice) at arrayPtr. The length is read again before every
+// element, so the body may change it.
+func (in *Interpreter) forEachElement(arrayPtr Value, body func(index int64, elem Value) (bool, error)) error {
+	arrayType := pointeeStruct(arrayPtr.T)
+	elemType := pointee(arrayType.Fields[1])
+	size := int64(in.sizeOf(elemType))
+	lengthAddr := in.fieldAddr(arrayType, arrayPtr.addr(), 0)
+	dataAddr := in.fieldAddr(arrayType, arrayPtr.addr(), 1)
+	for index := int64(0); index < int64(int32(in.mem.readUint(lengthAddr, 4))); index++ {
+		data := in.mem.readUint(dataAddr, 8)
+		elem := in.load(data+uint64(index*size), elemType)
+		if cont, err := body(index, elem); err != nil || !cont {
+			return err
+		}
+	}
+	return nil
+}
+
+// seqOnStack reports whether the seq v has its frame on the stack of the
+// current function, above where the function's slots start.
+func (in *Interpreter) seqOnStack(v Value) bool {
+	if _, isSeq := in.seqElem(v.T); !isSeq {
+		return false
+	}
+	addr := v.addr()
+	return in.fr.fn != nil && addr >= in.fr.base && addr < in.stack.sp
+}
+
+// checkSeqReturn rejects returning a seq whose frame is on the stack of the
+// current function.
+func (in *Interpreter) checkSeqReturn(v Value) error {
+	if in.fr.gen == nil && in.seqOnStack(v) {
+		return fmt.Errorf("cannot return a seq created in this function: its frame lives on the function's stack")
+	}
+	return nil
+}
+
+// checkSeqArguments rejects passing a seq whose frame is on the stack of the
+// current function to a function that keeps it, e.g. async.spawn. main is
+// exempt, as nothing it calls outlives it.
+func (in *Interpreter) checkSeqArguments(ce *ast.CallExpression, sig *funcSignature, args []Value) error {
+	if in.fr.gen != nil || in.fr.fn == nil || sig == nil || sig.Def == nil {
+		return nil
+	}
+	if cur := in.fr.fn.sig; cur == nil || cur.Name == "main" && cur.Scope != nil && cur.Scope.prefix == "" {
+		return nil
+	}
+	name := sig.Name
+	if mae, ok := ce.Function.(*ast.MemberAccessExpression); ok {
+		if alias, ok := mae.Left.(*ast.Identifier); ok {
+			name = alias.Value + "." + name
+		}
+	}
+	for i, arg := range args {
+		if in.seqOnStack(arg) && in.keeper.Keeps(sig.Def, i) {
+			return fmt.Errorf("cannot pass a seq created in this function to '%s', which keeps it: its frame lives on the function's stack", name)
+		}
+	}
+	return nil
+}
+
+// keptCallee resolves the function a call in the body of fn names for
+// keeper, in the scope of the module declaring fn.
+func (in *Interpreter) keptCallee(fn *ast.FunctionDefinition, call *ast.CallExpression) *ast.FunctionDefinition {
+	sig := in.definitions[fn]
+	if sig == nil {
+		return nil
+	}
+	var callee *function
+	switch f := call.Function.(type) {
+	case *ast.Identifier:
+		if callee = sig.Scope.functions[f.Value]; callee == nil {
+			callee = in.functions[f.Value]
+		}
+	case *ast.MemberAccessExpression:
+		alias, ok := f.Left.(*ast.Identifier)
+		if !ok {
+			return nil
+		}
+		if scope := in.modules[in.moduleAliases[alias.Value]]; scope != nil {
+			callee = scope.functions[f.Member.Value]
+		}
+	}
+	if callee != nil && callee.sig != nil {
+		return callee.sig.Def
+	}
+	return nil
+}
+
+// checkConvert reports whether values of type from convert to to.
+func (in *Interpreter) checkConvert(from, to types.Type) error {
+	_, err := in.convert(in.zeroValue(from), to)
+	return err
+}
+
+// arrayCallback checks that args holds a single one-parameter function
+// pointer, as taken by array.map and array.forEach, and returns the function
+// and its type.
+func (in *Interpreter) arrayCallback(method string, args []Value) (*function, *types.FuncType, error) {
+	if len(args) != 1 {
+		return nil, nil, fmt.Errorf("array.%s expects exactly 1 argument (callback function), got %d", method, len(args))
+	}
+	ptrType, ok := args[0].T.(*types.PointerType)
+	if !ok {
+		return nil, nil, fmt.Errorf("argument to %s is not a function pointer type: %s", method, args[0].T)
+	}
+	sig, ok := ptrType.ElemType.(*types.FuncType)
+	if !ok || len(sig.Params) != 1 {
+		return nil, nil, fmt.Errorf("%s callback must be a function of one parameter, got %s", method, args[0].T)
+	}
+	f := in.funcs[args[0].addr()]
+	if f == nil {
+		panic(fault{args[0].addr()})
+	}
+	return f, sig, nil
+}
+
+// arrayMap implements array.map(callback). Each element is converted to the
+// callback's parameter type and the results are collected in a new Array
+// whose data lives on the stack of the current function.
+func (in *Interpreter) arrayMap(arrayPtr Value, args []Value) error {
+	callback, sig, err := in.arrayCallback("map", args)
+	if err != nil {
+		return err
+	}
+	if sig.RetType.Equal(types.Void) {
+		return fmt.Errorf("map callback must return a value, got %s", sig)
+	}
+	if co := in.fr.gen; co != nil {
+		return co.info.frameError(fmt.Errorf("a stack allocation whose size is only known at run time (such as array.map) cannot be kept in a generator"))
+	}
+	arrayType := pointeeStruct(arrayPtr.T)
+	elemType := pointee(arrayType.Fields[1])
+	if err := in.checkConvert(elemType, sig.Params[0]); err != nil {
+		return fmt.Errorf("map callback: %w", err)
+	}
+	if err := in.checkConvert(sig.RetType, types.I32); err != nil {
+		return fmt.Errorf("map callback result: %w", err)
+	}
+
+	length := int32(in.mem.readUint(in.fieldAddr(arrayType, arrayPtr.addr(), 0), 4))
+	resultData := in.alloca(types.NewArray(uint64(max(length, 0)), types.I32))
+	result := in.entryAlloca(slotKey{node: in.site, kind: slotAdapter}, arrayType)
+	in.store(in.fieldAddr(arrayType, result, 0), intValue(types.I32, int64(length)))
+	in.store(in.fieldAddr(arrayType, result, 1), pointerValue(arrayType.Fields[1], resultData))
+
+	err = in.forEachElement(arrayPtr, func(index int64, elem Value) (bool, error) {
+		arg, _ := in.convert(elem, sig.Params[0])
+		mapped, err := in.call(callback, []Value{arg})
+		if err != nil {
+			return false, err
+		}
+		mapped, _ = in.convert(mapped, types.I32)
+		in.store(resultData+uint64(4*index), mapped)
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	in.last = pointerValue(types.NewPointer(arrayType), result)
+	return nil
+}
+
+// arrayForEach implements array.forEach(callback). Each element is converted
+// to the callback's parameter type, so a function taking any (like print)
+// can be passed directly. The array itself is the result.
+func (in *Interpreter) arrayForEach(arrayPtr Value, args []Value) error {
+	callback, sig, err := in.arrayCallback("forEach", args)
+	if err != nil {
+		return err
+	}
+	if err := in.checkConvert(pointee(pointeeStruct(arrayPtr.T).Fields[1]), sig.Params[0]); err != nil {
+		return fmt.Errorf("forEach callback: %w", err)
+	}
+	err = in.forEachElement(arrayPtr, func(index int64, elem Value) (bool, error) {
+		arg, _ := in.convert(elem, sig.Params[0])
+		_, err := in.call(callback, []Value{arg})
+		return err == nil, err
+	})
+	if err != nil {
+		return err
+	}
+	in.last = arrayPtr
+	return nil
+}
+
+// seqMethod implements the methods of a seq<T>: map, filter and take return
+// a new lazy sequence, forEach consumes it and next and current step it by
+// hand.
+func (in *Interpreter) seqMethod(handle Value, methodName string, args []Value) error {
+	switch methodName {
+	case "map", "filter":
+		_, sig, err := in.arrayCallback(methodName, args)
+		if err != nil {
+			return err
+		}
+		if sig.RetType.Equal(types.Void) {
+			return fmt.Errorf("%s callback must return a value, got %s", methodName, sig)
+		}
+		return in.newSeqAdapter(methodName, handle, args[0])
+	case "take":
+		if len(args) != 1 {
+			return fmt.Errorf("seq.take expects exactly 1 argument (count), got %d", len(args))
+		}
+		n, err := in.convert(args[0], types.I64)
+		if err != nil {
+			return fmt.Errorf("seq.take: %w", err)
+		}
+		return in.newSeqAdapter(methodName, handle, n)
+	case "next", "current":
+		if len(args) != 0 {
+			return fmt.Errorf("seq.%s expects no arguments, got %d", methodName, len(args))
+		}
+		if methodName == "current" {
+			in.last = in.seqCurrent(handle)
+			return nil
+		}
+		more, err := in.seqNext(handle)
+		if err != nil {
+			return err
+		}
+		in.last = boolValue(more)
+		return nil
+	case "forEach":
+		callback, sig, err := in.arrayCallback(methodName, args)
+		if err != nil {
+			return err
+		}
+		elem, _ := in.seqElem(handle.T)
+		if err := in.checkConvert(elem, sig.Params[0]); err != nil {
+			return fmt.Errorf("forEach callback: %w", err)
+		}
+		err = in.forEachSeq(handle, func(elem Value) (bool, error) {
+			arg, _ := in.convert(elem, sig.Params[0])
+			_, err := in.call(callback, []Value{arg})
+			return err == nil, err
+		})
+		if err != nil {
+			return err
+		}
+		in.last = handle
+		return nil
+	}
+	return fmt.Errorf("method '%s' not found for seq; expected map, filter, take, forEach, next or current", methodName)
+}
+
+// arrayLazy implements array.lazy(), a seq<T> over the elements of an array
+// or slice, so map and filter run element by element.
+func (in *Interpreter) arrayLazy(arrayPtr Value, args []Value) error {
+	if len(args) != 0 {
+		return fmt.Errorf("array.lazy expects no arguments, got %d", len(args))
+	}
+	return in.newSeqAdapter("lazy", arrayPtr, noValue)
+}
+
+// newSeqAdapter allocates the frame of a lazy sequence of the given kind
+// reading from source: { resume, current, source, arg }, where arg is the
+// callback of map and filter, the count of take and the index of lazy.
+func (in *Interpreter) newSeqAdapter(kind string, source, arg Value) error {
+	if !arg.valid() {
+		arg = constInt(types.I64, 0)
+	}
+	adapter, err := in.seqAdapter(kind, source.T, arg.T)
+	if err != nil {
+		return err
+	}
+	frame := adapter.typ.Params[0].(*types.PointerType).ElemType.(*types.StructType)
+	addr := in.entryAlloca(slotKey{node: in.site, kind: slotAdapter}, frame)
+	in.store(in.fieldAddr(frame, addr, seqResumeField), pointerValue(seqResumeType, adapter.addr))
+	in.store(in.fieldAddr(frame, addr, 2), source)
+	in.store(in.fieldAddr(frame, addr, 3), arg)
+	in.last = pointerValue(types.NewPointer(in.seqType(frame.Fields[seqCurrentField])), addr)
+	return nil
+}
+
+// seqAdapter returns the resume function of a kind of lazy sequence for
+// particular source and argument types. Its only parameter is typed as a
+// pointer to the frame it advances.
+func (in *Interpreter) seqAdapter(kind string, sourceType, argType types.Type) (*function, error) {
+	key := kind + " " + sourceType.String() + " " + argType.String()
+	if adapter, ok := in.seqAdapters[key]; ok {
+		return adapter, nil
+	}
+
+	var elem types.Type
+	if kind == "lazy" {
+		elem = pointee(pointeeStruct(sourceType).Fields[1])
+	} else {
+		elem, _ = in.seqElem(sourceType)
+	}
+	var callback *types.FuncType
+	if kind == "map" || kind == "filter" {
+		callback = pointee(argType).(*types.FuncType)
+		if err := in.checkConvert(elem, callback.Params[0]); err != nil {
+			return nil, fmt.Errorf("%s callback: %w", kind, err)
+		}
+		if kind == "map" {
+			elem = callback.RetType
+		}
+	}
+	frame := types.NewStruct(seqResumeType, elem, sourceType, argType)
+
+	field := func(self uint64, i int) uint64 { return in.fieldAddr(frame, self, i) }
+	var resume func(self uint64) (bool, error)
+	switch kind {
+	case "lazy":
+		resume = func(self uint64) (bool, error) {
+			array := in.load(field(self, 2), sourceType)
+			index := in.load(field(self, 3), types.I64).I
+			arrayType := pointeeStruct(sourceType)
+			length := int64(int32(in.mem.readUint(in.fieldAddr(arrayType, array.addr(), 0), 4)))
+			if index >= length {
+				return false, nil
+			}
+			data := in.mem.readUint(in.fieldAddr(arrayType, array.addr(), 1), 8)
+			in.store(field(self, seqCurrentField), in.load(data+uint64(index)*in.sizeOf(elem), elem))
+			in.store(field(self, 3), intValue(types.I64, index+1))
+			return true, nil
+		}
+	case "take":
+		resume = func(self uint64) (bool, error) {
+			left := in.load(field(self, 3), types.I64).I
+			if left <= 0 {
+				return false, nil
+			}
+			in.store(field(self, 3), intValue(types.I64, left-1))
+			source := in.load(field(self, 2), sourceType)
+			if more, err := in.seqNext(source); err != nil || !more {
+				return false, err
+			}
+			in.store(field(self, seqCurrentField), in.seqCurrent(source))
+			return true, nil
+		}
+	case "map", "filter":
+		// Request values from the source until one is produced: map produces
+		// every value, filter only those the callback accepts.
+		resume = func(self uint64) (bool, error) {
+			for {
+				source := in.load(field(self, 2), sourceType)
+				if more, err := in.seqNext(source); err != nil || !more {
+					return false, err
+				}
+				v := in.seqCurrent(source)
+				arg, _ := in.convert(v, callback.Params[0])
+				fnPtr := in.load(field(self, 3), argType)
+				f := in.funcs[fnPtr.addr()]
+				if f == nil {
+					panic(fault{fnPtr.addr()})
+				}
+				result, err := in.call(f, []Value{arg})
+				if err != nil {
+					return false, err
+				}
+				if kind == "map" {
+					if result.valid() {
+						in.store(field(self, seqCurrentField), result)
+					}
+					return true, nil
+				}
+				if condAsBool(result) {
+					in.store(field(self, seqCurrentField), v)
+					return true, nil
+				}
+			}
+		}
+	}
+
+	adapter := in.newFunction(&function{
+		name: fmt.Sprintf("seq.%s.%d", kind, len(in.seqAdapters)),
+		typ:  types.NewFunc(types.I1, types.NewPointer(frame)),
+		native: func(args []Value) (Value, error) {
+			more, err := resume(args[0].addr())
+			return boolValue(more), err
+		},
+	})
+	in.seqAdapters[key] = adapter
+	return adapter, nil
+}
@@ -0,0 +1,256 @@
+package interpreter
+
+import (
+	"compiler/ast"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+// Session runs a program a piece at a time, for a REPL. Declarations add to
+// the program as they come, and statements run in a frame that outlives
+// them, standing in for the body of main, so the names they bind stay
+// defined for the statements that follow.
+type Session struct {
+	in *Interpreter
+	fr *frame
+
+	exited bool
+	status int
+}
+
+// Result is the value of the last statement a Session ran.
+type Result struct {
+	Type types.Type // nil when the statement has no value
+	Text string     // the value, printed
+}
+
+// NewSession starts a session. The streams, command line and environment of
+// in are read now, as when Run starts.
+func (in *Interpreter) NewSession() *Session {
+	in.sys = newSystem(in)
+	f := &function{
+		name:  "main",
+		typ:   types.NewFunc(types.I32),
+		scope: in.scope,
+		wrap:  func(err error) error { return err },
+	}
+	s := &Session{in: in, fr: newFrame(f)}
+	in.fr = s.fr
+	in.interactive = true
+	return s
+}
+
+// Close ends the session, running the finalizers of the modules imported if
+// the program has not exited.
+func (s *Session) Close() error {
+	defer s.in.sys.close()
+	defer s.in.stopCoroutines()
+	if s.exited {
+		return nil
+	}
+	return s.guard(s.in.runFinalizers)
+}
+
+// Exited reports whether the program has exited, and its exit status.
+func (s *Session) Exited() (int, bool) {
+	return s.status, s.exited
+}
+
+// Declare adds the imports, types, functions and globals of program. A
+// function replaces a function of the same name declared before, for the
+// calls compiled from then on.
+func (s *Session) Declare(program *ast.Program) error {
+	fns := program.Functions
+	if program.MainFunction != nil {
+		fns = append([]*ast.FunctionDefinition{program.MainFunction}, fns...)
+	}
+	for _, fn := range fns {
+		name := fn.Name.Value
+		if f, ok := s.in.scope.functions[name]; ok {
+			delete(s.in.scope.functions, name)
+			if s.in.functions[name] == f {
+				delete(s.in.functions, name)
+			}
+		}
+	}
+	return s.guard(func() error { return program.Accept(s.in) })
+}
+
+// Exec runs stmts and returns the value of the last one: the value of an
+// expression statement or a return statement. Buffered output is flushed
+// afterwards by running the finalizers of the modules imported, which only
+// flush it in the standard library.
+func (s *Session) Exec(stmts []ast.Statement) (Result, error) {
+	in := s.in
+	var result Result
+	err := s.guard(func() error {
+		in.fr, in.scope, in.lhs = s.fr, s.fr.fn.scope, false
+		var v Value
+		for _, stmt := range stmts {
+			in.last = noValue
+			if err := stmt.Accept(in); err != nil {
+				return err
+			}
+			v = noValue
+			if _, isExpr := stmt.(*ast.ExpressionStatement); isExpr {
+				v = in.last
+			}
+			if s.fr.returned {
+				s.fr.returned = false
+				v = s.fr.ret
+				break
+			}
+		}
+		if v.valid() {
+			result = Result{Type: v.T, Text: in.format(v)}
+		}
+		return in.runFinalizers()
+	})
+	return result, err
+}
+
+// guard runs f, turning the end of the program into the exit status of the
+// session and a crash into an error the session survives.
+func (s *Session) guard(f func() error) (err error) {
+	if s.exited {
+		return fmt.Errorf("the program has exited")
+	}
+	depth, sp := s.in.depth, s.in.stack.sp
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case exitStatus:
+			s.exited, s.status = true, int(r)&0xff
+		case signal:
+			err = fmt.Errorf("the program was killed by signal %d", int(r))
+		case fault:
+			err = fmt.Errorf("segmentation fault at address 0x%x", r.addr)
+		default:
+			panic(r)
+		}
+		if err != nil {
+			s.in.fr, s.in.depth, s.in.stack.sp = s.fr, depth, sp
+		}
+	}()
+	return f()
+}
+
+// maxFormatted bounds the elements of a slice and the bytes of a string
+// format prints.
+const maxFormatted = 64
+
+// format prints v as a REPL shows it: integers in decimal, strings quoted,
+// slices and structs element by element, functions by name and other
+// pointers in hexadecimal.
+func (in *Interpreter) format(v Value) string {
+	switch t := v.T.(type) {
+	case *types.IntType:
+		if t.BitSize == 1 {
+			return strconv.FormatBool(v.I&1 != 0)
+		}
+		return strconv.FormatInt(v.signed(), 10)
+	case *types.FloatType:
+		f := v.F
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return fmt.Sprint(f)
+		}
+		return strconv.FormatFloat(f, 'g', -1, floatBits(t))
+	case *types.PointerType:
+		if v.fn != nil {
+			return v.fn.name
+		}
+		if v.I == 0 {
+			return "null"
+		}
+		if f := in.funcs[v.addr()]; f != nil {
+			return f.name
+		}
+		if t.ElemType.Equal(types.I8) {
+			if s, ok := in.formatString(v.addr()); ok {
+				return s
+			}
+		}
+		if st, ok := t.ElemType.(*types.StructType); ok && isSliceType(st) && in.mem.mapped(v.addr(), in.sizeOf(st)) {
+			return in.formatSlice(st, v.addr())
+		}
+		return fmt.Sprintf("0x%x", v.addr())
+	case *types.StructType:
+		if t.Equal(in.anyType()) {
+			return in.formatAny(v)
+		}
+		addr := in.alloca(t)
+		in.store(addr, v)
+		names := in.structFields[t.Name()]
+		fields := make([]string, len(t.Fields))
+		for i, ft := range t.Fields {
+			field := in.format(in.load(in.fieldAddr(t, addr, i), ft))
+			if i < len(names) {
+				field = names[i] + ": " + field
+			}
+			fields[i] = field
+		}
+		return t.Name() + "{" + strings.Join(fields, ", ") + "}"
+	}
+	return fmt.Sprint(v.T)
+}
+
+// formatString quotes the string at addr, if it is readable.
+func (in *Interpreter) formatString(addr uint64) (string, bool) {
+	var b []byte
+	for len(b) < maxFormatted {
+		if !in.mem.mapped(addr, 1) {
+			return "", false
+		}
+		c := in.mem.readBytes(addr, 1)[0]
+		if c == 0 {
+			return strconv.Quote(string(b)), true
+		}
+		b = append(b, c)
+		addr++
+	}
+	return strconv.Quote(string(b)) + "...", true
+}
+
+// formatSlice prints the elements of the slice at addr.
+func (in *Interpreter) formatSlice(st *types.StructType, addr uint64) string {
+	n := in.load(in.fieldAddr(st, addr, 0), st.Fields[0]).signed()
+	data := in.load(in.fieldAddr(st, addr, 1), st.Fields[1]).addr()
+	elem := pointee(st.Fields[1])
+	size := in.sizeOf(elem)
+	var items []string
+	for i := int64(0); i < n; i++ {
+		if i == maxFormatted {
+			items = append(items, "...")
+			break
+		}
+		at := data + uint64(i)*size
+		if !in.mem.mapped(at, size) {
+			items = append(items, "?")
+			break
+		}
+		items = append(items, in.format(in.load(at, elem)))
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+// formatAny prints the value an any holds.
+func (in *Interpreter) formatAny(v Value) string {
+	kind, bits := in.unboxAny(v)
+	switch kind {
+	case anyKindNil:
+		return "null"
+	case anyKindInt:
+		return in.format(intValue(types.I64, int64(bits)))
+	case anyKindFloat:
+		return in.format(Value{T: types.Double, F: float64frombits(bits)})
+	case anyKindString:
+		return in.format(pointerValue(i8Ptr, bits))
+	case anyKindBool:
+		return in.format(boolValue(bits&1 != 0))
+	}
+	return fmt.Sprintf("0x%x", bits)
+}
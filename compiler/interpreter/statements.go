@@ -0,0 +1,224 @@
+package interpreter
+
+import (
+	"compiler/ast"
+	"fmt"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+func (in *Interpreter) VisitLetStatement(ls *ast.LetStatement) error {
+	if ls.IsConst() {
+		return in.defineLocalConst(ls)
+	}
+	name := ls.Name.Value
+	var slotType types.Type = types.I32
+	var init Value
+	if ls.Value != nil {
+		if err := ls.Value.Accept(in); err != nil {
+			return err
+		}
+		if !in.last.valid() {
+			in.last = constInt(types.I32, 0)
+		}
+		init = in.last
+		if ls.Type != nil {
+			declType, err := in.mapType(ls.Type.Value)
+			if err != nil {
+				return fmt.Errorf("let '%s': %w", name, err)
+			}
+			converted, err := in.convert(init, declType)
+			if err != nil {
+				return fmt.Errorf("let '%s': %w", name, err)
+			}
+			init = converted
+		}
+		slotType = init.T
+	}
+
+	b := &binding{addr: in.localAlloca(slotKey{node: ls}, slotType), typ: slotType}
+	if init.fn != nil {
+		b.sig = init.fn.sig
+	}
+	in.fr.vars[name] = b
+	if init.valid() {
+		in.store(b.addr, init)
+	}
+	return nil
+}
+
+// defineLocalConst binds a local const to its folded value.
+func (in *Interpreter) defineLocalConst(ls *ast.LetStatement) error {
+	name := ls.Name.Value
+	c, err := in.evalConst(ls.Value)
+	if err != nil {
+		return fmt.Errorf("const '%s': %w", name, err)
+	}
+	if ls.Type != nil {
+		declType, err := in.mapType(ls.Type.Value)
+		if err != nil {
+			return fmt.Errorf("const '%s': %w", name, err)
+		}
+		if c, err = in.convertConst(c, declType); err != nil {
+			return fmt.Errorf("const '%s': %w", name, err)
+		}
+	}
+	in.fr.vars[name] = &binding{konst: c}
+	return nil
+}
+
+func (in *Interpreter) VisitReturnStatement(rs *ast.ReturnStatement) error {
+	if in.fr.gen != nil {
+		if rs.ReturnValue != nil {
+			return fmt.Errorf("return in generator '%s' at line %d cannot have a value; use yield", in.fr.gen.info.name, rs.Token.Line+1)
+		}
+		in.fr.returned = true
+		return nil
+	}
+	in.fr.returned = true
+	in.fr.ret = constInt(types.I32, 0)
+	if rs.ReturnValue == nil {
+		return nil
+	}
+	if err := rs.ReturnValue.Accept(in); err != nil {
+		return err
+	}
+	if v := in.last; v.valid() {
+		if err := in.checkSeqReturn(v); err != nil {
+			return err
+		}
+		if f := in.fr.fn; f != nil && !f.typ.RetType.Equal(types.Void) {
+			if converted, err := in.convert(v, f.typ.RetType); err == nil {
+				v = converted
+			}
+		}
+		in.fr.ret = v
+	}
+	return nil
+}
+
+func (in *Interpreter) VisitExpressionStatement(es *ast.ExpressionStatement) error {
+	if es.Expression == nil {
+		return nil
+	}
+	return es.Expression.Accept(in)
+}
+
+func (in *Interpreter) VisitBlockStatement(bs *ast.BlockStatement) error {
+	for _, stmt := range bs.Statements {
+		if stmt == nil {
+			continue
+		}
+		if err := stmt.Accept(in); err != nil {
+			return err
+		}
+		if in.fr.returned {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (in *Interpreter) VisitIfStatement(is *ast.IfStatement) error {
+	if err := is.Condition.Accept(in); err != nil {
+		return err
+	}
+	cond := in.last
+	if !cond.valid() {
+		return fmt.Errorf("if condition '%s' produced no value", is.Condition.String())
+	}
+	branch := is.Alternative
+	if condAsBool(cond) {
+		branch = is.Consequence
+	}
+	if branch != nil {
+		if err := branch.Accept(in); err != nil {
+			return err
+		}
+	}
+	in.last = constInt(types.I32, 0)
+	return nil
+}
+
+func (in *Interpreter) VisitWhileStatement(ws *ast.WhileStatement) error {
+	for {
+		if err := ws.Condition.Accept(in); err != nil {
+			return err
+		}
+		if !in.last.valid() {
+			return fmt.Errorf("while condition '%s' produced no value", ws.Condition.String())
+		}
+		if !condAsBool(in.last) {
+			break
+		}
+		if ws.Body != nil {
+			in.fr.loopDepth++
+			err := ws.Body.Accept(in)
+			in.fr.loopDepth--
+			if err != nil {
+				return err
+			}
+		}
+		if in.fr.returned {
+			return nil
+		}
+	}
+	in.last = constInt(types.I32, 0)
+	return nil
+}
+
+func (in *Interpreter) VisitForInStatement(fs *ast.ForInStatement) error {
+	if err := fs.Iterable.Accept(in); err != nil {
+		return err
+	}
+	iterable := in.last
+	if !iterable.valid() {
+		return fmt.Errorf("for loop at line %d: '%s' produced no value", fs.Token.Line+1, fs.Iterable.String())
+	}
+
+	var elemType types.Type
+	seqElem, isSeq := in.seqElem(iterable.T)
+	slice := pointeeStruct(iterable.T)
+	switch {
+	case isSeq:
+		elemType = seqElem
+	case slice != nil && isSliceType(slice):
+		elemType = pointee(slice.Fields[1])
+	default:
+		return fmt.Errorf("for loop at line %d: cannot iterate over '%s' of type %s; expected an array, a slice or a seq", fs.Token.Line+1, fs.Iterable.String(), iterable.T)
+	}
+
+	item := &binding{addr: in.entryAlloca(slotKey{node: fs, kind: slotLoopItem}, elemType), typ: elemType}
+	in.fr.vars[fs.Variable.Value] = item
+	body := func(elem Value) (bool, error) {
+		in.store(item.addr, elem)
+		in.fr.loopDepth++
+		err := fs.Body.Accept(in)
+		in.fr.loopDepth--
+		return !in.fr.returned, err
+	}
+
+	var err error
+	if isSeq {
+		err = in.forEachSeq(iterable, body)
+	} else {
+		err = in.forEachElement(iterable, func(index int64, elem Value) (bool, error) {
+			return body(elem)
+		})
+	}
+	if err != nil || in.fr.returned {
+		return err
+	}
+	in.last = constInt(types.I32, 0)
+	return nil
+}
+
+func (in *Interpreter) VisitVariableDeclaration(vd *ast.VariableDeclaration) error {
+	return nil
+}
+
+// VisitFunctionDefinition has nothing to do: functions are declared with
+// their module and their bodies run when called.
+func (in *Interpreter) VisitFunctionDefinition(fn *ast.FunctionDefinition) error {
+	return nil
+}
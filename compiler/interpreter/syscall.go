@@ -0,0 +1,606 @@
+package interpreter
+
+import (
+	"compiler/compiler/target"
+	"errors"
+	"io"
+	"os"
+	"runtime"
+	"syscall"
+	"unsafe"
+)
+
+// Error numbers returned, negated, by emulated system calls.
+const (
+	eagain = int64(syscall.EAGAIN)
+	ebadf  = int64(syscall.EBADF)
+	echild = int64(syscall.ECHILD)
+	efault = int64(syscall.EFAULT)
+	einval = int64(syscall.EINVAL)
+	eio    = int64(syscall.EIO)
+	enosys = int64(syscall.ENOSYS)
+	epipe  = int64(syscall.EPIPE)
+)
+
+const (
+	atFDCWD      = -100
+	mapAnonymous = 0x20
+	futexWait    = 0
+	futexCmdMask = 0x7f
+)
+
+// system carries out the system calls of a program. Descriptors 0, 1 and 2
+// are the streams of the Interpreter; the others are descriptors of the host
+// opened on the program's behalf, renumbered the way the kernel numbers
+// them, so a program sees the descriptors a compiled one would. Calls taking
+// or filling buffers copy them between the program's memory and the host's.
+type system struct {
+	in    *Interpreter
+	names map[int64]string // the program's syscall numbers
+	host  map[string]int64 // the host's
+	fds   map[int64]*fileDesc
+}
+
+// fileDesc is an open descriptor of the program: a host descriptor, or one of
+// the standard streams.
+type fileDesc struct {
+	host   int
+	stream int // 0, 1 or 2 for a stream, else -1
+}
+
+func newSystem(in *Interpreter) *system {
+	s := &system{
+		in:    in,
+		names: make(map[int64]string),
+		fds:   make(map[int64]*fileDesc),
+	}
+	for name, num := range in.target.Syscall.Numbers {
+		s.names[num] = name
+	}
+	if host, err := target.Lookup(runtime.GOARCH); err == nil {
+		s.host = host.Syscall.Numbers
+	}
+	for fd := 0; fd < 3; fd++ {
+		s.fds[int64(fd)] = &fileDesc{host: -1, stream: fd}
+	}
+	return s
+}
+
+// close closes the host descriptors the program left open.
+func (s *system) close() {
+	for fd, d := range s.fds {
+		if d.stream < 0 {
+			syscall.Close(d.host)
+		}
+		delete(s.fds, fd)
+	}
+}
+
+// writeFD writes b to the program's descriptor fd and returns the number of
+// bytes written or a negated error number.
+func (s *system) writeFD(fd int64, b []byte) int64 {
+	d, ok := s.fds[fd]
+	if !ok {
+		return -ebadf
+	}
+	if d.stream < 0 {
+		return s.raw("write", d.host, b, len(b))
+	}
+	_, stdout, stderr := s.in.stdio()
+	w := stdout
+	switch d.stream {
+	case 0:
+		return -ebadf
+	case 2:
+		w = stderr
+	}
+	n, err := w.Write(b)
+	if err != nil && n == 0 {
+		if errors.Is(err, syscall.EPIPE) {
+			return -epipe
+		}
+		return -eio
+	}
+	return int64(n)
+}
+
+// readFD reads up to n bytes from the program's descriptor fd.
+func (s *system) readFD(fd int64, n int64) ([]byte, int64) {
+	d, ok := s.fds[fd]
+	if !ok {
+		return nil, -ebadf
+	}
+	buf := make([]byte, n)
+	if d.stream < 0 {
+		r := s.raw("read", d.host, buf, n)
+		if r < 0 {
+			return nil, r
+		}
+		return buf[:r], r
+	}
+	if d.stream != 0 {
+		return nil, -ebadf
+	}
+	stdin, _, _ := s.in.stdio()
+	k, err := stdin.Read(buf)
+	if k == 0 && err != nil && err != io.EOF {
+		return nil, -eio
+	}
+	return buf[:k], int64(k)
+}
+
+// hostFD returns the host descriptor behind the program's descriptor fd.
+// The streams have one only when they are files.
+func (s *system) hostFD(fd int64) (int, bool) {
+	if fd == atFDCWD {
+		return atFDCWD, true
+	}
+	d, ok := s.fds[fd]
+	if !ok {
+		return -1, false
+	}
+	if d.stream < 0 {
+		return d.host, true
+	}
+	stdin, stdout, stderr := s.in.stdio()
+	if f, ok := []any{stdin, stdout, stderr}[d.stream].(*os.File); ok {
+		return int(f.Fd()), true
+	}
+	return -1, false
+}
+
+// newFD gives the host descriptor host the lowest free descriptor number of
+// the program. A negative host is the error of the call that was to create
+// it and is returned as is.
+func (s *system) newFD(host int64) int64 {
+	if host < 0 {
+		return host
+	}
+	fd := int64(0)
+	for s.fds[fd] != nil {
+		fd++
+	}
+	s.fds[fd] = &fileDesc{host: int(host), stream: -1}
+	return fd
+}
+
+// raw makes the host system call name. Arguments are integers or byte
+// slices, passed as the address of their first byte, and the result is a
+// negated error number on failure.
+func (s *system) raw(name string, args ...any) int64 {
+	num, ok := s.host[name]
+	if !ok {
+		return -enosys
+	}
+	var a [6]uintptr
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case int:
+			a[i] = uintptr(arg)
+		case int64:
+			a[i] = uintptr(arg)
+		case []byte:
+			if len(arg) > 0 {
+				a[i] = uintptr(unsafe.Pointer(&arg[0]))
+			}
+		}
+	}
+	r, _, errno := syscall.Syscall6(uintptr(num), a[0], a[1], a[2], a[3], a[4], a[5])
+	runtime.KeepAlive(args)
+	if errno != 0 {
+		return -int64(errno)
+	}
+	return int64(r)
+}
+
+// read copies n bytes of the program's memory at addr; a null addr gives
+// nil.
+func (s *system) read(addr, n int64) ([]byte, bool) {
+	if addr == 0 {
+		return nil, true
+	}
+	if n < 0 || !s.in.mem.mapped(uint64(addr), uint64(n)) {
+		return nil, false
+	}
+	return s.in.mem.readBytes(uint64(addr), uint64(n)), true
+}
+
+// write copies b to the program's memory at addr.
+func (s *system) write(addr int64, b []byte) bool {
+	if addr == 0 || !s.in.mem.mapped(uint64(addr), uint64(len(b))) {
+		return false
+	}
+	s.in.mem.writeBytes(uint64(addr), b)
+	return true
+}
+
+// path reads the NUL-terminated path at addr as a host string.
+func (s *system) path(addr int64) ([]byte, bool) {
+	if addr == 0 || !s.in.mem.mapped(uint64(addr), 1) {
+		return nil, false
+	}
+	return append([]byte(s.in.mem.cString(uint64(addr))), 0), true
+}
+
+// call carries out the system call num of the program with args and
+// returns its result.
+func (s *system) call(num int64, args []int64) int64 {
+	a := func(i int) int64 { return args[i] }
+	fd := func(i int) (int, bool) { return s.hostFD(args[i]) }
+
+	switch name := s.names[num]; name {
+	case "read":
+		b, r := s.readFD(a(0), max(a(2), 0))
+		if r > 0 && !s.write(a(1), b) {
+			return -efault
+		}
+		return r
+	case "write":
+		b, ok := s.read(a(1), a(2))
+		if !ok {
+			return -efault
+		}
+		return s.writeFD(a(0), b)
+	case "close":
+		d, ok := s.fds[a(0)]
+		if !ok {
+			return -ebadf
+		}
+		delete(s.fds, a(0))
+		if d.stream < 0 {
+			return s.raw("close", d.host)
+		}
+		return 0
+
+	case "openat", "mkdirat", "unlinkat":
+		dir, ok := fd(0)
+		if !ok {
+			return -ebadf
+		}
+		p, ok := s.path(a(1))
+		if !ok {
+			return -efault
+		}
+		r := s.raw(name, dir, p, a(2), a(3))
+		if name == "openat" {
+			return s.newFD(r)
+		}
+		return r
+	case "renameat2":
+		oldDir, ok1 := fd(0)
+		newDir, ok2 := fd(2)
+		if !ok1 || !ok2 {
+			return -ebadf
+		}
+		oldPath, ok1 := s.path(a(1))
+		newPath, ok2 := s.path(a(3))
+		if !ok1 || !ok2 {
+			return -efault
+		}
+		return s.raw(name, oldDir, oldPath, newDir, newPath, a(4))
+	case "statx":
+		dir, ok := fd(0)
+		if !ok {
+			return -ebadf
+		}
+		p, ok := s.path(a(1))
+		if !ok {
+			return -efault
+		}
+		buf := make([]byte, 256)
+		r := s.raw(name, dir, p, a(2), a(3), buf)
+		if r == 0 && !s.write(a(4), buf) {
+			return -efault
+		}
+		return r
+	case "getdents64":
+		h, ok := fd(0)
+		if !ok {
+			return -ebadf
+		}
+		buf := make([]byte, max(a(2), 0))
+		r := s.raw(name, h, buf, len(buf))
+		if r > 0 && !s.write(a(1), buf[:r]) {
+			return -efault
+		}
+		return r
+	case "fcntl", "listen", "shutdown", "timerfd_settime", "epoll_ctl", "setsockopt", "bind", "connect":
+		return s.fdCall(name, args)
+	case "dup3":
+		d, ok := s.fds[a(0)]
+		if !ok || a(0) == a(1) || a(1) < 0 {
+			if ok {
+				return -einval
+			}
+			return -ebadf
+		}
+		nd := &fileDesc{host: -1, stream: d.stream}
+		if d.stream < 0 {
+			r := s.raw("fcntl", d.host, int64(syscall.F_DUPFD_CLOEXEC), 0)
+			if r < 0 {
+				return r
+			}
+			nd.host = int(r)
+		}
+		if old, ok := s.fds[a(1)]; ok && old.stream < 0 {
+			s.raw("close", old.host)
+		}
+		s.fds[a(1)] = nd
+		return a(1)
+
+	case "pipe2", "socketpair":
+		pair := make([]byte, 8)
+		r, out := int64(0), a(3)
+		if name == "pipe2" {
+			r, out = s.raw(name, pair, a(1)), a(0)
+		} else {
+			r = s.raw(name, a(0), a(1), a(2), pair)
+		}
+		if r < 0 {
+			return r
+		}
+		fds := make([]byte, 8)
+		putUint(fds, 4, uint64(s.newFD(int64(int32(getUint(pair, 4))))))
+		putUint(fds[4:], 4, uint64(s.newFD(int64(int32(getUint(pair[4:], 4))))))
+		if !s.write(out, fds) {
+			return -efault
+		}
+		return r
+	case "socket", "epoll_create1", "timerfd_create":
+		return s.newFD(s.raw(name, a(0), a(1), a(2)))
+	case "accept4", "getsockname", "getpeername", "recvfrom":
+		return s.addrCall(name, args)
+	case "sendto":
+		h, ok := fd(0)
+		if !ok {
+			return -ebadf
+		}
+		buf, ok1 := s.read(a(1), a(2))
+		addr, ok2 := s.read(a(4), a(5))
+		if !ok1 || !ok2 {
+			return -efault
+		}
+		return s.raw(name, h, buf, a(2), a(3), addr, a(5))
+	case "epoll_pwait":
+		h, ok := fd(0)
+		if !ok {
+			return -ebadf
+		}
+		size := s.in.target.Syscall.EpollEventSize
+		events := make([]byte, max(a(2), 0)*size)
+		mask, ok := s.read(a(4), a(5))
+		if !ok {
+			return -efault
+		}
+		r := s.raw(name, h, events, a(2), a(3), mask, a(5))
+		if r > 0 && !s.write(a(1), events[:r*size]) {
+			return -efault
+		}
+		return r
+	case "ppoll":
+		return s.ppoll(args)
+
+	case "mmap":
+		return s.mmap(args)
+	case "munmap":
+		s.in.mem.unmap(uint64(a(0)), alignUp(uint64(a(1)), pageSize))
+		return 0
+	case "mprotect", "sched_yield":
+		return 0
+
+	case "clock_gettime":
+		ts := make([]byte, 16)
+		r := s.raw(name, a(0), ts)
+		if r == 0 && !s.write(a(1), ts) {
+			return -efault
+		}
+		return r
+	case "nanosleep":
+		req, ok := s.read(a(0), 16)
+		if !ok || req == nil {
+			return -efault
+		}
+		rem := make([]byte, 16)
+		r := s.raw(name, req, rem)
+		if a(1) != 0 {
+			s.write(a(1), rem)
+		}
+		return r
+	case "getrandom":
+		buf := make([]byte, max(a(1), 0))
+		r := s.raw(name, buf, len(buf), a(2))
+		if r > 0 && !s.write(a(0), buf[:r]) {
+			return -efault
+		}
+		return r
+	case "getpid", "gettid":
+		return s.raw(name)
+	case "kill":
+		if pid := s.raw("getpid"); a(0) == pid || a(0) == 0 {
+			if a(1) == 0 {
+				return 0
+			}
+			panic(signal(a(1)))
+		}
+		return s.raw(name, a(0), a(1))
+	case "futex":
+		// With a single thread nothing can wake a waiter: waiting on a value
+		// that has changed fails as it should, and waiting on one that has
+		// not returns at once, as a spurious wake-up.
+		if a(1)&futexCmdMask == futexWait {
+			if !s.in.mem.mapped(uint64(a(0)), 4) {
+				return -efault
+			}
+			if int32(s.in.mem.readUint(uint64(a(0)), 4)) != int32(a(2)) {
+				return -eagain
+			}
+		}
+		return 0
+	case "exit", "exit_group":
+		panic(exitStatus(a(0)))
+	case "wait4":
+		return -echild
+	}
+	// Among them clone and execve: the interpreter runs one thread of one
+	// process.
+	return -enosys
+}
+
+// fdCall makes a call whose first argument is a descriptor, copying in the
+// buffer some of them take.
+func (s *system) fdCall(name string, args []int64) int64 {
+	h, ok := s.hostFD(args[0])
+	if !ok {
+		return -ebadf
+	}
+	switch name {
+	case "bind", "connect":
+		addr, ok := s.read(args[1], args[2])
+		if !ok {
+			return -efault
+		}
+		return s.raw(name, h, addr, args[2])
+	case "setsockopt":
+		val, ok := s.read(args[3], args[4])
+		if !ok {
+			return -efault
+		}
+		return s.raw(name, h, args[1], args[2], val, args[4])
+	case "epoll_ctl":
+		target, ok := s.hostFD(args[2])
+		if !ok {
+			return -ebadf
+		}
+		event, ok := s.read(args[3], s.in.target.Syscall.EpollEventSize)
+		if !ok {
+			return -efault
+		}
+		return s.raw(name, h, args[1], target, event)
+	case "timerfd_settime":
+		newValue, ok := s.read(args[2], 32)
+		if !ok {
+			return -efault
+		}
+		old := make([]byte, 32)
+		r := s.raw(name, h, args[1], newValue, old)
+		if r == 0 && args[3] != 0 {
+			s.write(args[3], old)
+		}
+		return r
+	}
+	return s.raw(name, h, args[1], args[2])
+}
+
+// addrCall makes a call filling a socket address whose length the program
+// passes by reference: accept4, getsockname, getpeername and recvfrom.
+func (s *system) addrCall(name string, args []int64) int64 {
+	h, ok := s.hostFD(args[0])
+	if !ok {
+		return -ebadf
+	}
+	addrArg, lenArg := args[1], args[2]
+	if name == "recvfrom" {
+		addrArg, lenArg = args[4], args[5]
+	}
+	var addr, addrLen []byte
+	if addrArg != 0 {
+		if addrLen, ok = s.read(lenArg, 4); !ok || addrLen == nil {
+			return -efault
+		}
+		addr = make([]byte, getUint(addrLen, 4))
+	}
+	lenPtr := addrLen
+	if lenPtr == nil {
+		lenPtr = []byte{}
+	}
+
+	var r int64
+	switch name {
+	case "recvfrom":
+		buf := make([]byte, max(args[2], 0))
+		r = s.raw(name, h, buf, len(buf), args[3], addr, lenPtr)
+		if r > 0 && !s.write(args[1], buf[:r]) {
+			return -efault
+		}
+	case "accept4":
+		r = s.newFD(s.raw(name, h, addr, lenPtr, args[3]))
+	default:
+		r = s.raw(name, h, addr, lenPtr)
+	}
+	if r >= 0 && addr != nil {
+		n := min(uint64(len(addr)), getUint(addrLen, 4))
+		s.write(addrArg, addr[:n])
+		s.write(lenArg, addrLen)
+	}
+	return r
+}
+
+// ppoll polls the descriptors of the program's pollfd array, translating
+// them to the host's and back.
+func (s *system) ppoll(args []int64) int64 {
+	n := max(args[1], 0)
+	fds, ok := s.read(args[0], n*8)
+	if !ok {
+		return -efault
+	}
+	var timeout []byte
+	if args[2] != 0 {
+		if timeout, ok = s.read(args[2], 16); !ok {
+			return -efault
+		}
+	}
+	mask, ok := s.read(args[3], args[4])
+	if !ok {
+		return -efault
+	}
+	polls := make([]byte, len(fds))
+	copy(polls, fds)
+	for i := int64(0); i < n; i++ {
+		fd := int64(int32(getUint(fds[i*8:], 4)))
+		if fd < 0 {
+			continue
+		}
+		// A descriptor the program does not have reports POLLNVAL.
+		h, ok := s.hostFD(fd)
+		if !ok {
+			h = 1 << 30
+		}
+		putUint(polls[i*8:], 4, uint64(uint32(h)))
+	}
+	r := s.raw("ppoll", polls, n, timeout, mask, args[4])
+	if r < 0 {
+		return r
+	}
+	for i := int64(0); i < n; i++ {
+		copy(fds[i*8+6:i*8+8], polls[i*8+6:i*8+8])
+	}
+	s.write(args[0], fds)
+	return r
+}
+
+// mmap maps anonymous memory, or a copy of a file for file mappings, which
+// the program cannot tell apart as long as it does not write to a shared
+// mapping.
+func (s *system) mmap(args []int64) int64 {
+	length := args[1]
+	if length <= 0 {
+		return -einval
+	}
+	addr := s.in.mem.mmap(uint64(length))
+	if args[3]&mapAnonymous != 0 {
+		return int64(addr)
+	}
+	h, ok := s.hostFD(args[4])
+	if !ok {
+		s.in.mem.unmap(addr, alignUp(uint64(length), pageSize))
+		return -ebadf
+	}
+	buf := make([]byte, length)
+	r := s.raw("pread64", h, buf, length, args[5])
+	if r < 0 {
+		s.in.mem.unmap(addr, alignUp(uint64(length), pageSize))
+		return r
+	}
+	s.in.mem.writeBytes(addr, buf[:r])
+	return int64(addr)
+}
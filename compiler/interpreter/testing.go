@@ -0,0 +1,108 @@
+package interpreter
+
+import (
+	"compiler/ast"
+	"fmt"
+)
+
+// testingModule reports failed assertions. Its test harness runs each test
+// in a child process, which the interpreter cannot fork, so only the
+// assertions are available.
+const testingModule = "stdlib/testing"
+
+// isAssertion reports whether ce calls the assert or assertEq intrinsic,
+// which a program may shadow with a name of its own.
+func (in *Interpreter) isAssertion(ce *ast.CallExpression) bool {
+	ident, ok := ce.Function.(*ast.Identifier)
+	if !ok || (ident.Value != "assert" && ident.Value != "assertEq") {
+		return false
+	}
+	_, isVar := in.fr.vars[ident.Value]
+	_, isFunc := in.functions[ident.Value]
+	return !isVar && !isFunc && !in.scope.declares(ident.Value)
+}
+
+// visitAssertion runs assert(cond) or assertEq(got, want). When the check
+// fails, testing.fail or testing.failEq reports the file, the line and the
+// source of the call, and ends the program with status 1.
+func (in *Interpreter) visitAssertion(ce *ast.CallExpression) error {
+	name := ce.Function.(*ast.Identifier).Value
+	want := 1
+	if name == "assertEq" {
+		want = 2
+	}
+	if len(ce.Arguments) != want {
+		return fmt.Errorf("%s expects %d argument(s), got %d", name, want, len(ce.Arguments))
+	}
+	fns, err := in.moduleFuncs(testingModule, name, "fail", "failEq", "equal")
+	if err != nil {
+		return err
+	}
+
+	args := make([]Value, len(ce.Arguments))
+	for i, arg := range ce.Arguments {
+		if err := arg.Accept(in); err != nil {
+			return err
+		}
+		if !in.last.valid() {
+			return fmt.Errorf("argument %d of %s has no value", i+1, name)
+		}
+		args[i] = in.last
+	}
+
+	c := &calls{in: in, fns: fns}
+	var ok bool
+	if name == "assert" {
+		ok = condAsBool(args[0])
+	} else {
+		for i := range args {
+			if args[i], err = in.boxAny(args[i]); err != nil {
+				return fmt.Errorf("argument %d of %s: %w", i+1, name, err)
+			}
+		}
+		ok = condAsBool(c.call("equal", args[0], args[1]))
+	}
+
+	if c.err == nil && !ok {
+		where := in.cString(fmt.Sprintf("%s:%d", in.sourceFile(), ce.Token.Line+1))
+		if name == "assert" {
+			c.call("fail", where, in.cString(unparen(ce.Arguments[0].String())))
+		} else {
+			c.call("failEq", where, in.cString(ce.String()), args[0], args[1])
+		}
+	}
+	in.last = noValue
+	return c.err
+}
+
+// sourceFile names the file of the module being run.
+func (in *Interpreter) sourceFile() string {
+	if in.scope.path == "" {
+		if in.SourceFile == "" {
+			return "<input>"
+		}
+		return in.SourceFile
+	}
+	return in.scope.file
+}
+
+// unparen drops the parentheses the printed form of an infix expression is
+// wrapped in, so assert(x == 5) is reported as "x == 5".
+func unparen(s string) string {
+	if len(s) < 2 || s[0] != '(' || s[len(s)-1] != ')' {
+		return s
+	}
+	depth := 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 && i != len(s)-1 {
+				return s
+			}
+		}
+	}
+	return s[1 : len(s)-1]
+}
@@ -0,0 +1,423 @@
+package interpreter
+
+import (
+	"compiler/ast"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+// Types are the LLVM types the code generator maps source types to, so that
+// values convert, overflow and lie in memory exactly as in compiled code.
+
+// mapType maps a source type name to its type.
+func (in *Interpreter) mapType(typeName string) (types.Type, error) {
+	// Function types '(int,string)->int' are passed around as function pointers.
+	if strings.HasPrefix(typeName, "(") {
+		return in.mapFuncType(typeName)
+	}
+
+	// Sequences 'seq<T>' are pointers to the header of a generator frame.
+	if strings.HasPrefix(typeName, "seq<") && strings.HasSuffix(typeName, ">") {
+		elemType, err := in.mapType(typeName[len("seq<") : len(typeName)-1])
+		if err != nil {
+			return nil, err
+		}
+		return types.NewPointer(in.seqType(elemType)), nil
+	}
+
+	// Slices '[]T' and variadic packs '...T' are pointers to a {length, data} struct.
+	if strings.HasPrefix(typeName, "[]") || strings.HasPrefix(typeName, "...") {
+		elemName := strings.TrimPrefix(strings.TrimPrefix(typeName, "[]"), "...")
+		sliceType, err := in.sliceType(elemName)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewPointer(sliceType), nil
+	}
+
+	// Pointer types are spelled both '*int' and 'int*'.
+	if strings.HasPrefix(typeName, "*") || strings.HasSuffix(typeName, "*") {
+		baseTypeName := strings.TrimPrefix(typeName, "*")
+		if baseTypeName == typeName {
+			baseTypeName = strings.TrimSuffix(typeName, "*")
+		}
+		baseType, err := in.mapType(baseTypeName)
+		if err != nil {
+			return nil, fmt.Errorf("unknown base type '%s' for pointer type '%s'", baseTypeName, typeName)
+		}
+		return types.NewPointer(baseType), nil
+	}
+
+	switch typeName {
+	case "int":
+		return types.I32, nil
+	case "float", "f32":
+		return types.Float, nil
+	case "double", "f64":
+		return types.Double, nil
+	case "bool":
+		return types.I1, nil
+	case "string":
+		return i8Ptr, nil
+	case "void":
+		return types.Void, nil
+	case "any":
+		return in.anyType(), nil
+	case "i8", "u8", "byte":
+		return types.I8, nil
+	case "i16", "u16":
+		return types.I16, nil
+	case "i32", "u32":
+		return types.I32, nil
+	case "i64", "u64":
+		return types.I64, nil
+	}
+	if t, ok := in.scope.types[typeName]; ok {
+		return t, nil
+	}
+	if t, ok := in.structs[typeName]; ok {
+		return t, nil
+	}
+	return nil, fmt.Errorf("unsupported or undefined type: %s", typeName)
+}
+
+// mapFuncType maps a function type spelled '(T1,T2)->R' to a pointer to the
+// corresponding function type.
+func (in *Interpreter) mapFuncType(typeName string) (types.Type, error) {
+	depth := 0
+	closing := -1
+	for i, r := range typeName {
+		if r == '(' {
+			depth++
+		} else if r == ')' {
+			depth--
+			if depth == 0 {
+				closing = i
+				break
+			}
+		}
+	}
+	if closing < 0 || !strings.HasPrefix(typeName[closing+1:], "->") {
+		return nil, fmt.Errorf("malformed function type: %s", typeName)
+	}
+
+	var paramTypes []types.Type
+	for _, paramName := range splitTypeList(typeName[1:closing]) {
+		paramType, err := in.mapType(paramName)
+		if err != nil {
+			return nil, err
+		}
+		paramTypes = append(paramTypes, paramType)
+	}
+	retType, err := in.mapType(typeName[closing+3:])
+	if err != nil {
+		return nil, err
+	}
+	return types.NewPointer(types.NewFunc(retType, paramTypes...)), nil
+}
+
+// splitTypeList splits a comma separated list of type names, ignoring commas
+// nested inside function types.
+func splitTypeList(list string) []string {
+	var parts []string
+	depth := 0
+	start := 0
+	for i, r := range list {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, list[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(list) {
+		parts = append(parts, list[start:])
+	}
+	return parts
+}
+
+// paramType returns the type of a declared parameter. Parameters without an
+// annotation are i32.
+func (in *Interpreter) paramType(param *ast.Parameter) (types.Type, error) {
+	if param.Type == nil {
+		return types.I32, nil
+	}
+	return in.mapType(param.Type.Value)
+}
+
+// unsignedTypeName reports whether a type name denotes an unsigned integer.
+func unsignedTypeName(name string) bool {
+	switch name {
+	case "u8", "u16", "u32", "u64", "byte":
+		return true
+	}
+	return false
+}
+
+// newNamedStruct creates a named struct type and registers its fields.
+func (in *Interpreter) newNamedStruct(name string, fieldNames []string, fields ...types.Type) *types.StructType {
+	st := types.NewStruct(fields...)
+	st.SetName(name)
+	in.structs[name] = st
+	in.structFields[name] = fieldNames
+	return st
+}
+
+// Kind tags stored in the first field of an any value, as in compiled code.
+const (
+	anyKindNil = iota
+	anyKindInt
+	anyKindFloat
+	anyKindString
+	anyKindBool
+	anyKindPointer
+)
+
+// anyType returns the struct { i32 kind, i64 bits } of any values.
+func (in *Interpreter) anyType() *types.StructType {
+	if st, ok := in.structs["Any"].(*types.StructType); ok {
+		return st
+	}
+	return in.newNamedStruct("Any", []string{"kind", "value"}, types.I32, types.I64)
+}
+
+// boxAny wraps a primitive value in an any, tagging it with its kind.
+func (in *Interpreter) boxAny(v Value) (Value, error) {
+	anyType := in.anyType()
+	if v.T.Equal(anyType) {
+		return v, nil
+	}
+	var kind int64
+	var bits uint64
+	switch t := v.T.(type) {
+	case *types.IntType:
+		if t.BitSize == 1 {
+			kind = anyKindBool
+			bits = uint64(v.I & 1)
+		} else {
+			kind = anyKindInt
+			bits = uint64(v.signed())
+		}
+	case *types.FloatType:
+		kind = anyKindFloat
+		bits = float64bits(v.F)
+	case *types.PointerType:
+		if t.ElemType.Equal(types.I8) {
+			kind = anyKindString
+		} else {
+			kind = anyKindPointer
+		}
+		bits = v.addr()
+	default:
+		return noValue, fmt.Errorf("cannot convert value of type %s to any", v.T)
+	}
+	return in.makeAny(kind, bits), nil
+}
+
+func (in *Interpreter) makeAny(kind int64, bits uint64) Value {
+	anyType := in.anyType()
+	b := make([]byte, in.sizeOf(anyType))
+	l := in.layout(anyType)
+	putUint(b[l.offsets[0]:], 4, uint64(kind))
+	putUint(b[l.offsets[1]:], 8, bits)
+	return Value{T: anyType, B: b}
+}
+
+// unboxAny returns the kind and bits of an any value.
+func (in *Interpreter) unboxAny(v Value) (kind int64, bits uint64) {
+	l := in.layout(in.anyType())
+	return int64(int32(getUint(v.B[l.offsets[0]:], 4))), getUint(v.B[l.offsets[1]:], 8)
+}
+
+// anyView implements the read-only views of the any at addr: v.int (i64),
+// v.float (double), v.string (i8*) and v.bool (i1).
+func (in *Interpreter) anyView(addr uint64, view string) (Value, bool) {
+	bitsAddr := in.fieldAddr(in.anyType(), addr, 1)
+	switch view {
+	case "int":
+		return in.load(bitsAddr, types.I64), true
+	case "float":
+		return Value{T: types.Double, F: float64frombits(in.mem.readUint(bitsAddr, 8))}, true
+	case "string":
+		return pointerValue(i8Ptr, in.mem.readUint(bitsAddr, 8)), true
+	case "bool":
+		return intValue(types.I1, int64(in.mem.readUint(bitsAddr, 8)&1)), true
+	}
+	return noValue, false
+}
+
+// sliceTypeNames turns a type spelling into a fragment usable in a type
+// name.
+var sliceTypeNames = strings.NewReplacer("*", "ptr.", "[]", "slice.", "...", "slice.", "(", "fn.", ")", ".", ",", ".", "->", "ret.")
+
+// sliceType returns the struct backing []T and ...T: { i32 length, T* data }.
+// []int is the predefined Array, so slices and array literals interoperate.
+func (in *Interpreter) sliceType(elemName string) (*types.StructType, error) {
+	if elemName == "int" || elemName == "i32" {
+		return in.structs["Array"].(*types.StructType), nil
+	}
+	typeName := "Slice." + sliceTypeNames.Replace(elemName)
+	if st, ok := in.structs[typeName].(*types.StructType); ok {
+		return st, nil
+	}
+	elemType, err := in.mapType(elemName)
+	if err != nil {
+		return nil, err
+	}
+	return in.newNamedStruct(typeName, []string{"length", "data"}, types.I32, types.NewPointer(elemType)), nil
+}
+
+// isSliceType reports whether st is Array or the struct of a []T.
+func isSliceType(st *types.StructType) bool {
+	return st.Name() == "Array" || strings.HasPrefix(st.Name(), "Slice.")
+}
+
+// seqResumeType is the type of the function advancing a sequence.
+var seqResumeType = types.NewPointer(types.NewFunc(types.I1, i8Ptr))
+
+// Fields of the header shared by all sequences.
+const (
+	seqResumeField  = 0
+	seqCurrentField = 1
+)
+
+var seqTypeNames = strings.NewReplacer("%", "", "*", ".ptr", "\"", "", " ", "", "{", "", "}", "", ",", ".", "(", "", ")", "")
+
+// seqType returns the header struct { resume, current } of seq<T>.
+func (in *Interpreter) seqType(elem types.Type) *types.StructType {
+	key := elem.String()
+	if st, ok := in.seqTypes[key]; ok {
+		return st
+	}
+	st := types.NewStruct(seqResumeType, elem)
+	st.SetName("Seq." + seqTypeNames.Replace(key))
+	in.seqTypes[key] = st
+	return st
+}
+
+// seqElem returns the element type of t if t is a seq<T>.
+func (in *Interpreter) seqElem(t types.Type) (types.Type, bool) {
+	st := pointeeStruct(t)
+	if st == nil || len(st.Fields) != 2 {
+		return nil, false
+	}
+	if in.seqTypes[st.Fields[seqCurrentField].String()] != st {
+		return nil, false
+	}
+	return st.Fields[seqCurrentField], true
+}
+
+// defineStructType lays out the struct of a type declaration.
+func (in *Interpreter) defineStructType(cd *ast.ClassDeclaration) error {
+	typeName := cd.Name.Value
+	irName := in.scope.prefix + typeName
+	if _, exists := in.scope.types[typeName]; exists {
+		return nil
+	}
+	if t, exists := in.structs[irName]; exists && t.Name() == irName {
+		return nil
+	}
+
+	var fieldTypes []types.Type
+	var fieldNames []string
+	for _, member := range cd.Members {
+		vd := member.VariableDeclaration
+		if vd == nil {
+			continue
+		}
+		if vd.Type == nil {
+			return fmt.Errorf("type annotation missing for field '%s' in type '%s'", vd.Name.Value, typeName)
+		}
+		fieldType, err := in.mapType(vd.Type.Value)
+		if err != nil {
+			return fmt.Errorf("could not map type '%s' for field '%s' in type '%s': %w", vd.Type.Value, vd.Name.Value, typeName, err)
+		}
+		fieldTypes = append(fieldTypes, fieldType)
+		fieldNames = append(fieldNames, vd.Name.Value)
+	}
+
+	fieldTypes, fieldNames = layoutFields(cd.Layout, fieldTypes, fieldNames)
+	st := types.NewStruct(fieldTypes...)
+	st.Packed = cd.Layout == ast.StructLayoutPacked
+	st.SetName(irName)
+
+	in.scope.types[typeName] = st
+	in.structs[irName] = st
+	in.structFields[irName] = fieldNames
+	if _, taken := in.structs[typeName]; !taken {
+		in.structs[typeName] = st
+	}
+	return nil
+}
+
+// layoutFields orders the fields of a struct. extern and packed types keep
+// the declared order; otherwise fields are sorted by decreasing alignment,
+// keeping the declared order among equals.
+func layoutFields(layout ast.StructLayout, fieldTypes []types.Type, fieldNames []string) ([]types.Type, []string) {
+	if layout != ast.StructLayoutDefault {
+		return fieldTypes, fieldNames
+	}
+	order := make([]int, len(fieldTypes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return abiAlign(fieldTypes[order[a]]) > abiAlign(fieldTypes[order[b]])
+	})
+	sortedTypes := make([]types.Type, len(order))
+	sortedNames := make([]string, len(order))
+	for i, j := range order {
+		sortedTypes[i] = fieldTypes[j]
+		sortedNames[i] = fieldNames[j]
+	}
+	return sortedTypes, sortedNames
+}
+
+// abiAlign returns the alignment layoutFields sorts fields by.
+func abiAlign(t types.Type) int {
+	switch t := t.(type) {
+	case *types.IntType:
+		align := 1
+		for align*8 < int(t.BitSize) && align < 8 {
+			align *= 2
+		}
+		return align
+	case *types.FloatType:
+		if t.Kind == types.FloatKindFloat {
+			return 4
+		}
+		return 8
+	case *types.ArrayType:
+		return abiAlign(t.ElemType)
+	case *types.StructType:
+		if t.Packed {
+			return 1
+		}
+		align := 1
+		for _, field := range t.Fields {
+			if a := abiAlign(field); a > align {
+				align = a
+			}
+		}
+		return align
+	}
+	return 8
+}
+
+// fieldIndex returns the index of the named field of st.
+func (in *Interpreter) fieldIndex(st *types.StructType, name string) (int, bool) {
+	for i, field := range in.structFields[st.Name()] {
+		if field == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,331 @@
+package interpreter
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+// Value is a run-time value together with the LLVM type the code generator
+// gives it, so that conversions, overflow and the layout of data in memory
+// match compiled programs exactly.
+type Value struct {
+	T types.Type
+	I int64   // integers, sign-extended from their width (i1 is 0 or 1), and pointers
+	F float64 // floating point numbers; a float holds a float32 unless konst
+	B []byte  // structs, in their memory representation
+
+	// konst marks literals and folded constants. Constants keep their full
+	// precision until they are given a type, as in the code generator.
+	konst bool
+	// fn is the function itself when the value names one, rather than a
+	// pointer loaded or converted from elsewhere.
+	fn *function
+}
+
+// valid reports whether an expression produced a value.
+func (v Value) valid() bool { return v.T != nil }
+
+var (
+	i8Ptr   = types.NewPointer(types.I8)
+	i64Ptr  = types.NewPointer(types.I64)
+	noValue = Value{}
+)
+
+func intValue(t *types.IntType, x int64) Value {
+	return Value{T: t, I: wrap(x, t.BitSize)}
+}
+
+func boolValue(b bool) Value {
+	if b {
+		return Value{T: types.I1, I: 1}
+	}
+	return Value{T: types.I1}
+}
+
+func constInt(t *types.IntType, x int64) Value {
+	v := intValue(t, x)
+	v.konst = true
+	return v
+}
+
+func floatValue(t *types.FloatType, f float64) Value {
+	if t.Kind == types.FloatKindFloat {
+		f = float64(float32(f))
+	}
+	return Value{T: t, F: f}
+}
+
+func pointerValue(t types.Type, addr uint64) Value {
+	return Value{T: t, I: int64(addr)}
+}
+
+// wrap truncates x to bits and sign-extends it back, except that i1 values
+// are kept as 0 or 1.
+func wrap(x int64, bits uint64) int64 {
+	switch {
+	case bits >= 64:
+		return x
+	case bits == 1:
+		return x & 1
+	}
+	shift := 64 - bits
+	return x << shift >> shift
+}
+
+// signed returns the value of an integer read as signed.
+func (v Value) signed() int64 {
+	if t, ok := v.T.(*types.IntType); ok && t.BitSize == 1 {
+		return -(v.I & 1)
+	}
+	return v.I
+}
+
+// unsigned returns the value of an integer read as unsigned.
+func (v Value) unsigned() uint64 {
+	if t, ok := v.T.(*types.IntType); ok && t.BitSize < 64 {
+		return uint64(v.I) & (1<<t.BitSize - 1)
+	}
+	return uint64(v.I)
+}
+
+func (v Value) addr() uint64 { return uint64(v.I) }
+
+func isInt(t types.Type) bool {
+	_, ok := t.(*types.IntType)
+	return ok
+}
+
+func isFloat(t types.Type) bool {
+	_, ok := t.(*types.FloatType)
+	return ok
+}
+
+func isPointer(t types.Type) bool {
+	_, ok := t.(*types.PointerType)
+	return ok
+}
+
+func isDouble(t types.Type) bool {
+	f, ok := t.(*types.FloatType)
+	return ok && f.Kind == types.FloatKindDouble
+}
+
+// pointee returns the element type of a pointer type, or nil.
+func pointee(t types.Type) types.Type {
+	if p, ok := t.(*types.PointerType); ok {
+		return p.ElemType
+	}
+	return nil
+}
+
+// pointeeStruct returns the struct a pointer type points to, or nil.
+func pointeeStruct(t types.Type) *types.StructType {
+	st, _ := pointee(t).(*types.StructType)
+	return st
+}
+
+func floatBits(t *types.FloatType) int {
+	if t.Kind == types.FloatKindFloat {
+		return 32
+	}
+	return 64
+}
+
+// roundTo rounds f to the precision of t.
+func roundTo(t types.Type, f float64) float64 {
+	if ft, ok := t.(*types.FloatType); ok && ft.Kind == types.FloatKindFloat {
+		return float64(float32(f))
+	}
+	return f
+}
+
+// fptosi converts f to a signed integer of the given width the way x86-64
+// does: values out of range become the smallest integer of 32 or 64 bits.
+func fptosi(f float64, bits uint64) int64 {
+	if bits <= 32 {
+		if math.IsNaN(f) || f >= 1<<31 || f <= -(1<<31)-1 {
+			return wrap(math.MinInt32, bits)
+		}
+		return wrap(int64(f), bits)
+	}
+	if math.IsNaN(f) || f >= 1<<63 || f < -(1<<63) {
+		return math.MinInt64
+	}
+	return int64(f)
+}
+
+// fptoui converts f to an unsigned integer of the given width.
+func fptoui(f float64, bits uint64) int64 {
+	if f >= 1<<63 {
+		return wrap(int64(uint64(f)), bits)
+	}
+	return wrap(fptosi(f, 64), bits)
+}
+
+// convert converts v to t using the implicit conversions of the code
+// generator: integer widening (sign extending) and narrowing, int<->float,
+// int<->pointer, pointer casts and boxing into any.
+func (in *Interpreter) convert(v Value, t types.Type) (Value, error) {
+	if v.T.Equal(t) {
+		return v, nil
+	}
+	if t.Equal(in.anyType()) {
+		return in.boxAny(v)
+	}
+
+	switch dst := t.(type) {
+	case *types.IntType:
+		switch src := v.T.(type) {
+		case *types.IntType:
+			if v.konst {
+				return constInt(dst, v.I), nil
+			}
+			if src.BitSize == 1 || src.BitSize >= dst.BitSize {
+				return intValue(dst, int64(v.unsigned())), nil
+			}
+			return intValue(dst, v.signed()), nil
+		case *types.FloatType:
+			return Value{T: dst, I: fptosi(v.F, dst.BitSize)}, nil
+		case *types.PointerType:
+			return intValue(dst, v.I), nil
+		}
+	case *types.FloatType:
+		switch v.T.(type) {
+		case *types.IntType:
+			return floatValue(dst, float64(v.signed())), nil
+		case *types.FloatType:
+			if v.konst {
+				return Value{T: dst, F: v.F, konst: true}, nil
+			}
+			return floatValue(dst, v.F), nil
+		}
+	case *types.PointerType:
+		switch src := v.T.(type) {
+		case *types.IntType:
+			return pointerValue(dst, v.unsigned()), nil
+		case *types.PointerType:
+			if isSlicePointer(src) && isSlicePointer(dst) {
+				return in.convertSlice(v, dst)
+			}
+			if !pointerConvertible(src, dst) {
+				return noValue, fmt.Errorf("cannot convert value of type %s to %s without a cast", v.T, t)
+			}
+			return pointerValue(dst, v.addr()), nil
+		}
+	}
+	return noValue, fmt.Errorf("cannot convert value of type %s to %s", v.T, t)
+}
+
+// pointerConvertible reports whether a pointer of type src converts to dst
+// without a cast: *u8 stands for any pointer, and functions convert to other
+// function types. Anything else takes an explicit `as`.
+func pointerConvertible(src, dst *types.PointerType) bool {
+	if src.ElemType.Equal(types.I8) || dst.ElemType.Equal(types.I8) {
+		return true
+	}
+	_, srcFunc := src.ElemType.(*types.FuncType)
+	_, dstFunc := dst.ElemType.(*types.FuncType)
+	return srcFunc && dstFunc
+}
+
+// isSlicePointer reports whether t points to the struct of a slice.
+func isSlicePointer(t *types.PointerType) bool {
+	st, ok := t.ElemType.(*types.StructType)
+	return ok && isSliceType(st)
+}
+
+// convertSlice copies the slice at v into a new slice of type dst on the
+// stack, converting each element; only numeric elements convert, and any
+// element converts to any.
+func (in *Interpreter) convertSlice(v Value, dst *types.PointerType) (Value, error) {
+	srcType := v.T.(*types.PointerType).ElemType.(*types.StructType)
+	dstType := dst.ElemType.(*types.StructType)
+	srcElem := srcType.Fields[1].(*types.PointerType).ElemType
+	dstElem := dstType.Fields[1].(*types.PointerType).ElemType
+	if !dstElem.Equal(in.anyType()) && !(isNumeric(srcElem) && isNumeric(dstElem)) {
+		return noValue, fmt.Errorf("cannot convert value of type %s to %s", v.T, dst)
+	}
+
+	length := in.load(in.fieldAddr(srcType, v.addr(), 0), types.I32).signed()
+	srcData := in.load(in.fieldAddr(srcType, v.addr(), 1), srcType.Fields[1])
+	var data uint64
+	if length > 0 {
+		data = in.alloca(types.NewArray(uint64(length), dstElem))
+	}
+	for i := int64(0); i < length; i++ {
+		elem := in.load(in.offset(srcData, i).addr(), srcElem)
+		converted, err := in.convert(elem, dstElem)
+		if err != nil {
+			return noValue, err
+		}
+		in.store(data+uint64(i)*in.sizeOf(dstElem), converted)
+	}
+	addr := in.alloca(dstType)
+	in.store(in.fieldAddr(dstType, addr, 0), intValue(types.I32, length))
+	in.store(in.fieldAddr(dstType, addr, 1), pointerValue(dstType.Fields[1], data))
+	return pointerValue(dst, addr), nil
+}
+
+// isNumeric reports whether t is an integer or floating point type.
+func isNumeric(t types.Type) bool {
+	switch t.(type) {
+	case *types.IntType, *types.FloatType:
+		return true
+	}
+	return false
+}
+
+// condAsBool turns a condition into a boolean: integers and pointers are
+// true when not zero, floats when not equal to zero.
+func condAsBool(v Value) bool {
+	switch v.T.(type) {
+	case *types.FloatType:
+		return v.F != 0 || math.IsNaN(v.F)
+	case *types.IntType:
+		return v.unsigned() != 0
+	}
+	return v.I != 0
+}
+
+// zeroValue returns the zero value of t.
+func (in *Interpreter) zeroValue(t types.Type) Value {
+	switch t := t.(type) {
+	case *types.StructType, *types.ArrayType:
+		return Value{T: t, B: make([]byte, in.sizeOf(t))}
+	case *types.FloatType:
+		return Value{T: t, konst: true}
+	case *types.IntType:
+		return Value{T: t, konst: true}
+	}
+	return Value{T: t}
+}
+
+// firstClass reports whether values of t can be returned by a lambda with
+// an expression body.
+func firstClass(t types.Type) bool {
+	switch t.(type) {
+	case *types.IntType, *types.FloatType, *types.PointerType, *types.StructType:
+		return true
+	}
+	return false
+}
+
+func float64bits(f float64) uint64     { return math.Float64bits(f) }
+func float64frombits(b uint64) float64 { return math.Float64frombits(b) }
+
+// getUint and putUint read and write little-endian integers of size bytes.
+func getUint(b []byte, size int) uint64 {
+	var x uint64
+	for i := size - 1; i >= 0; i-- {
+		x = x<<8 | uint64(b[i])
+	}
+	return x
+}
+
+func putUint(b []byte, size int, x uint64) {
+	for i := 0; i < size; i++ {
+		b[i] = byte(x >> (8 * i))
+	}
+}
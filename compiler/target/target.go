@@ -0,0 +1,288 @@
+// Package target describes the machines the compiler can generate code for:
+// the LLVM target triple and data layout, and how Linux system calls are
+// issued on each architecture.
+package target
+
+import (
+	"fmt"
+	"strings"
+)
+
+//go:generate go run ../../tools/mksyscalls -arch amd64 -header /usr/include/x86_64-linux-gnu/asm/unistd_64.h -o syscalls_linux_amd64_gen.go
+//go:generate go run ../../tools/mksyscalls -arch arm64 -header /usr/include/asm-generic/unistd.h -cpp "-D__ARCH_WANT_RENAMEAT -D__ARCH_WANT_NEW_STAT -D__ARCH_WANT_SET_GET_RLIMIT -D__ARCH_WANT_TIME32_SYSCALLS -D__ARCH_WANT_SYS_CLONE3 -D__ARCH_WANT_MEMFD_SECRET" -o syscalls_linux_arm64_gen.go
+//go:generate go run ../../tools/mksyscalls -arch riscv64 -header /usr/include/asm-generic/unistd.h -cpp "-D__ARCH_WANT_NEW_STAT -D__ARCH_WANT_SET_GET_RLIMIT -D__ARCH_WANT_SYS_CLONE3 -D__ARCH_WANT_MEMFD_SECRET" -extra riscv_hwprobe=258,riscv_flush_icache=259 -o syscalls_linux_riscv64_gen.go
+
+// Target is a code generation target.
+type Target struct {
+	Name       string // Architecture name as accepted by Lookup, e.g. "amd64"
+	Triple     string // LLVM target triple
+	DataLayout string // LLVM data layout string
+	Syscall    SyscallABI
+
+	// StartAsm is the body of the naked _start of freestanding programs. It
+	// passes the initial stack pointer (pointing at argc) to StartFunc and
+	// never returns.
+	StartAsm string
+
+	// AsmConstraints lists the machine-specific single-letter operand
+	// constraints accepted in asm blocks, on top of the generic ones.
+	AsmConstraints string
+	// AsmRegisters lists the names of the registers an asm block may
+	// clobber, as LLVM spells them.
+	AsmRegisters []string
+
+	Thread ThreadABI
+}
+
+// IsAsmRegister reports whether name is one of t.AsmRegisters.
+func (t *Target) IsAsmRegister(name string) bool {
+	for _, r := range t.AsmRegisters {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// StartFunc is the function StartAsm calls with the initial stack pointer.
+const StartFunc = "__ylang_start"
+
+// SyscallABI describes how a Linux system call is issued: the instruction,
+// the registers carrying the number, arguments and result, the registers the
+// kernel may clobber, and the architecture's syscall numbers by name.
+type SyscallABI struct {
+	Instruction string
+	Number      string
+	Args        []string
+	Result      string
+	Clobbers    []string
+	Numbers     map[string]int64
+
+	// EpollEventSize is the size of struct epoll_event, which is packed on
+	// amd64 only. Its data field takes the last 8 bytes.
+	EpollEventSize int64
+	// ODirectory is the O_DIRECTORY flag of open, which arm64 moves to the
+	// value O_DIRECT has elsewhere.
+	ODirectory int64
+}
+
+// ThreadABI describes how threads are laid out and started: where the
+// thread-local storage (TLS) block lies relative to the thread pointer, as
+// fixed by the ELF TLS ABI, how the thread pointer is set, and the inline
+// assembly that starts a thread with clone.
+type ThreadABI struct {
+	// TLSAbove is true for variant I of the TLS ABI, where the TLS block
+	// follows the thread control block (TCB) the thread pointer points to,
+	// and false for variant II, where it ends at the thread pointer.
+	TLSAbove bool
+	// TCBSize is the size of the TCB. On variant II the TCB starts with a
+	// pointer to itself.
+	TCBSize int64
+
+	// SetPointerAsm sets the thread pointer to operand $0; empty when the
+	// arch_prctl syscall does it instead.
+	SetPointerAsm string
+
+	// CloneTLSArg is the index of the tls argument of clone; the child tid
+	// pointer is the other of arguments 3 and 4.
+	CloneTLSArg int
+	// StartAsm issues the clone syscall set up in the syscall registers. In
+	// the new thread it calls the function in StartRegs[0] with the
+	// argument in StartRegs[1] and ends the thread with the exit syscall;
+	// the caller continues with the result of clone.
+	StartAsm  string
+	StartRegs [2]string
+}
+
+var (
+	// AMD64 is Linux on x86-64.
+	AMD64 = &Target{
+		Name:       "amd64",
+		Triple:     "x86_64-unknown-linux-gnu",
+		DataLayout: "e-m:e-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128",
+		Syscall: SyscallABI{
+			Instruction: "syscall",
+			Number:      "rax",
+			Args:        []string{"rdi", "rsi", "rdx", "r10", "r8", "r9"},
+			Result:      "rax",
+			Clobbers:    []string{"rcx", "r11"},
+			Numbers:     syscallsLinuxAMD64,
+
+			EpollEventSize: 12,
+			ODirectory:     0x10000,
+		},
+		StartAsm: "xor %ebp, %ebp\n" +
+			"mov %rsp, %rdi\n" +
+			"and $$-16, %rsp\n" +
+			"call " + StartFunc + "\n" +
+			"hlt",
+		// a-d, S, D name single registers; x and y are SSE/MMX registers.
+		AsmConstraints: "abcdSDqQRAftuxyl",
+		AsmRegisters: registers(
+			strings.Fields("rax rbx rcx rdx rsi rdi rbp rsp eax ebx ecx edx esi edi ebp esp"),
+			strings.Fields("ax bx cx dx si di bp sp al bl cl dl ah bh ch dh sil dil bpl spl"),
+			numbered(8, 15, "r%d", "r%dd", "r%dw", "r%db"),
+			numbered(0, 31, "xmm%d", "ymm%d", "zmm%d"),
+			numbered(0, 7, "mm%d", "st(%d)", "k%d"),
+			strings.Fields("st flags eflags dirflag fpsr fpcr mxcsr"),
+		),
+		Thread: ThreadABI{
+			TCBSize:     16,
+			CloneTLSArg: 4,
+			StartAsm: "syscall\n" +
+				"test %rax, %rax\n" +
+				"jnz 1f\n" +
+				"xor %ebp, %ebp\n" +
+				"mov %r12, %rdi\n" +
+				"call *%r9\n" +
+				"mov $$60, %eax\n" +
+				"xor %edi, %edi\n" +
+				"syscall\n" +
+				"hlt\n" +
+				"1:",
+			StartRegs: [2]string{"r9", "r12"},
+		},
+	}
+
+	// ARM64 is Linux on AArch64.
+	ARM64 = &Target{
+		Name:       "arm64",
+		Triple:     "aarch64-unknown-linux-gnu",
+		DataLayout: "e-m:e-i8:8:32-i16:16:32-i64:64-i128:128-n32:64-S128",
+		Syscall: SyscallABI{
+			Instruction: "svc #0",
+			Number:      "x8",
+			Args:        []string{"x0", "x1", "x2", "x3", "x4", "x5"},
+			Result:      "x0",
+			Numbers:     syscallsLinuxARM64,
+
+			EpollEventSize: 16,
+			ODirectory:     0x4000,
+		},
+		StartAsm: "mov x29, #0\n" +
+			"mov x30, #0\n" +
+			"mov x0, sp\n" +
+			"bl " + StartFunc + "\n" +
+			"brk #0",
+		// w is a SIMD/FP register; the rest are immediate ranges and Q a
+		// memory operand addressed by a single base register.
+		AsmConstraints: "wxyQIJKLMNSYZ",
+		AsmRegisters: registers(
+			numbered(0, 30, "x%d", "w%d"),
+			numbered(0, 31, "v%d", "q%d", "d%d", "s%d", "h%d", "b%d", "z%d"),
+			numbered(0, 15, "p%d"),
+			strings.Fields("sp wsp xzr wzr fp lr nzcv fpsr fpcr ffr"),
+		),
+		Thread: ThreadABI{
+			TLSAbove:      true,
+			TCBSize:       16,
+			SetPointerAsm: "msr tpidr_el0, $0",
+			CloneTLSArg:   3,
+			StartAsm: "svc #0\n" +
+				"cbnz x0, 1f\n" +
+				"mov x29, #0\n" +
+				"mov x30, #0\n" +
+				"mov x0, x10\n" +
+				"blr x9\n" +
+				"mov x0, #0\n" +
+				"mov x8, #93\n" +
+				"svc #0\n" +
+				"brk #0\n" +
+				"1:",
+			StartRegs: [2]string{"x9", "x10"},
+		},
+	}
+
+	// RISCV64 is Linux on 64-bit RISC-V.
+	RISCV64 = &Target{
+		Name:       "riscv64",
+		Triple:     "riscv64-unknown-linux-gnu",
+		DataLayout: "e-m:e-p:64:64-i64:64-i128:128-n64-S128",
+		Syscall: SyscallABI{
+			Instruction: "ecall",
+			Number:      "x17",
+			Args:        []string{"x10", "x11", "x12", "x13", "x14", "x15"},
+			Result:      "x10",
+			Numbers:     syscallsLinuxRISCV64,
+
+			EpollEventSize: 16,
+			ODirectory:     0x10000,
+		},
+		// The global pointer must be set up before any gp-relative access the
+		// linker may relax loads and stores into.
+		StartAsm: ".option push\n" +
+			".option norelax\n" +
+			"lla gp, __global_pointer$$\n" +
+			".option pop\n" +
+			"li ra, 0\n" +
+			"mv a0, sp\n" +
+			"call " + StartFunc + "\n" +
+			"ebreak",
+		// f is a floating-point register, A an address held in a register and
+		// I, J, K the 12-bit, zero and 5-bit immediates.
+		AsmConstraints: "fAIJKS",
+		AsmRegisters: registers(
+			numbered(0, 31, "x%d", "f%d", "v%d"),
+			numbered(0, 6, "t%d"),
+			numbered(0, 11, "s%d", "ft%d", "fs%d"),
+			numbered(0, 7, "a%d", "fa%d"),
+			strings.Fields("zero ra sp gp tp fp fflags frm fcsr vl vtype vxsat vxrm"),
+		),
+		Thread: ThreadABI{
+			TLSAbove:      true,
+			SetPointerAsm: "mv tp, $0",
+			CloneTLSArg:   3,
+			StartAsm: "ecall\n" +
+				"bnez a0, 1f\n" +
+				"li ra, 0\n" +
+				"li s0, 0\n" +
+				"mv a0, t1\n" +
+				"jalr t0\n" +
+				"li a0, 0\n" +
+				"li a7, 93\n" +
+				"ecall\n" +
+				"ebreak\n" +
+				"1:",
+			StartRegs: [2]string{"x5", "x6"},
+		},
+	}
+
+	// Default is the target used when none is given.
+	Default = AMD64
+)
+
+// numbered returns the register names formats give for the numbers from
+// first to last.
+func numbered(first, last int, formats ...string) []string {
+	var names []string
+	for _, format := range formats {
+		for n := first; n <= last; n++ {
+			names = append(names, fmt.Sprintf(format, n))
+		}
+	}
+	return names
+}
+
+func registers(lists ...[]string) []string {
+	var names []string
+	for _, list := range lists {
+		names = append(names, list...)
+	}
+	return names
+}
+
+// Lookup returns the target for an architecture name. Both Go-style names
+// (amd64, arm64) and the first element of a triple (x86_64, aarch64) are
+// accepted, so a full triple such as aarch64-unknown-linux-gnu works too.
+func Lookup(name string) (*Target, error) {
+	arch, _, _ := strings.Cut(strings.ToLower(name), "-")
+	switch arch {
+	case "amd64", "x86_64", "x86-64":
+		return AMD64, nil
+	case "arm64", "aarch64":
+		return ARM64, nil
+	case "riscv64":
+		return RISCV64, nil
+	}
+	return nil, fmt.Errorf("unsupported target '%s' (want amd64, arm64 or riscv64)", name)
+}
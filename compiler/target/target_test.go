@@ -0,0 +1,87 @@
+package target
+
+import "testing"
+
+// TestSyscallTables spot-checks the generated tables against numbers from
+// the kernel's syscall tables.
+func TestSyscallTables(t *testing.T) {
+	tests := []struct {
+		target   *Target
+		expected map[string]int64
+	}{
+		{AMD64, map[string]int64{
+			"read": 0, "write": 1, "close": 3, "mmap": 9, "munmap": 11, "getdents64": 217,
+			"clock_gettime": 228, "exit_group": 231, "openat": 257, "getrandom": 318,
+		}},
+		{ARM64, map[string]int64{
+			"getdents64": 61, "read": 63, "write": 64, "openat": 56, "close": 57, "renameat": 38,
+			"exit_group": 94, "clock_gettime": 113, "munmap": 215, "mmap": 222, "getrandom": 278,
+		}},
+		{RISCV64, map[string]int64{
+			"getdents64": 61, "read": 63, "write": 64, "openat": 56, "close": 57, "exit_group": 94,
+			"clock_gettime": 113, "munmap": 215, "mmap": 222, "riscv_flush_icache": 259, "getrandom": 278,
+		}},
+	}
+	for _, tt := range tests {
+		for name, want := range tt.expected {
+			if got, ok := tt.target.Syscall.Numbers[name]; !ok || got != want {
+				t.Errorf("%s: Numbers[%q] = %d (present %t), want %d", tt.target.Name, name, got, ok, want)
+			}
+		}
+	}
+
+	// The generic table has no legacy calls such as open(2).
+	if _, ok := ARM64.Syscall.Numbers["open"]; ok {
+		t.Errorf("arm64: unexpected syscall 'open'")
+	}
+	if _, ok := RISCV64.Syscall.Numbers["renameat"]; ok {
+		t.Errorf("riscv64: unexpected syscall 'renameat'")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected *Target
+	}{
+		{"amd64", AMD64},
+		{"x86_64", AMD64},
+		{"x86_64-unknown-linux-gnu", AMD64},
+		{"arm64", ARM64},
+		{"aarch64-linux-gnu", ARM64},
+		{"riscv64", RISCV64},
+		{"RISCV64", RISCV64},
+	}
+	for _, tt := range tests {
+		got, err := Lookup(tt.name)
+		if err != nil {
+			t.Errorf("Lookup(%q) failed: %v", tt.name, err)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("Lookup(%q) = %s, want %s", tt.name, got.Name, tt.expected.Name)
+		}
+	}
+
+	if _, err := Lookup("mips"); err == nil {
+		t.Errorf("Lookup(\"mips\") succeeded, want an error")
+	}
+}
+
+// TestAsmRegisters checks that the registers the targets' own asm uses may be
+// clobbered.
+func TestAsmRegisters(t *testing.T) {
+	for _, tt := range []*Target{AMD64, ARM64, RISCV64} {
+		regs := append([]string{tt.Syscall.Number, tt.Syscall.Result}, tt.Syscall.Args...)
+		regs = append(regs, tt.Syscall.Clobbers...)
+		regs = append(regs, tt.Thread.StartRegs[:]...)
+		for _, r := range regs {
+			if !tt.IsAsmRegister(r) {
+				t.Errorf("%s: %q is not an asm register", tt.Name, r)
+			}
+		}
+	}
+	if AMD64.IsAsmRegister("x0") || ARM64.IsAsmRegister("rax") || RISCV64.IsAsmRegister("w0") {
+		t.Errorf("register of another target accepted")
+	}
+}
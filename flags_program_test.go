@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// flagsProgram is a sample command-line tool built on stdlib/flags. It
+// prints its flags and positional arguments.
+const flagsProgram = `
+import "stdlib/fmt";
+import "stdlib/os";
+import "stdlib/flags";
+
+main() -> {
+	let fs = flags.newFlagSet("greet", "[options] name...");
+	let loud = flags.boolFlag(fs, "loud", "l", "shout the greeting");
+	let verbose = flags.boolFlag(fs, "verbose", "v", "explain what happens");
+	let count = flags.intFlag(fs, "count", "n", 1, "times to greet");
+	let greeting = flags.stringFlag(fs, "greeting", "g", "hello", "the greeting");
+	let dry = flags.boolFlag(fs, "dry-run", "", "print nothing");
+	flags.parseOrExit(fs, os.args());
+	printf("loud=%d verbose=%d dry=%d\n", *loud, *verbose, *dry);
+	printf("count=%d greeting=%s set=%d\n", *count, *greeting, flags.isSet(fs, "count"));
+	let i: i64 = 0;
+	while (i < flags.narg(fs)) {
+		printf("arg %d: %s\n", i, flags.arg(fs, i));
+		i = i + 1;
+	}
+	return 0;
+}`
+
+// TestFlagsProgram runs a tool built on stdlib/flags with various argument
+// vectors and checks what it parsed, its errors, its help text and its exit
+// status.
+func TestFlagsProgram(t *testing.T) {
+	exeFile := buildFreestanding(t, flagsProgram)
+
+	tests := []struct {
+		name           string
+		args           []string
+		expectedStdout string
+		expectedStderr string
+		expectedCode   int
+	}{
+		{
+			name:           "Defaults",
+			expectedStdout: "loud=false verbose=false dry=false\ncount=1 greeting=hello set=false\n",
+		},
+		{
+			name: "Long flags and positional arguments",
+			args: []string{"--loud", "--count=3", "bob", "--greeting", "hi", "alice"},
+			expectedStdout: "loud=true verbose=false dry=false\ncount=3 greeting=hi set=true\n" +
+				"arg 0: bob\narg 1: alice\n",
+		},
+		{
+			name: "Short flags, bundles and attached values",
+			args: []string{"-lv", "-n5", "-g", "hey", "-", "x"},
+			expectedStdout: "loud=true verbose=true dry=false\ncount=5 greeting=hey set=true\n" +
+				"arg 0: -\narg 1: x\n",
+		},
+		{
+			name: "Double dash ends the flags",
+			args: []string{"-n", "-2", "--", "--loud", "-v"},
+			expectedStdout: "loud=false verbose=false dry=false\ncount=-2 greeting=hello set=true\n" +
+				"arg 0: --loud\narg 1: -v\n",
+		},
+		{
+			name:           "Boolean with a value",
+			args:           []string{"--dry-run=true", "--loud=false", "--count=1"},
+			expectedStdout: "loud=false verbose=false dry=true\ncount=1 greeting=hello set=true\n",
+		},
+		{
+			name:           "Unknown long flag",
+			args:           []string{"--color"},
+			expectedStderr: "greet: unknown flag --color\nRun 'greet --help' for usage.\n",
+			expectedCode:   2,
+		},
+		{
+			name:           "Unknown short flag in a bundle",
+			args:           []string{"-vx"},
+			expectedStderr: "greet: unknown flag -x\nRun 'greet --help' for usage.\n",
+			expectedCode:   2,
+		},
+		{
+			name:           "Invalid integer",
+			args:           []string{"--count", "9223372036854775808"},
+			expectedStderr: "greet: invalid value \"9223372036854775808\" for --count\nRun 'greet --help' for usage.\n",
+			expectedCode:   2,
+		},
+		{
+			name:           "Invalid boolean",
+			args:           []string{"--loud=maybe"},
+			expectedStderr: "greet: invalid value \"maybe\" for --loud\nRun 'greet --help' for usage.\n",
+			expectedCode:   2,
+		},
+		{
+			name:           "Missing value",
+			args:           []string{"-l", "-n"},
+			expectedStderr: "greet: flag -n needs a value\nRun 'greet --help' for usage.\n",
+			expectedCode:   2,
+		},
+		{
+			name: "Help",
+			args: []string{"-v", "--help", "--unknown"},
+			expectedStdout: "Usage: greet [options] name...\n\nOptions:\n" +
+				"  -l, --loud               shout the greeting\n" +
+				"  -v, --verbose            explain what happens\n" +
+				"  -n, --count <int>        times to greet (default 1)\n" +
+				"  -g, --greeting <string>  the greeting (default \"hello\")\n" +
+				"      --dry-run            print nothing\n" +
+				"  -h, --help               show this help\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := exec.Command(exeFile, tt.args...)
+			var stdout, stderr strings.Builder
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			err := cmd.Run()
+
+			code := 0
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatalf("Program execution failed: %v", err)
+			}
+			if code != tt.expectedCode {
+				t.Errorf("exit code %d, want %d", code, tt.expectedCode)
+			}
+			if stdout.String() != tt.expectedStdout {
+				t.Errorf("stdout mismatch\nGot:  %q\nWant: %q", stdout.String(), tt.expectedStdout)
+			}
+			if stderr.String() != tt.expectedStderr {
+				t.Errorf("stderr mismatch\nGot:  %q\nWant: %q", stderr.String(), tt.expectedStderr)
+			}
+		})
+	}
+}
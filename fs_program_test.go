@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	c "compiler/compiler"
+	l "compiler/lexer"
+	p "compiler/parser"
+)
+
+// buildFreestanding compiles a y-lang program without a C runtime and returns
+// the path of the executable, which lives in a temporary directory.
+func buildFreestanding(t *testing.T, input string) string {
+	t.Helper()
+	lexer, err := l.NewLexerFromString(input)
+	if err != nil {
+		t.Fatalf("Failed to create lexer: %v", err)
+	}
+	parser := p.NewParser(lexer)
+	program := parser.ParseProgram()
+	if len(parser.Errors()) != 0 {
+		t.Fatalf("Parser errors: %v", parser.Errors())
+	}
+
+	compilerInstance := c.NewCompiler(c.LLVM, nil)
+	compilerInstance.Freestanding = true
+	result := compilerInstance.Compile(program)
+	if len(result.Errors) != 0 {
+		t.Fatalf("Compiler errors: %v", result.Errors)
+	}
+
+	tmpDir := t.TempDir()
+	irFile := filepath.Join(tmpDir, "program.ll")
+	if err := os.WriteFile(irFile, []byte(result.Output), 0o644); err != nil {
+		t.Fatalf("Failed to write IR file: %v", err)
+	}
+	exeFile := filepath.Join(tmpDir, "program")
+	clangCmd := exec.Command("clang", irFile, "-o", exeFile, "-nostdlib", "-static")
+	if out, err := clangCmd.CombinedOutput(); err != nil {
+		t.Fatalf("clang compilation failed: %v\nOutput:\n%s", err, string(out))
+	}
+	return exeFile
+}
+
+// runLines runs an executable and returns its non-empty output lines.
+func runLines(t *testing.T, exeFile string) []string {
+	t.Helper()
+	output, err := exec.Command(exeFile).Output()
+	if err != nil {
+		t.Fatalf("Program execution failed: %v\nOutput:\n%s", err, output)
+	}
+	var lines []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// TestFsProgram exercises stdlib/fs inside a temporary directory: creating,
+// reading, renaming and removing files and directories, stat, walk and the
+// errors reported for failing calls.
+func TestFsProgram(t *testing.T) {
+	root := t.TempDir()
+	input := fmt.Sprintf(`
+	import "stdlib/fs";
+	import "stdlib/mem";
+	import "stdlib/path";
+	import "stdlib/fmt";
+	import "stdlib/core/print";
+
+	function visit(p: string, kind: i64): i64 -> {
+		printf("walk %%s\n", p);
+		return 0;
+	}
+
+	function stopAtFile(p: string, kind: i64): i64 -> {
+		if (kind == fs.DT_REG) {
+			return 7;
+		}
+		return 0;
+	}
+
+	main() -> {
+		let root = %q;
+		let sub = path.join(root, "sub");
+		let file = path.join(sub, "a.txt");
+		print(fs.mkdir(sub));
+		print(fs.isExist(fs.mkdir(sub)));
+		print(fs.writeFile(file, "hello", 5));
+
+		let text = "";
+		print(fs.readFile(file, &text));
+		print(text);
+
+		let st = alloc(fs.STAT_SIZE) as *fs.Stat;
+		print(fs.stat(file, st));
+		print(st.size as i64);
+		print(fs.isRegular(st));
+		fs.stat(sub, st);
+		print(fs.isDir(st));
+
+		let moved = path.join(root, "b.txt");
+		print(fs.rename(file, moved));
+		print(fs.walk(root, visit));
+		print(fs.walk(root, stopAtFile));
+
+		print(fs.isExist(fs.rmdir(root)));
+		let missing = fs.unlink(file);
+		print(fs.isNotExist(missing));
+		print(fs.errorString(missing));
+		print(fs.lastError().op);
+		print(fs.lastError().path == file);
+		print(fs.opendir(file) == 0);
+
+		print(fs.unlink(moved));
+		print(fs.rmdir(sub));
+		return 0;
+	}`, root)
+
+	lines := runLines(t, buildFreestanding(t, input))
+
+	var walked, rest []string
+	for _, line := range lines {
+		if strings.HasPrefix(line, "walk ") {
+			walked = append(walked, strings.TrimPrefix(line, "walk "))
+		} else {
+			rest = append(rest, line)
+		}
+	}
+	sort.Strings(walked)
+	expectedWalk := []string{filepath.Join(root, "b.txt"), filepath.Join(root, "sub")}
+	if strings.Join(walked, "\n") != strings.Join(expectedWalk, "\n") {
+		t.Errorf("walk visited %v, want %v", walked, expectedWalk)
+	}
+
+	expected := []string{
+		"0", "true", // mkdir, then mkdir of an existing directory
+		"0",          // writeFile
+		"5", "hello", // readFile
+		"0", "5", "true", // stat of the file
+		"true", // stat of the directory
+		"0",    // rename
+		"0",    // walk
+		"7",    // walk stopped by its callback
+		"true", // rmdir of a non-empty directory
+		"true", "no such file or directory", "unlink", "true",
+		"true",   // opendir of a missing directory
+		"0", "0", // cleanup
+	}
+	if strings.Join(rest, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("program output mismatch\nGot:\n%s\nWant:\n%s", strings.Join(rest, "\n"), strings.Join(expected, "\n"))
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatalf("Failed to read temp dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("temp dir not cleaned up by the program: %v", entries)
+	}
+}
+
+// TestPathProgram checks stdlib/path against the expected results of join,
+// base, dir and ext.
+func TestPathProgram(t *testing.T) {
+	tests := []struct {
+		call     string
+		expected string
+	}{
+		{`path.join("a", "b")`, "a/b"},
+		{`path.join("a/", "/b")`, "a/b"},
+		{`path.join("", "b")`, "b"},
+		{`path.join("/", "etc")`, "/etc"},
+		{`path.base("a/b/")`, "b"},
+		{`path.base("/")`, "/"},
+		{`path.base("")`, "."},
+		{`path.base("file.txt")`, "file.txt"},
+		{`path.dir("a/b/c")`, "a/b"},
+		{`path.dir("/a")`, "/"},
+		{`path.dir("a")`, "."},
+		{`path.dir("a//b")`, "a"},
+		{`path.ext("a/b.tar.gz")`, ".gz"},
+		{`path.ext("a.d/b")`, ""},
+	}
+
+	var body strings.Builder
+	for _, tt := range tests {
+		fmt.Fprintf(&body, "\t\tprintf(\"[%%s]\\n\", %s);\n", tt.call)
+	}
+	input := `
+	import "stdlib/path";
+	import "stdlib/fmt";
+
+	main() -> {
+` + body.String() + `
+		return 0;
+	}`
+
+	lines := runLines(t, buildFreestanding(t, input))
+	if len(lines) != len(tests) {
+		t.Fatalf("expected %d lines, got %d: %v", len(tests), len(lines), lines)
+	}
+	for i, tt := range tests {
+		if lines[i] != "["+tt.expected+"]" {
+			t.Errorf("%s = %q, want %q", tt.call, lines[i], tt.expected)
+		}
+	}
+}
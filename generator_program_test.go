@@ -0,0 +1,134 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestGeneratorProgram runs function* generators through for-in loops and
+// the lazy map, filter, take and forEach adapters.
+func TestGeneratorProgram(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name: "generators, for-in and lazy arrays",
+			input: `
+			import "stdlib/fmt";
+
+			function* count(from: i64, to: i64): i64 -> {
+				let i = from;
+				while (i < to) {
+					yield i;
+					i = i + 1;
+				}
+			}
+
+			function* evens(n: i64): i64 -> {
+				for x in count(0, n) {
+					if (x % 2 == 0) {
+						yield x;
+					}
+				}
+				yield 100;
+			}
+
+			function* words(): string -> {
+				yield "a";
+				yield "bb";
+				return;
+				yield "never";
+			}
+
+			main() -> {
+				for x in count(1, 4) {
+					printf("%d ", x);
+				}
+				printf("\n");
+				for (e in evens(7)) {
+					printf("%d ", e);
+				}
+				printf("\n");
+				for w in words() { printf("%s|", w); }
+				printf("\n");
+				let values = [1, 2, 3, 4, 5, 6];
+				for v in values.lazy().map((x: int) -> x * 10).filter((x: int) -> x > 20).take(3) {
+					printf("%d ", v);
+				}
+				printf("\n");
+				count(5, 8).map((x: i64) -> x * x).forEach((x: i64) -> printf("%d,", x));
+				printf("\n");
+				let g: seq<i64> = count(0, 3);
+				for a in g { printf("%d", a); }
+				for a in g { printf("%d", a); }
+				printf("\n");
+				for v in values { printf("%d", v); }
+				printf("\n");
+				return 0;
+			}`,
+			expected: []string{"1 2 3 ", "0 2 4 6 100 ", "a|bb|", "30 40 50 ", "25,36,49,", "012", "123456"},
+		},
+		{
+			name: "generator state, variadic packs and seq parameters",
+			input: `
+			import "stdlib/fmt";
+
+			function* fib(n: i64): i64 -> {
+				let a: i64 = 0;
+				let b: i64 = 1;
+				let i = 0;
+				while (i < n) {
+					yield a;
+					let t = a + b;
+					a = b;
+					b = t;
+					i = i + 1;
+				}
+			}
+
+			function* tokens(parts: ...string): string -> {
+				for p in parts {
+					yield p;
+				}
+			}
+
+			function* window(): i64 -> {
+				let arr = [7, 8, 9];
+				yield 1;
+				for v in arr {
+					yield v;
+				}
+			}
+
+			function total(s: seq<i64>): i64 -> {
+				let t: i64 = 0;
+				for x in s { t = t + x; }
+				return t;
+			}
+
+			main() -> {
+				for f in fib(10) { printf("%d ", f); }
+				printf("\n%d\n", total(fib(10)));
+				for t in tokens("x", "yy", "zzz") { printf("[%s]", t); }
+				printf("\n");
+				for w in window() { printf("%d ", w); }
+				printf("\n");
+				fib(5).filter((x: i64) -> x > 1).forEach((x: i64) -> printf("<%d>", x));
+				printf("\n");
+				return 0;
+			}`,
+			expected: []string{"0 1 1 2 3 5 8 13 21 34 ", "88", "[x][yy][zzz]", "1 7 8 9 ", "<2><3>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := runLines(t, buildFreestanding(t, tt.input))
+			if strings.Join(lines, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("program output mismatch\nGot:\n%s\nWant:\n%s", strings.Join(lines, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+}
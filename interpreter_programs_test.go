@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	c "compiler/compiler"
+	l "compiler/lexer"
+	p "compiler/parser"
+)
+
+// interpretProgram runs src with the interpreter backend, freestanding like
+// runProgram, and describes what it did the same way.
+func interpretProgram(t *testing.T, src string) expectation {
+	t.Helper()
+	lexer, err := l.NewLexerFromString(src)
+	if err != nil {
+		return expectation{err: firstLine(err.Error())}
+	}
+	parser := p.NewParser(lexer)
+	program := parser.ParseProgram()
+	if errs := parser.Errors(); len(errs) != 0 {
+		return expectation{err: firstLine(errs[0])}
+	}
+	var stdout bytes.Buffer
+	compilerInstance := c.NewCompiler(c.Interpreter, nil)
+	compilerInstance.Freestanding = true
+	compilerInstance.Stdin = strings.NewReader("")
+	compilerInstance.Stdout = &stdout
+	compilerInstance.Args = []string{"program"}
+	result := compilerInstance.Compile(program)
+	if len(result.Errors) != 0 {
+		return expectation{err: firstLine(result.Errors[0])}
+	}
+
+	got := expectation{exit: result.ExitStatus}
+	if text := strings.TrimSuffix(stdout.String(), "\n"); text != "" {
+		for _, line := range strings.Split(text, "\n") {
+			got.stdout = append(got.stdout, strings.TrimRight(line, " \t"))
+		}
+	}
+	return got
+}
+
+// TestProgramsInterpreted runs every program in testdata/programs with the
+// interpreter and checks it behaves as the compiled program is expected to:
+// same output, same exit status, same compile error.
+func TestProgramsInterpreted(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "programs", "*.y"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".y"), func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := parseExpectation(string(src))
+			if err != nil {
+				t.Fatalf("%s: %v", file, err)
+			}
+			got := interpretProgram(t, string(src))
+
+			if want.err != "" {
+				if !strings.Contains(got.err, want.err) {
+					t.Fatalf("expected a compile error containing %q, got %q", want.err, got.err)
+				}
+				return
+			}
+			if got.err != "" {
+				t.Fatalf("error: %s", got.err)
+			}
+			if strings.Join(got.stdout, "\n") != strings.Join(want.stdout, "\n") {
+				t.Errorf("stdout differs.\nexpected:\n%s\ngot:\n%s", strings.Join(want.stdout, "\n"), strings.Join(got.stdout, "\n"))
+			}
+			if got.exit != want.exit {
+				t.Errorf("expected exit status %d, got %d", want.exit, got.exit)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestIoProgram checks stdlib/io in a freestanding program: lines read from
+// stdin, buffered output to stdout and stderr, and the flush of both when the
+// program returns from main or calls os.exit.
+func TestIoProgram(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		stdin          string
+		expectedStdout string
+		expectedStderr string
+		expectedCode   int
+	}{
+		{
+			name: "Read lines",
+			input: `
+			import "stdlib/io";
+			import "stdlib/fmt";
+
+			main() -> {
+				let line = "";
+				let n = io.readLine(io.stdin, &line);
+				while (n > 0) {
+					printf("[%s] %d\n", line, n);
+					n = io.readLine(io.stdin, &line);
+				}
+				return 0;
+			}`,
+			stdin:          "ab\n\nlast",
+			expectedStdout: "[ab] 3\n[] 1\n[last] 4\n",
+		},
+		{
+			name: "Read all",
+			input: `
+			import "stdlib/io";
+			import "stdlib/fmt";
+
+			main() -> {
+				let first = io.readByte(io.stdin);
+				let rest = "";
+				let n = io.readAll(io.stdin, &rest);
+				printf("%d %d %s", first, n, rest);
+				return 0;
+			}`,
+			stdin:          "xhello\nworld\n",
+			expectedStdout: "120 12 hello\nworld\n",
+		},
+		{
+			name: "Output larger than the buffer",
+			input: `
+			import "stdlib/io";
+
+			main() -> {
+				let i = 0;
+				while (i < 5000) {
+					io.writeByte(io.stdout, 97);
+					i = i + 1;
+				}
+				io.writeString(io.stderr, "done\n");
+				return 0;
+			}`,
+			expectedStdout: strings.Repeat("a", 5000),
+			expectedStderr: "done\n",
+		},
+		{
+			name: "Exit flushes",
+			input: `
+			import "stdlib/os";
+			import "stdlib/core/print";
+
+			main() -> {
+				print("before exit");
+				os.exit(3);
+				print("after exit");
+				return 0;
+			}`,
+			expectedStdout: "before exit\n",
+			expectedCode:   3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exeFile := buildFreestanding(t, tt.input)
+			cmd := exec.Command(exeFile)
+			cmd.Stdin = strings.NewReader(tt.stdin)
+			var stdout, stderr strings.Builder
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			err := cmd.Run()
+
+			code := 0
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatalf("Program execution failed: %v", err)
+			}
+			if code != tt.expectedCode {
+				t.Errorf("exit code %d, want %d", code, tt.expectedCode)
+			}
+			if stdout.String() != tt.expectedStdout {
+				t.Errorf("stdout mismatch\nGot:  %q\nWant: %q", stdout.String(), tt.expectedStdout)
+			}
+			if stderr.String() != tt.expectedStderr {
+				t.Errorf("stderr mismatch\nGot:  %q\nWant: %q", stderr.String(), tt.expectedStderr)
+			}
+		})
+	}
+}
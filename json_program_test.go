@@ -0,0 +1,163 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestJsonProgram covers parsing, building and writing JSON with
+// stdlib/json, its error positions, and the toJson and fromJson functions
+// derived for data declarations.
+func TestJsonProgram(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name: "json",
+			input: `
+			import "stdlib/fmt";
+			import "stdlib/json";
+
+			main() -> {
+				let v = json.parse("{\"a\": [1, 2.5, \"x\\u00e9\\ud83d\\ude00\", true, null], \"b\": {}, \"n\": -9223372036854775807}");
+				printf("%s\n", json.stringify(v));
+				printf("%s\n", json.stringify(v, 2));
+				let a = json.get(v, "a");
+				printf("%d %d %.1f %s %d %d\n", json.length(a), json.asInt(json.at(a, 0)), json.asNumber(json.at(a, 1)), json.kindName(json.kind(json.at(a, 4))), json.has(v, "b"), json.has(v, "c"));
+				printf("%s %s\n", json.keyAt(v, 2), json.stringify(json.valueAt(v, 1)));
+				json.freeValue(v);
+
+				let o = json.newObject();
+				json.set(o, "pi", json.newNumber(0.1 + 0.2));
+				json.set(o, "s", json.newString("tab\tquote\""));
+				let arr = json.newArray();
+				json.add(arr, json.newInt(1));
+				json.add(arr, json.newBool(false));
+				json.set(o, "arr", arr);
+				json.set(o, "pi", json.newNumber(1048576.25));
+				printf("%s\n", json.stringify(o));
+
+				printf("%d %s\n", (json.parse("[1, 2,]") as i64) == 0, json.lastError());
+				printf("%d %s\n", (json.parse("{\n  \"a\": tru }") as i64) == 0, json.lastError());
+				printf("%d %d %d\n", json.errorLine(), json.errorColumn(), json.errorCount() > 0);
+				printf("%d %s\n", (json.parse("\"abc") as i64) == 0, json.lastError());
+				printf("%d %s\n", (json.parse("1 2") as i64) == 0, json.lastError());
+				return 0;
+			}`,
+			expected: []string{
+				`{"a":[1,2.5,"xé😀",true,null],"b":{},"n":-9223372036854775807}`,
+				`{`,
+				`  "a": [`,
+				`    1,`,
+				`    2.5,`,
+				`    "xé😀",`,
+				`    true,`,
+				`    null`,
+				`  ],`,
+				`  "b": {},`,
+				`  "n": -9223372036854775807`,
+				`}`,
+				`5 1 2.5 null true false`,
+				`n {}`,
+				`{"pi":1048576.25,"s":"tab\tquote\"","arr":[1,false]}`,
+				`true line 1, column 7: expected a value`,
+				`true line 2, column 8: invalid literal, expected 'true'`,
+				`2 8 true`,
+				`true line 1, column 1: unterminated string`,
+				`true line 1, column 3: unexpected text after the value`,
+			},
+		},
+		{
+			name: "data",
+			input: `
+			import "stdlib/fmt";
+			import "stdlib/json";
+			import "stdlib/mem";
+
+			data Inner { let z: i32 }
+			data Point { let x: i64, let name: string, let tags, let ok: bool, let w: f64, let inner: *Inner }
+
+			main() -> {
+				let p = Point.fromJson("{\"x\": 7, \"name\": \"hi\", \"tags\": [1,2], \"ok\": true, \"w\": 1.5, \"inner\": {\"z\": 3}}");
+				printf("%d %s %d %.1f %d\n", p.x, p.name, p.ok, p.w, p.inner.z);
+				printf("%s\n", json.stringify(p.toJson()));
+				let q = Point.fromJson("{\"x\": \"no\"}");
+				printf("%d %s\n", (q as i64) == 0, json.lastError());
+				let r = Point.fromJson("{\"x\": 1,");
+				printf("%d %s\n", (r as i64) == 0, json.lastError());
+				let e = Point.new();
+				printf("%s\n", json.stringify(e.toJson()));
+				return 0;
+			}`,
+			expected: []string{
+				`7 hi true 1.5 3`,
+				`{"x":7,"name":"hi","tags":[1,2],"ok":true,"w":1.5,"inner":{"z":3}}`,
+				`true line 1, column 7: Point.x expects an integer, got a string`,
+				`true line 1, column 9: expected a string as object key but found the end of the input`,
+				`{"x":0,"name":null,"tags":null,"ok":false,"w":0,"inner":null}`,
+			},
+		},
+		{
+			name: "numbers at the limits",
+			input: `
+			import "stdlib/fmt";
+			import "stdlib/json";
+			import "stdlib/math";
+
+			// roundTrip writes x and reports whether reading it back gives
+			// the same f64.
+			function roundTrip(x: f64) -> {
+				let s = json.stringify(json.newNumber(x));
+				let v = json.parse(s);
+				printf("%s %d\n", s, (v as i64) != 0 && math.bits(json.asNumber(v)) == math.bits(x));
+			}
+
+			function echo(text: string) -> {
+				let v = json.parse(text);
+				if ((v as i64) == 0) {
+					printf("%s\n", json.lastError());
+					return;
+				}
+				printf("%s\n", json.stringify(v));
+			}
+
+			main() -> {
+				roundTrip(math.fromBits(0x0010000000000000));
+				roundTrip(math.fromBits(0x000fffffffffffff));
+				roundTrip(math.fromBits(1));
+				roundTrip(math.fromBits(0x7fefffffffffffff));
+				roundTrip(math.fromBits(0x3fd3333333333334));
+				echo("[5e-324, 4.9e-324, 3e-324, 2e-324, 2.2250738585072014e-308]");
+				echo("[1.7976931348623157e308, 1.7976931348623158e308]");
+				echo("1.797693134862316e308");
+				echo("[-9223372036854775808, 9223372036854775807, 9223372036854775808, -9223372036854775809]");
+				let v = json.parse("-9223372036854775808");
+				printf("%d %s\n", json.asInt(v) == -9223372036854775807 - 1, json.stringify(v));
+				return 0;
+			}`,
+			expected: []string{
+				`2.2250738585072014e-308 true`,
+				`2.225073858507201e-308 true`,
+				`5e-324 true`,
+				`1.7976931348623157e+308 true`,
+				`0.30000000000000004 true`,
+				`[5e-324,5e-324,5e-324,0,2.2250738585072014e-308]`,
+				`[1.7976931348623157e+308,1.7976931348623157e+308]`,
+				`line 1, column 1: number out of range`,
+				`[-9223372036854775808,9223372036854775807,9223372036854776000,-9223372036854776000]`,
+				`true -9223372036854775808`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := runLines(t, buildFreestanding(t, tt.input))
+			if strings.Join(lines, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("program output mismatch\nGot:\n%s\nWant:\n%s", strings.Join(lines, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+}
@@ -288,7 +288,10 @@ func (l *Lexer) NextToken() (LangToken, error) {
 			tok = newTokenSingle(TokenTypeDivide, l.ch)
 		}
 	case '<':
-		if l.peekChar() == '=' { // <= (Less Than Equal)
+		if l.peekChar() == '<' { // << (Shift Left)
+			l.readChar() // consume second '<'
+			tok = newTokenLiteral(TokenTypeShiftLeft, "<<")
+		} else if l.peekChar() == '=' { // <= (Less Than Equal)
 			l.readChar() // consume '='
 			tok = newTokenLiteral(TokenTypeLessThanEqual, "<=")
 			advanceChar = true
@@ -296,8 +299,46 @@ func (l *Lexer) NextToken() (LangToken, error) {
 			tok = newTokenSingle(TokenTypeLessThan, l.ch)
 		}
 	case '>':
-		// TODO: Check for >=
-		tok = newTokenSingle(TokenTypeGreaterThan, l.ch)
+		if l.peekCharAtIndex(0) == '>' && l.peekCharAtIndex(1) == '>' { // >>> (Logical Shift Right)
+			l.readChar() // consume second '>'
+			l.readChar() // consume third '>'
+			tok = newTokenLiteral(TokenTypeShiftRightLogic, ">>>")
+		} else if l.peekChar() == '>' { // >> (Arithmetic Shift Right)
+			l.readChar() // consume second '>'
+			tok = newTokenLiteral(TokenTypeShiftRight, ">>")
+		} else if l.peekChar() == '=' { // >= (Greater Than Equal)
+			l.readChar() // consume '='
+			tok = newTokenLiteral(TokenTypeGreaterThanEqual, ">=")
+		} else { // > (Greater Than)
+			tok = newTokenSingle(TokenTypeGreaterThan, l.ch)
+		}
+	case '!':
+		if l.peekChar() == '=' { // != (Not Equal)
+			l.readChar() // consume '='
+			tok = newTokenLiteral(TokenTypeNotEqual, "!=")
+		} else { // ! (Logical Not)
+			tok = newTokenSingle(TokenTypeBang, l.ch)
+		}
+	case '%':
+		tok = newTokenSingle(TokenTypeModulo, l.ch)
+	case '&':
+		if l.peekChar() == '&' { // && (Logical And)
+			l.readChar() // consume second '&'
+			tok = newTokenLiteral(TokenTypeLogicalAnd, "&&")
+		} else { // & (Address Of, or Bitwise And as an infix operator)
+			tok = newTokenSingle(TokenTypeAmpersand, l.ch)
+		}
+	case '|':
+		if l.peekChar() == '|' { // || (Logical Or)
+			l.readChar() // consume second '|'
+			tok = newTokenLiteral(TokenTypeLogicalOr, "||")
+		} else { // | (Bitwise Or)
+			tok = newTokenSingle(TokenTypePipe, l.ch)
+		}
+	case '^':
+		tok = newTokenSingle(TokenTypeCaret, l.ch)
+	case '~':
+		tok = newTokenSingle(TokenTypeTilde, l.ch)
 	case '(':
 		tok = newTokenSingle(TokenTypeLeftParenthesis, l.ch)
 	case ')':
@@ -319,7 +360,13 @@ func (l *Lexer) NextToken() (LangToken, error) {
 	case '?':
 		tok = newTokenSingle(TokenTypeQuestionMark, l.ch)
 	case '.':
-		tok = newTokenSingle(TokenTypeDot, l.ch)
+		if l.peekCharAtIndex(0) == '.' && l.peekCharAtIndex(1) == '.' { // ... (Variadic)
+			l.readChar() // consume second '.'
+			l.readChar() // consume third '.'
+			tok = newTokenLiteral(TokenTypeEllipsis, "...")
+		} else {
+			tok = newTokenSingle(TokenTypeDot, l.ch)
+		}
 
 	// --- Literals ---
 	case '"', '`', '\'': // String literals
@@ -28,6 +28,15 @@ func TestLexer_NumericTests(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name:  "Test Hex Numbers",
+			input: "0x22 0XfF",
+			want: []LangToken{
+				{Type: TokenTypeNumber, Literal: "0x22", Line: 0, Pos: 0, Length: 4},
+				{Type: TokenTypeNumber, Literal: "0XfF", Line: 0, Pos: 5, Length: 4},
+			},
+			wantErr: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,95 @@
+package lexer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLexer_OperatorTests(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []LangToken
+	}{
+		{
+			name:  "Logical And Comparison Operators",
+			input: "a % b != c && !d || e >= f",
+			want: []LangToken{
+				{Type: TokenTypeIdentifier, Literal: "a", Line: 0, Pos: 0, Length: 1},
+				{Type: TokenTypeModulo, Literal: "%", Line: 0, Pos: 2, Length: 1},
+				{Type: TokenTypeIdentifier, Literal: "b", Line: 0, Pos: 4, Length: 1},
+				{Type: TokenTypeNotEqual, Literal: "!=", Line: 0, Pos: 6, Length: 2},
+				{Type: TokenTypeIdentifier, Literal: "c", Line: 0, Pos: 9, Length: 1},
+				{Type: TokenTypeLogicalAnd, Literal: "&&", Line: 0, Pos: 11, Length: 2},
+				{Type: TokenTypeBang, Literal: "!", Line: 0, Pos: 14, Length: 1},
+				{Type: TokenTypeIdentifier, Literal: "d", Line: 0, Pos: 15, Length: 1},
+				{Type: TokenTypeLogicalOr, Literal: "||", Line: 0, Pos: 17, Length: 2},
+				{Type: TokenTypeIdentifier, Literal: "e", Line: 0, Pos: 20, Length: 1},
+				{Type: TokenTypeGreaterThanEqual, Literal: ">=", Line: 0, Pos: 22, Length: 2},
+				{Type: TokenTypeIdentifier, Literal: "f", Line: 0, Pos: 25, Length: 1},
+			},
+		},
+		{
+			name:  "Booleans",
+			input: "true false",
+			want: []LangToken{
+				{Type: TokenTypeTrue, Literal: "true", Line: 0, Pos: 0, Length: 4},
+				{Type: TokenTypeFalse, Literal: "false", Line: 0, Pos: 5, Length: 5},
+			},
+		},
+		{
+			name:  "Bitwise Operators",
+			input: "a & b | ~c ^ d << 2 >> e >>> f",
+			want: []LangToken{
+				{Type: TokenTypeIdentifier, Literal: "a", Line: 0, Pos: 0, Length: 1},
+				{Type: TokenTypeAmpersand, Literal: "&", Line: 0, Pos: 2, Length: 1},
+				{Type: TokenTypeIdentifier, Literal: "b", Line: 0, Pos: 4, Length: 1},
+				{Type: TokenTypePipe, Literal: "|", Line: 0, Pos: 6, Length: 1},
+				{Type: TokenTypeTilde, Literal: "~", Line: 0, Pos: 8, Length: 1},
+				{Type: TokenTypeIdentifier, Literal: "c", Line: 0, Pos: 9, Length: 1},
+				{Type: TokenTypeCaret, Literal: "^", Line: 0, Pos: 11, Length: 1},
+				{Type: TokenTypeIdentifier, Literal: "d", Line: 0, Pos: 13, Length: 1},
+				{Type: TokenTypeShiftLeft, Literal: "<<", Line: 0, Pos: 15, Length: 2},
+				{Type: TokenTypeNumber, Literal: "2", Line: 0, Pos: 18, Length: 1},
+				{Type: TokenTypeShiftRight, Literal: ">>", Line: 0, Pos: 20, Length: 2},
+				{Type: TokenTypeIdentifier, Literal: "e", Line: 0, Pos: 23, Length: 1},
+				{Type: TokenTypeShiftRightLogic, Literal: ">>>", Line: 0, Pos: 25, Length: 3},
+				{Type: TokenTypeIdentifier, Literal: "f", Line: 0, Pos: 29, Length: 1},
+			},
+		},
+		{
+			name:  "Variadic Parameter",
+			input: "(args: ...any)",
+			want: []LangToken{
+				{Type: TokenTypeLeftParenthesis, Literal: "(", Line: 0, Pos: 0, Length: 1},
+				{Type: TokenTypeIdentifier, Literal: "args", Line: 0, Pos: 1, Length: 4},
+				{Type: TokenTypeColon, Literal: ":", Line: 0, Pos: 5, Length: 1},
+				{Type: TokenTypeEllipsis, Literal: "...", Line: 0, Pos: 7, Length: 3},
+				{Type: TokenTypeIdentifier, Literal: "any", Line: 0, Pos: 10, Length: 3},
+				{Type: TokenTypeRightParenthesis, Literal: ")", Line: 0, Pos: 13, Length: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := NewLexerFromString(tt.input)
+			if err != nil {
+				t.Fatalf("NewLexerFromString() error = %v", err)
+			}
+			for _, expected := range tt.want {
+				got, err := l.NextToken()
+				if err != nil {
+					t.Fatalf("NextToken() error = %v", err)
+				}
+				if !reflect.DeepEqual(got, expected) {
+					t.Errorf("NextToken() got = %v, want %v", got, expected)
+				}
+			}
+			extraToken, _ := l.NextToken()
+			if extraToken.Type != TokenTypeEOF {
+				t.Errorf("NextToken() produced extra token, got = %v", extraToken)
+			}
+		})
+	}
+}
@@ -9,6 +9,19 @@ func (l *Lexer) readNumber() string {
 	var numBuilder strings.Builder
 	hasDecimal := false
 
+	// Hexadecimal integer literal: 0x1F
+	if l.ch == '0' && (l.peekChar() == 'x' || l.peekChar() == 'X') {
+		numBuilder.WriteRune(l.ch)
+		l.readChar()
+		numBuilder.WriteRune(l.ch)
+		l.readChar()
+		for common.IsHexDigit(l.ch) {
+			numBuilder.WriteRune(l.ch)
+			l.readChar()
+		}
+		return numBuilder.String()
+	}
+
 	// Loop while the current character is a digit OR
 	// it's the first decimal point encountered AND the *next* character is a digit.
 	for common.IsDigit(l.ch) || (l.ch == '.' && !hasDecimal && common.IsDigit(l.peekChar())) {
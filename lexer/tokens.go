@@ -16,16 +16,32 @@ const (
 	TokenTypeIdentifier       TokenType = "Identifier"
 	TokenTypeNumber           TokenType = "Number"
 	TokenTypeString           TokenType = "String"
+	TokenTypeTrue             TokenType = "True"
+	TokenTypeFalse            TokenType = "False"
 	TokenTypeAssignment       TokenType = "Assignment"
 	TokenTypePlus             TokenType = "Plus"
 	TokenTypeMinus            TokenType = "Minus"
 	TokenTypeMultiply         TokenType = "Multiply"
 	TokenTypeDot              TokenType = "Dot"
 	TokenTypeDivide           TokenType = "Divide"
+	TokenTypeModulo           TokenType = "Modulo"
+	TokenTypeBang             TokenType = "Bang"
+	TokenTypeNotEqual         TokenType = "NotEqual"
+	TokenTypeLogicalAnd       TokenType = "LogicalAnd"
+	TokenTypeLogicalOr        TokenType = "LogicalOr"
+	TokenTypeAmpersand        TokenType = "Ampersand"
+	TokenTypePipe             TokenType = "Pipe"
+	TokenTypeCaret            TokenType = "Caret"
+	TokenTypeTilde            TokenType = "Tilde"
+	TokenTypeShiftLeft        TokenType = "ShiftLeft"
+	TokenTypeShiftRight       TokenType = "ShiftRight"
+	TokenTypeShiftRightLogic  TokenType = "ShiftRightLogical"
+	TokenTypeEllipsis         TokenType = "Ellipsis"
 	TokenTypeEqual            TokenType = "Equal"
 	TokenTypeLessThan         TokenType = "LessThan"
 	TokenTypeLessThanEqual    TokenType = "LessThanEqual"
 	TokenTypeGreaterThan      TokenType = "GreaterThan"
+	TokenTypeGreaterThanEqual TokenType = "GreaterThanEqual"
 	TokenTypeLeftParenthesis  TokenType = "LeftParenthesis"
 	TokenTypeRightParenthesis TokenType = "RightParenthesis"
 	TokenTypeLeftBrace        TokenType = "LeftBrace"
@@ -58,16 +74,21 @@ const (
 	TokenTypeReturn           TokenType = "Return"
 	TokenTypeSyscall          TokenType = "Syscall"
 	TokenTypeImport           TokenType = "Import"
+	TokenTypeAs               TokenType = "As"
+	TokenTypeYield            TokenType = "Yield"
 )
 
 const TokenTypeFunction TokenType = "Function"
 
 const TokenTypeLet TokenType = "Let"
 
+const TokenTypeConst TokenType = "Const"
+
 // Keywords is a map of reserved keywords to their corresponding token types.
 var Keywords = map[string]TokenType{
 	"function": TokenTypeFunction,
 	"let":      TokenTypeLet,
+	"const":    TokenTypeConst,
 	"if":       TokenTypeIf,
 	"in":       TokenTypeIn,
 	"range":    TokenTypeRange,
@@ -86,6 +107,10 @@ var Keywords = map[string]TokenType{
 	"asm":      TokenTypeAssembly,
 	"syscall":  TokenTypeSyscall,
 	"import":   TokenTypeImport,
+	"as":       TokenTypeAs,
+	"yield":    TokenTypeYield,
+	"true":     TokenTypeTrue,
+	"false":    TokenTypeFalse,
 	// Add more keywords here
 }
 
@@ -0,0 +1,417 @@
+// stdlib/async - an epoll event loop for non-blocking I/O, timers and tasks
+// Implemented entirely in Y-lang via Linux syscalls.
+// No external C runtime is required.
+//
+// A Loop waits for file descriptors with epoll and runs what was registered
+// for the ready ones:
+//
+//   - watch calls a function with the fd and the events that occurred,
+//   - timer calls a function when a timerfd expires, once or repeatedly,
+//   - spawn drives a task: a generator (function*) that yields the result of
+//     readable(fd), writable(fd) or sleep(ms) to suspend until that fd is
+//     ready or that time has passed, e.g. `yield async.readable(fd);`.
+//
+// run returns when nothing is registered any more or stop was called. The
+// loop is not synchronized; use it from one thread.
+//
+// A task's frame lives on the stack of the function that called the
+// generator, so tasks are spawned from main, which returns only after the
+// loop has finished with them; the compiler rejects spawning a task created
+// in any other function.
+//
+// Operations return a non-negative value on success and a negative error
+// code (-errno) on failure. read and write return -EAGAIN when the fd is not
+// ready.
+
+import "stdlib/mem"
+import "stdlib/sys"
+
+// Events of epoll_ctl. EPOLLERR and EPOLLHUP are always reported.
+const EPOLLIN = 1;
+const EPOLLOUT = 4;
+const EPOLLERR = 8;
+const EPOLLHUP = 16;
+
+const EPOLL_CLOEXEC = 524288;
+const EPOLL_CTL_ADD = 1;
+const EPOLL_CTL_DEL = 2;
+
+const F_GETFL = 3;
+const F_SETFL = 4;
+
+const AF_UNIX = 1;
+const SOCK_STREAM = 1;
+
+// Events fetched by one epoll_pwait.
+const MAX_EVENTS = 64;
+
+// What a Watcher does when its fd is ready.
+const WATCH_NONE = 0;
+const WATCH_CALLBACK = 1;
+const WATCH_TIMER = 2;
+const WATCH_TASK = 3;
+
+// A task's token is fd * 4 plus one of these.
+const TOKEN_READ = 1;
+const TOKEN_WRITE = 2;
+const TOKEN_SLEEP = 3;
+
+// Loop is an event loop.
+extern type Loop {
+    let epfd: i64;
+    // watchers is indexed by fd and has room for fds below cap.
+    let watchers: *u8;
+    let cap: i64;
+    // count is the number of registered watchers; run returns at 0.
+    let count: i64;
+    let stopped: bool;
+    // events receives the events of epoll_pwait; ctl is a struct
+    // epoll_event for epoll_ctl.
+    let events: *u8;
+    let ctl: *u8;
+}
+
+// Room for the Loop header ahead of its event buffers.
+const LOOP_SIZE = 64;
+
+// Watcher is what is registered for one fd.
+extern type Watcher {
+    let kind: i64;
+    let cb: (*Loop, i64, i64, *u8) -> i64;
+    let ctx: *u8;
+    let task: seq<i64>;
+    // owned is true when the fd was opened by the loop, as for timers and
+    // sleeps, and is closed with the watcher.
+    let owned: bool;
+    // repeat is true for an interval timer.
+    let repeat: bool;
+}
+
+const WATCHER_SIZE = 48;
+
+// itimerspec is the kernel's struct itimerspec.
+extern type itimerspec {
+    let intervalSec: i64;
+    let intervalNsec: i64;
+    let valueSec: i64;
+    let valueNsec: i64;
+}
+
+// Scratch itimerspec of the calling thread.
+thread_local let async_spec: *itimerspec = alloc(32) as *itimerspec;
+
+// newLoop returns an empty event loop, or 0 when epoll is not available.
+function newLoop(): *Loop -> {
+    let epfd = syscall(SYS.epoll_create1, EPOLL_CLOEXEC, 0, 0, 0, 0, 0);
+    if (epfd < 0) {
+        return 0 as *Loop;
+    }
+    let loop = alloc(LOOP_SIZE + 16 + MAX_EVENTS * 16) as *Loop;
+    loop.epfd = epfd;
+    loop.cap = 64;
+    loop.watchers = alloc(loop.cap * WATCHER_SIZE);
+    loop.count = 0;
+    loop.stopped = false;
+    loop.ctl = (loop as *u8) + LOOP_SIZE;
+    loop.events = loop.ctl + 16;
+    return loop;
+}
+
+// close releases loop, closing its epoll fd and the fds of its timers and
+// sleeping tasks. loop must not be used afterwards.
+function close(loop: *Loop) -> {
+    let fd = 0;
+    while (fd < loop.cap) {
+        let w = async_watcher(loop, fd);
+        if (w.kind != WATCH_NONE && w.owned) {
+            syscall(SYS.close, fd, 0, 0, 0, 0, 0);
+        }
+        fd = fd + 1;
+    }
+    syscall(SYS.close, loop.epfd, 0, 0, 0, 0, 0);
+    free(loop.watchers, loop.cap * WATCHER_SIZE);
+    free(loop as *u8, LOOP_SIZE + 16 + MAX_EVENTS * 16);
+}
+
+// async_watcher returns the Watcher of fd, which must be below loop.cap.
+function async_watcher(loop: *Loop, fd: i64): *Watcher -> {
+    return (loop.watchers + fd * WATCHER_SIZE) as *Watcher;
+}
+
+// async_add registers fd for events with epoll and returns its Watcher, or
+// 0 on failure with the error code in *err.
+function async_add(loop: *Loop, fd: i64, events: i64, kind: i64, err: *i64): *Watcher -> {
+    if (fd < 0) {
+        err[0] = -sys.EINVAL;
+        return 0 as *Watcher;
+    }
+    if (fd >= loop.cap) {
+        let newCap = loop.cap * 2;
+        while (fd >= newCap) {
+            newCap = newCap * 2;
+        }
+        let bigger = alloc(newCap * WATCHER_SIZE);
+        copy(bigger, loop.watchers, loop.cap * WATCHER_SIZE);
+        free(loop.watchers, loop.cap * WATCHER_SIZE);
+        loop.watchers = bigger;
+        loop.cap = newCap;
+    }
+
+    // The data field is the last 8 bytes of struct epoll_event; the fd goes
+    // in its low half.
+    let size: i64 = builtin.epollEventSize();
+    let ev = loop.ctl as *u32;
+    ev[0] = events;
+    let slot = (loop.ctl + size - 8) as *i32;
+    slot[0] = fd;
+    slot[1] = 0;
+    let r = syscall(SYS.epoll_ctl, loop.epfd, EPOLL_CTL_ADD, fd, loop.ctl, 0, 0);
+    if (r < 0) {
+        err[0] = r;
+        return 0 as *Watcher;
+    }
+
+    let w = async_watcher(loop, fd);
+    w.kind = kind;
+    w.owned = false;
+    w.repeat = false;
+    loop.count = loop.count + 1;
+    return w;
+}
+
+// watch calls cb(loop, fd, events, ctx) whenever fd is ready for any of
+// events (EPOLLIN, EPOLLOUT), until unwatch. The events passed to cb may
+// include EPOLLERR and EPOLLHUP. Only one watcher, timer or task can wait
+// for an fd at a time.
+function watch(loop: *Loop, fd: i64, events: i64, cb: (*Loop, i64, i64, *u8) -> i64, ctx: *u8 = 0 as *u8): i64 -> {
+    let err: i64 = 0;
+    let w = async_add(loop, fd, events, WATCH_CALLBACK, &err);
+    if (err < 0) {
+        return err;
+    }
+    w.cb = cb;
+    w.ctx = ctx;
+    return 0;
+}
+
+// unwatch removes what is registered for fd. The fd of a timer is closed.
+function unwatch(loop: *Loop, fd: i64): i64 -> {
+    if (fd < 0 || fd >= loop.cap) {
+        return -sys.EINVAL;
+    }
+    let w = async_watcher(loop, fd);
+    if (w.kind == WATCH_NONE) {
+        return -sys.EINVAL;
+    }
+    let r = syscall(SYS.epoll_ctl, loop.epfd, EPOLL_CTL_DEL, fd, loop.ctl, 0, 0);
+    if (w.owned) {
+        syscall(SYS.close, fd, 0, 0, 0, 0, 0);
+    }
+    w.kind = WATCH_NONE;
+    loop.count = loop.count - 1;
+    return r;
+}
+
+// async_timerfd returns a non-blocking timerfd expiring after ms
+// milliseconds, and then every intervalMs milliseconds unless that is 0.
+function async_timerfd(ms: i64, intervalMs: i64): i64 -> {
+    let fd = syscall(SYS.timerfd_create, sys.CLOCK_MONOTONIC, sys.O_NONBLOCK + sys.O_CLOEXEC, 0, 0, 0, 0);
+    if (fd < 0) {
+        return fd;
+    }
+    let spec = async_spec;
+    spec.intervalSec = intervalMs / 1000;
+    spec.intervalNsec = intervalMs % 1000 * 1000000;
+    spec.valueSec = ms / 1000;
+    spec.valueNsec = ms % 1000 * 1000000;
+    if (ms <= 0) {
+        // A zero expiry would disarm the timer.
+        spec.valueSec = 0;
+        spec.valueNsec = 1;
+    }
+    let r = syscall(SYS.timerfd_settime, fd, 0, spec, 0, 0, 0);
+    if (r < 0) {
+        syscall(SYS.close, fd, 0, 0, 0, 0, 0);
+        return r;
+    }
+    return fd;
+}
+
+// timer calls cb(loop, fd, expirations, ctx) after ms milliseconds, and then
+// every intervalMs milliseconds until cancel if intervalMs is not 0. It
+// returns the timer's fd, which identifies it for cancel. expirations counts
+// the intervals that passed since the last call.
+function timer(loop: *Loop, ms: i64, intervalMs: i64, cb: (*Loop, i64, i64, *u8) -> i64, ctx: *u8 = 0 as *u8): i64 -> {
+    let fd = async_timerfd(ms, intervalMs);
+    if (fd < 0) {
+        return fd;
+    }
+    let err: i64 = 0;
+    let w = async_add(loop, fd, EPOLLIN, WATCH_TIMER, &err);
+    if (err < 0) {
+        syscall(SYS.close, fd, 0, 0, 0, 0, 0);
+        return err;
+    }
+    w.cb = cb;
+    w.ctx = ctx;
+    w.owned = true;
+    w.repeat = intervalMs != 0;
+    return fd;
+}
+
+// cancel stops the timer fd.
+function cancel(loop: *Loop, fd: i64): i64 -> {
+    return unwatch(loop, fd);
+}
+
+// readable returns the token a task yields to wait until fd can be read.
+function readable(fd: i64): i64 -> {
+    return fd * 4 + TOKEN_READ;
+}
+
+// writable returns the token a task yields to wait until fd can be written.
+function writable(fd: i64): i64 -> {
+    return fd * 4 + TOKEN_WRITE;
+}
+
+// sleep returns the token a task yields to wait for ms milliseconds.
+function sleep(ms: i64): i64 -> {
+    let fd = async_timerfd(ms, 0);
+    if (fd < 0) {
+        return fd;
+    }
+    return fd * 4 + TOKEN_SLEEP;
+}
+
+// async_step resumes task until it yields a token, and registers the task
+// for it. A finished task is dropped; so is one yielding an invalid token,
+// whose error code is returned.
+function async_step(loop: *Loop, task: seq<i64>): i64 -> {
+    if (!task.next()) {
+        return 0;
+    }
+    let token = task.current();
+    if (token < 0) {
+        return token;
+    }
+    let fd = token / 4;
+    let kind = token % 4;
+    let events = EPOLLIN;
+    if (kind == TOKEN_WRITE) {
+        events = EPOLLOUT;
+    }
+    let err: i64 = 0;
+    let w = async_add(loop, fd, events, WATCH_TASK, &err);
+    if (err < 0) {
+        if (kind == TOKEN_SLEEP) {
+            syscall(SYS.close, fd, 0, 0, 0, 0, 0);
+        }
+        return err;
+    }
+    w.task = task;
+    w.owned = kind == TOKEN_SLEEP;
+    return 0;
+}
+
+// spawn runs task up to its first yield and lets the loop resume it each
+// time what it waits for is ready, until it finishes.
+function spawn(loop: *Loop, task: seq<i64>): i64 -> {
+    return async_step(loop, task);
+}
+
+// async_dispatch runs what is registered for fd, which had events.
+function async_dispatch(loop: *Loop, fd: i64, events: i64) -> {
+    if (fd >= loop.cap) {
+        return;
+    }
+    let w = async_watcher(loop, fd);
+    if (w.kind == WATCH_CALLBACK) {
+        let f = w.cb;
+        f(loop, fd, events, w.ctx);
+    } else if (w.kind == WATCH_TIMER) {
+        let expirations: i64 = 0;
+        if (syscall(SYS.read, fd, &expirations, 8, 0, 0, 0) == 8) {
+            let f = w.cb;
+            let ctx = w.ctx;
+            if (!w.repeat) {
+                unwatch(loop, fd);
+            }
+            f(loop, fd, expirations, ctx);
+        }
+    } else if (w.kind == WATCH_TASK) {
+        let task = w.task;
+        unwatch(loop, fd);
+        async_step(loop, task);
+    }
+}
+
+// run waits for events and dispatches them until nothing is registered or
+// stop is called. It returns 0, or the error code of a failed epoll_pwait.
+function run(loop: *Loop): i64 -> {
+    let size: i64 = builtin.epollEventSize();
+    loop.stopped = false;
+    while (!loop.stopped && loop.count > 0) {
+        let n = syscall(SYS.epoll_pwait, loop.epfd, loop.events, MAX_EVENTS, -1, 0, 8);
+        if (n < 0 && n != -sys.EINTR) {
+            return n;
+        }
+        let i = 0;
+        while (i < n) {
+            let ev = loop.events + i * size;
+            let flags = ev as *u32;
+            let slot = (ev + size - 8) as *i32;
+            async_dispatch(loop, slot[0] as i64, flags[0] as i64);
+            i = i + 1;
+        }
+    }
+    return 0;
+}
+
+// stop makes run return after the events it is dispatching.
+function stop(loop: *Loop) -> {
+    loop.stopped = true;
+}
+
+// read reads up to n bytes from fd into buf. It returns the number of bytes
+// read, 0 at the end of input, or -EAGAIN when nothing is available yet.
+function read(fd: i64, buf: *u8, n: i64): i64 -> {
+    let r = syscall(SYS.read, fd, buf, n, 0, 0, 0);
+    while (r == -sys.EINTR) {
+        r = syscall(SYS.read, fd, buf, n, 0, 0, 0);
+    }
+    return r;
+}
+
+// write writes up to n bytes of buf to fd. It returns the number of bytes
+// written, or -EAGAIN when fd cannot take any yet.
+function write(fd: i64, buf: *u8, n: i64): i64 -> {
+    let r = syscall(SYS.write, fd, buf, n, 0, 0, 0);
+    while (r == -sys.EINTR) {
+        r = syscall(SYS.write, fd, buf, n, 0, 0, 0);
+    }
+    return r;
+}
+
+// setNonblocking puts fd in non-blocking mode.
+function setNonblocking(fd: i64): i64 -> {
+    let flags = syscall(SYS.fcntl, fd, F_GETFL, 0, 0, 0, 0);
+    if (flags < 0) {
+        return flags;
+    }
+    if (flags / sys.O_NONBLOCK % 2 == 1) {
+        return 0;
+    }
+    return syscall(SYS.fcntl, fd, F_SETFL, flags + sys.O_NONBLOCK, 0, 0, 0);
+}
+
+// pipe creates a non-blocking pipe and stores its read and write ends in
+// fds[0] and fds[1].
+function pipe(fds: *i32): i64 -> {
+    return syscall(SYS.pipe2, fds, sys.O_NONBLOCK + sys.O_CLOEXEC, 0, 0, 0, 0);
+}
+
+// socketpair creates a pair of connected non-blocking Unix stream sockets
+// and stores them in fds[0] and fds[1].
+function socketpair(fds: *i32): i64 -> {
+    return syscall(SYS.socketpair, AF_UNIX, SOCK_STREAM + sys.O_NONBLOCK + sys.O_CLOEXEC, 0, fds, 0, 0);
+}
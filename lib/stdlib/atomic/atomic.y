@@ -0,0 +1,77 @@
+// stdlib/atomic - atomic operations on memory shared between threads
+// Implemented entirely in Y-lang over compiler intrinsics that lower to the
+// LLVM atomic instructions (load atomic, store atomic, atomicrmw, cmpxchg
+// and fence). No external C runtime is required.
+//
+// Every operation takes a memory ordering, SEQ_CST by default. Orderings a
+// load (RELEASE, ACQ_REL) or store (ACQUIRE, ACQ_REL) cannot have are
+// treated as SEQ_CST. The functions ending in 32 operate on i32 words, such
+// as the futex words of stdlib/sync.
+
+// Memory orderings, from weakest to strongest.
+const RELAXED = 0;
+const ACQUIRE = 1;
+const RELEASE = 2;
+const ACQ_REL = 3;
+const SEQ_CST = 4;
+
+// load returns *p.
+function load(p: *i64, order: i64 = SEQ_CST): i64 -> {
+    return builtin.atomicLoad(p, order);
+}
+
+// store sets *p to v.
+function store(p: *i64, v: i64, order: i64 = SEQ_CST) -> {
+    builtin.atomicStore(p, v, order);
+}
+
+// add adds delta to *p and returns the previous value.
+function add(p: *i64, delta: i64, order: i64 = SEQ_CST): i64 -> {
+    return builtin.atomicAdd(p, delta, order);
+}
+
+// sub subtracts delta from *p and returns the previous value.
+function sub(p: *i64, delta: i64, order: i64 = SEQ_CST): i64 -> {
+    return builtin.atomicSub(p, delta, order);
+}
+
+// swap sets *p to v and returns the previous value.
+function swap(p: *i64, v: i64, order: i64 = SEQ_CST): i64 -> {
+    return builtin.atomicSwap(p, v, order);
+}
+
+// cas sets *p to new if it holds old, and reports whether it did.
+function cas(p: *i64, old: i64, new: i64, order: i64 = SEQ_CST): bool -> {
+    return builtin.atomicCas(p, old, new, order);
+}
+
+// load32 returns *p.
+function load32(p: *i32, order: i64 = SEQ_CST): i32 -> {
+    return builtin.atomicLoad(p, order);
+}
+
+// store32 sets *p to v.
+function store32(p: *i32, v: i64, order: i64 = SEQ_CST) -> {
+    builtin.atomicStore(p, v, order);
+}
+
+// add32 adds delta to *p and returns the previous value.
+function add32(p: *i32, delta: i64, order: i64 = SEQ_CST): i32 -> {
+    return builtin.atomicAdd(p, delta, order);
+}
+
+// swap32 sets *p to v and returns the previous value.
+function swap32(p: *i32, v: i64, order: i64 = SEQ_CST): i32 -> {
+    return builtin.atomicSwap(p, v, order);
+}
+
+// cas32 sets *p to new if it holds old, and reports whether it did.
+function cas32(p: *i32, old: i64, new: i64, order: i64 = SEQ_CST): bool -> {
+    return builtin.atomicCas(p, old, new, order);
+}
+
+// fence orders the memory accesses before it against those after it.
+// RELAXED is treated as SEQ_CST.
+function fence(order: i64 = SEQ_CST) -> {
+    builtin.fence(order);
+}
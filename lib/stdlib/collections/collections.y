@@ -0,0 +1,827 @@
+// stdlib/collections - hash maps and sets, a deque, a priority queue and a
+// string builder
+// Implemented entirely in Y-lang over the heap of stdlib/mem.
+// No external C runtime is required.
+//
+// HashMap and HashSet use open addressing with linear probing and remove
+// entries by shifting the rest of their run back, so no tombstones build up.
+// Keys are hashed with SipHash-2-4 under a key chosen at random when the
+// first map is made; setSeed fixes it, for reproducible iteration order.
+// Maps and sets are keyed by strings (newHashMap, newHashSet), which they
+// copy, or by integers (newIntHashMap, newIntHashSet); use the Int
+// functions with the latter. Values are i64. None of the collections are
+// synchronized.
+
+import "stdlib/core/string"
+import "stdlib/mem"
+
+// Slots of a table are three words: the hash with its low bit set (0 for an
+// empty slot), the key and the value.
+const SLOT_WORDS = 3;
+const MIN_SLOTS = 8;
+
+// HashMap maps string or integer keys to i64 values.
+extern type HashMap {
+    let slots: *i64;
+    let cap: i64;
+    let count: i64;
+    let intKeys: bool;
+    // k0 and k1 are the SipHash key.
+    let k0: i64;
+    let k1: i64;
+}
+
+const HASHMAP_SIZE = 48;
+
+// HashSet is a set of strings or integers.
+extern type HashSet {
+    let map: *HashMap;
+}
+
+// Deque is a double-ended queue of i64 in a ring buffer.
+extern type Deque {
+    let items: *i64;
+    let cap: i64;
+    let head: i64;
+    let len: i64;
+}
+
+const DEQUE_SIZE = 32;
+
+// PriorityQueue is a binary heap of values ordered by an i64 priority,
+// lowest first (or highest, for newMaxPriorityQueue). Values of equal
+// priority leave in the order they were pushed.
+extern type PriorityQueue {
+    // items holds len entries of three words: priority, order of arrival
+    // and value.
+    let items: *i64;
+    let cap: i64;
+    let len: i64;
+    let pushed: i64;
+    let max: bool;
+}
+
+const QUEUE_SIZE = 40;
+
+// StringBuilder builds a string piece by piece. Its bytes are always null
+// terminated.
+extern type StringBuilder {
+    let buf: *u8;
+    let len: i64;
+    let cap: i64;
+}
+
+const BUILDER_SIZE = 24;
+
+// The seed of new maps and sets, read from getrandom by the first one
+// unless setSeed is called.
+let coll_seeded = false;
+let coll_seed0: i64 = 0;
+let coll_seed1: i64 = 0;
+
+// setSeed sets the SipHash key of maps and sets created from now on.
+function setSeed(k0: i64, k1: i64) -> {
+    coll_seed0 = k0;
+    coll_seed1 = k1;
+    coll_seeded = true;
+}
+
+function coll_seed() -> {
+    if (!coll_seeded) {
+        let key = heapAlloc(16);
+        syscall(SYS.getrandom, key, 16, 0, 0, 0, 0);
+        let words = key as *i64;
+        setSeed(words[0], words[1]);
+        heapFree(key);
+    }
+}
+
+// coll_rotl rotates x left by b bits.
+function coll_rotl(x: i64, b: i64): i64 -> {
+    return (x << b) | (x >>> (64 - b));
+}
+
+// sipHash returns the SipHash-2-4 of the n bytes at p under the key k0, k1.
+function sipHash(k0: i64, k1: i64, p: *u8, n: i64): i64 -> {
+    let v0 = k0 ^ 0x736f6d6570736575;
+    let v1 = k1 ^ 0x646f72616e646f6d;
+    let v2 = k0 ^ 0x6c7967656e657261;
+    let v3 = k1 ^ 0x7465646279746573;
+
+    // The last word holds the bytes after the last full word and the
+    // length in its top byte.
+    let full = n / 8;
+    let last = n << 56;
+    let i = full * 8;
+    while (i < n) {
+        last = last | ((p[i] as i64 & 255) << (8 * (i - full * 8)));
+        i = i + 1;
+    }
+
+    let w: i64 = 0;
+    while (w <= full) {
+        let m = last;
+        if (w < full) {
+            let word = (p + w * 8) as *i64;
+            m = word[0];
+        }
+        v3 = v3 ^ m;
+        let r = 0;
+        while (r < 2) {
+            v0 = v0 + v1; v1 = coll_rotl(v1, 13); v1 = v1 ^ v0; v0 = coll_rotl(v0, 32);
+            v2 = v2 + v3; v3 = coll_rotl(v3, 16); v3 = v3 ^ v2;
+            v0 = v0 + v3; v3 = coll_rotl(v3, 21); v3 = v3 ^ v0;
+            v2 = v2 + v1; v1 = coll_rotl(v1, 17); v1 = v1 ^ v2; v2 = coll_rotl(v2, 32);
+            r = r + 1;
+        }
+        v0 = v0 ^ m;
+        w = w + 1;
+    }
+
+    v2 = v2 ^ 255;
+    let r = 0;
+    while (r < 4) {
+        v0 = v0 + v1; v1 = coll_rotl(v1, 13); v1 = v1 ^ v0; v0 = coll_rotl(v0, 32);
+        v2 = v2 + v3; v3 = coll_rotl(v3, 16); v3 = v3 ^ v2;
+        v0 = v0 + v3; v3 = coll_rotl(v3, 21); v3 = v3 ^ v0;
+        v2 = v2 + v1; v1 = coll_rotl(v1, 17); v1 = v1 ^ v2; v2 = coll_rotl(v2, 32);
+        r = r + 1;
+    }
+    return v0 ^ v1 ^ v2 ^ v3;
+}
+
+// ---- HashMap ----
+
+function coll_new_map(intKeys: bool): *HashMap -> {
+    coll_seed();
+    let m = heapAlloc(HASHMAP_SIZE) as *HashMap;
+    m.intKeys = intKeys;
+    m.k0 = coll_seed0;
+    m.k1 = coll_seed1;
+    return m;
+}
+
+// newHashMap returns an empty map with string keys.
+function newHashMap(): *HashMap -> {
+    return coll_new_map(false);
+}
+
+// newIntHashMap returns an empty map with integer keys.
+function newIntHashMap(): *HashMap -> {
+    return coll_new_map(true);
+}
+
+// coll_hash returns the hash of key, with the low bit set so it is never 0.
+function coll_hash(m: *HashMap, key: i64): i64 -> {
+    if (m.intKeys) {
+        let word = key;
+        return sipHash(m.k0, m.k1, &word as *u8, 8) | 1;
+    }
+    let s = key as *u8;
+    return sipHash(m.k0, m.k1, s, strlen(s)) | 1;
+}
+
+// coll_home returns the slot where probing for hash h starts.
+function coll_home(m: *HashMap, h: i64): i64 -> {
+    return (h >>> 1) & (m.cap - 1);
+}
+
+function coll_same_key(m: *HashMap, a: i64, b: i64): bool -> {
+    if (a == b) {
+        return true;
+    }
+    if (m.intKeys) {
+        return false;
+    }
+    let x = a as *u8;
+    let y = b as *u8;
+    let i = 0;
+    while (x[i] != 0 && x[i] == y[i]) {
+        i = i + 1;
+    }
+    return x[i] == y[i];
+}
+
+// coll_find returns the slot holding key, or -1.
+function coll_find(m: *HashMap, key: i64, h: i64): i64 -> {
+    if (m.cap == 0) {
+        return -1;
+    }
+    let slots = m.slots;
+    let i = coll_home(m, h);
+    while (slots[i * SLOT_WORDS] != 0) {
+        if (slots[i * SLOT_WORDS] == h && coll_same_key(m, slots[i * SLOT_WORDS + 1], key)) {
+            return i;
+        }
+        i = (i + 1) & (m.cap - 1);
+    }
+    return -1;
+}
+
+// coll_place stores an entry that is not in the map yet.
+function coll_place(m: *HashMap, h: i64, key: i64, value: i64) -> {
+    let slots = m.slots;
+    let i = coll_home(m, h);
+    while (slots[i * SLOT_WORDS] != 0) {
+        i = (i + 1) & (m.cap - 1);
+    }
+    slots[i * SLOT_WORDS] = h;
+    slots[i * SLOT_WORDS + 1] = key;
+    slots[i * SLOT_WORDS + 2] = value;
+}
+
+// coll_grow doubles the table, keeping it at most three quarters full.
+function coll_grow(m: *HashMap) -> {
+    let old = m.slots;
+    let oldCap = m.cap;
+    let cap: i64 = MIN_SLOTS;
+    if (oldCap > 0) {
+        cap = oldCap * 2;
+    }
+    m.slots = heapAlloc(cap * SLOT_WORDS * 8) as *i64;
+    m.cap = cap;
+    let i: i64 = 0;
+    while (i < oldCap) {
+        if (old[i * SLOT_WORDS] != 0) {
+            coll_place(m, old[i * SLOT_WORDS], old[i * SLOT_WORDS + 1], old[i * SLOT_WORDS + 2]);
+        }
+        i = i + 1;
+    }
+    heapFree(old as *u8);
+}
+
+// coll_copy_key returns a copy of the string key the map can own.
+function coll_copy_key(key: i64): i64 -> {
+    let s = key as *u8;
+    let n = strlen(s) + 1;
+    let c = heapAlloc(n);
+    copy(c, s, n);
+    return c as i64;
+}
+
+function coll_put(m: *HashMap, key: i64, value: i64): bool -> {
+    let h = coll_hash(m, key);
+    let i = coll_find(m, key, h);
+    if (i >= 0) {
+        m.slots[i * SLOT_WORDS + 2] = value;
+        return false;
+    }
+    if ((m.count + 1) * 4 > m.cap * 3) {
+        coll_grow(m);
+    }
+    if (!m.intKeys) {
+        key = coll_copy_key(key);
+    }
+    coll_place(m, h, key, value);
+    m.count = m.count + 1;
+    return true;
+}
+
+function coll_get(m: *HashMap, key: i64, missing: i64): i64 -> {
+    let i = coll_find(m, key, coll_hash(m, key));
+    if (i < 0) {
+        return missing;
+    }
+    return m.slots[i * SLOT_WORDS + 2];
+}
+
+function coll_remove(m: *HashMap, key: i64): bool -> {
+    let i = coll_find(m, key, coll_hash(m, key));
+    if (i < 0) {
+        return false;
+    }
+    let slots = m.slots;
+    if (!m.intKeys) {
+        heapFree(slots[i * SLOT_WORDS + 1] as *u8);
+    }
+    // Move back each later entry of the run that may live in the hole, so
+    // lookups never stop early at it.
+    let mask = m.cap - 1;
+    let j = i;
+    let scanning = true;
+    while (scanning) {
+        j = (j + 1) & mask;
+        if (slots[j * SLOT_WORDS] == 0) {
+            scanning = false;
+        } else {
+            let home = coll_home(m, slots[j * SLOT_WORDS]);
+            // The entry stays when its home lies cyclically in (i, j].
+            let stays = (i < home && home <= j) || (j < i && (i < home || home <= j));
+            if (!stays) {
+                slots[i * SLOT_WORDS] = slots[j * SLOT_WORDS];
+                slots[i * SLOT_WORDS + 1] = slots[j * SLOT_WORDS + 1];
+                slots[i * SLOT_WORDS + 2] = slots[j * SLOT_WORDS + 2];
+                i = j;
+            }
+        }
+    }
+    slots[i * SLOT_WORDS] = 0;
+    slots[i * SLOT_WORDS + 1] = 0;
+    slots[i * SLOT_WORDS + 2] = 0;
+    m.count = m.count - 1;
+    return true;
+}
+
+// mapPut sets the value of key, adding the key when it is new.
+function mapPut(m: *HashMap, key: string, value: i64) -> {
+    coll_put(m, key as i64, value);
+}
+
+// mapGet returns the value of key, or missing when the map does not hold it.
+function mapGet(m: *HashMap, key: string, missing: i64 = 0): i64 -> {
+    return coll_get(m, key as i64, missing);
+}
+
+// mapHas reports whether the map holds key.
+function mapHas(m: *HashMap, key: string): bool -> {
+    return coll_find(m, key as i64, coll_hash(m, key as i64)) >= 0;
+}
+
+// mapRemove removes key and reports whether the map held it.
+function mapRemove(m: *HashMap, key: string): bool -> {
+    return coll_remove(m, key as i64);
+}
+
+// mapPutInt sets the value of key in a map with integer keys.
+function mapPutInt(m: *HashMap, key: i64, value: i64) -> {
+    coll_put(m, key, value);
+}
+
+// mapGetInt returns the value of key, or missing.
+function mapGetInt(m: *HashMap, key: i64, missing: i64 = 0): i64 -> {
+    return coll_get(m, key, missing);
+}
+
+// mapHasInt reports whether the map holds key.
+function mapHasInt(m: *HashMap, key: i64): bool -> {
+    return coll_find(m, key, coll_hash(m, key)) >= 0;
+}
+
+// mapRemoveInt removes key and reports whether the map held it.
+function mapRemoveInt(m: *HashMap, key: i64): bool -> {
+    return coll_remove(m, key);
+}
+
+// mapLen returns the number of entries.
+function mapLen(m: *HashMap): i64 -> {
+    return m.count;
+}
+
+// mapClear removes every entry, keeping the table.
+function mapClear(m: *HashMap) -> {
+    let i: i64 = 0;
+    while (i < m.cap) {
+        if (!m.intKeys && m.slots[i * SLOT_WORDS] != 0) {
+            heapFree(m.slots[i * SLOT_WORDS + 1] as *u8);
+        }
+        i = i + 1;
+    }
+    zero(m.slots as *u8, m.cap * SLOT_WORDS * 8);
+    m.count = 0;
+}
+
+// freeHashMap releases the map and the keys it copied.
+function freeHashMap(m: *HashMap) -> {
+    mapClear(m);
+    heapFree(m.slots as *u8);
+    heapFree(m as *u8);
+}
+
+// mapKeys yields the keys of a map with string keys, in table order. The
+// map must not change while it is iterated.
+function* mapKeys(m: *HashMap): string -> {
+    let i: i64 = 0;
+    while (i < m.cap) {
+        if (m.slots[i * SLOT_WORDS] != 0) {
+            yield m.slots[i * SLOT_WORDS + 1] as *u8;
+        }
+        i = i + 1;
+    }
+}
+
+// mapIntKeys yields the keys of a map with integer keys, in table order.
+function* mapIntKeys(m: *HashMap): i64 -> {
+    let i: i64 = 0;
+    while (i < m.cap) {
+        if (m.slots[i * SLOT_WORDS] != 0) {
+            yield m.slots[i * SLOT_WORDS + 1];
+        }
+        i = i + 1;
+    }
+}
+
+// mapValues yields the values of a map in the order of mapKeys.
+function* mapValues(m: *HashMap): i64 -> {
+    let i: i64 = 0;
+    while (i < m.cap) {
+        if (m.slots[i * SLOT_WORDS] != 0) {
+            yield m.slots[i * SLOT_WORDS + 2];
+        }
+        i = i + 1;
+    }
+}
+
+// ---- HashSet ----
+
+// newHashSet returns an empty set of strings.
+function newHashSet(): *HashSet -> {
+    let s = heapAlloc(8) as *HashSet;
+    s.map = coll_new_map(false);
+    return s;
+}
+
+// newIntHashSet returns an empty set of integers.
+function newIntHashSet(): *HashSet -> {
+    let s = heapAlloc(8) as *HashSet;
+    s.map = coll_new_map(true);
+    return s;
+}
+
+// setAdd adds key and reports whether it was new.
+function setAdd(s: *HashSet, key: string): bool -> {
+    return coll_put(s.map, key as i64, 0);
+}
+
+// setHas reports whether the set holds key.
+function setHas(s: *HashSet, key: string): bool -> {
+    return mapHas(s.map, key);
+}
+
+// setRemove removes key and reports whether the set held it.
+function setRemove(s: *HashSet, key: string): bool -> {
+    return coll_remove(s.map, key as i64);
+}
+
+// setAddInt adds key to a set of integers and reports whether it was new.
+function setAddInt(s: *HashSet, key: i64): bool -> {
+    return coll_put(s.map, key, 0);
+}
+
+// setHasInt reports whether the set holds key.
+function setHasInt(s: *HashSet, key: i64): bool -> {
+    return mapHasInt(s.map, key);
+}
+
+// setRemoveInt removes key and reports whether the set held it.
+function setRemoveInt(s: *HashSet, key: i64): bool -> {
+    return coll_remove(s.map, key);
+}
+
+// setLen returns the number of members.
+function setLen(s: *HashSet): i64 -> {
+    return s.map.count;
+}
+
+// setClear removes every member.
+function setClear(s: *HashSet) -> {
+    mapClear(s.map);
+}
+
+// freeHashSet releases the set.
+function freeHashSet(s: *HashSet) -> {
+    freeHashMap(s.map);
+    heapFree(s as *u8);
+}
+
+// setMembers yields the members of a set of strings, in table order.
+function* setMembers(s: *HashSet): string -> {
+    let m = s.map;
+    let i: i64 = 0;
+    while (i < m.cap) {
+        if (m.slots[i * SLOT_WORDS] != 0) {
+            yield m.slots[i * SLOT_WORDS + 1] as *u8;
+        }
+        i = i + 1;
+    }
+}
+
+// setIntMembers yields the members of a set of integers, in table order.
+function* setIntMembers(s: *HashSet): i64 -> {
+    let m = s.map;
+    let i: i64 = 0;
+    while (i < m.cap) {
+        if (m.slots[i * SLOT_WORDS] != 0) {
+            yield m.slots[i * SLOT_WORDS + 1];
+        }
+        i = i + 1;
+    }
+}
+
+// ---- Deque ----
+
+// newDeque returns an empty deque.
+function newDeque(): *Deque -> {
+    return heapAlloc(DEQUE_SIZE) as *Deque;
+}
+
+// coll_deque_grow doubles the ring, moving the items to its start.
+function coll_deque_grow(d: *Deque) -> {
+    let cap: i64 = MIN_SLOTS;
+    if (d.cap > 0) {
+        cap = d.cap * 2;
+    }
+    let items = heapAlloc(cap * 8) as *i64;
+    let i: i64 = 0;
+    while (i < d.len) {
+        items[i] = d.items[(d.head + i) & (d.cap - 1)];
+        i = i + 1;
+    }
+    heapFree(d.items as *u8);
+    d.items = items;
+    d.cap = cap;
+    d.head = 0;
+}
+
+// pushBack adds v at the back.
+function pushBack(d: *Deque, v: i64) -> {
+    if (d.len == d.cap) {
+        coll_deque_grow(d);
+    }
+    d.items[(d.head + d.len) & (d.cap - 1)] = v;
+    d.len = d.len + 1;
+}
+
+// pushFront adds v at the front.
+function pushFront(d: *Deque, v: i64) -> {
+    if (d.len == d.cap) {
+        coll_deque_grow(d);
+    }
+    d.head = (d.head - 1) & (d.cap - 1);
+    d.items[d.head] = v;
+    d.len = d.len + 1;
+}
+
+// popFront removes and returns the front item, or 0 when d is empty.
+function popFront(d: *Deque): i64 -> {
+    if (d.len == 0) {
+        return 0;
+    }
+    let v = d.items[d.head];
+    d.head = (d.head + 1) & (d.cap - 1);
+    d.len = d.len - 1;
+    return v;
+}
+
+// popBack removes and returns the back item, or 0 when d is empty.
+function popBack(d: *Deque): i64 -> {
+    if (d.len == 0) {
+        return 0;
+    }
+    d.len = d.len - 1;
+    return d.items[(d.head + d.len) & (d.cap - 1)];
+}
+
+// dequeAt returns the i-th item from the front, or 0 when i is out of range.
+function dequeAt(d: *Deque, i: i64): i64 -> {
+    if (i < 0 || i >= d.len) {
+        return 0;
+    }
+    return d.items[(d.head + i) & (d.cap - 1)];
+}
+
+// front returns the front item, or 0 when d is empty.
+function front(d: *Deque): i64 -> {
+    return dequeAt(d, 0);
+}
+
+// back returns the back item, or 0 when d is empty.
+function back(d: *Deque): i64 -> {
+    return dequeAt(d, d.len - 1);
+}
+
+// dequeLen returns the number of items.
+function dequeLen(d: *Deque): i64 -> {
+    return d.len;
+}
+
+// freeDeque releases the deque.
+function freeDeque(d: *Deque) -> {
+    heapFree(d.items as *u8);
+    heapFree(d as *u8);
+}
+
+// dequeItems yields the items from front to back.
+function* dequeItems(d: *Deque): i64 -> {
+    let i: i64 = 0;
+    while (i < d.len) {
+        yield d.items[(d.head + i) & (d.cap - 1)];
+        i = i + 1;
+    }
+}
+
+// ---- PriorityQueue ----
+
+// newPriorityQueue returns an empty queue that pops the lowest priority
+// first.
+function newPriorityQueue(): *PriorityQueue -> {
+    return heapAlloc(QUEUE_SIZE) as *PriorityQueue;
+}
+
+// newMaxPriorityQueue returns an empty queue that pops the highest priority
+// first.
+function newMaxPriorityQueue(): *PriorityQueue -> {
+    let q = newPriorityQueue();
+    q.max = true;
+    return q;
+}
+
+// coll_before reports whether entry a leaves the queue before entry b.
+function coll_before(q: *PriorityQueue, a: i64, b: i64): bool -> {
+    let x = q.items + a * SLOT_WORDS;
+    let y = q.items + b * SLOT_WORDS;
+    if (x[0] != y[0]) {
+        if (q.max) {
+            return x[0] > y[0];
+        }
+        return x[0] < y[0];
+    }
+    return x[1] < y[1];
+}
+
+function coll_swap(q: *PriorityQueue, a: i64, b: i64) -> {
+    let x = q.items + a * SLOT_WORDS;
+    let y = q.items + b * SLOT_WORDS;
+    let k = 0;
+    while (k < SLOT_WORDS) {
+        let t = x[k];
+        x[k] = y[k];
+        y[k] = t;
+        k = k + 1;
+    }
+}
+
+// push adds value with priority.
+function push(q: *PriorityQueue, priority: i64, value: i64) -> {
+    if (q.len == q.cap) {
+        let cap: i64 = MIN_SLOTS;
+        if (q.cap > 0) {
+            cap = q.cap * 2;
+        }
+        q.items = heapRealloc(q.items as *u8, cap * SLOT_WORDS * 8) as *i64;
+        q.cap = cap;
+    }
+    let e = q.items + q.len * SLOT_WORDS;
+    e[0] = priority;
+    e[1] = q.pushed;
+    e[2] = value;
+    q.pushed = q.pushed + 1;
+    let i = q.len;
+    q.len = q.len + 1;
+    while (i > 0 && coll_before(q, i, (i - 1) / 2)) {
+        coll_swap(q, i, (i - 1) / 2);
+        i = (i - 1) / 2;
+    }
+}
+
+// pop removes the first value in priority order and returns it, or 0 when
+// q is empty.
+function pop(q: *PriorityQueue): i64 -> {
+    if (q.len == 0) {
+        return 0;
+    }
+    let v = q.items[2];
+    q.len = q.len - 1;
+    coll_swap(q, 0, q.len);
+    let i: i64 = 0;
+    let sifting = true;
+    while (sifting) {
+        let first = i;
+        let l = 2 * i + 1;
+        if (l < q.len && coll_before(q, l, first)) {
+            first = l;
+        }
+        if (l + 1 < q.len && coll_before(q, l + 1, first)) {
+            first = l + 1;
+        }
+        if (first == i) {
+            sifting = false;
+        } else {
+            coll_swap(q, i, first);
+            i = first;
+        }
+    }
+    return v;
+}
+
+// peek returns the value pop would return without removing it.
+function peek(q: *PriorityQueue): i64 -> {
+    if (q.len == 0) {
+        return 0;
+    }
+    return q.items[2];
+}
+
+// peekPriority returns the priority of the value peek returns.
+function peekPriority(q: *PriorityQueue): i64 -> {
+    if (q.len == 0) {
+        return 0;
+    }
+    return q.items[0];
+}
+
+// queueLen returns the number of values queued.
+function queueLen(q: *PriorityQueue): i64 -> {
+    return q.len;
+}
+
+// freePriorityQueue releases the queue.
+function freePriorityQueue(q: *PriorityQueue) -> {
+    heapFree(q.items as *u8);
+    heapFree(q as *u8);
+}
+
+// ---- StringBuilder ----
+
+// newStringBuilder returns an empty builder.
+function newStringBuilder(): *StringBuilder -> {
+    let b = heapAlloc(BUILDER_SIZE) as *StringBuilder;
+    b.cap = 32;
+    b.buf = heapAlloc(b.cap);
+    return b;
+}
+
+// coll_reserve makes room for n more bytes and the terminator.
+function coll_reserve(b: *StringBuilder, n: i64) -> {
+    if (b.len + n + 1 > b.cap) {
+        let cap = b.cap * 2;
+        while (b.len + n + 1 > cap) {
+            cap = cap * 2;
+        }
+        b.buf = heapRealloc(b.buf, cap);
+        b.cap = cap;
+    }
+}
+
+// appendBytes appends the n bytes at p.
+function appendBytes(b: *StringBuilder, p: *u8, n: i64) -> {
+    coll_reserve(b, n);
+    copy(b.buf + b.len, p, n);
+    b.len = b.len + n;
+}
+
+// append appends s.
+function append(b: *StringBuilder, s: string) -> {
+    appendBytes(b, s, strlen(s));
+}
+
+// appendByte appends the byte c.
+function appendByte(b: *StringBuilder, c: i64) -> {
+    coll_reserve(b, 1);
+    b.buf[b.len] = c;
+    b.len = b.len + 1;
+}
+
+// appendInt appends n in decimal.
+function appendInt(b: *StringBuilder, n: i64) -> {
+    let digits = heapAlloc(24);
+    let i: i64 = 23;
+    // Work with the negative value, which also covers the smallest i64.
+    let v = n;
+    if (v > 0) {
+        v = -v;
+    }
+    let more = true;
+    while (more) {
+        i = i - 1;
+        digits[i] = 48 - v % 10;
+        v = v / 10;
+        more = v != 0;
+    }
+    if (n < 0) {
+        i = i - 1;
+        digits[i] = 45;
+    }
+    appendBytes(b, digits + i, 23 - i);
+    heapFree(digits);
+}
+
+// builderLen returns the number of bytes built.
+function builderLen(b: *StringBuilder): i64 -> {
+    return b.len;
+}
+
+// builderString returns the bytes built so far. The string belongs to the
+// builder and changes with it; use toString for a copy.
+function builderString(b: *StringBuilder): string -> {
+    return b.buf;
+}
+
+// toString returns a copy of the bytes built, to be released with
+// mem.heapFree.
+function toString(b: *StringBuilder): string -> {
+    let s = heapAlloc(b.len + 1);
+    copy(s, b.buf, b.len);
+    return s;
+}
+
+// builderReset empties the builder, keeping its buffer.
+function builderReset(b: *StringBuilder) -> {
+    zero(b.buf, b.len);
+    b.len = 0;
+}
+
+// freeStringBuilder releases the builder.
+function freeStringBuilder(b: *StringBuilder) -> {
+    heapFree(b.buf);
+    heapFree(b as *u8);
+}
@@ -0,0 +1,11 @@
+// std/core/string.y
+// Basic helpers for null-terminated strings.
+
+// strlen returns the number of bytes before the null terminator.
+function strlen(str: string): i64 -> {
+    let i: i64 = 0;
+    while (str[i]) {
+        i = i + 1;
+    }
+    return i;
+}
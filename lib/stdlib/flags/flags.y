@@ -0,0 +1,490 @@
+// stdlib/flags - command-line flags
+// Implemented entirely in Y-lang. No external C runtime is required.
+//
+// A FlagSet describes the flags a program accepts. boolFlag, intFlag and
+// stringFlag each define one, with a long name used as --name and an
+// optional one-letter short name used as -n, and return a pointer to its
+// value, which holds the default until parse sees the flag:
+//
+//     let fs = flags.newFlagSet("greet", "[options] name...");
+//     let count = flags.intFlag(fs, "count", "n", 1, "times to greet");
+//     flags.parseOrExit(fs, os.args());
+//     ... *count ... flags.arg(fs, 0) ...
+//
+// parse accepts --name=value and --name value, -n value and -nvalue, and
+// bundles of short booleans such as -vq. A boolean takes no separate value,
+// but --name=false turns it off. Arguments that are not flags are
+// positional wherever they appear; "--" makes the rest positional, and a
+// lone "-" is positional too. --help and -h print the generated usage,
+// unless the program defines flags of those names.
+
+import "stdlib/core/string"
+import "stdlib/mem"
+import "stdlib/io"
+import "stdlib/os"
+import "stdlib/collections"
+
+// Kinds of flag.
+const BOOL = 0;
+const INT = 1;
+const STRING = 2;
+
+// Flag is one flag of a FlagSet. value points to the variable the
+// definition returned; defInt and defStr are its default, shown in the
+// usage.
+extern type Flag {
+    let name: string;
+    let letter: string;
+    let help: string;
+    let kind: i64;
+    let value: *i64;
+    let defInt: i64;
+    let defStr: string;
+    let seen: i64;
+}
+
+const FLAG_SIZE = 64;
+
+// FlagSet is the flags of a program, in the order they were defined, and
+// the positional arguments parse found.
+extern type FlagSet {
+    let name: string;
+    let usage: string;
+    let flags: *i64;
+    let count: i64;
+    let cap: i64;
+    let args: *i64;
+    let nargs: i64;
+    let argsCap: i64;
+    let helpSeen: i64;
+    let err: *collections.StringBuilder;
+}
+
+const SET_SIZE = 80;
+
+// newFlagSet returns an empty set for the program name. usage follows the
+// name in the first line of the help text, e.g. "[options] file...".
+function newFlagSet(name: string, usage: string): *FlagSet -> {
+    let fs = heapAlloc(SET_SIZE) as *FlagSet;
+    fs.name = name;
+    fs.usage = usage;
+    fs.err = collections.newStringBuilder();
+    return fs;
+}
+
+// flags_equal reports whether the n bytes at p spell s.
+function flags_equal(s: string, p: *u8, n: i64): bool -> {
+    if (strlen(s) != n) {
+        return false;
+    }
+    let i: i64 = 0;
+    while (i < n && s[i] == p[i]) {
+        i = i + 1;
+    }
+    return i == n;
+}
+
+// flags_define adds a flag to fs. letter is its short name, or "" for none.
+function flags_define(fs: *FlagSet, name: string, letter: string, help: string, kind: i64): *Flag -> {
+    if (fs.count == fs.cap) {
+        fs.cap = fs.cap * 2 + 8;
+        fs.flags = heapRealloc(fs.flags as *u8, fs.cap * 8) as *i64;
+    }
+    let f = heapAlloc(FLAG_SIZE) as *Flag;
+    f.name = name;
+    f.letter = letter;
+    f.help = help;
+    f.kind = kind;
+    f.value = heapAlloc(8) as *i64;
+    f.defStr = "";
+    fs.flags[fs.count] = f as i64;
+    fs.count = fs.count + 1;
+    return f;
+}
+
+// boolFlag defines a flag that is false unless given.
+function boolFlag(fs: *FlagSet, name: string, letter: string, help: string): *bool -> {
+    return flags_define(fs, name, letter, help, BOOL).value as *bool;
+}
+
+// intFlag defines a flag taking a decimal i64, which is def unless given.
+function intFlag(fs: *FlagSet, name: string, letter: string, def: i64, help: string): *i64 -> {
+    let f = flags_define(fs, name, letter, help, INT);
+    f.defInt = def;
+    f.value[0] = def;
+    return f.value;
+}
+
+// stringFlag defines a flag taking a string, which is def unless given.
+function stringFlag(fs: *FlagSet, name: string, letter: string, def: string, help: string): *string -> {
+    let f = flags_define(fs, name, letter, help, STRING);
+    f.defStr = def;
+    let p = f.value as *string;
+    *p = def;
+    return p;
+}
+
+// flags_lookup returns the flag of fs whose long name is the n bytes at p,
+// or null.
+function flags_lookup(fs: *FlagSet, p: *u8, n: i64): *Flag -> {
+    let i: i64 = 0;
+    while (i < fs.count) {
+        let f = fs.flags[i] as *Flag;
+        if (flags_equal(f.name, p, n)) {
+            return f;
+        }
+        i = i + 1;
+    }
+    return 0 as *Flag;
+}
+
+// flags_lookup_letter returns the flag of fs whose short name is the byte
+// c, or null.
+function flags_lookup_letter(fs: *FlagSet, c: i64): *Flag -> {
+    let i: i64 = 0;
+    while (i < fs.count) {
+        let f = fs.flags[i] as *Flag;
+        if (strlen(f.letter) == 1 && (f.letter[0] & 255) == c) {
+            return f;
+        }
+        i = i + 1;
+    }
+    return 0 as *Flag;
+}
+
+// flags_fail records the error made of the given parts and reports it on
+// io.stderr, prefixed with the program name.
+function flags_fail(fs: *FlagSet, msg: string, msg2: string = "", msg3: string = "", msg4: string = "") -> {
+    let b = fs.err;
+    collections.builderReset(b);
+    collections.append(b, msg);
+    collections.append(b, msg2);
+    collections.append(b, msg3);
+    collections.append(b, msg4);
+    io.writeString(io.stderr, fs.name);
+    io.writeString(io.stderr, ": ");
+    io.writeString(io.stderr, collections.builderString(b));
+    io.writeString(io.stderr, "\nRun '");
+    io.writeString(io.stderr, fs.name);
+    io.writeString(io.stderr, " --help' for usage.\n");
+}
+
+// flags_fail_value reports the value val, which does not suit f, naming f
+// as the user wrote it: -n when short, otherwise --name.
+function flags_fail_value(fs: *FlagSet, f: *Flag, val: string, short: bool) -> {
+    if (short) {
+        flags_fail(fs, "invalid value \"", val, "\" for -", f.letter);
+    } else {
+        flags_fail(fs, "invalid value \"", val, "\" for --", f.name);
+    }
+}
+
+// flags_parse_int stores the decimal i64 in s at out, returning false when
+// s is not one or does not fit.
+function flags_parse_int(s: string, out: *i64): bool -> {
+    let n = strlen(s);
+    let i: i64 = 0;
+    let negative = false;
+    if (n > 0 && (s[0] == 45 || s[0] == 43)) {
+        negative = s[0] == 45;
+        i = 1;
+    }
+    if (i == n) {
+        return false;
+    }
+    // Accumulate negatively, so that the minimum fits.
+    let v: i64 = 0;
+    let min: i64 = -9223372036854775807 - 1;
+    while (i < n) {
+        let d = s[i] - 48;
+        if (d < 0 || d > 9 || v < (min + d) / 10) {
+            return false;
+        }
+        v = v * 10 - d;
+        i = i + 1;
+    }
+    if (!negative) {
+        if (v == min) {
+            return false;
+        }
+        v = -v;
+    }
+    *out = v;
+    return true;
+}
+
+// flags_set gives f the value val, which the user wrote for f's short
+// name when short is set, reporting a value that does not suit its kind.
+function flags_set(fs: *FlagSet, f: *Flag, val: string, short: bool): bool -> {
+    let n = strlen(val);
+    if (f.kind == BOOL) {
+        let b = f.value as *bool;
+        if (flags_equal("true", val, n) || flags_equal("1", val, n)) {
+            *b = true;
+        } else if (flags_equal("false", val, n) || flags_equal("0", val, n)) {
+            *b = false;
+        } else {
+            flags_fail_value(fs, f, val, short);
+            return false;
+        }
+    } else if (f.kind == INT) {
+        if (!flags_parse_int(val, f.value)) {
+            flags_fail_value(fs, f, val, short);
+            return false;
+        }
+    } else {
+        let p = f.value as *string;
+        *p = val;
+    }
+    f.seen = 1;
+    return true;
+}
+
+// flags_add_arg appends the positional argument a.
+function flags_add_arg(fs: *FlagSet, a: string) -> {
+    if (fs.nargs == fs.argsCap) {
+        fs.argsCap = fs.argsCap * 2 + 8;
+        fs.args = heapRealloc(fs.args as *u8, fs.argsCap * 8) as *i64;
+    }
+    fs.args[fs.nargs] = a as i64;
+    fs.nargs = fs.nargs + 1;
+}
+
+// flags_help prints the usage to io.stdout and records that help was
+// asked for.
+function flags_help(fs: *FlagSet) -> {
+    let text = usage(fs);
+    io.writeString(io.stdout, text);
+    heapFree(text);
+    fs.helpSeen = 1;
+}
+
+// parse sets the flags of fs from args, which start with the program name
+// as os.args() does, and collects the other arguments as positional. It
+// returns false when an argument is malformed, after reporting it on
+// io.stderr, or when --help printed the usage.
+function parse(fs: *FlagSet, args: []string): bool -> {
+    let ok = true;
+    let flagsDone = false;
+    let i: i64 = 1;
+    while (ok && i < args.length) {
+        let a = args[i];
+        let n = strlen(a);
+        if (flagsDone || n < 2 || a[0] != 45) {
+            flags_add_arg(fs, a);
+        } else if (n == 2 && a[1] == 45) {
+            flagsDone = true;
+        } else if (a[1] == 45) {
+            // --name, --name=value or --name value
+            let nameLen = n - 2;
+            let eq: i64 = 2;
+            while (eq < n && a[eq] != 61) {
+                eq = eq + 1;
+            }
+            if (eq < n) {
+                nameLen = eq - 2;
+            }
+            let f = flags_lookup(fs, a + 2, nameLen);
+            if ((f as i64) == 0) {
+                if (flags_equal("help", a + 2, nameLen)) {
+                    flags_help(fs);
+                } else {
+                    flags_fail(fs, "unknown flag ", a);
+                }
+                ok = false;
+            } else if (eq < n) {
+                ok = flags_set(fs, f, a + eq + 1, false);
+            } else if (f.kind == BOOL) {
+                ok = flags_set(fs, f, "true", false);
+            } else if (i + 1 < args.length) {
+                i = i + 1;
+                ok = flags_set(fs, f, args[i], false);
+            } else {
+                flags_fail(fs, "flag ", a, " needs a value");
+                ok = false;
+            }
+        } else {
+            // -n, -nvalue, -n value or a bundle of booleans such as -vq
+            let j: i64 = 1;
+            while (ok && j < n) {
+                let c = a[j] & 255;
+                let f = flags_lookup_letter(fs, c);
+                if ((f as i64) == 0) {
+                    if (c == 104) {
+                        flags_help(fs);
+                    } else {
+                        let letter = heapAlloc(2);
+                        letter[0] = c;
+                        flags_fail(fs, "unknown flag -", letter);
+                        heapFree(letter);
+                    }
+                    ok = false;
+                } else if (f.kind == BOOL) {
+                    ok = flags_set(fs, f, "true", true);
+                    j = j + 1;
+                } else {
+                    if (j + 1 < n) {
+                        ok = flags_set(fs, f, a + j + 1, true);
+                    } else if (i + 1 < args.length) {
+                        i = i + 1;
+                        ok = flags_set(fs, f, args[i], true);
+                    } else {
+                        flags_fail(fs, "flag -", f.letter, " needs a value");
+                        ok = false;
+                    }
+                    j = n;
+                }
+            }
+        }
+        i = i + 1;
+    }
+    return ok;
+}
+
+// parseOrExit parses args like parse, exiting with status 0 after --help
+// and 2 after an error.
+function parseOrExit(fs: *FlagSet, args: []string) -> {
+    if (!parse(fs, args)) {
+        if (fs.helpSeen == 1) {
+            os.exit(0);
+        }
+        os.exit(2);
+    }
+}
+
+// narg returns the number of positional arguments.
+function narg(fs: *FlagSet): i64 -> {
+    return fs.nargs;
+}
+
+// arg returns positional argument i, or "" when there are not that many.
+function arg(fs: *FlagSet, i: i64): string -> {
+    if (i < 0 || i >= fs.nargs) {
+        return "";
+    }
+    return fs.args[i] as string;
+}
+
+// isSet reports whether the flag of the long name was given, even with its
+// default value.
+function isSet(fs: *FlagSet, name: string): bool -> {
+    let f = flags_lookup(fs, name, strlen(name));
+    return (f as i64) != 0 && f.seen == 1;
+}
+
+// helpRequested reports whether parse stopped at --help.
+function helpRequested(fs: *FlagSet): bool -> {
+    return fs.helpSeen == 1;
+}
+
+// lastError returns the error parse last reported, or "".
+function lastError(fs: *FlagSet): string -> {
+    return collections.builderString(fs.err);
+}
+
+// flags_left writes the left column of the usage line of f to b: its
+// names and, unless a boolean, the kind of value it takes.
+function flags_left(b: *collections.StringBuilder, f: *Flag) -> {
+    if (strlen(f.letter) > 0) {
+        collections.append(b, "  -");
+        collections.append(b, f.letter);
+        collections.append(b, ", --");
+    } else {
+        collections.append(b, "      --");
+    }
+    collections.append(b, f.name);
+    if (f.kind == INT) {
+        collections.append(b, " <int>");
+    } else if (f.kind == STRING) {
+        collections.append(b, " <string>");
+    }
+}
+
+// flags_left_width returns the length of the left column flags_left
+// writes for f.
+function flags_left_width(f: *Flag): i64 -> {
+    let w = 8 + strlen(f.name);
+    if (f.kind == INT) {
+        w = w + 6;
+    } else if (f.kind == STRING) {
+        w = w + 9;
+    }
+    return w;
+}
+
+// flags_pad appends spaces to b up to column width of the line started at
+// start.
+function flags_pad(b: *collections.StringBuilder, start: i64, width: i64) -> {
+    while (collections.builderLen(b) - start < width) {
+        collections.appendByte(b, 32);
+    }
+}
+
+// usage returns the help text of fs: the usage line, then each flag with
+// its help and default, in the order they were defined, e.g.
+//
+//     Usage: greet [options] name...
+//
+//     Options:
+//       -n, --count <int>  times to greet (default 1)
+//       -h, --help         show this help
+//
+// The text is allocated with mem.heapAlloc.
+function usage(fs: *FlagSet): string -> {
+    let b = collections.newStringBuilder();
+    collections.append(b, "Usage: ");
+    collections.append(b, fs.name);
+    if (strlen(fs.usage) > 0) {
+        collections.append(b, " ");
+        collections.append(b, fs.usage);
+    }
+    collections.append(b, "\n\nOptions:\n");
+
+    // --help is listed unless the program took its names.
+    let helpName = (flags_lookup(fs, "help", 4) as i64) == 0;
+    let helpLetter = (flags_lookup_letter(fs, 104) as i64) == 0;
+
+    // The help column starts two spaces after the longest names.
+    let width: i64 = 16;
+    let i: i64 = 0;
+    while (i < fs.count) {
+        let w = flags_left_width(fs.flags[i] as *Flag) + 2;
+        if (w > width) {
+            width = w;
+        }
+        i = i + 1;
+    }
+    i = 0;
+    let line: i64 = 0;
+    while (i < fs.count) {
+        let f = fs.flags[i] as *Flag;
+        line = collections.builderLen(b);
+        flags_left(b, f);
+        flags_pad(b, line, width);
+        collections.append(b, f.help);
+        if (f.kind == INT && f.defInt != 0) {
+            collections.append(b, " (default ");
+            collections.appendInt(b, f.defInt);
+            collections.append(b, ")");
+        } else if (f.kind == STRING && strlen(f.defStr) > 0) {
+            collections.append(b, " (default \"");
+            collections.append(b, f.defStr);
+            collections.append(b, "\")");
+        }
+        collections.append(b, "\n");
+        i = i + 1;
+    }
+    if (helpName) {
+        line = collections.builderLen(b);
+        if (helpLetter) {
+            collections.append(b, "  -h, --help");
+        } else {
+            collections.append(b, "      --help");
+        }
+        flags_pad(b, line, width);
+        collections.append(b, "show this help\n");
+    }
+    let text = collections.toString(b);
+    collections.freeStringBuilder(b);
+    return text;
+}
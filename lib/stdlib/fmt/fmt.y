@@ -0,0 +1,392 @@
+// stdlib/fmt - printf-style formatting
+// Implemented entirely in Y-lang; output goes through the buffered writers of
+// stdlib/io.
+// No external C runtime is required.
+//
+// Verbs:
+//   %d  decimal integer            %x  hexadecimal integer, a-f
+//   %s  string                     %X  hexadecimal integer, A-F
+//   %f  float (default precision 6)
+//   %v  natural format of the argument's kind
+//   %%  a literal percent sign
+//
+// Flags, width and precision go between '%' and the verb: "%5d" pads to five
+// columns, "%-5s" left-aligns, "%05d" pads with zeros, "%.2f" sets the number
+// of decimals and "%.3s" truncates a string. A verb without a matching
+// argument is rendered as "%!d(MISSING)", and an unknown verb as
+// "%!q(BADVERB)", using up its argument. Floats must be smaller than 2^63 in
+// magnitude; NaN and infinities print as "NaN", "+Inf" and "-Inf".
+//
+// Directives are rendered into a scratch buffer each thread keeps between
+// calls, so printf and fprintf allocate nothing once it has grown to fit.
+//
+// Argument kinds (see the compiler's boxing of any):
+//   0 nil, 1 int, 2 float, 3 string, 4 bool, 5 pointer
+
+import "stdlib/core/string"
+import "stdlib/io"
+import "stdlib/mem"
+
+// fmt_count_digits returns the number of digits of -n in base. n is zero or
+// negative, so that the magnitude of the most negative i64 fits.
+function fmt_count_digits(n: i64, base: i64): i64 -> {
+    let count: i64 = 1;
+    while (n <= -base) {
+        n = n / base;
+        count = count + 1;
+    }
+    return count;
+}
+
+// fmt_put_digits writes the lowest count digits of -n in base into buf at
+// pos, zero-filled on the left, and returns the position after them. n is
+// zero or negative, as for fmt_count_digits; digits above 9 are upper case
+// when upper is 1.
+function fmt_put_digits(buf: *u8, pos: i64, n: i64, base: i64, count: i64, upper: i64): i64 -> {
+    let i: i64 = pos + count - 1;
+    while (i >= pos) {
+        let d: i64 = -(n % base);
+        if (d < 10) {
+            buf[i] = 48 + d;
+        } else if (upper) {
+            buf[i] = 55 + d;
+        } else {
+            buf[i] = 87 + d;
+        }
+        n = n / base;
+        i = i - 1;
+    }
+    return pos + count;
+}
+
+// fmt_put_repeat writes count copies of the byte c into buf at pos.
+function fmt_put_repeat(buf: *u8, pos: i64, c: i64, count: i64): i64 -> {
+    let i: i64 = 0;
+    while (i < count) {
+        buf[pos + i] = c;
+        i = i + 1;
+    }
+    return pos + count;
+}
+
+// fmt_put_str writes the first count bytes of s into buf at pos.
+function fmt_put_str(buf: *u8, pos: i64, s: string, count: i64): i64 -> {
+    let i: i64 = 0;
+    while (i < count) {
+        buf[pos + i] = s[i];
+        i = i + 1;
+    }
+    return pos + count;
+}
+
+// fmt_buf is the scratch buffer of this thread that fmt_render writes into,
+// of capacity fmt_cap. It is kept between calls.
+thread_local let fmt_buf: *u8 = 0 as *u8;
+thread_local let fmt_cap: i64 = 0;
+
+// fmt_reserve grows fmt_buf to hold at least n bytes and returns it.
+function fmt_reserve(n: i64): *u8 -> {
+    if (n > fmt_cap) {
+        let grown: i64 = fmt_cap * 2;
+        if (grown < 256) {
+            grown = 256;
+        }
+        if (grown < n) {
+            grown = n;
+        }
+        fmt_buf = heapRealloc(fmt_buf, grown);
+        fmt_cap = grown;
+    }
+    return fmt_buf;
+}
+
+// fmt_render writes f with every verb replaced by the next argument into
+// fmt_buf at pos and returns the position after it.
+function fmt_render(pos: i64, f: string, args: ...any): i64 -> {
+    let buf = fmt_reserve(pos + 1);
+    let n: i64 = pos;
+    let i: i64 = 0;
+    let ai: i64 = 0;
+    let flen: i64 = strlen(f);
+
+    while (i < flen) {
+        let c: i64 = f[i];
+        if (c != 37 || i + 1 >= flen) {
+            // Plain byte; a trailing '%' is copied as-is.
+            if (n + 1 > fmt_cap) {
+                buf = fmt_reserve(n + 1);
+            }
+            buf[n] = c;
+            n = n + 1;
+            i = i + 1;
+        } else {
+            i = i + 1;
+
+            // Flags: '-' left-aligns, '0' pads numbers with zeros.
+            let left: i64 = 0;
+            let zero: i64 = 0;
+            while (f[i] == 45 || f[i] == 48) {
+                if (f[i] == 45) {
+                    left = 1;
+                } else {
+                    zero = 1;
+                }
+                i = i + 1;
+            }
+
+            let width: i64 = 0;
+            while (f[i] >= 48 && f[i] <= 57) {
+                width = width * 10 + f[i] - 48;
+                i = i + 1;
+            }
+
+            let prec: i64 = -1;
+            if (f[i] == 46) {
+                prec = 0;
+                i = i + 1;
+                while (f[i] >= 48 && f[i] <= 57) {
+                    prec = prec * 10 + f[i] - 48;
+                    i = i + 1;
+                }
+            }
+
+            let verb: i64 = f[i];
+            i = i + 1;
+
+            // Every directive is reduced to one of three shapes before it is
+            // padded and written: 0 text, 1 integer, 2 float.
+            let shape: i64 = 0;
+            let text: string = "";
+            let tlen: i64 = 0;
+            let neg: i64 = 0;
+            let mag: i64 = 0;
+            let frac: i64 = 0;
+            let fprec: i64 = 0;
+            let base: i64 = 10;
+            let upper: i64 = 0;
+            let hexPrefix: i64 = 0;
+            let isFloat: i64 = 0;
+            let fv: f64 = 0.0;
+            // bad is 1 when the verb is shown as "%!" + verb + text.
+            let bad: i64 = 0;
+
+            if (verb == 37) {
+                text = "%";
+                tlen = 1;
+            } else if (verb == 0) {
+                text = "%!(NOVERB)";
+                tlen = 10;
+            } else if (ai >= args.length) {
+                bad = 1;
+                text = "(MISSING)";
+                tlen = 9;
+            } else if (verb != 100 && verb != 120 && verb != 88 && verb != 115 && verb != 102 && verb != 118) {
+                ai = ai + 1;
+                bad = 1;
+                text = "(BADVERB)";
+                tlen = 9;
+            } else {
+                let arg = args[ai];
+                ai = ai + 1;
+                let kind: i64 = arg.kind;
+
+                if (kind == 3) {
+                    text = arg.string;
+                    tlen = strlen(text);
+                    if (prec >= 0 && prec < tlen) {
+                        tlen = prec;
+                    }
+                } else if (kind == 4) {
+                    if (arg.bool) {
+                        text = "true";
+                        tlen = 4;
+                    } else {
+                        text = "false";
+                        tlen = 5;
+                    }
+                } else if (kind == 0) {
+                    text = "<nil>";
+                    tlen = 5;
+                } else if (kind == 2) {
+                    fv = arg.float;
+                    isFloat = 1;
+                } else {
+                    let v: i64 = arg.int;
+                    if (verb == 102) {
+                        fv = v;
+                        isFloat = 1;
+                    } else {
+                        shape = 1;
+                        // The magnitude is kept negative, which also holds
+                        // the most negative i64.
+                        if (v < 0) {
+                            neg = 1;
+                            mag = v;
+                        } else {
+                            mag = -v;
+                        }
+                        if (verb == 120 || verb == 88) {
+                            base = 16;
+                            if (verb == 88) {
+                                upper = 1;
+                            }
+                        } else if (kind == 5 && verb != 100) {
+                            base = 16;
+                            hexPrefix = 1;
+                        }
+                    }
+                }
+
+                if (isFloat) {
+                    if (fv < 0.0) {
+                        neg = 1;
+                        fv = -fv;
+                    }
+                    if (fv != fv) {
+                        text = "NaN";
+                        tlen = 3;
+                    } else if (fv - fv != 0.0) {
+                        if (neg) {
+                            text = "-Inf";
+                        } else {
+                            text = "+Inf";
+                        }
+                        tlen = 4;
+                    } else if (verb == 100 || verb == 120 || verb == 88) {
+                        shape = 1;
+                        mag = fv;
+                        mag = -mag;
+                        if (verb != 100) {
+                            base = 16;
+                            if (verb == 88) {
+                                upper = 1;
+                            }
+                        }
+                    } else {
+                        shape = 2;
+                        fprec = prec;
+                        if (fprec < 0) {
+                            fprec = 6;
+                        }
+                        if (fprec > 15) {
+                            fprec = 15;
+                        }
+                        let scale: i64 = 1;
+                        let k: i64 = 0;
+                        while (k < fprec) {
+                            scale = scale * 10;
+                            k = k + 1;
+                        }
+                        mag = fv;
+                        frac = (fv - mag) * scale + 0.5;
+                        if (frac >= scale) {
+                            mag = mag + 1;
+                            frac = frac - scale;
+                        }
+                        // %v drops trailing zeros unless a precision was given.
+                        if (verb == 118 && prec < 0) {
+                            while (fprec > 0 && frac % 10 == 0) {
+                                frac = frac / 10;
+                                fprec = fprec - 1;
+                            }
+                        }
+                        mag = -mag;
+                        frac = -frac;
+                    }
+                }
+            }
+
+            let digits: i64 = 0;
+            let blen: i64 = tlen + bad * 3;
+            if (shape != 0) {
+                digits = fmt_count_digits(mag, base);
+                blen = neg + hexPrefix * 2 + digits;
+                if (shape == 2 && fprec > 0) {
+                    blen = blen + 1 + fprec;
+                }
+            }
+            let pad: i64 = 0;
+            if (width > blen) {
+                pad = width - blen;
+            }
+
+            if (n + pad + blen > fmt_cap) {
+                buf = fmt_reserve(n + pad + blen);
+            }
+
+            if (left == 0 && (zero == 0 || shape == 0)) {
+                n = fmt_put_repeat(buf, n, 32, pad);
+            }
+            if (bad) {
+                n = fmt_put_str(buf, n, "%!", 2);
+                buf[n] = verb;
+                n = n + 1;
+            }
+            if (shape == 0) {
+                n = fmt_put_str(buf, n, text, tlen);
+            } else {
+                if (neg) {
+                    buf[n] = 45;
+                    n = n + 1;
+                }
+                if (hexPrefix) {
+                    n = fmt_put_str(buf, n, "0x", 2);
+                }
+                if (left == 0 && zero == 1) {
+                    n = fmt_put_repeat(buf, n, 48, pad);
+                }
+                n = fmt_put_digits(buf, n, mag, base, digits, upper);
+                if (shape == 2 && fprec > 0) {
+                    buf[n] = 46;
+                    n = n + 1;
+                    n = fmt_put_digits(buf, n, frac, 10, fprec, 0);
+                }
+            }
+            if (left == 1) {
+                n = fmt_put_repeat(buf, n, 32, pad);
+            }
+        }
+    }
+
+    return n;
+}
+
+// format returns f with every verb replaced by the next argument. The result
+// is null-terminated and obtained from mem.heapAlloc; release it with
+// heapFree.
+function format(f: string, args: ...any): string -> {
+    let n = fmt_render(0, f, args);
+    let s = heapAlloc(n + 1);
+    copy(s, fmt_buf, n);
+    return s;
+}
+
+// fprintf formats f like format and writes the result to w.
+function fprintf(w: *io.Writer, f: string, args: ...any) -> {
+    let n = fmt_render(0, f, args);
+    io.write(w, fmt_buf, n);
+}
+
+// printf formats f like format and writes the result to io.stdout.
+function printf(f: string, args: ...any) -> {
+    fprintf(io.stdout, f, args);
+}
+
+// println writes the %v form of each argument to io.stdout, separated by
+// single spaces and followed by a newline.
+function println(args: ...any) -> {
+    let n: i64 = 0;
+    let i: i64 = 0;
+    while (i < args.length) {
+        if (i > 0) {
+            let buf = fmt_reserve(n + 1);
+            buf[n] = 32;
+            n = n + 1;
+        }
+        n = fmt_render(n, "%v", args[i]);
+        i = i + 1;
+    }
+    let buf = fmt_reserve(n + 1);
+    buf[n] = 10;
+    io.write(io.stdout, buf, n + 1);
+}
@@ -1,104 +1,499 @@
 // stdlib/fs - filesystem operations
-// Implemented entirely in Y-lang via Linux x86-64 syscalls.
+// Implemented entirely in Y-lang via Linux syscalls.
 // No external C runtime is required.
 //
-// Syscall numbers (x86-64 Linux):
-//   SYS_write     =   1
-//   SYS_close     =   3
-//   SYS_mmap      =   9
-//   SYS_munmap    =  11
-//   SYS_getdents64= 217
-//   SYS_openat    = 257
-//
-// Constants:
-//   AT_FDCWD   = -100   (open relative to cwd)
-//   O_RDONLY   =    0
-//   PROT_READ  =    1
-//   PROT_WRITE =    2
-//   MAP_PRIVATE =   2
-//   MAP_ANONYMOUS= 32   (0x20)
-//
-// linux_dirent64 layout (each entry in getdents64 buffer):
-//   offset  0: d_ino    (8 bytes)
-//   offset  8: d_off    (8 bytes)
-//   offset 16: d_reclen (2 bytes, little-endian; low byte is enough for dirs < 4 KiB)
-//   offset 18: d_type   (1 byte)
-//   offset 19: d_name   (null-terminated string)
-
-// listdir prints the name of every entry in the current working directory,
-// one per line, to stdout.  The synthetic entries "." and ".." are omitted.
-function listdir() -> {
-    // Allocate a 4096-byte scratch buffer via mmap(2).
-    // mmap(addr=0, length=4096, prot=PROT_READ|PROT_WRITE=3,
-    //      flags=MAP_PRIVATE|MAP_ANONYMOUS=34, fd=-1, offset=0)
-    let buf = syscall(9, 0, 4096, 3, 34, -1, 0);
-
-    // MAP_FAILED is returned as -1 (0xFFFFFFFFFFFFFFFF as i64).
-    // Since signed comparisons treat that as negative, buf < 0 detects failure.
-    if (buf < 0) {
-        return 1;
-    }
-
-    // Open the current directory.
-    // openat(AT_FDCWD=-100, ".", O_RDONLY=0)
-    let fd = syscall(257, -100, ".", 0, 0, 0, 0);
-
-    // A negative fd means the open failed; clean up and bail out.
-    if (fd < 0) {
-        syscall(11, buf, 4096, 0, 0, 0, 0);
-        return 1;
+// Every operation returns a non-negative value on success and a negative
+// error code (-errno, compare with the sys.E* constants negated or use the
+// is* helpers) on failure. The failure is also recorded in lastError(), with
+// the operation and the path involved, for reporting.
+
+import "stdlib/core/string"
+import "stdlib/io"
+import "stdlib/mem"
+import "stdlib/path"
+import "stdlib/sys"
+
+// Permission bits used when open creates a file and by mkdir (0644, 0755)
+const DEFAULT_FILE_MODE = 420;
+const DEFAULT_DIR_MODE = 493;
+
+// statx(2) mask
+const STATX_BASIC_STATS = 0x7ff;
+
+// File types, as reported for directory entries and by fileType
+const DT_UNKNOWN = 0;
+const DT_FIFO = 1;
+const DT_CHR = 2;
+const DT_DIR = 4;
+const DT_BLK = 6;
+const DT_REG = 8;
+const DT_LNK = 10;
+const DT_SOCK = 12;
+
+// Size of the scratch buffer getdents64 fills.
+const DIRENT_BUF_SIZE = 4096;
+
+// Bytes to allocate for a Stat; the kernel may fill more than the fields
+// declared below.
+const STAT_SIZE = 256;
+
+// Error describes a failed operation: its name, the path it was given (empty
+// for operations on a descriptor) and the negative error code it returned.
+extern type Error {
+    let code: i64;
+    let op: string;
+    let path: string;
+}
+
+// Stat is the struct statx(2) fills. Allocate STAT_SIZE bytes for it, e.g.
+// `let st = alloc(STAT_SIZE) as *Stat;`.
+extern type Stat {
+    let mask: u32;
+    let blksize: u32;
+    let attributes: u64;
+    let nlink: u32;
+    let uid: u32;
+    let gid: u32;
+    let mode: u16;
+    let spare0: u16;
+    let ino: u64;
+    let size: u64;
+    let blocks: u64;
+    let attributes_mask: u64;
+    let atime_sec: i64;
+    let atime_nsec: u32;
+    let atime_reserved: i32;
+    let btime_sec: i64;
+    let btime_nsec: u32;
+    let btime_reserved: i32;
+    let ctime_sec: i64;
+    let ctime_nsec: u32;
+    let ctime_reserved: i32;
+    let mtime_sec: i64;
+    let mtime_nsec: u32;
+    let mtime_reserved: i32;
+    let rdev_major: u32;
+    let rdev_minor: u32;
+    let dev_major: u32;
+    let dev_minor: u32;
+}
+
+// linux_dirent64 is one record of the buffer getdents64 fills. The kernel
+// lays it out as C would; d_name is the first byte of the null-terminated
+// name, which runs on past the end of the struct.
+extern type linux_dirent64 {
+    let d_ino: u64;
+    let d_off: i64;
+    let d_reclen: u16;
+    let d_type: u8;
+    let d_name: u8;
+}
+
+// Dir is an open directory being read with readdir. The getdents64 buffer
+// follows it in the same allocation.
+extern type Dir {
+    let fd: i64;
+    let buf: *u8;
+    let pos: i64;
+    let end: i64;
+    // kind is the DT_* type of the entry readdir returned last.
+    let kind: i64;
+    // err is the error code of a failed read, 0 otherwise.
+    let err: i64;
+    let path: string;
+}
+
+// Room for the Dir header ahead of its buffer.
+const DIR_HEADER_SIZE = 64;
+
+let fs_last_error: *Error = alloc(64) as *Error;
+
+// lastError returns the most recent failure of an operation in this module.
+// Successful operations leave it unchanged.
+function lastError(): *Error -> {
+    return fs_last_error;
+}
+
+// fs_fail records a failure of op on name in lastError and returns code.
+function fs_fail(code: i64, op: string, name: string): i64 -> {
+    let e = fs_last_error;
+    e.code = code;
+    e.op = op;
+    e.path = name;
+    return code;
+}
+
+// fs_check records result as a failure of op on name when it is negative and
+// returns it unchanged.
+function fs_check(result: i64, op: string, name: string): i64 -> {
+    if (result < 0) {
+        return fs_fail(result, op, name);
+    }
+    return result;
+}
+
+// errorString returns a short description of an error code.
+function errorString(code: i64): string -> {
+    if (code >= 0) {
+        return "no error";
     }
+    let errno = 0 - code;
+    if (errno == sys.EPERM) { return "operation not permitted"; }
+    if (errno == sys.ENOENT) { return "no such file or directory"; }
+    if (errno == sys.EIO) { return "input/output error"; }
+    if (errno == sys.EBADF) { return "bad file descriptor"; }
+    if (errno == sys.EACCES) { return "permission denied"; }
+    if (errno == sys.EEXIST) { return "file exists"; }
+    if (errno == sys.EXDEV) { return "invalid cross-device link"; }
+    if (errno == sys.ENOTDIR) { return "not a directory"; }
+    if (errno == sys.EISDIR) { return "is a directory"; }
+    if (errno == sys.EINVAL) { return "invalid argument"; }
+    if (errno == sys.ENOSPC) { return "no space left on device"; }
+    if (errno == sys.EROFS) { return "read-only file system"; }
+    if (errno == sys.ENAMETOOLONG) { return "file name too long"; }
+    if (errno == sys.ENOTEMPTY) { return "directory not empty"; }
+    if (errno == sys.ELOOP) { return "too many levels of symbolic links"; }
+    return "unknown error";
+}
 
-    // Read directory entries into buf.
-    // getdents64(fd, buf, 4096)
-    let nbytes = syscall(217, fd, buf, 4096, 0, 0, 0);
+// isNotExist reports whether code means the file or directory is missing.
+function isNotExist(code: i64): bool -> {
+    return code == 0 - sys.ENOENT;
+}
 
-    // Walk each linux_dirent64 record in the buffer.
-    let pos = 0;
-    while (pos < nbytes) {
-        // d_reclen is a little-endian u16 at offset 16 within the entry.
-        // For directories small enough to fit in a 4 KiB buffer the low byte
-        // is sufficient (reclen <= 255).
-        let reclen = buf[pos + 16];
+// isExist reports whether code means the file or directory already exists.
+function isExist(code: i64): bool -> {
+    return code == 0 - sys.EEXIST || code == 0 - sys.ENOTEMPTY;
+}
 
-        // d_name begins at offset 19; compute its start address.
-        let name_addr = buf + pos + 19;
+// isPermission reports whether code means access was denied.
+function isPermission(code: i64): bool -> {
+    return code == 0 - sys.EACCES || code == 0 - sys.EPERM;
+}
+
+// open opens name with flags (O_* constants added together) and returns the
+// file descriptor. mode sets the permissions of a file created by O_CREAT.
+function open(name: string, flags: i64, mode: i64 = DEFAULT_FILE_MODE): i64 -> {
+    return fs_check(syscall(SYS.openat, sys.AT_FDCWD, name, flags + sys.O_CLOEXEC, mode, 0, 0), "open", name);
+}
 
-        // Measure the name length (inline strlen).
-        let name_len = 0;
-        while (name_addr[name_len]) {
-            name_len = name_len + 1;
+// create opens name for writing, creating it or truncating it to empty.
+function create(name: string): i64 -> {
+    return open(name, sys.O_WRONLY + sys.O_CREAT + sys.O_TRUNC);
+}
+
+// read reads up to count bytes from fd into buf and returns the number read,
+// 0 at the end of the file.
+function read(fd: i64, buf: *u8, count: i64): i64 -> {
+    return fs_check(syscall(SYS.read, fd, buf, count, 0, 0, 0), "read", "");
+}
+
+// write writes the count bytes of buf to fd, retrying after short writes, and
+// returns count.
+function write(fd: i64, buf: *u8, count: i64): i64 -> {
+    let done = 0;
+    while (done < count) {
+        let n = syscall(SYS.write, fd, buf + done, count - done, 0, 0, 0);
+        if (n < 0) {
+            return fs_fail(n, "write", "");
         }
+        done = done + n;
+    }
+    return done;
+}
 
-        // Skip the pseudo-entries "." (len==1) and ".." (len==2) whose first
-        // character is '.'.
-        let is_dot = 0;
-        if (name_len == 1) {
-            if (name_addr[0] == 46) {
-                is_dot = 1;
-            }
+// close releases fd and returns 0.
+function close(fd: i64): i64 -> {
+    return fs_check(syscall(SYS.close, fd, 0, 0, 0, 0, 0), "close", "");
+}
+
+// readFile reads the whole of name and stores its contents, null-terminated
+// and in memory obtained from mem.alloc, in *contents. It returns the number
+// of bytes read.
+function readFile(name: string, contents: *string): i64 -> {
+    let fd = open(name, sys.O_RDONLY);
+    if (fd < 0) {
+        return fd;
+    }
+    let cap = 4096;
+    let used = 0;
+    let buf = alloc(cap);
+    let n = 1;
+    while (n > 0) {
+        // Keep a byte free for the terminator.
+        if (used == cap - 1) {
+            let bigger = alloc(cap * 2);
+            copy(bigger, buf, used);
+            free(buf, cap);
+            buf = bigger;
+            cap = cap * 2;
+        }
+        n = syscall(SYS.read, fd, buf + used, cap - 1 - used, 0, 0, 0);
+        if (n < 0) {
+            syscall(SYS.close, fd, 0, 0, 0, 0, 0);
+            free(buf, cap);
+            return fs_fail(n, "read", name);
         }
-        if (name_len == 2) {
-            if (name_addr[0] == 46) {
-                if (name_addr[1] == 46) {
-                    is_dot = 1;
-                }
+        used = used + n;
+    }
+    syscall(SYS.close, fd, 0, 0, 0, 0, 0);
+    *contents = buf;
+    return used;
+}
+
+// writeFile replaces the contents of name, creating it if needed, with the
+// count bytes of buf. It returns 0.
+function writeFile(name: string, buf: *u8, count: i64): i64 -> {
+    let fd = create(name);
+    if (fd < 0) {
+        return fd;
+    }
+    let n = write(fd, buf, count);
+    let closed = close(fd);
+    if (n < 0) {
+        return fs_fail(n, "write", name);
+    }
+    return closed;
+}
+
+// stat fills st with the status of name, following symbolic links.
+function stat(name: string, st: *Stat): i64 -> {
+    return fs_check(syscall(SYS.statx, sys.AT_FDCWD, name, 0, STATX_BASIC_STATS, st, 0), "stat", name);
+}
+
+// lstat is stat, but describes a symbolic link itself rather than its target.
+function lstat(name: string, st: *Stat): i64 -> {
+    return fs_check(syscall(SYS.statx, sys.AT_FDCWD, name, sys.AT_SYMLINK_NOFOLLOW, STATX_BASIC_STATS, st, 0), "lstat", name);
+}
+
+// fileType returns the DT_* type of the file st describes.
+function fileType(st: *Stat): i64 -> {
+    return (st.mode as u64) / 4096;
+}
+
+// isDir reports whether st describes a directory.
+function isDir(st: *Stat): bool -> {
+    return fileType(st) == DT_DIR;
+}
+
+// isRegular reports whether st describes a regular file.
+function isRegular(st: *Stat): bool -> {
+    return fileType(st) == DT_REG;
+}
+
+// mkdir creates the directory name with permissions mode.
+function mkdir(name: string, mode: i64 = DEFAULT_DIR_MODE): i64 -> {
+    return fs_check(syscall(SYS.mkdirat, sys.AT_FDCWD, name, mode, 0, 0, 0), "mkdir", name);
+}
+
+// rename moves from to to, replacing to if it exists.
+function rename(from: string, to: string): i64 -> {
+    return fs_check(syscall(SYS.renameat2, sys.AT_FDCWD, from, sys.AT_FDCWD, to, 0, 0), "rename", from);
+}
+
+// unlink removes the file name.
+function unlink(name: string): i64 -> {
+    return fs_check(syscall(SYS.unlinkat, sys.AT_FDCWD, name, 0, 0, 0, 0), "unlink", name);
+}
+
+// rmdir removes the empty directory name.
+function rmdir(name: string): i64 -> {
+    return fs_check(syscall(SYS.unlinkat, sys.AT_FDCWD, name, sys.AT_REMOVEDIR, 0, 0, 0), "rmdir", name);
+}
+
+// opendir opens the directory name for reading with readdir. It returns a
+// null pointer on failure.
+function opendir(name: string): *Dir -> {
+    let fd = open(name, sys.O_RDONLY + sys.O_DIRECTORY);
+    if (fd < 0) {
+        return 0 as *Dir;
+    }
+    let d = alloc(DIR_HEADER_SIZE + DIRENT_BUF_SIZE) as *Dir;
+    d.fd = fd;
+    d.buf = (d as *u8) + DIR_HEADER_SIZE;
+    d.pos = 0;
+    d.end = 0;
+    d.kind = DT_UNKNOWN;
+    d.err = 0;
+    d.path = name;
+    return d;
+}
+
+// fs_is_dot reports whether name is "." or "..".
+function fs_is_dot(name: *u8): bool -> {
+    if (name[0] != 46) {
+        return false;
+    }
+    return name[1] == 0 || (name[1] == 46 && name[2] == 0);
+}
+
+// readdir returns the name of the next entry of d, skipping "." and "..", and
+// sets d.kind to its type. The name is valid until the next call. It returns
+// "" once every entry has been read, or when reading fails, in which case
+// d.err holds the error code.
+function readdir(d: *Dir): string -> {
+    while (true) {
+        if (d.pos >= d.end) {
+            let n = syscall(SYS.getdents64, d.fd, d.buf, DIRENT_BUF_SIZE, 0, 0, 0);
+            if (n <= 0) {
+                d.err = fs_check(n, "readdir", d.path);
+                return "";
             }
+            d.pos = 0;
+            d.end = n;
         }
+        let e: *linux_dirent64 = (d.buf + d.pos) as *linux_dirent64;
+        d.pos = d.pos + e.d_reclen as u64;
+        let name: *u8 = &e.d_name;
+        if (!fs_is_dot(name)) {
+            d.kind = e.d_type as u64;
+            return name;
+        }
+    }
+    return "";
+}
 
-        if (is_dot == 0) {
-            syscall(1, 1, name_addr, name_len);
-            syscall(1, 1, "\n", 1);
+// closedir closes d and releases its memory.
+function closedir(d: *Dir): i64 -> {
+    let result = close(d.fd);
+    free(d as *u8, DIR_HEADER_SIZE + DIRENT_BUF_SIZE);
+    return result;
+}
+
+// walk calls visit(path, kind) for every file and directory below root,
+// depth first, with path joined onto root and kind the DT_* type. It descends
+// into each directory after visiting it; symbolic links are not followed.
+// A non-zero result from visit stops the walk and is returned; otherwise walk
+// returns 0, or the error code of a directory it could not read.
+function walk(root: string, visit: (string, i64) -> i64): i64 -> {
+    let d = opendir(root);
+    if (d == 0) {
+        return fs_last_error.code;
+    }
+    let result = 0;
+    let name = readdir(d);
+    while (result == 0 && name[0] != 0) {
+        let child = join(root, name);
+        let kind = d.kind;
+        if (kind == DT_UNKNOWN) {
+            // Some filesystems leave the type out of directory entries.
+            let st = alloc(STAT_SIZE) as *Stat;
+            if (lstat(child, st) == 0) {
+                kind = fileType(st);
+            }
+            free(st as *u8, STAT_SIZE);
         }
+        result = visit(child, kind);
+        if (result == 0 && kind == DT_DIR) {
+            result = walk(child, visit);
+        }
+        free(child, strlen(child) + 1);
+        if (result == 0) {
+            name = readdir(d);
+        }
+    }
+    if (result == 0) {
+        result = d.err;
+    }
+    closedir(d);
+    return result;
+}
+
+// listdir prints the name of every entry in the directory name, one per line,
+// to io.stdout. The synthetic entries "." and ".." are omitted.
+function listdir(name: string = "."): i64 -> {
+    let d = opendir(name);
+    if (d == 0) {
+        return fs_last_error.code;
+    }
+    let entry = readdir(d);
+    while (entry[0] != 0) {
+        io.writeString(io.stdout, entry);
+        io.writeByte(io.stdout, 10);
+        entry = readdir(d);
+    }
+    let result = d.err;
+    closedir(d);
+    return result;
+}
+
+// The two functions below only serve the tests at the end of this file and
+// are not part of the module's interface.
 
-        pos = pos + reclen;
+// fs_test_put_int writes n >= 0 in decimal to buf at pos and returns the
+// position after it.
+function fs_test_put_int(buf: *u8, pos: i64, n: i64): i64 -> {
+    let digits = 1;
+    let rest = n / 10;
+    while (rest > 0) {
+        digits = digits + 1;
+        rest = rest / 10;
     }
+    let i = digits;
+    while (i > 0) {
+        i = i - 1;
+        buf[pos + i] = 48 + n % 10;
+        n = n / 10;
+    }
+    return pos + digits;
+}
 
-    // close(fd)
-    syscall(3, fd, 0, 0, 0, 0, 0);
+// fs_test_dir creates a new directory for a test, /tmp/ylang-fs-PID-N with
+// the first N not taken, so that runs of the tests at the same time or after
+// one that was killed do not meet. It returns "" on failure.
+function fs_test_dir(): string -> {
+    let prefix = "/tmp/ylang-fs-";
+    let n = strlen(prefix);
+    let name = alloc(n + 42);
+    copy(name, prefix, n);
+    let pos = fs_test_put_int(name, n, syscall(SYS.getpid, 0, 0, 0, 0, 0, 0));
+    name[pos] = 45;
+    let attempt = 0;
+    while (attempt < 1000) {
+        name[fs_test_put_int(name, pos + 1, attempt)] = 0;
+        let r = mkdir(name, 448);
+        if (r == 0) {
+            return name;
+        }
+        if (!isExist(r)) {
+            return "";
+        }
+        attempt = attempt + 1;
+    }
+    return "";
+}
 
-    // munmap(buf, 4096)
-    syscall(11, buf, 4096, 0, 0, 0, 0);
+test "writeFile and readFile" {
+    let dir = fs_test_dir();
+    assert(strlen(dir) > 0);
+    let name = join(dir, "file.txt");
+    assertEq(writeFile(name, "hello", 5), 0);
+    let contents: string = "";
+    assertEq(readFile(name, &contents), 5);
+    assertEq(contents, "hello");
+    assertEq(unlink(name), 0);
+    assertEq(rmdir(dir), 0);
+}
+
+test "missing file" {
+    let contents: string = "";
+    let r = readFile("/nonexistent/ylang-fs-test", &contents);
+    assert(isNotExist(r));
+    assertEq(lastError().op, "open");
+    assertEq(errorString(r), "no such file or directory");
+}
 
-    return 0;
+test "mkdir, stat and rmdir" {
+    let dir = fs_test_dir();
+    assert(strlen(dir) > 0);
+    let name = join(dir, "sub");
+    assertEq(mkdir(name), 0);
+    assert(isExist(mkdir(name)));
+    let st = alloc(STAT_SIZE) as *Stat;
+    assertEq(stat(name, st), 0);
+    assert(isDir(st));
+    assert(!isRegular(st));
+    assertEq(rmdir(name), 0);
+    assert(isNotExist(stat(name, st)));
+    assertEq(rmdir(dir), 0);
 }
@@ -0,0 +1,251 @@
+// stdlib/io - buffered reading and writing of file descriptors
+// Implemented entirely in Y-lang via Linux syscalls.
+// No external C runtime is required.
+//
+// A Writer collects output in a buffer and writes it with one syscall when
+// the buffer fills or is flushed; a Reader fills its buffer with one read and
+// hands out bytes and lines from it. Both work over any file descriptor:
+// files from fs.open, pipes, sockets and the standard streams.
+//
+// stdout and stderr are flushed when the program returns from main or calls
+// os.exit. Reading stdin flushes stdout first, so prompts appear before the
+// program waits for input.
+//
+// Operations return a non-negative value on success and a negative error
+// code (-errno) on failure.
+
+import "stdlib/core/string"
+import "stdlib/mem"
+
+// Size of the buffer of each Reader and Writer.
+const BUF_SIZE = 4096;
+
+// Writer buffers output to fd.
+extern type Writer {
+    let fd: i64;
+    let buf: *u8;
+    let len: i64;
+    // err is the error code of the first failed write, 0 otherwise. A writer
+    // that failed discards further output.
+    let err: i64;
+}
+
+// Reader buffers input from fd.
+extern type Reader {
+    let fd: i64;
+    let buf: *u8;
+    let pos: i64;
+    let end: i64;
+    // err is the error code of a failed read, 0 otherwise.
+    let err: i64;
+}
+
+// Room for the Reader or Writer header ahead of its buffer.
+const HEADER_SIZE = 64;
+
+let stdin: *Reader = newReader(0);
+let stdout: *Writer = newWriter(1);
+let stderr: *Writer = newWriter(2);
+
+// newWriter returns a Writer for fd.
+function newWriter(fd: i64): *Writer -> {
+    let w = alloc(HEADER_SIZE + BUF_SIZE) as *Writer;
+    w.fd = fd;
+    w.buf = (w as *u8) + HEADER_SIZE;
+    w.len = 0;
+    w.err = 0;
+    return w;
+}
+
+// newReader returns a Reader for fd.
+function newReader(fd: i64): *Reader -> {
+    let r = alloc(HEADER_SIZE + BUF_SIZE) as *Reader;
+    r.fd = fd;
+    r.buf = (r as *u8) + HEADER_SIZE;
+    r.pos = 0;
+    r.end = 0;
+    r.err = 0;
+    return r;
+}
+
+// io_write_all writes the count bytes at p to fd, retrying after short writes.
+// It returns 0 or the error code of the failed write.
+function io_write_all(fd: i64, p: *u8, count: i64): i64 -> {
+    let done = 0;
+    while (done < count) {
+        let n = syscall(SYS.write, fd, p + done, count - done, 0, 0, 0);
+        if (n < 0) {
+            return n;
+        }
+        done = done + n;
+    }
+    return 0;
+}
+
+// flush writes the buffered output of w and returns 0.
+function flush(w: *Writer): i64 -> {
+    if (w.err == 0 && w.len > 0) {
+        w.err = io_write_all(w.fd, w.buf, w.len);
+    }
+    w.len = 0;
+    return w.err;
+}
+
+// write appends the count bytes at p to w and returns count. Writes larger
+// than the buffer bypass it.
+function write(w: *Writer, p: *u8, count: i64): i64 -> {
+    if (w.err != 0) {
+        return w.err;
+    }
+    if (w.len + count > BUF_SIZE) {
+        if (flush(w) != 0) {
+            return w.err;
+        }
+        if (count > BUF_SIZE) {
+            w.err = io_write_all(w.fd, p, count);
+            if (w.err != 0) {
+                return w.err;
+            }
+            return count;
+        }
+    }
+    copy(w.buf + w.len, p, count);
+    w.len = w.len + count;
+    return count;
+}
+
+// writeString appends the null-terminated s to w.
+function writeString(w: *Writer, s: string): i64 -> {
+    return write(w, s, strlen(s));
+}
+
+// writeByte appends the byte c to w.
+function writeByte(w: *Writer, c: i64): i64 -> {
+    if (w.len == BUF_SIZE) {
+        if (flush(w) != 0) {
+            return w.err;
+        }
+    }
+    w.buf[w.len] = c;
+    w.len = w.len + 1;
+    return 1;
+}
+
+// io_fill refills the buffer of r when it is empty and returns the number of
+// buffered bytes, 0 at the end of input.
+function io_fill(r: *Reader): i64 -> {
+    if (r.pos < r.end) {
+        return r.end - r.pos;
+    }
+    if (r.fd == 0) {
+        flush(stdout);
+    }
+    let n = syscall(SYS.read, r.fd, r.buf, BUF_SIZE, 0, 0, 0);
+    r.pos = 0;
+    if (n < 0) {
+        r.err = n;
+        r.end = 0;
+        return n;
+    }
+    r.end = n;
+    return n;
+}
+
+// read copies up to count buffered bytes of r to p and returns the number
+// copied, 0 at the end of input.
+function read(r: *Reader, p: *u8, count: i64): i64 -> {
+    let n = io_fill(r);
+    if (n <= 0) {
+        return n;
+    }
+    if (count < n) {
+        n = count;
+    }
+    copy(p, r.buf + r.pos, n);
+    r.pos = r.pos + n;
+    return n;
+}
+
+// readByte returns the next byte of r, or -1 at the end of input or after an
+// error.
+function readByte(r: *Reader): i64 -> {
+    if (io_fill(r) <= 0) {
+        return -1;
+    }
+    let c = r.buf[r.pos] as u64;
+    r.pos = r.pos + 1;
+    return c;
+}
+
+// io_read_until reads from r up to and including the byte stop (or to the end
+// of input when stop is -1) and stores the bytes, null-terminated and in
+// memory obtained from mem.alloc, in *out. It returns the number of bytes
+// read, 0 at the end of input.
+function io_read_until(r: *Reader, stop: i64, out: *string): i64 -> {
+    let cap = 128;
+    let used = 0;
+    let buf = alloc(cap);
+    let done = false;
+    while (!done) {
+        let n = io_fill(r);
+        if (n < 0) {
+            free(buf, cap);
+            return n;
+        }
+        if (n == 0) {
+            done = true;
+        }
+        // Take bytes up to the stop byte, or everything buffered.
+        let take = 0;
+        while (take < n && !done) {
+            if ((r.buf[r.pos + take] as u64) == stop) {
+                done = true;
+            }
+            take = take + 1;
+        }
+        // Keep a byte free for the terminator.
+        if (used + take + 1 > cap) {
+            let newCap = cap * 2;
+            while (used + take + 1 > newCap) {
+                newCap = newCap * 2;
+            }
+            let bigger = alloc(newCap);
+            copy(bigger, buf, used);
+            free(buf, cap);
+            buf = bigger;
+            cap = newCap;
+        }
+        copy(buf + used, r.buf + r.pos, take);
+        used = used + take;
+        r.pos = r.pos + take;
+    }
+    *out = buf;
+    return used;
+}
+
+// readLine reads the next line of r and stores it, without its "\n" and in
+// memory obtained from mem.alloc, in *line. It returns the number of bytes
+// consumed including the newline, so 0 means the end of input; a last line
+// without a newline is returned as is.
+function readLine(r: *Reader, line: *string): i64 -> {
+    let n = io_read_until(r, 10, line);
+    if (n > 0) {
+        let s = *line;
+        if (s[n - 1] == 10) {
+            s[n - 1] = 0;
+        }
+    }
+    return n;
+}
+
+// readAll reads the rest of r and stores it, null-terminated and in memory
+// obtained from mem.alloc, in *contents. It returns the number of bytes read.
+function readAll(r: *Reader, contents: *string): i64 -> {
+    return io_read_until(r, -1, contents);
+}
+
+// fini flushes the standard streams when the program exits.
+function fini() -> {
+    flush(stdout);
+    flush(stderr);
+}
@@ -0,0 +1,1638 @@
+// stdlib/json - JSON values, parsing and serialization
+// Implemented entirely in Y-lang over the heap of stdlib/mem.
+// No external C runtime is required.
+//
+// A document is a tree of JsonValues. parse reads one from text, returning
+// null on malformed input, with lastError describing the problem and where
+// it is, e.g. "line 3, column 12: expected ',' or '}' after object member".
+// stringify writes a tree back out, compact or indented. Values parsed from
+// text remember their line and column, so code checking their shape can
+// point at the input too; data declarations get toJson and fromJson from
+// the compiler, built on the helpers at the end of this file.
+//
+// Numbers are integers when written without a fraction or exponent and they
+// fit an i64, and f64 otherwise. Numbers with up to 19 significant digits are
+// converted to the nearest f64, and stringify writes the shortest digits
+// that read back as the same f64. Strings are UTF-8 and
+// cannot contain NUL. A tree owns its strings and children; freeValue
+// releases all of it. Nothing is synchronized, but the error state is kept
+// per thread.
+
+import "stdlib/core/string"
+import "stdlib/mem"
+import "stdlib/collections"
+
+// Kinds of value.
+const NULL = 0;
+const BOOL = 1;
+const NUMBER = 2;
+const STRING = 3;
+const ARRAY = 4;
+const OBJECT = 5;
+
+// JsonValue is one node of a document. A NUMBER keeps its value in num,
+// and in int as well when isInt is set. A STRING keeps its bytes in str,
+// null-terminated, with len of them. An ARRAY keeps len children in items;
+// an OBJECT keeps len members there as key, value pairs of words. line and
+// col locate a parsed value in its text, starting at 1; they are 0 for
+// values built by hand.
+extern type JsonValue {
+    let kind: i64;
+    let isInt: i64;
+    let int: i64;
+    let num: f64;
+    let str: *u8;
+    let items: *i64;
+    let len: i64;
+    let cap: i64;
+    let line: i64;
+    let col: i64;
+}
+
+const VALUE_SIZE = 80;
+
+// Arrays and objects deeper than this are rejected rather than risk
+// running out of stack.
+const MAX_DEPTH = 512;
+
+// Tokens of the lexer.
+const TOK_EOF = 0;
+const TOK_LBRACE = 1;
+const TOK_RBRACE = 2;
+const TOK_LBRACKET = 3;
+const TOK_RBRACKET = 4;
+const TOK_COLON = 5;
+const TOK_COMMA = 6;
+const TOK_STRING = 7;
+const TOK_NUMBER = 8;
+const TOK_TRUE = 9;
+const TOK_FALSE = 10;
+const TOK_NULL = 11;
+const TOK_ERROR = 12;
+
+// JsonLexer splits text into tokens. The current token starts at line,
+// col; a string token's bytes are in buf and a number's value in int and
+// num, like a JsonValue's.
+extern type JsonLexer {
+    let src: *u8;
+    let pos: i64;
+    let end: i64;
+    let lineNo: i64;
+    let lineStart: i64;
+    let tok: i64;
+    let line: i64;
+    let col: i64;
+    let isInt: i64;
+    let int: i64;
+    let num: f64;
+    let buf: *collections.StringBuilder;
+    let depth: i64;
+}
+
+const LEXER_SIZE = 104;
+
+// The last error of this thread and the number of errors so far.
+thread_local let json_err: *collections.StringBuilder = collections.newStringBuilder();
+thread_local let json_err_count: i64 = 0;
+thread_local let json_err_line: i64 = 0;
+thread_local let json_err_col: i64 = 0;
+
+// ---- Errors ----
+
+// lastError returns the message of the last error on this thread, "" if
+// there was none. The string changes with the next error.
+function lastError(): string -> {
+    return collections.builderString(json_err);
+}
+
+// errorLine returns the line of the last error, 0 if it has none.
+function errorLine(): i64 -> {
+    return json_err_line;
+}
+
+// errorColumn returns the column of the last error, 0 if it has none.
+function errorColumn(): i64 -> {
+    return json_err_col;
+}
+
+// errorCount returns the number of errors reported on this thread.
+function errorCount(): i64 -> {
+    return json_err_count;
+}
+
+// json_fail records an error at line, col (not shown when line is 0) made
+// of the three parts of msg.
+function json_fail(line: i64, col: i64, msg: string, msg2: string = "", msg3: string = "") -> {
+    let b = json_err;
+    collections.builderReset(b);
+    if (line > 0) {
+        collections.append(b, "line ");
+        collections.appendInt(b, line);
+        collections.append(b, ", column ");
+        collections.appendInt(b, col);
+        collections.append(b, ": ");
+    }
+    collections.append(b, msg);
+    collections.append(b, msg2);
+    collections.append(b, msg3);
+    json_err_line = line;
+    json_err_col = col;
+    json_err_count = json_err_count + 1;
+}
+
+// kindName returns the kind of v for messages, e.g. "a string".
+function kindName(v: *JsonValue): string -> {
+    let k = kind(v);
+    if (k == BOOL) {
+        return "a boolean";
+    }
+    if (k == NUMBER) {
+        return "a number";
+    }
+    if (k == STRING) {
+        return "a string";
+    }
+    if (k == ARRAY) {
+        return "an array";
+    }
+    if (k == OBJECT) {
+        return "an object";
+    }
+    return "null";
+}
+
+// ---- Building values ----
+
+function json_new(kind: i64): *JsonValue -> {
+    let v = heapAlloc(VALUE_SIZE) as *JsonValue;
+    v.kind = kind;
+    return v;
+}
+
+// newNull returns a null value.
+function newNull(): *JsonValue -> {
+    return json_new(NULL);
+}
+
+// newBool returns b as a value.
+function newBool(b: bool): *JsonValue -> {
+    let v = json_new(BOOL);
+    if (b) {
+        v.int = 1;
+    }
+    return v;
+}
+
+// newInt returns the integer n as a value.
+function newInt(n: i64): *JsonValue -> {
+    let v = json_new(NUMBER);
+    v.isInt = 1;
+    v.int = n;
+    v.num = n as f64;
+    return v;
+}
+
+// newNumber returns x as a value.
+function newNumber(x: f64): *JsonValue -> {
+    let v = json_new(NUMBER);
+    v.num = x;
+    return v;
+}
+
+// json_copy returns a null-terminated copy of the n bytes at p.
+function json_copy(p: *u8, n: i64): *u8 -> {
+    let s = heapAlloc(n + 1);
+    copy(s, p, n);
+    return s;
+}
+
+// json_equal reports whether the null-terminated strings a and b are equal.
+function json_equal(a: *u8, b: *u8): bool -> {
+    let i: i64 = 0;
+    while (a[i] == b[i] && a[i] != 0) {
+        i = i + 1;
+    }
+    return a[i] == b[i];
+}
+
+// newStringBytes returns a string value holding a copy of the n bytes at p.
+function newStringBytes(p: *u8, n: i64): *JsonValue -> {
+    let v = json_new(STRING);
+    v.str = json_copy(p, n);
+    v.len = n;
+    return v;
+}
+
+// newString returns a string value holding a copy of s, or null when s is
+// a null pointer.
+function newString(s: string): *JsonValue -> {
+    if ((s as i64) == 0) {
+        return newNull();
+    }
+    return newStringBytes(s, strlen(s));
+}
+
+// newArray returns an empty array.
+function newArray(): *JsonValue -> {
+    return json_new(ARRAY);
+}
+
+// newObject returns an object without members.
+function newObject(): *JsonValue -> {
+    return json_new(OBJECT);
+}
+
+// json_reserve makes room in v.items for one more entry of words words.
+function json_reserve(v: *JsonValue, words: i64) -> {
+    if (v.len < v.cap) {
+        return;
+    }
+    let cap: i64 = 8;
+    if (v.cap > 0) {
+        cap = v.cap * 2;
+    }
+    v.items = heapRealloc(v.items as *u8, cap * words * 8) as *i64;
+    v.cap = cap;
+}
+
+// add appends item to the array arr, which takes ownership of it.
+function add(arr: *JsonValue, item: *JsonValue) -> {
+    json_reserve(arr, 1);
+    arr.items[arr.len] = item as i64;
+    arr.len = arr.len + 1;
+}
+
+// json_find returns the index of the member key of obj, or -1.
+function json_find(obj: *JsonValue, key: string): i64 -> {
+    if ((obj as i64) == 0 || obj.kind != OBJECT) {
+        return -1;
+    }
+    let i: i64 = 0;
+    while (i < obj.len) {
+        if (json_equal(obj.items[2 * i] as *u8, key)) {
+            return i;
+        }
+        i = i + 1;
+    }
+    return -1;
+}
+
+// set makes value the member key of obj, which takes ownership of it,
+// replacing and freeing any member of that name.
+function set(obj: *JsonValue, key: string, value: *JsonValue) -> {
+    let i = json_find(obj, key);
+    if (i >= 0) {
+        freeValue(obj.items[2 * i + 1] as *JsonValue);
+        obj.items[2 * i + 1] = value as i64;
+        return;
+    }
+    json_reserve(obj, 2);
+    obj.items[2 * obj.len] = json_copy(key, strlen(key)) as i64;
+    obj.items[2 * obj.len + 1] = value as i64;
+    obj.len = obj.len + 1;
+}
+
+// ---- Reading values ----
+
+// kind returns the kind of v; a null pointer counts as NULL.
+function kind(v: *JsonValue): i64 -> {
+    if ((v as i64) == 0) {
+        return NULL;
+    }
+    return v.kind;
+}
+
+// isNull reports whether v is null or missing.
+function isNull(v: *JsonValue): bool -> {
+    return kind(v) == NULL;
+}
+
+// asBool returns the value of a boolean, or false for other kinds.
+function asBool(v: *JsonValue): bool -> {
+    return kind(v) == BOOL && v.int != 0;
+}
+
+// asInt returns a number as an integer, truncating a fraction, or 0 for
+// other kinds.
+function asInt(v: *JsonValue): i64 -> {
+    if (kind(v) != NUMBER) {
+        return 0;
+    }
+    if (v.isInt) {
+        return v.int;
+    }
+    return v.num as i64;
+}
+
+// asNumber returns a number as an f64, or 0 for other kinds.
+function asNumber(v: *JsonValue): f64 -> {
+    if (kind(v) != NUMBER) {
+        return 0.0;
+    }
+    return v.num;
+}
+
+// asString returns the text of a string, or "" for other kinds. The string
+// belongs to v.
+function asString(v: *JsonValue): string -> {
+    if (kind(v) != STRING) {
+        return "";
+    }
+    return v.str;
+}
+
+// length returns the number of items of an array, members of an object or
+// bytes of a string, and 0 for other kinds.
+function length(v: *JsonValue): i64 -> {
+    let k = kind(v);
+    if (k == ARRAY || k == OBJECT || k == STRING) {
+        return v.len;
+    }
+    return 0;
+}
+
+// at returns item i of an array, or null when there is none.
+function at(arr: *JsonValue, i: i64): *JsonValue -> {
+    if (kind(arr) != ARRAY || i < 0 || i >= arr.len) {
+        return 0 as *JsonValue;
+    }
+    return arr.items[i] as *JsonValue;
+}
+
+// get returns the member key of an object, or null when there is none.
+function get(obj: *JsonValue, key: string): *JsonValue -> {
+    let i = json_find(obj, key);
+    if (i < 0) {
+        return 0 as *JsonValue;
+    }
+    return obj.items[2 * i + 1] as *JsonValue;
+}
+
+// has reports whether an object has a member key.
+function has(obj: *JsonValue, key: string): bool -> {
+    return json_find(obj, key) >= 0;
+}
+
+// keyAt returns the name of member i of an object, in the order members
+// were parsed or set, or "" when there is none.
+function keyAt(obj: *JsonValue, i: i64): string -> {
+    if (kind(obj) != OBJECT || i < 0 || i >= obj.len) {
+        return "";
+    }
+    return obj.items[2 * i] as *u8;
+}
+
+// valueAt returns the value of member i of an object, or null.
+function valueAt(obj: *JsonValue, i: i64): *JsonValue -> {
+    if (kind(obj) != OBJECT || i < 0 || i >= obj.len) {
+        return 0 as *JsonValue;
+    }
+    return obj.items[2 * i + 1] as *JsonValue;
+}
+
+// clone returns a deep copy of v.
+function clone(v: *JsonValue): *JsonValue -> {
+    if ((v as i64) == 0) {
+        return newNull();
+    }
+    let c = json_new(v.kind);
+    c.isInt = v.isInt;
+    c.int = v.int;
+    c.num = v.num;
+    c.line = v.line;
+    c.col = v.col;
+    if (v.kind == STRING) {
+        c.str = json_copy(v.str, v.len);
+        c.len = v.len;
+    }
+    let i: i64 = 0;
+    while (i < v.len && v.kind == ARRAY) {
+        add(c, clone(v.items[i] as *JsonValue));
+        i = i + 1;
+    }
+    while (i < v.len && v.kind == OBJECT) {
+        set(c, v.items[2 * i] as *u8, clone(v.items[2 * i + 1] as *JsonValue));
+        i = i + 1;
+    }
+    return c;
+}
+
+// freeValue releases v with all of its strings and children.
+function freeValue(v: *JsonValue) -> {
+    if ((v as i64) == 0) {
+        return;
+    }
+    let i: i64 = 0;
+    while (i < v.len && v.kind == ARRAY) {
+        freeValue(v.items[i] as *JsonValue);
+        i = i + 1;
+    }
+    while (i < v.len && v.kind == OBJECT) {
+        heapFree(v.items[2 * i] as *u8);
+        freeValue(v.items[2 * i + 1] as *JsonValue);
+        i = i + 1;
+    }
+    if ((v.items as i64) != 0) {
+        heapFree(v.items as *u8);
+    }
+    if ((v.str as i64) != 0) {
+        heapFree(v.str);
+    }
+    heapFree(v as *u8);
+}
+
+// ---- Numbers ----
+
+// json_pow10 returns 10^n for n >= 0; exact up to 10^22.
+function json_pow10(n: i64): f64 -> {
+    let r: f64 = 1.0;
+    while (n >= 22) {
+        r = r * 10000000000000000000000.0;
+        n = n - 22;
+    }
+    while (n > 0) {
+        r = r * 10.0;
+        n = n - 1;
+    }
+    return r;
+}
+
+// json_scale returns m * 10^e, correctly rounded when m is an integer below
+// 2^53 and e is within 22, and otherwise within a few f64 of the exact
+// value.
+function json_scale(m: f64, e: i64): f64 -> {
+    if (e >= 0) {
+        return m * json_pow10(e);
+    }
+    // Go down in steps of 10^22 so the divisor does not overflow, and the
+    // result does not underflow before the last one.
+    while (e < -22) {
+        m = m / 10000000000000000000000.0;
+        e = e + 22;
+    }
+    return m / json_pow10(-e);
+}
+
+// Exact conversions work on big natural numbers: JSON_BIG_LIMBS limbs of 32
+// bits, least significant first, after a word holding how many are in use.
+// That is enough for every product the conversions below form, the largest
+// being about 1100 bits.
+const JSON_BIG_LIMBS = 48;
+
+// json_big_new returns a big number holding 0, to be freed with heapFree.
+function json_big_new(): *i64 -> {
+    let a = heapAlloc((JSON_BIG_LIMBS + 1) * 8) as *i64;
+    a[0] = 0;
+    return a;
+}
+
+// json_big_set_neg sets a to -v, for v <= 0, so that the smallest i64 works.
+function json_big_set_neg(a: *i64, v: i64) -> {
+    a[1] = -(v % 4294967296);
+    a[2] = -(v / 4294967296);
+    a[0] = 2;
+    json_big_trim(a);
+}
+
+// json_big_trim drops the zero limbs at the top of a.
+function json_big_trim(a: *i64) -> {
+    while (a[0] > 0 && a[a[0]] == 0) {
+        a[0] = a[0] - 1;
+    }
+}
+
+// json_big_copy sets dst to src.
+function json_big_copy(dst: *i64, src: *i64) -> {
+    let i: i64 = 0;
+    while (i <= src[0]) {
+        dst[i] = src[i];
+        i = i + 1;
+    }
+}
+
+// json_big_mul_small multiplies a by k, for 0 < k < 2^31.
+function json_big_mul_small(a: *i64, k: i64) -> {
+    let carry: i64 = 0;
+    let i: i64 = 1;
+    while (i <= a[0]) {
+        let t = a[i] * k + carry;
+        a[i] = t & 4294967295;
+        carry = t >>> 32;
+        i = i + 1;
+    }
+    if (carry > 0) {
+        a[0] = a[0] + 1;
+        a[a[0]] = carry;
+    }
+}
+
+// json_big_mul_pow multiplies a by 5^n, or by 10^n when ten is true.
+function json_big_mul_pow(a: *i64, n: i64, ten: bool) -> {
+    // 5^13 and 10^9 are the largest powers below 2^31.
+    let step: i64 = 13;
+    let big: i64 = 1220703125;
+    let base: i64 = 5;
+    if (ten) {
+        step = 9;
+        big = 1000000000;
+        base = 10;
+    }
+    while (n >= step) {
+        json_big_mul_small(a, big);
+        n = n - step;
+    }
+    let k: i64 = 1;
+    while (n > 0) {
+        k = k * base;
+        n = n - 1;
+    }
+    if (k > 1) {
+        json_big_mul_small(a, k);
+    }
+}
+
+// json_big_shl multiplies a by 2^n.
+function json_big_shl(a: *i64, n: i64) -> {
+    if (a[0] == 0) {
+        return;
+    }
+    let words = n / 32;
+    let bits = n % 32;
+    let i = a[0];
+    while (i >= 1) {
+        a[i + words] = a[i];
+        i = i - 1;
+    }
+    i = 1;
+    while (i <= words) {
+        a[i] = 0;
+        i = i + 1;
+    }
+    a[0] = a[0] + words;
+    if (bits > 0) {
+        let carry: i64 = 0;
+        i = words + 1;
+        while (i <= a[0]) {
+            let t = (a[i] << bits) + carry;
+            carry = a[i] >>> (32 - bits);
+            a[i] = t & 4294967295;
+            i = i + 1;
+        }
+        if (carry > 0) {
+            a[0] = a[0] + 1;
+            a[a[0]] = carry;
+        }
+    }
+}
+
+// json_big_add adds b to a.
+function json_big_add(a: *i64, b: *i64) -> {
+    let carry: i64 = 0;
+    let i: i64 = 1;
+    while (i <= a[0] || i <= b[0]) {
+        let t = carry;
+        if (i <= a[0]) {
+            t = t + a[i];
+        }
+        if (i <= b[0]) {
+            t = t + b[i];
+        }
+        a[i] = t & 4294967295;
+        carry = t >>> 32;
+        i = i + 1;
+    }
+    a[0] = i - 1;
+    if (carry > 0) {
+        a[0] = i;
+        a[i] = carry;
+    }
+}
+
+// json_big_sub subtracts b from a, which must not be smaller.
+function json_big_sub(a: *i64, b: *i64) -> {
+    let borrow: i64 = 0;
+    let i: i64 = 1;
+    while (i <= a[0]) {
+        let t = a[i] - borrow;
+        if (i <= b[0]) {
+            t = t - b[i];
+        }
+        borrow = 0;
+        if (t < 0) {
+            t = t + 4294967296;
+            borrow = 1;
+        }
+        a[i] = t;
+        i = i + 1;
+    }
+    json_big_trim(a);
+}
+
+// json_big_cmp returns -1, 0 or 1 as a is less than, equal to or greater
+// than b.
+function json_big_cmp(a: *i64, b: *i64): i64 -> {
+    if (a[0] != b[0]) {
+        if (a[0] < b[0]) {
+            return -1;
+        }
+        return 1;
+    }
+    let i = a[0];
+    while (i >= 1) {
+        if (a[i] != b[i]) {
+            if (a[i] < b[i]) {
+                return -1;
+            }
+            return 1;
+        }
+        i = i - 1;
+    }
+    return 0;
+}
+
+// json_digits writes the digits of x, a positive finite f64, to b: the
+// shortest digits that read back as x, and of those the closest to x, in
+// plain notation when the exponent is between -7 and 20 and scientific
+// notation otherwise.
+//
+// The digits are generated exactly, as in Steele and White's free-format
+// algorithm: x is r / s, and x - mm / s and x + mp / s are the halfway
+// points to its neighbours. Digits stop as soon as the ones so far, or
+// those with the last one raised, fall strictly between the halfway points,
+// or onto one when x is even and so wins the tie.
+function json_digits(b: *collections.StringBuilder, x: f64) -> {
+    let bits = builtin.floatBits(x);
+    let f = bits & 4503599627370495;
+    let be = bits >>> 52;
+    let exp2: i64 = -1074;
+    if (be > 0) {
+        f = f + 4503599627370496;
+        exp2 = be - 1075;
+    }
+    let even = f % 2 == 0;
+    let r = json_big_new();
+    let s = json_big_new();
+    let mp = json_big_new();
+    let mm = json_big_new();
+    let t = json_big_new();
+    json_big_set_neg(r, -f);
+    json_big_set_neg(s, -1);
+    json_big_set_neg(mp, -1);
+    json_big_set_neg(mm, -1);
+    // The gap below x is half the one above when x is the first of its
+    // binade; scale everything by 4 rather than 2 to keep it whole.
+    let lowGap = be > 1 && f == 4503599627370496;
+    let scale: i64 = 1;
+    if (lowGap) {
+        scale = 2;
+        json_big_shl(mp, 1);
+    }
+    json_big_shl(r, scale);
+    if (exp2 >= 0) {
+        json_big_shl(r, exp2);
+        json_big_shl(mp, exp2);
+        json_big_shl(mm, exp2);
+    } else {
+        json_big_shl(s, -exp2);
+    }
+    json_big_shl(s, scale);
+    // Estimate k, the decimal exponent with 10^(k-1) <= x < 10^k, then
+    // correct it exactly.
+    let k: i64 = 1;
+    let y = x;
+    while (y >= 10.0) {
+        y = y / 10.0;
+        k = k + 1;
+    }
+    while (y < 1.0) {
+        y = y * 10.0;
+        k = k - 1;
+    }
+    if (k >= 0) {
+        json_big_mul_pow(s, k, true);
+    } else {
+        json_big_mul_pow(r, -k, true);
+        json_big_mul_pow(mp, -k, true);
+        json_big_mul_pow(mm, -k, true);
+    }
+    let fixed = false;
+    while (!fixed) {
+        json_big_copy(t, r);
+        json_big_add(t, mp);
+        let c = json_big_cmp(t, s);
+        if (c > 0 || (even && c == 0)) {
+            json_big_mul_small(s, 10);
+            k = k + 1;
+        } else {
+            json_big_mul_small(t, 10);
+            c = json_big_cmp(t, s);
+            if (c < 0 || (!even && c == 0)) {
+                json_big_mul_small(r, 10);
+                json_big_mul_small(mp, 10);
+                json_big_mul_small(mm, 10);
+                k = k - 1;
+            } else {
+                fixed = true;
+            }
+        }
+    }
+    let digits = heapAlloc(24);
+    let p: i64 = 0;
+    let done = false;
+    while (!done) {
+        json_big_mul_small(r, 10);
+        json_big_mul_small(mp, 10);
+        json_big_mul_small(mm, 10);
+        let d: i64 = 0;
+        while (json_big_cmp(r, s) >= 0) {
+            json_big_sub(r, s);
+            d = d + 1;
+        }
+        let c = json_big_cmp(r, mm);
+        let low = c < 0 || (even && c == 0);
+        json_big_copy(t, r);
+        json_big_add(t, mp);
+        c = json_big_cmp(t, s);
+        let high = c > 0 || (even && c == 0);
+        if (low && high) {
+            // Both are close enough; take the nearer one.
+            json_big_copy(t, r);
+            json_big_shl(t, 1);
+            if (json_big_cmp(t, s) >= 0) {
+                d = d + 1;
+            }
+        } else if (high) {
+            d = d + 1;
+        }
+        digits[p] = 48 + d;
+        p = p + 1;
+        done = low || high;
+    }
+    heapFree(r as *u8);
+    heapFree(s as *u8);
+    heapFree(mp as *u8);
+    heapFree(mm as *u8);
+    heapFree(t as *u8);
+    // The decimal exponent of the first digit.
+    let e = k - 1;
+    let i: i64 = 0;
+    if (e >= -7 && e < 21) {
+        if (e < 0) {
+            collections.append(b, "0.");
+            i = e + 1;
+            while (i < 0) {
+                collections.appendByte(b, 48);
+                i = i + 1;
+            }
+            collections.appendBytes(b, digits, p);
+        } else if (p <= e + 1) {
+            collections.appendBytes(b, digits, p);
+            i = p;
+            while (i <= e) {
+                collections.appendByte(b, 48);
+                i = i + 1;
+            }
+        } else {
+            collections.appendBytes(b, digits, e + 1);
+            collections.appendByte(b, 46);
+            collections.appendBytes(b, digits + e + 1, p - e - 1);
+        }
+    } else {
+        collections.appendByte(b, digits[0]);
+        if (p > 1) {
+            collections.appendByte(b, 46);
+            collections.appendBytes(b, digits + 1, p - 1);
+        }
+        collections.appendByte(b, 101);
+        if (e < 0) {
+            collections.appendByte(b, 45);
+            e = -e;
+        } else {
+            collections.appendByte(b, 43);
+        }
+        collections.appendInt(b, e);
+    }
+    heapFree(digits);
+}
+
+// json_cmp_half compares m * 10^e, for m < 0 standing for -m, with the
+// point halfway between the f64 (2^52 + f) * 2^exp2, or f * 2^exp2 for a
+// subnormal, and the next one up. It returns -1, 0 or 1.
+function json_cmp_half(l: *i64, h: *i64, m: i64, e: i64, f: i64, exp2: i64): i64 -> {
+    json_big_set_neg(l, m);
+    json_big_set_neg(h, -(2 * f + 1));
+    // m * 5^e * 2^e against (2f + 1) * 2^(exp2 - 1), with the negative
+    // powers moved to the other side.
+    if (e >= 0) {
+        json_big_mul_pow(l, e, false);
+    } else {
+        json_big_mul_pow(h, -e, false);
+    }
+    let t = e - exp2 + 1;
+    if (t >= 0) {
+        json_big_shl(l, t);
+    } else {
+        json_big_shl(h, -t);
+    }
+    return json_big_cmp(l, h);
+}
+
+// json_decimal returns -m * 10^e, for m <= 0, correctly rounded with ties to
+// even: infinity when it is too large for an f64. The estimate json_scale
+// makes is corrected one f64 at a time by exact comparisons with the
+// halfway points around it.
+function json_decimal(m: i64, e: i64): f64 -> {
+    if (m == 0) {
+        return 0.0;
+    }
+    // Both the digits and the power of ten are exact, so is one operation.
+    if (m > -9007199254740992 && e >= -22 && e <= 22) {
+        return json_scale(-(m as f64), e);
+    }
+    let digits: i64 = 0;
+    let rest = m;
+    while (rest != 0) {
+        rest = rest / 10;
+        digits = digits + 1;
+    }
+    let inf = builtin.floatFromBits(9218868437227405312);
+    if (e + digits > 310) {
+        return inf;
+    }
+    if (e + digits < -325) {
+        return 0.0;
+    }
+    let bits = builtin.floatBits(json_scale(-(m as f64), e));
+    if (bits >= 9218868437227405312) {
+        bits = 9218868437227405311;
+    }
+    let l = json_big_new();
+    let h = json_big_new();
+    let done = false;
+    while (!done) {
+        let f = bits & 4503599627370495;
+        let be = bits >>> 52;
+        let exp2: i64 = -1074;
+        if (be > 0) {
+            f = f + 4503599627370496;
+            exp2 = be - 1075;
+        }
+        let c = json_cmp_half(l, h, m, e, f, exp2);
+        if (c > 0 || (c == 0 && f % 2 == 1)) {
+            bits = bits + 1;
+            done = bits == 9218868437227405312;
+        } else if (bits == 0) {
+            done = true;
+        } else {
+            let below = bits - 1;
+            f = below & 4503599627370495;
+            be = below >>> 52;
+            exp2 = -1074;
+            if (be > 0) {
+                f = f + 4503599627370496;
+                exp2 = be - 1075;
+            }
+            c = json_cmp_half(l, h, m, e, f, exp2);
+            // On a tie the even one of the two wins; bits is odd when the
+            // one below is even.
+            if (c < 0 || (c == 0 && bits % 2 == 1)) {
+                bits = below;
+            } else {
+                done = true;
+            }
+        }
+    }
+    heapFree(l as *u8);
+    heapFree(h as *u8);
+    return builtin.floatFromBits(bits);
+}
+
+// ---- Lexer ----
+
+function json_lexer_error(lx: *JsonLexer, msg: string, msg2: string = "", msg3: string = "") -> {
+    json_fail(lx.line, lx.col, msg, msg2, msg3);
+    lx.tok = TOK_ERROR;
+}
+
+// json_is_digit reports whether c is an ASCII digit.
+function json_is_digit(c: i64): bool -> {
+    return c >= 48 && c <= 57;
+}
+
+// json_hex returns the value of the hex digit c, or -1.
+function json_hex(c: i64): i64 -> {
+    if (c >= 48 && c <= 57) {
+        return c - 48;
+    }
+    if (c >= 97 && c <= 102) {
+        return c - 87;
+    }
+    if (c >= 65 && c <= 70) {
+        return c - 55;
+    }
+    return -1;
+}
+
+// json_lex_hex4 reads the four hex digits of a \u escape at lx.pos and
+// returns their value, or -1.
+function json_lex_hex4(lx: *JsonLexer): i64 -> {
+    if (lx.pos + 4 > lx.end) {
+        return -1;
+    }
+    let r: i64 = 0;
+    let i: i64 = 0;
+    while (i < 4) {
+        let d = json_hex(lx.src[lx.pos + i]);
+        if (d < 0) {
+            return -1;
+        }
+        r = r * 16 + d;
+        i = i + 1;
+    }
+    lx.pos = lx.pos + 4;
+    return r;
+}
+
+// json_put_utf8 appends the code point c to b as UTF-8.
+function json_put_utf8(b: *collections.StringBuilder, c: i64) -> {
+    if (c < 0x80) {
+        collections.appendByte(b, c);
+    } else if (c < 0x800) {
+        collections.appendByte(b, 0xc0 | (c >> 6));
+        collections.appendByte(b, 0x80 | (c & 0x3f));
+    } else if (c < 0x10000) {
+        collections.appendByte(b, 0xe0 | (c >> 12));
+        collections.appendByte(b, 0x80 | ((c >> 6) & 0x3f));
+        collections.appendByte(b, 0x80 | (c & 0x3f));
+    } else {
+        collections.appendByte(b, 0xf0 | (c >> 18));
+        collections.appendByte(b, 0x80 | ((c >> 12) & 0x3f));
+        collections.appendByte(b, 0x80 | ((c >> 6) & 0x3f));
+        collections.appendByte(b, 0x80 | (c & 0x3f));
+    }
+}
+
+// json_lex_string reads a string token, its opening quote at lx.pos, into
+// lx.buf.
+function json_lex_string(lx: *JsonLexer) -> {
+    let b = lx.buf;
+    collections.builderReset(b);
+    lx.pos = lx.pos + 1;
+    lx.tok = TOK_STRING;
+    let done = false;
+    while (!done) {
+        if (lx.pos >= lx.end) {
+            json_lexer_error(lx, "unterminated string");
+            done = true;
+        } else {
+            let c: i64 = lx.src[lx.pos] & 255;
+            lx.pos = lx.pos + 1;
+            if (c == 34) {
+                done = true;
+            } else if (c < 32) {
+                json_lexer_error(lx, "control character in string");
+                done = true;
+            } else if (c != 92) {
+                collections.appendByte(b, c);
+            } else if (lx.pos >= lx.end) {
+                json_lexer_error(lx, "unterminated string");
+                done = true;
+            } else {
+                let e: i64 = lx.src[lx.pos];
+                lx.pos = lx.pos + 1;
+                if (e == 34 || e == 92 || e == 47) {
+                    collections.appendByte(b, e);
+                } else if (e == 98) {
+                    collections.appendByte(b, 8);
+                } else if (e == 102) {
+                    collections.appendByte(b, 12);
+                } else if (e == 110) {
+                    collections.appendByte(b, 10);
+                } else if (e == 114) {
+                    collections.appendByte(b, 13);
+                } else if (e == 116) {
+                    collections.appendByte(b, 9);
+                } else if (e == 117) {
+                    let cp = json_lex_hex4(lx);
+                    // A high surrogate pairs with the \u escape after it.
+                    if (cp >= 0xd800 && cp < 0xdc00 && lx.pos + 1 < lx.end && lx.src[lx.pos] == 92 && lx.src[lx.pos + 1] == 117) {
+                        lx.pos = lx.pos + 2;
+                        let lo = json_lex_hex4(lx);
+                        if (lo >= 0xdc00 && lo < 0xe000) {
+                            cp = 0x10000 + ((cp - 0xd800) << 10) + (lo - 0xdc00);
+                        } else {
+                            cp = -1;
+                        }
+                    }
+                    if (cp < 0) {
+                        json_lexer_error(lx, "invalid \\u escape in string");
+                        done = true;
+                    } else if (cp == 0) {
+                        json_lexer_error(lx, "\\u0000 in string");
+                        done = true;
+                    } else {
+                        json_put_utf8(b, cp);
+                    }
+                } else {
+                    json_lexer_error(lx, "invalid escape in string");
+                    done = true;
+                }
+            }
+        }
+    }
+}
+
+// json_lex_number reads a number token at lx.pos.
+function json_lex_number(lx: *JsonLexer) -> {
+    let src = lx.src;
+    let neg = false;
+    if (src[lx.pos] == 45) {
+        neg = true;
+        lx.pos = lx.pos + 1;
+    }
+    if (lx.pos >= lx.end || !json_is_digit(src[lx.pos])) {
+        json_lexer_error(lx, "invalid number");
+        return;
+    }
+    // As many significant digits as fit an i64 are kept in m, exactly; the
+    // rest only move the exponent. m holds the negated value, as the
+    // smallest i64 has no positive counterpart.
+    let m: i64 = 0;
+    let kept: i64 = 0;
+    let exp: i64 = 0;
+    let isInt = true;
+    if (src[lx.pos] == 48) {
+        lx.pos = lx.pos + 1;
+    } else {
+        while (lx.pos < lx.end && json_is_digit(src[lx.pos])) {
+            let d: i64 = src[lx.pos] - 48;
+            if (kept < 18 || (kept == 18 && m >= (-9223372036854775807 - 1 + d) / 10)) {
+                m = m * 10 - d;
+                kept = kept + 1;
+            } else {
+                exp = exp + 1;
+                isInt = false;
+            }
+            lx.pos = lx.pos + 1;
+        }
+    }
+    if (lx.pos < lx.end && src[lx.pos] == 46) {
+        isInt = false;
+        lx.pos = lx.pos + 1;
+        if (lx.pos >= lx.end || !json_is_digit(src[lx.pos])) {
+            json_lexer_error(lx, "invalid number: expected a digit after '.'");
+            return;
+        }
+        while (lx.pos < lx.end && json_is_digit(src[lx.pos])) {
+            if (kept < 18 && (m < 0 || src[lx.pos] != 48)) {
+                m = m * 10 - (src[lx.pos] - 48);
+                kept = kept + 1;
+                exp = exp - 1;
+            } else if (m == 0) {
+                exp = exp - 1;
+            }
+            lx.pos = lx.pos + 1;
+        }
+    }
+    if (lx.pos < lx.end && (src[lx.pos] == 101 || src[lx.pos] == 69)) {
+        isInt = false;
+        lx.pos = lx.pos + 1;
+        let eneg = false;
+        if (lx.pos < lx.end && (src[lx.pos] == 43 || src[lx.pos] == 45)) {
+            eneg = src[lx.pos] == 45;
+            lx.pos = lx.pos + 1;
+        }
+        if (lx.pos >= lx.end || !json_is_digit(src[lx.pos])) {
+            json_lexer_error(lx, "invalid number: expected a digit in the exponent");
+            return;
+        }
+        let e: i64 = 0;
+        while (lx.pos < lx.end && json_is_digit(src[lx.pos])) {
+            if (e < 100000) {
+                e = e * 10 + src[lx.pos] - 48;
+            }
+            lx.pos = lx.pos + 1;
+        }
+        if (eneg) {
+            e = -e;
+        }
+        exp = exp + e;
+    }
+    if (lx.pos < lx.end && (json_is_digit(src[lx.pos]) || src[lx.pos] == 46)) {
+        json_lexer_error(lx, "invalid number");
+        return;
+    }
+    lx.tok = TOK_NUMBER;
+    lx.num = json_decimal(m, exp);
+    if (neg) {
+        lx.num = 0.0 - lx.num;
+    } else if (m == -9223372036854775807 - 1) {
+        isInt = false;
+    } else {
+        m = -m;
+    }
+    lx.isInt = 0;
+    if (isInt) {
+        lx.isInt = 1;
+    }
+    lx.int = m;
+    if (lx.num - lx.num != 0.0) {
+        json_lexer_error(lx, "number out of range");
+    }
+}
+
+// json_lex_word reads the literal word at lx.pos, which must be text.
+function json_lex_word(lx: *JsonLexer, text: string, tok: i64) -> {
+    let n = strlen(text);
+    let i: i64 = 0;
+    while (i < n && lx.pos + i < lx.end && lx.src[lx.pos + i] == text[i]) {
+        i = i + 1;
+    }
+    if (i < n) {
+        json_lexer_error(lx, "invalid literal, expected '", text, "'");
+        return;
+    }
+    lx.pos = lx.pos + n;
+    lx.tok = tok;
+}
+
+// json_next moves lx to its next token.
+function json_next(lx: *JsonLexer) -> {
+    // Skip white space, counting lines.
+    let skipping = true;
+    while (skipping && lx.pos < lx.end) {
+        let c: i64 = lx.src[lx.pos];
+        if (c == 10) {
+            lx.lineNo = lx.lineNo + 1;
+            lx.lineStart = lx.pos + 1;
+            lx.pos = lx.pos + 1;
+        } else if (c == 32 || c == 9 || c == 13) {
+            lx.pos = lx.pos + 1;
+        } else {
+            skipping = false;
+        }
+    }
+    lx.line = lx.lineNo;
+    lx.col = lx.pos - lx.lineStart + 1;
+    if (lx.pos >= lx.end) {
+        lx.tok = TOK_EOF;
+        return;
+    }
+    let c: i64 = lx.src[lx.pos];
+    if (c == 123) {
+        lx.tok = TOK_LBRACE;
+        lx.pos = lx.pos + 1;
+    } else if (c == 125) {
+        lx.tok = TOK_RBRACE;
+        lx.pos = lx.pos + 1;
+    } else if (c == 91) {
+        lx.tok = TOK_LBRACKET;
+        lx.pos = lx.pos + 1;
+    } else if (c == 93) {
+        lx.tok = TOK_RBRACKET;
+        lx.pos = lx.pos + 1;
+    } else if (c == 58) {
+        lx.tok = TOK_COLON;
+        lx.pos = lx.pos + 1;
+    } else if (c == 44) {
+        lx.tok = TOK_COMMA;
+        lx.pos = lx.pos + 1;
+    } else if (c == 34) {
+        json_lex_string(lx);
+    } else if (c == 45 || json_is_digit(c)) {
+        json_lex_number(lx);
+    } else if (c == 116) {
+        json_lex_word(lx, "true", TOK_TRUE);
+    } else if (c == 102) {
+        json_lex_word(lx, "false", TOK_FALSE);
+    } else if (c == 110) {
+        json_lex_word(lx, "null", TOK_NULL);
+    } else {
+        json_lexer_error(lx, "unexpected character");
+    }
+}
+
+// ---- Parser ----
+
+// json_parse_error reports an unexpected token, unless the lexer already
+// reported a malformed one.
+function json_parse_error(lx: *JsonLexer, msg: string) -> {
+    if (lx.tok == TOK_ERROR) {
+        return;
+    }
+    if (lx.tok == TOK_EOF) {
+        json_fail(lx.line, lx.col, msg, " but found the end of the input");
+    } else {
+        json_fail(lx.line, lx.col, msg);
+    }
+    lx.tok = TOK_ERROR;
+}
+
+// json_parse_value parses the value starting at the current token and
+// moves past it. It returns null after reporting an error.
+function json_parse_value(lx: *JsonLexer): *JsonValue -> {
+    let line = lx.line;
+    let col = lx.col;
+    let v = 0 as *JsonValue;
+    if (lx.tok == TOK_NULL) {
+        v = newNull();
+        json_next(lx);
+    } else if (lx.tok == TOK_TRUE || lx.tok == TOK_FALSE) {
+        v = newBool(lx.tok == TOK_TRUE);
+        json_next(lx);
+    } else if (lx.tok == TOK_NUMBER) {
+        v = newNumber(lx.num);
+        v.isInt = lx.isInt;
+        v.int = lx.int;
+        json_next(lx);
+    } else if (lx.tok == TOK_STRING) {
+        v = newStringBytes(collections.builderString(lx.buf), collections.builderLen(lx.buf));
+        json_next(lx);
+    } else if (lx.tok == TOK_LBRACKET || lx.tok == TOK_LBRACE) {
+        if (lx.depth >= MAX_DEPTH) {
+            json_parse_error(lx, "nesting too deep");
+            return v;
+        }
+        lx.depth = lx.depth + 1;
+        if (lx.tok == TOK_LBRACKET) {
+            v = json_parse_array(lx);
+        } else {
+            v = json_parse_object(lx);
+        }
+        lx.depth = lx.depth - 1;
+    } else {
+        json_parse_error(lx, "expected a value");
+    }
+    if ((v as i64) != 0) {
+        v.line = line;
+        v.col = col;
+    }
+    return v;
+}
+
+// json_parse_array parses an array, the current token being its '['.
+function json_parse_array(lx: *JsonLexer): *JsonValue -> {
+    let arr = newArray();
+    json_next(lx);
+    if (lx.tok == TOK_RBRACKET) {
+        json_next(lx);
+        return arr;
+    }
+    let more = true;
+    while (more) {
+        let item = json_parse_value(lx);
+        if ((item as i64) == 0) {
+            freeValue(arr);
+            return item;
+        }
+        add(arr, item);
+        if (lx.tok == TOK_COMMA) {
+            json_next(lx);
+        } else if (lx.tok == TOK_RBRACKET) {
+            json_next(lx);
+            more = false;
+        } else {
+            json_parse_error(lx, "expected ',' or ']' after array item");
+            freeValue(arr);
+            return 0 as *JsonValue;
+        }
+    }
+    return arr;
+}
+
+// json_parse_object parses an object, the current token being its '{'.
+// A repeated key keeps the last value.
+function json_parse_object(lx: *JsonLexer): *JsonValue -> {
+    let obj = newObject();
+    json_next(lx);
+    if (lx.tok == TOK_RBRACE) {
+        json_next(lx);
+        return obj;
+    }
+    let more = true;
+    while (more) {
+        if (lx.tok != TOK_STRING) {
+            json_parse_error(lx, "expected a string as object key");
+            freeValue(obj);
+            return 0 as *JsonValue;
+        }
+        let key = collections.toString(lx.buf);
+        json_next(lx);
+        if (lx.tok != TOK_COLON) {
+            json_parse_error(lx, "expected ':' after object key");
+            heapFree(key);
+            freeValue(obj);
+            return 0 as *JsonValue;
+        }
+        json_next(lx);
+        let value = json_parse_value(lx);
+        if ((value as i64) == 0) {
+            heapFree(key);
+            freeValue(obj);
+            return value;
+        }
+        set(obj, key, value);
+        heapFree(key);
+        if (lx.tok == TOK_COMMA) {
+            json_next(lx);
+        } else if (lx.tok == TOK_RBRACE) {
+            json_next(lx);
+            more = false;
+        } else {
+            json_parse_error(lx, "expected ',' or '}' after object member");
+            freeValue(obj);
+            return 0 as *JsonValue;
+        }
+    }
+    return obj;
+}
+
+// parseBytes parses the n bytes at p as one JSON value. It returns null
+// when they are not valid JSON; lastError tells why.
+function parseBytes(p: *u8, n: i64): *JsonValue -> {
+    let lx = heapAlloc(LEXER_SIZE) as *JsonLexer;
+    lx.src = p;
+    lx.end = n;
+    lx.lineNo = 1;
+    lx.buf = collections.newStringBuilder();
+    json_next(lx);
+    let v = json_parse_value(lx);
+    if ((v as i64) != 0 && lx.tok != TOK_EOF) {
+        json_parse_error(lx, "unexpected text after the value");
+        freeValue(v);
+        v = 0 as *JsonValue;
+    }
+    collections.freeStringBuilder(lx.buf);
+    heapFree(lx as *u8);
+    return v;
+}
+
+// parse parses text as one JSON value, like parseBytes.
+function parse(text: string): *JsonValue -> {
+    return parseBytes(text, strlen(text));
+}
+
+// ---- Serialization ----
+
+// json_quote appends the n bytes at s to b as a JSON string.
+function json_quote(b: *collections.StringBuilder, s: *u8, n: i64) -> {
+    collections.appendByte(b, 34);
+    let i: i64 = 0;
+    while (i < n) {
+        let c: i64 = s[i] & 255;
+        if (c == 34 || c == 92) {
+            collections.appendByte(b, 92);
+            collections.appendByte(b, c);
+        } else if (c == 10) {
+            collections.append(b, "\\n");
+        } else if (c == 13) {
+            collections.append(b, "\\r");
+        } else if (c == 9) {
+            collections.append(b, "\\t");
+        } else if (c < 32) {
+            collections.append(b, "\\u00");
+            collections.appendByte(b, 48 + (c >> 4));
+            let lo = c & 15;
+            if (lo < 10) {
+                collections.appendByte(b, 48 + lo);
+            } else {
+                collections.appendByte(b, 87 + lo);
+            }
+        } else {
+            collections.appendByte(b, c);
+        }
+        i = i + 1;
+    }
+    collections.appendByte(b, 34);
+}
+
+// json_newline starts a new line indented for depth, when indenting.
+function json_newline(b: *collections.StringBuilder, indent: i64, depth: i64) -> {
+    if (indent <= 0) {
+        return;
+    }
+    collections.appendByte(b, 10);
+    let i: i64 = 0;
+    while (i < indent * depth) {
+        collections.appendByte(b, 32);
+        i = i + 1;
+    }
+}
+
+// json_write appends v to b.
+function json_write(b: *collections.StringBuilder, v: *JsonValue, indent: i64, depth: i64) -> {
+    let k = kind(v);
+    if (k == BOOL) {
+        if (v.int != 0) {
+            collections.append(b, "true");
+        } else {
+            collections.append(b, "false");
+        }
+    } else if (k == NUMBER) {
+        if (v.isInt) {
+            collections.appendInt(b, v.int);
+        } else if (v.num != v.num || v.num - v.num != 0.0) {
+            // NaN and the infinities have no JSON form.
+            collections.append(b, "null");
+        } else if (v.num == 0.0) {
+            collections.appendByte(b, 48);
+        } else {
+            let x = v.num;
+            if (x < 0.0) {
+                collections.appendByte(b, 45);
+                x = -x;
+            }
+            json_digits(b, x);
+        }
+    } else if (k == STRING) {
+        json_quote(b, v.str, v.len);
+    } else if (k == ARRAY || k == OBJECT) {
+        let close: i64 = 93;
+        if (k == ARRAY) {
+            collections.appendByte(b, 91);
+        } else {
+            collections.appendByte(b, 123);
+            close = 125;
+        }
+        let i: i64 = 0;
+        while (i < v.len) {
+            if (i > 0) {
+                collections.appendByte(b, 44);
+            }
+            json_newline(b, indent, depth + 1);
+            if (k == ARRAY) {
+                json_write(b, v.items[i] as *JsonValue, indent, depth + 1);
+            } else {
+                let key = v.items[2 * i] as *u8;
+                json_quote(b, key, strlen(key));
+                collections.appendByte(b, 58);
+                if (indent > 0) {
+                    collections.appendByte(b, 32);
+                }
+                json_write(b, v.items[2 * i + 1] as *JsonValue, indent, depth + 1);
+            }
+            i = i + 1;
+        }
+        if (v.len > 0) {
+            json_newline(b, indent, depth);
+        }
+        collections.appendByte(b, close);
+    } else {
+        collections.append(b, "null");
+    }
+}
+
+// stringify returns v as JSON text, on one line when indent is 0 and
+// otherwise with each item and member on a line of its own, indented by
+// indent spaces per level. The string is allocated with mem.heapAlloc.
+function stringify(v: *JsonValue, indent: i64 = 0): string -> {
+    let b = collections.newStringBuilder();
+    json_write(b, v, indent, 0);
+    let s = collections.toString(b);
+    collections.freeStringBuilder(b);
+    return s;
+}
+
+// ---- Support for data declarations ----
+//
+// The toJson and fromJson functions the compiler derives for data
+// declarations call these. A field of an any type carries its kind and
+// bits as the compiler's any values do: 0 nil, 1 int, 2 float (the bits of
+// an f64), 3 string, 4 bool and 5 pointer, which is taken to be a
+// *JsonValue.
+
+// fromAny returns the value of an any with kind and bits.
+function fromAny(kind: i64, bits: i64): *JsonValue -> {
+    if (kind == 1) {
+        return newInt(bits);
+    }
+    if (kind == 2) {
+        return newNumber(builtin.floatFromBits(bits));
+    }
+    if (kind == 3) {
+        return newString(bits as *u8);
+    }
+    if (kind == 4) {
+        return newBool(bits != 0);
+    }
+    if (kind == 5) {
+        return clone(bits as *JsonValue);
+    }
+    return newNull();
+}
+
+// anyKind returns the kind of any that decodeAnyBits turns v into.
+function anyKind(v: *JsonValue): i64 -> {
+    let k = kind(v);
+    if (k == BOOL) {
+        return 4;
+    }
+    if (k == NUMBER) {
+        if (v.isInt) {
+            return 1;
+        }
+        return 2;
+    }
+    if (k == STRING) {
+        return 3;
+    }
+    if (k == ARRAY || k == OBJECT) {
+        return 5;
+    }
+    return 0;
+}
+
+// decodeAnyBits returns the bits of the any v becomes: strings are copied
+// and arrays and objects cloned.
+function decodeAnyBits(v: *JsonValue): i64 -> {
+    let k = anyKind(v);
+    if (k == 1 || k == 4) {
+        return v.int;
+    }
+    if (k == 2) {
+        return builtin.floatBits(v.num);
+    }
+    if (k == 3) {
+        return json_copy(v.str, v.len) as i64;
+    }
+    if (k == 5) {
+        return clone(v) as i64;
+    }
+    return 0;
+}
+
+// json_mismatch reports that v, the field what, is not of the kind want.
+function json_mismatch(v: *JsonValue, what: string, want: string) -> {
+    let b = collections.newStringBuilder();
+    collections.append(b, what);
+    collections.append(b, " expects ");
+    collections.append(b, want);
+    collections.append(b, ", got ");
+    json_fail(v.line, v.col, collections.builderString(b), kindName(v));
+    collections.freeStringBuilder(b);
+}
+
+// expectObject reports an error unless v, the value of a data type what,
+// is an object.
+function expectObject(v: *JsonValue, what: string): bool -> {
+    if (kind(v) == OBJECT) {
+        return true;
+    }
+    if ((v as i64) == 0) {
+        json_fail(0, 0, what, " expects an object, got null");
+        return false;
+    }
+    json_mismatch(v, what, "an object");
+    return false;
+}
+
+// decodeBool returns the boolean v of the field what. Missing and null
+// fields are false; other kinds are an error.
+function decodeBool(v: *JsonValue, what: string): bool -> {
+    let k = kind(v);
+    if (k != BOOL && k != NULL) {
+        json_mismatch(v, what, "a boolean");
+    }
+    return asBool(v);
+}
+
+// decodeInt returns the integer v of the field what.
+function decodeInt(v: *JsonValue, what: string): i64 -> {
+    let k = kind(v);
+    if (k == NUMBER && v.isInt == 0 && (v.num as i64) as f64 != v.num) {
+        json_mismatch(v, what, "an integer");
+    } else if (k != NUMBER && k != NULL) {
+        json_mismatch(v, what, "an integer");
+    }
+    return asInt(v);
+}
+
+// decodeNumber returns the number v of the field what.
+function decodeNumber(v: *JsonValue, what: string): f64 -> {
+    let k = kind(v);
+    if (k != NUMBER && k != NULL) {
+        json_mismatch(v, what, "a number");
+    }
+    return asNumber(v);
+}
+
+// decodeString returns a copy of the string v of the field what, or a null
+// pointer for a missing or null field.
+function decodeString(v: *JsonValue, what: string): string -> {
+    let k = kind(v);
+    if (k == STRING) {
+        return json_copy(v.str, v.len);
+    }
+    if (k != NULL) {
+        json_mismatch(v, what, "a string");
+    }
+    return 0 as *u8;
+}
+
+// decodeValue returns a copy of v, the field what of type *JsonValue.
+function decodeValue(v: *JsonValue, what: string): *JsonValue -> {
+    if ((v as i64) == 0) {
+        return v;
+    }
+    return clone(v);
+}
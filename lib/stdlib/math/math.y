@@ -0,0 +1,612 @@
+// stdlib/math - integer and floating point math
+// Implemented entirely in Y-lang over compiler intrinsics. No external C
+// runtime is required.
+//
+// sqrt and fabs lower to the LLVM intrinsics, which are instructions on
+// every target. floor, ceil, trunc, exp, log, sin and cos lower to the LLVM
+// intrinsics when libm is linked; freestanding programs, which have no
+// libm, get the software versions below instead. Those are accurate to a
+// unit or two in the last place.
+//
+// The integer functions work on i64 and wrap around on overflow unless they
+// say otherwise; the checked, overflowing and saturating forms of add, sub
+// and mul detect it.
+
+const PI: f64 = 3.141592653589793;
+const E: f64 = 2.718281828459045;
+const LN2: f64 = 0.6931471805599453;
+const SQRT2: f64 = 1.4142135623730951;
+
+const MAX_INT: i64 = 0x7fffffffffffffff;
+const MIN_INT: i64 = 0x8000000000000000;
+
+// The layout of an f64: sign, 11 exponent bits with a bias of 1023 and 52
+// bits of mantissa.
+const SIGN_BIT: i64 = 0x8000000000000000;
+const MANTISSA_MASK: i64 = 0x000fffffffffffff;
+const EXPONENT_ONE: i64 = 0x3ff0000000000000;
+const INF_BITS: i64 = 0x7ff0000000000000;
+const NAN_BITS: i64 = 0x7ff8000000000000;
+
+// ln 2 split in two, the high part with trailing zero bits so k * LN2_HI is
+// exact, and 2/pi and pi/2 likewise, as in fdlibm.
+const LN2_HI: f64 = 0.6931471803691238;
+const LN2_LO: f64 = 0.00000000019082149292705877;
+const INV_LN2: f64 = 1.4426950408889634;
+const PIO2_HI: f64 = 1.5707963267341256;
+const PIO2_LO: f64 = 0.000000000060771005065061922;
+const TWO_OVER_PI: f64 = 0.6366197723675814;
+
+// Beyond these exp overflows to +Inf or underflows to 0.
+const EXP_MAX: f64 = 709.782712893384;
+const EXP_MIN: f64 = -745.1332191019412;
+
+// Every f64 at least this large in magnitude is an integer.
+const TWO_52: f64 = 4503599627370496.0;
+
+// Below this the quadrant k of x is below 2^19 and k * PIO2_HI is exact;
+// sin and cos reduce larger arguments with math_reduce_large.
+const REDUCE_SMALL: f64 = 524288.0;
+
+// ---- Integers ----
+
+// abs returns the absolute value of x. abs(MIN_INT) is MIN_INT.
+function abs(x: i64): i64 -> {
+    if (x < 0) {
+        return -x;
+    }
+    return x;
+}
+
+// min returns the smaller of a and b.
+function min(a: i64, b: i64): i64 -> {
+    if (b < a) {
+        return b;
+    }
+    return a;
+}
+
+// max returns the larger of a and b.
+function max(a: i64, b: i64): i64 -> {
+    if (b > a) {
+        return b;
+    }
+    return a;
+}
+
+// clamp returns x limited to the range lo..hi.
+function clamp(x: i64, lo: i64, hi: i64): i64 -> {
+    if (x < lo) {
+        return lo;
+    }
+    if (x > hi) {
+        return hi;
+    }
+    return x;
+}
+
+// pow returns base raised to exp by repeated squaring. A negative exp gives
+// 0, except for a base of 1 or -1.
+function pow(base: i64, exp: i64): i64 -> {
+    if (exp < 0) {
+        if (base == 1) {
+            return 1;
+        }
+        if (base == -1) {
+            if (exp % 2 == 0) {
+                return 1;
+            }
+            return -1;
+        }
+        return 0;
+    }
+    let result: i64 = 1;
+    while (exp > 0) {
+        if (exp & 1 == 1) {
+            result = result * base;
+        }
+        base = base * base;
+        exp = exp >> 1;
+    }
+    return result;
+}
+
+// isqrt returns the largest integer whose square is at most n, or -1 for a
+// negative n.
+function isqrt(n: i64): i64 -> {
+    if (n < 0) {
+        return -1;
+    }
+    // Find the root a bit pair at a time, from the highest one of n down.
+    let rest = n;
+    let root: i64 = 0;
+    let bit: i64 = 0x4000000000000000;
+    while (bit > n) {
+        bit = bit >> 2;
+    }
+    while (bit != 0) {
+        if (rest >= root + bit) {
+            rest = rest - (root + bit);
+            root = (root >> 1) + bit;
+        } else {
+            root = root >> 1;
+        }
+        bit = bit >> 2;
+    }
+    return root;
+}
+
+// gcd returns the greatest common divisor of a and b, which is never
+// negative; gcd(0, 0) is 0. When both are MIN_INT or 0 but not both 0 the
+// divisor is 2^63, which does not fit in an i64, and gcd returns MIN_INT.
+function gcd(a: i64, b: i64): i64 -> {
+    // Work on a and b made non-positive, as -MIN_INT does not fit.
+    if (a > 0) {
+        a = -a;
+    }
+    if (b > 0) {
+        b = -b;
+    }
+    while (b != 0) {
+        // The remainder of MIN_INT by -1 overflows.
+        if (b == -1) {
+            return 1;
+        }
+        let t = a % b;
+        a = b;
+        b = t;
+    }
+    return -a;
+}
+
+// lcm returns the least common multiple of a and b, or 0 if either is 0.
+function lcm(a: i64, b: i64): i64 -> {
+    if (a == 0 || b == 0) {
+        return 0;
+    }
+    return abs(a / gcd(a, b) * b);
+}
+
+// checkedAdd stores a + b in *out and returns true, or returns false
+// without touching *out when the sum does not fit an i64.
+function checkedAdd(a: i64, b: i64, out: *i64): bool -> {
+    let r: i64 = 0;
+    if (builtin.addOverflow(a, b, &r)) {
+        return false;
+    }
+    out[0] = r;
+    return true;
+}
+
+// checkedSub stores a - b in *out and returns true, or returns false when
+// it overflows.
+function checkedSub(a: i64, b: i64, out: *i64): bool -> {
+    let r: i64 = 0;
+    if (builtin.subOverflow(a, b, &r)) {
+        return false;
+    }
+    out[0] = r;
+    return true;
+}
+
+// checkedMul stores a * b in *out and returns true, or returns false when
+// it overflows.
+function checkedMul(a: i64, b: i64, out: *i64): bool -> {
+    let r: i64 = 0;
+    if (builtin.mulOverflow(a, b, &r)) {
+        return false;
+    }
+    out[0] = r;
+    return true;
+}
+
+// overflowingAdd returns a + b wrapped around, and sets *overflow to
+// whether it overflowed.
+function overflowingAdd(a: i64, b: i64, overflow: *bool): i64 -> {
+    let r: i64 = 0;
+    overflow[0] = builtin.addOverflow(a, b, &r);
+    return r;
+}
+
+// overflowingSub returns a - b wrapped around, and sets *overflow.
+function overflowingSub(a: i64, b: i64, overflow: *bool): i64 -> {
+    let r: i64 = 0;
+    overflow[0] = builtin.subOverflow(a, b, &r);
+    return r;
+}
+
+// overflowingMul returns a * b wrapped around, and sets *overflow.
+function overflowingMul(a: i64, b: i64, overflow: *bool): i64 -> {
+    let r: i64 = 0;
+    overflow[0] = builtin.mulOverflow(a, b, &r);
+    return r;
+}
+
+// saturatingAdd returns a + b, or MAX_INT or MIN_INT when it overflows.
+function saturatingAdd(a: i64, b: i64): i64 -> {
+    let r: i64 = 0;
+    if (builtin.addOverflow(a, b, &r)) {
+        if (b > 0) {
+            return MAX_INT;
+        }
+        return MIN_INT;
+    }
+    return r;
+}
+
+// saturatingSub returns a - b, or MAX_INT or MIN_INT when it overflows.
+function saturatingSub(a: i64, b: i64): i64 -> {
+    let r: i64 = 0;
+    if (builtin.subOverflow(a, b, &r)) {
+        if (b < 0) {
+            return MAX_INT;
+        }
+        return MIN_INT;
+    }
+    return r;
+}
+
+// saturatingMul returns a * b, or MAX_INT or MIN_INT when it overflows.
+function saturatingMul(a: i64, b: i64): i64 -> {
+    let r: i64 = 0;
+    if (builtin.mulOverflow(a, b, &r)) {
+        if ((a < 0) == (b < 0)) {
+            return MAX_INT;
+        }
+        return MIN_INT;
+    }
+    return r;
+}
+
+// ---- Floating point ----
+
+// bits returns the IEEE 754 representation of x.
+function bits(x: f64): i64 -> {
+    return builtin.floatBits(x);
+}
+
+// fromBits returns the f64 with the IEEE 754 representation b.
+function fromBits(b: i64): f64 -> {
+    return builtin.floatFromBits(b);
+}
+
+// inf returns positive infinity for sign >= 0 and negative infinity
+// otherwise.
+function inf(sign: i64 = 1): f64 -> {
+    if (sign < 0) {
+        return fromBits(INF_BITS | SIGN_BIT);
+    }
+    return fromBits(INF_BITS);
+}
+
+// nan returns a quiet NaN.
+function nan(): f64 -> {
+    return fromBits(NAN_BITS);
+}
+
+// isNaN reports whether x is a NaN.
+function isNaN(x: f64): bool -> {
+    return x != x;
+}
+
+// isInf reports whether x is an infinity of the sign of sign, or of either
+// sign when sign is 0.
+function isInf(x: f64, sign: i64 = 0): bool -> {
+    let b = bits(x);
+    if (b & ~SIGN_BIT != INF_BITS) {
+        return false;
+    }
+    return sign == 0 || (sign > 0) == (b >= 0);
+}
+
+// fabs returns the absolute value of x.
+function fabs(x: f64): f64 -> {
+    return builtin.fabs(x);
+}
+
+// copysign returns x with the sign of y.
+function copysign(x: f64, y: f64): f64 -> {
+    return fromBits((bits(x) & ~SIGN_BIT) | (bits(y) & SIGN_BIT));
+}
+
+// sqrt returns the square root of x, NaN for a negative x.
+function sqrt(x: f64): f64 -> {
+    return builtin.sqrt(x);
+}
+
+// trunc returns x without its fraction.
+function trunc(x: f64): f64 -> {
+    if (builtin.libm()) {
+        return builtin.trunc(x);
+    }
+    // NaN fails the comparison and is returned as it is.
+    if (!(fabs(x) < TWO_52)) {
+        return x;
+    }
+    return copysign((x as i64) as f64, x);
+}
+
+// floor returns the largest integer not above x.
+function floor(x: f64): f64 -> {
+    if (builtin.libm()) {
+        return builtin.floor(x);
+    }
+    let t = trunc(x);
+    if (t > x) {
+        return t - 1.0;
+    }
+    return t;
+}
+
+// ceil returns the smallest integer not below x.
+function ceil(x: f64): f64 -> {
+    if (builtin.libm()) {
+        return builtin.ceil(x);
+    }
+    let t = trunc(x);
+    if (t < x) {
+        return t + 1.0;
+    }
+    return t;
+}
+
+// math_round_int returns x rounded to the nearest integer, halves away from
+// zero, as an i64.
+function math_round_int(x: f64): i64 -> {
+    if (x < 0.0) {
+        return (x - 0.5) as i64;
+    }
+    return (x + 0.5) as i64;
+}
+
+// ldexp returns x * 2^e.
+function ldexp(x: f64, e: i64): f64 -> {
+    // Scale in steps that stay within the normal exponent range.
+    while (e > 1023) {
+        x = x * fromBits(0x7fe0000000000000);
+        e = e - 1023;
+    }
+    while (e < -1022) {
+        x = x * fromBits(0x0010000000000000);
+        e = e + 1022;
+    }
+    return x * fromBits((e + 1023) << 52);
+}
+
+// exp returns e^x.
+function exp(x: f64): f64 -> {
+    if (builtin.libm()) {
+        return builtin.exp(x);
+    }
+    if (isNaN(x)) {
+        return x;
+    }
+    if (x > EXP_MAX) {
+        return inf();
+    }
+    if (x < EXP_MIN) {
+        return 0.0;
+    }
+    // x = k ln2 + r with |r| <= ln2 / 2, so e^x = 2^k e^r.
+    let k = math_round_int(x * INV_LN2);
+    let r: f64 = x - (k as f64) * LN2_HI - (k as f64) * LN2_LO;
+    // Taylor series of e^r to r^13 / 13!, evaluated from the inside out.
+    let p: f64 = 1.0;
+    let n: i64 = 13;
+    while (n > 0) {
+        p = 1.0 + p * r / (n as f64);
+        n = n - 1;
+    }
+    return ldexp(p, k);
+}
+
+// log returns the natural logarithm of x: -Inf for 0 and NaN for a
+// negative x.
+function log(x: f64): f64 -> {
+    if (builtin.libm()) {
+        return builtin.log(x);
+    }
+    if (isNaN(x) || x < 0.0) {
+        return nan();
+    }
+    if (x == 0.0) {
+        return inf(-1);
+    }
+    if (isInf(x)) {
+        return x;
+    }
+    // x = m 2^e with m in [sqrt(2)/2, sqrt(2)).
+    let e: i64 = 0;
+    let b = bits(x);
+    if (b >>> 52 == 0) {
+        // Subnormal: scale into the normal range first.
+        b = bits(x * fromBits(0x4350000000000000));
+        e = -54;
+    }
+    e = e + (b >>> 52) - 1023;
+    let m = fromBits((b & MANTISSA_MASK) | EXPONENT_ONE);
+    if (m > SQRT2) {
+        m = m / 2.0;
+        e = e + 1;
+    }
+    // log(m) = 2 atanh(s) = 2 (s + s^3/3 + s^5/5 + ...), s = (m-1)/(m+1).
+    let f: f64 = m - 1.0;
+    let s: f64 = f / (2.0 + f);
+    let z: f64 = s * s;
+    let q: f64 = 0.0;
+    let k: i64 = 11;
+    while (k >= 0) {
+        q = q * z + 1.0 / ((2 * k + 1) as f64);
+        k = k - 1;
+    }
+    return (e as f64) * LN2_HI + (2.0 * s * q + (e as f64) * LN2_LO);
+}
+
+// math_sin_poly returns sin(r) for |r| <= pi/4, from its Taylor series.
+function math_sin_poly(r: f64): f64 -> {
+    let z: f64 = r * r;
+    let s: f64 = 1.0;
+    let n: i64 = 8;
+    while (n > 0) {
+        s = 1.0 - z * s / ((2 * n * (2 * n + 1)) as f64);
+        n = n - 1;
+    }
+    return r * s;
+}
+
+// math_cos_poly returns cos(r) for |r| <= pi/4, from its Taylor series.
+function math_cos_poly(r: f64): f64 -> {
+    let z: f64 = r * r;
+    let c: f64 = 1.0;
+    let n: i64 = 8;
+    while (n > 0) {
+        c = 1.0 - z * c / (((2 * n - 1) * 2 * n) as f64);
+        n = n - 1;
+    }
+    return c;
+}
+
+// math_pio2_bits returns the 24 bits of the binary fraction of 2/pi that
+// follow bit s, from the table of math_reduce_large. Bits before the first
+// are 0.
+function math_pio2_bits(table: []i64, s: i64): i64 -> {
+    let q = s / 24;
+    if (s < 0 && q * 24 != s) {
+        q = q - 1;
+    }
+    let o = s - q * 24;
+    let hi: i64 = 0;
+    if (q >= 0) {
+        hi = table[q];
+    }
+    let lo: i64 = 0;
+    if (q + 1 >= 0) {
+        lo = table[q + 1];
+    }
+    return ((hi << o) | (lo >> (24 - o))) & 0xffffff;
+}
+
+// math_reduce_large returns the quadrant k of x >= REDUCE_SMALL, such that
+// x = (k + f) * pi/2 modulo 2pi with |f| <= 1/2, and stores f * pi/2 in *r.
+// As in the method of Payne and Hanek, only the 192 bits of 2/pi that give
+// the last two bits of the integer part of x * 2/pi and its fraction are
+// multiplied with the mantissa of x; the bits before them only add multiples
+// of 4.
+function math_reduce_large(x: f64, r: *f64): i64 -> {
+    // The binary fraction of 2/pi in 24-bit chunks, enough for any f64.
+    let table: []i64 = [
+        0xA2F983, 0x6E4E44, 0x1529FC, 0x2757D1, 0xF534DD, 0xC0DB62, 0x95993C, 0x439041,
+        0xFE5163, 0xABDEBB, 0xC561B7, 0x246E3A, 0x424DD2, 0xE00649, 0x2EEA09, 0xD1921C,
+        0xFE1DEB, 0x1CB129, 0xA73EE8, 0x8235F5, 0x2EBB44, 0x84E99C, 0x7026B4, 0x5F7E41,
+        0x3991D6, 0x398353, 0x39F49C, 0x845F8B, 0xBDF928, 0x3B1FF8, 0x97FFDE, 0x05980F,
+        0xEF2F11, 0x8B5A0A, 0x6D1F6D, 0x367ECF, 0x27CB09, 0xB74F46, 0x3F669E, 0x5FEA2D,
+        0x7527BA, 0xC7EBE5, 0xF17B3D, 0x0739F7, 0x8A5292, 0xEA6BFB, 0x5FB11F, 0x8D5D08,
+        0x560330, 0x46FC7B
+    ];
+    let b = bits(x);
+    let e = ((b >> 52) & 0x7ff) - 1023;
+    let m = (b & MANTISSA_MASK) + MANTISSA_MASK + 1;
+    let m0 = m & 0xffffff;
+    let m1 = (m >> 24) & 0xffffff;
+    let m2 = m >> 48;
+
+    // x = m * 2^(e-52), so with w the 192 bits of 2/pi after bit e-54,
+    // x * 2/pi modulo 4 is (m * w modulo 2^192) / 2^190. Multiply in 24-bit
+    // digits, least significant first.
+    let p = e - 54;
+    let d: []i64 = [0, 0, 0, 0, 0, 0, 0, 0];
+    let carry: i64 = 0;
+    let i = 0;
+    while (i < 8) {
+        let sum = carry + m0 * math_pio2_bits(table, p + 24 * (7 - i));
+        if (i >= 1) {
+            sum = sum + m1 * math_pio2_bits(table, p + 24 * (8 - i));
+        }
+        if (i >= 2) {
+            sum = sum + m2 * math_pio2_bits(table, p + 24 * (9 - i));
+        }
+        d[i] = sum & 0xffffff;
+        carry = sum >> 24;
+        i = i + 1;
+    }
+
+    // The top two bits are the quadrant and the bits of the six upper digits
+    // below them the fraction, which keeps its precision however close x is
+    // to a multiple of pi/2. A fraction of 1/2 or more is taken as f - 1 in
+    // the next quadrant, its magnitude computed exactly as one minus it.
+    let k = d[7] >> 22;
+    d[7] = d[7] & 0x3fffff;
+    let sign: f64 = 1.0;
+    if (d[7] >= 0x200000) {
+        k = k + 1;
+        sign = -1.0;
+        carry = 1;
+        i = 2;
+        while (i < 8) {
+            let digit = (~d[i] & 0xffffff) + carry;
+            d[i] = digit & 0xffffff;
+            carry = digit >> 24;
+            i = i + 1;
+        }
+        d[7] = d[7] & 0x3fffff;
+    }
+    let f: f64 = 0.0;
+    i = 2;
+    while (i < 7) {
+        f = (f + (d[i] as f64)) / 16777216.0;
+        i = i + 1;
+    }
+    f = sign * ((d[7] as f64) + f) / 4194304.0;
+    *r = f * PIO2_HI + f * PIO2_LO;
+    return k;
+}
+
+// math_sincos returns sin(x) when wantCos is false and cos(x) otherwise,
+// by reducing x to r in [-pi/4, pi/4] and the quadrant k.
+function math_sincos(x: f64, wantCos: bool): f64 -> {
+    if (isNaN(x) || isInf(x)) {
+        return nan();
+    }
+    let k: i64 = 0;
+    let r: f64 = 0.0;
+    if (fabs(x) < REDUCE_SMALL) {
+        k = math_round_int(x * TWO_OVER_PI);
+        r = x - (k as f64) * PIO2_HI - (k as f64) * PIO2_LO;
+    } else {
+        k = math_reduce_large(fabs(x), &r);
+        if (x < 0.0) {
+            k = -k;
+            r = -r;
+        }
+    }
+    if (wantCos) {
+        k = k + 1;
+    }
+    let quadrant = k & 3;
+    if (quadrant == 0) {
+        return math_sin_poly(r);
+    }
+    if (quadrant == 1) {
+        return math_cos_poly(r);
+    }
+    if (quadrant == 2) {
+        return -math_sin_poly(r);
+    }
+    return -math_cos_poly(r);
+}
+
+// sin returns the sine of x, in radians.
+function sin(x: f64): f64 -> {
+    if (builtin.libm()) {
+        return builtin.sin(x);
+    }
+    return math_sincos(x, false);
+}
+
+// cos returns the cosine of x, in radians.
+function cos(x: f64): f64 -> {
+    if (builtin.libm()) {
+        return builtin.cos(x);
+    }
+    return math_sincos(x, true);
+}
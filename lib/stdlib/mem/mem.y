@@ -0,0 +1,191 @@
+// stdlib/mem - raw memory allocation
+// Implemented entirely in Y-lang via Linux syscalls.
+// No external C runtime is required.
+//
+// mmap(2) arguments
+const PROT_READ = 1;
+const PROT_WRITE = 2;
+const MAP_PRIVATE = 0x02;
+const MAP_ANONYMOUS = 0x20;
+
+// alloc returns size bytes of zeroed memory backed by a private anonymous
+// read-write mapping. Every call maps at least one page, so prefer few large
+// buffers.
+function alloc(size: i64): *u8 -> {
+    return syscall(SYS.mmap, 0, size, PROT_READ + PROT_WRITE, MAP_PRIVATE + MAP_ANONYMOUS, -1, 0);
+}
+
+// free returns memory obtained from alloc to the system. size must be the
+// size passed to alloc (or any length covering the same pages).
+function free(p: *u8, size: i64) -> {
+    syscall(SYS.munmap, p, size, 0, 0, 0, 0);
+}
+
+// copy copies n bytes from src to dst. The regions must not overlap.
+function copy(dst: *u8, src: *u8, n: i64) -> {
+    let i: i64 = 0;
+    while (i < n) {
+        dst[i] = src[i];
+        i = i + 1;
+    }
+}
+
+// zero sets n bytes at p to 0.
+function zero(p: *u8, n: i64) -> {
+    let words = p as *i64;
+    let i: i64 = 0;
+    while (i < n / 8) {
+        words[i] = 0;
+        i = i + 1;
+    }
+    i = i * 8;
+    while (i < n) {
+        p[i] = 0;
+        i = i + 1;
+    }
+}
+
+// The heap: heapAlloc, heapRealloc and heapFree hand out blocks of any size
+// without a mapping per call. Every block starts with a 16-byte header that
+// holds the block size and the size asked for; the bytes past the size asked
+// for are kept zero. Blocks of up to HEAP_MAX_CLASS bytes, header included,
+// come in power-of-two size classes carved from HEAP_CHUNK mappings, with a
+// free list per class; larger blocks are mappings of their own and go back
+// to the system when freed.
+const HEAP_HEADER = 16;
+const HEAP_MIN_CLASS = 32;
+const HEAP_MAX_CLASS = 32768;
+const HEAP_CLASSES = 11;
+const HEAP_CHUNK = 65536;
+const PAGE_SIZE = 4096;
+
+// heap_lock is a spin lock guarding the free lists; heap_lists is the
+// address of their heads, one word per class, mapped on first use.
+let heap_lock: i64 = 0;
+let heap_lists: i64 = 0;
+
+function heap_acquire() -> {
+    while (!builtin.atomicCas(&heap_lock, 0, 1, 3)) {
+        syscall(SYS.sched_yield, 0, 0, 0, 0, 0, 0);
+    }
+}
+
+function heap_release() -> {
+    builtin.atomicStore(&heap_lock, 0, 2);
+}
+
+// heap_class returns the size class of a block of blk bytes.
+function heap_class(blk: i64): i64 -> {
+    let c: i64 = 0;
+    while ((HEAP_MIN_CLASS << c) < blk) {
+        c = c + 1;
+    }
+    return c;
+}
+
+// heap_refill maps a chunk and adds its blocks to free list c.
+function heap_refill(lists: *i64, c: i64): bool -> {
+    let chunk = alloc(HEAP_CHUNK) as i64;
+    if (chunk < 0) {
+        return false;
+    }
+    let blk: i64 = HEAP_MIN_CLASS << c;
+    let off: i64 = HEAP_CHUNK - blk;
+    while (off >= 0) {
+        let b = (chunk + off) as *i64;
+        b[0] = lists[c];
+        lists[c] = chunk + off;
+        off = off - blk;
+    }
+    return true;
+}
+
+// heapAlloc returns size bytes of zeroed memory, 16-byte aligned, or a null
+// pointer when the system is out of memory. Release it with heapFree.
+function heapAlloc(size: i64): *u8 -> {
+    if (size < 0) {
+        return 0 as *u8;
+    }
+    let need = size + HEAP_HEADER;
+    let block = 0 as *i64;
+    if (need > HEAP_MAX_CLASS) {
+        let total = (need + PAGE_SIZE - 1) & ~(PAGE_SIZE - 1);
+        let m = alloc(total);
+        if ((m as i64) < 0) {
+            return 0 as *u8;
+        }
+        block = m as *i64;
+        block[0] = total;
+    } else {
+        let c = heap_class(need);
+        heap_acquire();
+        if (heap_lists == 0) {
+            heap_lists = alloc(HEAP_CLASSES * 8) as i64;
+        }
+        let lists = heap_lists as *i64;
+        if (lists[c] == 0 && !heap_refill(lists, c)) {
+            heap_release();
+            return 0 as *u8;
+        }
+        block = lists[c] as *i64;
+        lists[c] = block[0];
+        heap_release();
+        block[0] = HEAP_MIN_CLASS << c;
+    }
+    block[1] = size;
+    return (block as *u8) + HEAP_HEADER;
+}
+
+// heapFree releases memory obtained from heapAlloc or heapRealloc. Freeing
+// a null pointer does nothing.
+function heapFree(p: *u8) -> {
+    if ((p as i64) == 0) {
+        return;
+    }
+    let block = ((p as i64) - HEAP_HEADER) as *i64;
+    let blk = block[0];
+    if (blk > HEAP_MAX_CLASS) {
+        free(block as *u8, blk);
+        return;
+    }
+    zero(p, block[1]);
+    block[1] = 0;
+    let c = heap_class(blk);
+    heap_acquire();
+    let lists = heap_lists as *i64;
+    block[0] = lists[c];
+    lists[c] = block as i64;
+    heap_release();
+}
+
+// heapSize returns the size p was allocated or last reallocated with.
+function heapSize(p: *u8): i64 -> {
+    let block = ((p as i64) - HEAP_HEADER) as *i64;
+    return block[1];
+}
+
+// heapRealloc resizes the memory at p to size bytes, moving it when it does
+// not fit its block, and returns its new address. Bytes past the old size
+// are zero. A null p allocates; on failure the result is null and p is left
+// untouched.
+function heapRealloc(p: *u8, size: i64): *u8 -> {
+    if ((p as i64) == 0) {
+        return heapAlloc(size);
+    }
+    let block = ((p as i64) - HEAP_HEADER) as *i64;
+    let old = block[1];
+    if (size >= 0 && size <= block[0] - HEAP_HEADER) {
+        if (size < old) {
+            zero(p + size, old - size);
+        }
+        block[1] = size;
+        return p;
+    }
+    let q = heapAlloc(size);
+    if ((q as i64) == 0) {
+        return q;
+    }
+    copy(q, p, old);
+    heapFree(p);
+    return q;
+}
@@ -0,0 +1,973 @@
+// stdlib/net - sockets, IPv4 and IPv6 addresses, and a minimal HTTP/1.1
+// Implemented entirely in Y-lang via Linux syscalls.
+// No external C runtime is required.
+//
+// Addresses are IP literals with a port; there is no name resolution. An
+// Addr holds the kernel's sockaddr_in or sockaddr_in6, so it is passed to
+// bind, connect and sendto as is.
+//
+// Sockets are plain file descriptors: blocking unless created with
+// SOCK_NONBLOCK, usable with stdlib/io and stdlib/async.
+//
+// parseRequest and readRequest read an HTTP/1.1 request line, header fields
+// and a body of Content-Length bytes; chunked bodies are not supported.
+// respond and the write functions produce a response.
+//
+// Operations return a non-negative value on success and a negative error
+// code (-errno) on failure. Functions returning strings or Addrs allocate
+// them with mem.alloc.
+
+import "stdlib/core/string"
+import "stdlib/mem"
+import "stdlib/io"
+import "stdlib/sys"
+
+// Address families.
+const AF_INET = 2;
+const AF_INET6 = 10;
+
+// Socket types and flags for socket.
+const SOCK_STREAM = 1;
+const SOCK_DGRAM = 2;
+const SOCK_NONBLOCK = 2048;
+const SOCK_CLOEXEC = 524288;
+
+// Levels and options of setsockopt.
+const SOL_SOCKET = 1;
+const SO_REUSEADDR = 2;
+const SO_BROADCAST = 6;
+const SO_KEEPALIVE = 9;
+const IPPROTO_TCP = 6;
+const TCP_NODELAY = 1;
+const IPPROTO_IPV6 = 41;
+const IPV6_V6ONLY = 26;
+
+// How of shutdown.
+const SHUT_RD = 0;
+const SHUT_WR = 1;
+const SHUT_RDWR = 2;
+
+// send without raising SIGPIPE on a closed connection.
+const MSG_NOSIGNAL = 16384;
+
+// Bytes to allocate for an Addr, enough for a sockaddr_in6.
+const ADDR_SIZE = 32;
+
+// Addr is an IP address and port: a sockaddr_in for AF_INET, with the
+// address at offset 4, or a sockaddr_in6 for AF_INET6, with the address at
+// offset 8. Use port(a) to read the port.
+extern type Addr {
+    let family: u16;
+    // portBE is the port in network byte order.
+    let portBE: u16;
+    let flowinfo: u32;
+}
+
+// Characters used by the parsers.
+const CHAR_TAB = 9;
+const CHAR_LF = 10;
+const CHAR_CR = 13;
+const CHAR_SPACE = 32;
+const CHAR_DOT = 46;
+const CHAR_COLON = 58;
+const CHAR_LBRACKET = 91;
+const CHAR_RBRACKET = 93;
+
+// net_byte returns p[i] as an unsigned value.
+function net_byte(p: *u8, i: i64): i64 -> {
+    return (p[i] as i64 + 256) % 256;
+}
+
+// net_swap16 converts a 16-bit value between host and network byte order.
+function net_swap16(v: i64): i64 -> {
+    v = (v + 65536) % 65536;
+    return v % 256 * 256 + v / 256;
+}
+
+// net_ip returns the address bytes of a.
+function net_ip(a: *Addr): *u8 -> {
+    if (a.family == AF_INET6) {
+        return (a as *u8) + 8;
+    }
+    return (a as *u8) + 4;
+}
+
+// newAddr returns an Addr of family with port and a zero address.
+function newAddr(family: i64, port: i64): *Addr -> {
+    let a = alloc(ADDR_SIZE) as *Addr;
+    a.family = family;
+    a.portBE = net_swap16(port);
+    return a;
+}
+
+// ipv4 returns the IPv4 address a.b.c.d with port.
+function ipv4(a: i64, b: i64, c: i64, d: i64, port: i64): *Addr -> {
+    let addr = newAddr(AF_INET, port);
+    let ip = net_ip(addr);
+    ip[0] = a;
+    ip[1] = b;
+    ip[2] = c;
+    ip[3] = d;
+    return addr;
+}
+
+// family returns AF_INET or AF_INET6.
+function family(a: *Addr): i64 -> {
+    return a.family as i64;
+}
+
+// port returns the port of a.
+function port(a: *Addr): i64 -> {
+    return net_swap16(a.portBE as i64);
+}
+
+// setPort changes the port of a.
+function setPort(a: *Addr, port: i64) -> {
+    a.portBE = net_swap16(port);
+}
+
+// addrLen returns the size of the sockaddr in a, as the socket calls take.
+function addrLen(a: *Addr): i64 -> {
+    if (a.family == AF_INET6) {
+        return 28;
+    }
+    return 16;
+}
+
+// net_dec returns the value of the decimal digit c, or -1.
+function net_dec(c: i64): i64 -> {
+    if (c >= 48 && c <= 57) {
+        return c - 48;
+    }
+    return -1;
+}
+
+// net_hex returns the value of the hexadecimal digit c, or -1.
+function net_hex(c: i64): i64 -> {
+    if (c >= 48 && c <= 57) {
+        return c - 48;
+    }
+    if (c >= 97 && c <= 102) {
+        return c - 87;
+    }
+    if (c >= 65 && c <= 70) {
+        return c - 55;
+    }
+    return -1;
+}
+
+// net_parse_ip4 parses the n bytes of s as a dotted IPv4 address into the
+// 4 bytes at out. Parts with leading zeros are rejected, as they could be
+// meant as octal.
+function net_parse_ip4(s: *u8, n: i64, out: *u8): bool -> {
+    let i = 0;
+    let part = 0;
+    while (part < 4) {
+        let start = i;
+        let v = 0;
+        while (i < n && net_dec(s[i]) >= 0) {
+            v = v * 10 + net_dec(s[i]);
+            if (v > 255) {
+                return false;
+            }
+            i = i + 1;
+        }
+        if (i == start || (i - start > 1 && s[start] == 48)) {
+            return false;
+        }
+        out[part] = v;
+        part = part + 1;
+        if (part < 4) {
+            if (i >= n || s[i] != CHAR_DOT) {
+                return false;
+            }
+            i = i + 1;
+        }
+    }
+    return i == n;
+}
+
+// net_parse_ip6 parses the n bytes of s as an IPv6 address into the 16
+// bytes at out. "::" stands for one or more zero groups and the last 32
+// bits may be written as an IPv4 address, as in "::ffff:10.0.0.1".
+function net_parse_ip6(s: *u8, n: i64, out: *u8): bool -> {
+    let i = 0;
+    let groups = 0;
+    // gap is the group where "::" was, or -1.
+    let gap = -1;
+    if (n >= 2 && s[0] == CHAR_COLON && s[1] == CHAR_COLON) {
+        gap = 0;
+        i = 2;
+    }
+    while (i < n) {
+        if (groups == 8) {
+            return false;
+        }
+        let start = i;
+        let v = 0;
+        while (i < n && i - start < 4 && net_hex(s[i]) >= 0) {
+            v = v * 16 + net_hex(s[i]);
+            i = i + 1;
+        }
+        if (i == start) {
+            return false;
+        }
+        if (i < n && s[i] == CHAR_DOT) {
+            // An IPv4 tail ends the address.
+            if (groups > 6 || !net_parse_ip4(s + start, n - start, out + groups * 2)) {
+                return false;
+            }
+            groups = groups + 2;
+            i = n;
+        } else {
+            out[groups * 2] = v / 256;
+            out[groups * 2 + 1] = v % 256;
+            groups = groups + 1;
+            if (i < n) {
+                if (s[i] != CHAR_COLON || i + 1 == n) {
+                    return false;
+                }
+                i = i + 1;
+                if (s[i] == CHAR_COLON) {
+                    if (gap >= 0) {
+                        return false;
+                    }
+                    gap = groups;
+                    i = i + 1;
+                }
+            }
+        }
+    }
+    if (gap < 0) {
+        return groups == 8;
+    }
+    if (groups == 8) {
+        return false;
+    }
+    // Move the groups after "::" to the end and zero the ones between.
+    let tail = (groups - gap) * 2;
+    let k = 1;
+    while (k <= tail) {
+        out[16 - k] = out[gap * 2 + tail - k];
+        k = k + 1;
+    }
+    let z = gap * 2;
+    while (z < 16 - tail) {
+        out[z] = 0;
+        z = z + 1;
+    }
+    return true;
+}
+
+// net_parse_ip parses the n bytes of s as an IPv4 or IPv6 address with
+// port. It returns 0 when they are not an address.
+function net_parse_ip(s: *u8, n: i64, port: i64): *Addr -> {
+    let v6 = false;
+    let i = 0;
+    while (i < n) {
+        if (s[i] == CHAR_COLON) {
+            v6 = true;
+        }
+        i = i + 1;
+    }
+    let a = newAddr(AF_INET, port);
+    if (v6) {
+        a.family = AF_INET6;
+    }
+    let ok = false;
+    if (v6) {
+        ok = net_parse_ip6(s, n, net_ip(a));
+    } else {
+        ok = net_parse_ip4(s, n, net_ip(a));
+    }
+    if (!ok) {
+        free(a as *u8, ADDR_SIZE);
+        return 0 as *Addr;
+    }
+    return a;
+}
+
+// parseIP parses an IPv4 address such as "127.0.0.1" or an IPv6 address such
+// as "::1" and returns it with port. It returns 0 when s is not an address.
+function parseIP(s: string, port: i64 = 0): *Addr -> {
+    return net_parse_ip(s, strlen(s), port);
+}
+
+// parseAddr parses "host:port", where host is an IPv4 address or an IPv6
+// address in brackets, as in "127.0.0.1:80" or "[::1]:8080". It returns 0
+// when s is not such an address.
+function parseAddr(s: string): *Addr -> {
+    let n = strlen(s);
+    let hostStart = 0;
+    let hostEnd = 0;
+    let colon = 0;
+    if (n > 0 && s[0] == CHAR_LBRACKET) {
+        hostStart = 1;
+        hostEnd = 1;
+        while (hostEnd < n && s[hostEnd] != CHAR_RBRACKET) {
+            hostEnd = hostEnd + 1;
+        }
+        colon = hostEnd + 1;
+    } else {
+        while (hostEnd < n && s[hostEnd] != CHAR_COLON) {
+            hostEnd = hostEnd + 1;
+        }
+        colon = hostEnd;
+    }
+    if (colon >= n || s[colon] != CHAR_COLON || colon + 1 == n || colon + 6 < n) {
+        return 0 as *Addr;
+    }
+    let p = 0;
+    let i = colon + 1;
+    while (i < n) {
+        if (net_dec(s[i]) < 0) {
+            return 0 as *Addr;
+        }
+        p = p * 10 + net_dec(s[i]);
+        i = i + 1;
+    }
+    if (p > 65535) {
+        return 0 as *Addr;
+    }
+    let a = net_parse_ip(s + hostStart, hostEnd - hostStart, p);
+    if ((a as i64) == 0) {
+        return a;
+    }
+    // Brackets are for IPv6 only, which in turn needs them.
+    if ((hostStart == 1) != (a.family == AF_INET6)) {
+        free(a as *u8, ADDR_SIZE);
+        return 0 as *Addr;
+    }
+    return a;
+}
+
+// net_put_dec writes v in decimal at buf + pos and returns the new position.
+function net_put_dec(buf: *u8, pos: i64, v: i64): i64 -> {
+    if (v >= 10) {
+        pos = net_put_dec(buf, pos, v / 10);
+    }
+    buf[pos] = 48 + v % 10;
+    return pos + 1;
+}
+
+// net_put_hex writes v in lowercase hexadecimal without leading zeros at
+// buf + pos and returns the new position.
+function net_put_hex(buf: *u8, pos: i64, v: i64): i64 -> {
+    if (v >= 16) {
+        pos = net_put_hex(buf, pos, v / 16);
+    }
+    let d = v % 16;
+    if (d < 10) {
+        buf[pos] = 48 + d;
+    } else {
+        buf[pos] = 87 + d;
+    }
+    return pos + 1;
+}
+
+// net_put_ip4 writes the dotted form of the 4 bytes at ip and returns the
+// new position.
+function net_put_ip4(buf: *u8, pos: i64, ip: *u8): i64 -> {
+    let i = 0;
+    while (i < 4) {
+        if (i > 0) {
+            buf[pos] = CHAR_DOT;
+            pos = pos + 1;
+        }
+        pos = net_put_dec(buf, pos, net_byte(ip, i));
+        i = i + 1;
+    }
+    return pos;
+}
+
+// net_put_ip writes the address of a at buf + pos and returns the new
+// position. IPv6 addresses take their canonical form (RFC 5952): lowercase,
+// the longest run of two or more zero groups shortened to "::", and
+// IPv4-mapped addresses ending in dotted form.
+function net_put_ip(buf: *u8, pos: i64, a: *Addr): i64 -> {
+    let ip = net_ip(a);
+    if (a.family != AF_INET6) {
+        return net_put_ip4(buf, pos, ip);
+    }
+    let mapped = net_byte(ip, 10) == 255 && net_byte(ip, 11) == 255;
+    let i = 0;
+    while (i < 10) {
+        if (ip[i] != 0) {
+            mapped = false;
+        }
+        i = i + 1;
+    }
+    if (mapped) {
+        copy(buf + pos, "::ffff:", 7);
+        return net_put_ip4(buf, pos + 7, ip + 12);
+    }
+
+    let bestStart = -1;
+    let bestLen = 1;
+    let g = 0;
+    while (g < 8) {
+        let run = 0;
+        while (g + run < 8 && ip[(g + run) * 2] == 0 && ip[(g + run) * 2 + 1] == 0) {
+            run = run + 1;
+        }
+        if (run > bestLen) {
+            bestStart = g;
+            bestLen = run;
+        }
+        g = g + run + 1;
+    }
+    g = 0;
+    while (g < 8) {
+        if (g == bestStart) {
+            buf[pos] = CHAR_COLON;
+            buf[pos + 1] = CHAR_COLON;
+            pos = pos + 2;
+            g = g + bestLen;
+        } else {
+            if (g > 0 && g != bestStart + bestLen) {
+                buf[pos] = CHAR_COLON;
+                pos = pos + 1;
+            }
+            pos = net_put_hex(buf, pos, net_byte(ip, g * 2) * 256 + net_byte(ip, g * 2 + 1));
+            g = g + 1;
+        }
+    }
+    return pos;
+}
+
+// formatIP returns the address of a without the port, e.g. "10.0.0.1" or
+// "fe80::1".
+function formatIP(a: *Addr): string -> {
+    let buf = alloc(48);
+    net_put_ip(buf, 0, a);
+    return buf;
+}
+
+// formatAddr returns a as "ip:port", with an IPv6 address in brackets, as
+// parseAddr accepts it.
+function formatAddr(a: *Addr): string -> {
+    let buf = alloc(64);
+    let pos = 0;
+    if (a.family == AF_INET6) {
+        buf[0] = CHAR_LBRACKET;
+        pos = net_put_ip(buf, 1, a);
+        buf[pos] = CHAR_RBRACKET;
+        pos = pos + 1;
+    } else {
+        pos = net_put_ip(buf, 0, a);
+    }
+    buf[pos] = CHAR_COLON;
+    net_put_dec(buf, pos + 1, port(a));
+    return buf;
+}
+
+// socket creates a socket of family (AF_INET, AF_INET6) and kind
+// (SOCK_STREAM, SOCK_DGRAM, plus SOCK_NONBLOCK), closed in programs started
+// with stdlib/process.
+function socket(family: i64, kind: i64): i64 -> {
+    return syscall(SYS.socket, family, kind + SOCK_CLOEXEC, 0, 0, 0, 0);
+}
+
+// setOption sets the integer socket option name at level to value.
+function setOption(fd: i64, level: i64, name: i64, value: i64): i64 -> {
+    let v: i32 = value;
+    return syscall(SYS.setsockopt, fd, level, name, &v, 4, 0);
+}
+
+// setNoDelay turns off Nagle's algorithm on a TCP socket when on is true.
+function setNoDelay(fd: i64, on: bool): i64 -> {
+    let v = 0;
+    if (on) {
+        v = 1;
+    }
+    return setOption(fd, IPPROTO_TCP, TCP_NODELAY, v);
+}
+
+// bind binds fd to the address a.
+function bind(fd: i64, a: *Addr): i64 -> {
+    return syscall(SYS.bind, fd, a, addrLen(a), 0, 0, 0);
+}
+
+// listen marks fd as accepting connections, queueing up to backlog.
+function listen(fd: i64, backlog: i64 = 128): i64 -> {
+    return syscall(SYS.listen, fd, backlog, 0, 0, 0, 0);
+}
+
+// accept waits for a connection on the listening socket fd and returns its
+// socket. When peer is not 0 it receives the address of the other end.
+function accept(fd: i64, peer: *Addr = 0 as *Addr, flags: i64 = 0): i64 -> {
+    let len: i32 = ADDR_SIZE;
+    let r = syscall(SYS.accept4, fd, peer, &len, flags + SOCK_CLOEXEC, 0, 0);
+    while (r == -sys.EINTR) {
+        r = syscall(SYS.accept4, fd, peer, &len, flags + SOCK_CLOEXEC, 0, 0);
+    }
+    return r;
+}
+
+// connect connects fd to a.
+function connect(fd: i64, a: *Addr): i64 -> {
+    return syscall(SYS.connect, fd, a, addrLen(a), 0, 0, 0);
+}
+
+// localAddr returns the address fd is bound to, e.g. to learn the port
+// picked for port 0, or 0 on failure.
+function localAddr(fd: i64): *Addr -> {
+    let a = alloc(ADDR_SIZE) as *Addr;
+    let len: i32 = ADDR_SIZE;
+    if (syscall(SYS.getsockname, fd, a, &len, 0, 0, 0) < 0) {
+        free(a as *u8, ADDR_SIZE);
+        return 0 as *Addr;
+    }
+    return a;
+}
+
+// peerAddr returns the address of the other end of the connected socket
+// fd, or 0 on failure.
+function peerAddr(fd: i64): *Addr -> {
+    let a = alloc(ADDR_SIZE) as *Addr;
+    let len: i32 = ADDR_SIZE;
+    if (syscall(SYS.getpeername, fd, a, &len, 0, 0, 0) < 0) {
+        free(a as *u8, ADDR_SIZE);
+        return 0 as *Addr;
+    }
+    return a;
+}
+
+// listenTCP returns a socket listening on a, with SO_REUSEADDR set so a
+// restarted server can bind again at once.
+function listenTCP(a: *Addr, backlog: i64 = 128): i64 -> {
+    let fd = socket(a.family as i64, SOCK_STREAM);
+    if (fd < 0) {
+        return fd;
+    }
+    setOption(fd, SOL_SOCKET, SO_REUSEADDR, 1);
+    let r = bind(fd, a);
+    if (r >= 0) {
+        r = listen(fd, backlog);
+    }
+    if (r < 0) {
+        syscall(SYS.close, fd, 0, 0, 0, 0, 0);
+        return r;
+    }
+    return fd;
+}
+
+// dialTCP returns a socket connected to a.
+function dialTCP(a: *Addr): i64 -> {
+    let fd = socket(a.family as i64, SOCK_STREAM);
+    if (fd < 0) {
+        return fd;
+    }
+    let r = connect(fd, a);
+    if (r < 0) {
+        syscall(SYS.close, fd, 0, 0, 0, 0, 0);
+        return r;
+    }
+    return fd;
+}
+
+// listenUDP returns a datagram socket bound to a.
+function listenUDP(a: *Addr): i64 -> {
+    let fd = socket(a.family as i64, SOCK_DGRAM);
+    if (fd < 0) {
+        return fd;
+    }
+    let r = bind(fd, a);
+    if (r < 0) {
+        syscall(SYS.close, fd, 0, 0, 0, 0, 0);
+        return r;
+    }
+    return fd;
+}
+
+// send writes up to n bytes of buf to the connected socket fd and returns
+// how many were sent. A closed connection gives -EPIPE, not SIGPIPE.
+function send(fd: i64, buf: *u8, n: i64): i64 -> {
+    let r = syscall(SYS.sendto, fd, buf, n, MSG_NOSIGNAL, 0, 0);
+    while (r == -sys.EINTR) {
+        r = syscall(SYS.sendto, fd, buf, n, MSG_NOSIGNAL, 0, 0);
+    }
+    return r;
+}
+
+// sendAll sends all n bytes of buf, returning n or the first error.
+function sendAll(fd: i64, buf: *u8, n: i64): i64 -> {
+    let done = 0;
+    while (done < n) {
+        let r = send(fd, buf + done, n - done);
+        if (r < 0) {
+            return r;
+        }
+        done = done + r;
+    }
+    return n;
+}
+
+// recv reads up to n bytes from the connected socket fd into buf. It
+// returns 0 when the other end has closed the connection.
+function recv(fd: i64, buf: *u8, n: i64): i64 -> {
+    let r = syscall(SYS.recvfrom, fd, buf, n, 0, 0, 0);
+    while (r == -sys.EINTR) {
+        r = syscall(SYS.recvfrom, fd, buf, n, 0, 0, 0);
+    }
+    return r;
+}
+
+// sendTo sends the datagram of n bytes at buf to a.
+function sendTo(fd: i64, buf: *u8, n: i64, a: *Addr): i64 -> {
+    return syscall(SYS.sendto, fd, buf, n, MSG_NOSIGNAL, a, addrLen(a));
+}
+
+// recvFrom receives a datagram of up to n bytes into buf. When from is not
+// 0 it receives the sender's address.
+function recvFrom(fd: i64, buf: *u8, n: i64, from: *Addr = 0 as *Addr): i64 -> {
+    let len: i32 = ADDR_SIZE;
+    let r = syscall(SYS.recvfrom, fd, buf, n, 0, from, &len);
+    while (r == -sys.EINTR) {
+        r = syscall(SYS.recvfrom, fd, buf, n, 0, from, &len);
+    }
+    return r;
+}
+
+// shutdown shuts down reading (SHUT_RD), writing (SHUT_WR) or both
+// (SHUT_RDWR) on fd.
+function shutdown(fd: i64, how: i64): i64 -> {
+    return syscall(SYS.shutdown, fd, how, 0, 0, 0, 0);
+}
+
+// close closes the socket fd.
+function close(fd: i64): i64 -> {
+    return syscall(SYS.close, fd, 0, 0, 0, 0, 0);
+}
+
+// Header fields kept per Request.
+const MAX_HEADERS = 64;
+
+// Request is an HTTP request read by parseRequest. Its strings are null
+// terminated and live in memory owned by the request; freeRequest releases
+// it.
+extern type Request {
+    let method: string;
+    let target: string;
+    let version: string;
+    // names and values hold headerCount header fields in the order received,
+    // with the values stripped of surrounding whitespace.
+    let names: *string;
+    let values: *string;
+    let headerCount: i64;
+    let body: *u8;
+    let bodyLen: i64;
+    let mem: *u8;
+    let memSize: i64;
+}
+
+const REQUEST_SIZE = 96;
+
+// newRequest returns an empty Request for parseRequest.
+function newRequest(): *Request -> {
+    let req = alloc(REQUEST_SIZE + MAX_HEADERS * 16) as *Request;
+    req.names = ((req as *u8) + REQUEST_SIZE) as *string;
+    req.values = ((req as *u8) + REQUEST_SIZE + MAX_HEADERS * 8) as *string;
+    return req;
+}
+
+// net_reset_request releases what the last parse left in req.
+function net_reset_request(req: *Request) -> {
+    if (req.memSize > 0) {
+        free(req.mem, req.memSize);
+    }
+    req.mem = 0 as *u8;
+    req.memSize = 0;
+    req.headerCount = 0;
+    req.bodyLen = 0;
+    req.method = "";
+    req.target = "";
+    req.version = "";
+    req.body = "" as *u8;
+}
+
+// freeRequest releases req.
+function freeRequest(req: *Request) -> {
+    net_reset_request(req);
+    free(req as *u8, REQUEST_SIZE + MAX_HEADERS * 16);
+}
+
+// net_lower returns c in lowercase if it is an ASCII letter.
+function net_lower(c: i64): i64 -> {
+    if (c >= 65 && c <= 90) {
+        return c + 32;
+    }
+    return c;
+}
+
+// net_equal_fold reports whether a and b are equal ignoring ASCII case.
+function net_equal_fold(a: string, b: string): bool -> {
+    let i = 0;
+    while (a[i] != 0 && net_lower(a[i]) == net_lower(b[i])) {
+        i = i + 1;
+    }
+    return a[i] == 0 && b[i] == 0;
+}
+
+// header returns the value of the first header field called name, ignoring
+// case, or "" when there is none.
+function header(req: *Request, name: string): string -> {
+    let i = 0;
+    while (i < req.headerCount) {
+        if (net_equal_fold(req.names[i], name)) {
+            return req.values[i];
+        }
+        i = i + 1;
+    }
+    return "";
+}
+
+// net_is_space reports whether c is a space or tab.
+function net_is_space(c: i64): bool -> {
+    return c == CHAR_SPACE || c == CHAR_TAB;
+}
+
+// net_header_end returns the length of the request line and header fields
+// at buf, including the empty line ending them, or 0 if that line has not
+// arrived. Lines end in CRLF or a bare LF.
+function net_header_end(buf: *u8, n: i64): i64 -> {
+    let i = 0;
+    while (i < n) {
+        if (buf[i] == CHAR_LF) {
+            if (i + 1 < n && buf[i + 1] == CHAR_LF) {
+                return i + 2;
+            }
+            if (i + 2 < n && buf[i + 1] == CHAR_CR && buf[i + 2] == CHAR_LF) {
+                return i + 3;
+            }
+        }
+        i = i + 1;
+    }
+    return 0;
+}
+
+// net_line_end returns the index of the LF ending the line at start, and
+// cuts the line off with a null in place of its CR or LF.
+function net_line_end(p: *u8, start: i64): i64 -> {
+    let i = start;
+    while (p[i] != CHAR_LF) {
+        i = i + 1;
+    }
+    if (i > start && p[i - 1] == CHAR_CR) {
+        p[i - 1] = 0;
+    }
+    p[i] = 0;
+    return i;
+}
+
+// net_parse_head parses the request line and header fields copied to
+// req.mem. It returns 0, or a negative HTTP status for a malformed request.
+function net_parse_head(req: *Request): i64 -> {
+    let p = req.mem;
+    let eol = net_line_end(p, 0);
+    // METHOD SP target SP HTTP/1.x
+    let sp1 = 0;
+    while (sp1 < eol && p[sp1] != CHAR_SPACE) {
+        sp1 = sp1 + 1;
+    }
+    let sp2 = sp1 + 1;
+    while (sp2 < eol && p[sp2] != CHAR_SPACE) {
+        sp2 = sp2 + 1;
+    }
+    if (sp1 == 0 || sp2 >= eol || sp2 == sp1 + 1) {
+        return -400;
+    }
+    p[sp1] = 0;
+    p[sp2] = 0;
+    req.method = p;
+    req.target = p + sp1 + 1;
+    req.version = p + sp2 + 1;
+    let v = req.version;
+    if (strlen(v) != 8 || v[0] != 72 || v[1] != 84 || v[2] != 84 || v[3] != 80 || v[4] != 47 || v[5] != 49 || v[6] != CHAR_DOT) {
+        return -400;
+    }
+
+    let pos = eol + 1;
+    while (p[pos] != CHAR_LF && !(p[pos] == CHAR_CR && p[pos + 1] == CHAR_LF)) {
+        eol = net_line_end(p, pos);
+        let colon = pos;
+        while (colon < eol && p[colon] != CHAR_COLON) {
+            colon = colon + 1;
+        }
+        if (colon == pos || colon >= eol || net_is_space(p[colon - 1])) {
+            return -400;
+        }
+        if (req.headerCount == MAX_HEADERS) {
+            return -431;
+        }
+        p[colon] = 0;
+        let vs = colon + 1;
+        while (net_is_space(p[vs])) {
+            vs = vs + 1;
+        }
+        let ve = vs + strlen(p + vs);
+        while (ve > vs && net_is_space(p[ve - 1])) {
+            ve = ve - 1;
+        }
+        p[ve] = 0;
+        req.names[req.headerCount] = p + pos;
+        req.values[req.headerCount] = p + vs;
+        req.headerCount = req.headerCount + 1;
+        pos = eol + 1;
+    }
+    return 0;
+}
+
+// parseRequest parses the HTTP request at the start of the n bytes at buf
+// into req. It returns the number of bytes the request took, 0 when it has
+// not arrived completely, or a negative HTTP status: -400 for a malformed
+// request, -431 for too many header fields and -501 for a chunked body.
+// buf is not changed; req keeps copies of what it refers to.
+function parseRequest(buf: *u8, n: i64, req: *Request): i64 -> {
+    net_reset_request(req);
+    let headEnd = net_header_end(buf, n);
+    if (headEnd == 0) {
+        return 0;
+    }
+    req.memSize = headEnd + 1;
+    req.mem = alloc(req.memSize);
+    copy(req.mem, buf, headEnd);
+    let r = net_parse_head(req);
+    if (r < 0) {
+        net_reset_request(req);
+        return r;
+    }
+
+    if (strlen(header(req, "Transfer-Encoding")) > 0) {
+        net_reset_request(req);
+        return -501;
+    }
+    let cl = header(req, "Content-Length");
+    let bodyLen = 0;
+    let i = 0;
+    while (cl[i] != 0) {
+        if (net_dec(cl[i]) < 0 || i >= 15) {
+            net_reset_request(req);
+            return -400;
+        }
+        bodyLen = bodyLen * 10 + net_dec(cl[i]);
+        i = i + 1;
+    }
+    if (headEnd + bodyLen > n) {
+        net_reset_request(req);
+        return 0;
+    }
+    if (bodyLen > 0) {
+        // Keep the header strings and the body, null terminated, in one
+        // block.
+        let mem = alloc(headEnd + 1 + bodyLen + 1);
+        copy(mem, req.mem, headEnd + 1);
+        copy(mem + headEnd + 1, buf + headEnd, bodyLen);
+        let delta = (mem as i64) - (req.mem as i64);
+        req.method = req.method + delta;
+        req.target = req.target + delta;
+        req.version = req.version + delta;
+        let h = 0;
+        while (h < req.headerCount) {
+            req.names[h] = req.names[h] + delta;
+            req.values[h] = req.values[h] + delta;
+            h = h + 1;
+        }
+        free(req.mem, req.memSize);
+        req.mem = mem;
+        req.memSize = headEnd + 1 + bodyLen + 1;
+        req.body = mem + headEnd + 1;
+        req.bodyLen = bodyLen;
+    }
+    return headEnd + bodyLen;
+}
+
+// readRequest reads from fd into the cap bytes at buf until a whole request
+// has arrived and parses it into req. It returns the number of bytes the
+// request took; bytes after them, up to *filled, belong to the next request.
+// It returns 0 when the connection closed first, a negative error code from
+// the read, or a negative HTTP status as parseRequest does, with -413 for a
+// request larger than cap.
+function readRequest(fd: i64, buf: *u8, cap: i64, filled: *i64, req: *Request): i64 -> {
+    let r = parseRequest(buf, filled[0], req);
+    while (r == 0) {
+        if (filled[0] == cap) {
+            return -413;
+        }
+        let got = syscall(SYS.read, fd, buf + filled[0], cap - filled[0], 0, 0, 0);
+        if (got == -sys.EINTR) {
+            got = 1;
+        } else if (got <= 0) {
+            return got;
+        } else {
+            filled[0] = filled[0] + got;
+        }
+        r = parseRequest(buf, filled[0], req);
+    }
+    return r;
+}
+
+// statusText returns the reason phrase of the HTTP status code.
+function statusText(code: i64): string -> {
+    if (code == 200) { return "OK"; }
+    if (code == 201) { return "Created"; }
+    if (code == 204) { return "No Content"; }
+    if (code == 301) { return "Moved Permanently"; }
+    if (code == 302) { return "Found"; }
+    if (code == 304) { return "Not Modified"; }
+    if (code == 400) { return "Bad Request"; }
+    if (code == 403) { return "Forbidden"; }
+    if (code == 404) { return "Not Found"; }
+    if (code == 405) { return "Method Not Allowed"; }
+    if (code == 413) { return "Content Too Large"; }
+    if (code == 431) { return "Request Header Fields Too Large"; }
+    if (code == 500) { return "Internal Server Error"; }
+    if (code == 501) { return "Not Implemented"; }
+    if (code == 503) { return "Service Unavailable"; }
+    return "Unknown";
+}
+
+// writeStatus writes the status line of a response with code to w.
+function writeStatus(w: *io.Writer, code: i64): i64 -> {
+    let buf = alloc(16);
+    net_put_dec(buf, 0, code);
+    io.writeString(w, "HTTP/1.1 ");
+    io.writeString(w, buf);
+    io.writeByte(w, CHAR_SPACE);
+    io.writeString(w, statusText(code));
+    free(buf, 16);
+    return io.writeString(w, "\r\n");
+}
+
+// writeHeader writes the header field "name: value" to w.
+function writeHeader(w: *io.Writer, name: string, value: string): i64 -> {
+    io.writeString(w, name);
+    io.writeString(w, ": ");
+    io.writeString(w, value);
+    return io.writeString(w, "\r\n");
+}
+
+// writeBody ends the header fields with a Content-Length of n, writes the n
+// bytes of body and flushes w. It returns the error code of the first
+// failed write, or 0.
+function writeBody(w: *io.Writer, body: *u8, n: i64): i64 -> {
+    let buf = alloc(32);
+    net_put_dec(buf, 0, n);
+    writeHeader(w, "Content-Length", buf);
+    free(buf, 32);
+    io.writeString(w, "\r\n");
+    io.write(w, body, n);
+    io.flush(w);
+    return w.err;
+}
+
+// respond writes a complete response with code, a Content-Type of
+// contentType and body to fd.
+function respond(fd: i64, code: i64, contentType: string, body: string): i64 -> {
+    let w = io.newWriter(fd);
+    writeStatus(w, code);
+    writeHeader(w, "Content-Type", contentType);
+    let r = writeBody(w, body, strlen(body));
+    free(w as *u8, io.HEADER_SIZE + io.BUF_SIZE);
+    return r;
+}
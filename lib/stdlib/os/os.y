@@ -0,0 +1,38 @@
+// stdlib/os - command line and environment of the running program
+// Implemented entirely in Y-lang. The compiler records argc, argv and envp at
+// startup: in _start for programs built with --freestanding, otherwise from
+// the C runtime before main runs.
+
+import "stdlib/core/string"
+
+// args returns the command-line arguments, starting with the program name.
+function args(): []string -> {
+    return builtin.args();
+}
+
+// exit ends the program with status code, after flushing buffered output as
+// returning from main does.
+function exit(code: i64) -> {
+    builtin.exit(code);
+}
+
+// env returns the value of the environment variable name, or "" when it is
+// not set.
+function env(name: string): string -> {
+    let envp: *i64 = builtin.environ();
+    let n: i64 = strlen(name);
+    let i: i64 = 0;
+    while (envp[i] != 0) {
+        let entry: i64 = envp[i];
+        // An entry matches when it starts with name followed by '='.
+        let j: i64 = 0;
+        while (j < n && entry[j] == name[j]) {
+            j = j + 1;
+        }
+        if (j == n && entry[n] == 61) {
+            return entry + n + 1;
+        }
+        i = i + 1;
+    }
+    return "";
+}
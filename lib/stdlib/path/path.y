@@ -0,0 +1,143 @@
+// stdlib/path - manipulation of slash-separated file paths
+// Implemented entirely in Y-lang. Paths are handled as plain strings; nothing
+// here touches the filesystem.
+//
+// Results that are not a suffix of the argument are new null-terminated
+// strings in memory obtained from mem.alloc.
+
+import "stdlib/core/string"
+import "stdlib/mem"
+
+// path_slice returns a copy of the bytes of s from start up to, not
+// including, end.
+function path_slice(s: string, start: i64, end: i64): string -> {
+    let out = alloc(end - start + 1);
+    copy(out, s + start, end - start);
+    return out;
+}
+
+// path_trim_end returns the length of s without its trailing slashes. A path
+// made only of slashes keeps its first one.
+function path_trim_end(s: string, n: i64): i64 -> {
+    while (n > 1 && s[n - 1] == 47) {
+        n = n - 1;
+    }
+    return n;
+}
+
+// join returns a and b separated by exactly one slash. An empty element is
+// dropped, so join("", "b") is "b" and join("a", "") is "a".
+function join(a: string, b: string): string -> {
+    let na = strlen(a);
+    let nb = strlen(b);
+    if (na == 0) {
+        return path_slice(b, 0, nb);
+    }
+    if (nb == 0) {
+        return path_slice(a, 0, na);
+    }
+    // Drop the slashes where the two elements meet.
+    while (na > 1 && a[na - 1] == 47) {
+        na = na - 1;
+    }
+    let skip = 0;
+    while (skip < nb - 1 && b[skip] == 47) {
+        skip = skip + 1;
+    }
+    let out = alloc(na + 1 + nb - skip + 1);
+    copy(out, a, na);
+    let pos = na;
+    if (a[na - 1] != 47) {
+        out[pos] = 47;
+        pos = pos + 1;
+    }
+    copy(out + pos, b + skip, nb - skip);
+    return out;
+}
+
+// base returns the last element of p, ignoring trailing slashes: base("a/b/")
+// is "b". It returns "." for an empty path and "/" for a path of slashes.
+function base(p: string): string -> {
+    let n = strlen(p);
+    if (n == 0) {
+        return ".";
+    }
+    n = path_trim_end(p, n);
+    if (n == 1 && p[0] == 47) {
+        return "/";
+    }
+    let start = n;
+    while (start > 0 && p[start - 1] != 47) {
+        start = start - 1;
+    }
+    return path_slice(p, start, n);
+}
+
+// dir returns everything but the last element of p, without the slash that
+// separated them: dir("a/b/c") is "a/b". It returns "." when p has no slash
+// and "/" for an element at the root.
+function dir(p: string): string -> {
+    let n = path_trim_end(p, strlen(p));
+    let end = n;
+    while (end > 0 && p[end - 1] != 47) {
+        end = end - 1;
+    }
+    if (end == 0) {
+        return ".";
+    }
+    end = path_trim_end(p, end);
+    if (end == 1 && p[0] == 47) {
+        return "/";
+    }
+    return path_slice(p, 0, end);
+}
+
+// ext returns the extension of the last element of p, from its final dot:
+// ext("a/b.tar.gz") is ".gz". It returns "" when the element has no dot.
+function ext(p: string): string -> {
+    let i = strlen(p) - 1;
+    while (i >= 0 && p[i] != 47) {
+        if (p[i] == 46) {
+            return p + i;
+        }
+        i = i - 1;
+    }
+    return "";
+}
+
+// isAbs reports whether p starts at the root.
+function isAbs(p: string): bool -> {
+    return p[0] == 47;
+}
+
+test "join" {
+    assertEq(join("a", "b"), "a/b");
+    assertEq(join("a/", "/b"), "a/b");
+    assertEq(join("/", "b"), "/b");
+    assertEq(join("", "b"), "b");
+    assertEq(join("a", ""), "a");
+}
+
+test "base" {
+    assertEq(base("a/b/"), "b");
+    assertEq(base("b"), "b");
+    assertEq(base(""), ".");
+    assertEq(base("//"), "/");
+}
+
+test "dir" {
+    assertEq(dir("a/b/c"), "a/b");
+    assertEq(dir("a"), ".");
+    assertEq(dir("/a"), "/");
+    assertEq(dir("a//b"), "a");
+}
+
+test "ext" {
+    assertEq(ext("a/b.tar.gz"), ".gz");
+    assertEq(ext("a.d/b"), "");
+}
+
+test "isAbs" {
+    assert(isAbs("/etc"));
+    assert(!isAbs("etc"));
+}
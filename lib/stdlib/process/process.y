@@ -0,0 +1,312 @@
+// stdlib/process - starting programs, waiting for them and capturing output
+// Implemented entirely in Y-lang via Linux syscalls.
+// No external C runtime is required.
+//
+// spawn starts a program and returns its process id; wait collects its exit
+// status, which exited/exitCode and signaled/termSignal decode. run does
+// both and captures what the program writes to stdout and stderr.
+//
+// Operations return a non-negative value on success and a negative error
+// code (-errno) on failure.
+
+import "stdlib/core/string"
+import "stdlib/mem"
+import "stdlib/io"
+import "stdlib/sys"
+
+// Flags of the clone syscall. arm64 and riscv64 have no fork or vfork
+// syscalls, so both are expressed through clone.
+const CLONE_VFORK = 16384;
+
+// Signal numbers.
+const SIGINT = 2;
+const SIGKILL = 9;
+const SIGPIPE = 13;
+const SIGTERM = 15;
+const SIGCHLD = 17;
+
+// Exit code of a child whose execve failed, as in the shells.
+const EXIT_NOT_FOUND = 127;
+
+// Events of ppoll.
+const POLLIN = 1;
+
+// Result is what run returns.
+extern type Result {
+    // code is the exit code of the program, 128 plus the signal number when
+    // a signal ended it, or a negative error code when it could not start.
+    let code: i64;
+    // stdout and stderr hold the captured output, null-terminated and in
+    // memory obtained from mem.alloc.
+    let stdout: string;
+    let stdoutLen: i64;
+    let stderr: string;
+    let stderrLen: i64;
+}
+
+const RESULT_SIZE = 64;
+
+// pollfd is the kernel's struct pollfd.
+extern type pollfd {
+    let fd: i32;
+    let events: i16;
+    let revents: i16;
+}
+
+// Capture collects the output read from one pipe.
+type Capture {
+    let fd: i64;
+    let buf: string;
+    let len: i64;
+    let cap: i64;
+}
+
+// getpid returns the process id of the running program.
+function getpid(): i64 -> {
+    return syscall(SYS.getpid, 0, 0, 0, 0, 0, 0);
+}
+
+// fork creates a copy of the running program. It returns the process id of
+// the child in the parent and 0 in the child. Buffered output of io.stdout
+// and io.stderr is flushed first so it is not written twice.
+function fork(): i64 -> {
+    io.flush(io.stdout);
+    io.flush(io.stderr);
+    return syscall(SYS.clone, SIGCHLD, 0, 0, 0, 0, 0);
+}
+
+// process_argv builds the null-terminated argument vector of execve: path
+// followed by args.
+function process_argv(path: string, args: []string): *i64 -> {
+    let argv = alloc((args.length + 2) * 8) as *i64;
+    argv[0] = path as i64;
+    let i = 0;
+    while (i < args.length) {
+        argv[i + 1] = args[i] as i64;
+        i = i + 1;
+    }
+    argv[args.length + 1] = 0;
+    return argv;
+}
+
+// execve replaces the running program with the program at path, passing it
+// argv and envp, both null-terminated arrays of strings. It only returns on
+// failure, with the error code. Buffered output of io.stdout and io.stderr is
+// flushed first, as the new program does not inherit the buffers.
+function execve(path: string, argv: *i64, envp: *i64): i64 -> {
+    io.flush(io.stdout);
+    io.flush(io.stderr);
+    return syscall(SYS.execve, path, argv, envp, 0, 0, 0);
+}
+
+// exec replaces the running program with the program at path, called with
+// args and the current environment. It only returns on failure.
+function exec(path: string, args: ...string): i64 -> {
+    let envp: *i64 = builtin.environ();
+    return execve(path, process_argv(path, args), envp);
+}
+
+// process_start starts the program at path with args. When out and errOut
+// are not negative, the child's stdout and stderr are redirected to them.
+//
+// The child is a copy of the parent, as with fork, and the parent is
+// suspended until the child has called execve or exited, as with vfork. The
+// child does not share the memory of the parent (CLONE_VM) as a real vfork
+// child does: it returns a second time into the same stack frames, which
+// code compiled from Y is not prepared for, so its calls and stores would
+// overwrite what the parent still needs. For the same reason vfork is not
+// offered.
+function process_start(path: string, args: []string, out: i64, errOut: i64): i64 -> {
+    io.flush(io.stdout);
+    io.flush(io.stderr);
+    // Prepared before the child exists, so the child does not allocate.
+    let argv = process_argv(path, args);
+    let envp: *i64 = builtin.environ();
+    let pid = syscall(SYS.clone, CLONE_VFORK + SIGCHLD, 0, 0, 0, 0, 0);
+    if (pid == 0) {
+        if (out >= 0) {
+            syscall(SYS.dup3, out, 1, 0, 0, 0, 0);
+        }
+        if (errOut >= 0) {
+            syscall(SYS.dup3, errOut, 2, 0, 0, 0, 0);
+        }
+        syscall(SYS.execve, path, argv, envp, 0, 0, 0);
+        // exit_group rather than os.exit: the child must not flush the
+        // buffers the parent flushes too, nor run its finalizers.
+        syscall(SYS.exit_group, EXIT_NOT_FOUND, 0, 0, 0, 0, 0);
+    }
+    free(argv, (args.length + 2) * 8);
+    return pid;
+}
+
+// spawn starts the program at path with args and the current environment,
+// and returns its process id. The program shares stdin, stdout and stderr
+// with the caller. A program that cannot be executed exits with code 127.
+function spawn(path: string, args: ...string): i64 -> {
+    return process_start(path, args, -1, -1);
+}
+
+// wait waits for the child pid to end and returns its raw exit status.
+function wait(pid: i64): i64 -> {
+    let status: i32 = 0;
+    let r = syscall(SYS.wait4, pid, &status, 0, 0, 0, 0);
+    while (r == -sys.EINTR) {
+        r = syscall(SYS.wait4, pid, &status, 0, 0, 0, 0);
+    }
+    if (r < 0) {
+        return r;
+    }
+    return status as i64;
+}
+
+// exited reports whether the status returned by wait is that of a program
+// that exited.
+function exited(status: i64): bool -> {
+    return status % 128 == 0;
+}
+
+// exitCode returns the exit code in a status for which exited is true.
+function exitCode(status: i64): i64 -> {
+    return (status / 256) % 256;
+}
+
+// signaled reports whether the status returned by wait is that of a program
+// ended by a signal.
+function signaled(status: i64): bool -> {
+    let sig = status % 128;
+    return sig != 0 && sig != 127;
+}
+
+// termSignal returns the signal in a status for which signaled is true.
+function termSignal(status: i64): i64 -> {
+    return status % 128;
+}
+
+// kill sends the signal sig to the process pid.
+function kill(pid: i64, sig: i64): i64 -> {
+    return syscall(SYS.kill, pid, sig, 0, 0, 0, 0);
+}
+
+// pipe creates a pipe and stores its read and write ends in fds[0] and
+// fds[1]. Both ends are closed in programs started by spawn or run.
+function pipe(fds: *i32): i64 -> {
+    return syscall(SYS.pipe2, fds, sys.O_CLOEXEC, 0, 0, 0, 0);
+}
+
+// process_capture reads what is available from the pipe of c into its
+// buffer. At the end of input it closes the pipe and sets c.fd to -1.
+function process_capture(c: *Capture) -> {
+    if (c.len + 1024 + 1 > c.cap) {
+        let newCap = c.cap * 2;
+        let bigger = alloc(newCap);
+        copy(bigger, c.buf, c.len);
+        free(c.buf, c.cap);
+        c.buf = bigger;
+        c.cap = newCap;
+    }
+    let n = syscall(SYS.read, c.fd, c.buf + c.len, c.cap - c.len - 1, 0, 0, 0);
+    if (n == -sys.EINTR) {
+        return;
+    }
+    if (n <= 0) {
+        syscall(SYS.close, c.fd, 0, 0, 0, 0, 0);
+        c.fd = -1;
+        return;
+    }
+    c.len = c.len + n;
+}
+
+// process_new_capture returns a Capture reading from fd.
+function process_new_capture(fd: i64): *Capture -> {
+    let c = alloc(32) as *Capture;
+    c.fd = fd;
+    c.cap = 4096;
+    c.buf = alloc(c.cap);
+    c.len = 0;
+    return c;
+}
+
+// run starts the program at path with args, waits for it to end and returns
+// its exit code and everything it wrote to stdout and stderr. Both pipes
+// are read as output arrives, so a program filling one of them while the
+// other is empty does not block.
+function run(path: string, args: ...string): *Result -> {
+    let res = alloc(RESULT_SIZE) as *Result;
+    res.stdout = "";
+    res.stdoutLen = 0;
+    res.stderr = "";
+    res.stderrLen = 0;
+
+    let outPipe: i64 = 0;
+    let errPipe: i64 = 0;
+    let outFds = &outPipe as *i32;
+    let errFds = &errPipe as *i32;
+    let r = pipe(outFds);
+    if (r < 0) {
+        res.code = r;
+        return res;
+    }
+    r = pipe(errFds);
+    if (r < 0) {
+        syscall(SYS.close, outFds[0], 0, 0, 0, 0, 0);
+        syscall(SYS.close, outFds[1], 0, 0, 0, 0, 0);
+        res.code = r;
+        return res;
+    }
+
+    let pid = process_start(path, args, outFds[1], errFds[1]);
+    syscall(SYS.close, outFds[1], 0, 0, 0, 0, 0);
+    syscall(SYS.close, errFds[1], 0, 0, 0, 0, 0);
+    let out = process_new_capture(outFds[0]);
+    let errOut = process_new_capture(errFds[0]);
+    if (pid < 0) {
+        syscall(SYS.close, out.fd, 0, 0, 0, 0, 0);
+        syscall(SYS.close, errOut.fd, 0, 0, 0, 0, 0);
+        res.code = pid;
+        return res;
+    }
+
+    let polls = alloc(16) as *pollfd;
+    let errPoll = polls + 1;
+    while (out.fd >= 0 || errOut.fd >= 0) {
+        // A negative fd is ignored by ppoll.
+        polls.fd = out.fd;
+        polls.events = POLLIN;
+        polls.revents = 0;
+        errPoll.fd = errOut.fd;
+        errPoll.events = POLLIN;
+        errPoll.revents = 0;
+        let ready = syscall(SYS.ppoll, polls, 2, 0, 0, 8, 0);
+        if (ready < 0 && ready != -sys.EINTR) {
+            // Reading blocks now, but cannot miss output.
+            polls.revents = POLLIN;
+            errPoll.revents = POLLIN;
+        }
+        if (out.fd >= 0 && polls.revents != 0) {
+            process_capture(out);
+        }
+        if (errOut.fd >= 0 && errPoll.revents != 0) {
+            process_capture(errOut);
+        }
+    }
+    free(polls, 16);
+
+    out.buf[out.len] = 0;
+    errOut.buf[errOut.len] = 0;
+    res.stdout = out.buf;
+    res.stdoutLen = out.len;
+    res.stderr = errOut.buf;
+    res.stderrLen = errOut.len;
+    free(out, 32);
+    free(errOut, 32);
+
+    let status = wait(pid);
+    if (status < 0) {
+        res.code = status;
+    } else if (signaled(status)) {
+        res.code = 128 + termSignal(status);
+    } else {
+        res.code = exitCode(status);
+    }
+    return res;
+}
@@ -0,0 +1,131 @@
+// stdlib/rand - pseudo-random numbers
+// Implemented entirely in Y-lang. No external C runtime is required.
+//
+// A Rand is a xoshiro256** generator: fast, with a period of 2^256 - 1, but
+// not suitable for cryptography; read sys.getrandom for that. newRand seeds
+// one from getrandom and newSeededRand from a number, for repeatable
+// sequences. A Rand is not synchronized, so give each thread its own.
+
+import "stdlib/mem"
+
+// Rand is the 256-bit state of a generator, never all zero.
+extern type Rand {
+    let s0: i64;
+    let s1: i64;
+    let s2: i64;
+    let s3: i64;
+}
+
+const RAND_SIZE = 32;
+
+// rand_rotl rotates x left by b bits.
+function rand_rotl(x: i64, b: i64): i64 -> {
+    return (x << b) | (x >>> (64 - b));
+}
+
+// newSeededRand returns a generator whose sequence is determined by seed.
+// The state is filled from seed with splitmix64, as xoshiro's authors
+// recommend.
+function newSeededRand(seed: i64): *Rand -> {
+    let r = heapAlloc(RAND_SIZE) as *Rand;
+    let s = r as *i64;
+    let i: i64 = 0;
+    while (i < 4) {
+        seed = seed + 0x9e3779b97f4a7c15;
+        let z = seed;
+        z = (z ^ (z >>> 30)) * 0xbf58476d1ce4e5b9;
+        z = (z ^ (z >>> 27)) * 0x94d049bb133111eb;
+        s[i] = z ^ (z >>> 31);
+        i = i + 1;
+    }
+    return r;
+}
+
+// newRand returns a generator seeded from getrandom.
+function newRand(): *Rand -> {
+    let r = heapAlloc(RAND_SIZE) as *Rand;
+    let s = r as *i64;
+    syscall(SYS.getrandom, s, RAND_SIZE, 0, 0, 0, 0);
+    if ((s[0] | s[1] | s[2] | s[3]) == 0) {
+        s[0] = 1;
+    }
+    return r;
+}
+
+// freeRand releases a generator.
+function freeRand(r: *Rand) -> {
+    heapFree(r as *u8);
+}
+
+// next64 returns 64 random bits.
+function next64(r: *Rand): i64 -> {
+    let result = rand_rotl(r.s1 * 5, 7) * 9;
+    let t = r.s1 << 17;
+    r.s2 = r.s2 ^ r.s0;
+    r.s3 = r.s3 ^ r.s1;
+    r.s1 = r.s1 ^ r.s2;
+    r.s0 = r.s0 ^ r.s3;
+    r.s2 = r.s2 ^ t;
+    r.s3 = rand_rotl(r.s3, 45);
+    return result;
+}
+
+// intn returns a number in [0, n), each equally likely, or 0 when n is not
+// positive.
+function intn(r: *Rand, n: i64): i64 -> {
+    if (n <= 0) {
+        return 0;
+    }
+    // Draw just enough bits to cover n and retry the values past it, which
+    // happens less than half of the time.
+    let mask: i64 = n - 1;
+    mask = mask | (mask >>> 1);
+    mask = mask | (mask >>> 2);
+    mask = mask | (mask >>> 4);
+    mask = mask | (mask >>> 8);
+    mask = mask | (mask >>> 16);
+    mask = mask | (mask >>> 32);
+    let v = next64(r) & mask;
+    while (v >= n) {
+        v = next64(r) & mask;
+    }
+    return v;
+}
+
+// between returns a number in [lo, hi], each equally likely, or lo when hi
+// is below it.
+function between(r: *Rand, lo: i64, hi: i64): i64 -> {
+    if (hi <= lo) {
+        return lo;
+    }
+    return lo + intn(r, hi - lo + 1);
+}
+
+// nextFloat returns an f64 in [0, 1) from the top 53 bits of next64.
+function nextFloat(r: *Rand): f64 -> {
+    return ((next64(r) >>> 11) as f64) / 9007199254740992.0;
+}
+
+// chance returns true with probability p.
+function chance(r: *Rand, p: f64): bool -> {
+    return nextFloat(r) < p;
+}
+
+// shuffle puts the n values at xs in a random order, each order equally
+// likely.
+function shuffle(r: *Rand, xs: *i64, n: i64) -> {
+    let i = n - 1;
+    while (i > 0) {
+        let j = intn(r, i + 1);
+        let t = xs[i];
+        xs[i] = xs[j];
+        xs[j] = t;
+        i = i - 1;
+    }
+}
+
+// choice returns one of the n values at xs, each equally likely. n must
+// be positive.
+function choice(r: *Rand, xs: *i64, n: i64): i64 -> {
+    return xs[intn(r, n)];
+}
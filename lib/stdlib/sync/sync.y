@@ -0,0 +1,133 @@
+// stdlib/sync - mutexes, condition variables and wait groups for threads
+// Implemented entirely in Y-lang over stdlib/atomic and the futex syscall.
+// No external C runtime is required.
+//
+// A zeroed Mutex, Cond or WaitGroup is ready to use, so they can live in
+// any zeroed memory; newMutex, newCond and newWaitGroup allocate one.
+// Waiting threads sleep in the kernel instead of spinning.
+
+import "stdlib/mem"
+import "stdlib/atomic"
+
+// Operations of the futex syscall, on memory private to this process.
+const FUTEX_WAIT_PRIVATE = 128;
+const FUTEX_WAKE_PRIVATE = 129;
+
+// Wakes every waiter.
+const WAKE_ALL = 2147483647;
+
+// Mutex is a lock held by at most one thread at a time. state is 0 when
+// unlocked, 1 when locked and 2 when locked with threads waiting, as in
+// Drepper's "Futexes Are Tricky".
+extern type Mutex {
+    let state: i32;
+}
+
+// Cond lets threads wait for a condition guarded by a Mutex. seq changes
+// with every signal, so a signal between unlocking and sleeping is not lost.
+extern type Cond {
+    let seq: i32;
+}
+
+// WaitGroup waits for a number of tasks to finish.
+extern type WaitGroup {
+    let count: i32;
+}
+
+// futexWait sleeps while *p holds val, or until woken.
+function futexWait(p: *i32, val: i64): i64 -> {
+    return syscall(SYS.futex, p, FUTEX_WAIT_PRIVATE, val, 0, 0, 0);
+}
+
+// futexWake wakes up to n threads sleeping on p and returns how many woke.
+function futexWake(p: *i32, n: i64): i64 -> {
+    return syscall(SYS.futex, p, FUTEX_WAKE_PRIVATE, n, 0, 0, 0);
+}
+
+// newMutex returns an unlocked Mutex.
+function newMutex(): *Mutex -> {
+    return alloc(8) as *Mutex;
+}
+
+// newCond returns a Cond.
+function newCond(): *Cond -> {
+    return alloc(8) as *Cond;
+}
+
+// newWaitGroup returns a WaitGroup with a count of 0.
+function newWaitGroup(): *WaitGroup -> {
+    return alloc(8) as *WaitGroup;
+}
+
+// lock locks m, waiting until it is unlocked.
+function lock(m: *Mutex) -> {
+    if (atomic.cas32(&m.state, 0, 1, atomic.ACQUIRE)) {
+        return;
+    }
+    // Mark the mutex contended, so unlock wakes a waiter.
+    let c = atomic.swap32(&m.state, 2, atomic.ACQUIRE);
+    while (c != 0) {
+        futexWait(&m.state, 2);
+        c = atomic.swap32(&m.state, 2, atomic.ACQUIRE);
+    }
+}
+
+// tryLock locks m if it is unlocked and reports whether it did.
+function tryLock(m: *Mutex): bool -> {
+    return atomic.cas32(&m.state, 0, 1, atomic.ACQUIRE);
+}
+
+// unlock unlocks m, waking a waiting thread.
+function unlock(m: *Mutex) -> {
+    if (atomic.swap32(&m.state, 0, atomic.RELEASE) == 2) {
+        futexWake(&m.state, 1);
+    }
+}
+
+// wait unlocks m, sleeps until c is signalled and locks m again. As wakeups
+// may be spurious, callers check their condition in a loop.
+function wait(c: *Cond, m: *Mutex) -> {
+    let seq = atomic.load32(&c.seq, atomic.ACQUIRE);
+    unlock(m);
+    futexWait(&c.seq, seq);
+    // Relock as contended: other waiters may have been woken too.
+    let s = atomic.swap32(&m.state, 2, atomic.ACQUIRE);
+    while (s != 0) {
+        futexWait(&m.state, 2);
+        s = atomic.swap32(&m.state, 2, atomic.ACQUIRE);
+    }
+}
+
+// signal wakes one thread waiting on c.
+function signal(c: *Cond) -> {
+    atomic.add32(&c.seq, 1, atomic.RELEASE);
+    futexWake(&c.seq, 1);
+}
+
+// broadcast wakes every thread waiting on c.
+function broadcast(c: *Cond) -> {
+    atomic.add32(&c.seq, 1, atomic.RELEASE);
+    futexWake(&c.seq, WAKE_ALL);
+}
+
+// add adds n, which may be negative, to the count of wg.
+function add(wg: *WaitGroup, n: i64) -> {
+    let old = atomic.add32(&wg.count, n, atomic.ACQ_REL);
+    if (old + n == 0) {
+        futexWake(&wg.count, WAKE_ALL);
+    }
+}
+
+// done decrements the count of wg by one.
+function done(wg: *WaitGroup) -> {
+    add(wg, -1);
+}
+
+// waitAll waits until the count of wg drops to 0.
+function waitAll(wg: *WaitGroup) -> {
+    let n = atomic.load32(&wg.count, atomic.ACQUIRE);
+    while (n != 0) {
+        futexWait(&wg.count, n);
+        n = atomic.load32(&wg.count, atomic.ACQUIRE);
+    }
+}
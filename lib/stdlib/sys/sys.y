@@ -0,0 +1,141 @@
+// stdlib/sys - typed wrappers around raw Linux system calls
+// Implemented entirely in Y-lang; syscall numbers come from the compiler's
+// table for the target (SYS.name), so this file carries no magic numbers.
+// No external C runtime is required.
+//
+// Every wrapper returns the kernel's result unchanged: a non-negative value on
+// success and -errno on failure, e.g. -2 (-ENOENT) from openat for a missing
+// file. Compare against the E* constants below, negated.
+//
+// The flag and errno tables here are the single definition the other stdlib
+// modules share; refer to them as sys.O_CREAT, sys.EINTR and so on.
+
+// Syscall numbers re-exported under their conventional names.
+const SYS_read = SYS.read;
+const SYS_write = SYS.write;
+const SYS_openat = SYS.openat;
+const SYS_close = SYS.close;
+const SYS_mmap = SYS.mmap;
+const SYS_munmap = SYS.munmap;
+const SYS_exit_group = SYS.exit_group;
+const SYS_getdents64 = SYS.getdents64;
+const SYS_clock_gettime = SYS.clock_gettime;
+const SYS_getrandom = SYS.getrandom;
+
+// Standard file descriptors
+const STDIN = 0;
+const STDOUT = 1;
+const STDERR = 2;
+
+// openat(2) flags and the directory fd meaning "relative to the cwd"
+const AT_FDCWD = -100;
+const O_RDONLY = 0x0;
+const O_WRONLY = 0x1;
+const O_RDWR = 0x2;
+const O_CREAT = 0x40;
+const O_EXCL = 0x80;
+const O_TRUNC = 0x200;
+const O_APPEND = 0x400;
+const O_NONBLOCK = 0x800;
+const O_CLOEXEC = 0x80000;
+
+// O_DIRECTORY differs between architectures (arm64 uses 0x4000), so the
+// compiler supplies it for the target.
+const O_DIRECTORY = builtin.oDirectory();
+
+// unlinkat(2) and fstatat(2) flags
+const AT_SYMLINK_NOFOLLOW = 0x100;
+const AT_REMOVEDIR = 0x200;
+
+// mmap(2) protection and flags
+const PROT_NONE = 0x0;
+const PROT_READ = 0x1;
+const PROT_WRITE = 0x2;
+const PROT_EXEC = 0x4;
+const MAP_SHARED = 0x01;
+const MAP_PRIVATE = 0x02;
+const MAP_ANONYMOUS = 0x20;
+
+// clock_gettime(2) clocks
+const CLOCK_REALTIME = 0;
+const CLOCK_MONOTONIC = 1;
+
+// getrandom(2) flags
+const GRND_NONBLOCK = 0x1;
+
+// errno values (wrappers return them negated)
+const EPERM = 1;
+const ENOENT = 2;
+const EINTR = 4;
+const EIO = 5;
+const EBADF = 9;
+const EAGAIN = 11;
+const ENOMEM = 12;
+const EACCES = 13;
+const EFAULT = 14;
+const EEXIST = 17;
+const EXDEV = 18;
+const ENOTDIR = 20;
+const EISDIR = 21;
+const EINVAL = 22;
+const ENOSPC = 28;
+const EROFS = 30;
+const ENAMETOOLONG = 36;
+const ENOSYS = 38;
+const ENOTEMPTY = 39;
+const ELOOP = 40;
+
+// read reads up to count bytes from fd into buf and returns the number read.
+function read(fd: i64, buf: *u8, count: i64): i64 -> {
+    return syscall(SYS.read, fd, buf, count, 0, 0, 0);
+}
+
+// write writes up to count bytes of buf to fd and returns the number written.
+function write(fd: i64, buf: *u8, count: i64): i64 -> {
+    return syscall(SYS.write, fd, buf, count, 0, 0, 0);
+}
+
+// openat opens path relative to the directory dirfd (AT_FDCWD for the current
+// directory) and returns the new file descriptor.
+function openat(dirfd: i64, path: string, flags: i64, mode: i64): i64 -> {
+    return syscall(SYS.openat, dirfd, path, flags, mode, 0, 0);
+}
+
+// close releases fd and returns 0.
+function close(fd: i64): i64 -> {
+    return syscall(SYS.close, fd, 0, 0, 0, 0, 0);
+}
+
+// mmap maps length bytes and returns the address of the mapping.
+function mmap(addr: i64, length: i64, prot: i64, flags: i64, fd: i64, offset: i64): i64 -> {
+    return syscall(SYS.mmap, addr, length, prot, flags, fd, offset);
+}
+
+// munmap removes the mapping of length bytes at addr and returns 0.
+function munmap(addr: i64, length: i64): i64 -> {
+    return syscall(SYS.munmap, addr, length, 0, 0, 0, 0);
+}
+
+// exit_group terminates every thread of the process with status code; it
+// does not return.
+function exit_group(code: i64): i64 -> {
+    return syscall(SYS.exit_group, code, 0, 0, 0, 0, 0);
+}
+
+// getdents64 fills buf with up to count bytes of linux_dirent64 records for
+// the directory fd and returns the number of bytes used (0 at the end).
+function getdents64(fd: i64, buf: *u8, count: i64): i64 -> {
+    return syscall(SYS.getdents64, fd, buf, count, 0, 0, 0);
+}
+
+// clock_gettime stores the time of clock as {seconds, nanoseconds} (two i64)
+// at ts and returns 0.
+function clock_gettime(clock: i64, ts: *i64): i64 -> {
+    return syscall(SYS.clock_gettime, clock, ts, 0, 0, 0, 0);
+}
+
+// getrandom fills buf with up to count random bytes and returns the number
+// written.
+function getrandom(buf: *u8, count: i64, flags: i64): i64 -> {
+    return syscall(SYS.getrandom, buf, count, flags, 0, 0, 0);
+}
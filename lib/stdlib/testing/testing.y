@@ -0,0 +1,170 @@
+// stdlib/testing - running test blocks and reporting failed assertions
+// Implemented entirely in Y-lang. No external C runtime is required.
+//
+// `ylang test` compiles the test blocks of a file into a harness whose main
+// calls start, then run for each test, and returns what finish returns:
+//
+//     test "join drops empty elements" {
+//         assertEq(path.join("", "b"), "b");
+//     }
+//
+// Each test runs in a forked child, so a failed assertion, an exit or a
+// crash ends that test only. The harness accepts --run pattern (-r) to run
+// only the tests whose name contains pattern, and --verbose (-v) to report
+// every test rather than only the failed ones.
+//
+// assert(cond) and assertEq(got, want) are compiler intrinsics; on failure
+// they call fail and failEq with the file, the line and the source of the
+// assertion. They may be used outside tests too, after importing this
+// module.
+
+import "stdlib/core/string"
+import "stdlib/io"
+import "stdlib/os"
+import "stdlib/fmt"
+import "stdlib/flags"
+import "stdlib/process"
+import "stdlib/time"
+
+let testing_filter: string = "";
+let testing_verbose: bool = false;
+let testing_passed: i64 = 0;
+let testing_failed: i64 = 0;
+let testing_filtered: i64 = 0;
+
+// testing_contains reports whether s contains sub.
+function testing_contains(s: string, sub: string): bool -> {
+    let n = strlen(s);
+    let m = strlen(sub);
+    let i: i64 = 0;
+    while (i + m <= n) {
+        let j: i64 = 0;
+        while (j < m && s[i + j] == sub[j]) {
+            j = j + 1;
+        }
+        if (j == m) {
+            return true;
+        }
+        i = i + 1;
+    }
+    return false;
+}
+
+// testing_streq reports whether a and b hold the same bytes.
+function testing_streq(a: string, b: string): bool -> {
+    let i: i64 = 0;
+    while (a[i] != 0 && a[i] == b[i]) {
+        i = i + 1;
+    }
+    return a[i] == b[i];
+}
+
+// start reads the options of the harness from the command line.
+function start() -> {
+    let args = os.args();
+    let fs = flags.newFlagSet(args[0], "[options]");
+    let run = flags.stringFlag(fs, "run", "r", "", "run only the tests whose name contains pattern");
+    let verbose = flags.boolFlag(fs, "verbose", "v", "report every test, not only failed ones");
+    flags.parseOrExit(fs, args);
+    testing_filter = *run;
+    testing_verbose = *verbose;
+}
+
+// run runs the test t called name in a child process and reports its
+// outcome. A test passes when it returns.
+function run(name: string, t: () -> void) -> {
+    if (!testing_contains(name, testing_filter)) {
+        testing_filtered = testing_filtered + 1;
+        return;
+    }
+    if (testing_verbose) {
+        fmt.printf("=== RUN   %s\n", name);
+    }
+    let began = time.monotonic();
+    let pid = process.fork();
+    if (pid == 0) {
+        t();
+        os.exit(0);
+    }
+    if (pid < 0) {
+        fmt.printf("--- FAIL: %s (fork failed: %d)\n", name, pid);
+        testing_failed = testing_failed + 1;
+        return;
+    }
+    let status = process.wait(pid);
+    let took = time.formatDuration(time.since(began));
+    if (process.exited(status) && process.exitCode(status) == 0) {
+        testing_passed = testing_passed + 1;
+        if (testing_verbose) {
+            fmt.printf("--- PASS: %s (%s)\n", name, took);
+        }
+        return;
+    }
+    testing_failed = testing_failed + 1;
+    if (process.signaled(status)) {
+        fmt.printf("    killed by signal %d\n", process.termSignal(status));
+    } else if (process.exitCode(status) != 1) {
+        fmt.printf("    exit status %d\n", process.exitCode(status));
+    }
+    fmt.printf("--- FAIL: %s (%s)\n", name, took);
+}
+
+// finish prints the summary of the tests run and returns the exit status of
+// the harness: 0 when every test passed, 1 otherwise.
+function finish(): i32 -> {
+    if (testing_failed > 0) {
+        fmt.printf("FAIL\n");
+    } else {
+        fmt.printf("PASS\n");
+    }
+    fmt.printf("%d passed, %d failed, %d filtered out\n", testing_passed, testing_failed, testing_filtered);
+    if (testing_failed > 0) {
+        return 1;
+    }
+    return 0;
+}
+
+// equal reports whether a and b are of the same kind and hold the same
+// value. Strings are compared by content.
+function equal(a: any, b: any): bool -> {
+    if (a.kind != b.kind) {
+        return false;
+    }
+    if (a.kind == 3) {
+        return testing_streq(a.string, b.string);
+    }
+    if (a.kind == 2) {
+        return a.float == b.float;
+    }
+    if (a.kind == 4) {
+        return a.bool == b.bool;
+    }
+    return a.int == b.int;
+}
+
+// testing_show writes v to io.stderr, quoting strings.
+function testing_show(v: any) -> {
+    if (v.kind == 3) {
+        fmt.fprintf(io.stderr, "\"%s\"", v.string);
+    } else {
+        fmt.fprintf(io.stderr, "%v", v);
+    }
+}
+
+// fail reports the failed assertion expr at where and ends the program
+// with status 1.
+function fail(where: string, expr: string) -> {
+    fmt.fprintf(io.stderr, "    %s: assertion failed: %s\n", where, expr);
+    os.exit(1);
+}
+
+// failEq reports the failed assertEq expr at where, with the values it
+// compared, and ends the program with status 1.
+function failEq(where: string, expr: string, got: any, want: any) -> {
+    fmt.fprintf(io.stderr, "    %s: assertion failed: %s\n        got:  ", where, expr);
+    testing_show(got);
+    fmt.fprintf(io.stderr, "\n        want: ");
+    testing_show(want);
+    fmt.fprintf(io.stderr, "\n");
+    os.exit(1);
+}
@@ -0,0 +1,108 @@
+// stdlib/thread - threads sharing the memory of the program
+// Implemented entirely in Y-lang via Linux syscalls.
+// No external C runtime is required.
+//
+// spawn runs a function on a new thread created with clone; join waits for
+// it and returns the function's result. Each thread gets its own stack,
+// below an unmapped guard page, and its own copy of the thread_local
+// variables.
+//
+// Buffered output (io.stdout, printf) and lazily initialized globals are not
+// synchronized: give each thread its own Writer, or guard shared ones with a
+// sync.Mutex. In programs linked with a C runtime, threads must not call
+// into it, as it does not know about them.
+
+import "stdlib/mem"
+import "stdlib/atomic"
+
+// Default size of a thread's stack.
+const STACK_SIZE = 262144;
+const GUARD_SIZE = 4096;
+
+// Flags of clone: share memory, files and signal handlers, join the thread
+// group, set the TLS area, and store the thread id for join, which the
+// kernel clears when the thread exits.
+const CLONE_VM = 256;
+const CLONE_FS = 512;
+const CLONE_FILES = 1024;
+const CLONE_SIGHAND = 2048;
+const CLONE_THREAD = 65536;
+const CLONE_SYSVSEM = 262144;
+const CLONE_SETTLS = 524288;
+const CLONE_PARENT_SETTID = 1048576;
+const CLONE_CHILD_CLEARTID = 2097152;
+
+// FUTEX_WAIT on shared memory, which the kernel wakes when it clears the
+// thread id.
+const FUTEX_WAIT = 0;
+const PROT_NONE = 0;
+
+// Thread is a running or finished thread. It lives at the top of the
+// thread's stack mapping, which join unmaps.
+extern type Thread {
+    // tid is the thread id while the thread runs, 0 once it has exited.
+    let tid: i32;
+    let fn: (i64) -> i64;
+    let arg: i64;
+    let result: i64;
+    let area: *u8;
+    let areaSize: i64;
+}
+
+const THREAD_SIZE = 64;
+
+// thread_main runs on the new thread.
+function thread_main(t: *Thread): i64 -> {
+    let f = t.fn;
+    t.result = f(t.arg);
+    return 0;
+}
+
+// spawn runs fn(arg) on a new thread with a stack of stackSize bytes and
+// returns it, or 0 when the thread could not be created.
+function spawn(fn: (i64) -> i64, arg: i64 = 0, stackSize: i64 = STACK_SIZE): *Thread -> {
+    // [guard page][stack ... Thread][TLS area]
+    let stack = (stackSize + 4095) / 4096 * 4096;
+    let tlsSize: i64 = builtin.tlsSize();
+    let size = stack + tlsSize;
+    let area = alloc(size);
+    if ((area as i64) < 0) {
+        return 0 as *Thread;
+    }
+    syscall(SYS.mprotect, area, GUARD_SIZE, PROT_NONE, 0, 0, 0);
+
+    let t = (area + stack - THREAD_SIZE) as *Thread;
+    t.fn = fn;
+    t.arg = arg;
+    t.area = area;
+    t.areaSize = size;
+    let tp: *u8 = builtin.tlsInit(area + stack);
+
+    let flags = CLONE_VM + CLONE_FS + CLONE_FILES + CLONE_SIGHAND + CLONE_THREAD + CLONE_SYSVSEM +
+        CLONE_SETTLS + CLONE_PARENT_SETTID + CLONE_CHILD_CLEARTID;
+    // The stack grows down from just below the Thread.
+    let tid: i64 = builtin.threadStart(flags, t, &t.tid, &t.tid, tp, thread_main, t);
+    if (tid < 0) {
+        free(area, size);
+        return 0 as *Thread;
+    }
+    return t;
+}
+
+// join waits for t to finish, releases its stack and returns the result of
+// its function. t must not be used afterwards.
+function join(t: *Thread): i64 -> {
+    let tid = atomic.load32(&t.tid, atomic.ACQUIRE);
+    while (tid != 0) {
+        syscall(SYS.futex, &t.tid, FUTEX_WAIT, tid, 0, 0, 0);
+        tid = atomic.load32(&t.tid, atomic.ACQUIRE);
+    }
+    let result = t.result;
+    free(t.area, t.areaSize);
+    return result;
+}
+
+// id returns the thread id of the calling thread.
+function id(): i64 -> {
+    return syscall(SYS.gettid, 0, 0, 0, 0, 0, 0);
+}
@@ -0,0 +1,176 @@
+// stdlib/time - wall clock and monotonic time, sleeping and durations
+// Implemented entirely in Y-lang over the clock_gettime and nanosleep
+// syscalls. No external C runtime is required.
+//
+// Times and durations are i64 nanoseconds. now() counts from the Unix epoch
+// and follows changes to the system clock; monotonic() counts from an
+// arbitrary point and only ever moves forward, so use it to measure elapsed
+// time.
+
+import "stdlib/mem"
+import "stdlib/sys"
+
+// Durations, in nanoseconds.
+const NANOSECOND: i64 = 1;
+const MICROSECOND: i64 = 1000;
+const MILLISECOND: i64 = 1000000;
+const SECOND: i64 = 1000000000;
+const MINUTE: i64 = 60000000000;
+const HOUR: i64 = 3600000000000;
+
+// A timespec, {seconds, nanoseconds}, and the remainder nanosleep leaves
+// when a signal interrupts it.
+thread_local let time_spec: *i64 = alloc(32) as *i64;
+
+// time_read returns the time of clock in nanoseconds.
+function time_read(clock: i64): i64 -> {
+    let ts = time_spec;
+    syscall(SYS.clock_gettime, clock, ts, 0, 0, 0, 0);
+    return ts[0] * SECOND + ts[1];
+}
+
+// now returns the wall clock time in nanoseconds since the Unix epoch.
+function now(): i64 -> {
+    return time_read(sys.CLOCK_REALTIME);
+}
+
+// unix returns the wall clock time in whole seconds since the Unix epoch.
+function unix(): i64 -> {
+    return now() / SECOND;
+}
+
+// monotonic returns the time of the monotonic clock in nanoseconds.
+function monotonic(): i64 -> {
+    return time_read(sys.CLOCK_MONOTONIC);
+}
+
+// since returns the nanoseconds elapsed since start, a value of monotonic().
+function since(start: i64): i64 -> {
+    return monotonic() - start;
+}
+
+// sleepNanos suspends the calling thread for at least ns nanoseconds,
+// resuming the wait when a signal interrupts it. It returns 0, or -errno.
+function sleepNanos(ns: i64): i64 -> {
+    if (ns <= 0) {
+        return 0;
+    }
+    let ts = time_spec;
+    ts[0] = ns / SECOND;
+    ts[1] = ns % SECOND;
+    let rem = ts + 16;
+    let r = syscall(SYS.nanosleep, ts, rem, 0, 0, 0, 0);
+    while (r == -sys.EINTR) {
+        ts[0] = rem[0];
+        ts[1] = rem[1];
+        r = syscall(SYS.nanosleep, ts, rem, 0, 0, 0, 0);
+    }
+    return r;
+}
+
+// sleep suspends the calling thread for at least ms milliseconds.
+function sleep(ms: i64): i64 -> {
+    return sleepNanos(ms * MILLISECOND);
+}
+
+// time_put_int writes the decimal digits of the non-negative n into buf at
+// pos and returns the position after them.
+function time_put_int(buf: *u8, pos: i64, n: i64): i64 -> {
+    let count: i64 = 1;
+    let rest = n;
+    while (rest >= 10) {
+        rest = rest / 10;
+        count = count + 1;
+    }
+    let i = pos + count - 1;
+    while (i >= pos) {
+        buf[i] = 48 + n % 10;
+        n = n / 10;
+        i = i - 1;
+    }
+    return pos + count;
+}
+
+// time_put_frac writes the non-negative n / 10^digits in decimal, leaving
+// out trailing zeros of the fraction, e.g. 1500 with 3 digits as "1.5", and
+// returns the position after it.
+function time_put_frac(buf: *u8, pos: i64, n: i64, digits: i64): i64 -> {
+    let scale: i64 = 1;
+    let i: i64 = 0;
+    while (i < digits) {
+        scale = scale * 10;
+        i = i + 1;
+    }
+    pos = time_put_int(buf, pos, n / scale);
+    let frac = n % scale;
+    if (frac == 0) {
+        return pos;
+    }
+    while (frac % 10 == 0) {
+        frac = frac / 10;
+        digits = digits - 1;
+    }
+    buf[pos] = 46;
+    let end = pos + digits;
+    i = end;
+    while (i > pos) {
+        buf[i] = 48 + frac % 10;
+        frac = frac / 10;
+        i = i - 1;
+    }
+    return end + 1;
+}
+
+// formatDuration returns d as a string such as "1h2m3.5s", "2.5ms", "12us"
+// or "0s": hours, minutes and seconds from a second up, otherwise the
+// largest of ms, us and ns under it. The string is allocated with
+// mem.heapAlloc.
+function formatDuration(d: i64): string -> {
+    let buf = heapAlloc(40);
+    let pos: i64 = 0;
+    // The parts are taken from the negative magnitude, which unlike -d also
+    // exists for the smallest i64.
+    let neg = d;
+    if (d < 0) {
+        buf[0] = 45;
+        pos = 1;
+    } else {
+        neg = -d;
+    }
+    if (neg == 0) {
+        buf[0] = 48;
+        buf[1] = 115;
+        return buf;
+    }
+    if (neg > -MICROSECOND) {
+        pos = time_put_int(buf, pos, -neg);
+        buf[pos] = 110;
+        buf[pos + 1] = 115;
+        return buf;
+    }
+    if (neg > -MILLISECOND) {
+        pos = time_put_frac(buf, pos, -neg, 3);
+        buf[pos] = 117;
+        buf[pos + 1] = 115;
+        return buf;
+    }
+    if (neg > -SECOND) {
+        pos = time_put_frac(buf, pos, -neg, 6);
+        buf[pos] = 109;
+        buf[pos + 1] = 115;
+        return buf;
+    }
+    if (neg <= -HOUR) {
+        pos = time_put_int(buf, pos, -(neg / HOUR));
+        buf[pos] = 104;
+        pos = pos + 1;
+    }
+    if (neg <= -MINUTE) {
+        pos = time_put_int(buf, pos, -(neg / MINUTE % 60));
+        buf[pos] = 109;
+        pos = pos + 1;
+    }
+    pos = time_put_frac(buf, pos, -(neg % MINUTE), 9);
+    buf[pos] = 115;
+    return buf;
+}
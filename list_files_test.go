@@ -17,8 +17,8 @@ import (
 // working directory, compiles and runs it, then verifies the output matches
 // the actual directory contents.
 //
-// The program is implemented entirely in Y-lang using Linux syscalls — no
-// external C runtime or stubs are required.
+// The program is implemented entirely in Y-lang using Linux syscalls and is
+// built freestanding, so no C runtime or stubs are linked in.
 func TestListFilesProgram(t *testing.T) {
 	input := `
 	import "stdlib/fs";
@@ -45,7 +45,8 @@ func TestListFilesProgram(t *testing.T) {
 	}
 
 	// 3. Compile to LLVM IR.
-	compilerInstance := c.NewCompiler(c.LLVM)
+	compilerInstance := c.NewCompiler(c.LLVM, nil)
+	compilerInstance.Freestanding = true
 	result := compilerInstance.Compile(program)
 	if len(result.Errors) != 0 {
 		t.Fatalf("Compiler errors: %v", result.Errors)
@@ -64,10 +65,10 @@ func TestListFilesProgram(t *testing.T) {
 	}
 
 	// 5. Compile IR with clang into an executable.
-	//    No C runtime file is needed — the program is fully self-contained
-	//    (all I/O goes through inline-asm syscall instructions).
+	//    No C runtime is linked: the generated _start calls main and exits
+	//    through exit_group, and all I/O goes through syscall instructions.
 	exeFile := filepath.Join(tmpDir, "listfiles")
-	clangCmd := exec.Command("clang", irFile, "-o", exeFile)
+	clangCmd := exec.Command("clang", irFile, "-o", exeFile, "-nostdlib", "-static")
 	if out, err := clangCmd.CombinedOutput(); err != nil {
 		t.Fatalf("clang compilation failed: %v\nOutput:\n%s", err, string(out))
 	}
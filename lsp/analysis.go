@@ -0,0 +1,840 @@
+package lsp
+
+import (
+	"compiler/ast"
+	"compiler/compiler/generator"
+	"compiler/compiler/target"
+	"compiler/lexer"
+	"compiler/module"
+	"compiler/parser"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
+)
+
+// pos is a place in a file: a line and a rune column, both from 0.
+type pos struct {
+	line, col int
+}
+
+func (p pos) before(q pos) bool {
+	return p.line < q.line || p.line == q.line && p.col < q.col
+}
+
+// file is a source file, lexed.
+type file struct {
+	uri    string
+	lines  [][]rune
+	tokens []lexer.LangToken
+	// index maps the line and lexer column of each token to its index.
+	index map[[2]int]int
+}
+
+func newFile(uri, text string) *file {
+	f := &file{uri: uri, index: make(map[[2]int]int)}
+	for _, line := range strings.Split(text, "\n") {
+		f.lines = append(f.lines, []rune(strings.TrimSuffix(line, "\r")))
+	}
+	l, err := lexer.NewLexerFromString(text)
+	if err != nil {
+		return f
+	}
+	for {
+		tok, err := l.NextToken()
+		if err != nil || tok.Type == lexer.TokenTypeEOF {
+			return f
+		}
+		f.index[[2]int{tok.Line, tok.Pos}] = len(f.tokens)
+		f.tokens = append(f.tokens, tok)
+	}
+}
+
+// start returns where tok starts. The lexer counts the columns of the first
+// line from 0 and those of the others from 1.
+func start(tok lexer.LangToken) pos {
+	if tok.Line == 0 {
+		return pos{0, tok.Pos}
+	}
+	return pos{tok.Line, tok.Pos - 1}
+}
+
+// end returns where tok ends. The literal of a string leaves out its quotes
+// and escapes, so strings are measured in the source.
+func (f *file) end(tok lexer.LangToken) pos {
+	p := start(tok)
+	if tok.Type == lexer.TokenTypeString && p.line < len(f.lines) {
+		line := f.lines[p.line]
+		if p.col < len(line) {
+			quote := line[p.col]
+			for i := p.col + 1; i < len(line); i++ {
+				switch line[i] {
+				case '\\':
+					i++
+				case quote:
+					return pos{p.line, i + 1}
+				}
+			}
+		}
+		return pos{p.line, len(line)}
+	}
+	n := tok.Length
+	if n == 0 {
+		n = len([]rune(tok.Literal))
+	}
+	return pos{p.line, p.col + n}
+}
+
+// position converts p to a protocol position, in UTF-16 code units.
+func (f *file) position(p pos) position {
+	if p.line >= len(f.lines) {
+		if len(f.lines) == 0 {
+			return position{}
+		}
+		p = pos{len(f.lines) - 1, len(f.lines[len(f.lines)-1])}
+	}
+	line := f.lines[p.line]
+	if p.col > len(line) {
+		p.col = len(line)
+	}
+	units := 0
+	for _, r := range line[:p.col] {
+		units += utf16Len(r)
+	}
+	return position{Line: p.line, Character: units}
+}
+
+// pos converts a protocol position to a place in f.
+func (f *file) pos(p position) pos {
+	if p.Line >= len(f.lines) {
+		return pos{p.Line, 0}
+	}
+	col, units := 0, 0
+	for _, r := range f.lines[p.Line] {
+		if units >= p.Character {
+			break
+		}
+		units += utf16Len(r)
+		col++
+	}
+	return pos{p.Line, col}
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func (f *file) textRange(from, to pos) textRange {
+	return textRange{Start: f.position(from), End: f.position(to)}
+}
+
+func (f *file) tokenRange(tok lexer.LangToken) textRange {
+	return f.textRange(start(tok), f.end(tok))
+}
+
+// blockEnd returns where the block opened by the brace open ends, or the
+// end of f when the brace is not closed.
+func (f *file) blockEnd(open lexer.LangToken) pos {
+	if i, ok := f.index[[2]int{open.Line, open.Pos}]; ok {
+		depth := 0
+		for _, tok := range f.tokens[i:] {
+			switch tok.Type {
+			case lexer.TokenTypeLeftBrace:
+				depth++
+			case lexer.TokenTypeRightBrace:
+				depth--
+				if depth == 0 {
+					return f.end(tok)
+				}
+			}
+		}
+	}
+	if len(f.lines) == 0 {
+		return pos{}
+	}
+	return pos{len(f.lines) - 1, len(f.lines[len(f.lines)-1])}
+}
+
+// tokenAt returns the index of the token at p, preferring an identifier
+// when p is between two tokens.
+func (f *file) tokenAt(p pos) (int, bool) {
+	found := -1
+	for i, tok := range f.tokens {
+		from, to := start(tok), f.end(tok)
+		if p.before(from) {
+			break
+		}
+		if to.before(p) {
+			continue
+		}
+		if found < 0 || tok.Type == lexer.TokenTypeIdentifier {
+			found = i
+		}
+	}
+	return found, found >= 0
+}
+
+// kind tells what a definition defines.
+type kind int
+
+const (
+	kindFunction kind = iota
+	kindType
+	kindData
+	kindGlobal
+	kindConst
+	kindLocal
+	kindParam
+	kindField
+	kindMethod
+	kindModule
+)
+
+// definition is a name a file declares.
+type definition struct {
+	name string
+	kind kind
+	file *file
+	tok  lexer.LangToken // the name, or the import keyword of a module
+	// end is where the declaration ends, for document symbols.
+	end    pos
+	detail string // the declaration as hover shows it
+	// typeName is the type of a variable, parameter or field, for the
+	// members of a value.
+	typeName string
+	children []*definition // the fields and methods of a type
+	// from and to bound where a local or a parameter is visible.
+	from, to pos
+	// importPath is the module a module definition imports.
+	importPath string
+}
+
+// moduleFile is a module imported by a document.
+type moduleFile struct {
+	*file
+	path string
+	defs []*definition
+}
+
+// analysis is what the server knows about a document: its tokens, its
+// syntax tree, the types the code generator inferred and the errors both
+// reported.
+type analysis struct {
+	*file
+	path    string
+	program *ast.Program
+	cg      *generator.CodeGenerator
+	mm      *module.ModuleManager
+
+	diagnostics []diagnostic
+	defs        []*definition // the top-level declarations
+	locals      []*definition // lets, parameters and loop variables
+	modules     map[string]*moduleFile
+}
+
+// analyze parses and compiles the document at uri, whose file is path, with
+// the modules it imports found by mm.
+func analyze(uri, filePath, text string, mm *module.ModuleManager, t *target.Target) *analysis {
+	a := &analysis{file: newFile(uri, text), path: filePath, mm: mm, modules: make(map[string]*moduleFile)}
+	l, err := lexer.NewLexerFromString(text)
+	if err != nil {
+		a.report(lexer.LangToken{}, err.Error())
+		return a
+	}
+	p := parser.NewParser(l)
+	a.program, err = parse(p)
+	if err != nil {
+		a.report(lexer.LangToken{}, err.Error())
+		return a
+	}
+	errs, toks := p.Errors(), p.ErrorTokens()
+	for i, msg := range errs {
+		a.report(toks[i], errorPosition.ReplaceAllString(msg, ""))
+	}
+
+	a.cg = generator.NewCodeGeneratorForTarget(t)
+	a.cg.ModuleManager = mm
+	a.cg.SourceFile = filePath
+	a.cg.Types = make(map[ast.Statement]types.Type)
+	// Errors the syntax errors leave behind are not worth reporting, but
+	// the types inferred before them are still of use.
+	if err := compile(a.program, a.cg); err != nil && len(errs) == 0 {
+		a.diagnostics = append(a.diagnostics, diagnostic{
+			Range:    a.locate(err),
+			Severity: severityError,
+			Source:   "ylang",
+			Message:  err.Error(),
+		})
+	}
+	a.declare()
+	return a
+}
+
+// errorPosition matches the position parser errors end with, which the
+// range of a diagnostic gives instead.
+var errorPosition = regexp.MustCompile(` *(at|near) line \d+(, (position|pos) \d+)?`)
+
+func parse(p *parser.Parser) (program *ast.Program, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("internal parser error: %v", r)
+		}
+	}()
+	return p.ParseProgram(), nil
+}
+
+func compile(program *ast.Program, cg *generator.CodeGenerator) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("internal compiler error: %v", r)
+		}
+	}()
+	return program.Accept(cg)
+}
+
+func (a *analysis) report(tok lexer.LangToken, msg string) {
+	a.diagnostics = append(a.diagnostics, diagnostic{
+		Range:    a.tokenRange(tok),
+		Severity: severityError,
+		Source:   "ylang",
+		Message:  msg,
+	})
+}
+
+// The errors of the code generator naming what it was compiling.
+var (
+	importError   = regexp.MustCompile(`^error visiting import (\S+):`)
+	functionError = regexp.MustCompile(`^error (?:visiting|declaring) function (\S+):`)
+	mainError     = regexp.MustCompile(`^error (?:visiting|declaring) main function:`)
+	typeError     = regexp.MustCompile(`^error defining (?:data )?type (\S+):`)
+)
+
+// locate returns the range an error of the code generator is about: the
+// statement it failed on when that is in the document, and otherwise the
+// declaration its message names, or the start of the document.
+func (a *analysis) locate(err error) textRange {
+	if st := a.cg.FailedAt; st != nil && a.contains(st) {
+		if tok, ok := nodeToken(st); ok {
+			from := start(tok)
+			return a.textRange(from, pos{from.line, len(a.lines[min(from.line, len(a.lines)-1)])})
+		}
+	}
+	msg := err.Error()
+	if m := importError.FindStringSubmatch(msg); m != nil {
+		for _, is := range a.program.ImportStatements {
+			if is.Path == m[1] {
+				return a.tokenRange(is.Token)
+			}
+		}
+	}
+	name := ""
+	if m := functionError.FindStringSubmatch(msg); m != nil {
+		name = m[1]
+	} else if m := typeError.FindStringSubmatch(msg); m != nil {
+		name = m[1]
+	} else if mainError.MatchString(msg) && a.program.MainFunction != nil {
+		name = a.program.MainFunction.Name.Value
+	}
+	for _, d := range a.defs {
+		if name != "" && d.name == name {
+			return a.tokenRange(d.tok)
+		}
+	}
+	return textRange{}
+}
+
+// contains reports whether the statement st is part of the document rather
+// than of a module it imports.
+func (a *analysis) contains(st ast.Statement) bool {
+	found := false
+	walk(a.program, func(node any) {
+		if node == st {
+			found = true
+		}
+	})
+	return found
+}
+
+var langTokenType = reflect.TypeOf(lexer.LangToken{})
+
+// nodeToken returns the token of node, its first but for some expressions.
+func nodeToken(node any) (lexer.LangToken, bool) {
+	v := reflect.ValueOf(node)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return lexer.LangToken{}, false
+	}
+	f := v.Elem().FieldByName("Token")
+	if !f.IsValid() || f.Type() != langTokenType {
+		return lexer.LangToken{}, false
+	}
+	tok := f.Interface().(lexer.LangToken)
+	return tok, tok.Type != ""
+}
+
+// walk calls visit with node and each node of the syntax tree below it,
+// parents before their children.
+func walk(node any, visit func(any)) {
+	walkValue(reflect.ValueOf(node), visit)
+}
+
+func walkValue(v reflect.Value, visit func(any)) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if !v.IsNil() {
+			walkValue(v.Elem(), visit)
+		}
+	case reflect.Pointer:
+		if v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			return
+		}
+		visit(v.Interface())
+		walkValue(v.Elem(), visit)
+	case reflect.Struct:
+		if v.Type() == langTokenType {
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				walkValue(v.Field(i), visit)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			walkValue(v.Index(i), visit)
+		}
+	}
+}
+
+// declare lists the declarations of the document, and the locals of its
+// functions with the types inferred for them.
+func (a *analysis) declare() {
+	if a.program == nil {
+		return
+	}
+	a.defs = declarations(a.file, a.program, a.cg)
+	for _, fn := range functions(a.program) {
+		if fn.Name == nil {
+			continue
+		}
+		body := a.scopeEnd(fn.Token, fn.Body)
+		for _, param := range fn.Parameters {
+			a.addParam(param, body, a.cg.Functions[fn.Name.Value], fn.Parameters)
+		}
+		a.addLocals(fn.Body, body)
+	}
+	for _, cd := range a.program.ClassDeclarations {
+		for _, member := range cd.Members {
+			md := member.MethodDeclaration
+			if md == nil {
+				continue
+			}
+			body := a.scopeEnd(md.Token, md.Body)
+			for _, param := range md.Parameters {
+				a.addParam(param, body, nil, nil)
+			}
+			a.addLocals(md.Body, body)
+		}
+	}
+	for _, tb := range a.program.Tests {
+		if tb.Body != nil {
+			a.addLocals(tb.Body, a.blockEnd(tb.Body.Token))
+		}
+	}
+}
+
+// scopeEnd returns where the parameters and locals of a function whose
+// body is body stop being visible: the end of a block, and otherwise the
+// end of the document.
+func (a *analysis) scopeEnd(head lexer.LangToken, body ast.ExpressionNode) pos {
+	if bs, ok := body.(*ast.BlockStatement); ok && bs != nil {
+		return a.blockEnd(bs.Token)
+	}
+	return a.blockEnd(head)
+}
+
+func (a *analysis) addParam(param *ast.Parameter, to pos, f *ir.Func, params []*ast.Parameter) {
+	if param == nil || param.Name == nil {
+		return
+	}
+	typeName := ""
+	if param.Type != nil {
+		typeName = param.Type.Value
+	} else if f != nil {
+		for i, p := range params {
+			if p == param && i < len(f.Params) {
+				typeName = generator.TypeName(f.Params[i].Typ)
+			}
+		}
+	}
+	a.locals = append(a.locals, &definition{
+		name:     param.Name.Value,
+		kind:     kindParam,
+		file:     a.file,
+		tok:      param.Name.Token,
+		detail:   withType(param.Name.Value, typeName),
+		typeName: typeName,
+		from:     start(param.Name.Token),
+		to:       to,
+	})
+}
+
+// addLocals adds the variables declared in body, visible until to.
+func (a *analysis) addLocals(body ast.Node, to pos) {
+	walk(body, func(node any) {
+		switch n := node.(type) {
+		case *ast.LetStatement:
+			if n.Name == nil {
+				return
+			}
+			d := letDefinition(a.file, n, a.cg)
+			d.kind = kindLocal
+			if n.Token.Type == lexer.TokenTypeConst {
+				d.kind = kindConst
+			}
+			d.from, d.to = start(n.Name.Token), to
+			a.locals = append(a.locals, d)
+		case *ast.ForInStatement:
+			if n.Variable == nil {
+				return
+			}
+			a.locals = append(a.locals, &definition{
+				name:   n.Variable.Value,
+				kind:   kindLocal,
+				file:   a.file,
+				tok:    n.Variable.Token,
+				detail: "for " + n.Variable.Value,
+				from:   start(n.Variable.Token),
+				to:     to,
+			})
+		case *ast.LambdaExpression:
+			for _, param := range n.Parameters {
+				a.addParam(param, to, nil, nil)
+			}
+		}
+	})
+}
+
+// functions returns the functions program declares, main among them.
+func functions(program *ast.Program) []*ast.FunctionDefinition {
+	fns := program.Functions
+	if program.MainFunction != nil {
+		fns = append([]*ast.FunctionDefinition{program.MainFunction}, fns...)
+	}
+	return fns
+}
+
+// declarations lists the top-level declarations of program, read from f.
+// cg, when not nil, has compiled it and gives the types left out.
+func declarations(f *file, program *ast.Program, cg *generator.CodeGenerator) []*definition {
+	var defs []*definition
+	for _, is := range program.ImportStatements {
+		if is == nil {
+			continue
+		}
+		defs = append(defs, &definition{
+			name:       path.Base(is.Path),
+			kind:       kindModule,
+			file:       f,
+			tok:        is.Token,
+			detail:     fmt.Sprintf("import %q", is.Path),
+			importPath: is.Path,
+		})
+	}
+	for _, fn := range functions(program) {
+		if fn.Name == nil {
+			continue
+		}
+		var irFunc *ir.Func
+		if cg != nil {
+			irFunc = cg.Functions[fn.Name.Value]
+		}
+		defs = append(defs, &definition{
+			name:     fn.Name.Value,
+			kind:     kindFunction,
+			file:     f,
+			tok:      fn.Name.Token,
+			end:      bodyEnd(f, fn.Token, fn.Body),
+			detail:   signature(fn.Name.Value, fn.Parameters, fn.ReturnType, irFunc),
+			typeName: returnType(fn.ReturnType, irFunc),
+		})
+	}
+	for _, cd := range program.ClassDeclarations {
+		if cd == nil || cd.Name == nil {
+			continue
+		}
+		d := &definition{name: cd.Name.Value, kind: kindType, file: f, tok: cd.Name.Token}
+		var lines []string
+		for _, member := range cd.Members {
+			if vd := member.VariableDeclaration; vd != nil && vd.Name != nil {
+				typeName := ""
+				if vd.Type != nil {
+					typeName = vd.Type.Value
+				}
+				field := &definition{name: vd.Name.Value, kind: kindField, file: f, tok: vd.Name.Token, detail: withType(vd.Name.Value, typeName), typeName: typeName}
+				field.end = f.end(vd.Name.Token)
+				d.children = append(d.children, field)
+				lines = append(lines, "    let "+field.detail+";")
+			}
+			if md := member.MethodDeclaration; md != nil && md.Name != nil {
+				var irFunc *ir.Func
+				if cg != nil {
+					irFunc = cg.Functions[cd.Name.Value+"_"+md.Name.Value]
+				}
+				method := &definition{
+					name:     md.Name.Value,
+					kind:     kindMethod,
+					file:     f,
+					tok:      md.Name.Token,
+					end:      bodyEnd(f, md.Token, md.Body),
+					detail:   signature(cd.Name.Value+"."+md.Name.Value, md.Parameters, md.ReturnType, nil),
+					typeName: returnType(md.ReturnType, irFunc),
+				}
+				d.children = append(d.children, method)
+				lines = append(lines, "    "+signature(md.Name.Value, md.Parameters, md.ReturnType, nil))
+			}
+		}
+		d.detail = "type " + cd.Name.Value + braced(lines)
+		d.end = f.blockEnd(nextBrace(f, cd.Name.Token))
+		defs = append(defs, d)
+	}
+	for _, ds := range program.DataStructures {
+		if ds == nil || ds.Name == nil {
+			continue
+		}
+		d := &definition{name: ds.Name.Value, kind: kindData, file: f, tok: ds.Name.Token}
+		var lines []string
+		for _, field := range ds.Fields {
+			if field == nil || field.Name == nil {
+				continue
+			}
+			typeName := ""
+			if field.Type != nil {
+				typeName = field.Type.Value
+			}
+			fd := &definition{name: field.Name.Value, kind: kindField, file: f, tok: field.Name.Token, detail: withType(field.Name.Value, typeName), typeName: typeName}
+			fd.end = f.end(field.Name.Token)
+			d.children = append(d.children, fd)
+			lines = append(lines, "    let "+fd.detail)
+		}
+		d.detail = "data " + ds.Name.Value + braced(lines)
+		d.end = f.blockEnd(nextBrace(f, ds.Name.Token))
+		defs = append(defs, d)
+	}
+	for _, ls := range program.Globals {
+		if ls == nil || ls.Name == nil {
+			continue
+		}
+		d := letDefinition(f, ls, cg)
+		d.kind = kindGlobal
+		if ls.Token.Type == lexer.TokenTypeConst {
+			d.kind = kindConst
+		}
+		defs = append(defs, d)
+	}
+	return defs
+}
+
+// letDefinition describes the variable ls declares, with its inferred type
+// when it has no annotation.
+func letDefinition(f *file, ls *ast.LetStatement, cg *generator.CodeGenerator) *definition {
+	typeName := ""
+	if ls.Type != nil {
+		typeName = ls.Type.Value
+	} else if cg != nil {
+		if t, ok := cg.Types[ls]; ok {
+			typeName = generator.TypeName(t)
+		}
+	}
+	keyword := "let "
+	if ls.Token.Type == lexer.TokenTypeConst {
+		keyword = "const "
+	}
+	return &definition{
+		name:     ls.Name.Value,
+		file:     f,
+		tok:      ls.Name.Token,
+		end:      f.end(ls.Name.Token),
+		detail:   keyword + withType(ls.Name.Value, typeName),
+		typeName: typeName,
+	}
+}
+
+// bodyEnd returns where a function declared from head with body ends.
+func bodyEnd(f *file, head lexer.LangToken, body ast.ExpressionNode) pos {
+	if bs, ok := body.(*ast.BlockStatement); ok && bs != nil {
+		return f.blockEnd(bs.Token)
+	}
+	return f.end(head)
+}
+
+// nextBrace returns the first brace after tok, which opens the body of the
+// type or data tok names.
+func nextBrace(f *file, tok lexer.LangToken) lexer.LangToken {
+	if i, ok := f.index[[2]int{tok.Line, tok.Pos}]; ok {
+		for _, next := range f.tokens[i:] {
+			if next.Type == lexer.TokenTypeLeftBrace {
+				return next
+			}
+		}
+	}
+	return tok
+}
+
+func braced(lines []string) string {
+	if len(lines) == 0 {
+		return " {}"
+	}
+	return " {\n" + strings.Join(lines, "\n") + "\n}"
+}
+
+func withType(name, typeName string) string {
+	if typeName == "" {
+		return name
+	}
+	return name + ": " + typeName
+}
+
+// signature spells a function as it is declared, with the types the code
+// generator gave the parameters and the result when they are left out.
+func signature(name string, params []*ast.Parameter, ret *ast.Identifier, f *ir.Func) string {
+	list := make([]string, 0, len(params))
+	for i, p := range params {
+		if p == nil || p.Name == nil {
+			continue
+		}
+		typeName := ""
+		if p.Type != nil {
+			typeName = p.Type.Value
+		} else if f != nil && i < len(f.Params) {
+			typeName = generator.TypeName(f.Params[i].Typ)
+		}
+		list = append(list, withType(p.Name.Value, typeName))
+	}
+	s := name + "(" + strings.Join(list, ", ") + ")"
+	if r := returnType(ret, f); r != "" && r != "void" {
+		s += ": " + r
+	}
+	return s
+}
+
+func returnType(ret *ast.Identifier, f *ir.Func) string {
+	if ret != nil {
+		return ret.Value
+	}
+	if f != nil {
+		return generator.TypeName(f.Sig.RetType)
+	}
+	return ""
+}
+
+// module returns the module imported as importPath, nil when it cannot be
+// loaded.
+func (a *analysis) module(importPath string) *moduleFile {
+	if m, ok := a.modules[importPath]; ok {
+		return m
+	}
+	var m *moduleFile
+	if mod, err := a.mm.LoadModule(importPath); err == nil {
+		if src, err := os.ReadFile(mod.Path); err == nil {
+			f := newFile(fileURI(mod.Path), string(src))
+			m = &moduleFile{file: f, path: filePath(f.uri), defs: declarations(f, mod.AST, nil)}
+		}
+	}
+	a.modules[importPath] = m
+	return m
+}
+
+// lookup returns what name refers to at p: the local declared last before
+// p, or else a top-level declaration.
+func (a *analysis) lookup(name string, p pos) *definition {
+	var found *definition
+	for _, d := range a.locals {
+		if d.name == name && !p.before(d.from) && !d.to.before(p) && (found == nil || found.from.before(d.from)) {
+			found = d
+		}
+	}
+	if found != nil {
+		return found
+	}
+	for _, d := range a.defs {
+		if d.name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// member returns what left.name refers to at p: a declaration of the
+// module left imports, or a field or method of the type of left.
+func (a *analysis) member(left, name string, p pos) *definition {
+	for _, d := range a.members(left, p) {
+		if d.name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// members returns the declarations of the module the name left imports
+// at p, or the fields and methods of the type of the value it names.
+func (a *analysis) members(left string, p pos) []*definition {
+	d := a.lookup(left, p)
+	if d == nil {
+		return nil
+	}
+	if d.kind == kindModule {
+		if m := a.module(d.importPath); m != nil {
+			return m.defs
+		}
+		return nil
+	}
+	typeName := strings.TrimLeft(d.typeName, "*")
+	defs := a.defs
+	// A type of a module is named through the module.
+	if alias, name, ok := strings.Cut(typeName, "."); ok {
+		defs, typeName = nil, name
+		if md := a.lookup(alias, p); md != nil && md.kind == kindModule {
+			if m := a.module(md.importPath); m != nil {
+				defs = m.defs
+			}
+		}
+	}
+	for _, td := range defs {
+		if (td.kind == kindType || td.kind == kindData) && td.name == typeName {
+			return td.children
+		}
+	}
+	return nil
+}
+
+// resolve returns what the identifier at index i of the tokens of the
+// document refers to.
+func (a *analysis) resolve(i int) *definition {
+	tok := a.tokens[i]
+	if tok.Type != lexer.TokenTypeIdentifier {
+		return nil
+	}
+	p := start(tok)
+	if i >= 2 && a.tokens[i-1].Type == lexer.TokenTypeDot && a.tokens[i-2].Type == lexer.TokenTypeIdentifier {
+		return a.member(a.tokens[i-2].Literal, tok.Literal, p)
+	}
+	return a.lookup(tok.Literal, p)
+}
+
+// importAt returns the import path of the import statement whose path is
+// the token at index i.
+func (a *analysis) importAt(i int) (string, bool) {
+	tok := a.tokens[i]
+	if tok.Type == lexer.TokenTypeString && i > 0 && a.tokens[i-1].Type == lexer.TokenTypeImport {
+		return tok.Literal, true
+	}
+	return "", false
+}
@@ -0,0 +1,379 @@
+package lsp
+
+import (
+	"compiler/lexer"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// hover describes what the name at p refers to, or the module an import
+// path at p names.
+func (a *analysis) hover(p position) *hover {
+	i, ok := a.tokenAt(a.pos(p))
+	if !ok {
+		return nil
+	}
+	r := a.tokenRange(a.tokens[i])
+	if importPath, ok := a.importAt(i); ok {
+		text := "import " + quote(importPath)
+		if m := a.module(importPath); m != nil {
+			text += "\n// " + m.path
+		}
+		return &hover{Contents: code(text), Range: &r}
+	}
+	d := a.resolve(i)
+	if d == nil {
+		return nil
+	}
+	return &hover{Contents: code(d.detail), Range: &r}
+}
+
+func code(text string) markupContent {
+	return markupContent{Kind: "markdown", Value: "```y\n" + text + "\n```"}
+}
+
+func quote(s string) string {
+	return "\"" + s + "\""
+}
+
+// definition returns where the name at p is declared, in the document or in
+// a module it imports, or the file of the module an import path at p names.
+func (a *analysis) definition(p position) *location {
+	i, ok := a.tokenAt(a.pos(p))
+	if !ok {
+		return nil
+	}
+	if importPath, ok := a.importAt(i); ok {
+		return a.moduleLocation(importPath)
+	}
+	d := a.resolve(i)
+	if d == nil {
+		return nil
+	}
+	if d.kind == kindModule {
+		return a.moduleLocation(d.importPath)
+	}
+	return &location{URI: d.file.uri, Range: d.file.tokenRange(d.tok)}
+}
+
+func (a *analysis) moduleLocation(importPath string) *location {
+	m := a.module(importPath)
+	if m == nil {
+		return nil
+	}
+	return &location{URI: m.uri}
+}
+
+// symbols returns the functions, types, data and globals of the document,
+// with the fields and methods of types.
+func (a *analysis) symbols() []documentSymbol {
+	symbols := []documentSymbol{}
+	for _, d := range a.defs {
+		if d.kind == kindModule {
+			continue
+		}
+		symbols = append(symbols, a.symbol(d))
+	}
+	return symbols
+}
+
+// symbolKinds maps the kinds of definitions to the kinds of symbols.
+var symbolKinds = map[kind]int{
+	kindFunction: symbolFunction,
+	kindType:     symbolClass,
+	kindData:     symbolStruct,
+	kindGlobal:   symbolVariable,
+	kindConst:    symbolConstant,
+	kindField:    symbolField,
+	kindMethod:   symbolMethod,
+}
+
+func (a *analysis) symbol(d *definition) documentSymbol {
+	from := start(d.tok)
+	to := d.end
+	if to.before(from) {
+		to = a.end(d.tok)
+	}
+	sym := documentSymbol{
+		Name:           d.name,
+		Detail:         firstLine(d.detail),
+		Kind:           symbolKinds[d.kind],
+		Range:          a.textRange(from, to),
+		SelectionRange: a.tokenRange(d.tok),
+	}
+	for _, child := range d.children {
+		sym.Children = append(sym.Children, a.symbol(child))
+	}
+	return sym
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return strings.TrimSuffix(line, " {")
+}
+
+// keywords are offered by completion outside of members, with the types
+// every program can name.
+var keywords = func() []string {
+	var words []string
+	for word := range lexer.Keywords {
+		if isWord(word) {
+			words = append(words, word)
+		}
+	}
+	sort.Strings(words)
+	return words
+}()
+
+var builtinTypes = []string{"any", "bool", "f32", "f64", "i8", "i16", "i32", "i64", "string", "u8", "u16", "u32", "u64", "void"}
+
+func isWord(s string) bool {
+	return s != "" && (s[0] == '_' || s[0] >= 'a' && s[0] <= 'z' || s[0] >= 'A' && s[0] <= 'Z')
+}
+
+var (
+	importPrefix = regexp.MustCompile(`\bimport\s+"([^"]*)$`)
+	memberPrefix = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\s*\.\s*[A-Za-z0-9_]*$`)
+)
+
+// complete returns what may be typed at p: the modules under the stdlib
+// directories of dirs in an import path, the members of a module or a value
+// after a dot, and otherwise the names in scope and the keywords.
+func (a *analysis) complete(p position, dirs []string) completionList {
+	at := a.pos(p)
+	var prefix string
+	if at.line < len(a.lines) {
+		line := a.lines[at.line]
+		prefix = string(line[:min(at.col, len(line))])
+	}
+	items := []completionItem{}
+	switch {
+	case importPrefix.MatchString(prefix):
+		typed := importPrefix.FindStringSubmatch(prefix)[1]
+		from := pos{at.line, at.col - len([]rune(typed))}
+		for _, name := range stdlibModules(dirs) {
+			items = append(items, completionItem{
+				Label:    name,
+				Kind:     completionModule,
+				TextEdit: &textEdit{Range: a.textRange(from, at), NewText: name},
+			})
+		}
+	case memberPrefix.MatchString(prefix):
+		left := memberPrefix.FindStringSubmatch(prefix)[1]
+		for _, d := range a.members(left, at) {
+			items = append(items, completionFor(d))
+		}
+	default:
+		seen := make(map[string]bool)
+		for _, d := range a.locals {
+			if !at.before(d.from) && !d.to.before(at) && !seen[d.name] {
+				seen[d.name] = true
+				items = append(items, completionFor(d))
+			}
+		}
+		for _, d := range a.defs {
+			if !seen[d.name] {
+				seen[d.name] = true
+				items = append(items, completionFor(d))
+			}
+		}
+		for _, word := range keywords {
+			items = append(items, completionItem{Label: word, Kind: completionKeyword})
+		}
+		for _, word := range builtinTypes {
+			items = append(items, completionItem{Label: word, Kind: completionStruct})
+		}
+	}
+	return completionList{Items: items}
+}
+
+// completionKinds maps the kinds of definitions to the kinds of completion
+// items.
+var completionKinds = map[kind]int{
+	kindFunction: completionFunction,
+	kindType:     completionClass,
+	kindData:     completionStruct,
+	kindGlobal:   completionVariable,
+	kindConst:    completionConstant,
+	kindLocal:    completionVariable,
+	kindParam:    completionVariable,
+	kindField:    completionField,
+	kindMethod:   completionMethod,
+	kindModule:   completionModule,
+}
+
+func completionFor(d *definition) completionItem {
+	return completionItem{Label: d.name, Kind: completionKinds[d.kind], Detail: firstLine(d.detail)}
+}
+
+// stdlibModules returns the import paths of the modules in the stdlib
+// directories of dirs: stdlib/name for stdlib/name.y and for
+// stdlib/name/name.y.
+func stdlibModules(dirs []string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, dir := range dirs {
+		stdlib := filepath.Join(dir, "stdlib")
+		entries, err := os.ReadDir(stdlib)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if e.IsDir() {
+				if _, err := os.Stat(filepath.Join(stdlib, name, name+".y")); err != nil {
+					continue
+				}
+			} else if filepath.Ext(name) == ".y" {
+				name = strings.TrimSuffix(name, ".y")
+			} else {
+				continue
+			}
+			if path := "stdlib/" + name; !seen[path] {
+				seen[path] = true
+				names = append(names, path)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// The semantic token types and modifiers the server reports, indexed by
+// semanticTokens.
+var legend = semanticTokensLegend{
+	TokenTypes: []string{
+		"namespace", "type", "parameter", "variable", "property", "function",
+		"method", "keyword", "string", "number", "operator",
+	},
+	TokenModifiers: []string{"declaration", "readonly"},
+}
+
+const (
+	tokenNamespace = iota
+	tokenType
+	tokenParameter
+	tokenVariable
+	tokenProperty
+	tokenFunction
+	tokenMethod
+	tokenKeyword
+	tokenString
+	tokenNumber
+	tokenOperator
+)
+
+const (
+	modifierDeclaration = 1 << iota
+	modifierReadonly
+)
+
+// operators are the token types classified as operators.
+var operators = map[lexer.TokenType]bool{
+	lexer.TokenTypeAssignment:       true,
+	lexer.TokenTypePlus:             true,
+	lexer.TokenTypeMinus:            true,
+	lexer.TokenTypeMultiply:         true,
+	lexer.TokenTypeDivide:           true,
+	lexer.TokenTypeModulo:           true,
+	lexer.TokenTypeBang:             true,
+	lexer.TokenTypeNotEqual:         true,
+	lexer.TokenTypeLogicalAnd:       true,
+	lexer.TokenTypeLogicalOr:        true,
+	lexer.TokenTypeAmpersand:        true,
+	lexer.TokenTypePipe:             true,
+	lexer.TokenTypeCaret:            true,
+	lexer.TokenTypeTilde:            true,
+	lexer.TokenTypeShiftLeft:        true,
+	lexer.TokenTypeShiftRight:       true,
+	lexer.TokenTypeShiftRightLogic:  true,
+	lexer.TokenTypeEllipsis:         true,
+	lexer.TokenTypeEqual:            true,
+	lexer.TokenTypeLessThan:         true,
+	lexer.TokenTypeLessThanEqual:    true,
+	lexer.TokenTypeGreaterThan:      true,
+	lexer.TokenTypeGreaterThanEqual: true,
+	lexer.TokenTypeQuestionMark:     true,
+	lexer.TokenTypeArrow:            true,
+	lexer.TokenTypeLambdaArrow:      true,
+}
+
+// semanticTokens classifies the tokens of the document: keywords, strings,
+// numbers and operators by their token type, and identifiers by what they
+// refer to.
+func (a *analysis) semanticTokens() semanticTokens {
+	data := []int{}
+	var last position
+	for i, tok := range a.tokens {
+		typ, mods, ok := a.classify(i)
+		if !ok {
+			continue
+		}
+		r := a.tokenRange(tok)
+		if r.End.Line != r.Start.Line || r.End.Character <= r.Start.Character {
+			continue
+		}
+		line, char := r.Start.Line-last.Line, r.Start.Character
+		if line == 0 {
+			char -= last.Character
+		}
+		data = append(data, line, char, r.End.Character-r.Start.Character, typ, mods)
+		last = r.Start
+	}
+	return semanticTokens{Data: data}
+}
+
+// classify returns the semantic token type and modifiers of the token at
+// index i, and false for punctuation.
+func (a *analysis) classify(i int) (int, int, bool) {
+	tok := a.tokens[i]
+	switch {
+	case tok.Type == lexer.TokenTypeString:
+		return tokenString, 0, true
+	case tok.Type == lexer.TokenTypeNumber:
+		return tokenNumber, 0, true
+	case operators[tok.Type]:
+		return tokenOperator, 0, true
+	case tok.Type != lexer.TokenTypeIdentifier:
+		if lexer.Keywords[tok.Literal] == tok.Type {
+			return tokenKeyword, 0, true
+		}
+		return 0, 0, false
+	}
+	for _, name := range builtinTypes {
+		if tok.Literal == name {
+			return tokenType, 0, true
+		}
+	}
+	d := a.resolve(i)
+	if d == nil {
+		if i > 0 && a.tokens[i-1].Type == lexer.TokenTypeDot {
+			return tokenProperty, 0, true
+		}
+		return tokenVariable, 0, true
+	}
+	mods := 0
+	if d.file == a.file && d.tok == tok {
+		mods |= modifierDeclaration
+	}
+	switch d.kind {
+	case kindModule:
+		return tokenNamespace, mods, true
+	case kindFunction:
+		return tokenFunction, mods, true
+	case kindMethod:
+		return tokenMethod, mods, true
+	case kindType, kindData:
+		return tokenType, mods, true
+	case kindParam:
+		return tokenParameter, mods, true
+	case kindField:
+		return tokenProperty, mods, true
+	case kindConst:
+		return tokenVariable, mods | modifierReadonly, true
+	}
+	return tokenVariable, mods, true
+}
@@ -0,0 +1,448 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client drives a server in process, as an editor would over stdio.
+type client struct {
+	t      *testing.T
+	conn   *conn
+	nextID int
+	// messages receives what the server sends; pending holds the
+	// notifications read while waiting for a response.
+	messages chan *message
+	pending  []*message
+	done     chan error
+}
+
+func newClient(t *testing.T, root string) *client {
+	t.Helper()
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+	s := NewServer(nil)
+	s.SearchPaths = []string{filepath.Join("..", "lib")}
+	c := &client{t: t, conn: newConn(clientIn, clientOut), messages: make(chan *message, 64), done: make(chan error, 1)}
+	go func() {
+		c.done <- s.Serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+	go func() {
+		for {
+			msg, err := c.conn.read()
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+	c.call("initialize", map[string]any{"rootUri": fileURI(root)}, nil)
+	c.notify("initialized", map[string]any{})
+	return c
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	if err := c.conn.notify(method, params); err != nil {
+		c.t.Fatalf("%s: %v", method, err)
+	}
+}
+
+// call sends a request and decodes the result of its response into result.
+func (c *client) call(method string, params any, result any) {
+	c.t.Helper()
+	c.nextID++
+	id := json.RawMessage(fmt.Sprint(c.nextID))
+	raw, _ := json.Marshal(params)
+	if err := c.conn.write(&message{ID: id, Method: method, Params: raw}); err != nil {
+		c.t.Fatalf("%s: %v", method, err)
+	}
+	for {
+		msg := c.receive()
+		if msg.Method != "" {
+			c.pending = append(c.pending, msg)
+			continue
+		}
+		if string(msg.ID) != string(id) {
+			c.t.Fatalf("%s: response to request %s", method, msg.ID)
+		}
+		if msg.Error != nil {
+			c.t.Fatalf("%s: %v", method, msg.Error)
+		}
+		if result != nil {
+			if err := json.Unmarshal(msg.Result, result); err != nil {
+				c.t.Fatalf("%s: %v", method, err)
+			}
+		}
+		return
+	}
+}
+
+func (c *client) receive() *message {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			c.t.Fatalf("the server closed the connection")
+		}
+		return msg
+	case <-time.After(30 * time.Second):
+		c.t.Fatalf("no message from the server")
+	}
+	return nil
+}
+
+// diagnostics returns the next diagnostics published for uri.
+func (c *client) diagnostics(uri string) []diagnostic {
+	c.t.Helper()
+	for {
+		var msg *message
+		if len(c.pending) > 0 {
+			msg, c.pending = c.pending[0], c.pending[1:]
+		} else {
+			msg = c.receive()
+		}
+		if msg.Method != "textDocument/publishDiagnostics" {
+			continue
+		}
+		var p publishDiagnosticsParams
+		if err := json.Unmarshal(msg.Params, &p); err != nil {
+			c.t.Fatal(err)
+		}
+		if p.URI == uri {
+			return p.Diagnostics
+		}
+	}
+}
+
+func (c *client) open(uri, text string) []diagnostic {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", didOpenParams{TextDocument: textDocumentItem{URI: uri, LanguageID: "y", Version: 1, Text: text}})
+	return c.diagnostics(uri)
+}
+
+func at(uri string, line, char int) textDocumentPositionParams {
+	return textDocumentPositionParams{TextDocument: textDocumentIdentifier{URI: uri}, Position: position{Line: line, Character: char}}
+}
+
+// shutdown ends the session as an editor does and waits for the server.
+func (c *client) shutdown() {
+	c.t.Helper()
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	select {
+	case err := <-c.done:
+		if err != nil {
+			c.t.Errorf("Serve: %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		c.t.Fatalf("the server did not exit")
+	}
+}
+
+const shapes = `type Point {
+    let x: i64;
+    let y: i64;
+    function i64 sum(self: *Point) -> { return self.x + self.y; }
+}
+
+function area(w: i64, h: i64): i64 -> {
+    return w * h;
+}
+`
+
+const program = `import "stdlib/fmt";
+import "shapes";
+
+type Pair {
+    let a: i64;
+    let b: i64;
+}
+
+function square(n: i64) -> {
+    return n * n;
+}
+
+main() -> {
+    let total = square(7);
+    let area = shapes.area(3, 4);
+    let p = 0 as *Pair;
+    let q = 0 as *shapes.Point;
+    fmt.printf("%d %d\n", total, area);
+    return 0;
+}
+`
+
+// lineOf returns the 0-based line of the file at path that starts with
+// prefix.
+func lineOf(t *testing.T, path, prefix string) int {
+	t.Helper()
+	text, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, line := range strings.Split(string(text), "\n") {
+		if strings.HasPrefix(line, prefix) {
+			return i
+		}
+	}
+	t.Fatalf("%s has no line starting with %q", path, prefix)
+	return 0
+}
+
+// TestServer covers a session on a program importing a module of the
+// standard library and one of its own: hover, definition, symbols and
+// completion, then the diagnostics of documents with errors.
+func TestServer(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "shapes.y"), []byte(shapes), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	uri := fileURI(filepath.Join(dir, "main.y"))
+	shapesURI := fileURI(filepath.Join(dir, "shapes.y"))
+	fmtPath := filepath.Join("..", "lib", "stdlib", "fmt", "fmt.y")
+	fmtURI := fileURI(fmtPath)
+	printfLine := lineOf(t, fmtPath, "function printf(")
+	c := newClient(t, dir)
+	defer c.shutdown()
+
+	if diags := c.open(uri, program); len(diags) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+
+	t.Run("Hover", func(t *testing.T) {
+		tests := []struct {
+			line, char int
+			expected   string
+		}{
+			{13, 9, "let total: i32"},
+			{13, 17, "square(n: i64): i32"},
+			{8, 16, "n: i64"},
+			{14, 9, "let area: i64"},
+			{14, 23, "area(w: i64, h: i64): i64"},
+			{15, 9, "let p: *Pair"},
+			{17, 5, `import "stdlib/fmt"`},
+			{3, 6, "type Pair {\n    let a: i64;\n    let b: i64;\n}"},
+		}
+		for _, tt := range tests {
+			var h *hover
+			c.call("textDocument/hover", at(uri, tt.line, tt.char), &h)
+			if h == nil {
+				t.Errorf("%d:%d: no hover", tt.line, tt.char)
+				continue
+			}
+			if expected := "```y\n" + tt.expected + "\n```"; h.Contents.Value != expected {
+				t.Errorf("%d:%d: hover %q, want %q", tt.line, tt.char, h.Contents.Value, expected)
+			}
+		}
+	})
+
+	t.Run("Definition", func(t *testing.T) {
+		tests := []struct {
+			line, char int
+			uri        string
+			at         position
+		}{
+			{13, 17, uri, position{8, 9}},
+			{17, 28, uri, position{13, 8}},
+			{14, 23, shapesURI, position{6, 9}},
+			{14, 16, shapesURI, position{0, 0}},
+			{1, 10, shapesURI, position{0, 0}},
+			{17, 9, fmtURI, position{printfLine, 9}},
+		}
+		for _, tt := range tests {
+			var loc *location
+			c.call("textDocument/definition", at(uri, tt.line, tt.char), &loc)
+			if loc == nil {
+				t.Errorf("%d:%d: no definition", tt.line, tt.char)
+				continue
+			}
+			if loc.URI != tt.uri || loc.Range.Start != tt.at {
+				t.Errorf("%d:%d: definition at %s %v, want %s %v", tt.line, tt.char, loc.URI, loc.Range.Start, tt.uri, tt.at)
+			}
+		}
+	})
+
+	t.Run("Symbols", func(t *testing.T) {
+		var symbols []documentSymbol
+		c.call("textDocument/documentSymbol", documentSymbolParams{TextDocument: textDocumentIdentifier{URI: uri}}, &symbols)
+		var got []string
+		for _, sym := range symbols {
+			got = append(got, fmt.Sprintf("%s:%d", sym.Name, sym.Kind))
+			for _, child := range sym.Children {
+				got = append(got, fmt.Sprintf("%s.%s:%d", sym.Name, child.Name, child.Kind))
+			}
+		}
+		expected := "main:12 square:12 Pair:5 Pair.a:8 Pair.b:8"
+		if strings.Join(got, " ") != expected {
+			t.Errorf("symbols %s, want %s", strings.Join(got, " "), expected)
+		}
+	})
+
+	t.Run("Completion", func(t *testing.T) {
+		// Member accesses being typed, before the return of main.
+		c.notify("textDocument/didChange", didChangeParams{
+			TextDocument: versionedTextDocumentIdentifier{URI: uri, Version: 2},
+			ContentChanges: []contentChange{{
+				Range: &textRange{Start: position{18, 4}, End: position{18, 4}},
+				Text:  "q.\n    p.\n    shapes.\n    ",
+			}},
+		})
+		c.diagnostics(uri)
+		tests := []struct {
+			line, char int
+			expected   string
+		}{
+			{18, 6, "x y sum"},
+			{19, 6, "a b"},
+			{20, 11, "area Point"},
+		}
+		for _, tt := range tests {
+			var list completionList
+			c.call("textDocument/completion", at(uri, tt.line, tt.char), &list)
+			var labels []string
+			for _, item := range list.Items {
+				labels = append(labels, item.Label)
+			}
+			if strings.Join(labels, " ") != tt.expected {
+				t.Errorf("%d:%d: completion %s, want %s", tt.line, tt.char, strings.Join(labels, " "), tt.expected)
+			}
+		}
+
+		var list completionList
+		c.call("textDocument/completion", at(uri, 21, 4), &list)
+		labels := make(map[string]bool)
+		for _, item := range list.Items {
+			labels[item.Label] = true
+		}
+		for _, name := range []string{"total", "q", "square", "Pair", "fmt", "return", "i64"} {
+			if !labels[name] {
+				t.Errorf("completion in main lacks %s", name)
+			}
+		}
+
+		importURI := fileURI(filepath.Join(dir, "partial.y"))
+		c.open(importURI, `import "stdlib/f`)
+		c.call("textDocument/completion", at(importURI, 0, 16), &list)
+		var paths []string
+		for _, item := range list.Items {
+			if strings.HasPrefix(item.Label, "stdlib/f") {
+				paths = append(paths, item.Label)
+			}
+			if item.TextEdit == nil || item.TextEdit.Range.Start != (position{0, 8}) {
+				t.Errorf("completion %s does not replace the path typed", item.Label)
+			}
+		}
+		if strings.Join(paths, " ") != "stdlib/flags stdlib/fmt stdlib/fs" {
+			t.Errorf("import completion %v, want the stdlib modules", paths)
+		}
+	})
+
+	t.Run("Diagnostics", func(t *testing.T) {
+		tests := []struct {
+			name, text string
+			expected   string
+		}{
+			{
+				name:     "syntax",
+				text:     "main() -> {\n    let x = ;\n    return 0;\n}\n",
+				expected: "1:12-1:13 Operator ';' cannot start an expression",
+			},
+			{
+				name:     "semantic",
+				text:     "type Pair {\n    let a: i64;\n}\nmain() -> {\n    let p = 0 as *Pair;\n    let z = p.z;\n    return 0;\n}\n",
+				expected: "5:4-5:16 error visiting main function: error generating body for function 'main': field 'z' not found in struct type 'Pair'",
+			},
+			{
+				name:     "cycle",
+				text:     "let a = b + 1;\nlet b = a + 1;\nmain() -> {\n    return a;\n}\n",
+				expected: "0:0-0:14 let 'a' at line 1: initialization cycle: 'a' refers to 'b', 'b' refers to 'a' at line 2",
+			},
+			{
+				name:     "import",
+				text:     "import \"stdlib/nope\";\nmain() -> {\n    return 0;\n}\n",
+				expected: "0:0-0:6 error visiting import stdlib/nope: module stdlib/nope not found in search paths",
+			},
+		}
+		for _, tt := range tests {
+			diags := c.open(fileURI(filepath.Join(dir, tt.name+".y")), tt.text)
+			var got []string
+			for _, d := range diags {
+				got = append(got, fmt.Sprintf("%d:%d-%d:%d %s", d.Range.Start.Line, d.Range.Start.Character, d.Range.End.Line, d.Range.End.Character, d.Message))
+			}
+			if strings.Join(got, "\n") != tt.expected {
+				t.Errorf("%s: diagnostics\n%s\nwant\n%s", tt.name, strings.Join(got, "\n"), tt.expected)
+			}
+		}
+	})
+}
+
+// TestSemanticTokens covers classifying tokens by their type and
+// identifiers by what they refer to.
+func TestSemanticTokens(t *testing.T) {
+	dir := t.TempDir()
+	uri := fileURI(filepath.Join(dir, "main.y"))
+	c := newClient(t, dir)
+	defer c.shutdown()
+	text := "import \"stdlib/fmt\";\nconst LIMIT = 3;\nmain() -> {\n    let n: i64 = LIMIT + 1;\n    fmt.printf(\"%d\", n);\n    return 0;\n}\n"
+	c.open(uri, text)
+
+	var tokens semanticTokens
+	c.call("textDocument/semanticTokens/full", semanticTokensParams{TextDocument: textDocumentIdentifier{URI: uri}}, &tokens)
+	lines := strings.Split(text, "\n")
+	var got []string
+	line, char := 0, 0
+	for i := 0; i+4 < len(tokens.Data); i += 5 {
+		d := tokens.Data[i : i+5]
+		if d[0] > 0 {
+			char = 0
+		}
+		line, char = line+d[0], char+d[1]
+		tok := lines[line][char : char+d[2]]
+		desc := tok + ":" + legend.TokenTypes[d[3]]
+		for bit, mod := range legend.TokenModifiers {
+			if d[4]&(1<<bit) != 0 {
+				desc += "." + mod
+			}
+		}
+		got = append(got, desc)
+	}
+	expected := []string{
+		"import:keyword", `"stdlib/fmt":string`,
+		"const:keyword", "LIMIT:variable.declaration.readonly", "=:operator", "3:number",
+		"main:function.declaration", "->:operator",
+		"let:keyword", "n:variable.declaration", "i64:type", "=:operator", "LIMIT:variable.readonly", "+:operator", "1:number",
+		"fmt:namespace", "printf:function", `"%d":string`, "n:variable",
+		"return:keyword", "0:number",
+	}
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("tokens\n%s\nwant\n%s", strings.Join(got, " "), strings.Join(expected, " "))
+	}
+}
+
+// TestApplyChange covers edits of a range, counted in UTF-16 code units.
+func TestApplyChange(t *testing.T) {
+	tests := []struct {
+		text     string
+		change   contentChange
+		expected string
+	}{
+		{"abc", contentChange{Text: "xyz"}, "xyz"},
+		{"ab\ncd\n", contentChange{Range: &textRange{Start: position{1, 1}, End: position{1, 2}}, Text: "X"}, "ab\ncX\n"},
+		{"a\U0001F600b\n", contentChange{Range: &textRange{Start: position{0, 3}, End: position{0, 4}}, Text: "c"}, "a\U0001F600c\n"},
+		{"ab", contentChange{Range: &textRange{Start: position{0, 2}, End: position{0, 2}}, Text: "\ncd"}, "ab\ncd"},
+	}
+	for _, tt := range tests {
+		if got := applyChange(tt.text, tt.change); got != tt.expected {
+			t.Errorf("applyChange(%q) = %q, want %q", tt.text, got, tt.expected)
+		}
+	}
+}
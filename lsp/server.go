@@ -0,0 +1,315 @@
+// Package lsp implements ylang lsp, a language server for Y. It speaks the
+// language server protocol, JSON-RPC messages framed by a Content-Length
+// header, over a pair of streams.
+//
+// Each time a document is opened or changed it is parsed and compiled with
+// the code generator, and the errors of both are published as diagnostics;
+// the code generator stops at its first error, which is reported only once
+// the document parses. What the compilation infers serves the requests:
+// hover shows declarations with the types of variables and functions that
+// leave them out, definition follows names into the modules a document
+// imports, found by a module.ModuleManager, document symbols list the
+// functions, types, data and globals, completion offers the members of
+// modules and values, the names in scope and the modules of the standard
+// library, and semantic tokens classify the tokens of the lexer.
+package lsp
+
+import (
+	"compiler/compiler/target"
+	"compiler/module"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Server is a language server.
+type Server struct {
+	// SearchPaths are searched for imported modules after the default
+	// search paths of module.ModuleManager, the directory of the document,
+	// the root of the workspace and its lib directory.
+	SearchPaths []string
+
+	target *target.Target
+	conn   *conn
+	root   string
+	docs   map[string]*document
+
+	initialized, shutdown bool
+}
+
+// document is a document open in the client.
+type document struct {
+	uri, path string
+	version   int
+	text      string
+	analysis  *analysis
+}
+
+// NewServer returns a server compiling for target t.
+func NewServer(t *target.Target) *Server {
+	if t == nil {
+		t = target.Default
+	}
+	return &Server{target: t, docs: make(map[string]*document)}
+}
+
+// Serve answers the messages read from in on out until the client asks the
+// server to exit or in ends. Exiting without being asked to shut down first
+// is an error.
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+	s.conn = newConn(in, out)
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if rerr, ok := err.(*responseError); ok {
+			if err := s.conn.reply(json.RawMessage("null"), nil, rerr); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return fmt.Errorf("exit before shutdown")
+			}
+			return nil
+		}
+		if err := s.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// handle answers a request, or acts on a notification. It fails when the
+// client cannot be written to.
+func (s *Server) handle(msg *message) error {
+	isRequest := len(msg.ID) > 0
+	if msg.Method == "" {
+		// A response; the server sends no requests.
+		return nil
+	}
+	var result any
+	var err error
+	switch {
+	case msg.Method == "initialize":
+		result, err = s.initialize(msg.Params)
+	case !s.initialized:
+		err = &responseError{Code: codeServerNotInitialized, Message: "the server is not initialized"}
+	case s.shutdown && isRequest:
+		err = &responseError{Code: codeInvalidRequest, Message: "the server is shut down"}
+	default:
+		result, err = s.dispatch(msg.Method, msg.Params)
+	}
+	if !isRequest {
+		// A notification gets no answer, so only failing to publish the
+		// diagnostics it causes ends the session.
+		if _, ok := err.(*responseError); ok {
+			return nil
+		}
+		return err
+	}
+	return s.conn.reply(msg.ID, result, err)
+}
+
+func (s *Server) dispatch(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "initialized":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p didOpenParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		doc := &document{uri: p.TextDocument.URI, path: filePath(p.TextDocument.URI), version: p.TextDocument.Version, text: p.TextDocument.Text}
+		s.docs[doc.uri] = doc
+		return nil, s.update(doc)
+	case "textDocument/didChange":
+		var p didChangeParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		doc, ok := s.docs[p.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		for _, change := range p.ContentChanges {
+			doc.text = applyChange(doc.text, change)
+		}
+		doc.version = p.TextDocument.Version
+		return nil, s.update(doc)
+	case "textDocument/didClose":
+		var p didCloseParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		delete(s.docs, p.TextDocument.URI)
+		return nil, s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []diagnostic{}})
+	case "textDocument/hover":
+		return positionRequest(s, params, (*analysis).hover)
+	case "textDocument/definition":
+		return positionRequest(s, params, (*analysis).definition)
+	case "textDocument/completion":
+		var p textDocumentPositionParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		doc, ok := s.docs[p.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		return doc.analysis.complete(p.Position, s.searchPaths(doc)), nil
+	case "textDocument/documentSymbol":
+		var p documentSymbolParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		doc, ok := s.docs[p.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		return doc.analysis.symbols(), nil
+	case "textDocument/semanticTokens/full":
+		var p semanticTokensParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		doc, ok := s.docs[p.TextDocument.URI]
+		if !ok {
+			return nil, nil
+		}
+		return doc.analysis.semanticTokens(), nil
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %s is not supported", method)}
+}
+
+// positionRequest answers a request about a position in a document with f.
+func positionRequest[T any](s *Server, params json.RawMessage, f func(*analysis, position) T) (any, error) {
+	var p textDocumentPositionParams
+	if err := decode(params, &p); err != nil {
+		return nil, err
+	}
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+	return f(doc.analysis, p.Position), nil
+}
+
+func decode(params json.RawMessage, v any) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) initialize(params json.RawMessage) (any, error) {
+	var p initializeParams
+	if len(params) > 0 {
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+	}
+	s.root = p.RootPath
+	if p.RootURI != "" {
+		s.root = filePath(p.RootURI)
+	}
+	s.initialized = true
+	return initializeResult{
+		Capabilities: serverCapabilities{
+			TextDocumentSync:       textDocumentSyncFull,
+			HoverProvider:          true,
+			DefinitionProvider:     true,
+			DocumentSymbolProvider: true,
+			CompletionProvider:     completionOptions{TriggerCharacters: []string{".", "\"", "/"}},
+			SemanticTokensProvider: semanticTokensOptions{Legend: legend, Full: true},
+		},
+		ServerInfo: serverInfo{Name: "ylang"},
+	}, nil
+}
+
+// update analyzes doc again and publishes its diagnostics.
+func (s *Server) update(doc *document) error {
+	mm := module.NewModuleManager()
+	for _, dir := range s.searchPaths(doc) {
+		mm.AddSearchPath(dir)
+	}
+	doc.analysis = analyze(doc.uri, doc.path, doc.text, mm, s.target)
+	diags := doc.analysis.diagnostics
+	if diags == nil {
+		diags = []diagnostic{}
+	}
+	return s.conn.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: doc.uri, Version: doc.version, Diagnostics: diags})
+}
+
+// searchPaths returns the directories searched for the modules doc imports,
+// besides the default ones.
+func (s *Server) searchPaths(doc *document) []string {
+	var dirs []string
+	if doc.path != "" {
+		dirs = append(dirs, filepath.Dir(doc.path))
+	}
+	if s.root != "" {
+		dirs = append(dirs, s.root, filepath.Join(s.root, "lib"))
+	}
+	return append(dirs, s.SearchPaths...)
+}
+
+// applyChange applies change to text.
+func applyChange(text string, change contentChange) string {
+	if change.Range == nil {
+		return change.Text
+	}
+	from, to := offset(text, change.Range.Start), offset(text, change.Range.End)
+	if to < from {
+		from, to = to, from
+	}
+	return text[:from] + change.Text + text[to:]
+}
+
+// offset returns the byte offset of p in text.
+func offset(text string, p position) int {
+	n := 0
+	for line := 0; line < p.Line; line++ {
+		i := strings.IndexByte(text[n:], '\n')
+		if i < 0 {
+			return len(text)
+		}
+		n += i + 1
+	}
+	units := 0
+	for i, r := range text[n:] {
+		if r == '\n' || units >= p.Character {
+			return n + i
+		}
+		units += utf16Len(r)
+	}
+	return len(text)
+}
+
+// filePath returns the path of the file a file URI names, and "" for other
+// URIs.
+func filePath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// fileURI returns the URI of the file at path.
+func fileURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
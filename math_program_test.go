@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestMathProgram covers the integer, overflow-aware and floating point
+// routines of stdlib/math. The programs are freestanding, so the float
+// functions run their software versions rather than libm.
+func TestMathProgram(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name: "integers",
+			input: `
+			import "stdlib/fmt";
+			import "stdlib/math";
+
+			main() -> {
+				printf("%d %d %d %d\n", math.abs(-5), math.min(3, 4), math.max(3, 4), math.clamp(12, 0, 10));
+				printf("%d %d %d %d\n", math.pow(3, 13), math.pow(-1, -3), math.pow(5, -1), math.pow(2, 64));
+				printf("%d %d %d %d\n", math.isqrt(0), math.isqrt(99), math.isqrt(9223372036854775807), math.isqrt(-4));
+				printf("%d %d %d\n", math.gcd(-12, 18), math.lcm(4, 6), math.gcd(0, 0));
+				printf("%d %d %d\n", math.gcd(math.MIN_INT, 6), math.gcd(math.MIN_INT, 1), math.gcd(0, math.MIN_INT) == math.MIN_INT);
+
+				let r: i64 = 0;
+				printf("%d %d\n", math.checkedAdd(math.MAX_INT, 1, &r), r);
+				printf("%d %d\n", math.checkedMul(3000000000, 3, &r), r);
+				printf("%d %d\n", math.checkedSub(math.MIN_INT, 1, &r), r);
+				let o = false;
+				printf("%d %d\n", math.overflowingMul(4611686018427387904, 4, &o), o);
+				printf("%d %d\n", math.overflowingAdd(math.MAX_INT, 1, &o) == math.MIN_INT, o);
+				printf("%d %d\n", math.overflowingSub(7, 9, &o), o);
+				printf("%d %d %d %d\n", math.saturatingAdd(math.MAX_INT, 5) == math.MAX_INT, math.saturatingSub(math.MIN_INT, 1) == math.MIN_INT, math.saturatingMul(-3, math.MAX_INT) == math.MIN_INT, math.saturatingMul(-3, 4));
+				return 0;
+			}`,
+			expected: []string{
+				"5 3 4 10",
+				"1594323 -1 0 0",
+				"0 9 3037000499 -1",
+				"6 12 0",
+				"2 1 true",
+				"false 0",
+				"true 9000000000",
+				"false 9000000000",
+				"0 true",
+				"true true",
+				"-2 false",
+				"true true true -12",
+			},
+		},
+		{
+			name: "floats",
+			input: `
+			import "stdlib/fmt";
+			import "stdlib/math";
+
+			main() -> {
+				printf("%.6f %.6f %.6f %.6f\n", math.sqrt(2.0), math.fabs(-1.5), math.floor(-2.5), math.ceil(-2.5));
+				printf("%.6f %.6f %.6f\n", math.trunc(-2.7), math.ceil(2.1), math.floor(4503599627370497.0) - 4503599627370496.0);
+				printf("%.10f %.10f %.10f\n", math.exp(1.0), math.exp(-3.5), math.exp(0.0));
+				printf("%.10f %.10f %.10f\n", math.log(10.0), math.log(math.exp(20.0)), math.log(1.0));
+				printf("%.10f %.10f %.10f\n", math.sin(1.0), math.cos(1.0), math.sin(100.0));
+				printf("%.10f %.10f\n", math.cos(math.PI), math.sin(math.PI / 6.0));
+				// Large arguments are reduced with all the bits of 2/pi they need.
+				let big = math.fromBits(0x4415af1d78b58c40); // 1e20
+				printf("%.10f %.10f %.10f\n", math.sin(big), math.cos(big), math.sin(-big));
+				printf("%.10f %.10f\n", math.sin(math.fromBits(0x7fefffffffffffff)), math.cos(1000000.0));
+				printf("%d %d %d %d\n", math.isNaN(math.log(-1.0)), math.isInf(math.log(0.0), -1), math.isInf(math.exp(1000.0), 1), math.exp(-1000.0) == 0.0);
+				printf("%d %d %d\n", math.isNaN(math.sin(math.inf())), math.isInf(math.nan()), math.copysign(3.0, -0.5) == -3.0);
+				printf("%x %.3f\n", math.bits(1.0), math.fromBits(0x4000000000000000));
+				// Subnormals: the smallest f64 is 2^-1074.
+				printf("%.6f\n", math.log(math.fromBits(1)) / math.LN2);
+				return 0;
+			}`,
+			expected: []string{
+				"1.414214 1.500000 -3.000000 -2.000000",
+				"-2.000000 3.000000 1.000000",
+				"2.7182818285 0.0301973834 1.0000000000",
+				"2.3025850930 20.0000000000 0.0000000000",
+				"0.8414709848 0.5403023059 -0.5063656411",
+				"-1.0000000000 0.5000000000",
+				"-0.6452512853 0.7639704044 0.6452512853",
+				"0.0049619548 0.9367521275",
+				"true true true true",
+				"true false true",
+				"3ff0000000000000 2.000",
+				"-1074.000000",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := runLines(t, buildFreestanding(t, tt.input))
+			if strings.Join(lines, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("program output mismatch\nGot:\n%s\nWant:\n%s", strings.Join(lines, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+}
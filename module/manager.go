@@ -5,7 +5,9 @@ import (
 	"compiler/parser"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 )
 
 type ModuleManager struct {
@@ -56,11 +58,57 @@ func (mm *ModuleManager) LoadModule(modulePath string) (*Module, error) {
 	mod := &Module{
 		Name: modulePath,
 		AST:  astProg,
+		Path: filePath,
 	}
 	mm.modules[modulePath] = mod
 	return mod, nil
 }
 
+// AddSearchPath adds dir to the directories searched for modules, after
+// the default ones.
+func (mm *ModuleManager) AddSearchPath(dir string) {
+	mm.searchPaths = append(mm.searchPaths, dir)
+}
+
+// ModulePath returns the path under which file is imported, e.g.
+// "stdlib/fs" for lib/stdlib/fs/fs.y. When several search paths contain the
+// file, the most specific one wins. ok is false when no search path leads to
+// the file.
+func (mm *ModuleManager) ModulePath(file string) (modulePath string, ok bool) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", false
+	}
+	best := -1
+	for _, sp := range mm.searchPaths {
+		dir, err := filepath.Abs(sp)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(dir, abs)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") || filepath.Ext(rel) != ".y" {
+			continue
+		}
+		candidate := filepath.ToSlash(strings.TrimSuffix(rel, ".y"))
+		// A module may be a directory holding a file of the same name.
+		if parent := path.Dir(candidate); parent != "." && path.Base(parent) == path.Base(candidate) {
+			candidate = parent
+		}
+		found, err := mm.findModuleFile(candidate)
+		if err != nil {
+			continue
+		}
+		if foundAbs, err := filepath.Abs(found); err != nil || foundAbs != abs {
+			continue
+		}
+		if len(dir) > best {
+			best = len(dir)
+			modulePath, ok = candidate, true
+		}
+	}
+	return modulePath, ok
+}
+
 // Simplistic approach
 func (mm *ModuleManager) findModuleFile(modulePath string) (string, error) {
 	// If it’s "std/core", maybe we search "std/core.y" or something
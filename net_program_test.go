@@ -0,0 +1,180 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestNetProgram covers stdlib/net: parsing and formatting IPv4 and IPv6
+// addresses, and a TCP server and client, an HTTP exchange and UDP datagrams
+// over loopback in one process.
+func TestNetProgram(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name: "addresses",
+			input: `
+			import "stdlib/fmt";
+			import "stdlib/net";
+			import "stdlib/mem";
+
+			function show(s: string) -> {
+				let a = net.parseIP(s, 80);
+				if ((a as i64) == 0) {
+					printf("%s: invalid\n", s);
+				} else {
+					printf("%s: %s %s\n", s, net.formatIP(a), net.formatAddr(a));
+				}
+			}
+
+			function showAddr(s: string) -> {
+				let a = net.parseAddr(s);
+				if ((a as i64) == 0) {
+					printf("%s: invalid\n", s);
+				} else {
+					printf("%s: %s port %d\n", s, net.formatAddr(a), net.port(a));
+				}
+			}
+
+			main() -> {
+				show("127.0.0.1");
+				show("255.255.255.255");
+				show("256.1.1.1");
+				show("1.2.3");
+				show("01.2.3.4");
+				show("::1");
+				show("::");
+				show("2001:db8::8a2e:370:7334");
+				show("2001:0db8:0000:0000:0000:0000:0000:0001");
+				show("1:0:0:1:0:0:0:1");
+				show("fe80::");
+				show("::ffff:10.0.0.1");
+				show("1::2::3");
+				show("1:2:3:4:5:6:7:8:9");
+				show("12345::");
+				show("1:2:3:4:5:6:7::");
+				showAddr("127.0.0.1:8080");
+				showAddr("[::1]:443");
+				showAddr("::1:443");
+				showAddr("[1.2.3.4]:80");
+				showAddr("1.2.3.4:99999");
+				showAddr("1.2.3.4:");
+				return 0;
+			}`,
+			expected: []string{
+				"127.0.0.1: 127.0.0.1 127.0.0.1:80",
+				"255.255.255.255: 255.255.255.255 255.255.255.255:80",
+				"256.1.1.1: invalid",
+				"1.2.3: invalid",
+				"01.2.3.4: invalid",
+				"::1: ::1 [::1]:80",
+				"::: :: [::]:80",
+				"2001:db8::8a2e:370:7334: 2001:db8::8a2e:370:7334 [2001:db8::8a2e:370:7334]:80",
+				"2001:0db8:0000:0000:0000:0000:0000:0001: 2001:db8::1 [2001:db8::1]:80",
+				"1:0:0:1:0:0:0:1: 1:0:0:1::1 [1:0:0:1::1]:80",
+				"fe80::: fe80:: [fe80::]:80",
+				"::ffff:10.0.0.1: ::ffff:10.0.0.1 [::ffff:10.0.0.1]:80",
+				"1::2::3: invalid",
+				"1:2:3:4:5:6:7:8:9: invalid",
+				"12345::: invalid",
+				"1:2:3:4:5:6:7::: 1:2:3:4:5:6:7:0 [1:2:3:4:5:6:7:0]:80",
+				"127.0.0.1:8080: 127.0.0.1:8080 port 8080",
+				"[::1]:443: [::1]:443 port 443",
+				"::1:443: invalid",
+				"[1.2.3.4]:80: invalid",
+				"1.2.3.4:99999: invalid",
+				"1.2.3.4:: invalid",
+			},
+		},
+		{
+			name: "tcp, http and udp over loopback",
+			input: `
+			import "stdlib/fmt";
+			import "stdlib/net";
+			import "stdlib/mem";
+
+			main() -> {
+				let ln = net.listenTCP(net.parseAddr("127.0.0.1:0"));
+				let addr = net.localAddr(ln);
+				printf("listening %d\n", net.port(addr) > 0);
+				let c = net.dialTCP(addr);
+				let peer = net.newAddr(net.AF_INET, 0);
+				let s = net.accept(ln, peer);
+				printf("accepted from %s\n", net.formatIP(peer));
+
+				let req = "POST /items?id=7 HTTP/1.1\r\nHost: example\r\nContent-Type:  text/plain \r\nContent-Length: 5\r\n\r\nhelloGET /next HTTP/1.1\r\n\r\n";
+				net.sendAll(c, req as *u8, strlen(req));
+
+				let buf = alloc(4096);
+				let filled: i64 = 0;
+				let r = net.newRequest();
+				let used = net.readRequest(s, buf, 4096, &filled, r);
+				printf("%s %s %s headers=%d\n", r.method, r.target, r.version, r.headerCount);
+				printf("type=[%s] host=[%s] missing=[%s]\n", net.header(r, "content-type"), net.header(r, "HOST"), net.header(r, "Accept"));
+				buf[0] = 0;
+				printf("body %d: %s\n", r.bodyLen, r.body as string);
+				printf("used %d of %d\n", used, filled);
+				let second = net.parseRequest(buf + used, filled - used, r);
+				printf("second %d %s %s\n", second, r.method, r.target);
+				net.respond(s, 200, "text/plain", "hi there");
+				net.close(s);
+
+				let resp = alloc(1024);
+				let n = 0;
+				let got = net.recv(c, resp, 1023);
+				while (got > 0) {
+					n = n + got;
+					got = net.recv(c, resp + n, 1023 - n);
+				}
+				resp[n] = 0;
+				printf("%s\n", resp as string);
+				net.close(c);
+
+				// Malformed and partial requests.
+				printf("%d %d %d %d\n", net.parseRequest("GET / HTTP/1.1\r\nHost: x\r\n" as *u8, 25, r),
+					net.parseRequest("GET /\r\n\r\n" as *u8, 9, r),
+					net.parseRequest("GET / HTTP/1.1\r\nBad Header\r\n\r\n" as *u8, 32, r),
+					net.parseRequest("POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n" as *u8, 48, r));
+
+				// UDP
+				let u1 = net.listenUDP(net.parseAddr("127.0.0.1:0"));
+				let u2 = net.listenUDP(net.parseAddr("127.0.0.1:0"));
+				net.sendTo(u1, "ping" as *u8, 4, net.localAddr(u2));
+				let from = net.newAddr(net.AF_INET, 0);
+				let dg = alloc(64);
+				let m = net.recvFrom(u2, dg, 63, from);
+				printf("udp %d %s from matches %d\n", m, dg as string, net.port(from) == net.port(net.localAddr(u1)));
+
+				return 0;
+			}`,
+			expected: []string{
+				"listening true",
+				"accepted from 127.0.0.1",
+				"POST /items?id=7 HTTP/1.1 headers=3",
+				"type=[text/plain] host=[example] missing=[]",
+				"body 5: hello",
+				"used 96 of 118",
+				"second 22 GET /next",
+				"HTTP/1.1 200 OK\r",
+				"Content-Type: text/plain\r",
+				"Content-Length: 8\r",
+				"\r",
+				"hi there",
+				"0 -400 -400 -501",
+				"udp 4 ping from matches true",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := runLines(t, buildFreestanding(t, tt.input))
+			if strings.Join(lines, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("program output mismatch\nGot:\n%s\nWant:\n%s", strings.Join(lines, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+}
@@ -16,6 +16,10 @@ func (p *Parser) parseAssemblyStatement() ast.ExpressionNode {
 
 	expr := &ast.AssemblyExpression{Token: p.currentToken}
 
+	if !p.peekTokenIs(TokenTypeLeftParenthesis) {
+		return p.parseAssemblyBlock(expr)
+	}
+
 	if !p.expectPeek(TokenTypeLeftParenthesis) {
 		p.errors = append(p.errors, fmt.Sprintf("expected '(' after 'asm', got %s", p.peekToken.Type))
 		return nil
@@ -40,3 +44,113 @@ func (p *Parser) parseAssemblyStatement() ast.ExpressionNode {
 
 	return expr
 }
+
+// parseAssemblyBlock parses the block form of inline assembly:
+//
+//	asm [volatile] [intel|att] {
+//	    "line" "line"...
+//	    : "=r"(out), ...   // outputs
+//	    : "r"(in), ...     // inputs
+//	    : "memory", ...    // clobbers
+//	}
+//
+// It leaves the current token on the closing '}'.
+func (p *Parser) parseAssemblyBlock(expr *ast.AssemblyExpression) ast.ExpressionNode {
+	expr.Block = true
+	for p.peekTokenIs(TokenTypeIdentifier) {
+		p.nextToken()
+		switch p.currentToken.Literal {
+		case "volatile":
+			expr.Volatile = true
+		case "intel", "att":
+			if expr.Dialect != "" {
+				p.errors = append(p.errors, fmt.Sprintf("asm block at line %d names more than one dialect", expr.Token.Line+1))
+				return nil
+			}
+			expr.Dialect = p.currentToken.Literal
+		default:
+			p.errors = append(p.errors, fmt.Sprintf("unexpected '%s' after 'asm', expected volatile, intel, att or '{'", p.currentToken.Literal))
+			return nil
+		}
+	}
+
+	if !p.expectPeek(TokenTypeLeftBrace) {
+		return nil
+	}
+	if !p.expectPeek(TokenTypeString) {
+		p.errors = append(p.errors, "asm block must start with the assembly text as a string literal")
+		return nil
+	}
+	code := &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal}
+	for p.peekTokenIs(TokenTypeString) {
+		p.nextToken()
+		code.Value += "\n" + p.currentToken.Literal
+	}
+	expr.Code = code
+
+	for section := 0; section < 3 && p.peekTokenIs(TokenTypeColon); section++ {
+		p.nextToken() // Consume ':'
+		if section == 2 {
+			expr.Clobbers = p.parseAsmClobbers()
+			continue
+		}
+		operands, ok := p.parseAsmOperands()
+		if !ok {
+			return nil
+		}
+		if section == 0 {
+			expr.Outputs = operands
+		} else {
+			expr.Inputs = operands
+		}
+	}
+
+	if !p.expectPeek(TokenTypeRightBrace) {
+		return nil
+	}
+	return expr
+}
+
+// parseAsmOperands parses a possibly empty, comma-separated list of
+// "constraint"(expression) operands.
+func (p *Parser) parseAsmOperands() ([]*ast.AsmOperand, bool) {
+	var operands []*ast.AsmOperand
+	if !p.peekTokenIs(TokenTypeString) {
+		return operands, true
+	}
+	for {
+		p.nextToken()
+		op := &ast.AsmOperand{Constraint: &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal}}
+		if !p.expectPeek(TokenTypeLeftParenthesis) {
+			return nil, false
+		}
+		p.nextToken()
+		op.Value = p.parseExpression(LOWEST)
+		if op.Value == nil || !p.expectPeek(TokenTypeRightParenthesis) {
+			return nil, false
+		}
+		operands = append(operands, op)
+		if !p.peekTokenIs(TokenTypeComma) {
+			return operands, true
+		}
+		p.nextToken() // Consume ','
+		if !p.peekTokenIs(TokenTypeString) {
+			p.errors = append(p.errors, fmt.Sprintf("expected asm operand constraint after ',', got %s", p.peekToken.Type))
+			return nil, false
+		}
+	}
+}
+
+// parseAsmClobbers parses the comma-separated clobber strings.
+func (p *Parser) parseAsmClobbers() []*ast.StringLiteral {
+	var clobbers []*ast.StringLiteral
+	for p.peekTokenIs(TokenTypeString) {
+		p.nextToken()
+		clobbers = append(clobbers, &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal})
+		if !p.peekTokenIs(TokenTypeComma) {
+			break
+		}
+		p.nextToken() // Consume ','
+	}
+	return clobbers
+}
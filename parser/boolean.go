@@ -0,0 +1,10 @@
+package parser
+
+import (
+	"compiler/ast"
+	. "compiler/lexer"
+)
+
+func (p *Parser) parseBooleanLiteral() ast.ExpressionNode {
+	return &ast.BooleanLiteral{Token: p.currentToken, Value: p.currentTokenIs(TokenTypeTrue)}
+}
@@ -6,9 +6,42 @@ import (
 	"fmt"
 )
 
+// isLayoutQualifier reports whether tok is one of the contextual words that
+// may precede 'type' to choose a struct layout.
+func isLayoutQualifier(tok LangToken) bool {
+	return tok.Type == TokenTypeIdentifier && (tok.Literal == "packed" || tok.Literal == "extern")
+}
+
 func (p *Parser) parseClassDeclaration() *ast.ClassDeclaration {
 	classDecl := &ast.ClassDeclaration{Token: p.currentToken}
 
+	if isLayoutQualifier(p.currentToken) && p.peekTokenIs(TokenTypeType) {
+		if p.currentToken.Literal == "packed" {
+			classDecl.Layout = ast.StructLayoutPacked
+		} else {
+			classDecl.Layout = ast.StructLayoutExtern
+		}
+		p.nextToken()
+	}
+
+	if p.currentTokenIs(TokenTypeType) {
+		// type Name { let field: T; ... }
+		if !p.expectPeek(TokenTypeIdentifier) {
+			return nil
+		}
+		classDecl.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+		if !p.expectPeek(TokenTypeLeftBrace) {
+			return nil
+		}
+		classDecl.Members = p.parseTypeMembers()
+		if !p.currentTokenIs(TokenTypeRightBrace) {
+			p.errors = append(p.errors, fmt.Sprintf("expected '}' to close type '%s', got %s", classDecl.Name.Value, p.currentToken.Type))
+			return nil
+		}
+		p.nextToken() // Consume '}'
+		return classDecl
+	}
+
 	if p.currentTokenIs(TokenTypeIdentifier) {
 		classDecl.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
 		classDecl.LambdaStyle = true
@@ -18,16 +51,6 @@ func (p *Parser) parseClassDeclaration() *ast.ClassDeclaration {
 			fmt.Println("Expected '->' after class name")
 			return nil
 		}
-	} else if p.currentTokenIs(TokenTypeType) {
-		p.nextToken()
-
-		if !p.expectPeek(TokenTypeIdentifier) {
-			fmt.Println("Expected identifier after 'type'")
-			return nil
-		}
-
-		classDecl.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-		classDecl.LambdaStyle = false
 	} else {
 		return nil
 	}
@@ -47,6 +70,33 @@ func (p *Parser) parseClassDeclaration() *ast.ClassDeclaration {
 	return classDecl
 }
 
+// parseTypeMembers parses the fields and methods of a type body. It starts on
+// the opening '{' and stops on the closing '}'.
+func (p *Parser) parseTypeMembers() []*ast.ClassMember {
+	var members []*ast.ClassMember
+	p.nextToken()
+	for !p.currentTokenIs(TokenTypeRightBrace) && !p.currentTokenIs(TokenTypeEOF) {
+		if p.currentTokenIs(TokenTypeLet) {
+			decl, ok := p.parseVariableDeclaration().(*ast.VariableDeclaration)
+			if !ok || decl == nil {
+				p.errors = append(p.errors, fmt.Sprintf("malformed field declaration near line %d", p.currentToken.Line+1))
+				return members
+			}
+			members = append(members, &ast.ClassMember{VariableDeclaration: decl})
+			p.nextToken()
+			continue
+		}
+		// Methods end past the closing '}' of their body.
+		method := p.parseMethodDeclaration()
+		if method == nil {
+			p.errors = append(p.errors, fmt.Sprintf("expected a field or method in type body near line %d, got '%s'", p.currentToken.Line+1, p.currentToken.Literal))
+			return members
+		}
+		members = append(members, &ast.ClassMember{MethodDeclaration: method})
+	}
+	return members
+}
+
 func (p *Parser) parseClassMembers() []*ast.ClassMember {
 	var members []*ast.ClassMember
 
@@ -96,8 +146,8 @@ func (p *Parser) parseMethodDeclaration() *ast.MethodDeclaration {
 		fmt.Println("Expected '(' after method name")
 		return nil
 	}
-	method.Parameters = p.parseParameterList()
-	if !p.expectPeek(TokenTypeRightParenthesis) {
+	method.Parameters = p.parseFunctionParameters()
+	if method.Parameters == nil {
 		fmt.Println("Expected ')' after method parameters")
 		return nil
 	}
@@ -109,6 +159,9 @@ func (p *Parser) parseMethodDeclaration() *ast.MethodDeclaration {
 	}
 
 	// Parse method body
+	if !p.expectPeek(TokenTypeLeftBrace) {
+		return nil
+	}
 	method.Body = p.parseBlockStatement()
 
 	return method
@@ -123,6 +176,15 @@ func (p *Parser) parseVariableDeclaration() ast.ExpressionNode {
 
 	varDecl.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
 
+	if p.peekTokenIs(TokenTypeColon) {
+		p.nextToken() // ':'
+		p.nextToken() // start of the type
+		varDecl.Type = p.parseTypeName()
+		if varDecl.Type == nil {
+			return nil
+		}
+	}
+
 	if p.peekTokenIs(TokenTypeAssignment) {
 		p.nextToken()
 
@@ -137,59 +199,6 @@ func (p *Parser) parseVariableDeclaration() ast.ExpressionNode {
 	return varDecl
 }
 
-func (p *Parser) parseParameterList() []*ast.Parameter {
-	var parameters []*ast.Parameter
-
-	if p.peekTokenIs(TokenTypeRightParenthesis) {
-		return parameters
-	}
-
-	p.nextToken()
-
-	param := &ast.Parameter{Token: p.currentToken}
-	param.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-
-	if p.peekTokenIs(TokenTypeLeftParenthesis) {
-		p.nextToken()
-		if !p.expectPeek(TokenTypeIdentifier) {
-			fmt.Println("Expected type after '('")
-			return nil
-		}
-		param.Type = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-		if !p.expectPeek(TokenTypeRightParenthesis) {
-			fmt.Println("Expected ')' after type")
-			return nil
-		}
-	}
-
-	parameters = append(parameters, param)
-
-	for p.peekTokenIs(TokenTypeComma) {
-		p.nextToken()
-		p.nextToken()
-
-		param := &ast.Parameter{Token: p.currentToken}
-		param.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-
-		if p.peekTokenIs(TokenTypeLeftParenthesis) {
-			p.nextToken()
-			if !p.expectPeek(TokenTypeIdentifier) {
-				fmt.Println("Expected type after '('")
-				return nil
-			}
-			param.Type = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-			if !p.expectPeek(TokenTypeRightParenthesis) {
-				fmt.Println("Expected ')' after type")
-				return nil
-			}
-		}
-
-		parameters = append(parameters, param)
-	}
-
-	return parameters
-}
-
 func (p *Parser) parseOnConstructStatement() *ast.OnConstructStatement {
 	stmt := &ast.OnConstructStatement{Token: p.currentToken}
 	if !p.expectPeek(TokenTypeIdentifier) {
@@ -86,6 +86,44 @@ func (p *Parser) parseWhileStatement() *ast.WhileStatement {
 	return ws
 }
 
+// parseForInStatement parses 'for item in iterable { body }'. The loop head
+// may also be parenthesized: 'for (item in iterable) { body }'.
+func (p *Parser) parseForInStatement() *ast.ForInStatement {
+	fs := &ast.ForInStatement{Token: p.currentToken}
+
+	parenthesized := p.peekTokenIs(TokenTypeLeftParenthesis)
+	if parenthesized {
+		p.nextToken()
+	}
+	if !p.expectPeek(TokenTypeIdentifier) {
+		return nil
+	}
+	fs.Variable = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	if !p.expectPeek(TokenTypeIn) {
+		return nil
+	}
+
+	p.nextToken()
+	fs.Iterable = p.parseExpression(LOWEST)
+	if fs.Iterable == nil {
+		return nil
+	}
+
+	if parenthesized && !p.expectPeek(TokenTypeRightParenthesis) {
+		return nil
+	}
+	if !p.expectPeek(TokenTypeLeftBrace) {
+		return nil
+	}
+
+	bodyNode := p.parseBlockStatement()
+	if bodyNode == nil {
+		return nil
+	}
+	fs.Body = bodyNode
+	return fs
+}
+
 func (p *Parser) parseTraditionalTernaryExpression(condition ast.ExpressionNode) ast.ExpressionNode {
 	ternaryExp := &ast.TraditionalTernaryExpression{
 		Token:     p.currentToken, // '?' token (already current when called as infix fn)
@@ -57,10 +57,14 @@ func (p *Parser) parseDataStructure() *ast.DataStructure {
 		fmt.Println("Expected '}' after data structure fields")
 		return nil
 	}
+	p.nextToken() // Consume '}'
 
 	return dataStruct
 }
 
+// parseFieldList parses the fields of a data declaration, `let name` or
+// `let name: Type`, separated by commas or semicolons. It leaves the cursor
+// on the last token before the closing '}'.
 func (p *Parser) parseFieldList() []*ast.Field {
 	var fields []*ast.Field
 
@@ -69,26 +73,44 @@ func (p *Parser) parseFieldList() []*ast.Field {
 		return nil
 	}
 
+	for {
+		field := p.parseField()
+		if field == nil {
+			return nil
+		}
+		fields = append(fields, field)
+
+		if !p.peekTokenIs(TokenTypeComma) && !p.peekTokenIs(TokenTypeSemicolon) {
+			return fields
+		}
+		p.nextToken()
+		if p.peekTokenIs(TokenTypeRightBrace) {
+			// A trailing separator
+			return fields
+		}
+		if !p.expectPeek(TokenTypeLet) {
+			fmt.Println("Expected 'let' after ','")
+			return nil
+		}
+	}
+}
+
+// parseField parses one field, starting on its 'let'.
+func (p *Parser) parseField() *ast.Field {
 	field := &ast.Field{Token: p.currentToken}
 	if !p.expectPeek(TokenTypeIdentifier) {
 		fmt.Println("Expected field name after 'let'")
 		return nil
 	}
 	field.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-	fields = append(fields, field)
-
-	for p.peekTokenIs(TokenTypeComma) {
-		p.nextToken()
-		p.nextToken()
 
-		field := &ast.Field{Token: p.currentToken}
-		if !p.expectPeek(TokenTypeIdentifier) {
-			fmt.Println("Expected field name after ','")
+	if p.peekTokenIs(TokenTypeColon) {
+		p.nextToken() // ':'
+		p.nextToken() // start of the type
+		field.Type = p.parseTypeName()
+		if field.Type == nil {
 			return nil
 		}
-		field.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-		fields = append(fields, field)
 	}
-
-	return fields
+	return field
 }
@@ -48,6 +48,13 @@ func (p *Parser) parsePrefixExpression() ast.ExpressionNode {
 	if expr.Right == nil {
 		return nil
 	}
+	// Assignment binds tighter than prefix operators, so *p = v arrives as
+	// *(p = v); rebuild it as (*p) = v.
+	if assign, ok := expr.Right.(*ast.AssignmentExpression); ok && expr.Operator == "*" {
+		expr.Right = assign.Left
+		assign.Left = expr
+		return assign
+	}
 	return expr
 }
 
@@ -80,7 +87,7 @@ func (p *Parser) parseParenthesisExpression() ast.ExpressionNode {
 		p.nextToken()                    // Move past '->' to body start
 
 		lambda := &ast.LambdaExpression{Token: lambdaArrowToken}
-		lambda.Parameters = []*ast.Identifier{} // Empty params
+		lambda.Parameters = []*ast.Parameter{} // Empty params
 
 		// Parse Body - currentToken is already at the start of the body
 		lambda.Body = p.parseLambdaBody()
@@ -91,15 +98,11 @@ func (p *Parser) parseParenthesisExpression() ast.ExpressionNode {
 	}
 
 	// Check for non-empty parameters lambda: (ident, ...) ->
-	isLambda, params := p.probeIsLambdaParameters()
-	if isLambda {
-		p.nextToken()                                          // Consume '('
+	if p.probeIsLambdaParameters() {
 		lambda := &ast.LambdaExpression{Token: p.currentToken} // Use '(' as token for now
-		lambda.Parameters = params
-
-		// Consume tokens up to and including ')'
-		for !p.currentTokenIs(TokenTypeRightParenthesis) && !p.currentTokenIs(TokenTypeEOF) {
-			p.nextToken()
+		lambda.Parameters = p.parseFunctionParameters()
+		if lambda.Parameters == nil {
+			return nil
 		}
 		if !p.currentTokenIs(TokenTypeRightParenthesis) {
 			p.errors = append(p.errors, fmt.Sprintf("Expected ')' after lambda parameters starting line %d, got %s", startToken.Line+1, p.currentToken.Type))
@@ -143,55 +146,51 @@ func (p *Parser) parseParenthesisExpression() ast.ExpressionNode {
 	return expr
 }
 
-func (p *Parser) probeIsLambdaParameters() (bool, []*ast.Identifier) {
+// probeIsLambdaParameters looks ahead from the current '(' and reports whether
+// the group is a lambda parameter list: each comma-separated entry starts with
+// an identifier, optionally followed by a ': Type' annotation or '= default',
+// and the matching ')' is followed by '->'. Nothing is consumed.
+func (p *Parser) probeIsLambdaParameters() bool {
 	if !(p.currentTokenIs(TokenTypeLeftParenthesis)) {
-		return false, nil // Should be called when current is '('
+		return false // Should be called when current is '('
 	}
 
 	// Handle empty params () -> checked by caller already
 	if p.peekTokenIs(TokenTypeRightParenthesis) && p.peekToken2Is(TokenTypeLambdaArrow) {
-		return false, nil // Handled by caller
+		return false // Handled by caller
 	}
 
-	// Handle single param (ident) ->
-	if p.peekTokenIs(TokenTypeIdentifier) && p.peekToken2Is(TokenTypeRightParenthesis) && p.peekToken3Is(TokenTypeLambdaArrow) {
-		params := []*ast.Identifier{{Token: p.peekToken, Value: p.peekToken.Literal}}
-		return true, params
-	}
-
-	// Handle multiple params (ident, ident, ...) ) ->
-	if !p.peekTokenIs(TokenTypeIdentifier) {
-		return false, nil // First item after ( must be identifier for non-empty list
-	}
-
-	var params []*ast.Identifier
-	params = append(params, &ast.Identifier{Token: p.peekToken, Value: p.peekToken.Literal})
-	idx := 2 // Start peeking at index 2 (potential comma or ')')
-
+	idx := 1
+	depth := 0
+	segmentStart := true
 	for {
 		pk := p.peekTokenAtIndex(idx)
-		if pk.Type == TokenTypeComma {
-			idx++ // Move past comma
-			pkNext := p.peekTokenAtIndex(idx)
-			if pkNext.Type == TokenTypeIdentifier {
-				params = append(params, &ast.Identifier{Token: pkNext, Value: pkNext.Literal})
-				idx++    // Move past identifier
-				continue // Look for next comma or ')'
-			} else {
-				return false, nil // Expected identifier after comma
+		switch {
+		case pk.Type == TokenTypeEOF:
+			return false
+		case segmentStart:
+			// Each parameter must start with an identifier followed by ',', ')',
+			// ':' or '='.
+			if pk.Type != TokenTypeIdentifier {
+				return false
 			}
-		} else if pk.Type == TokenTypeRightParenthesis {
-			// Found closing parenthesis. Now check if the *next* token is '->'
-			pkArrow := p.peekTokenAtIndex(idx + 1)
-			if pkArrow.Type == TokenTypeLambdaArrow {
-				return true, params // Looks like a lambda!
-			} else {
-				return false, nil // Found ')' but not followed by '->'
+			switch p.peekTokenAtIndex(idx + 1).Type {
+			case TokenTypeComma, TokenTypeRightParenthesis, TokenTypeColon, TokenTypeAssignment:
+			default:
+				return false
 			}
-		} else {
-			// Unexpected token in parameter list probe
-			return false, nil
+			segmentStart = false
+		case pk.Type == TokenTypeLeftParenthesis || pk.Type == TokenTypeLeftBracket || pk.Type == TokenTypeLeftBrace:
+			depth++
+		case pk.Type == TokenTypeRightParenthesis && depth == 0:
+			// Found the closing parenthesis. It is a lambda only if '->' follows.
+			return p.peekTokenAtIndex(idx+1).Type == TokenTypeLambdaArrow
+		case pk.Type == TokenTypeRightParenthesis || pk.Type == TokenTypeRightBracket || pk.Type == TokenTypeRightBrace:
+			depth--
+		case pk.Type == TokenTypeComma && depth == 0:
+			segmentStart = true
 		}
+		idx++
 	}
 }
 
@@ -296,3 +295,16 @@ func (p *Parser) noPrefixParseFnError(t TokenType) {
 		p.errors = append(p.errors, msg)
 	}
 }
+
+// parseCastExpression parses `value as Type`; the type may be any type name,
+// e.g. `buf as *u16`.
+func (p *Parser) parseCastExpression(left ast.ExpressionNode) ast.ExpressionNode {
+	expr := &ast.CastExpression{Token: p.currentToken, Value: left}
+	p.nextToken()
+	expr.Type = p.parseTypeName()
+	if expr.Type == nil {
+		p.errors = append(p.errors, fmt.Sprintf("expected a type after 'as' near line %d, got %s", expr.Token.Line+1, p.currentToken.Type))
+		return nil
+	}
+	return expr
+}
@@ -4,12 +4,16 @@ import (
 	"compiler/ast"
 	. "compiler/lexer"
 	"fmt"
+	"strings"
 )
 
 func (p *Parser) isFunctionDefinition() bool {
 	if p.currentTokenIs(TokenTypeFunction) && p.peekTokenIs(TokenTypeIdentifier) && p.peekTokenAtIndex(1).Type == TokenTypeLeftParenthesis {
 		return true
 	}
+	if p.currentTokenIs(TokenTypeFunction) && p.peekTokenIs(TokenTypeMultiply) && p.peekToken2Is(TokenTypeIdentifier) {
+		return true
+	}
 	if p.currentTokenIs(TokenTypeIdentifier) && p.peekTokenIs(TokenTypeLeftParenthesis) {
 		return true
 	}
@@ -28,6 +32,11 @@ func (p *Parser) parseFunctionDefinition() *ast.FunctionDefinition {
 	if p.currentTokenIs(TokenTypeFunction) {
 		fn.Token = p.currentToken
 		p.nextToken()
+		// function* declares a generator
+		if p.currentTokenIs(TokenTypeMultiply) {
+			fn.Generator = true
+			p.nextToken()
+		}
 	}
 
 	if p.currentTokenIs(TokenTypeIdentifier) {
@@ -73,12 +82,11 @@ func (p *Parser) parseFunctionDefinition() *ast.FunctionDefinition {
 		p.nextToken() // Consume ')'
 		p.nextToken() // Consume ':'
 
-		if !p.currentTokenIs(TokenTypeIdentifier) {
-			p.errors = append(p.errors, fmt.Sprintf("Expected return type identifier after ':', got %s at line %d", p.currentToken.Type, p.currentToken.Line))
+		fn.ReturnType = p.parseTypeName()
+		if fn.ReturnType == nil {
 			p.advanceToRecoveryPoint()
 			return nil
 		}
-		fn.ReturnType = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
 		p.nextToken()
 	} else {
 		p.nextToken() // Consume ')'
@@ -152,8 +160,8 @@ func (p *Parser) parseAnonymousFunctionExpression() ast.ExpressionNode {
 	return fn
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
-	identifiers := []*ast.Identifier{}
+func (p *Parser) parseFunctionParameters() []*ast.Parameter {
+	parameters := []*ast.Parameter{}
 
 	if !p.currentTokenIs(TokenTypeLeftParenthesis) {
 		p.errors = append(p.errors, fmt.Sprintf("Internal Error: parseFunctionParameters called without '(' token at line %d", p.currentToken.Line))
@@ -162,13 +170,13 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 
 	if p.peekTokenIs(TokenTypeRightParenthesis) {
 		p.nextToken()
-		return identifiers
+		return parameters
 	}
 
 	p.nextToken()
 
 	if p.currentTokenIs(TokenTypeRightParenthesis) {
-		return identifiers
+		return parameters
 	}
 
 	if !p.currentTokenIs(TokenTypeIdentifier) {
@@ -176,9 +184,12 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 		p.advanceToRecoveryPoint()
 		return nil
 	}
-	ident := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-	identifiers = append(identifiers, ident)
-	p.nextToken()
+	param := p.parseParameter()
+	if param == nil {
+		p.advanceToRecoveryPoint()
+		return nil
+	}
+	parameters = append(parameters, param)
 
 	for p.currentTokenIs(TokenTypeComma) {
 		p.nextToken()
@@ -187,9 +198,12 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 			p.advanceToRecoveryPoint()
 			return nil
 		}
-		ident := &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
-		identifiers = append(identifiers, ident)
-		p.nextToken()
+		param := p.parseParameter()
+		if param == nil {
+			p.advanceToRecoveryPoint()
+			return nil
+		}
+		parameters = append(parameters, param)
 	}
 
 	if !p.currentTokenIs(TokenTypeRightParenthesis) {
@@ -198,5 +212,164 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 		return nil
 	}
 
-	return identifiers
+	for i, param := range parameters {
+		if param.IsVariadic() && (i != len(parameters)-1 || param.Default != nil) {
+			p.errors = append(p.errors, fmt.Sprintf("Variadic parameter '%s' must be the last parameter and cannot have a default at line %d", param.Name.Value, param.Token.Line+1))
+			return nil
+		}
+		if param.Default == nil && !param.IsVariadic() && i > 0 && parameters[i-1].Default != nil {
+			p.errors = append(p.errors, fmt.Sprintf("Parameter '%s' without a default value follows a parameter with a default at line %d", param.Name.Value, param.Token.Line+1))
+			return nil
+		}
+	}
+
+	return parameters
+}
+
+// parseParameter parses `name`, `name: Type`, the legacy `name(Type)` form and
+// an optional `= default` suffix. It starts on the identifier and leaves the
+// cursor on the token following the parameter (',' or ')').
+func (p *Parser) parseParameter() *ast.Parameter {
+	param := &ast.Parameter{Token: p.currentToken}
+	param.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+
+	if p.peekTokenIs(TokenTypeColon) {
+		p.nextToken() // ':'
+		p.nextToken() // start of the type
+		param.Type = p.parseTypeName()
+		if param.Type == nil {
+			return nil
+		}
+	} else if p.peekTokenIs(TokenTypeLeftParenthesis) {
+		p.nextToken() // '('
+		p.nextToken() // start of the type
+		param.Type = p.parseTypeName()
+		if param.Type == nil {
+			return nil
+		}
+		if !p.expectPeek(TokenTypeRightParenthesis) {
+			return nil
+		}
+	}
+
+	if p.peekTokenIs(TokenTypeAssignment) {
+		p.nextToken() // '='
+		p.nextToken() // start of the default value
+		param.Default = p.parseExpression(LOWEST)
+		if param.Default == nil {
+			p.errors = append(p.errors, fmt.Sprintf("Failed to parse default value for parameter '%s' at line %d", param.Name.Value, param.Token.Line+1))
+			return nil
+		}
+	}
+
+	p.nextToken()
+	return param
+}
+
+// parseTypeName parses a type annotation starting at the current token and
+// returns it as an identifier holding the canonical spelling: "int", "*u8",
+// "[]string", "...any", "seq<int>" or "(int,string)->int" for function types. The cursor is left on
+// the last token of the type.
+func (p *Parser) parseTypeName() *ast.Identifier {
+	start := p.currentToken
+
+	switch p.currentToken.Type {
+	case TokenTypeIdentifier:
+		// A sequence produced by a generator: seq<T>
+		if start.Literal == "seq" && p.peekTokenIs(TokenTypeLessThan) {
+			p.nextToken()
+			p.nextToken()
+			inner := p.parseTypeName()
+			if inner == nil {
+				return nil
+			}
+			// The lexer reads the closers of seq<seq<T>> as a shift
+			// operator; take one '>' off it and leave the rest for the
+			// enclosing seq.
+			if p.peekTokenIs(TokenTypeShiftRight) || p.peekTokenIs(TokenTypeShiftRightLogic) {
+				rest := TokenTypeGreaterThan
+				if p.peekTokenIs(TokenTypeShiftRightLogic) {
+					rest = TokenTypeShiftRight
+				}
+				p.peekToken.Type = rest
+				p.peekToken.Literal = p.peekToken.Literal[1:]
+				p.peekToken.Pos++
+				p.peekToken.Length--
+				return &ast.Identifier{Token: start, Value: "seq<" + inner.Value + ">"}
+			}
+			if !p.expectPeek(TokenTypeGreaterThan) {
+				return nil
+			}
+			return &ast.Identifier{Token: start, Value: "seq<" + inner.Value + ">"}
+		}
+		// A type declared by an imported module, e.g. fs.Stat
+		if p.peekTokenIs(TokenTypeDot) && p.peekToken2Is(TokenTypeIdentifier) {
+			p.nextToken()
+			p.nextToken()
+			return &ast.Identifier{Token: start, Value: start.Literal + "." + p.currentToken.Literal}
+		}
+		return &ast.Identifier{Token: start, Value: start.Literal}
+
+	case TokenTypeMultiply:
+		p.nextToken()
+		inner := p.parseTypeName()
+		if inner == nil {
+			return nil
+		}
+		return &ast.Identifier{Token: start, Value: "*" + inner.Value}
+
+	case TokenTypeEllipsis:
+		// Variadic parameter: ...T collects the remaining arguments into a []T
+		p.nextToken()
+		inner := p.parseTypeName()
+		if inner == nil {
+			return nil
+		}
+		return &ast.Identifier{Token: start, Value: "..." + inner.Value}
+
+	case TokenTypeLeftBracket:
+		if !p.expectPeek(TokenTypeRightBracket) {
+			return nil
+		}
+		p.nextToken()
+		inner := p.parseTypeName()
+		if inner == nil {
+			return nil
+		}
+		return &ast.Identifier{Token: start, Value: "[]" + inner.Value}
+
+	case TokenTypeLeftParenthesis:
+		// Function type: (T1, T2) -> R
+		var paramTypes []string
+		if !p.peekTokenIs(TokenTypeRightParenthesis) {
+			p.nextToken()
+			for {
+				t := p.parseTypeName()
+				if t == nil {
+					return nil
+				}
+				paramTypes = append(paramTypes, t.Value)
+				if !p.peekTokenIs(TokenTypeComma) {
+					break
+				}
+				p.nextToken() // ','
+				p.nextToken() // next type
+			}
+		}
+		if !p.expectPeek(TokenTypeRightParenthesis) {
+			return nil
+		}
+		if !p.expectPeek(TokenTypeLambdaArrow) {
+			return nil
+		}
+		p.nextToken()
+		ret := p.parseTypeName()
+		if ret == nil {
+			return nil
+		}
+		return &ast.Identifier{Token: start, Value: "(" + strings.Join(paramTypes, ",") + ")->" + ret.Value}
+	}
+
+	p.errors = append(p.errors, fmt.Sprintf("Expected a type name, got %s ('%s') at line %d", p.currentToken.Type, p.currentToken.Literal, p.currentToken.Line+1))
+	return nil
 }
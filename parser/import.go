@@ -31,6 +31,7 @@ func (p *Parser) advanceToRecoveryPoint() {
 		TokenTypeEOF:        true,
 		// Potentially add keywords that start new top-level/block-level items
 		TokenTypeLet:      true,
+		TokenTypeConst:    true,
 		TokenTypeIf:       true,
 		TokenTypeReturn:   true,
 		TokenTypeFunction: true,
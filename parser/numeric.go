@@ -2,13 +2,26 @@ package parser
 
 import (
 	"compiler/ast"
+	"fmt"
 	"strconv"
+	"strings"
 )
 
 func (p *Parser) parseNumberLiteral() ast.ExpressionNode {
 	lit := &ast.NumberLiteral{Token: p.currentToken}
 
-	value, err := strconv.ParseFloat(p.currentToken.Literal, 64)
+	literal := p.currentToken.Literal
+	if strings.HasPrefix(literal, "0x") || strings.HasPrefix(literal, "0X") {
+		value, err := strconv.ParseUint(literal[2:], 16, 64)
+		if err != nil {
+			p.errors = append(p.errors, fmt.Sprintf("Invalid hexadecimal literal '%s' at line %d", literal, p.currentToken.Line))
+			return nil
+		}
+		lit.Value = float64(value)
+		return lit
+	}
+
+	value, err := strconv.ParseFloat(literal, 64)
 	if err != nil {
 		// Handle error; could log or set an error on the parser
 		return nil
@@ -9,11 +9,14 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
-	SUM         // +
-	PRODUCT     // *
-	PREFIX      // -X or !X
+	SUM         // +, | or ^
+	PRODUCT     // *, &, << or >>
+	CAST        // X as T
+	PREFIX      // -X, !X, ~X, &X or *X
 	CALL        // myFunction(X)
 	INDEX       // array[index]
 	ASSIGN      // =
@@ -21,17 +24,30 @@ const (
 )
 
 var precedences = map[TokenType]int{
-	TokenTypeAssignment:      ASSIGN,
-	TokenTypeEqual:           EQUALS,
-	TokenTypeLessThan:        LESSGREATER,
-	TokenTypeGreaterThan:     LESSGREATER,
-	TokenTypePlus:            SUM,
-	TokenTypeMinus:           SUM,
-	TokenTypeMultiply:        PRODUCT,
-	TokenTypeDivide:          PRODUCT,
-	TokenTypeLeftParenthesis: CALL,
-	TokenTypeLeftBracket:     INDEX,
-	TokenTypeDot:             CALL,
+	TokenTypeAssignment:       ASSIGN,
+	TokenTypeLogicalOr:        LOGICAL_OR,
+	TokenTypeLogicalAnd:       LOGICAL_AND,
+	TokenTypeEqual:            EQUALS,
+	TokenTypeNotEqual:         EQUALS,
+	TokenTypeLessThan:         LESSGREATER,
+	TokenTypeLessThanEqual:    LESSGREATER,
+	TokenTypeGreaterThan:      LESSGREATER,
+	TokenTypeGreaterThanEqual: LESSGREATER,
+	TokenTypePlus:             SUM,
+	TokenTypeMinus:            SUM,
+	TokenTypeMultiply:         PRODUCT,
+	TokenTypeDivide:           PRODUCT,
+	TokenTypeModulo:           PRODUCT,
+	TokenTypePipe:             SUM,
+	TokenTypeCaret:            SUM,
+	TokenTypeAmpersand:        PRODUCT,
+	TokenTypeShiftLeft:        PRODUCT,
+	TokenTypeShiftRight:       PRODUCT,
+	TokenTypeShiftRightLogic:  PRODUCT,
+	TokenTypeAs:               CAST,
+	TokenTypeLeftParenthesis:  CALL,
+	TokenTypeLeftBracket:      INDEX,
+	TokenTypeDot:              CALL,
 
 	TokenTypeQuestionMark: TERNARY,
 	TokenTypeLambdaArrow:  TERNARY,
@@ -46,9 +62,17 @@ type (
 	infixParseFn  func(ast.ExpressionNode) ast.ExpressionNode
 )
 
+type bufferedToken struct {
+	tok LangToken
+	err error
+}
+
 type Parser struct {
 	lexer  *Lexer
 	errors []string
+	// errorTokens holds, for each error, the token the parser was at when
+	// it reported the error.
+	errorTokens []LangToken
 
 	currentToken LangToken
 	peekToken    LangToken
@@ -58,6 +82,9 @@ type Parser struct {
 	peekToken5   LangToken
 	peekTokenErr error
 
+	// overflow holds tokens read past peekToken5 by peekTokenAtIndex.
+	overflow []bufferedToken
+
 	prefixParseFns map[TokenType]prefixParseFn
 	infixParseFns  map[TokenType]infixParseFn
 }
@@ -72,6 +99,8 @@ func NewParser(lexer *Lexer) *Parser {
 	p.registerPrefix(TokenTypeIdentifier, p.parseIdentifier)
 	p.registerPrefix(TokenTypeNumber, p.parseNumberLiteral)
 	p.registerPrefix(TokenTypeString, p.parseStringLiteral)
+	p.registerPrefix(TokenTypeTrue, p.parseBooleanLiteral)
+	p.registerPrefix(TokenTypeFalse, p.parseBooleanLiteral)
 	p.registerPrefix(TokenTypeLeftParenthesis, p.parseParenthesisExpression)
 	p.registerPrefix(TokenTypeLeftBracket, p.parseArrayLiteral)
 	p.registerPrefix(TokenTypeLambdaArrow, p.parseLambdaExpression)
@@ -81,6 +110,10 @@ func NewParser(lexer *Lexer) *Parser {
 	p.registerPrefix(TokenTypeSyscall, p.parseSysCallExpression)
 	p.registerPrefix(TokenTypeAssembly, p.parseAssemblyStatement)
 	p.registerPrefix(TokenTypeMinus, p.parsePrefixExpression)
+	p.registerPrefix(TokenTypeBang, p.parsePrefixExpression)
+	p.registerPrefix(TokenTypeTilde, p.parsePrefixExpression)
+	p.registerPrefix(TokenTypeAmpersand, p.parsePrefixExpression)
+	p.registerPrefix(TokenTypeMultiply, p.parsePrefixExpression)
 	p.registerPrefix(TokenTypeFunction, p.parseAnonymousFunctionExpression)
 	//p.registerPrefix(TokenTypeComment, p.parseCommentExpression)
 	//p.registerPrefix(TokenTypeImport, p.parseImportStatement)
@@ -90,11 +123,24 @@ func NewParser(lexer *Lexer) *Parser {
 	p.registerInfix(TokenTypeMinus, p.parseInfixExpression)
 	p.registerInfix(TokenTypeMultiply, p.parseInfixExpression)
 	p.registerInfix(TokenTypeDivide, p.parseInfixExpression)
+	p.registerInfix(TokenTypeModulo, p.parseInfixExpression)
 	p.registerInfix(TokenTypeEqual, p.parseInfixExpression)
+	p.registerInfix(TokenTypeNotEqual, p.parseInfixExpression)
 	p.registerInfix(TokenTypeLessThan, p.parseInfixExpression)
+	p.registerInfix(TokenTypeLessThanEqual, p.parseInfixExpression)
 	p.registerInfix(TokenTypeGreaterThan, p.parseInfixExpression)
+	p.registerInfix(TokenTypeGreaterThanEqual, p.parseInfixExpression)
+	p.registerInfix(TokenTypeLogicalAnd, p.parseInfixExpression)
+	p.registerInfix(TokenTypeLogicalOr, p.parseInfixExpression)
+	p.registerInfix(TokenTypePipe, p.parseInfixExpression)
+	p.registerInfix(TokenTypeCaret, p.parseInfixExpression)
+	p.registerInfix(TokenTypeAmpersand, p.parseInfixExpression)
+	p.registerInfix(TokenTypeShiftLeft, p.parseInfixExpression)
+	p.registerInfix(TokenTypeShiftRight, p.parseInfixExpression)
+	p.registerInfix(TokenTypeShiftRightLogic, p.parseInfixExpression)
 
 	p.registerInfix(TokenTypeDot, p.parseMemberAccessExpression)
+	p.registerInfix(TokenTypeAs, p.parseCastExpression)
 
 	p.registerInfix(TokenTypeLeftParenthesis, p.parseCallExpression)
 	p.registerInfix(TokenTypeLeftBracket, p.parseIndexExpression)
@@ -121,6 +167,8 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.ClassDeclarations = []*ast.ClassDeclaration{}
 	program.DataStructures = []*ast.DataStructure{}
 	program.ImportStatements = []*ast.ImportStatement{}
+	program.Globals = []*ast.LetStatement{}
+	program.Tests = []*ast.TestBlock{}
 
 	for !p.currentTokenIs(TokenTypeEOF) {
 		parseStartPos := p.lexer.Position
@@ -136,14 +184,40 @@ func (p *Parser) ParseProgram() *ast.Program {
 				parsedItem = true
 			}
 
+		case TokenTypeLet, TokenTypeConst:
+			stmtNode := p.parseLetStatement()
+			if stmtNode != nil {
+				program.Globals = append(program.Globals, stmtNode)
+				parsedItem = true
+			}
+
 		case TokenTypeFunction, TokenTypeIdentifier:
+			if p.isTestBlock() {
+				if tb := p.parseTestBlock(); tb != nil {
+					program.Tests = append(program.Tests, tb)
+					parsedItem = true
+				}
+				break
+			}
+			if p.isThreadLocal() {
+				stmtNode := p.parseThreadLocalStatement()
+				if stmtNode != nil {
+					program.Globals = append(program.Globals, stmtNode)
+					parsedItem = true
+				}
+				break
+			}
 			looksLikeFunc := (p.currentTokenIs(TokenTypeFunction) && p.peekTokenIs(TokenTypeIdentifier)) ||
+				(p.currentTokenIs(TokenTypeFunction) && p.peekTokenIs(TokenTypeMultiply)) ||
 				(p.currentTokenIs(TokenTypeIdentifier) && p.peekTokenIs(TokenTypeLeftParenthesis))
 
 			if looksLikeFunc {
 				funcNode := p.parseFunctionDefinition()
 				if funcNode != nil {
 					if funcNode.Name != nil && funcNode.Name.Value == "main" {
+						if funcNode.Generator {
+							p.errors = append(p.errors, fmt.Sprintf("main cannot be a generator at line %d", funcNode.Token.Line))
+						}
 						if program.MainFunction != nil {
 							p.errors = append(p.errors, fmt.Sprintf("Redefinition of main function at line %d", funcNode.Token.Line))
 						}
@@ -155,7 +229,14 @@ func (p *Parser) ParseProgram() *ast.Program {
 				}
 			} else if p.currentTokenIs(TokenTypeIdentifier) {
 				isDecl := false
-				if p.peekTokenIs(TokenTypeArrow) || p.peekTokenIs(TokenTypeAssignment) || p.peekTokenIs(TokenTypeColon) {
+				if isLayoutQualifier(p.currentToken) && p.peekTokenIs(TokenTypeType) {
+					// packed type Name { ... } / extern type Name { ... }
+					classNode := p.parseClassDeclaration()
+					if classNode != nil {
+						program.ClassDeclarations = append(program.ClassDeclarations, classNode)
+						isDecl = true
+					}
+				} else if p.peekTokenIs(TokenTypeArrow) || p.peekTokenIs(TokenTypeAssignment) || p.peekTokenIs(TokenTypeColon) {
 					if p.peekTokenIs(TokenTypeArrow) {
 						classNode := p.parseClassDeclaration()
 						if classNode != nil {
@@ -217,6 +298,22 @@ func (p *Parser) Errors() []string {
 	return p.errors
 }
 
+// ErrorTokens returns, for each error Errors returns, the token the parser
+// was at when it reported the error, for tools that point at it in the
+// source.
+func (p *Parser) ErrorTokens() []LangToken {
+	p.markErrors()
+	return p.errorTokens
+}
+
+// markErrors records the current token for the errors reported since it
+// was last called.
+func (p *Parser) markErrors() {
+	for len(p.errorTokens) < len(p.errors) {
+		p.errorTokens = append(p.errorTokens, p.currentToken)
+	}
+}
+
 func (p *Parser) peekError(t TokenType) {
 	peekType := TokenTypeUndefined
 	peekLiteral := ""
@@ -269,6 +366,22 @@ func (p *Parser) parseIdentifier() ast.ExpressionNode {
 func (p *Parser) parseCallExpression(function ast.ExpressionNode) ast.ExpressionNode {
 	exp := &ast.CallExpression{Token: p.currentToken, Function: function}
 	exp.Arguments = p.parseExpressionList(TokenTypeRightParenthesis)
+
+	// `name = value` inside an argument list binds a parameter by name.
+	seenNamed := false
+	for i, arg := range exp.Arguments {
+		if assign, ok := arg.(*ast.AssignmentExpression); ok {
+			if name, ok := assign.Left.(*ast.Identifier); ok {
+				exp.Arguments[i] = &ast.NamedArgument{Token: assign.Token, Name: name, Value: assign.Right}
+				seenNamed = true
+				continue
+			}
+		}
+		if seenNamed {
+			p.errors = append(p.errors, fmt.Sprintf("Positional argument %s follows a named argument at line %d", arg.String(), exp.Token.Line+1))
+			return nil
+		}
+	}
 	return exp
 }
 
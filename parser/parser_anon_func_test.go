@@ -80,8 +80,8 @@ func TestAnonymousFunctionParsing(t *testing.T) {
 			}
 
 			for i, param := range lambda.Parameters {
-				if param.Value != tt.expectedParams[i] {
-					t.Errorf("Expected parameter %d to be %s but got %s for input: %s", i, tt.expectedParams[i], param.Value, tt.input)
+				if param.Name.Value != tt.expectedParams[i] {
+					t.Errorf("Expected parameter %d to be %s but got %s for input: %s", i, tt.expectedParams[i], param.Name.Value, tt.input)
 				}
 			}
 
@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"compiler/ast"
+	"compiler/lexer"
+	"strings"
+	"testing"
+)
+
+func TestAssemblyExpressionUnit(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expected      string // String() of the first statement in main
+		statements    int    // Statements expected in main
+		expectedError string // Substring of the first parser error, empty if none
+	}{
+		{
+			name:       "Intrinsic call",
+			input:      `main() -> { asm("builtin_print_int", 5); }`,
+			expected:   `asm("builtin_print_int", 5)`,
+			statements: 1,
+		},
+		{
+			name:       "Basic block",
+			input:      `main() -> { asm { "mov eax, 1" } }`,
+			expected:   `asm { "mov eax, 1" }`,
+			statements: 1,
+		},
+		{
+			name: "Block with every section",
+			input: `main() -> {
+				asm volatile intel {
+					"mov %0, %1"
+					"add %0, 2"
+					: "=r"(out), "+r"(acc)
+					: "r"(a + 1)
+					: "cc", "memory"
+				}
+				return out;
+			}`,
+			expected:   `asm volatile intel { "mov %0, %1" "add %0, 2" : "=r"(out), "+r"(acc) : "r"((a + 1)) : "cc", "memory" }`,
+			statements: 2,
+		},
+		{
+			name:       "Inputs only, followed by a statement",
+			input:      `main() -> { asm { "outb %0, $0x80" : : "r"(b) }; let x = 1; }`,
+			expected:   `asm { "outb %0, $0x80" : : "r"(b) }`,
+			statements: 2,
+		},
+		{
+			name:       "Clobbers only",
+			input:      `main() -> { asm att { "nop" : : : "memory" } }`,
+			expected:   `asm att { "nop" : : : "memory" }`,
+			statements: 1,
+		},
+		{
+			name:          "Two dialects",
+			input:         `main() -> { asm intel att { "nop" } }`,
+			expectedError: "names more than one dialect",
+		},
+		{
+			name:          "Unknown qualifier",
+			input:         `main() -> { asm inline { "nop" } }`,
+			expectedError: "unexpected 'inline' after 'asm'",
+		},
+		{
+			name:          "Missing text",
+			input:         `main() -> { asm { : "=r"(x) } }`,
+			expectedError: "must start with the assembly text",
+		},
+		{
+			name:          "Operand without expression",
+			input:         `main() -> { asm { "nop" : "=r" } }`,
+			expectedError: "expected next token to be",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, _ := lexer.NewLexerFromString(tt.input)
+			p := NewParser(l)
+			program := p.ParseProgram()
+
+			if tt.expectedError != "" {
+				if len(p.Errors()) == 0 {
+					t.Fatalf("expected parser error containing %q, got none", tt.expectedError)
+				}
+				if !strings.Contains(strings.Join(p.Errors(), "\n"), tt.expectedError) {
+					t.Fatalf("expected parser error containing %q, got %v", tt.expectedError, p.Errors())
+				}
+				return
+			}
+			checkParserErrors(t, p)
+
+			body, ok := program.MainFunction.Body.(*ast.BlockStatement)
+			if !ok {
+				t.Fatalf("MainFunction.Body is not a BlockStatement")
+			}
+			if len(body.Statements) != tt.statements {
+				t.Fatalf("expected %d statements, got %d: %v", tt.statements, len(body.Statements), body.Statements)
+			}
+			stmt, ok := body.Statements[0].(*ast.ExpressionStatement)
+			if !ok {
+				t.Fatalf("body.Statements[0] is not *ast.ExpressionStatement. got=%T", body.Statements[0])
+			}
+			if _, ok := stmt.Expression.(*ast.AssemblyExpression); !ok {
+				t.Fatalf("expression is not *ast.AssemblyExpression. got=%T", stmt.Expression)
+			}
+			if got := stmt.Expression.String(); got != tt.expected {
+				t.Errorf("String() wrong.\nexpected: %s\ngot:      %s", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"compiler/lexer"
+	"strings"
+	"testing"
+)
+
+func TestDataDeclarationUnit(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		fields        []string // name:type of each field, type empty if untyped
+		expectedError string   // Substring of the first parser error, empty if none
+	}{
+		{
+			name:   "Untyped fields",
+			input:  `data Point { let x, let y } main() -> { return 0; }`,
+			fields: []string{"x:", "y:"},
+		},
+		{
+			name:   "Typed fields and semicolons",
+			input:  `data Point { let x: i64; let name: string; let next: *Point; } main() -> { return 0; }`,
+			fields: []string{"x:i64", "name:string", "next:*Point"},
+		},
+		{
+			name:   "Mixed, trailing comma",
+			input:  `data Rec { let v: *json.JsonValue, let tag, } main() -> { return 0; }`,
+			fields: []string{"v:*json.JsonValue", "tag:"},
+		},
+		{
+			name:   "Equals style",
+			input:  `Pair = data { let a: i32, let b: i32 } main() -> { return 0; }`,
+			fields: []string{"a:i32", "b:i32"},
+		},
+		{
+			name:          "Field without let",
+			input:         `data Point { let x, y } main() -> { return 0; }`,
+			expectedError: "expected next token to be",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, _ := lexer.NewLexerFromString(tt.input)
+			p := NewParser(l)
+			program := p.ParseProgram()
+
+			if tt.expectedError != "" {
+				if len(p.Errors()) == 0 {
+					t.Fatalf("expected parser error containing %q, got none", tt.expectedError)
+				}
+				if !strings.Contains(strings.Join(p.Errors(), "\n"), tt.expectedError) {
+					t.Fatalf("expected parser error containing %q, got %v", tt.expectedError, p.Errors())
+				}
+				return
+			}
+			checkParserErrors(t, p)
+
+			if len(program.DataStructures) != 1 {
+				t.Fatalf("expected 1 data declaration, got %d", len(program.DataStructures))
+			}
+			if program.MainFunction == nil {
+				t.Fatalf("main was not parsed after the data declaration")
+			}
+			var got []string
+			for _, f := range program.DataStructures[0].Fields {
+				typeName := ""
+				if f.Type != nil {
+					typeName = f.Type.Value
+				}
+				got = append(got, f.Name.Value+":"+typeName)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.fields, " ") {
+				t.Errorf("fields wrong.\nexpected: %v\ngot:      %v", tt.fields, got)
+			}
+		})
+	}
+}
@@ -21,20 +21,23 @@ func TestInfixExpressionUnit(t *testing.T) {
 		{"main() -> {5 / 5;}", int64(5), "/", int64(5)},
 		{"main() -> {5 > 5;}", int64(5), ">", int64(5)},
 		{"main() -> {5 < 5;}", int64(5), "<", int64(5)},
-		// {"main() -> {5 == 5;}", int64(5), "==", int64(5)}, // TokenTypeEqual not handled in infix map?
-		// {"main() -> {5 != 5;}", int64(5), "!=", int64(5)}, // Need != operator
+		{"main() -> {5 == 5;}", int64(5), "==", int64(5)},
+		{"main() -> {5 != 5;}", int64(5), "!=", int64(5)},
+		{"main() -> {5 >= 5;}", int64(5), ">=", int64(5)},
+		{"main() -> {5 % 5;}", int64(5), "%", int64(5)},
 		{"main() -> {foo + bar;}", "foo", "+", "bar"},
 		{"main() -> {foo - bar;}", "foo", "-", "bar"},
 		{"main() -> {foo * bar;}", "foo", "*", "bar"},
 		{"main() -> {foo / bar;}", "foo", "/", "bar"},
 		{"main() -> {foo > bar;}", "foo", ">", "bar"},
 		{"main() -> {foo < bar;}", "foo", "<", "bar"},
-		// {"main() -> {foo == bar;}", "foo", "==", "bar"},
-		// {"main() -> {foo != bar;}", "foo", "!=", "bar"},
-		// Add boolean tests when supported:
-		// {"main() -> {true == true;}", true, "==", true},
-		// {"main() -> {true != false;}", true, "!=", false},
-		// {"main() -> {false == false;}", false, "==", false},
+		{"main() -> {foo == bar;}", "foo", "==", "bar"},
+		{"main() -> {foo != bar;}", "foo", "!=", "bar"},
+		{"main() -> {foo && bar;}", "foo", "&&", "bar"},
+		{"main() -> {foo || bar;}", "foo", "||", "bar"},
+		{"main() -> {true == true;}", true, "==", true},
+		{"main() -> {true != false;}", true, "!=", false},
+		{"main() -> {false == false;}", false, "==", false},
 	}
 
 	for _, tt := range infixTests {
@@ -86,6 +89,8 @@ func TestPrefixExpressionUnit(t *testing.T) {
 		value    interface{} // Value of the operand
 	}{
 		{"main() -> {-15;}", "-", int64(15)},
+		{"main() -> {!foo;}", "!", "foo"},
+		{"main() -> {!true;}", "!", true},
 	}
 
 	for _, tt := range prefixTests {
@@ -133,10 +138,10 @@ func TestOperatorPrecedenceParsingUnit(t *testing.T) {
 			"main() -> {-a * b;}",
 			"main() -> ((-a) * b);", // Requires PrefixExpression support
 		},
-		// {
-		// 	"main() -> {!-a;}",
-		// 	"main() -> (!(-a));", // Requires PrefixExpression support
-		// },
+		{
+			"main() -> {!-a;}",
+			"main() -> (!(-a));",
+		},
 		{
 			"main() -> {a + b + c;}",
 			"main() -> ((a + b) + c);",
@@ -169,31 +174,50 @@ func TestOperatorPrecedenceParsingUnit(t *testing.T) {
 			"main() -> {5 > 4 == 3 < 4;}", // Needs == support
 			"main() -> ((5 > 4) == (3 < 4));",
 		},
-		// {
-		// 	"main() -> {5 < 4 != 3 > 4;}", // Needs != support
-		// 	"main() -> ((5 < 4) != (3 > 4));",
-		// },
-		// {
-		// 	"main() -> {3 + 4 * 5 == 3 * 1 + 4 * 5;}", // Needs == support
-		// 	"main() -> ((3 + (4 * 5)) == ((3 * 1) + (4 * 5)));",
-		// },
-		// Boolean precedence if added
-		// {
-		// 	"main() -> {true;}",
-		// 	"main() -> true;",
-		// },
-		// {
-		// 	"main() -> {false;}",
-		// 	"main() -> false;",
-		// },
-		// {
-		// 	"main() -> {3 > 5 == false;}", // Needs == support
-		// 	"main() -> ((3 > 5) == false);",
-		// },
-		// {
-		// 	"main() -> {3 < 5 == true;}", // Needs == support
-		// 	"main() -> ((3 < 5) == true);",
-		// },
+		{
+			"main() -> {5 < 4 != 3 > 4;}",
+			"main() -> ((5 < 4) != (3 > 4));",
+		},
+		{
+			"main() -> {3 + 4 * 5 == 3 * 1 + 4 * 5;}",
+			"main() -> ((3 + (4 * 5)) == ((3 * 1) + (4 * 5)));",
+		},
+		{
+			"main() -> {a || b && c == d;}",
+			"main() -> (a || (b && (c == d)));",
+		},
+		{
+			"main() -> {a % b + c >= d;}",
+			"main() -> (((a % b) + c) >= d);",
+		},
+		{
+			"main() -> {a | b & c ^ d;}",
+			"main() -> ((a | (b & c)) ^ d);",
+		},
+		{
+			"main() -> {a & 255 == b << 8 >>> 4;}",
+			"main() -> ((a & 255) == ((b << 8) >>> 4));",
+		},
+		{
+			"main() -> {1 << n - 1 + ~a >> 2;}",
+			"main() -> (((1 << n) - 1) + ((~a) >> 2));",
+		},
+		{
+			"main() -> {true;}",
+			"main() -> true;",
+		},
+		{
+			"main() -> {false;}",
+			"main() -> false;",
+		},
+		{
+			"main() -> {3 > 5 == false;}",
+			"main() -> ((3 > 5) == false);",
+		},
+		{
+			"main() -> {3 < 5 == true;}",
+			"main() -> ((3 < 5) == true);",
+		},
 		// Grouping with Parentheses
 		{
 			"main() -> {1 + (2 + 3) + 4;}",
@@ -207,14 +231,14 @@ func TestOperatorPrecedenceParsingUnit(t *testing.T) {
 			"main() -> {2 / (5 + 5);}",
 			"main() -> (2 / (5 + 5));",
 		},
-		// {
-		// 	"main() -> {-(5 + 5);}", // Requires PrefixExpression support
-		// 	"main() -> (-(5 + 5));",
-		// },
-		// {
-		// 	"main() -> {!(true == true);}", // Requires PrefixExpression and Boolean support
-		// 	"main() -> (!(true == true));",
-		// },
+		{
+			"main() -> {-(5 + 5);}",
+			"main() -> (-(5 + 5));",
+		},
+		{
+			"main() -> {!(true == true);}",
+			"main() -> (!(true == true));",
+		},
 		// Calls and Indexing (Higher precedence)
 		{
 			"main() -> {a + add(b * c) + d;}",
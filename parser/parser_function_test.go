@@ -168,8 +168,8 @@ func TestFunctionDefinitionParsingIntegration(t *testing.T) {
 				t.Errorf("Parameter count mismatch. want=%d, got=%d", len(tt.expectedParams), len(fnDef.Parameters))
 			} else {
 				for i, expectedParam := range tt.expectedParams {
-					if fnDef.Parameters[i].Value != expectedParam {
-						t.Errorf("Parameter %d mismatch. want=%s, got=%s", i, expectedParam, fnDef.Parameters[i].Value)
+					if fnDef.Parameters[i].Name.Value != expectedParam {
+						t.Errorf("Parameter %d mismatch. want=%s, got=%s", i, expectedParam, fnDef.Parameters[i].Name.Value)
 					}
 				}
 			}
@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"compiler/ast"
+	"compiler/lexer"
+	"strings"
+	"testing"
+)
+
+func TestGeneratorDefinitionUnit(t *testing.T) {
+	input := `
+function* count(from: i64, to: i64): i64 -> {
+	let i = from;
+	while (i < to) {
+		yield i;
+		i = i + 1;
+	}
+}
+function flat(ss: seq<seq<i64>>, deep: seq<seq<seq<i64>>>): i64 -> { return 0; }
+function total(s: seq<i64>): i64 -> {
+	let t: i64 = 0;
+	for x in s { t = t + x; }
+	for (y in s) { t = t + y; }
+	return t;
+}
+main() -> { return 0; }
+`
+	l, err := lexer.NewLexerFromString(input)
+	if err != nil {
+		t.Fatalf("Lexer creation failed: %v", err)
+	}
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Functions) != 3 {
+		t.Fatalf("expected 3 functions, got %d", len(program.Functions))
+	}
+	count, flat, total := program.Functions[0], program.Functions[1], program.Functions[2]
+	if !count.Generator || count.Name.Value != "count" {
+		t.Errorf("expected generator 'count', got %s (generator=%t)", count.Name.Value, count.Generator)
+	}
+	if count.ReturnType == nil || count.ReturnType.Value != "i64" {
+		t.Errorf("generator yield type wrong: %v", count.ReturnType)
+	}
+	if !strings.Contains(count.String(), "yield i;") {
+		t.Errorf("generator body missing yield statement:\n%s", count.String())
+	}
+	if total.Generator {
+		t.Errorf("'total' should not be a generator")
+	}
+	if got := total.Parameters[0].Type.Value; got != "seq<i64>" {
+		t.Errorf("parameter type wrong. expected seq<i64>, got %s", got)
+	}
+
+	// The closing '>>' and '>>>' of nested seq types are split up.
+	if got := flat.Parameters[0].Type.Value; got != "seq<seq<i64>>" {
+		t.Errorf("parameter type wrong. expected seq<seq<i64>>, got %s", got)
+	}
+	if got := flat.Parameters[1].Type.Value; got != "seq<seq<seq<i64>>>" {
+		t.Errorf("parameter type wrong. expected seq<seq<seq<i64>>>, got %s", got)
+	}
+
+	body, ok := total.Body.(*ast.BlockStatement)
+	if !ok {
+		t.Fatalf("total.Body is not a BlockStatement")
+	}
+	for i, name := range []string{"x", "y"} {
+		loop, ok := body.Statements[i+1].(*ast.ForInStatement)
+		if !ok {
+			t.Fatalf("statement %d is not a ForInStatement: %T", i+1, body.Statements[i+1])
+		}
+		if loop.Variable.Value != name || loop.Iterable.String() != "s" {
+			t.Errorf("for loop %d wrong: %s", i, loop.String())
+		}
+	}
+}
+
+func TestGeneratorErrorsUnit(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"function* g(): i64 -> { yield; } main() -> { return 0; }", "yield needs a value"},
+		{"function* main() -> { yield 1; }", "main cannot be a generator"},
+		{"main() -> { for 1 in xs { } return 0; }", "expected next token to be Identifier"},
+		{"function f(s: seq<seq<i64>) -> { } main() -> { return 0; }", "expected next token to be GreaterThan"},
+	}
+	for _, tt := range tests {
+		l, err := lexer.NewLexerFromString(tt.input)
+		if err != nil {
+			t.Fatalf("Lexer creation failed: %v", err)
+		}
+		p := NewParser(l)
+		p.ParseProgram()
+		if !strings.Contains(strings.Join(p.Errors(), "\n"), tt.expectedError) {
+			t.Errorf("input %q: expected error containing %q, got %v", tt.input, tt.expectedError, p.Errors())
+		}
+	}
+}
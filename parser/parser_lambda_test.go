@@ -173,8 +173,8 @@ func TestLambdaExpressionParsingIntegration(t *testing.T) {
 				t.Errorf("Parameter count mismatch. want=%d, got=%d", len(tt.expectedParams), len(lambda.Parameters))
 			} else {
 				for i, expectedParam := range tt.expectedParams {
-					if lambda.Parameters[i].Value != expectedParam {
-						t.Errorf("Parameter %d mismatch. want=%s, got=%s", i, expectedParam, lambda.Parameters[i].Value)
+					if lambda.Parameters[i].Name.Value != expectedParam {
+						t.Errorf("Parameter %d mismatch. want=%s, got=%s", i, expectedParam, lambda.Parameters[i].Name.Value)
 					}
 				}
 			}
@@ -42,3 +42,30 @@ func TestVariableDeclarationNumberLiteral(t *testing.T) {
 		t.Fatalf("stmt.Name.Value not 'x'. got=%q", stmt.Name.Value)
 	}
 }
+
+func TestHexNumberLiteral(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"main() -> {let x = 0x22;}", 34},
+		{"main() -> {let x = 0XfF;}", 255},
+		{"main() -> {let x = 0x0;}", 0},
+	}
+
+	for _, tt := range tests {
+		l, _ := lexer.NewLexerFromString(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.MainFunction.Body.(*ast.BlockStatement).Statements[0].(*ast.LetStatement)
+		num, ok := stmt.Value.(*ast.NumberLiteral)
+		if !ok {
+			t.Fatalf("%s: stmt.Value is not *ast.NumberLiteral. got=%T", tt.input, stmt.Value)
+		}
+		if num.Value != tt.expected {
+			t.Errorf("%s: NumberLiteral value not %v. got=%v", tt.input, tt.expected, num.Value)
+		}
+	}
+}
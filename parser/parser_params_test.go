@@ -0,0 +1,180 @@
+package parser
+
+import (
+	"compiler/ast"
+	"compiler/lexer"
+	"testing"
+)
+
+func TestTypedAndDefaultParameters(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string // Parameter.String() of each parameter
+		expectedErrors int
+	}{
+		{
+			input:          `function add(a: int, b: int) -> a + b;`,
+			expectedParams: []string{"a: int", "b: int"},
+		},
+		{
+			input:          `function greet(name: string, times: int = 1) -> name;`,
+			expectedParams: []string{"name: string", "times: int = 1"},
+		},
+		{
+			input:          `function mix(a, b = 2, c: i64 = 3) -> a;`,
+			expectedParams: []string{"a", "b = 2", "c: i64 = 3"},
+		},
+		{
+			input:          `function ptrs(p: *u8, xs: []int) -> p;`,
+			expectedParams: []string{"p: *u8", "xs: []int"},
+		},
+		{
+			input:          `function apply(f: (int, int) -> int, x: int) -> f(x, x);`,
+			expectedParams: []string{"f: (int,int)->int", "x: int"},
+		},
+		{
+			input:          `function legacy(x(int)) -> x;`,
+			expectedParams: []string{"x: int"},
+		},
+		{
+			input:          `function fmt(f: string, args: ...any) -> f;`,
+			expectedParams: []string{"f: string", "args: ...any"},
+		},
+		{
+			input:          `function bad(args: ...int, x: int) -> x;`, // Variadic parameter must be last
+			expectedErrors: 1,
+		},
+		{
+			input:          `function bad(args: ...int = 1) -> args;`, // Variadic parameter cannot have a default
+			expectedErrors: 1,
+		},
+		{
+			input:          `function bad(a = 1, b) -> a;`, // Required parameter after a default
+			expectedErrors: 1,
+		},
+		{
+			input:          `function bad(a: ) -> a;`, // Missing type
+			expectedErrors: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			l, err := lexer.NewLexerFromString(tt.input)
+			if err != nil {
+				t.Fatalf("Lexer creation failed: %v", err)
+			}
+			p := NewParser(l)
+			program := p.ParseProgram()
+
+			if tt.expectedErrors > 0 {
+				if len(p.Errors()) < tt.expectedErrors {
+					t.Errorf("Expected at least %d parser errors, but got %d", tt.expectedErrors, len(p.Errors()))
+				}
+				return
+			}
+			checkParserErrors(t, p)
+
+			if len(program.Functions) != 1 {
+				t.Fatalf("Expected 1 function, got %d", len(program.Functions))
+			}
+			params := program.Functions[0].Parameters
+			if len(params) != len(tt.expectedParams) {
+				t.Fatalf("Parameter count mismatch. want=%d, got=%d", len(tt.expectedParams), len(params))
+			}
+			for i, expected := range tt.expectedParams {
+				if params[i].String() != expected {
+					t.Errorf("Parameter %d mismatch. want=%q, got=%q", i, expected, params[i].String())
+				}
+			}
+		})
+	}
+}
+
+func TestTypedLambdaParameters(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedParams []string
+	}{
+		{`let f = (x: int, y: int) -> x + y;`, []string{"x: int", "y: int"}},
+		{`let f = (x, scale = 2) -> x * scale;`, []string{"x", "scale = 2"}},
+		{`let f = (s: string, n: int = strlen(s)) -> n;`, []string{"s: string", "n: int = strlen(s)"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			l, err := lexer.NewLexerFromString(tt.input)
+			if err != nil {
+				t.Fatalf("Lexer creation failed: %v", err)
+			}
+			p := NewParser(l)
+			stmt := p.parseStatement()
+			checkParserErrors(t, p)
+
+			letStmt, ok := stmt.(*ast.LetStatement)
+			if !ok {
+				t.Fatalf("Expected *ast.LetStatement, got %T", stmt)
+			}
+			lambda, ok := letStmt.Value.(*ast.LambdaExpression)
+			if !ok {
+				t.Fatalf("Expected *ast.LambdaExpression, got %T", letStmt.Value)
+			}
+			if len(lambda.Parameters) != len(tt.expectedParams) {
+				t.Fatalf("Parameter count mismatch. want=%d, got=%d", len(tt.expectedParams), len(lambda.Parameters))
+			}
+			for i, expected := range tt.expectedParams {
+				if lambda.Parameters[i].String() != expected {
+					t.Errorf("Parameter %d mismatch. want=%q, got=%q", i, expected, lambda.Parameters[i].String())
+				}
+			}
+		})
+	}
+}
+
+func TestNamedArguments(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedArgs   []string
+		expectedErrors int
+	}{
+		{input: `f(1, b = 2);`, expectedArgs: []string{"1", "b = 2"}},
+		{input: `f(c = 3, a = x + 1);`, expectedArgs: []string{"c = 3", "a = (x + 1)"}},
+		{input: `f(a = 1, 2);`, expectedErrors: 1}, // Positional after named
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			l, err := lexer.NewLexerFromString(tt.input)
+			if err != nil {
+				t.Fatalf("Lexer creation failed: %v", err)
+			}
+			p := NewParser(l)
+			stmt := p.parseStatement()
+
+			if tt.expectedErrors > 0 {
+				if len(p.Errors()) < tt.expectedErrors {
+					t.Errorf("Expected at least %d parser errors, but got %d", tt.expectedErrors, len(p.Errors()))
+				}
+				return
+			}
+			checkParserErrors(t, p)
+
+			exprStmt, ok := stmt.(*ast.ExpressionStatement)
+			if !ok {
+				t.Fatalf("Expected *ast.ExpressionStatement, got %T", stmt)
+			}
+			call, ok := exprStmt.Expression.(*ast.CallExpression)
+			if !ok {
+				t.Fatalf("Expected *ast.CallExpression, got %T", exprStmt.Expression)
+			}
+			if len(call.Arguments) != len(tt.expectedArgs) {
+				t.Fatalf("Argument count mismatch. want=%d, got=%d", len(tt.expectedArgs), len(call.Arguments))
+			}
+			for i, expected := range tt.expectedArgs {
+				if call.Arguments[i].String() != expected {
+					t.Errorf("Argument %d mismatch. want=%q, got=%q", i, expected, call.Arguments[i].String())
+				}
+			}
+		})
+	}
+}
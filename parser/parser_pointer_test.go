@@ -0,0 +1,163 @@
+package parser
+
+import (
+	"compiler/ast"
+	"compiler/lexer"
+	"strings"
+	"testing"
+)
+
+func TestPointerExpressionUnit(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		expected      string // String() of the first statement in main
+		expectedError string // Substring of the first parser error, empty if none
+	}{
+		{
+			name:     "Address of",
+			input:    `main() -> { bump(&x); }`,
+			expected: `bump((&x))`,
+		},
+		{
+			name:     "Dereference",
+			input:    `main() -> { let v = *p + 1; }`,
+			expected: `let v = ((*p) + 1);`,
+		},
+		{
+			name:     "Store through pointer",
+			input:    `main() -> { *p = *p + 1; }`,
+			expected: `(*p) = ((*p) + 1)`,
+		},
+		{
+			name:     "Address of a field",
+			input:    `main() -> { let n = &d.d_name; }`,
+			expected: `let n = (&(d.d_name));`,
+		},
+		{
+			name:     "Pointer cast",
+			input:    `main() -> { let w = buf as *u16; }`,
+			expected: `let w = (buf as *u16);`,
+		},
+		{
+			name:     "Cast to a type of another module",
+			input:    `main() -> { let st = buf as *fs.Stat; }`,
+			expected: `let st = (buf as *fs.Stat);`,
+		},
+		{
+			name:     "Cast binds tighter than arithmetic",
+			input:    `main() -> { let n = pos + d.d_reclen as u64; }`,
+			expected: `let n = (pos + ((d.d_reclen) as u64));`,
+		},
+		{
+			name:          "Cast without a type",
+			input:         `main() -> { let w = buf as 3; }`,
+			expectedError: "expected a type after 'as'",
+		},
+		{
+			name:          "Store to a call",
+			input:         `main() -> { f() = 1; }`,
+			expectedError: "Invalid left-hand side in assignment",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, _ := lexer.NewLexerFromString(tt.input)
+			p := NewParser(l)
+			program := p.ParseProgram()
+
+			if tt.expectedError != "" {
+				if len(p.Errors()) == 0 {
+					t.Fatalf("expected parser error containing %q, got none", tt.expectedError)
+				}
+				if !strings.Contains(strings.Join(p.Errors(), "\n"), tt.expectedError) {
+					t.Fatalf("expected parser error containing %q, got %v", tt.expectedError, p.Errors())
+				}
+				return
+			}
+			checkParserErrors(t, p)
+
+			body, ok := program.MainFunction.Body.(*ast.BlockStatement)
+			if !ok {
+				t.Fatalf("MainFunction.Body is not a BlockStatement")
+			}
+			if len(body.Statements) != 1 {
+				t.Fatalf("expected 1 statement, got %d: %v", len(body.Statements), body.Statements)
+			}
+			if got := body.Statements[0].String(); got != tt.expected {
+				t.Errorf("String() wrong.\nexpected: %s\ngot:      %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTypeDeclarationLayout(t *testing.T) {
+	tests := []struct {
+		input  string
+		layout ast.StructLayout
+		fields []string
+	}{
+		{`type point { let x: i32; let y: i32; } main() -> { return 0; }`, ast.StructLayoutDefault, []string{"x", "y"}},
+		{`extern type header { let tag: u8; let len: u16; let id: u64; } main() -> { return 0; }`, ast.StructLayoutExtern, []string{"tag", "len", "id"}},
+		{`packed type wire { let tag: u8; let id: u64; } main() -> { return 0; }`, ast.StructLayoutPacked, []string{"tag", "id"}},
+	}
+
+	for _, tt := range tests {
+		l, _ := lexer.NewLexerFromString(tt.input)
+		p := NewParser(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.ClassDeclarations) != 1 {
+			t.Fatalf("%s: expected 1 type declaration, got %d", tt.input, len(program.ClassDeclarations))
+		}
+		cd := program.ClassDeclarations[0]
+		if cd.Layout != tt.layout {
+			t.Errorf("%s: layout wrong. expected %v, got %v", tt.input, tt.layout, cd.Layout)
+		}
+		if len(cd.Members) != len(tt.fields) {
+			t.Fatalf("%s: expected %d members, got %d", tt.input, len(tt.fields), len(cd.Members))
+		}
+		for i, name := range tt.fields {
+			vd := cd.Members[i].VariableDeclaration
+			if vd == nil {
+				t.Fatalf("%s: member %d is not a field", tt.input, i)
+			}
+			if vd.Name.Value != name || vd.Type == nil {
+				t.Errorf("%s: member %d wrong. expected typed field %q, got %s", tt.input, i, name, vd.String())
+			}
+		}
+	}
+}
+
+// TestTypeMethods checks that the methods of a type end at their own '}', so
+// further members and the type's closing brace still parse.
+func TestTypeMethods(t *testing.T) {
+	input := `type counter {
+		let n: i64;
+		function i64 bump() -> { self.n = self.n + 1; return self.n; }
+		function i64 get() -> { return self.n; }
+		let step: i64;
+	}
+	main() -> { return 0; }`
+	l, _ := lexer.NewLexerFromString(input)
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.ClassDeclarations) != 1 || program.MainFunction == nil {
+		t.Fatalf("expected 1 type declaration and main, got %d types, main %v", len(program.ClassDeclarations), program.MainFunction != nil)
+	}
+	var got []string
+	for _, m := range program.ClassDeclarations[0].Members {
+		if m.MethodDeclaration != nil {
+			got = append(got, m.MethodDeclaration.Name.Value+"()")
+		} else {
+			got = append(got, m.VariableDeclaration.Name.Value)
+		}
+	}
+	if strings.Join(got, " ") != "n bump() get() step" {
+		t.Errorf("members wrong. expected [n bump() get() step], got %v", got)
+	}
+}
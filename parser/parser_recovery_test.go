@@ -133,3 +133,31 @@ func TestStatementRecoveryUnit(t *testing.T) {
 		})
 	}
 }
+
+// TestErrorTokens covers locating each error at the token the parser was at
+// when it reported it.
+func TestErrorTokens(t *testing.T) {
+	input := "main() -> {\n    let a = 1;\n    + 5;\n    let = 6;\n}"
+	l, err := lexer.NewLexerFromString(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewParser(l)
+	p.ParseProgram()
+	errs, toks := p.Errors(), p.ErrorTokens()
+	if len(errs) != 2 || len(toks) != len(errs) {
+		t.Fatalf("expected 2 errors with their tokens, got %q and %v", errs, toks)
+	}
+	expected := []struct {
+		line, pos int
+		literal   string
+	}{
+		{2, 5, "+"},
+		{3, 5, "let"},
+	}
+	for i, e := range expected {
+		if toks[i].Line != e.line || toks[i].Pos != e.pos || toks[i].Literal != e.literal {
+			t.Errorf("error %q at %q line %d pos %d, want %q line %d pos %d", errs[i], toks[i].Literal, toks[i].Line, toks[i].Pos, e.literal, e.line, e.pos)
+		}
+	}
+}
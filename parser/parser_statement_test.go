@@ -4,6 +4,7 @@ import (
 	"compiler/ast"
 	"compiler/lexer"
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -33,7 +34,9 @@ func TestLetStatementUnit(t *testing.T) {
 		{"main() -> {let foo = 12345;}", "foo", int64(12345)},
 		{"main() -> {let name = \"bar\";}", "name", "bar"},
 		{"main() -> {let empty = '';}", "empty", ""},
-		// Add tests for other literal types (bool, etc.) if/when supported
+		{"main() -> {let ok = true;}", "ok", true},
+		{"main() -> {let wide: i64 = 5;}", "wide", int64(5)},
+		{"main() -> {const limit = 8;}", "limit", int64(8)},
 	}
 
 	for _, tc := range testCases {
@@ -73,6 +76,118 @@ func TestLetStatementUnit(t *testing.T) {
 	}
 }
 
+func TestTypedLetStatementUnit(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType string
+		expected     string // LetStatement.String()
+	}{
+		{"main() -> {let x: i64 = 5;}", "i64", "let x: i64 = 5;"},
+		{"main() -> {let buf: *u8 = p;}", "*u8", "let buf: *u8 = p;"},
+		{"main() -> {let xs: []any = ys;}", "[]any", "let xs: []any = ys;"},
+		{"main() -> {let x = 5;}", "", "let x = 5;"},
+		{"main() -> {const n: i64 = 5;}", "i64", "const n: i64 = 5;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			l, err := lexer.NewLexerFromString(tt.input)
+			if err != nil {
+				t.Fatalf("Lexer creation failed: %v", err)
+			}
+			p := NewParser(l)
+			program := p.ParseProgram()
+			checkParserErrors(t, p)
+
+			stmt, ok := program.MainFunction.Body.(*ast.BlockStatement).Statements[0].(*ast.LetStatement)
+			if !ok {
+				t.Fatalf("Statement is not *ast.LetStatement. got=%T", program.MainFunction.Body.(*ast.BlockStatement).Statements[0])
+			}
+			gotType := ""
+			if stmt.Type != nil {
+				gotType = stmt.Type.Value
+			}
+			if gotType != tt.expectedType {
+				t.Errorf("LetStatement.Type mismatch. want=%q, got=%q", tt.expectedType, gotType)
+			}
+			if stmt.String() != tt.expected {
+				t.Errorf("LetStatement.String() mismatch. want=%q, got=%q", tt.expected, stmt.String())
+			}
+		})
+	}
+}
+
+func TestTopLevelDeclarationsUnit(t *testing.T) {
+	input := `
+const SYS_openat = 257;
+let counter: i64 = 0;
+main() -> { return counter; }
+const O_RDONLY = 0x0;
+thread_local let seen: i64 = 1;
+`
+	l, err := lexer.NewLexerFromString(input)
+	if err != nil {
+		t.Fatalf("Lexer creation failed: %v", err)
+	}
+	p := NewParser(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := []struct {
+		name        string
+		isConst     bool
+		threadLocal bool
+		str         string
+	}{
+		{"SYS_openat", true, false, "const SYS_openat = 257;"},
+		{"counter", false, false, "let counter: i64 = 0;"},
+		{"O_RDONLY", true, false, "const O_RDONLY = 0;"},
+		{"seen", false, true, "thread_local let seen: i64 = 1;"},
+	}
+	if len(program.Globals) != len(expected) {
+		t.Fatalf("program.Globals has wrong length. want=%d, got=%d", len(expected), len(program.Globals))
+	}
+	for i, want := range expected {
+		got := program.Globals[i]
+		if got.Name.Value != want.name {
+			t.Errorf("Globals[%d].Name mismatch. want=%q, got=%q", i, want.name, got.Name.Value)
+		}
+		if got.IsConst() != want.isConst {
+			t.Errorf("Globals[%d].IsConst() mismatch. want=%t, got=%t", i, want.isConst, got.IsConst())
+		}
+		if got.ThreadLocal != want.threadLocal {
+			t.Errorf("Globals[%d].ThreadLocal mismatch. want=%t, got=%t", i, want.threadLocal, got.ThreadLocal)
+		}
+		if got.String() != want.str {
+			t.Errorf("Globals[%d].String() mismatch. want=%q, got=%q", i, want.str, got.String())
+		}
+	}
+	if program.MainFunction == nil {
+		t.Errorf("ParseProgram() lost the main function between top-level declarations")
+	}
+}
+
+func TestThreadLocalErrorsUnit(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"thread_local const N = 1;", "thread_local cannot be applied to const"},
+		{"main() -> { thread_local let x = 1; return x; }", "thread_local variables must be declared at top level"},
+	}
+	for _, tt := range tests {
+		l, err := lexer.NewLexerFromString(tt.input)
+		if err != nil {
+			t.Fatalf("Lexer creation failed: %v", err)
+		}
+		p := NewParser(l)
+		p.ParseProgram()
+		if !strings.Contains(strings.Join(p.Errors(), "\n"), tt.expectedError) {
+			t.Errorf("input %q: expected error containing %q, got %v", tt.input, tt.expectedError, p.Errors())
+		}
+	}
+}
+
 func TestReturnStatementUnit(t *testing.T) {
 	testCases := []struct {
 		input         string
@@ -189,12 +304,25 @@ func testLiteralExpression(t *testing.T, expr ast.ExpressionNode, expected inter
 			testStringLiteral(t, expr, v)
 		}
 	case bool:
-		// testBooleanLiteral(t, expr, v) // Add when boolean literals are supported
+		testBooleanLiteral(t, expr, v)
 	default:
 		t.Errorf("type of expr not handled: %T", expr)
 	}
 }
 
+// Helper for boolean literals
+func testBooleanLiteral(t *testing.T, expr ast.ExpressionNode, expectedValue bool) {
+	t.Helper()
+	b, ok := expr.(*ast.BooleanLiteral)
+	if !ok {
+		t.Errorf("expr not *ast.BooleanLiteral. got=%T", expr)
+		return
+	}
+	if b.Value != expectedValue {
+		t.Errorf("b.Value not %t. got=%t", expectedValue, b.Value)
+	}
+}
+
 // Helper for number literals
 func testNumberLiteral(t *testing.T, expr ast.ExpressionNode, expectedValue interface{}) {
 	t.Helper()
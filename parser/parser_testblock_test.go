@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"compiler/lexer"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestTestBlockUnit(t *testing.T) {
+	tests := []struct {
+		name          string
+		input         string
+		tests         []string // Name and number of statements of each test block
+		functions     int      // Functions other than main
+		expectedError string   // Substring of the first parser error, empty if none
+	}{
+		{
+			name:      "Blocks around functions",
+			input:     `test "first" { assert(f() == 1); } f() -> { return 1; } test "second one" { let x = f(); assertEq(x, 1); } main() -> { return 0; }`,
+			tests:     []string{"first:1", "second one:2"},
+			functions: 1,
+		},
+		{
+			name:  "Empty block",
+			input: `test "nothing" { } main() -> { return 0; }`,
+			tests: []string{"nothing:0"},
+		},
+		{
+			name:      "test is still a name",
+			input:     `test(a: i64) -> { return a; } main() -> { return test(1); }`,
+			functions: 1,
+		},
+		{
+			name:          "Missing block",
+			input:         `test "broken" main() -> { return 0; }`,
+			expectedError: "expected next token to be",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, _ := lexer.NewLexerFromString(tt.input)
+			p := NewParser(l)
+			program := p.ParseProgram()
+
+			if tt.expectedError != "" {
+				if len(p.Errors()) == 0 {
+					t.Fatalf("expected parser error containing %q, got none", tt.expectedError)
+				}
+				if !strings.Contains(strings.Join(p.Errors(), "\n"), tt.expectedError) {
+					t.Fatalf("expected parser error containing %q, got %v", tt.expectedError, p.Errors())
+				}
+				return
+			}
+			checkParserErrors(t, p)
+
+			if program.MainFunction == nil {
+				t.Fatalf("main was not parsed")
+			}
+			if len(program.Functions) != tt.functions {
+				t.Errorf("expected %d functions, got %d", tt.functions, len(program.Functions))
+			}
+			var got []string
+			for _, tb := range program.Tests {
+				got = append(got, fmt.Sprintf("%s:%d", tb.Name.Value, len(tb.Body.Statements)))
+			}
+			if strings.Join(got, " ") != strings.Join(tt.tests, " ") {
+				t.Errorf("test blocks wrong.\nexpected: %v\ngot:      %v", tt.tests, got)
+			}
+		})
+	}
+}
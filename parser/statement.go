@@ -11,9 +11,16 @@ func (p *Parser) parseStatement() ast.Statement {
 		p.nextToken()
 		return nil
 	}
+	if p.isThreadLocal() {
+		p.errors = append(p.errors, fmt.Sprintf("thread_local variables must be declared at top level near line %d", p.currentToken.Line))
+		if ls := p.parseThreadLocalStatement(); ls != nil {
+			return ls
+		}
+		return nil
+	}
 
 	switch p.currentToken.Type {
-	case TokenTypeLet:
+	case TokenTypeLet, TokenTypeConst:
 		ls := p.parseLetStatement()
 		if ls == nil {
 			return nil // Propagate nil on failure
@@ -47,6 +54,18 @@ func (p *Parser) parseStatement() ast.Statement {
 			return nil
 		}
 		return wsNode
+	case TokenTypeFor:
+		fsNode := p.parseForInStatement()
+		if fsNode == nil {
+			return nil
+		}
+		return fsNode
+	case TokenTypeYield:
+		ysNode := p.parseYieldStatement()
+		if ysNode == nil {
+			return nil
+		}
+		return ysNode
 	default:
 		es := p.parseExpressionStatement()
 		if es == nil {
@@ -57,9 +76,15 @@ func (p *Parser) parseStatement() ast.Statement {
 }
 
 func (p *Parser) parseAssignmentExpression(left ast.ExpressionNode) ast.ExpressionNode {
-	switch left.(type) {
+	switch l := left.(type) {
 	case *ast.Identifier, *ast.IndexExpression, *ast.MemberAccessExpression, *ast.DotOperator:
 		// Assignable
+	case *ast.PrefixExpression:
+		// Only a dereference is assignable: *p = v stores through p
+		if l.Operator != "*" {
+			p.errors = append(p.errors, fmt.Sprintf("Invalid left-hand side in assignment near line %d: %s", p.currentToken.Line, left.String()))
+			return nil
+		}
 	default:
 		p.errors = append(p.errors, fmt.Sprintf("Invalid left-hand side in assignment near line %d: %s", p.currentToken.Line, left.String()))
 		return nil
@@ -86,6 +111,15 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 		return nil
 	}
 	stmt.Name = &ast.Identifier{Token: p.currentToken, Value: p.currentToken.Literal}
+	if p.peekTokenIs(TokenTypeColon) {
+		p.nextToken() // ':'
+		p.nextToken() // start of the type
+		stmt.Type = p.parseTypeName()
+		if stmt.Type == nil {
+			p.advanceToRecoveryPoint()
+			return nil
+		}
+	}
 	if !p.expectPeek(TokenTypeAssignment) {
 		p.advanceToRecoveryPoint()
 		return nil
@@ -117,6 +151,29 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	return stmt
 }
 
+// isThreadLocal reports whether the current token is the contextual word
+// 'thread_local' preceding a let.
+func (p *Parser) isThreadLocal() bool {
+	return p.currentToken.Type == TokenTypeIdentifier && p.currentToken.Literal == "thread_local" &&
+		(p.peekTokenIs(TokenTypeLet) || p.peekTokenIs(TokenTypeConst))
+}
+
+// parseThreadLocalStatement parses 'thread_local let name = value;'. Constants
+// cannot be thread-local, as they are never stored.
+func (p *Parser) parseThreadLocalStatement() *ast.LetStatement {
+	line := p.currentToken.Line
+	p.nextToken() // 'let'
+	if p.currentTokenIs(TokenTypeConst) {
+		p.errors = append(p.errors, fmt.Sprintf("thread_local cannot be applied to const near line %d", line))
+	}
+	stmt := p.parseLetStatement()
+	if stmt == nil {
+		return nil
+	}
+	stmt.ThreadLocal = true
+	return stmt
+}
+
 func (p *Parser) parseReturnStatement() ast.ExpressionNode {
 	stmt := &ast.ReturnStatement{Token: p.currentToken}
 	p.nextToken()
@@ -146,6 +203,34 @@ func (p *Parser) parseReturnStatement() ast.ExpressionNode {
 	return stmt
 }
 
+// parseYieldStatement parses 'yield value;'.
+func (p *Parser) parseYieldStatement() *ast.YieldStatement {
+	stmt := &ast.YieldStatement{Token: p.currentToken}
+	if p.peekTokenIs(TokenTypeSemicolon) || p.peekTokenIs(TokenTypeRightBrace) {
+		p.errors = append(p.errors, fmt.Sprintf("yield needs a value near line %d", p.currentToken.Line))
+		p.nextToken()
+		p.advanceToRecoveryPoint()
+		return nil
+	}
+	p.nextToken()
+	errorsBeforeExpr := len(p.errors)
+	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		if !p.errorsEncounteredSince(errorsBeforeExpr) {
+			p.errors = append(p.errors, fmt.Sprintf("Failed to parse yield value at line %d", p.currentToken.Line))
+		}
+		p.advanceToRecoveryPoint()
+		return nil
+	}
+	if p.peekTokenIs(TokenTypeSemicolon) {
+		p.nextToken() // advance to ';'
+	}
+	if p.currentTokenIs(TokenTypeSemicolon) {
+		p.nextToken() // advance past ';'
+	}
+	return stmt
+}
+
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	if p.currentTokenIs(TokenTypeSemicolon) {
 		p.nextToken() // Consume the semicolon
@@ -157,6 +242,14 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	if stmt.Expression == nil {
 		return nil
 	}
+	// An asm block ends on its own '}', which closes the statement
+	if asm, isAsm := stmt.Expression.(*ast.AssemblyExpression); isAsm && asm.Block {
+		p.nextToken()
+		if p.currentTokenIs(TokenTypeSemicolon) {
+			p.nextToken()
+		}
+		return stmt
+	}
 	// BlockStatement prefix fn already advances past '}'; don't double-advance
 	if _, isBlock := stmt.Expression.(*ast.BlockStatement); !isBlock {
 		if !p.currentTokenIs(TokenTypeSemicolon) && !p.currentTokenIs(TokenTypeRightBrace) && !p.currentTokenIs(TokenTypeEOF) {
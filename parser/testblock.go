@@ -0,0 +1,31 @@
+package parser
+
+import (
+	"compiler/ast"
+	. "compiler/lexer"
+	"fmt"
+)
+
+// isTestBlock reports whether the parser is at `test "name"`. 'test' is
+// only a keyword there, so it remains usable as a name.
+func (p *Parser) isTestBlock() bool {
+	return p.currentToken.Type == TokenTypeIdentifier && p.currentToken.Literal == "test" && p.peekTokenIs(TokenTypeString)
+}
+
+// parseTestBlock parses `test "name" { ... }`, leaving the cursor after the
+// closing '}'.
+func (p *Parser) parseTestBlock() *ast.TestBlock {
+	tb := &ast.TestBlock{Token: p.currentToken}
+	p.nextToken()
+	tb.Name = &ast.StringLiteral{Token: p.currentToken, Value: p.currentToken.Literal}
+	if !p.expectPeek(TokenTypeLeftBrace) {
+		return nil
+	}
+	body, ok := p.parseBlockStatement().(*ast.BlockStatement)
+	if !ok {
+		p.errors = append(p.errors, fmt.Sprintf("expected a block for test \"%s\" near line %d", tb.Name.Value, tb.Token.Line+1))
+		return nil
+	}
+	tb.Body = body
+	return tb
+}
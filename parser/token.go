@@ -6,12 +6,13 @@ import (
 )
 
 func (p *Parser) nextToken() {
+	p.markErrors()
 	p.currentToken = p.peekToken
 	p.peekToken = p.peekToken2
 	p.peekToken2 = p.peekToken3
 	p.peekToken3 = p.peekToken4
 	p.peekToken4 = p.peekToken5
-	nextTokenFromLexer, lexErr := p.lexer.NextToken()
+	nextTokenFromLexer, lexErr := p.readToken()
 
 	p.peekToken5 = nextTokenFromLexer
 
@@ -30,6 +31,17 @@ func (p *Parser) nextToken() {
 	}
 }
 
+// readToken returns the next token from the lookahead overflow buffer if
+// peekTokenAtIndex has pulled tokens ahead, and from the lexer otherwise.
+func (p *Parser) readToken() (LangToken, error) {
+	if len(p.overflow) > 0 {
+		next := p.overflow[0]
+		p.overflow = p.overflow[1:]
+		return next.tok, next.err
+	}
+	return p.lexer.NextToken()
+}
+
 func (p *Parser) currentTokenIs(t TokenType) bool {
 	return p.currentToken.Type == t
 }
@@ -61,6 +73,20 @@ func (p *Parser) peekTokenAtIndex(index int) LangToken {
 	case 5:
 		return p.peekToken5
 	default:
+		// Look further ahead than the fixed peek slots by buffering tokens.
+		for len(p.overflow) < index-5 {
+			if len(p.overflow) > 0 && p.overflow[len(p.overflow)-1].tok.Type == TokenTypeEOF {
+				break
+			}
+			if len(p.overflow) == 0 && p.peekToken5.Type == TokenTypeEOF {
+				break
+			}
+			tok, err := p.lexer.NextToken()
+			p.overflow = append(p.overflow, bufferedToken{tok: tok, err: err})
+		}
+		if index-6 < len(p.overflow) {
+			return p.overflow[index-6].tok
+		}
 		return LangToken{Type: TokenTypeEOF, Literal: "", Line: 0, Pos: 0, Length: 0}
 	}
 }
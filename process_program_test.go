@@ -0,0 +1,93 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestProcessProgram starts programs with stdlib/process and checks their
+// exit statuses and captured output.
+func TestProcessProgram(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected []string
+	}{
+		{
+			name: "spawn and wait",
+			body: `
+			let st = process.wait(process.spawn("/bin/true"));
+			print(process.exited(st));
+			print(process.exitCode(st));
+			st = process.wait(process.spawn("/bin/sh", "-c", "exit 3"));
+			print(process.exitCode(st));`,
+			expected: []string{"true", "0", "3"},
+		},
+		{
+			name: "signal",
+			body: `
+			let st = process.wait(process.spawn("/bin/sh", "-c", "kill -9 $$"));
+			print(process.signaled(st));
+			print(process.termSignal(st));
+			print(process.run("/bin/sh", "-c", "kill -15 $$").code);`,
+			expected: []string{"true", "9", "143"},
+		},
+		{
+			name: "run captures output",
+			body: `
+			let r = process.run("/bin/echo", "hello", "world");
+			print(r.code);
+			print(r.stdout);
+			print(r.stdoutLen);
+			r = process.run("/bin/sh", "-c", "echo out; echo err 1>&2; exit 5");
+			printf("%d [%s] [%s]\n", r.code, r.stdout, r.stderr);`,
+			expected: []string{"0", "hello world", "12", "5 [out", "] [err", "]"},
+		},
+		{
+			name: "output larger than a pipe",
+			body: `
+			let r = process.run("/bin/sh", "-c", "i=0; while [ $i -lt 2000 ]; do echo 0123456789012345678901234567890123456789 1>&2; i=$((i+1)); done; echo done");
+			printf("%d %d %s", r.code, r.stderrLen, r.stdout);`,
+			expected: []string{"0 82000 done"},
+		},
+		{
+			name: "missing program",
+			body: `
+			print(process.run("/nonexistent").code);
+			print(process.exitCode(process.wait(process.spawn("/nonexistent"))));`,
+			expected: []string{"127", "127"},
+		},
+		{
+			name: "output order",
+			body: `
+			printf("before\n");
+			process.wait(process.spawn("/bin/echo", "child"));
+			printf("after\n");`,
+			expected: []string{"before", "child", "after"},
+		},
+		{
+			name: "output before exec",
+			body: `
+			printf("before exec\n");
+			process.exec("/bin/echo", "from echo");`,
+			expected: []string{"before exec", "from echo"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := `
+			import "stdlib/process";
+			import "stdlib/fmt";
+			import "stdlib/core/print";
+
+			main() -> {` + tt.body + `
+				return 0;
+			}`
+			lines := runLines(t, buildFreestanding(t, input))
+			if strings.Join(lines, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("program output mismatch\nGot:\n%s\nWant:\n%s", strings.Join(lines, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+}
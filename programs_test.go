@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	c "compiler/compiler"
+	l "compiler/lexer"
+	p "compiler/parser"
+)
+
+var update = flag.Bool("update", false, "rewrite the expectations of testdata/programs")
+
+// Headers of the programs in testdata/programs. Each expect-stdout line is
+// one line of the expected output, whose trailing spaces do not matter;
+// expect-exit is the exit status, 0 when absent, or 128 plus the number of
+// the signal that ends the program; and expect-error is a substring of the
+// error the program fails to compile with.
+const (
+	expectStdout = "// expect-stdout:"
+	expectExit   = "// expect-exit:"
+	expectError  = "// expect-error:"
+)
+
+// expectation is the behaviour the headers of a program describe.
+type expectation struct {
+	stdout []string
+	exit   int
+	err    string
+}
+
+// parseExpectation reads the headers of src.
+func parseExpectation(src string) (expectation, error) {
+	var e expectation
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, expectStdout):
+			e.stdout = append(e.stdout, strings.TrimPrefix(strings.TrimPrefix(line, expectStdout), " "))
+		case strings.HasPrefix(line, expectExit):
+			code, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, expectExit)))
+			if err != nil {
+				return e, fmt.Errorf("bad %s header: %v", expectExit, err)
+			}
+			e.exit = code
+		case strings.HasPrefix(line, expectError):
+			e.err = strings.TrimSpace(strings.TrimPrefix(line, expectError))
+		}
+	}
+	return e, nil
+}
+
+// headers returns the header lines describing e.
+func (e expectation) headers() []string {
+	if e.err != "" {
+		return []string{expectError + " " + e.err}
+	}
+	var lines []string
+	for _, out := range e.stdout {
+		lines = append(lines, strings.TrimRight(expectStdout+" "+out, " "))
+	}
+	if e.exit != 0 {
+		lines = append(lines, fmt.Sprintf("%s %d", expectExit, e.exit))
+	}
+	return lines
+}
+
+// rewriteExpectation replaces the headers of src with those of e, where
+// the first header was, or after the comment the file starts with.
+func rewriteExpectation(src string, e expectation) string {
+	var out []string
+	at := -1
+	for _, line := range strings.Split(src, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, expectStdout) || strings.HasPrefix(trimmed, expectExit) || strings.HasPrefix(trimmed, expectError) {
+			if at < 0 {
+				at = len(out)
+			}
+			continue
+		}
+		out = append(out, line)
+	}
+	if at < 0 {
+		at = 0
+		for at < len(out) && strings.HasPrefix(strings.TrimSpace(out[at]), "//") {
+			at++
+		}
+	}
+	out = append(out[:at], append(e.headers(), out[at:]...)...)
+	return strings.Join(out, "\n")
+}
+
+// runProgram compiles src freestanding, links it and runs it. A program that
+// does not compile yields an expectation with only its error set, the first
+// line of the message.
+func runProgram(t *testing.T, src string) expectation {
+	t.Helper()
+	lexer, err := l.NewLexerFromString(src)
+	if err != nil {
+		return expectation{err: firstLine(err.Error())}
+	}
+	parser := p.NewParser(lexer)
+	program := parser.ParseProgram()
+	if errs := parser.Errors(); len(errs) != 0 {
+		return expectation{err: firstLine(errs[0])}
+	}
+	compilerInstance := c.NewCompiler(c.LLVM, nil)
+	compilerInstance.Freestanding = true
+	result := compilerInstance.Compile(program)
+	if len(result.Errors) != 0 {
+		return expectation{err: firstLine(result.Errors[0])}
+	}
+
+	tmpDir := t.TempDir()
+	irFile := filepath.Join(tmpDir, "program.ll")
+	if err := os.WriteFile(irFile, []byte(result.Output), 0o644); err != nil {
+		t.Fatalf("Failed to write IR file: %v", err)
+	}
+	exeFile := filepath.Join(tmpDir, "program")
+	clangCmd := exec.Command("clang", irFile, "-o", exeFile, "-nostdlib", "-static")
+	if out, err := clangCmd.CombinedOutput(); err != nil {
+		t.Fatalf("clang compilation failed: %v\nOutput:\n%s", err, string(out))
+	}
+
+	var got expectation
+	output, err := exec.Command(exeFile).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		got.exit = exitErr.ExitCode()
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			got.exit = 128 + int(status.Signal())
+		}
+	} else if err != nil {
+		t.Fatalf("Program execution failed: %v", err)
+	}
+	if text := strings.TrimSuffix(string(output), "\n"); text != "" {
+		for _, line := range strings.Split(text, "\n") {
+			got.stdout = append(got.stdout, strings.TrimRight(line, " \t"))
+		}
+	}
+	return got
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return strings.TrimSpace(line)
+}
+
+// TestPrograms compiles, links and runs every program in testdata/programs
+// and compares its output and exit status, or its compile error, with the
+// expectations in its headers. Run with -update to rewrite the headers from
+// what the programs do now.
+func TestPrograms(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "programs", "*.y"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no programs in testdata/programs")
+	}
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".y"), func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := parseExpectation(string(src))
+			if err != nil {
+				t.Fatalf("%s: %v", file, err)
+			}
+			got := runProgram(t, string(src))
+
+			if *update {
+				// New headers may move the lines an error message refers
+				// to, so run the program again until they settle.
+				updated := rewriteExpectation(string(src), got)
+				for i := 0; i < 3 && updated != string(src); i++ {
+					src = []byte(updated)
+					updated = rewriteExpectation(updated, runProgram(t, updated))
+				}
+				if err := os.WriteFile(file, []byte(updated), 0o644); err != nil {
+					t.Fatalf("Failed to update %s: %v", file, err)
+				}
+				return
+			}
+
+			if want.err != "" {
+				if got.err == "" {
+					t.Fatalf("expected a compile error containing %q, but the program compiled", want.err)
+				}
+				if !strings.Contains(got.err, want.err) {
+					t.Fatalf("expected a compile error containing %q, got %q", want.err, got.err)
+				}
+				return
+			}
+			if got.err != "" {
+				t.Fatalf("compile error: %s", got.err)
+			}
+			if strings.Join(got.stdout, "\n") != strings.Join(want.stdout, "\n") {
+				t.Errorf("stdout differs.\nexpected:\n%s\ngot:\n%s", strings.Join(want.stdout, "\n"), strings.Join(got.stdout, "\n"))
+			}
+			if got.exit != want.exit {
+				t.Errorf("expected exit status %d, got %d", want.exit, got.exit)
+			}
+		})
+	}
+}
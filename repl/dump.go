@@ -0,0 +1,79 @@
+package repl
+
+import (
+	"compiler/lexer"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// maxDumpDepth bounds the nesting dumpAST prints.
+const maxDumpDepth = 64
+
+var tokenType = reflect.TypeOf(lexer.LangToken{})
+
+// dumpAST prints the syntax tree of node, one node per line: its type and
+// its scalar fields, then its children indented under the name of the field
+// holding them.
+func dumpAST(w io.Writer, node any) {
+	dumpValue(w, "", reflect.ValueOf(node), 0)
+}
+
+func dumpValue(w io.Writer, label string, v reflect.Value, depth int) {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	indent := strings.Repeat("  ", depth)
+	if v.Kind() != reflect.Struct {
+		fmt.Fprintf(w, "%s%s%v\n", indent, label, v.Interface())
+		return
+	}
+	if depth > maxDumpDepth {
+		fmt.Fprintf(w, "%s%s...\n", indent, label)
+		return
+	}
+
+	t := v.Type()
+	line := []string{t.Name()}
+	type child struct {
+		name string
+		v    reflect.Value
+	}
+	var children []child
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		if !f.IsExported() || f.Type == tokenType {
+			continue
+		}
+		switch fv.Kind() {
+		case reflect.String:
+			if fv.String() != "" {
+				line = append(line, fmt.Sprintf("%s=%q", f.Name, fv.String()))
+			}
+		case reflect.Bool:
+			if fv.Bool() {
+				line = append(line, f.Name)
+			}
+		case reflect.Int, reflect.Int64, reflect.Float64:
+			if !fv.IsZero() {
+				line = append(line, fmt.Sprintf("%s=%v", f.Name, fv.Interface()))
+			}
+		case reflect.Slice:
+			for j := 0; j < fv.Len(); j++ {
+				children = append(children, child{fmt.Sprintf("%s[%d]", f.Name, j), fv.Index(j)})
+			}
+		case reflect.Pointer, reflect.Interface, reflect.Struct:
+			if !fv.IsZero() {
+				children = append(children, child{f.Name, fv})
+			}
+		}
+	}
+	fmt.Fprintf(w, "%s%s%s\n", indent, label, strings.Join(line, " "))
+	for _, c := range children {
+		dumpValue(w, c.name+": ", c.v, depth+1)
+	}
+}
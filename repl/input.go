@@ -0,0 +1,138 @@
+package repl
+
+import (
+	"compiler/ast"
+	"compiler/lexer"
+	"compiler/parser"
+	"fmt"
+	"strings"
+)
+
+// complete reports whether src is a whole entry: its braces, parentheses and
+// brackets balance and no string or comment is left open. Input the lexer
+// rejects otherwise is complete, for the parser to report.
+func complete(src string) bool {
+	l, err := lexer.NewLexerFromString(src)
+	if err != nil {
+		return true
+	}
+	depth := 0
+	for {
+		tok, err := l.NextToken()
+		if err != nil {
+			return !strings.Contains(err.Error(), "unterminated")
+		}
+		switch tok.Type {
+		case lexer.TokenTypeEOF:
+			return depth <= 0
+		case lexer.TokenTypeLeftBrace, lexer.TokenTypeLeftParenthesis, lexer.TokenTypeLeftBracket:
+			depth++
+		case lexer.TokenTypeRightBrace, lexer.TokenTypeRightParenthesis, lexer.TokenTypeRightBracket:
+			depth--
+		}
+	}
+}
+
+// tokens returns the tokens of src, up to the first the lexer rejects.
+func tokens(src string) []lexer.LangToken {
+	l, err := lexer.NewLexerFromString(src)
+	if err != nil {
+		return nil
+	}
+	var toks []lexer.LangToken
+	for {
+		tok, err := l.NextToken()
+		if err != nil || tok.Type == lexer.TokenTypeEOF {
+			return toks
+		}
+		toks = append(toks, tok)
+	}
+}
+
+// isDeclaration reports whether src declares something at the top level of a
+// program, as parser.ParseProgram tells declarations apart, rather than being
+// statements. A let at the start of an entry is a statement, binding a local
+// of the session.
+func isDeclaration(src string) bool {
+	toks := tokens(src)
+	if len(toks) == 0 {
+		return false
+	}
+	first := toks[0]
+	switch first.Type {
+	case lexer.TokenTypeImport, lexer.TokenTypeFunction, lexer.TokenTypeType, lexer.TokenTypeData:
+		return true
+	case lexer.TokenTypeIdentifier:
+	default:
+		return false
+	}
+	if len(toks) < 2 {
+		return false
+	}
+	switch toks[1].Type {
+	case lexer.TokenTypeType:
+		// packed type Name { ... } and extern type Name { ... }
+		return first.Literal == "packed" || first.Literal == "extern"
+	case lexer.TokenTypeArrow:
+		return true
+	case lexer.TokenTypeAssignment:
+		// Name = data { ... } and the tuple-like Name = { ... }
+		return len(toks) > 2 && (toks[2].Type == lexer.TokenTypeData || toks[2].Type == lexer.TokenTypeLeftBrace)
+	case lexer.TokenTypeLeftParenthesis:
+		// name(params) -> { ... } and name(params): T -> { ... }, as opposed
+		// to a call.
+		depth := 0
+		for i := 1; i < len(toks); i++ {
+			switch toks[i].Type {
+			case lexer.TokenTypeLeftParenthesis:
+				depth++
+			case lexer.TokenTypeRightParenthesis:
+				depth--
+				if depth == 0 {
+					return i+1 < len(toks) && (toks[i+1].Type == lexer.TokenTypeLambdaArrow || toks[i+1].Type == lexer.TokenTypeColon)
+				}
+			}
+		}
+	}
+	return first.Literal == "thread_local"
+}
+
+// parseProgram parses src as a program.
+func parseProgram(src string) (*ast.Program, error) {
+	l, err := lexer.NewLexerFromString(src)
+	if err != nil {
+		return nil, err
+	}
+	p := parser.NewParser(l)
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return program, nil
+}
+
+// parseStatements parses src as the statements of a function body: the body
+// of main, whose header takes a line of its own, so errors point at the
+// columns of src and at its lines counted from 1.
+func parseStatements(src string) ([]ast.Statement, error) {
+	l, err := lexer.NewLexerFromString("main() -> {\n" + src + "\n}")
+	if err != nil {
+		return nil, err
+	}
+	p := parser.NewParser(l)
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		// The errors after the first are mostly the parser recovering
+		// past the end of src.
+		return nil, fmt.Errorf("%s", errs[0])
+	}
+	if program.MainFunction == nil {
+		return nil, fmt.Errorf("expected statements")
+	}
+	body, ok := program.MainFunction.Body.(*ast.BlockStatement)
+	if !ok || len(program.Functions) > 0 || len(program.Globals) > 0 || len(program.ClassDeclarations) > 0 ||
+		len(program.DataStructures) > 0 || len(program.ImportStatements) > 0 {
+		return nil, fmt.Errorf("unexpected '}' closing no block")
+	}
+	return body.Statements, nil
+}
@@ -0,0 +1,409 @@
+// Package repl implements the interactive loop of ylang repl. It reads Y a
+// piece at a time and runs it with the interpreter: imports, functions and
+// types are declared for the rest of the session, and statements run as if
+// in the body of main, so the names their let statements bind stay defined.
+// An entry spans lines until its braces, parentheses and brackets balance.
+//
+// The value of an expression is printed with its type. Commands start with
+// a colon: :ast, :ir and :type show the syntax tree, the LLVM IR and the type
+// of what follows them, compiling the session so far with the code
+// generator, and :load declares the contents of a file.
+package repl
+
+import (
+	"bufio"
+	"compiler/ast"
+	"compiler/compiler/generator"
+	"compiler/compiler/interpreter"
+	"compiler/compiler/target"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+const help = `Enter statements and expressions to run them, and imports, functions and
+types to declare them. An entry continues over lines until its braces,
+parentheses and brackets balance.
+
+:ast code     print the syntax tree of code
+:ir [code]    print the LLVM IR of code, or of the functions declared so far
+:type expr    print the type of expr without running it
+:load file.y  declare the imports, functions, types and globals of file.y
+:help         print this help
+:quit         leave the REPL
+`
+
+// REPL is an interactive session.
+type REPL struct {
+	// Prompt and Continuation are printed before the first line of an entry
+	// and before each further line.
+	Prompt, Continuation string
+
+	out     io.Writer
+	target  *target.Target
+	in      *interpreter.Interpreter
+	session *interpreter.Session
+
+	// The session so far, compiled again by :ir and :type: what was
+	// declared, and the let statements run, which are all the statements
+	// after them need to compile.
+	program *ast.Program
+	lets    []ast.Statement
+	// declared names the functions declared in the session.
+	declared []string
+}
+
+// New starts a session running code with in, which is configured already,
+// for target t. Results and messages are written to out.
+func New(in *interpreter.Interpreter, t *target.Target, out io.Writer) *REPL {
+	if t == nil {
+		t = target.Default
+	}
+	return &REPL{
+		Prompt:       "y> ",
+		Continuation: "... ",
+		out:          out,
+		target:       t,
+		in:           in,
+		session:      in.NewSession(),
+		program:      &ast.Program{},
+	}
+}
+
+// Run reads entries from input until it ends, :quit is entered or the
+// program exits, and returns the exit status of the program.
+func (r *REPL) Run(input io.Reader) (int, error) {
+	scanner := bufio.NewScanner(input)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	var entry strings.Builder
+	fmt.Fprint(r.out, r.Prompt)
+	for scanner.Scan() {
+		if entry.Len() > 0 {
+			entry.WriteByte('\n')
+		}
+		entry.WriteString(scanner.Text())
+		if !complete(entry.String()) {
+			fmt.Fprint(r.out, r.Continuation)
+			continue
+		}
+		src := entry.String()
+		entry.Reset()
+		if !r.Eval(src) {
+			break
+		}
+		fmt.Fprint(r.out, r.Prompt)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	if status, exited := r.session.Exited(); exited {
+		return status, nil
+	}
+	return 0, r.session.Close()
+}
+
+// Eval runs one entry, a command or code, and prints what it produced. It
+// returns false when the session is over.
+func (r *REPL) Eval(src string) bool {
+	trimmed := strings.TrimSpace(src)
+	if strings.HasPrefix(trimmed, ":") {
+		name, arg, _ := strings.Cut(trimmed[1:], " ")
+		arg = strings.TrimSpace(arg)
+		switch name {
+		case "q", "quit":
+			return false
+		case "help":
+			fmt.Fprint(r.out, help)
+		case "ast":
+			r.report(r.ast(arg))
+		case "ir":
+			r.report(r.ir(arg))
+		case "type":
+			r.report(r.typeOf(arg))
+		case "load":
+			r.report(r.load(arg))
+		default:
+			fmt.Fprintf(r.out, "unknown command :%s; :help lists the commands\n", name)
+		}
+		return true
+	}
+	if trimmed != "" {
+		r.report(r.run(src))
+	}
+	_, exited := r.session.Exited()
+	return !exited
+}
+
+func (r *REPL) report(err error) {
+	if err != nil {
+		fmt.Fprintf(r.out, "error: %v\n", err)
+	}
+}
+
+// run declares or runs src.
+func (r *REPL) run(src string) error {
+	if isDeclaration(src) {
+		program, err := parseProgram(src)
+		if err != nil {
+			return err
+		}
+		return r.declare(program)
+	}
+	stmts, err := parseStatements(src)
+	if err != nil {
+		return err
+	}
+	if len(stmts) == 0 {
+		return nil
+	}
+	result, err := r.session.Exec(stmts)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		if ls, ok := stmt.(*ast.LetStatement); ok {
+			r.lets = append(r.lets, ls)
+		}
+	}
+	if result.Type != nil {
+		fmt.Fprintf(r.out, "%s : %s\n", result.Text, generator.TypeName(result.Type))
+	}
+	return nil
+}
+
+// declare adds the declarations of program to the session.
+func (r *REPL) declare(program *ast.Program) error {
+	if err := r.session.Declare(program); err != nil {
+		return err
+	}
+	p := r.program
+	p.ImportStatements = append(p.ImportStatements, program.ImportStatements...)
+	p.ClassDeclarations = append(p.ClassDeclarations, program.ClassDeclarations...)
+	p.DataStructures = append(p.DataStructures, program.DataStructures...)
+	p.Globals = append(p.Globals, program.Globals...)
+	for _, fn := range functions(program) {
+		name := fn.Name.Value
+		// A function declared again replaces the one before.
+		for i, old := range p.Functions {
+			if old.Name.Value == name {
+				p.Functions = append(p.Functions[:i:i], p.Functions[i+1:]...)
+				break
+			}
+		}
+		p.Functions = append(p.Functions, fn)
+		r.declared = appendName(r.declared, name)
+	}
+	for _, name := range methodNames(program) {
+		r.declared = appendName(r.declared, name)
+	}
+	return nil
+}
+
+// functions returns the functions program declares, main among them. The
+// session keeps main as a function like the others, called as main().
+func functions(program *ast.Program) []*ast.FunctionDefinition {
+	fns := program.Functions
+	if program.MainFunction != nil {
+		fns = append(fns[:len(fns):len(fns)], program.MainFunction)
+	}
+	return fns
+}
+
+// methodNames returns the names of the functions implementing the methods
+// of the types program declares.
+func methodNames(program *ast.Program) []string {
+	var names []string
+	for _, cd := range program.ClassDeclarations {
+		for _, member := range cd.Members {
+			if md := member.MethodDeclaration; md != nil {
+				names = append(names, cd.Name.Value+"_"+md.Name.Value)
+			}
+		}
+	}
+	return names
+}
+
+func appendName(names []string, name string) []string {
+	if contains(names, name) {
+		return names
+	}
+	return append(names, name)
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// load declares the contents of the file at path.
+func (r *REPL) load(path string) error {
+	if path == "" {
+		return fmt.Errorf(":load expects a file")
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	program, err := parseProgram(string(src))
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if err := r.declare(program); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if program.MainFunction != nil {
+		fmt.Fprintf(r.out, "loaded %s; main() runs its main function\n", path)
+	}
+	return nil
+}
+
+// ast prints the syntax tree of src.
+func (r *REPL) ast(src string) error {
+	if src == "" {
+		return fmt.Errorf(":ast expects code")
+	}
+	if isDeclaration(src) {
+		program, err := parseProgram(src)
+		if err != nil {
+			return err
+		}
+		for _, is := range program.ImportStatements {
+			dumpAST(r.out, is)
+		}
+		for _, cd := range program.ClassDeclarations {
+			dumpAST(r.out, cd)
+		}
+		for _, ds := range program.DataStructures {
+			dumpAST(r.out, ds)
+		}
+		for _, ls := range program.Globals {
+			dumpAST(r.out, ls)
+		}
+		if program.MainFunction != nil {
+			dumpAST(r.out, program.MainFunction)
+		}
+		for _, fn := range program.Functions {
+			dumpAST(r.out, fn)
+		}
+		return nil
+	}
+	stmts, err := parseStatements(src)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		dumpAST(r.out, stmt)
+	}
+	return nil
+}
+
+// ir prints the IR of the functions src declares, of the body standing in
+// for main when src is statements, or of the functions declared so far when
+// src is empty.
+func (r *REPL) ir(src string) error {
+	program := *r.program
+	names := r.declared
+	var extra []ast.Statement
+	switch {
+	case src == "":
+	case isDeclaration(src):
+		decls, err := parseProgram(src)
+		if err != nil {
+			return err
+		}
+		program.ImportStatements = append(program.ImportStatements[:len(program.ImportStatements):len(program.ImportStatements)], decls.ImportStatements...)
+		program.ClassDeclarations = append(program.ClassDeclarations[:len(program.ClassDeclarations):len(program.ClassDeclarations)], decls.ClassDeclarations...)
+		program.DataStructures = append(program.DataStructures[:len(program.DataStructures):len(program.DataStructures)], decls.DataStructures...)
+		program.Globals = append(program.Globals[:len(program.Globals):len(program.Globals)], decls.Globals...)
+		declFns := functions(decls)
+		names = nil
+		for _, fn := range declFns {
+			names = append(names, fn.Name.Value)
+		}
+		var fns []*ast.FunctionDefinition
+		for _, fn := range program.Functions {
+			if !contains(names, fn.Name.Value) {
+				fns = append(fns, fn)
+			}
+		}
+		program.Functions = append(fns, declFns...)
+		names = append(names, methodNames(decls)...)
+	default:
+		stmts, err := parseStatements(src)
+		if err != nil {
+			return err
+		}
+		extra = stmts
+		names = []string{entryName}
+	}
+
+	cg, err := r.compile(program, extra)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		for _, f := range cg.Module.Funcs {
+			if f.Name() == name {
+				fmt.Fprintln(r.out, f.LLString())
+			}
+		}
+	}
+	return nil
+}
+
+// typeOf prints the type of the expression src, compiling it after the
+// session so far rather than running it.
+func (r *REPL) typeOf(src string) error {
+	if src == "" {
+		return fmt.Errorf(":type expects an expression")
+	}
+	stmts, err := parseStatements(src)
+	if err != nil {
+		return err
+	}
+	if len(stmts) != 1 {
+		return fmt.Errorf(":type expects one expression")
+	}
+	cg, err := r.compile(*r.program, stmts)
+	if err != nil {
+		return err
+	}
+	t, ok := cg.Types[stmts[0]]
+	if !ok {
+		return fmt.Errorf("%s has no type", stmts[0])
+	}
+	fmt.Fprintln(r.out, generator.TypeName(t))
+	return nil
+}
+
+// entryName names the function the statements of the session are compiled
+// into; no function of a program can be named so.
+const entryName = "repl.entry"
+
+// compile compiles program, declared in the session, with the code
+// generator, recording the types of the statements. The let statements of
+// the session followed by extra are compiled as the body of a function of
+// their own.
+func (r *REPL) compile(program ast.Program, extra []ast.Statement) (*generator.CodeGenerator, error) {
+	body := append(append([]ast.Statement(nil), r.lets...), extra...)
+	program.Functions = append(program.Functions[:len(program.Functions):len(program.Functions)], &ast.FunctionDefinition{
+		Name: &ast.Identifier{Value: entryName},
+		Body: &ast.BlockStatement{Statements: body},
+	})
+
+	cg := generator.NewCodeGeneratorForTarget(r.target)
+	cg.ModuleManager = r.in.ModuleManager
+	cg.Freestanding = r.in.Freestanding
+	cg.Types = make(map[ast.Statement]types.Type)
+	if err := program.Accept(cg); err != nil {
+		return nil, err
+	}
+	return cg, nil
+}
@@ -0,0 +1,170 @@
+package repl
+
+import (
+	"bytes"
+	"compiler/compiler/interpreter"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runSession feeds input to a REPL without prompts and returns what it
+// printed and the exit status.
+func runSession(t *testing.T, input string) (string, int) {
+	t.Helper()
+	var out bytes.Buffer
+	in := interpreter.New(nil)
+	in.Stdin = strings.NewReader("")
+	in.Stdout = &out
+	in.ModuleManager.AddSearchPath(filepath.Join("..", "lib"))
+	r := New(in, nil, &out)
+	r.Prompt, r.Continuation = "", ""
+	status, err := r.Run(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return out.String(), status
+}
+
+// TestREPLSessions covers sessions: bindings and functions kept across
+// entries, entries spanning lines, values printed with their types, the
+// commands, and errors the session survives.
+func TestREPLSessions(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.y")
+	src := "let counter: i64 = 40;\nfunction bump(): i64 -> { counter = counter + 1; return counter; }\n"
+	if err := os.WriteFile(lib, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		input          string
+		expectedOutput string
+		expectedStatus int
+	}{
+		{
+			name:           "Bindings Persist",
+			input:          "let x = 5;\nx + 1\nx = x * 2;\nx\n",
+			expectedOutput: "6 : i32\n10 : i32\n10 : i32\n",
+		},
+		{
+			name:           "Functions Span Lines",
+			input:          "function sq(n: i64): i64 -> {\n    return n * n;\n}\nsq(7)\n",
+			expectedOutput: "49 : i64\n",
+		},
+		{
+			name:           "Redefined Function",
+			input:          "function f(): i64 -> { return 1; }\nf()\nfunction f(): i64 -> { return 2; }\nf()\n",
+			expectedOutput: "1 : i64\n2 : i64\n",
+		},
+		{
+			name:           "Values With Types",
+			input:          "\"hi\"\n1 < 2\n[1, 2, 3]\nlet sq = (n: i64) -> n * n;\nsq(3)\ntrue ? 4 : 5\n",
+			expectedOutput: "\"hi\" : string\ntrue : bool\n[1, 2, 3] : []i32\n9 : i64\n4 : i32\n",
+		},
+		{
+			name:           "Imports And Output",
+			input:          "import \"stdlib/fmt\";\nfmt.printf(\"%d-%s\\n\", 42, \"ok\");\n",
+			expectedOutput: "42-ok\n0 : i32\n",
+		},
+		{
+			name:           "Type Without Running",
+			input:          "let n: i64 = 3;\n:type n * 2\n:type \"s\"\nfunction boom(): i64 -> { let p = 0 as *i64; return p[0]; }\n:type boom\n:type boom()\n",
+			expectedOutput: "i64\nstring\n()->i64\ni64\n",
+		},
+		{
+			name:           "Syntax Tree",
+			input:          ":ast x + 1\n",
+			expectedOutput: "ExpressionStatement\n  Expression: InfixExpression Operator=\"+\"\n    Left: Identifier Value=\"x\"\n    Right: NumberLiteral Value=1\n",
+		},
+		{
+			name:           "IR",
+			input:          "function inc(a: i32): i32 -> { return a + 1; }\n:ir\n",
+			expectedOutput: "define i32 @inc(i32 %a) {\nentry:\n\t%a.addr = alloca i32\n\tstore i32 %a, i32* %a.addr\n\t%0 = load i32, i32* %a.addr\n\t%1 = add i32 %0, 1\n\tret i32 %1\n}\n",
+		},
+		{
+			name:           "Load",
+			input:          ":load " + lib + "\nbump()\nbump()\ncounter\n",
+			expectedOutput: "41 : i64\n42 : i64\n42 : i64\n",
+		},
+		{
+			name:           "Errors Are Survived",
+			input:          "let y = ;\nfunction boom(): i64 -> { let p = 0 as *i64; return p[0]; }\nboom()\n1 + 1\n:nope\n",
+			expectedOutput: "error: Operator ';' cannot start an expression at line 1, position 9\nerror: segmentation fault at address 0x0\n2 : i32\nunknown command :nope; :help lists the commands\n",
+		},
+		{
+			name:           "Undefined Names Are Reported",
+			input:          "undefined_thing\nfunction f(): i64 -> { return g(); }\nf()\nfunction g(): i64 -> { return 3; }\nf()\n",
+			expectedOutput: "error: undefined name 'undefined_thing'\nerror: error visiting function f: error generating body for function 'f': error evaluating function expression 'g': undefined name 'g'\n3 : i64\n",
+		},
+		{
+			name:           "Exit Ends The Session",
+			input:          "import \"stdlib/os\";\nos.exit(7);\n1\n",
+			expectedStatus: 7,
+		},
+		{
+			name:           "Quit",
+			input:          "1\n:quit\n2\n",
+			expectedOutput: "1 : i32\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, status := runSession(t, tt.input)
+			if out != tt.expectedOutput {
+				t.Errorf("output mismatch\nGot:\n%s\nWant:\n%s", out, tt.expectedOutput)
+			}
+			if status != tt.expectedStatus {
+				t.Errorf("exit status %d, want %d", status, tt.expectedStatus)
+			}
+		})
+	}
+}
+
+// TestComplete covers telling a whole entry from the first lines of one.
+func TestComplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 1;", true},
+		{"function f() -> {", false},
+		{"function f() -> {\n    return 1;\n}", true},
+		{"f(1,", false},
+		{"let s = \"{\";", true},
+		{"let s = \"abc", false},
+		{"/* a comment", false},
+		{"}", true},
+	}
+	for _, tt := range tests {
+		if got := complete(tt.input); got != tt.expected {
+			t.Errorf("complete(%q) = %v, want %v", tt.input, got, tt.expected)
+		}
+	}
+}
+
+// TestIsDeclaration covers telling declarations from statements.
+func TestIsDeclaration(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{`import "stdlib/fmt";`, true},
+		{"function f() -> { }", true},
+		{"f(x: i64): i64 -> { return x; }", true},
+		{"f(1)", false},
+		{"f(g(1)) + 2", false},
+		{"type P { let x: i64; }", true},
+		{"data D { let x: i64 }", true},
+		{"let x = 1;", false},
+		{"x = 2;", false},
+	}
+	for _, tt := range tests {
+		if got := isDeclaration(tt.input); got != tt.expected {
+			t.Errorf("isDeclaration(%q) = %v, want %v", tt.input, got, tt.expected)
+		}
+	}
+}
@@ -38,7 +38,7 @@ func TestSimpleMain(t *testing.T) {
 	// Print the AST for verification
 	t.Logf("AST:\n%s", program.MainFunction.String())
 
-	compilerInstance := c.NewCompiler(c.LLVM)
+	compilerInstance := c.NewCompiler(c.LLVM, nil)
 	result := compilerInstance.Compile(program)
 
 	if len(result.Errors) != 0 {
@@ -0,0 +1,24 @@
+// Integer and float arithmetic, precedence, division and remainder of
+// negative numbers, and the bitwise operators.
+// expect-stdout: 14 20 16
+// expect-stdout: 3 -3 1 -1
+// expect-stdout: 9223372036854775807
+// expect-stdout: 3.375 2.50
+// expect-stdout: 8 14 6 -1
+// expect-stdout: 1024 128 -4
+// expect-stdout: 4
+import "stdlib/fmt";
+
+main() -> {
+    printf("%d %d %d\n", 2 + 3 * 4, (2 + 3) * 4, 20 - 6 / 2 - 1);
+    printf("%d %d %d %d\n", 7 / 2, -7 / 2, 7 % 3, -7 % 3);
+    let big: i64 = 9223372036854775807;
+    printf("%d\n", big);
+    printf("%.3f %.2f\n", 1.5 * 2.25, 10.0 / 4.0);
+    printf("%d %d %d %d\n", 12 & 10, 12 | 10, 12 ^ 10, ~0);
+    printf("%d %d %d\n", 1 << 10, 1024 >> 3, -16 >> 2);
+    let x: u8 = 250;
+    let y = (x + 10) as u8;
+    printf("%d\n", y as i64);
+    return 0;
+}
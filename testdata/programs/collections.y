@@ -0,0 +1,26 @@
+// stdlib/collections hash maps, deques and string builders.
+// expect-stdout: 11 2 false 2
+// expect-stdout: 1 3
+// expect-stdout: n=42
+import "stdlib/fmt";
+import "stdlib/collections";
+
+main() -> {
+    let m = collections.newHashMap();
+    collections.mapPut(m, "one", 1);
+    collections.mapPut(m, "two", 2);
+    collections.mapPut(m, "one", 11);
+    printf("%d %d %v %d\n", collections.mapGet(m, "one"), collections.mapGet(m, "two"), collections.mapHas(m, "three"), collections.mapLen(m));
+
+    let d = collections.newDeque();
+    collections.pushBack(d, 2);
+    collections.pushFront(d, 1);
+    collections.pushBack(d, 3);
+    printf("%d %d\n", collections.popFront(d), collections.popBack(d));
+
+    let b = collections.newStringBuilder();
+    collections.append(b, "n=");
+    collections.appendInt(b, 42);
+    printf("%s\n", collections.toString(b));
+    return 0;
+}
@@ -0,0 +1,51 @@
+// if/else chains, while loops, nested loops and early returns.
+// expect-stdout: negative zero even odd
+// expect-stdout: 8
+// expect-stdout: 1
+// expect-stdout: 24
+// expect-stdout: 369
+// expect-stdout: sum 10
+import "stdlib/fmt";
+
+classify(n: i64): string -> {
+    if (n < 0) {
+        return "negative";
+    } else if (n == 0) {
+        return "zero";
+    } else if (n % 2 == 0) {
+        return "even";
+    }
+    return "odd";
+}
+
+firstSquareOver(limit: i64): i64 -> {
+    let i: i64 = 1;
+    while (true) {
+        if (i * i > limit) {
+            return i;
+        }
+        i = i + 1;
+    }
+    return -1;
+}
+
+main() -> {
+    printf("%s %s %s %s\n", classify(-3), classify(0), classify(4), classify(7));
+    printf("%d\n", firstSquareOver(50));
+    let row: i64 = 1;
+    while (row <= 3) {
+        let col: i64 = 1;
+        while (col <= row) {
+            printf("%d", row * col);
+            col = col + 1;
+        }
+        printf("\n");
+        row = row + 1;
+    }
+    let sum = 0;
+    for i in [1, 2, 3, 4] {
+        sum = sum + i;
+    }
+    printf("sum %d\n", sum);
+    return 0;
+}
@@ -0,0 +1,11 @@
+// A program killed by a signal reports 128 plus the signal number.
+// expect-exit: 139
+import "stdlib/fmt";
+
+main() -> {
+    printf("before\n");
+    let p: *i64 = 0 as *i64;
+    *p = 1;
+    printf("after\n");
+    return 0;
+}
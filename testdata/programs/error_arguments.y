@@ -0,0 +1,9 @@
+// Calls must supply every parameter without a default.
+// expect-error: error visiting main function: error generating body for function 'main': error evaluating arguments for call to 'add': missing argument for parameter 'b' in call to 'add' (declared at line 3)
+add(a: i64, b: i64): i64 -> {
+    return a + b;
+}
+
+main() -> {
+    return add(1);
+}
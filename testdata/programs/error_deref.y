@@ -0,0 +1,6 @@
+// Only pointers can be dereferenced.
+// expect-error: error visiting main function: error generating body for function 'main': cannot dereference 'x' of non-pointer type i64
+main() -> {
+    let x: i64 = 1;
+    return *x;
+}
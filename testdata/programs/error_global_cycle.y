@@ -0,0 +1,11 @@
+// Globals whose initializers refer to each other cannot be initialized.
+// expect-error: let 'first' at line 5: initialization cycle: 'first' refers to 'second', 'second' refers to 'first' at line 6
+import "stdlib/fmt";
+
+let first = second + 1;
+let second = first + 1;
+
+main() -> {
+    printf("%d\n", first);
+    return 0;
+}
@@ -0,0 +1,6 @@
+// A missing closing parenthesis is reported by the parser.
+// expect-error: expected next token to be RightParenthesis, got Semicolon (';') instead at line 4, position 19
+main() -> {
+    let x = (1 + 2;
+    return x;
+}
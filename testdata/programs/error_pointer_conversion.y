@@ -0,0 +1,7 @@
+// A pointer only converts to a pointer of another type with an explicit cast.
+// expect-error: error visiting main function: error generating body for function 'main': let 'p': cannot convert value of type i32* to i64* without a cast
+main() -> {
+    let x: i32 = 1;
+    let p: *i64 = &x;
+    return *p;
+}
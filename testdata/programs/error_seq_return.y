@@ -0,0 +1,21 @@
+// A seq cannot outlive the function holding its frame.
+// expect-error: error visiting function make: error generating body for function 'make': cannot return a seq created in this function: its frame lives on the function's stack
+function* count(n: i64): i64 -> {
+    let i: i64 = 0;
+    while (i < n) {
+        yield i;
+        i = i + 1;
+    }
+}
+
+function make(n: i64): seq<i64> -> {
+    return count(n);
+}
+
+main() -> {
+    let total: i64 = 0;
+    for x in make(3) {
+        total = total + x;
+    }
+    return total;
+}
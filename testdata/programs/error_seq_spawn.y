@@ -0,0 +1,17 @@
+// Only main may spawn a task it created, as the loop keeps the task.
+// expect-error: error visiting function start: error generating body for function 'start': cannot pass a seq created in this function to 'async.spawn', which keeps it: its frame lives on the function's stack
+import "stdlib/async";
+
+function* task(): i64 -> {
+    yield async.sleep(1);
+}
+
+function start(loop: *async.Loop) -> {
+    async.spawn(loop, task());
+}
+
+main() -> {
+    let loop = async.newLoop();
+    start(loop);
+    return async.run(loop);
+}
@@ -0,0 +1,27 @@
+// The value main returns becomes the exit status; os.exit flushes buffered
+// output before ending the program.
+// expect-stdout: 0
+// expect-stdout: 1
+// expect-stdout: 2
+// expect-stdout: 3
+// expect-stdout: too big: 3
+// expect-exit: 3
+import "stdlib/fmt";
+import "stdlib/os";
+
+check(n: i64) -> {
+    if (n > 2) {
+        printf("too big: %d\n", n);
+        os.exit(n);
+    }
+}
+
+main() -> {
+    let i: i64 = 0;
+    while (i < 10) {
+        printf("%d\n", i);
+        check(i);
+        i = i + 1;
+    }
+    return 0;
+}
@@ -0,0 +1,22 @@
+// The extremes of the integer verbs, unknown verbs, println and releasing
+// the result of format.
+// expect-stdout: -9223372036854775808 9223372036854775807
+// expect-stdout: [ff] [FF] [  ABC] [-00000ff] [-8000000000000000]
+// expect-stdout: %!q(BADVERB) 42 %!d(MISSING)
+// expect-stdout: a 1 2.5 true -9223372036854775808
+// expect-stdout: 7-up 4
+import "stdlib/fmt";
+import "stdlib/core/string";
+
+main() -> {
+    let min: i64 = -9223372036854775807 - 1;
+    let max: i64 = 9223372036854775807;
+    printf("%d %d\n", min, max);
+    printf("[%x] [%X] [%5X] [%08x] [%x]\n", 255, 255, 2748, -255, min);
+    printf("%q %d %d\n", 5, 42);
+    println("a", 1, 2.5, true, min);
+    let msg = format("%d-%s", 7, "up");
+    printf("%s %d\n", msg, strlen(msg));
+    heapFree(msg);
+    return 0;
+}
@@ -0,0 +1,41 @@
+// Generators driven by for-in loops and by hand.
+// expect-stdout: 2 3 5 7 11 13 17 19 23 29
+// expect-stdout: 0;4;8;
+import "stdlib/fmt";
+
+function* range2(from: i64, to: i64, step: i64): i64 -> {
+    let i = from;
+    while (i < to) {
+        yield i;
+        i = i + step;
+    }
+}
+
+function* primes(limit: i64): i64 -> {
+    for n in range2(2, limit, 1) {
+        let prime = true;
+        let d: i64 = 2;
+        while (d * d <= n) {
+            if (n % d == 0) {
+                prime = false;
+            }
+            d = d + 1;
+        }
+        if (prime) {
+            yield n;
+        }
+    }
+}
+
+main() -> {
+    for p in primes(30) {
+        printf("%d ", p);
+    }
+    printf("\n");
+    let g = range2(0, 10, 4);
+    while (g.next()) {
+        printf("%d;", g.current());
+    }
+    printf("\n");
+    return 0;
+}
@@ -0,0 +1,40 @@
+// Constants, globals with constant and computed initializers, globals
+// updated by functions, and initializers naming declarations further down.
+// expect-stdout: 3 5 255
+// expect-stdout: 10 40
+// expect-stdout: 80 6
+import "stdlib/fmt";
+
+const LIMIT = 5;
+const MASK = 0xff;
+let counter: i64 = 0;
+let table: *i64 = makeTable();
+let last = table[LIMIT - 1] * SCALE;
+const SCALE = 2;
+const NEXT = LIMIT + 1;
+
+makeTable(): *i64 -> {
+    let t: *i64 = heapAlloc(8 * LIMIT) as *i64;
+    let i: i64 = 0;
+    while (i < LIMIT) {
+        t[i] = i * 10;
+        i = i + 1;
+    }
+    return t;
+}
+
+tick(): i64 -> {
+    counter = counter + 1;
+    return counter;
+}
+
+import "stdlib/mem";
+
+main() -> {
+    tick();
+    tick();
+    printf("%d %d %d\n", tick(), LIMIT, MASK);
+    printf("%d %d\n", table[1], table[LIMIT - 1]);
+    printf("%d %d\n", last, NEXT);
+    return 0;
+}
@@ -0,0 +1,8 @@
+// The smallest program that prints something.
+// expect-stdout: hello, world
+import "stdlib/fmt";
+
+main() -> {
+    printf("hello, world\n");
+    return 0;
+}
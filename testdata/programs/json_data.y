@@ -0,0 +1,20 @@
+// A data declaration round-tripped through stdlib/json.
+// expect-stdout: {"x":3,"y":-4,"label":"corner"}
+// expect-stdout: 1 2 a
+import "stdlib/fmt";
+import "stdlib/mem";
+import "stdlib/json";
+
+data Point { let x: i64, let y: i64, let label: string }
+
+main() -> {
+    let p = Point.new();
+    p.x = 3;
+    p.y = -4;
+    p.label = "corner";
+    let text = json.stringify(p.toJson(), 0);
+    printf("%s\n", text);
+    let q = Point.fromJson("{\"x\": 1, \"y\": 2, \"label\": \"a\"}");
+    printf("%d %d %s\n", q.x, q.y, q.label);
+    return 0;
+}
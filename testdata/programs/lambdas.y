@@ -0,0 +1,21 @@
+// Lambdas stored in variables and passed to functions.
+// expect-stdout: 49
+// expect-stdout: 16
+// expect-stdout: 81
+import "stdlib/fmt";
+
+apply(f: (i64) -> i64, x: i64): i64 -> {
+    return f(x);
+}
+
+twice(f: (i64) -> i64, x: i64): i64 -> {
+    return f(f(x));
+}
+
+main() -> {
+    let square = (x: i64) -> x * x;
+    printf("%d\n", apply(square, 7));
+    printf("%d\n", twice((x: i64) -> x + 3, 10));
+    printf("%d\n", twice(square, 3));
+    return 0;
+}
@@ -0,0 +1,31 @@
+// Default parameter values, named arguments and variadic parameters.
+// expect-stdout: hello, ann
+// expect-stdout: hi, bob
+// expect-stdout: hello, cy
+// expect-stdout: hello, cy
+// expect-stdout: 0 1 10
+import "stdlib/fmt";
+
+greet(name: string, greeting: string = "hello", times: i64 = 1) -> {
+    let i: i64 = 0;
+    while (i < times) {
+        printf("%s, %s\n", greeting, name);
+        i = i + 1;
+    }
+}
+
+sum(xs: ...i64): i64 -> {
+    let total: i64 = 0;
+    for x in xs {
+        total = total + x;
+    }
+    return total;
+}
+
+main() -> {
+    greet("ann");
+    greet("bob", "hi");
+    greet(times = 2, name = "cy");
+    printf("%d %d %d\n", sum(), sum(1), sum(1, 2, 3, 4));
+    return 0;
+}
@@ -0,0 +1,40 @@
+// Address-of, dereference, pointer arithmetic and extern struct layout.
+// expect-stdout: 2 1
+// expect-stdout: 9 4 4
+// expect-stdout: 42
+import "stdlib/fmt";
+import "stdlib/mem";
+
+extern type Pair {
+    let a: i64;
+    let b: i64;
+}
+
+swap(p: *i64, q: *i64) -> {
+    let t = *p;
+    *p = *q;
+    *q = t;
+}
+
+main() -> {
+    let x: i64 = 1;
+    let y: i64 = 2;
+    swap(&x, &y);
+    printf("%d %d\n", x, y);
+
+    let buf = heapAlloc(8 * 4) as *i64;
+    let i: i64 = 0;
+    while (i < 4) {
+        buf[i] = i * i;
+        i = i + 1;
+    }
+    printf("%d %d %d\n", *(buf + 3), buf[2], (buf + 4) - buf);
+
+    let pr = heapAlloc(16) as *Pair;
+    pr.a = 40;
+    pr.b = 2;
+    printf("%d\n", pr.a + pr.b);
+    heapFree(pr as *u8);
+    heapFree(buf as *u8);
+    return 0;
+}
@@ -0,0 +1,40 @@
+// Recursive and mutually recursive functions.
+// expect-stdout: 55 6765 832040
+// expect-stdout: true true
+// expect-stdout: 21
+import "stdlib/fmt";
+
+fib(n: i64): i64 -> {
+    if (n < 2) {
+        return n;
+    }
+    return fib(n - 1) + fib(n - 2);
+}
+
+isEven(n: i64): bool -> {
+    if (n == 0) {
+        return true;
+    }
+    return isOdd(n - 1);
+}
+
+isOdd(n: i64): bool -> {
+    if (n == 0) {
+        return false;
+    }
+    return isEven(n - 1);
+}
+
+gcd(a: i64, b: i64): i64 -> {
+    if (b == 0) {
+        return a;
+    }
+    return gcd(b, a % b);
+}
+
+main() -> {
+    printf("%d %d %d\n", fib(10), fib(20), fib(30));
+    printf("%v %v\n", isEven(10), isOdd(7));
+    printf("%d\n", gcd(1071, 462));
+    return 0;
+}
@@ -0,0 +1,33 @@
+// Array literals convert element by element to the slice type they
+// initialise or are passed for.
+// expect-stdout: 2 10
+// expect-stdout: 1.500000
+// expect-stdout: 10 20 30
+import "stdlib/fmt";
+
+sum(xs: []i64): i64 -> {
+    let total: i64 = 0;
+    for x in xs {
+        total = total + x;
+    }
+    return total;
+}
+
+average(xs: []f64): f64 -> {
+    let total: f64 = 0;
+    for x in xs {
+        total = total + x;
+    }
+    return total / xs.length;
+}
+
+main() -> {
+    let arr: []i64 = [1, 2, 3];
+    printf("%d %d\n", arr[1], sum([1, 2, 3, 4]));
+    printf("%f\n", average([1, 2]));
+    for v in arr.lazy().map((x: i64) -> x * 10) {
+        printf("%d ", v);
+    }
+    printf("\n");
+    return 0;
+}
@@ -0,0 +1,19 @@
+// String literals, escapes, indexing and formatting verbs.
+// expect-stdout: hello has 5 bytes, starts with 104
+// expect-stdout: [   ab] [ab   ] [ab]
+// expect-stdout: [00042] [ff] [str] [true]
+// expect-stdout: tab	quote" backslash\
+// expect-stdout: 7-up
+import "stdlib/fmt";
+import "stdlib/core/string";
+
+main() -> {
+    let s = "hello";
+    printf("%s has %d bytes, starts with %d\n", s, strlen(s), s[0] & 255);
+    printf("[%5s] [%-5s] [%.2s]\n", "ab", "ab", "abcdef");
+    printf("[%05d] [%x] [%v] [%v]\n", 42, 255, "str", true);
+    printf("tab\tquote\" backslash\\\n");
+    let msg = format("%d-%s", 7, "up");
+    printf("%s\n", msg);
+    return 0;
+}
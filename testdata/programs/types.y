@@ -0,0 +1,31 @@
+// Types with fields and methods, declared as `function RetType name(...)`;
+// methods receive the instance as self.
+// expect-stdout: 25
+// expect-stdout: 50
+import "stdlib/fmt";
+import "stdlib/mem";
+
+type Counter {
+    let count: i64;
+    let step: i64;
+
+    function i64 bump() -> {
+        self.count = self.count + self.step;
+        return self.count;
+    }
+
+    function i64 scaled(factor: i64) -> {
+        return self.count * factor;
+    }
+}
+
+main() -> {
+    let c = heapAlloc(16) as *Counter;
+    c.count = 10;
+    c.step = 5;
+    c.bump();
+    c.bump();
+    printf("%d\n", c.bump());
+    printf("%d\n", c.scaled(2));
+    return 0;
+}
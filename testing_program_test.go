@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"compiler/ast"
+	c "compiler/compiler"
+)
+
+// testedModule is a module with passing and failing test blocks.
+const testedModule = `
+add(a: i64, b: i64): i64 -> {
+	return a + b;
+}
+
+main() -> {
+	return 3;
+}
+
+test "add" {
+	assertEq(add(1, 2), 3);
+	assert(add(-1, 1) == 0);
+}
+
+test "add overflows" {
+	let x = add(2, 2);
+	assert(x == 5);
+}
+
+test "strings" {
+	assertEq("abc", "abd");
+}
+
+test "crash" {
+	let p: *i64 = 0 as *i64;
+	*p = 1;
+}
+`
+
+// buildTestHarness compiles the test blocks of the module at modulePath,
+// searched for in dir as well as the default directories, into a harness
+// and returns the path of the executable.
+func buildTestHarness(t *testing.T, modulePath, dir string) string {
+	t.Helper()
+	compilerInstance := c.NewCompiler(c.LLVM, nil)
+	compilerInstance.Freestanding = true
+	compilerInstance.TestModule = modulePath
+	if dir != "" {
+		compilerInstance.SearchPaths = []string{dir}
+	}
+	result := compilerInstance.Compile(&ast.Program{ImportStatements: []*ast.ImportStatement{{Path: modulePath}}})
+	if len(result.Errors) != 0 {
+		t.Fatalf("Compiler errors: %v", result.Errors)
+	}
+
+	tmpDir := t.TempDir()
+	irFile := filepath.Join(tmpDir, "harness.ll")
+	if err := os.WriteFile(irFile, []byte(result.Output), 0o644); err != nil {
+		t.Fatalf("Failed to write IR file: %v", err)
+	}
+	exeFile := filepath.Join(tmpDir, "harness")
+	clangCmd := exec.Command("clang", irFile, "-o", exeFile, "-nostdlib", "-static")
+	if out, err := clangCmd.CombinedOutput(); err != nil {
+		t.Fatalf("clang compilation failed: %v\nOutput:\n%s", err, string(out))
+	}
+	return exeFile
+}
+
+// durations matches the time a test took in the harness output.
+var durations = regexp.MustCompile(`\([0-9.]+[a-z]+\)`)
+
+// TestTestingProgram runs the test harness of a module with various
+// arguments and checks its report, including where assertions failed, and
+// its exit status. It also runs the tests of stdlib modules.
+func TestTestingProgram(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "calc.y"), []byte(testedModule), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "calc.y")
+
+	tests := []struct {
+		name           string
+		module         string
+		args           []string
+		expectedOutput string
+		expectedCode   int
+	}{
+		{
+			name:   "Failures only",
+			module: "calc",
+			expectedOutput: "    " + file + ":16: assertion failed: x == 5\n" +
+				"--- FAIL: add overflows (D)\n" +
+				"    " + file + ":20: assertion failed: assertEq(\"abc\", \"abd\")\n" +
+				"        got:  \"abc\"\n" +
+				"        want: \"abd\"\n" +
+				"--- FAIL: strings (D)\n" +
+				"    killed by signal 11\n" +
+				"--- FAIL: crash (D)\n" +
+				"FAIL\n1 passed, 3 failed, 0 filtered out\n",
+			expectedCode: 1,
+		},
+		{
+			name:   "Verbose and filtered",
+			module: "calc",
+			args:   []string{"-v", "--run", "add"},
+			expectedOutput: "=== RUN   add\n--- PASS: add (D)\n" +
+				"=== RUN   add overflows\n" +
+				"    " + file + ":16: assertion failed: x == 5\n" +
+				"--- FAIL: add overflows (D)\n" +
+				"FAIL\n1 passed, 1 failed, 2 filtered out\n",
+			expectedCode: 1,
+		},
+		{
+			name:           "Short run flag",
+			module:         "calc",
+			args:           []string{"-r", "crash"},
+			expectedOutput: "    killed by signal 11\n--- FAIL: crash (D)\nFAIL\n0 passed, 1 failed, 3 filtered out\n",
+			expectedCode:   1,
+		},
+		{
+			name:           "Nothing matches",
+			module:         "calc",
+			args:           []string{"--run", "nothing"},
+			expectedOutput: "PASS\n0 passed, 0 failed, 4 filtered out\n",
+		},
+		{
+			name:           "stdlib/path",
+			module:         "stdlib/path",
+			expectedOutput: "PASS\n5 passed, 0 failed, 0 filtered out\n",
+		},
+		{
+			name:           "stdlib/fs",
+			module:         "stdlib/fs",
+			args:           []string{"-v", "--run", "missing"},
+			expectedOutput: "=== RUN   missing file\n--- PASS: missing file (D)\nPASS\n1 passed, 0 failed, 2 filtered out\n",
+		},
+	}
+
+	harnesses := make(map[string]string)
+	for _, tt := range tests {
+		if _, ok := harnesses[tt.module]; !ok {
+			harnesses[tt.module] = buildTestHarness(t, tt.module, dir)
+		}
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := exec.Command(harnesses[tt.module], tt.args...).CombinedOutput()
+			code := 0
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				code = exitErr.ExitCode()
+			} else if err != nil {
+				t.Fatalf("Harness execution failed: %v", err)
+			}
+			output := durations.ReplaceAllString(string(out), "(D)")
+			if output != tt.expectedOutput {
+				t.Errorf("output wrong.\nexpected:\n%s\ngot:\n%s", tt.expectedOutput, output)
+			}
+			if code != tt.expectedCode {
+				t.Errorf("expected exit code %d, got %d", tt.expectedCode, code)
+			}
+		})
+	}
+}
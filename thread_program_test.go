@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestThreadProgram runs threads from stdlib/thread that share counters
+// through stdlib/atomic and stdlib/sync and keep their own thread_local
+// variables.
+func TestThreadProgram(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name: "atomics, mutex and thread locals",
+			input: `
+			import "stdlib/thread";
+			import "stdlib/sync";
+			import "stdlib/atomic";
+			import "stdlib/fmt";
+			import "stdlib/mem";
+
+			let hits: *i64 = alloc(8) as *i64;
+			let plain: *i64 = alloc(8) as *i64;
+			let mu: *sync.Mutex = sync.newMutex();
+			thread_local let mine: i64 = 100;
+
+			function work(n: i64): i64 -> {
+				let i = 0;
+				while (i < n) {
+					atomic.add(hits, 1);
+					sync.lock(mu);
+					plain[0] = plain[0] + 1;
+					sync.unlock(mu);
+					mine = mine + 1;
+					i = i + 1;
+				}
+				return mine;
+			}
+
+			main() -> {
+				// Initialize the shared globals before the threads use them.
+				hits; plain; mu;
+				let a = thread.spawn(work, 100000);
+				let b = thread.spawn(work, 50000);
+				let c = thread.spawn(work, 7);
+				printf("%d %d %d\n", thread.join(a), thread.join(b), thread.join(c));
+				printf("%d %d %d\n", hits[0], plain[0], mine);
+				return 0;
+			}`,
+			expected: []string{"100100 50100 107", "150007 150007 100"},
+		},
+		{
+			name: "condition variable and wait group",
+			input: `
+			import "stdlib/thread";
+			import "stdlib/sync";
+			import "stdlib/fmt";
+			import "stdlib/mem";
+
+			let mu: *sync.Mutex = sync.newMutex();
+			let ready: *sync.Cond = sync.newCond();
+			let wg: *sync.WaitGroup = sync.newWaitGroup();
+			let queued: *i64 = alloc(8) as *i64;
+			thread_local let scratch: *i64 = alloc(8) as *i64;
+
+			function produce(n: i64): i64 -> {
+				let i = 0;
+				while (i < n) {
+					sync.lock(mu);
+					queued[0] = queued[0] + 1;
+					sync.signal(ready);
+					sync.unlock(mu);
+					i = i + 1;
+				}
+				sync.done(wg);
+				return n;
+			}
+
+			function consume(n: i64): i64 -> {
+				let got = 0;
+				while (got < n) {
+					sync.lock(mu);
+					while (queued[0] == 0) {
+						sync.wait(ready, mu);
+					}
+					queued[0] = queued[0] - 1;
+					sync.unlock(mu);
+					got = got + 1;
+					scratch[0] = scratch[0] + 1;
+				}
+				sync.done(wg);
+				return scratch[0];
+			}
+
+			main() -> {
+				mu; ready; wg; queued;
+				sync.add(wg, 3);
+				let c = thread.spawn(consume, 2000);
+				let p1 = thread.spawn(produce, 1000);
+				let p2 = thread.spawn(produce, 1000);
+				sync.waitAll(wg);
+				printf("%d %d %d %d\n", thread.join(c), thread.join(p1), thread.join(p2), queued[0]);
+				// The consumer's lazily initialized thread_local was its own.
+				printf("%d\n", scratch[0]);
+				return 0;
+			}`,
+			expected: []string{"2000 1000 1000 0", "0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := runLines(t, buildFreestanding(t, tt.input))
+			if strings.Join(lines, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("program output mismatch\nGot:\n%s\nWant:\n%s", strings.Join(lines, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestTimeRandProgram covers the clocks, sleep and duration formatting of
+// stdlib/time and the generators of stdlib/rand, including xoshiro256**
+// reference values for a fixed seed.
+func TestTimeRandProgram(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name: "time",
+			input: `
+			import "stdlib/fmt";
+			import "stdlib/time";
+
+			function show(d: i64) -> {
+				printf("%s ", time.formatDuration(d));
+			}
+
+			main() -> {
+				show(0); show(7); show(1500); show(12000); show(2500000); show(1005000); show(999999999);
+				printf("\n");
+				show(1000000000); show(1500000000); show(63000000000); show(3723004000000); show(time.HOUR); show(-1500000);
+				printf("\n");
+				show(-9223372036854775807 - 1); show(9223372036854775807); show(-3723004000000);
+				printf("\n");
+				let start = time.monotonic();
+				printf("%d\n", time.sleep(20));
+				let elapsed = time.since(start);
+				printf("%d %d\n", elapsed >= 20 * time.MILLISECOND, elapsed < 5 * time.SECOND);
+				printf("%d %d %d\n", time.unix() > 1700000000, time.now() / time.SECOND - time.unix() <= 1, time.sleepNanos(-1));
+				return 0;
+			}`,
+			expected: []string{
+				"0s 7ns 1.5us 12us 2.5ms 1.005ms 999.999999ms ",
+				"1s 1.5s 1m3s 1h2m3.004s 1h0m0s -1.5ms ",
+				"-2562047h47m16.854775808s 2562047h47m16.854775807s -1h2m3.004s ",
+				"0",
+				"true true",
+				"true true 0",
+			},
+		},
+		{
+			name: "rand",
+			input: `
+			import "stdlib/fmt";
+			import "stdlib/mem";
+			import "stdlib/rand";
+
+			function showHex(h: i64) -> {
+				printf("%08x%08x\n", h >>> 32, h & 0xffffffff);
+			}
+
+			main() -> {
+				let r = rand.newSeededRand(42);
+				showHex(rand.next64(r));
+				showHex(rand.next64(r));
+				showHex(rand.next64(r));
+
+				// Each face of a die comes up about a sixth of the time.
+				let counts = mem.heapAlloc(6 * 8) as *i64;
+				let i: i64 = 0;
+				while (i < 60000) {
+					let k = rand.intn(r, 6);
+					counts[k] = counts[k] + 1;
+					i = i + 1;
+				}
+				let even = true;
+				i = 0;
+				while (i < 6) {
+					if (counts[i] < 9500 || counts[i] > 10500) { even = false; }
+					i = i + 1;
+				}
+				printf("%d %d %d\n", even, rand.intn(r, 0), rand.intn(r, 1));
+
+				let lo: i64 = 100;
+				let hi: i64 = 0;
+				let sum: f64 = 0.0;
+				let inRange = true;
+				i = 0;
+				while (i < 1000) {
+					let v = rand.between(r, 3, 5);
+					if (v < lo) { lo = v; }
+					if (v > hi) { hi = v; }
+					let x = rand.nextFloat(r);
+					if (x < 0.0 || x >= 1.0) { inRange = false; }
+					sum = sum + x;
+					i = i + 1;
+				}
+				printf("%d %d %d %d\n", lo, hi, inRange, sum > 450.0 && sum < 550.0);
+
+				let xs = mem.heapAlloc(10 * 8) as *i64;
+				i = 0;
+				while (i < 10) { xs[i] = i; i = i + 1; }
+				rand.shuffle(r, xs, 10);
+				let total: i64 = 0;
+				let moved: i64 = 0;
+				i = 0;
+				while (i < 10) {
+					total = total + xs[i];
+					if (xs[i] != i) { moved = moved + 1; }
+					i = i + 1;
+				}
+				let c = rand.choice(r, xs, 10);
+				printf("%d %d %d\n", total, moved > 0, c >= 0 && c < 10);
+
+				let a = rand.newRand();
+				let b = rand.newRand();
+				printf("%d\n", rand.next64(a) != rand.next64(b));
+				rand.freeRand(a);
+				rand.freeRand(b);
+				return 0;
+			}`,
+			expected: []string{
+				"15780b2e0c2ec716",
+				"6104d9866d113a7e",
+				"ae17533239e499a1",
+				"true 0 0",
+				"3 5 true true",
+				"45 true true",
+				"true",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := runLines(t, buildFreestanding(t, tt.input))
+			if strings.Join(lines, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("program output mismatch\nGot:\n%s\nWant:\n%s", strings.Join(lines, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+// Command mksyscalls generates the syscall number tables used by the code
+// generator from the kernel's unistd headers.
+//
+// Usage:
+//
+//	go run ./tools/mksyscalls -arch amd64 -header /usr/include/x86_64-linux-gnu/asm/unistd_64.h -o compiler/target/syscalls_linux_amd64_gen.go
+//
+// Architectures using the kernel's generic table (arm64, riscv64) define their
+// numbers conditionally, so the header is run through the C preprocessor with
+// the architecture's feature macros:
+//
+//	go run ./tools/mksyscalls -arch arm64 -header /usr/include/asm-generic/unistd.h -cpp "-D__ARCH_WANT_RENAMEAT" -o ...
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defineRe matches '#define __NR_name value' and '#define __NR3264_name value'
+// where value is a number, another __NR macro or '(__NR_macro + number)'.
+var defineRe = regexp.MustCompile(`^#define\s+(__NR(?:3264)?_\w+)\s+(.+?)\s*$`)
+
+var exprRe = regexp.MustCompile(`^\(?\s*(\w+)\s*(?:\+\s*(\d+))?\s*\)?$`)
+
+func main() {
+	arch := flag.String("arch", "", "target architecture name used in the table identifier, e.g. amd64")
+	header := flag.String("header", "", "path to the unistd header listing __NR_* numbers")
+	out := flag.String("o", "", "output Go file")
+	cppFlags := flag.String("cpp", "", "run the header through 'cpp -dM' with these space-separated flags")
+	extra := flag.String("extra", "", "comma-separated name=number pairs for architecture-specific syscalls")
+	flag.Parse()
+	if *arch == "" || *header == "" || *out == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	numbers, err := readNumbers(*header, *cppFlags)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mksyscalls: %v\n", err)
+		os.Exit(1)
+	}
+	if *extra != "" {
+		for _, pair := range strings.Split(*extra, ",") {
+			name, num, ok := strings.Cut(pair, "=")
+			n, err := strconv.ParseInt(num, 10, 64)
+			if !ok || err != nil {
+				fmt.Fprintf(os.Stderr, "mksyscalls: bad -extra entry %q\n", pair)
+				os.Exit(1)
+			}
+			numbers[name] = n
+		}
+	}
+
+	src, err := render(*arch, numbers)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mksyscalls: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*out, src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "mksyscalls: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// readNumbers reads the header, preprocessed first when cppFlags is set, and
+// returns the syscall numbers it defines.
+func readNumbers(path, cppFlags string) (map[string]int64, error) {
+	if cppFlags == "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return parseDefines(path, f)
+	}
+	args := append([]string{"-dM"}, strings.Fields(cppFlags)...)
+	out, err := exec.Command("cpp", append(args, path)...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("cpp %s: %w", path, err)
+	}
+	return parseDefines(path, bytes.NewReader(out))
+}
+
+// parseDefines collects the '#define __NR_name ...' lines of a unistd header
+// and resolves the values that refer to other macros.
+func parseDefines(path string, r io.Reader) (map[string]int64, error) {
+	macros := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if m := defineRe.FindStringSubmatch(scanner.Text()); m != nil {
+			macros[m[1]] = m[2]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	numbers := make(map[string]int64)
+	for macro := range macros {
+		name, ok := strings.CutPrefix(macro, "__NR_")
+		// __NR_syscalls is the table size, not a syscall.
+		if !ok || name == "syscalls" || name == "arch_specific_syscall" {
+			continue
+		}
+		n, err := resolve(macros, macro, 0)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		numbers[name] = n
+	}
+	if len(numbers) == 0 {
+		return nil, fmt.Errorf("%s: no __NR_* definitions found", path)
+	}
+	return numbers, nil
+}
+
+func resolve(macros map[string]string, macro string, depth int) (int64, error) {
+	if depth > 8 {
+		return 0, fmt.Errorf("macro %s is defined in terms of itself", macro)
+	}
+	m := exprRe.FindStringSubmatch(macros[macro])
+	if m == nil {
+		return 0, fmt.Errorf("cannot evaluate %s = %q", macro, macros[macro])
+	}
+	base, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		if _, known := macros[m[1]]; !known {
+			return 0, fmt.Errorf("%s refers to undefined macro %s", macro, m[1])
+		}
+		if base, err = resolve(macros, m[1], depth+1); err != nil {
+			return 0, err
+		}
+	}
+	if m[2] != "" {
+		offset, _ := strconv.ParseInt(m[2], 10, 64)
+		base += offset
+	}
+	return base, nil
+}
+
+func render(arch string, numbers map[string]int64) ([]byte, error) {
+	names := make([]string, 0, len(numbers))
+	for name := range numbers {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if numbers[names[i]] != numbers[names[j]] {
+			return numbers[names[i]] < numbers[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by tools/mksyscalls; DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package target\n\n")
+	fmt.Fprintf(&buf, "// syscallsLinux%s maps syscall names to their numbers on linux/%s.\n", exported(arch), arch)
+	fmt.Fprintf(&buf, "var syscallsLinux%s = map[string]int64{\n", exported(arch))
+	for _, name := range names {
+		fmt.Fprintf(&buf, "\t%q: %d,\n", name, numbers[name])
+	}
+	fmt.Fprintf(&buf, "}\n")
+	return format.Source(buf.Bytes())
+}
+
+// exported turns an architecture name into the form used in identifiers.
+func exported(arch string) string {
+	switch arch {
+	case "amd64":
+		return "AMD64"
+	case "arm64":
+		return "ARM64"
+	case "riscv64":
+		return "RISCV64"
+	}
+	if arch == "" {
+		return arch
+	}
+	return string(arch[0]-'a'+'A') + arch[1:]
+}